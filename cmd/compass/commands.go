@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/store"
+	"git.sr.ht/~jakintosh/compass/internal/vault"
+)
+
+// runCommand runs an admin subcommand against compass.db in the working
+// directory, instead of starting the server
+func runCommand(args []string) error {
+	switch args[0] {
+	case "merge-categories":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: compass merge-categories FROM INTO")
+		}
+		return mergeCategories(args[1], args[2])
+	case "import-vault":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: compass import-vault DIR CATEGORY")
+		}
+		return importVault(args[1], args[2])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// mergeCategories merges one category into another, each named by ID or by
+// name
+func mergeCategories(from, into string) error {
+	db, err := store.NewSQLiteStore("compass.db", true, domain.SystemClock{}, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	categories, err := db.GetCategories()
+	if err != nil {
+		return err
+	}
+	fromID, err := findCategory(categories, from)
+	if err != nil {
+		return err
+	}
+	intoID, err := findCategory(categories, into)
+	if err != nil {
+		return err
+	}
+
+	cat, err := db.MergeCategories(fromID, intoID, "cli")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Merged %s into %s, which now has %d tasks\n", from, cat.Name, len(cat.Tasks))
+	return nil
+}
+
+// importVault imports the checkboxes in the markdown vault at dir into a
+// category named by ID or by name
+func importVault(dir, into string) error {
+	tasks, err := vault.Parse(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("no checkboxes found in %s", dir)
+	}
+
+	db, err := store.NewSQLiteStore("compass.db", true, domain.SystemClock{}, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	categories, err := db.GetCategories()
+	if err != nil {
+		return err
+	}
+	intoID, err := findCategory(categories, into)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.ImportVault(intoID, tasks, "cli")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Found %d headings with checkboxes; added %d tasks and %d subtasks, completed %d subtasks\n",
+		len(tasks), result.TasksAdded, result.SubtasksAdded, result.Completed)
+	return nil
+}
+
+// findCategory resolves a category by ID, or by name if that is unambiguous
+func findCategory(categories []*domain.Category, ref string) (string, error) {
+	var matches []string
+	for _, c := range categories {
+		if c.ID == ref {
+			return c.ID, nil
+		}
+		if c.Name == ref {
+			matches = append(matches, c.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no category %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%d categories are named %q, use an ID", len(matches), ref)
+}
@@ -10,13 +10,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezone preferences must work on hosts without zoneinfo
 
 	"git.sr.ht/~jakintosh/consent/pkg/client"
 	contesting "git.sr.ht/~jakintosh/consent/pkg/testing"
 	"git.sr.ht/~jakintosh/consent/pkg/tokens"
-	"git.sr.ht/~jakintosh/compass/internal/store"
-	"git.sr.ht/~jakintosh/compass/internal/web"
+	"git.sr.ht/~jakintosh/compass"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/fixtures"
 )
 
 // getConfigValue returns the CLI flag value if set, otherwise falls back to env var.
@@ -27,31 +34,197 @@ func getConfigValue(flagVal, envKey string) string {
 	return os.Getenv(envKey)
 }
 
+// getSecretValue resolves a secret like getConfigValue, but the value can
+// also be read from a file, named by fileFlagVal or the envKey_FILE
+// variable, so it can be mounted from a secret store instead of passed
+// inline. Order: flag, file flag, env, env file.
+func getSecretValue(flagVal, fileFlagVal, envKey string) (string, error) {
+	if flagVal != "" {
+		return flagVal, nil
+	}
+	path := fileFlagVal
+	if path == "" {
+		if v := os.Getenv(envKey); v != "" {
+			return v, nil
+		}
+		path = os.Getenv(envKey + "_FILE")
+	}
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 func main() {
 	// Parse CLI flags
 	devMode := flag.Bool("dev", false, "Run in dev mode (no consent server needed)")
 	consentURL := flag.String("consent-url", "", "Consent server URL (env: CONSENT_URL)")
 	consentPubkey := flag.String("consent-pubkey", "", "Consent server public key PEM (env: CONSENT_PUBKEY)")
+	consentPubkeyFile := flag.String("consent-pubkey-file", "", "File holding the consent server public key PEM (env: CONSENT_PUBKEY_FILE)")
 	appID := flag.String("app-id", "", "Application identifier/audience (env: APP_ID)")
+	proxyAuth := flag.Bool("proxy-auth", false, "Trust an authenticating reverse proxy, such as Authelia or oauth2-proxy, to name users in a header instead of using a consent server (env: PROXY_AUTH)")
+	proxyAuthHeader := flag.String("proxy-auth-header", "", "Header naming the user with --proxy-auth (env: PROXY_AUTH_HEADER, default: Remote-User, then X-Auth-Request-Email)")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated addresses or CIDR ranges the --proxy-auth proxy connects from (env: TRUSTED_PROXIES, default: loopback)")
+	proxyLoginURL := flag.String("proxy-login-url", "", "Sign-in page of the --proxy-auth proxy (env: PROXY_LOGIN_URL)")
+	proxyLogoutURL := flag.String("proxy-logout-url", "", "Sign-out page of the --proxy-auth proxy (env: PROXY_LOGOUT_URL)")
+	redirectURL := flag.String("redirect-url", "", "Callback URL registered with the consent server, ending in /auth/callback (env: REDIRECT_URL)")
+	trashRetention := flag.Duration("trash-retention", 30*24*time.Hour, "How long deleted items stay restorable before being purged")
+	attachmentsDir := flag.String("attachments-dir", "", "Directory for uploaded attachments (env: ATTACHMENTS_DIR, default: attachments)")
+	vapidKey := flag.String("vapid-key", "vapid.key", "Key for signing Web Push messages, generated if missing")
+	vapidSubject := flag.String("vapid-subject", "", "Contact for push services, a mailto: or https: URL (env: VAPID_SUBJECT)")
+	sentryDSN := flag.String("sentry-dsn", "", "Report panics to a Sentry-compatible error tracker (env: SENTRY_DSN, or SENTRY_DSN_FILE)")
+	clientIPHeader := flag.String("client-ip-header", "", "Header a trusted reverse proxy puts the client address in, e.g. X-Forwarded-For (env: CLIENT_IP_HEADER)")
+	workLogLedger := flag.Bool("work-log-ledger", false, "Record work log changes as adjustment entries instead of edits (env: WORK_LOG_LEDGER)")
+	seed := flag.String("seed", "", "With --dev, fill an empty database with fixture data: small, large, or demo")
+	fakeTime := flag.String("clock", "", "With --dev, freeze the clock at this RFC 3339 time; advance it with POST /dev/clock")
+	idSeed := flag.Uint64("id-seed", 0, "With --dev, generate IDs from this seed so they are the same on every run")
+	recordHTTP := flag.String("record-http", "", "With --dev, save every request and response to this directory, viewable at /dev/http")
+	syncPrivatePeers := flag.Bool("sync-private-peers", false, "Let boards sync with peers on private addresses, e.g. on a home network (env: SYNC_PRIVATE_PEERS)")
+	notifyPrivateServers := flag.Bool("notify-private-servers", false, "Let users send notifications to ntfy and Gotify servers on private addresses (env: NOTIFY_PRIVATE_SERVERS)")
+	smtpHost := flag.String("smtp-host", "", "SMTP server for sending email; no email is sent without one (env: SMTP_HOST)")
+	smtpPort := flag.Int("smtp-port", 0, "SMTP server port; 465 for TLS, otherwise STARTTLS when offered (env: SMTP_PORT, default: 587)")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username (env: SMTP_USERNAME)")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password (env: SMTP_PASSWORD)")
+	smtpPasswordFile := flag.String("smtp-password-file", "", "File holding the SMTP password (env: SMTP_PASSWORD_FILE)")
+	mailFrom := flag.String("mail-from", "", "Sender of email, e.g. \"Compass <compass@example.com>\" (env: MAIL_FROM)")
+	publicURL := flag.String("public-url", "", "Address the app is reached at, for links in email (env: PUBLIC_URL, default: the origin of --redirect-url)")
+	provisioningToken := flag.String("provisioning-token", "", "Bearer token an identity provider presents to manage accounts through the SCIM API at /scim/v2 (env: PROVISIONING_TOKEN, or PROVISIONING_TOKEN_FILE)")
+	provisioningTokenFile := flag.String("provisioning-token-file", "", "File holding the provisioning token (env: PROVISIONING_TOKEN_FILE)")
+	defaultCategories := flag.String("default-categories", "", "Comma-separated names of categories to share with each provisioned account (env: DEFAULT_CATEGORIES)")
+	admins := flag.String("admins", "", "Comma-separated handles of the users who manage groups and category sharing (env: ADMINS)")
+	requireLogin := flag.Bool("require-login", false, "Show signed-out visitors a landing page instead of public categories and tasks (env: REQUIRE_LOGIN=true)")
+	instanceName := flag.String("instance-name", "", "Name on the --require-login landing page (env: INSTANCE_NAME)")
+	instanceDescription := flag.String("instance-description", "", "Text on the --require-login landing page; blank lines separate paragraphs (env: INSTANCE_DESCRIPTION, or INSTANCE_DESCRIPTION_FILE)")
+	instanceDescriptionFile := flag.String("instance-description-file", "", "File holding the landing page text (env: INSTANCE_DESCRIPTION_FILE)")
+	privateBoards := flag.Bool("private-boards", false, "Give each user a board of their own, hiding the categories they add from other users unless shared (env: PRIVATE_BOARDS=true)")
+	gitMirror := flag.String("git-mirror", "", "Keep a git repository of the board as markdown files in this directory (env: GIT_MIRROR)")
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if (*seed != "" || *fakeTime != "" || *idSeed != 0 || *recordHTTP != "") && !*devMode {
+		log.Fatalf("--seed, --clock, --id-seed, and --record-http are only available with --dev")
+	}
+	if *seed != "" && !fixtures.IsScenario(*seed) {
+		log.Fatalf("Unknown --seed %q, expected one of %v", *seed, fixtures.Scenarios)
+	}
+
 	// Resolve config with CLI > env fallback
 	resolvedConsentURL := getConfigValue(*consentURL, "CONSENT_URL")
-	resolvedConsentPubkey := getConfigValue(*consentPubkey, "CONSENT_PUBKEY")
+	resolvedConsentPubkey, err := getSecretValue(*consentPubkey, *consentPubkeyFile, "CONSENT_PUBKEY")
+	if err != nil {
+		log.Fatalf("Failed to read consent public key: %v", err)
+	}
 	resolvedAppID := getConfigValue(*appID, "APP_ID")
+	resolvedRedirectURL := getConfigValue(*redirectURL, "REDIRECT_URL")
+	resolvedAttachmentsDir := getConfigValue(*attachmentsDir, "ATTACHMENTS_DIR")
+	if resolvedAttachmentsDir == "" {
+		resolvedAttachmentsDir = "attachments"
+	}
+	resolvedVapidSubject := getConfigValue(*vapidSubject, "VAPID_SUBJECT")
+	if resolvedVapidSubject == "" {
+		resolvedVapidSubject = "mailto:admin@localhost"
+	}
+	resolvedLedger := *workLogLedger || os.Getenv("WORK_LOG_LEDGER") == "true"
 
-	// Initialize Store
-	store, err := store.NewSQLiteStore("compass.db", true)
+	var mailConfig compass.MailConfig
+	if mailConfig.Host = getConfigValue(*smtpHost, "SMTP_HOST"); mailConfig.Host != "" {
+		mailConfig.Port = *smtpPort
+		if port := os.Getenv("SMTP_PORT"); mailConfig.Port == 0 && port != "" {
+			if mailConfig.Port, err = strconv.Atoi(port); err != nil {
+				log.Fatalf("Invalid SMTP_PORT: %v", err)
+			}
+		}
+		mailConfig.Username = getConfigValue(*smtpUsername, "SMTP_USERNAME")
+		if mailConfig.Password, err = getSecretValue(*smtpPassword, *smtpPasswordFile, "SMTP_PASSWORD"); err != nil {
+			log.Fatalf("Failed to read SMTP password: %v", err)
+		}
+		mailConfig.From = getConfigValue(*mailFrom, "MAIL_FROM")
+		mailConfig.LinkBase = getConfigValue(*publicURL, "PUBLIC_URL")
+		if u, err := url.Parse(resolvedRedirectURL); mailConfig.LinkBase == "" && err == nil && u.Host != "" {
+			mailConfig.LinkBase = u.Scheme + "://" + u.Host
+		} else if mailConfig.LinkBase == "" && *devMode {
+			mailConfig.LinkBase = "http://localhost:8080"
+		}
+	}
+
+	resolvedProvisioningToken, err := getSecretValue(*provisioningToken, *provisioningTokenFile, "PROVISIONING_TOKEN")
 	if err != nil {
-		log.Fatalf("Failed to initialize store: %v", err)
+		log.Fatalf("Failed to read provisioning token: %v", err)
+	}
+	var resolvedDefaultCategories []string
+	for _, name := range strings.Split(getConfigValue(*defaultCategories, "DEFAULT_CATEGORIES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			resolvedDefaultCategories = append(resolvedDefaultCategories, name)
+		}
+	}
+	var landing *compass.Landing
+	if *requireLogin || os.Getenv("REQUIRE_LOGIN") == "true" {
+		description, err := getSecretValue(*instanceDescription, *instanceDescriptionFile, "INSTANCE_DESCRIPTION")
+		if err != nil {
+			log.Fatalf("Failed to read instance description: %v", err)
+		}
+		landing = &compass.Landing{
+			Name:        getConfigValue(*instanceName, "INSTANCE_NAME"),
+			Description: description,
+		}
+	}
+	var resolvedAdmins []string
+	for _, handle := range strings.Split(getConfigValue(*admins, "ADMINS"), ",") {
+		if handle = strings.TrimSpace(handle); handle != "" {
+			resolvedAdmins = append(resolvedAdmins, handle)
+		}
+	}
+
+	var reporter compass.Reporter
+	dsn, err := getSecretValue(*sentryDSN, "", "SENTRY_DSN")
+	if err != nil {
+		log.Fatalf("Failed to read Sentry DSN: %v", err)
+	}
+	if dsn != "" {
+		sentry, err := compass.NewSentryReporter(dsn)
+		if err != nil {
+			log.Fatalf("Invalid --sentry-dsn: %v", err)
+		}
+		reporter = sentry
+	}
+
+	// Dev mode can pin time and IDs so runs are reproducible
+	var clock domain.Clock = domain.SystemClock{}
+	var fakeClock *domain.FakeClock
+	if *fakeTime != "" {
+		t, err := time.Parse(time.RFC3339, *fakeTime)
+		if err != nil {
+			log.Fatalf("Invalid --clock: %v", err)
+		}
+		fakeClock = domain.NewFakeClock(t)
+		clock = fakeClock
+	}
+	var ids domain.IDGenerator
+	if *idSeed != 0 {
+		ids = domain.NewSeededIDs(*idSeed)
+	}
+
+	pushKey, err := getOrGenerateKey(*vapidKey)
+	if err != nil {
+		log.Fatalf("Failed to get/generate push key: %v", err)
 	}
 
 	// Configure authentication based on mode
-	var authConfig web.AuthConfig
+	var authConfig compass.AuthConfig
 
 	if *devMode {
 		// Dev mode: use TestVerifier from consent/pkg/testing with persistent key
-		key, err := getOrGenerateDevKey("dev.key")
+		key, err := getOrGenerateKey("dev.key")
 		if err != nil {
 			log.Fatalf("Failed to get/generate dev key: %v", err)
 		}
@@ -59,19 +232,43 @@ func main() {
 		env := contesting.NewTestEnvWithKey(key, "localhost", "compass-dev")
 		tv := contesting.NewTestVerifierWithEnv(env)
 
-		authConfig = web.AuthConfig{
+		authConfig = compass.AuthConfig{
 			Verifier:  tv,
 			LoginURL:  "/dev/login",
 			LogoutURL: "/dev/logout",
 			Routes: map[string]http.HandlerFunc{
-				"/dev/login":  tv.HandleDevLogin(),
+				"/dev/login": tv.HandleDevLogin(),
+			},
+			ExtraRoutes: map[string]http.HandlerFunc{
 				"/dev/logout": tv.HandleDevLogout(),
 			},
 		}
+		if fakeClock != nil {
+			authConfig.ExtraRoutes["POST /dev/clock"] = handleDevClock(fakeClock)
+		}
+	} else if *proxyAuth || os.Getenv("PROXY_AUTH") == "true" {
+		// Production mode behind an authenticating proxy
+		key, err := getOrGenerateKey("proxy-auth.key")
+		if err != nil {
+			log.Fatalf("Failed to get/generate proxy auth key: %v", err)
+		}
+		proxies, err := parsePrefixes(getConfigValue(*trustedProxies, "TRUSTED_PROXIES"))
+		if err != nil {
+			log.Fatalf("Invalid --trusted-proxies: %v", err)
+		}
+
+		headerAuth := &compass.HeaderAuth{
+			Key:            key,
+			TrustedProxies: proxies,
+			UserHeader:     getConfigValue(*proxyAuthHeader, "PROXY_AUTH_HEADER"),
+			LoginURL:       getConfigValue(*proxyLoginURL, "PROXY_LOGIN_URL"),
+			LogoutURL:      getConfigValue(*proxyLogoutURL, "PROXY_LOGOUT_URL"),
+		}
+		authConfig = headerAuth.Auth()
 	} else {
 		// Production mode: real consent server
 		if resolvedConsentURL == "" || resolvedConsentPubkey == "" || resolvedAppID == "" {
-			log.Fatalf("Production mode requires --consent-url, --consent-pubkey, and --app-id (or use --dev for development)")
+			log.Fatalf("Production mode requires --consent-url, --consent-pubkey (or --consent-pubkey-file), and --app-id, or --proxy-auth (or use --dev for development)")
 		}
 
 		pubKey, err := parsePublicKey(resolvedConsentPubkey)
@@ -82,22 +279,40 @@ func main() {
 		validator := tokens.InitClient(pubKey, resolvedConsentURL, resolvedAppID)
 		authClient := client.Init(validator, resolvedConsentURL)
 
-		// TODO: Construct proper authorize URL with client_id, redirect_uri params
-		loginURL := resolvedConsentURL + "/authorize"
-		logoutURL := resolvedConsentURL + "/logout"
-
-		authConfig = web.AuthConfig{
-			Verifier:  authClient,
-			LoginURL:  loginURL,
-			LogoutURL: logoutURL,
-			Routes: map[string]http.HandlerFunc{
-				"/auth/callback": authClient.HandleAuthorizationCode(),
-			},
+		login := &compass.ConsentLogin{
+			ServerURL:   resolvedConsentURL,
+			Service:     resolvedAppID,
+			Tokens:      authClient,
+			RedirectURL: resolvedRedirectURL,
 		}
+		authConfig = login.Auth(authClient)
 	}
 
-	opts := web.ServerOptions{Auth: authConfig}
-	srv, err := web.NewServer(store, opts)
+	srv, err := compass.New(compass.Config{
+		DatabasePath:     "compass.db",
+		Auth:             authConfig,
+		AttachmentsDir:   resolvedAttachmentsDir,
+		WorkLogLedger:    resolvedLedger,
+		TrashRetention:   *trashRetention,
+		PushKey:          pushKey,
+		PushSubject:      resolvedVapidSubject,
+		Clock:            clock,
+		IDs:              ids,
+		Fixture:          *seed,
+		RecordDir:        *recordHTTP,
+		Reporter:         reporter,
+		ClientIPHeader:   getConfigValue(*clientIPHeader, "CLIENT_IP_HEADER"),
+		PrivatePeers:     *syncPrivatePeers || os.Getenv("SYNC_PRIVATE_PEERS") == "true",
+		GitMirrorDir:     getConfigValue(*gitMirror, "GIT_MIRROR"),
+		PrivateNotifiers: *notifyPrivateServers || os.Getenv("NOTIFY_PRIVATE_SERVERS") == "true",
+		Mail:             mailConfig,
+
+		ProvisioningToken: resolvedProvisioningToken,
+		DefaultCategories: resolvedDefaultCategories,
+		Admins:            resolvedAdmins,
+		Landing:           landing,
+		PrivateBoards:     *privateBoards || os.Getenv("PRIVATE_BOARDS") == "true",
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
@@ -106,6 +321,9 @@ func main() {
 	if *devMode {
 		log.Println("Starting server in DEV mode on :8080...")
 		log.Println("  → Visit /dev/login to authenticate as 'alice'")
+		if *recordHTTP != "" {
+			log.Printf("  → Recording HTTP to %s; browse it at /dev/http", *recordHTTP)
+		}
 	} else {
 		log.Println("Starting server in PRODUCTION mode on :8080...")
 	}
@@ -114,6 +332,30 @@ func main() {
 	}
 }
 
+// handleDevClock moves a fake clock, by a duration with ?advance=1h30m or to
+// a time with ?set=2006-01-02T15:04:05Z, and reports where it now is.
+func handleDevClock(clock *domain.FakeClock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if v := r.FormValue("set"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			clock.Set(t)
+		}
+		if v := r.FormValue("advance"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			clock.Advance(d)
+		}
+		fmt.Fprintln(w, clock.Now().Format(time.RFC3339))
+	}
+}
+
 // parsePublicKey parses a PEM-encoded ECDSA public key.
 func parsePublicKey(pemData string) (*ecdsa.PublicKey, error) {
 	block, _ := pem.Decode([]byte(pemData))
@@ -134,9 +376,30 @@ func parsePublicKey(pemData string) (*ecdsa.PublicKey, error) {
 	return ecdsaPub, nil
 }
 
-// getOrGenerateDevKey attempts to load a private key from the given filename.
+// parsePrefixes reads a comma-separated list of addresses and CIDR ranges
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(field); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// getOrGenerateKey attempts to load a private key from the given filename.
 // If the file does not exist, it generates a new key and saves it.
-func getOrGenerateDevKey(filename string) (*ecdsa.PrivateKey, error) {
+func getOrGenerateKey(filename string) (*ecdsa.PrivateKey, error) {
 	// Try to read existing key
 	data, err := os.ReadFile(filename)
 	if err == nil {
@@ -148,7 +411,7 @@ func getOrGenerateDevKey(filename string) (*ecdsa.PrivateKey, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
 		}
-		log.Printf("Loaded existing dev key from %s", filename)
+		log.Printf("Loaded existing key from %s", filename)
 		return key, nil
 	}
 
@@ -157,7 +420,7 @@ func getOrGenerateDevKey(filename string) (*ecdsa.PrivateKey, error) {
 	}
 
 	// Generate new key
-	log.Printf("Generating new dev key to %s...", filename)
+	log.Printf("Generating new key to %s...", filename)
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate key: %w", err)
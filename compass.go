@@ -0,0 +1,283 @@
+// Package compass runs the compass progress tracker inside another Go
+// program. New returns an http.Handler for the whole app, so it can be
+// served on its own or mounted in an existing server:
+//
+//	h, err := compass.New(compass.Config{
+//		DatabasePath: "compass.db",
+//		Auth:         auth,
+//	})
+//	mux.Handle("compass.example.com/", h)
+//
+// The app expects to own the root of whatever host serves it; its pages link
+// to absolute paths such as /tasks/{id}, so it cannot yet live under a path
+// prefix. Mount it on its own host, as above, rather than with
+// http.StripPrefix.
+package compass
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/blob"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/fixtures"
+	"git.sr.ht/~jakintosh/compass/internal/gitexport"
+	"git.sr.ht/~jakintosh/compass/internal/jobs"
+	"git.sr.ht/~jakintosh/compass/internal/mail"
+	"git.sr.ht/~jakintosh/compass/internal/mirror"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+	"git.sr.ht/~jakintosh/compass/internal/preview"
+	"git.sr.ht/~jakintosh/compass/internal/report"
+	"git.sr.ht/~jakintosh/compass/internal/store"
+	"git.sr.ht/~jakintosh/compass/internal/web"
+)
+
+// AuthConfig says how users sign in: the token verifier, where the login and
+// logout buttons go, and any routes the sign-in flow needs.
+type AuthConfig = web.AuthConfig
+
+// ConsentLogin signs users in through a consent server, checking state on
+// the way back and returning them to the page they started from
+type ConsentLogin = web.ConsentLogin
+
+// HeaderAuth signs users in by trusting an authenticating reverse proxy,
+// such as Authelia or oauth2-proxy, to name them in a request header
+type HeaderAuth = web.HeaderAuth
+
+// Landing is the page an instance that requires login shows signed-out
+// visitors
+type Landing = web.Landing
+
+// Clock is the source of the current time
+type Clock = domain.Clock
+
+// IDGenerator is the source of new entity IDs
+type IDGenerator = domain.IDGenerator
+
+// Reporter is told about panics while serving requests
+type Reporter = report.Reporter
+
+// MailConfig says how to send email through an SMTP server
+type MailConfig = mail.Config
+
+// NewSentryReporter reports panics to a Sentry-compatible error tracker,
+// given a DSN of the form https://KEY@HOST/PROJECT_ID
+func NewSentryReporter(dsn string) (Reporter, error) {
+	return report.NewSentry(dsn)
+}
+
+// Config configures an embedded compass
+type Config struct {
+	// DatabasePath is the SQLite database file. Required; it is created
+	// if missing.
+	DatabasePath string
+	// Auth is required; Auth.Verifier must be non-nil.
+	Auth AuthConfig
+
+	// AttachmentsDir holds uploaded files. Defaults to "attachments".
+	AttachmentsDir string
+	// WorkLogLedger makes work logs append-only; see the --work-log-ledger
+	// flag.
+	WorkLogLedger bool
+	// TrashRetention is how long deleted items stay restorable. Defaults
+	// to 30 days.
+	TrashRetention time.Duration
+
+	// PushKey signs Web Push messages. Optional; without it browsers
+	// cannot subscribe and notifications only reach the inbox.
+	PushKey *ecdsa.PrivateKey
+	// PushSubject is the contact given to push services, a mailto: or
+	// https: URL. Defaults to mailto:admin@localhost.
+	PushSubject string
+
+	// Mail sends notifications and account notices by email, to users who
+	// give an address in settings. Optional; leave Mail.Host empty to
+	// send no email.
+	Mail MailConfig
+
+	// Reporter is told about panics while serving requests, in addition
+	// to them being logged. Optional.
+	Reporter Reporter
+	// ClientIPHeader names the header a trusted reverse proxy puts the
+	// client's address in, for throttling failed sign-ins. Optional.
+	ClientIPHeader string
+
+	// ProvisioningToken turns on the SCIM API under /scim/v2, for an
+	// identity provider to create and deactivate accounts ahead of sign-in.
+	// It is presented as a bearer token. Optional.
+	ProvisioningToken string
+	// DefaultCategories names the categories shared with each account
+	// created through provisioning. Missing ones are created.
+	DefaultCategories []string
+	// Admins are the handles of the users who manage groups and share
+	// categories with them. Optional.
+	Admins []string
+	// Landing requires login to see anything on the board, showing
+	// signed-out visitors this page instead. Optional; without it public
+	// categories and tasks can be seen by anyone.
+	Landing *Landing
+	// PrivateBoards gives each user a board of their own: the categories
+	// they add, and everything in them, are seen and changed only by them,
+	// admins, and whoever they share them with. Categories from before
+	// stay everyone's.
+	PrivateBoards bool
+
+	// PrivatePeers lets boards sync with peers on loopback and private
+	// addresses, such as another instance on the same home network.
+	// Otherwise only public addresses are reached.
+	PrivatePeers bool
+	// PrivateNotifiers lets users send notifications to ntfy and Gotify
+	// servers on loopback and private addresses, for servers that run
+	// alongside compass. Otherwise only public addresses are reached.
+	PrivateNotifiers bool
+
+	// GitMirrorDir keeps a git repository of the board as markdown files
+	// there, committing each batch of changes under the names of the users
+	// who made them. Optional; it needs git installed.
+	GitMirrorDir string
+
+	// Context bounds the background jobs: snapshots, trash purging, link
+	// previews, digests, nudges, aging rules, board sync, email, and the
+	// git mirror. They stop
+	// when it is done. Defaults to running for the life of the process.
+	Context context.Context
+
+	// Clock and IDs replace the system clock and random IDs, for tests and
+	// reproducible runs. Optional.
+	Clock Clock
+	IDs   IDGenerator
+	// Fixture names a fixture scenario (small, large, or demo) to load
+	// into an empty database. For development only.
+	Fixture string
+	// RecordDir saves every request and response there, browsable at
+	// /dev/http. For development only.
+	RecordDir string
+}
+
+// New opens the database, starts the background jobs, and returns the app's
+// handler
+func New(cfg Config) (http.Handler, error) {
+	if cfg.DatabasePath == "" {
+		return nil, errors.New("DatabasePath is required")
+	}
+	if cfg.AttachmentsDir == "" {
+		cfg.AttachmentsDir = "attachments"
+	}
+	if cfg.TrashRetention == 0 {
+		cfg.TrashRetention = 30 * 24 * time.Hour
+	}
+	if cfg.PushSubject == "" {
+		cfg.PushSubject = "mailto:admin@localhost"
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = domain.SystemClock{}
+	}
+
+	db, err := store.NewSQLiteStore(cfg.DatabasePath, true, cfg.Clock, cfg.IDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize store: %w", err)
+	}
+
+	if cfg.Fixture != "" {
+		board, err := fixtures.Scenario(cfg.Fixture, cfg.Clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		switch err := db.LoadFixture(board, ""); {
+		case errors.Is(err, domain.ErrConflict):
+			log.Printf("Not seeding: the database already has data")
+		case err != nil:
+			return nil, fmt.Errorf("failed to seed %s fixtures: %w", cfg.Fixture, err)
+		default:
+			log.Printf("Seeded %s fixtures", cfg.Fixture)
+		}
+	}
+
+	blobs, err := blob.NewDisk(cfg.AttachmentsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize attachment storage: %w", err)
+	}
+
+	// Notifications go out over every configured channel. ntfy and Gotify
+	// are set up by each user, so they are always offered.
+	channels := []notify.Channel{
+		notify.NewInbox(db),
+		notify.NewNtfy(db, cfg.PrivateNotifiers),
+		notify.NewGotify(db, cfg.PrivateNotifiers),
+	}
+	var push *notify.WebPush
+	if cfg.PushKey != nil {
+		if push, err = notify.NewWebPush(db, cfg.PushKey, cfg.PushSubject); err != nil {
+			return nil, fmt.Errorf("failed to initialize push notifications: %w", err)
+		}
+		channels = append(channels, push)
+	}
+	var mailer *mail.Mailer
+	if cfg.Mail.Host != "" {
+		if mailer, err = mail.New(cfg.Mail, db, cfg.Clock); err != nil {
+			return nil, fmt.Errorf("failed to initialize email: %w", err)
+		}
+		channels = append(channels, notify.NewEmail(db, mailer))
+	}
+	notifier := notify.NewDispatcher(db, channels...)
+
+	previews := preview.NewFetcher()
+	syncer := mirror.NewClient(db, cfg.Clock, cfg.PrivatePeers)
+	background := []jobs.Job{
+		jobs.DailySnapshot(db, cfg.Clock),
+		jobs.PurgeTrash(db, cfg.Clock, cfg.TrashRetention),
+		jobs.PruneBoardChanges(db, cfg.Clock),
+		jobs.RefreshLinkPreviews(db, previews, cfg.Clock),
+		jobs.SendDigests(notifier, cfg.Clock),
+		jobs.SendNudges(db, notifier, cfg.Clock),
+		jobs.ApplyAgingRules(db, cfg.Clock),
+		jobs.WriteWeeklyDigests(db, cfg.Clock),
+		jobs.PauseIdleTimers(db, cfg.Clock),
+		jobs.SyncBoards(db, syncer, cfg.Clock),
+	}
+	if mailer != nil {
+		background = append(background, jobs.SendMail(mailer))
+	}
+	if cfg.GitMirrorDir != "" {
+		exporter, err := gitexport.New(cfg.GitMirrorDir, db, cfg.Clock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize the git mirror: %w", err)
+		}
+		background = append(background, jobs.MirrorToGit(exporter))
+	}
+	runner := jobs.NewRunner(background...)
+
+	srv, err := web.NewServer(db, web.ServerOptions{
+		Auth:           cfg.Auth,
+		Clock:          cfg.Clock,
+		WorkLogLedger:  cfg.WorkLogLedger,
+		Blobs:          blobs,
+		Previews:       previews,
+		Notifier:       notifier,
+		Push:           push,
+		Mail:           mailer,
+		Sync:           syncer,
+		RecordDir:      cfg.RecordDir,
+		Reporter:       cfg.Reporter,
+		ClientIPHeader: cfg.ClientIPHeader,
+
+		ProvisioningToken: cfg.ProvisioningToken,
+		DefaultCategories: cfg.DefaultCategories,
+		Admins:            cfg.Admins,
+		Landing:           cfg.Landing,
+		PrivateBoards:     cfg.PrivateBoards,
+	})
+	if err != nil {
+		return nil, err
+	}
+	go runner.Run(cfg.Context)
+	return srv, nil
+}
@@ -5,6 +5,8 @@ go 1.25.4
 require (
 	git.sr.ht/~jakintosh/consent v0.2.1
 	github.com/google/uuid v1.6.0
+	golang.org/x/crypto v0.30.0
+	golang.org/x/text v0.31.0
 	modernc.org/sqlite v1.40.1
 )
 
@@ -13,7 +15,6 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	golang.org/x/crypto v0.30.0 // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	modernc.org/libc v1.66.10 // indirect
@@ -0,0 +1,153 @@
+// Package alerts reads the webhooks monitoring systems send when something
+// goes wrong, for turning into tasks. Alertmanager and Uptime Kuma are
+// understood; each alert keeps a fingerprint that stays the same while it
+// fires, so repeated notifications can be told apart from new incidents.
+package alerts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// ErrUnknownFormat is returned for a body that is not from a known system
+var ErrUnknownFormat = errors.New("not an Alertmanager or Uptime Kuma webhook")
+
+// Parse reads the alerts in a webhook body. A body with no alerts in it,
+// such as Uptime Kuma's test notification, gives none.
+func Parse(body []byte) ([]*domain.Alert, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, err
+	}
+	if _, ok := probe["alerts"]; ok {
+		return parseAlertmanager(body)
+	}
+	_, hasMonitor := probe["monitor"]
+	_, hasHeartbeat := probe["heartbeat"]
+	if hasMonitor || hasHeartbeat {
+		return parseUptimeKuma(body)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// alertmanagerWebhook is the part of Alertmanager's webhook compass reads
+type alertmanagerWebhook struct {
+	Alerts []struct {
+		Status       string            `json:"status"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		GeneratorURL string            `json:"generatorURL"`
+		Fingerprint  string            `json:"fingerprint"`
+	} `json:"alerts"`
+}
+
+func parseAlertmanager(body []byte) ([]*domain.Alert, error) {
+	var hook alertmanagerWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, err
+	}
+
+	var alerts []*domain.Alert
+	for _, a := range hook.Alerts {
+		name := a.Labels["alertname"]
+		if name == "" {
+			name = "Alert"
+		}
+		if instance := a.Labels["instance"]; instance != "" {
+			name += " on " + instance
+		}
+
+		var lines []string
+		for _, key := range []string{"summary", "description"} {
+			if v := strings.TrimSpace(a.Annotations[key]); v != "" {
+				lines = append(lines, v)
+			}
+		}
+		lines = append(lines, "Labels: "+formatLabels(a.Labels))
+		if a.GeneratorURL != "" {
+			lines = append(lines, a.GeneratorURL)
+		}
+
+		// Alertmanager has sent a fingerprint since 0.19; before that
+		// an alert is known by its labels
+		fingerprint := a.Fingerprint
+		if fingerprint == "" {
+			sum := sha256.Sum256([]byte(formatLabels(a.Labels)))
+			fingerprint = hex.EncodeToString(sum[:8])
+		}
+		alerts = append(alerts, &domain.Alert{
+			Fingerprint: "alertmanager:" + fingerprint,
+			Name:        name,
+			Description: strings.Join(lines, "\n\n"),
+			Resolved:    a.Status == "resolved",
+		})
+	}
+	return alerts, nil
+}
+
+// formatLabels writes labels in the order Prometheus does, by name
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// uptimeKumaWebhook is the part of Uptime Kuma's webhook compass reads. A
+// heartbeat's status is 0 when the monitor is down and 1 when it is up.
+type uptimeKumaWebhook struct {
+	Heartbeat *struct {
+		Status int    `json:"status"`
+		Msg    string `json:"msg"`
+	} `json:"heartbeat"`
+	Monitor *struct {
+		ID       json.Number `json:"id"`
+		Name     string      `json:"name"`
+		URL      string      `json:"url"`
+		Hostname string      `json:"hostname"`
+	} `json:"monitor"`
+	Msg string `json:"msg"`
+}
+
+func parseUptimeKuma(body []byte) ([]*domain.Alert, error) {
+	var hook uptimeKumaWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, err
+	}
+	// The test notification names no monitor
+	if hook.Monitor == nil || hook.Heartbeat == nil {
+		return nil, nil
+	}
+
+	m := hook.Monitor
+	var lines []string
+	if msg := strings.TrimSpace(hook.Heartbeat.Msg); msg != "" {
+		lines = append(lines, msg)
+	}
+	target := m.URL
+	if target == "" || target == "https://" {
+		target = m.Hostname
+	}
+	if target != "" {
+		lines = append(lines, "Monitoring "+target)
+	}
+	return []*domain.Alert{{
+		Fingerprint: "uptime-kuma:" + m.ID.String(),
+		Name:        m.Name + " is down",
+		Description: strings.Join(lines, "\n\n"),
+		Resolved:    hook.Heartbeat.Status == 1,
+	}}, nil
+}
@@ -0,0 +1,213 @@
+// Package backup encrypts backup archives with a passphrase, so they can be
+// kept on storage that is not trusted with the board's contents.
+//
+// The format follows age's passphrase recipient: a key is derived from the
+// passphrase with scrypt, and the plaintext is sealed in 64 KiB chunks with
+// ChaCha20-Poly1305. Each chunk's nonce carries its position and whether it
+// is the last, so reordered, dropped, or truncated chunks fail to open.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Extension is the file extension for encrypted backups
+const Extension = ".compass-backup"
+
+const (
+	magic     = "compass-backup v1\n"
+	saltSize  = 16
+	chunkSize = 64 << 10
+
+	workFactor    = 17 // scrypt N is 2^workFactor
+	maxWorkFactor = 22 // refuse headers that would take minutes to open
+)
+
+// ErrPassphrase is returned when a backup cannot be opened with the
+// passphrase given, or has been tampered with
+var ErrPassphrase = errors.New("wrong passphrase or damaged backup")
+
+// ErrFormat is returned for input that is not an encrypted backup
+var ErrFormat = errors.New("not a compass backup")
+
+func deriveKey(passphrase string, salt []byte, logN byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+// nonce is the chunk counter, big-endian, with the last byte flagging the
+// final chunk
+func nonce(counter uint64, last bool) []byte {
+	n := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(n[3:11], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+type writer struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// Encrypt returns a writer that encrypts everything written to it under
+// passphrase and passes it on to dst. Close must be called to seal the final
+// chunk; it does not close dst.
+func Encrypt(dst io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nil, errors.New("backup passphrase is empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := deriveKey(passphrase, salt, workFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(magic), workFactor)
+	header = append(header, salt...)
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{dst: dst, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed backup")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, because the
+		// last chunk must be marked as such
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) flush(last bool) error {
+	sealed := w.aead.Seal(nil, nonce(w.counter, last), w.buf, nil)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.dst.Write(sealed)
+	return err
+}
+
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+type reader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	sealed  []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+// Decrypt returns a reader of what was encrypted into src under passphrase.
+// The first chunk is opened before returning, so a wrong passphrase is
+// reported here as ErrPassphrase rather than on the first read. A later
+// read fails with ErrPassphrase if the rest has been altered or cut short.
+func Decrypt(src io.Reader, passphrase string) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	header := make([]byte, len(magic)+1+saltSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return nil, ErrFormat
+	}
+	logN := header[len(magic)]
+	if logN < 10 || logN > maxWorkFactor {
+		return nil, fmt.Errorf("%w: unsupported work factor %d", ErrFormat, logN)
+	}
+	aead, err := deriveKey(passphrase, header[len(magic)+1:], logN)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{
+		src:    br,
+		aead:   aead,
+		sealed: make([]byte, chunkSize+aead.Overhead()),
+	}
+	if err := r.next(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// next opens the following chunk into r.plain
+func (r *reader) next() error {
+	n, err := io.ReadFull(r.src, r.sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// A short chunk must be the last; a full one is the last only if
+	// nothing follows it
+	last := n < len(r.sealed)
+	if !last {
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+
+	plain, err := r.aead.Open(r.sealed[:0:0], nonce(r.counter, last), r.sealed[:n], nil)
+	if err != nil {
+		return ErrPassphrase
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
@@ -0,0 +1,90 @@
+// Package blob stores uploaded file contents. Metadata about the files lives
+// in the domain store; a blob is only bytes under a key.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Open for a key that holds no blob
+var ErrNotFound = errors.New("blob not found")
+
+// Store is where blobs are kept. Keys are opaque, flat names chosen by the
+// caller; implementations may reject keys containing path separators.
+type Store interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Disk keeps each blob as a file in one directory
+type Disk struct {
+	dir string
+}
+
+// NewDisk creates dir if needed and stores blobs in it
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(d.dir, key), nil
+}
+
+// Put writes to a temporary file and renames it into place, so a reader
+// never sees a partial blob.
+func (d *Disk) Put(key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (d *Disk) Open(key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes a blob; deleting a missing blob is not an error
+func (d *Disk) Delete(key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package boardfile renders the board as plain-text files: a folder per
+// category, holding a README.md about the category and a markdown file per
+// task. It is how the board is browsed over WebDAV and mirrored to git.
+package boardfile
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Readme is the file in each category's folder that describes it
+const Readme = "README.md"
+
+// maxNameLen keeps file names within what common filesystems allow
+const maxNameLen = 120
+
+// File is a folder or file in the tree
+type File struct {
+	Name     string
+	Dir      bool
+	Modified time.Time
+	Content  []byte // files only
+	Children []*File
+}
+
+// Tree lays out a folder for each category, without rendering what is in
+// them, so only the folders that are wanted need to be
+func Tree(categories []*domain.Category, lastWorked map[string]time.Time) *File {
+	root := &File{Dir: true}
+	names := Names(len(categories), func(i int) string { return categories[i].Name })
+	for i, c := range categories {
+		folder := &File{Name: names[i], Dir: true, Modified: CategoryModified(c, lastWorked)}
+		if folder.Modified.After(root.Modified) {
+			root.Modified = folder.Modified
+		}
+		root.Children = append(root.Children, folder)
+	}
+	return root
+}
+
+// CategoryFiles renders a category's README and a file for each task
+func CategoryFiles(c *domain.Category, lastWorked map[string]time.Time, loc *time.Location) []*File {
+	taskNames := make(map[string]string, len(c.Tasks))
+	for _, t := range c.Tasks {
+		taskNames[t.ID] = t.Name
+	}
+	logs := map[string][]*domain.WorkLog{}
+	for _, l := range c.WorkLogs {
+		logs[l.TaskID] = append(logs[l.TaskID], l)
+	}
+
+	// The README takes its name first, so a task called README is renamed
+	names := Names(len(c.Tasks)+1, func(i int) string {
+		if i == 0 {
+			return strings.TrimSuffix(Readme, ".md")
+		}
+		return c.Tasks[i-1].Name
+	})
+	files := make([]*File, len(c.Tasks)+1)
+	for i, t := range c.Tasks {
+		files[i+1] = &File{
+			Name:     names[i+1] + ".md",
+			Modified: TaskModified(t, lastWorked),
+			Content:  Task(t, logs[t.ID], taskNames, loc),
+		}
+	}
+
+	var readme bytes.Buffer
+	fmt.Fprintf(&readme, "# %s\n\n", c.Name)
+	if c.Description != "" {
+		fmt.Fprintf(&readme, "%s\n\n", strings.TrimSpace(c.Description))
+	}
+	done := 0
+	for _, t := range c.Tasks {
+		if t.Completion >= 100 {
+			done++
+		}
+	}
+	fmt.Fprintf(&readme, "%d%% complete, %d of %d tasks done.\n", c.Completion, done, len(c.Tasks))
+	if len(c.Tasks) > 0 {
+		readme.WriteString("\n## Tasks\n\n")
+		for i, t := range c.Tasks {
+			fmt.Fprintf(&readme, "- [%s](%s) — %d%%\n", markdownEscape(t.Name), url.PathEscape(files[i+1].Name), t.Completion)
+		}
+	}
+	files[0] = &File{
+		Name:     Readme,
+		Modified: CategoryModified(c, lastWorked),
+		Content:  readme.Bytes(),
+	}
+	return files
+}
+
+// Task renders a task with its details, subtasks, and work log
+func Task(t *domain.Task, logs []*domain.WorkLog, taskNames map[string]string, loc *time.Location) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", t.Name)
+
+	fmt.Fprintf(&b, "- Completion: %d%%\n", t.Completion)
+	if t.Priority > domain.PriorityNormal {
+		fmt.Fprintf(&b, "- Priority: %s\n", domain.PriorityNames[t.Priority])
+	}
+	if t.Size != "" {
+		fmt.Fprintf(&b, "- Size: %s\n", t.Size)
+	}
+	if t.Estimate > 0 {
+		fmt.Fprintf(&b, "- Estimate: %sh\n", formatHours(t.Estimate))
+	}
+	if t.StartDate != "" {
+		fmt.Fprintf(&b, "- Starts: %s\n", t.StartDate)
+	}
+	if t.DueDate != "" {
+		fmt.Fprintf(&b, "- Due: %s\n", t.DueDate)
+	}
+	if t.Blocked {
+		fmt.Fprintf(&b, "- Blocked: %s", t.BlockedReason)
+		if t.WaitingOn != "" {
+			fmt.Fprintf(&b, " (waiting on %s)", t.WaitingOn)
+		}
+		b.WriteString("\n")
+	}
+	if t.Flag != "" {
+		fmt.Fprintf(&b, "- Flagged: %s\n", t.Flag)
+	}
+	if len(t.Contexts) > 0 {
+		fmt.Fprintf(&b, "- Contexts: @%s\n", strings.Join(t.Contexts, " @"))
+	}
+	if len(t.DependsOn) > 0 {
+		var deps []string
+		for _, id := range t.DependsOn {
+			deps = append(deps, taskNames[id])
+		}
+		fmt.Fprintf(&b, "- Waits for: %s\n", strings.Join(deps, ", "))
+	}
+	fmt.Fprintf(&b, "- ID: %s\n", t.ID)
+
+	if t.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(t.Description))
+	}
+
+	if len(t.Subtasks) > 0 {
+		b.WriteString("\n## Subtasks\n\n")
+		for _, st := range t.Subtasks {
+			check := " "
+			if st.Completion >= 100 {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s — %d%%\n", check, st.Name, st.Completion)
+		}
+	}
+
+	if len(t.Checklist) > 0 {
+		b.WriteString("\n## Definition of done\n\n")
+		for _, c := range t.Checklist {
+			check := " "
+			if c.Checked {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s\n", check, c.Text)
+		}
+	}
+
+	if len(logs) > 0 {
+		subtaskNames := make(map[string]string, len(t.Subtasks))
+		for _, st := range t.Subtasks {
+			subtaskNames[st.ID] = st.Name
+		}
+		b.WriteString("\n## Work log\n\n")
+		for _, l := range logs {
+			fmt.Fprintf(&b, "- %s · %sh", l.CreatedAt.In(loc).Format("2006-01-02 15:04"), formatHours(l.HoursWorked))
+			if l.Author != "" {
+				fmt.Fprintf(&b, " · %s", l.Author)
+			}
+			if name := subtaskNames[l.SubtaskID]; name != "" {
+				fmt.Fprintf(&b, " · on %s", name)
+			}
+			if d := strings.TrimSpace(l.WorkDescription); d != "" {
+				fmt.Fprintf(&b, " — %s", strings.Join(strings.Fields(d), " "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// markdownEscape keeps a name from closing the link text it is put in
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// Names turns n names into file names that are safe and distinct within
+// one folder, numbering repeats in order
+func Names(n int, name func(int) string) []string {
+	names := make([]string, n)
+	seen := map[string]bool{}
+	for i := range n {
+		base := strings.Map(func(r rune) rune {
+			switch {
+			case r == '/' || r == '\\':
+				return '-'
+			case r < ' ' || r == 0x7f:
+				return -1
+			}
+			return r
+		}, name(i))
+		base = strings.TrimSpace(strings.Trim(base, "."))
+		if runes := []rune(base); len(runes) > maxNameLen {
+			base = strings.TrimSpace(string(runes[:maxNameLen]))
+		}
+		if base == "" {
+			base = "Untitled"
+		}
+		// Compared without case, since the tree may be mounted on a
+		// filesystem that ignores it
+		candidate := base
+		for k := 2; seen[strings.ToLower(candidate)]; k++ {
+			candidate = base + " (" + strconv.Itoa(k) + ")"
+		}
+		seen[strings.ToLower(candidate)] = true
+		names[i] = candidate
+	}
+	return names
+}
+
+// TaskModified is when the task was last touched, as far as is recorded
+func TaskModified(t *domain.Task, lastWorked map[string]time.Time) time.Time {
+	if worked := lastWorked[t.ID]; worked.After(t.CreatedAt) {
+		return worked
+	}
+	return t.CreatedAt
+}
+
+func CategoryModified(c *domain.Category, lastWorked map[string]time.Time) time.Time {
+	var latest time.Time
+	for _, t := range c.Tasks {
+		if m := TaskModified(t, lastWorked); m.After(latest) {
+			latest = m
+		}
+	}
+	return latest
+}
+
+func formatHours(h float64) string {
+	return strconv.FormatFloat(h, 'f', -1, 64)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time. Stores and handlers take one
+// instead of calling time.Now so that time can be controlled in tests and
+// fixtures.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the real time, in UTC
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// FakeClock is a Clock that only moves when told to, for fixtures, tests,
+// and reproducing bugs at a known time. It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now.UTC()}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to t, which may be in the past
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t.UTC()
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "errors"
+
+// ErrNotFound is returned (possibly wrapped) when a referenced entity does
+// not exist. Check for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ErrStaleOrder is returned when a reorder request does not list exactly the
+// current children of its parent, usually because the client's view is out
+// of date.
+var ErrStaleOrder = errors.New("order does not match current items")
+
+// ErrInvalid is returned (wrapped with the reason) when input fails
+// validation and cannot be stored.
+var ErrInvalid = errors.New("invalid input")
+
+// ErrConflict is returned when a request cannot apply to the current state,
+// such as seeding a board that already has data.
+var ErrConflict = errors.New("conflict")
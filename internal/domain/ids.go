@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator is the source of new entity IDs. Stores take one instead of
+// calling uuid directly so that IDs can be made reproducible.
+type IDGenerator interface {
+	NewID() string
+}
+
+// RandomIDs generates random version 4 UUIDs
+type RandomIDs struct{}
+
+func (RandomIDs) NewID() string {
+	return uuid.NewString()
+}
+
+// SeededIDs generates version 4 UUIDs from a seeded stream, so the same seed
+// always produces the same sequence of IDs. They are not unpredictable and
+// must only be used for development and tests. It is safe for concurrent
+// use.
+type SeededIDs struct {
+	mu  sync.Mutex
+	src *rand.ChaCha8
+}
+
+func NewSeededIDs(seed uint64) *SeededIDs {
+	var key [32]byte
+	for i := range 8 {
+		key[i] = byte(seed >> (8 * i))
+	}
+	return &SeededIDs{src: rand.NewChaCha8(key)}
+}
+
+func (g *SeededIDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return uuid.Must(uuid.NewRandomFromReader(g.src)).String()
+}
@@ -1,16 +1,46 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
 
 type WorkLog struct {
-	ID                 string    `json:"id"`
-	CategoryID         string    `json:"category_id"`
-	TaskID             string    `json:"task_id"`
-	SubtaskID          string    `json:"subtask_id"` // empty string for task-level work
-	HoursWorked        float64   `json:"hours_worked"`
-	WorkDescription    string    `json:"work_description"`
-	CompletionEstimate int       `json:"completion_estimate"` // 0-100
-	CreatedAt          time.Time `json:"created_at"`
+	ID                 string        `json:"id"`
+	CategoryID         string        `json:"category_id"`
+	TaskID             string        `json:"task_id"`
+	SubtaskID          string        `json:"subtask_id"` // empty string for task-level work
+	HoursWorked        float64       `json:"hours_worked"`
+	WorkDescription    string        `json:"work_description"`
+	CompletionEstimate int           `json:"completion_estimate"` // 0-100
+	CreatedAt          time.Time     `json:"created_at"`
+	Author             string        `json:"author"`                // subject handle of whoever logged the work
+	CorrectsID         string        `json:"corrects_id,omitempty"` // set on adjustment entries; the original entry they correct
+	Attachments        []*Attachment `json:"attachments,omitempty"`
+}
+
+// RecentWork is a task or subtask someone logged work on lately, as a
+// suggestion for logging more
+type RecentWork struct {
+	TaskID       string    `json:"task_id"`
+	SubtaskID    string    `json:"subtask_id"` // empty for task-level work
+	TaskName     string    `json:"task_name"`
+	SubtaskName  string    `json:"subtask_name"`
+	CategoryName string    `json:"category_name"`
+	Completion   int       `json:"completion"` // of the subtask if there is one, else the task
+	LastHours    float64   `json:"last_hours"` // hours in the most recent log
+	LastLoggedAt time.Time `json:"last_logged_at"`
+}
+
+// SimilarTask is an existing task whose name is close to one being added
+type SimilarTask struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Completion int     `json:"completion"`
+	Similarity float64 `json:"similarity"` // 0-1, how many trigrams the names share
 }
 
 type Subtask struct {
@@ -21,20 +51,86 @@ type Subtask struct {
 	Description  string     `json:"description"`
 	Completion   int        `json:"completion"` // 0-100
 	Public       bool       `json:"public"`
+	Estimate     float64    `json:"estimate"`      // hours of effort; weighs the subtask in its task's completion
+	DueDate      string     `json:"due_date"`      // YYYY-MM-DD; empty if there is no deadline
 	ParentPublic bool       `json:"parent_public"` // category.public AND task.public
+	Checkbox     bool       `json:"checkbox"`      // task.subtask_checkboxes: done or not, 0 or 100
 	WorkLogs     []*WorkLog `json:"work_logs,omitempty"`
 }
 
 type Task struct {
-	ID           string     `json:"id"`
-	CategoryID   string     `json:"category_id"`
-	Name         string     `json:"name"`
-	Description  string     `json:"description"`
-	Completion   int        `json:"completion"` // 0-100
-	Public       bool       `json:"public"`
-	ParentPublic bool       `json:"parent_public"` // category.public
-	Subtasks     []*Subtask `json:"subtasks"`
-	WorkLogs     []*WorkLog `json:"work_logs,omitempty"`
+	ID           string        `json:"id"`
+	CategoryID   string        `json:"category_id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	Completion   int           `json:"completion"` // 0-100; with subtasks, their weighted average, maintained by the store
+	Public       bool          `json:"public"`
+	ParentPublic bool          `json:"parent_public"` // category.public
+	Estimate     float64       `json:"estimate"`      // hours of effort for the whole task; 0 if unestimated
+	StartDate    string        `json:"start_date"`    // YYYY-MM-DD; empty if unscheduled
+	DueDate      string        `json:"due_date"`      // YYYY-MM-DD; empty if there is no deadline
+	DependsOn    []string      `json:"depends_on"`    // IDs of tasks in the same category that must finish first
+	Subtasks     []*Subtask    `json:"subtasks"`
+	WorkLogs     []*WorkLog    `json:"work_logs,omitempty"`
+	Attachments  []*Attachment `json:"attachments,omitempty"` // files attached to the task itself, not its work logs
+	Links        []*Link       `json:"links,omitempty"`
+	Backlinks    []*Backlink   `json:"-"` // what refers to this task; derived, so not exported
+	Nudges       []*Nudge      `json:"-"` // personal reminders, shown only to their owners
+
+	EstimateChanges []*EstimateChange `json:"estimate_changes,omitempty"` // re-forecasts of the estimate, newest first
+
+	Review            string              `json:"review,omitempty"`             // one of the Review states
+	ReviewRequired    bool                `json:"review_required"`              // category.requires_review
+	ReviewTransitions []*ReviewTransition `json:"review_transitions,omitempty"` // newest first
+
+	Checklist []*TaskCheck `json:"checklist,omitempty"` // the category's definition of done, copied when the task was created
+
+	Blocked       bool      `json:"blocked"`
+	BlockedReason string    `json:"blocked_reason,omitempty"` // required while blocked
+	WaitingOn     string    `json:"waiting_on,omitempty"`     // who the task waits for, if anyone
+	BlockedSince  time.Time `json:"blocked_since,omitzero"`   // maintained by the store
+
+	Contexts []string `json:"contexts,omitempty"` // where the task can be done, like "errands"; without the @
+	Tags     []*Tag   `json:"tags,omitempty"`     // by name
+
+	Size string `json:"size"` // one of TaskSizes; empty if unsized
+
+	SubtaskCheckboxes bool `json:"subtask_checkboxes"` // subtasks are done or not, 0% or 100%, rather than part-way
+
+	Priority  int       `json:"priority"`       // PriorityNormal and up; aging rules can raise it
+	Flag      string    `json:"flag,omitempty"` // why an aging rule flagged the task; cleared by hand
+	CreatedAt time.Time `json:"created_at"`     // maintained by the store
+}
+
+// Task priorities, lowest first
+const (
+	PriorityNormal = iota
+	PriorityHigh
+	PriorityUrgent
+)
+
+// PriorityNames names each priority, indexed by its level
+var PriorityNames = []string{"normal", "high", "urgent"}
+
+// Task sizes, a rough label for how big a sitting a task needs
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
+// TaskSizes lists the sizes a task can be labelled with, smallest first
+var TaskSizes = []string{SizeSmall, SizeMedium, SizeLarge}
+
+// BlockedTask is a task on the blocked report
+type BlockedTask struct {
+	ID           string
+	Name         string
+	CategoryID   string
+	CategoryName string
+	Reason       string
+	WaitingOn    string
+	Since        time.Time
 }
 
 type Category struct {
@@ -42,19 +138,1214 @@ type Category struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Public      bool       `json:"public"`
+	Color       string     `json:"color"`      // one of CategoryColors; empty for the default
+	Icon        string     `json:"icon"`       // one of CategoryIcons; empty for none
+	Completion  int        `json:"completion"` // 0-100, weighted average of tasks; maintained by the store
 	Tasks       []*Task    `json:"tasks"`
 	WorkLogs    []*WorkLog `json:"work_logs,omitempty"`
+
+	DoneCriteria []*DoneCriterion `json:"done_criteria,omitempty"` // copied onto each new task
+
+	WIPLimit int `json:"wip_limit"` // most tasks in progress at once; 0 for no limit
+
+	AgingRules []*AgingRule `json:"aging_rules,omitempty"` // shortest first
+	SyncPeers  []*SyncPeer  `json:"sync_peers,omitempty"`  // oldest first
+
+	ShareToken string `json:"-"` // lets its progress be embedded elsewhere; empty if not shared
+
+	ReceivesAlerts bool `json:"-"` // has a webhook monitoring alerts add tasks through
+
+	OwnerID string `json:"owner_id,omitempty"` // who added it, whose its tasks are; empty for everyone's
+
+	WeeklyDigest bool `json:"weekly_digest"` // each week's activity is appended to its description
+
+	RequiresReview bool `json:"requires_review"` // tasks are finished by an owner approving them
 }
 
-// Helper methods
+// OwnedBy reports whether handle owns the category, as everyone does a
+// category added before categories had owners
+func (c *Category) OwnedBy(handle string) bool {
+	return c.OwnerID == "" || c.OwnerID == handle
+}
 
-func (c *Category) AverageCompletion() int {
-	if len(c.Tasks) == 0 {
-		return 0
+// Review states of a task in a category that requires review
+const (
+	ReviewNone     = ""         // being worked on
+	ReviewReady    = "ready"    // finished as far as its editors can tell
+	ReviewApproved = "approved" // approved by an owner, and so finished
+)
+
+// ReviewStateNames describes each review state
+var ReviewStateNames = map[string]string{
+	ReviewNone:     "in progress",
+	ReviewReady:    "ready for review",
+	ReviewApproved: "approved",
+}
+
+// ReviewTransition is a task moving from one review state to another
+type ReviewTransition struct {
+	ID        string    `json:"id"`
+	TaskID    string    `json:"task_id"`
+	From      string    `json:"from"` // one of the Review states
+	To        string    `json:"to"`
+	Note      string    `json:"note,omitempty"` // why it was sent back, if it was
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InProgress reports whether work on the task has started but not finished
+func (t *Task) InProgress() bool {
+	return t.Completion > 0 && t.Completion < 100
+}
+
+// ReforecastShare is how much of its estimate a task can take before the
+// estimate is worth a second look
+const ReforecastShare = 0.8
+
+// EstimateChange is a task's estimate re-forecast in light of the hours
+// logged on it
+type EstimateChange struct {
+	ID          string    `json:"id"`
+	TaskID      string    `json:"task_id"`
+	Previous    float64   `json:"previous"` // hours; 0 if it was unestimated
+	Estimate    float64   `json:"estimate"`
+	HoursLogged float64   `json:"hours_logged"` // on the task when it was re-forecast
+	Author      string    `json:"author"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HoursLogged totals the hours in the task's work logs, which must be loaded
+func (t *Task) HoursLogged() float64 {
+	var hours float64
+	for _, wl := range t.WorkLogs {
+		hours += wl.HoursWorked
 	}
-	sum := 0
+	return hours
+}
+
+// NeedsReforecast reports whether an unfinished task has used up
+// ReforecastShare of its estimate
+func (t *Task) NeedsReforecast() bool {
+	return t.Estimate > 0 && t.Completion < 100 && t.HoursLogged() >= t.Estimate*ReforecastShare
+}
+
+// ForecastEstimate projects what the task will take in all from the hours
+// logged so far and how complete it is, to the nearest half hour. With no
+// progress to go on, it adds a quarter to what has been logged.
+func (t *Task) ForecastEstimate() float64 {
+	logged := t.HoursLogged()
+	forecast := logged * 1.25
+	if t.Completion > 0 && t.Completion < 100 {
+		forecast = logged * 100 / float64(t.Completion)
+	}
+	return math.Max(math.Round(forecast*2)/2, 0.5)
+}
+
+// WIP counts the category's tasks in progress
+func (c *Category) WIP() int {
+	n := 0
 	for _, t := range c.Tasks {
-		sum += t.Completion
+		if t.InProgress() {
+			n++
+		}
 	}
-	return sum / len(c.Tasks)
+	return n
+}
+
+// AtWIPLimit reports whether starting another task would take the category
+// over its work-in-progress limit
+func (c *Category) AtWIPLimit() bool {
+	return c.WIPLimit > 0 && c.WIP() >= c.WIPLimit
+}
+
+// DoneCriterion is one line of a category's definition of done
+type DoneCriterion struct {
+	ID         string `json:"id"`
+	CategoryID string `json:"category_id"`
+	Text       string `json:"text"`
+}
+
+// What an aging rule does to a task that has gone too long without work
+const (
+	AgingFlag     = "flag"     // flag the task
+	AgingEscalate = "escalate" // raise its priority one level
+)
+
+// AgingActions lists what an aging rule can do
+var AgingActions = []string{AgingFlag, AgingEscalate}
+
+// AgingRule acts on a category's unfinished tasks once they go Days without
+// any work logged. It acts once per stretch without work, so logging work
+// lets it act again later.
+type AgingRule struct {
+	ID         string `json:"id"`
+	CategoryID string `json:"category_id"`
+	Days       int    `json:"days"`
+	Action     string `json:"action"` // one of AgingActions
+}
+
+// AgingDue is an aging rule that has come due for a task
+type AgingDue struct {
+	Rule   *AgingRule
+	TaskID string
+}
+
+// SearchResult is a category, task, subtask, or work log matching a search.
+// Snippet is the text that matched, with each match set between
+// SearchMatchStart and SearchMatchEnd, which never appear in cleaned text.
+type SearchResult struct {
+	EntityType string `json:"entity_type"`
+	ID         string `json:"id"`
+	CategoryID string `json:"category_id"`
+	TaskID     string `json:"task_id,omitempty"`    // the task a subtask or work log is under
+	SubtaskID  string `json:"subtask_id,omitempty"` // the subtask a work log is on, if any
+	Name       string `json:"name"`                 // a work log's is its task's
+	Snippet    string `json:"snippet"`
+}
+
+const (
+	SearchMatchStart = "\x02"
+	SearchMatchEnd   = "\x03"
+)
+
+// CategoryWeek is what happened in a category over a week, for its digest
+type CategoryWeek struct {
+	Start     time.Time
+	Completed []*Task // tasks that reached 100%, in the order they did
+	Hours     float64 // logged over the week
+}
+
+// TaskCheck is a task's own copy of a done criterion. A task cannot reach
+// 100% while any of its checks is unchecked.
+type TaskCheck struct {
+	ID      string `json:"id"`
+	TaskID  string `json:"task_id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+// CategoryColors are the accent colors a category can be given, by name, so
+// the stylesheet can pick shades that read well on any theme
+var CategoryColors = []string{"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink", "gray"}
+
+// CategoryIcons are the icons a category can be given
+var CategoryIcons = []string{"📁", "🏠", "💼", "🎯", "💡", "🛠️", "📚", "🎨", "🌱", "💰", "🏃", "✈️", "🎵", "❤️"}
+
+// Tag labels tasks across categories, for themes categories cut across,
+// such as a release or a client. Names are unique regardless of case.
+type Tag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`           // one of CategoryColors; empty for the default
+	Tasks int    `json:"tasks,omitempty"` // how many tasks carry it; only counted by GetTags
+}
+
+// Preferences holds per-user display settings.
+type Preferences struct {
+	UserID      string `json:"user_id"`
+	Accessible  bool   `json:"accessible"`   // plain forms and links, no scripts or motion
+	DisplayName string `json:"display_name"` // shown in attribution chips instead of the handle
+	Timezone    string `json:"timezone"`     // IANA name; empty means the browser's, or else the server's, zone
+	Language    string `json:"language"`     // BCP 47 tag dates and numbers are written for; empty follows the browser
+
+	Notifications NotificationPrefs `json:"notifications"` // how each kind of event reaches the user
+	DigestHour    int               `json:"digest_hour"`   // local hour (0-23) the daily digest goes out
+	Email         string            `json:"email"`         // where email notifications go; empty for none
+
+	WeeklyCapacity float64 `json:"weekly_capacity"` // hours a week available for planned work; 0 if unset
+
+	Context string `json:"context"` // the context the board is filtered to; empty for everything
+
+	ClipCategory string `json:"clip_category"` // category pages clipped from the browser go to; empty for DefaultClipCategory
+
+	IdleMinutes    int  `json:"idle_minutes"`    // minutes without a heartbeat before a running timer pauses; 0 never pauses it
+	MultipleTimers bool `json:"multiple_timers"` // more than one timer may be started at once
+
+	Deactivated bool `json:"-"` // turned off by the identity provider; the user cannot use compass
+}
+
+// Group is a set of users that categories can be shared with at once, so
+// people joining or leaving a team gain or lose its categories together
+type Group struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"` // handles, in order
+}
+
+// CategoryAccess is who a category is shared with. A category shared with
+// nobody is open to every signed-in user; once shared, only the people it
+// is shared with, directly or through a group, and admins see it.
+type CategoryAccess struct {
+	Users  []string // handles, in order
+	Groups []*Group // by name
+}
+
+// Restricted reports whether the category is shared with anyone, and so
+// hidden from everyone else
+func (a *CategoryAccess) Restricted() bool {
+	return len(a.Users) > 0 || len(a.Groups) > 0
+}
+
+// Account is a user as an identity provider manages them. Accounts are
+// otherwise implicit: anyone the sign-in vouches for can use compass, and a
+// user's preferences are saved when they first change them. Provisioning
+// saves them ahead of the first sign-in instead.
+type Account struct {
+	Handle      string   `json:"handle"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+	Active      bool     `json:"active"`
+	Categories  []string `json:"categories"` // IDs of the categories shared with the user
+}
+
+// DefaultDigestHour is when digests go out for users who have not chosen
+const DefaultDigestHour = 8
+
+// DefaultClipCategory is where clipped pages go for users who have not chosen
+const DefaultClipCategory = "Reading"
+
+// Delivery modes for a kind of notification on a channel
+const (
+	DeliverImmediately = "immediately"
+	DeliverDigest      = "digest" // held for the daily digest
+	DeliverOff         = "off"
+)
+
+// DeliveryModes lists the valid delivery modes
+var DeliveryModes = []string{DeliverImmediately, DeliverDigest, DeliverOff}
+
+// NotificationPrefs maps an event kind to a channel name to a delivery
+// mode. Anything unset is delivered immediately.
+type NotificationPrefs map[string]map[string]string
+
+// Delivery returns how events of kind should be delivered on channel
+func (n NotificationPrefs) Delivery(kind, channel string) string {
+	if mode := n[kind][channel]; mode != "" {
+		return mode
+	}
+	return DeliverImmediately
+}
+
+// Set records how events of kind should be delivered on channel
+func (n *NotificationPrefs) Set(kind, channel, mode string) {
+	if *n == nil {
+		*n = NotificationPrefs{}
+	}
+	if (*n)[kind] == nil {
+		(*n)[kind] = map[string]string{}
+	}
+	(*n)[kind][channel] = mode
+}
+
+// LastDigest returns the most recent time at or before now that this
+// user's digest was due
+func (p *Preferences) LastDigest(now time.Time) time.Time {
+	local := now.In(p.Location())
+	due := time.Date(local.Year(), local.Month(), local.Day(), p.DigestHour, 0, 0, 0, local.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due
+}
+
+// Location returns the zone timestamps should be shown in for this user.
+func (p *Preferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Entity types, as recorded in the trash, the audit log, and revisions
+const (
+	EntityCategory = "category"
+	EntityTask     = "task"
+	EntitySubtask  = "subtask"
+	EntityWorkLog  = "work_log"
+)
+
+// What else hangs off a category or task and is named by its own ID, so
+// that GetOwningCategoryID can find who it belongs to
+const (
+	EntityRevision      = "revision"
+	EntityAttachment    = "attachment"
+	EntityLink          = "link"
+	EntityCheck         = "check"
+	EntityNudge         = "nudge"
+	EntityDoneCriterion = "done_criterion"
+	EntityAgingRule     = "aging_rule"
+	EntityTimeBlock     = "time_block"
+	EntitySyncPeer      = "sync_peer"
+)
+
+// Sign-in events, as recorded in the audit log against the client's address
+const (
+	AuditLoginFailed    = "login_failed"
+	AuditLoginThrottled = "login_throttled"
+	AuditLoginBanned    = "login_banned"
+)
+
+// TrashEntry is a deleted category, task, or subtask. The whole subtree,
+// work logs included, is kept so that it can be restored exactly.
+type TrashEntry struct {
+	ID         string    `json:"id"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	CategoryID string    `json:"category_id"`
+	TaskID     string    `json:"task_id"` // empty unless EntityType is EntitySubtask
+	Name       string    `json:"name"`
+	DeletedBy  string    `json:"deleted_by"`
+	DeletedAt  time.Time `json:"deleted_at"`
+}
+
+// QueuedTask is a task on someone's focus queue, with enough of its
+// category to show it away from the board
+type QueuedTask struct {
+	TaskID       string    `json:"task_id"`
+	TaskName     string    `json:"task_name"`
+	CategoryID   string    `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Completion   int       `json:"completion"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+// MinutesPerDay is the end of the last time block that fits in a day
+const MinutesPerDay = 24 * 60
+
+// TimeBlock is a stretch of someone's day set aside for a task. Day and the
+// minutes past midnight are in the user's timezone.
+type TimeBlock struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	TaskID    string    `json:"task_id"`
+	TaskName  string    `json:"task_name"`
+	Day       string    `json:"day"` // YYYY-MM-DD
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	WorkLogID string    `json:"work_log_id,omitempty"` // set once the block is logged as work
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the block's day and times
+func (b *TimeBlock) Validate() error {
+	if _, err := time.Parse(time.DateOnly, b.Day); err != nil {
+		return fmt.Errorf("%w: day must be written as YYYY-MM-DD", ErrInvalid)
+	}
+	if b.Start < 0 || b.End > MinutesPerDay || b.Start >= b.End {
+		return fmt.Errorf("%w: a time block must end after it starts, within the day", ErrInvalid)
+	}
+	return nil
+}
+
+// Hours is the length of the block
+func (b *TimeBlock) Hours() float64 {
+	return float64(b.End-b.Start) / 60
+}
+
+// EndsAt is when the block is over, in loc
+func (b *TimeBlock) EndsAt(loc *time.Location) time.Time {
+	day, err := time.ParseInLocation(time.DateOnly, b.Day, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return day.Add(time.Duration(b.End) * time.Minute)
+}
+
+// Allocation is the time someone plans to spend on a task in a week, next to
+// what they have logged on it so far that week
+type Allocation struct {
+	TaskID       string  `json:"task_id"`
+	TaskName     string  `json:"task_name"`
+	CategoryName string  `json:"category_name"`
+	Week         string  `json:"week"`    // the Monday the week starts on, YYYY-MM-DD
+	Planned      float64 `json:"planned"` // hours
+	Logged       float64 `json:"logged"`
+}
+
+// Remaining is the planned time not yet logged
+func (a *Allocation) Remaining() float64 {
+	return max(a.Planned-a.Logged, 0)
+}
+
+// Workload is what someone planned for a week across the board: the
+// unfinished tasks they allocated hours to, against their weekly capacity
+type Workload struct {
+	UserID   string         `json:"user_id"`
+	Week     string         `json:"week"`     // the Monday the week starts on, YYYY-MM-DD
+	Capacity float64        `json:"capacity"` // hours; 0 if unset
+	Tasks    []*PlannedTask `json:"tasks"`
+}
+
+// PlannedTask is an unfinished task someone allocated hours to in a week
+type PlannedTask struct {
+	TaskID       string  `json:"task_id"`
+	TaskName     string  `json:"task_name"`
+	CategoryID   string  `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Planned      float64 `json:"planned"`   // hours, that week
+	Remaining    float64 `json:"remaining"` // of the task's estimate, going by its completion; 0 if unestimated
+}
+
+// Planned totals the hours planned for the week
+func (w *Workload) Planned() float64 {
+	var hours float64
+	for _, t := range w.Tasks {
+		hours += t.Planned
+	}
+	return hours
+}
+
+// Over reports whether more is planned than the week has room for
+func (w *Workload) Over() bool {
+	return w.Capacity > 0 && w.Planned() > w.Capacity
+}
+
+// CommitEvent is a commit reported by a user's local git hook, waiting to be
+// confirmed as work or dismissed. TaskID is empty when the commit named no
+// task and none was being worked on in its branch.
+type CommitEvent struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	TaskID      string    `json:"task_id,omitempty"`
+	TaskName    string    `json:"task_name,omitempty"`
+	Repo        string    `json:"repo"`
+	Branch      string    `json:"branch"`
+	SHA         string    `json:"sha,omitempty"`
+	Message     string    `json:"message"`
+	CommittedAt time.Time `json:"committed_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Gaps in commits longer than SessionGap end a work session, and each
+// session is taken to have started SessionLeadIn before its first commit
+const (
+	SessionGap    = 2 * time.Hour
+	SessionLeadIn = 30 * time.Minute
+)
+
+// WorkSession is a run of commits on one task, or on one branch when they
+// are not linked to a task, suggested as a work log
+type WorkSession struct {
+	TaskID   string
+	TaskName string
+	Repo     string
+	Branch   string
+	Start    time.Time
+	End      time.Time // the last commit
+	Commits  []*CommitEvent
+}
+
+// Hours is the session's length rounded up to a quarter hour
+func (s *WorkSession) Hours() float64 {
+	quarters := math.Ceil(s.End.Sub(s.Start).Hours() * 4)
+	return max(quarters, 1) / 4
+}
+
+// CommitIDs lists the IDs of the session's commits
+func (s *WorkSession) CommitIDs() []string {
+	ids := make([]string, len(s.Commits))
+	for i, c := range s.Commits {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// GroupWorkSessions splits commits into sessions, newest first. Commits on
+// the same task, or on the same repository and branch when unlinked, belong
+// to one session until they are more than SessionGap apart.
+func GroupWorkSessions(commits []*CommitEvent) []*WorkSession {
+	sorted := slices.Clone(commits)
+	slices.SortStableFunc(sorted, func(a, b *CommitEvent) int {
+		return a.CommittedAt.Compare(b.CommittedAt)
+	})
+
+	var sessions []*WorkSession
+	open := map[string]*WorkSession{}
+	for _, c := range sorted {
+		key := "task\x00" + c.TaskID
+		if c.TaskID == "" {
+			key = "branch\x00" + c.Repo + "\x00" + c.Branch
+		}
+		s := open[key]
+		if s == nil || c.CommittedAt.Sub(s.End) > SessionGap {
+			s = &WorkSession{
+				TaskID:   c.TaskID,
+				TaskName: c.TaskName,
+				Repo:     c.Repo,
+				Branch:   c.Branch,
+				Start:    c.CommittedAt.Add(-SessionLeadIn),
+			}
+			open[key] = s
+			sessions = append(sessions, s)
+		}
+		s.End = c.CommittedAt
+		s.Commits = append(s.Commits, c)
+	}
+	slices.Reverse(sessions)
+	return sessions
+}
+
+// WeekStart returns midnight on the Monday on or before t, in t's location
+func WeekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// Objective is a goal for a quarter, measured by its key results
+type Objective struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Quarter     string       `json:"quarter"` // e.g. 2026-Q4
+	KeyResults  []*KeyResult `json:"key_results"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// Progress is the average progress of the objective's key results
+func (o *Objective) Progress() int {
+	if len(o.KeyResults) == 0 {
+		return 0
+	}
+	total := 0
+	for _, kr := range o.KeyResults {
+		total += kr.Progress()
+	}
+	return total / len(o.KeyResults)
+}
+
+// KeyResult is a measurable outcome of an objective. Its progress is either
+// entered by hand, as Current out of Target, or derived from the completion
+// of the tasks linked to it.
+type KeyResult struct {
+	ID          string        `json:"id"`
+	ObjectiveID string        `json:"objective_id"`
+	Objective   string        `json:"objective"` // the objective's name
+	Name        string        `json:"name"`
+	Target      float64       `json:"target"`
+	Current     float64       `json:"current"`
+	Unit        string        `json:"unit"`
+	FromTasks   bool          `json:"from_tasks"`
+	Tasks       []*LinkedTask `json:"tasks"`
+}
+
+// LinkedTask is a task counted towards a key result
+type LinkedTask struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	CategoryName string  `json:"category_name"`
+	Completion   int     `json:"completion"`
+	Estimate     float64 `json:"estimate"`
+}
+
+// Progress is how far along the key result is, from 0 to 100
+func (kr *KeyResult) Progress() int {
+	if kr.FromTasks {
+		completions := make([]int, len(kr.Tasks))
+		estimates := make([]float64, len(kr.Tasks))
+		for i, t := range kr.Tasks {
+			completions[i], estimates[i] = t.Completion, t.Estimate
+		}
+		return WeightedCompletion(completions, estimates)
+	}
+	if kr.Target <= 0 {
+		return 0
+	}
+	return int(min(max(kr.Current/kr.Target*100, 0), 100))
+}
+
+// WeightedCompletion averages completions weighted by their estimates, so a
+// day-long task counts for more than a quick one. Unestimated items weigh as
+// much as the average estimated one, which makes the result a plain average
+// when nothing is estimated. It is 0 when there is nothing to average.
+func WeightedCompletion(completions []int, estimates []float64) int {
+	var estimated, count float64
+	for _, e := range estimates {
+		if e > 0 {
+			estimated += e
+			count++
+		}
+	}
+	fallback := 1.0
+	if count > 0 {
+		fallback = estimated / count
+	}
+
+	var sum, weights float64
+	for i, c := range completions {
+		weight := estimates[i]
+		if weight <= 0 {
+			weight = fallback
+		}
+		sum += float64(c) * weight
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return int(sum/weights + 1e-9)
+}
+
+// Quarter names the quarter t falls in, such as 2026-Q4
+func Quarter(t time.Time) string {
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3)
+}
+
+// QuarterStart parses a quarter name into the first day of the quarter, in UTC
+func QuarterStart(quarter string) (time.Time, error) {
+	var year, q int
+	if _, err := fmt.Sscanf(quarter, "%d-Q%d", &year, &q); err != nil || q < 1 || q > 4 || Quarter(time.Date(year, time.Month(q*3), 1, 0, 0, 0, 0, time.UTC)) != quarter {
+		return time.Time{}, fmt.Errorf("%w: quarters are written like 2026-Q4", ErrInvalid)
+	}
+	return time.Date(year, time.Month(q*3-2), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// DumpVersion is the format of dumps written by this version
+const DumpVersion = 1
+
+// Dump is every row of board data, keyed by column name, for backups. Like
+// the trash, it keeps rows whole so that a restore brings back IDs, sort
+// order, visibility, and work log history exactly.
+type Dump struct {
+	Version     int              `json:"version"`
+	ExportedAt  time.Time        `json:"exported_at"`
+	Categories  []map[string]any `json:"categories"`
+	Tasks       []map[string]any `json:"tasks"`
+	Subtasks    []map[string]any `json:"subtasks"`
+	WorkLogs    []map[string]any `json:"work_logs"`
+	Attachments []map[string]any `json:"attachments"`
+	Links       []map[string]any `json:"links"`
+	Nudges      []map[string]any `json:"nudges"`
+
+	Dependencies []map[string]any `json:"task_dependencies"`
+	Objectives   []map[string]any `json:"objectives"`
+	KeyResults   []map[string]any `json:"key_results"`
+	GoalLinks    []map[string]any `json:"key_result_tasks"`
+	DoneCriteria []map[string]any `json:"done_criteria"`
+	TaskChecks   []map[string]any `json:"task_checks"`
+	TaskContexts []map[string]any `json:"task_contexts"`
+	Tags         []map[string]any `json:"tags"`
+	TaskTags     []map[string]any `json:"task_tags"`
+	Estimates    []map[string]any `json:"estimate_changes"`
+	Reviews      []map[string]any `json:"review_transitions"`
+	AgingRules   []map[string]any `json:"aging_rules"`
+	AgingRuns    []map[string]any `json:"aging_runs"`
+}
+
+// WorkspaceVersion is the format of workspace exports written by this
+// version
+const WorkspaceVersion = 1
+
+// Workspace is the board as nested JSON, for moving it to another instance
+// or keeping a copy anyone can read: each category with its tasks, their
+// subtasks, and the work logged on each, in board order. Unlike a Dump it
+// names fields instead of copying rows, so it does not follow the schema;
+// attachments, sharing, goals, and the like are left out.
+type Workspace struct {
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Categories []*WorkspaceCategory `json:"categories"`
+}
+
+// WorkspaceCategory is a category in a Workspace
+type WorkspaceCategory struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Public      bool             `json:"public"`
+	Color       string           `json:"color,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	WIPLimit    int              `json:"wip_limit,omitempty"`
+	SortOrder   int              `json:"sort_order"`
+	Tasks       []*WorkspaceTask `json:"tasks"`
+}
+
+// WorkspaceTask is a task in a Workspace. Completion is only kept for tasks
+// without subtasks; the rest are worked out from their subtasks again.
+type WorkspaceTask struct {
+	ID                string              `json:"id"`
+	Name              string              `json:"name"`
+	Description       string              `json:"description"`
+	Completion        int                 `json:"completion"`
+	Public            bool                `json:"public"`
+	Estimate          float64             `json:"estimate,omitempty"`
+	StartDate         string              `json:"start_date,omitempty"`
+	DueDate           string              `json:"due_date,omitempty"`
+	Size              string              `json:"size,omitempty"`
+	Priority          int                 `json:"priority,omitempty"`
+	BlockedReason     string              `json:"blocked_reason,omitempty"` // set only while blocked
+	WaitingOn         string              `json:"waiting_on,omitempty"`
+	SubtaskCheckboxes bool                `json:"subtask_checkboxes,omitempty"`
+	SortOrder         int                 `json:"sort_order"`
+	CreatedAt         time.Time           `json:"created_at"`
+	Subtasks          []*WorkspaceSubtask `json:"subtasks"`
+	WorkLogs          []*WorkspaceWorkLog `json:"work_logs"` // logged on the task itself
+}
+
+// WorkspaceSubtask is a subtask in a Workspace
+type WorkspaceSubtask struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Completion  int                 `json:"completion"`
+	Public      bool                `json:"public"`
+	Estimate    float64             `json:"estimate,omitempty"`
+	DueDate     string              `json:"due_date,omitempty"`
+	SortOrder   int                 `json:"sort_order"`
+	WorkLogs    []*WorkspaceWorkLog `json:"work_logs"`
+}
+
+// WorkspaceWorkLog is a work log in a Workspace, oldest first under what it
+// was logged on
+type WorkspaceWorkLog struct {
+	ID                 string    `json:"id"`
+	HoursWorked        float64   `json:"hours_worked"`
+	WorkDescription    string    `json:"work_description"`
+	CompletionEstimate int       `json:"completion_estimate"`
+	CreatedAt          time.Time `json:"created_at"`
+	Author             string    `json:"author"`
+	CorrectsID         string    `json:"corrects_id,omitempty"`
+}
+
+// AccountExportVersion is the format of account exports written by this
+// version
+const AccountExportVersion = 1
+
+// AccountExport is everything kept about one user, for data portability
+// requests: their settings, the work and edits attributed to them, and
+// what the board keeps for them alone. Rows are keyed by column name, as
+// in a Dump. Secrets such as token hashes and push keys are left out, and
+// attachments are listed without their files.
+type AccountExport struct {
+	Version     int            `json:"version"`
+	ExportedAt  time.Time      `json:"exported_at"`
+	Subject     string         `json:"subject"`
+	Preferences map[string]any `json:"preferences"` // nil if never saved
+
+	WorkLogs     []map[string]any `json:"work_logs"`
+	Revisions    []map[string]any `json:"revisions"` // versions of categories, tasks, and subtasks they saved
+	AuditEntries []map[string]any `json:"audit_entries"`
+	Trash        []map[string]any `json:"trash"` // what they deleted, without the deleted data
+	Attachments  []map[string]any `json:"attachments"`
+	Links        []map[string]any `json:"links"`
+	Estimates    []map[string]any `json:"estimate_changes"`   // estimates they re-forecast
+	Reviews      []map[string]any `json:"review_transitions"` // tasks they marked ready, approved, or sent back
+	Commits      []map[string]any `json:"commits"`
+
+	Nudges        []map[string]any `json:"nudges"`
+	Queue         []map[string]any `json:"queue"`
+	TimeBlocks    []map[string]any `json:"time_blocks"`
+	Allocations   []map[string]any `json:"allocations"`
+	Timers        []map[string]any `json:"timers"`
+	Notifications []map[string]any `json:"notifications"`
+	PendingDigest []map[string]any `json:"pending_digest"`
+
+	HookTokens        []map[string]any `json:"hook_tokens"`
+	PushSubscriptions []map[string]any `json:"push_subscriptions"`
+	NotifyServices    []map[string]any `json:"notify_services"`
+	StatsLinks        []map[string]any `json:"stats_links"`
+	SharedCategories  []map[string]any `json:"shared_categories"`
+	Groups            []map[string]any `json:"groups"`
+}
+
+// VaultTask is a heading in a markdown vault with the checkboxes under it,
+// to be imported as a task with a subtask per checkbox. Source tells it
+// apart across imports: its file's path and the headings leading to it.
+type VaultTask struct {
+	Source string
+	File   string // path within the vault
+	Name   string
+	Items  []VaultItem
+}
+
+// VaultItem is a checkbox in a vault
+type VaultItem struct {
+	Source string // its task's Source and its own text
+	Name   string
+	Done   bool
+}
+
+// VaultImport counts what importing a vault changed
+type VaultImport struct {
+	TasksAdded    int
+	SubtasksAdded int
+	Completed     int // subtasks imported before whose box has since been ticked
+}
+
+// Alert is an alert from a monitoring system. Fingerprint is the same in
+// every notification about it while it fires, and is prefixed with the
+// system's name.
+type Alert struct {
+	Fingerprint string
+	Name        string
+	Description string
+	Resolved    bool
+}
+
+// AuditEntry records who changed what, and when
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	At         time.Time `json:"at"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"` // e.g. "delete", "restore"
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	Summary    string    `json:"summary"`
+}
+
+// Revision is a saved version of a category, task, or subtask. One is
+// recorded on every update, so restoring a revision is itself an update and
+// can be undone by restoring the one before it.
+type Revision struct {
+	ID          int64     `json:"id"`
+	EntityType  string    `json:"entity_type"`
+	EntityID    string    `json:"entity_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Completion  int       `json:"completion"` // always 0 for categories, whose completion is derived
+	Public      bool      `json:"public"`
+	Author      string    `json:"author"` // empty for the version that predates revision tracking
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Snapshot is a copy of the whole board as it stood on Day (YYYY-MM-DD,
+// UTC). Days on which nothing changed have no snapshot of their own; the
+// most recent earlier one applies.
+type Snapshot struct {
+	ID      int64     `json:"id"`
+	Day     string    `json:"day"`
+	TakenAt time.Time `json:"taken_at"`
+	Hash    string    `json:"hash"`  // SHA-256 of Board, hex encoded
+	Board   []byte    `json:"board"` // JSON array of categories with their tasks, subtasks, and work logs
+}
+
+// Attachment is a file uploaded to a task or one of its work logs. The
+// contents are kept in blob storage under the attachment's ID.
+type Attachment struct {
+	ID           string    `json:"id"`
+	TaskID       string    `json:"task_id"`
+	WorkLogID    string    `json:"work_log_id,omitempty"` // empty for files attached to the task itself
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"` // bytes
+	HasThumbnail bool      `json:"has_thumbnail"`
+	UploadedBy   string    `json:"uploaded_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Link kinds describe what a link points at, for grouping and icons
+const (
+	LinkRepo   = "repo"
+	LinkDoc    = "doc"
+	LinkTicket = "ticket"
+	LinkOther  = "other"
+)
+
+// LinkKinds lists the valid kinds in display order
+var LinkKinds = []string{LinkRepo, LinkDoc, LinkTicket, LinkOther}
+
+// Link points a task at supporting material elsewhere. Title and
+// HasFavicon come from the cached preview of URL and are empty until it has
+// been fetched.
+type Link struct {
+	ID         string    `json:"id"`
+	TaskID     string    `json:"task_id"`
+	Kind       string    `json:"kind"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	HasFavicon bool      `json:"has_favicon,omitempty"`
+	AddedBy    string    `json:"added_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LinkPreview is what was fetched from a URL, shared by every link to it
+type LinkPreview struct {
+	URL         string
+	Title       string
+	Favicon     []byte
+	FaviconType string
+	FetchedAt   time.Time
+}
+
+// TaskRef is a task as seen from a reference to it
+type TaskRef struct {
+	ID         string
+	Name       string
+	CategoryID string
+	Visible    bool // public along with its category, so anonymous viewers may follow it
+}
+
+// Backlink is something whose text refers to a task. For work logs, Name is
+// the name of the task or subtask the work was logged against.
+type Backlink struct {
+	SourceType string // one of the Entity constants
+	SourceID   string
+	Name       string
+	TaskID     string // empty for categories
+	SubtaskID  string // set for subtasks and work logs on subtasks
+	Visible    bool
+}
+
+// EveryDay is the Nudge weekday for a reminder that repeats daily
+const EveryDay = -1
+
+// Nudge is a standing reminder on a task, repeated on a schedule until the
+// task is done. The schedule is in the user's timezone.
+type Nudge struct {
+	ID        string    `json:"id"`
+	TaskID    string    `json:"task_id"`
+	UserID    string    `json:"user_id"` // who is reminded
+	Weekday   int       `json:"weekday"` // 0 (Sunday) to 6, or EveryDay
+	Hour      int       `json:"hour"`
+	Minute    int       `json:"minute"`
+	NextAt    time.Time `json:"next_at"` // when the reminder next goes out
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the nudge's schedule
+func (n *Nudge) Validate() error {
+	if n.Weekday < EveryDay || n.Weekday > int(time.Saturday) {
+		return fmt.Errorf("%w: unknown weekday %d", ErrInvalid, n.Weekday)
+	}
+	if n.Hour < 0 || n.Hour > 23 || n.Minute < 0 || n.Minute > 59 {
+		return fmt.Errorf("%w: reminder time must be between 00:00 and 23:59", ErrInvalid)
+	}
+	return nil
+}
+
+// Next returns the first time the nudge is due after after, in loc. It
+// returns the zero time if the schedule is invalid.
+func (n *Nudge) Next(after time.Time, loc *time.Location) time.Time {
+	local := after.In(loc)
+	for day := range 8 {
+		due := time.Date(local.Year(), local.Month(), local.Day()+day, n.Hour, n.Minute, 0, 0, loc)
+		if due.After(after) && (n.Weekday == EveryDay || due.Weekday() == time.Weekday(n.Weekday)) {
+			return due
+		}
+	}
+	return time.Time{}
+}
+
+// Timer times a user's work on a task until it is stopped and logged.
+// Paused time does not count. While it runs, the user's browser sends
+// heartbeats; one not heard from within the user's idle threshold pauses
+// at its last heartbeat, so time away from the desk is left out. Users who
+// opt in can run several at once, told apart by their names.
+type Timer struct {
+	ID           string        `json:"id"`
+	UserID       string        `json:"user_id"`
+	TaskID       string        `json:"task_id"`
+	TaskName     string        `json:"task_name"`
+	Name         string        `json:"name"`                    // what is being timed, if the task's name does not say; may be empty
+	Elapsed      time.Duration `json:"elapsed"`                 // counted before RunningSince, or in all if paused
+	RunningSince *time.Time    `json:"running_since,omitempty"` // nil while paused
+	LastSeen     time.Time     `json:"last_seen"`               // the latest heartbeat
+	IdlePaused   bool          `json:"idle_paused"`             // paused for want of heartbeats, not by the user
+	StartedAt    time.Time     `json:"started_at"`
+}
+
+// Running reports whether the timer is counting
+func (t *Timer) Running() bool {
+	return t.RunningSince != nil
+}
+
+// Label is what the timer is called: its name, or else its task's
+func (t *Timer) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.TaskName
+}
+
+// ElapsedAt returns the time counted as of now
+func (t *Timer) ElapsedAt(now time.Time) time.Duration {
+	if t.RunningSince == nil || now.Before(*t.RunningSince) {
+		return t.Elapsed
+	}
+	return t.Elapsed + now.Sub(*t.RunningSince)
+}
+
+// DefaultIdleMinutes is how long a running timer goes without a heartbeat
+// before it pauses, for users who have not chosen
+const DefaultIdleMinutes = 15
+
+// Notification is an entry in a user's in-app inbox
+type Notification struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	URL       string    `json:"url"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BoardStats are totals about the board that are safe to publish, e.g. in
+// a progress widget on a personal site; nothing in them is named
+type BoardStats struct {
+	Categories    int     `json:"categories"`
+	Tasks         int     `json:"tasks"`
+	OpenTasks     int     `json:"open_tasks"`      // below 100%
+	Completion    int     `json:"completion"`      // percent, weighted by estimate as a category's is
+	HoursThisWeek float64 `json:"hours_this_week"` // logged by the link's owner since Monday
+}
+
+// LoggedHours is the time and hours of one work log, for charting how much
+// work went into the board over time
+type LoggedHours struct {
+	At         time.Time `json:"at"`
+	Hours      float64   `json:"hours"`
+	Author     string    `json:"author"`
+	CategoryID string    `json:"category_id"`
+}
+
+// Kinds of task event
+const (
+	TaskEventAdded     = "added"
+	TaskEventCompleted = "completed"
+)
+
+// TaskEvent is a task being added or reaching 100%, numbered in the order
+// they happened so that automations polling for them can ask for what came
+// after the last one they saw
+type TaskEvent struct {
+	Seq          int64     `json:"seq"`
+	Kind         string    `json:"kind"`
+	At           time.Time `json:"at"`
+	TaskID       string    `json:"task_id"`
+	TaskName     string    `json:"task_name"`
+	CategoryID   string    `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Completion   int       `json:"completion"` // as the task is now
+}
+
+// Kinds of board change
+const (
+	ChangeAdded   = "added"
+	ChangeChanged = "changed"
+	ChangeDeleted = "deleted"
+)
+
+// BoardChange is a category, task, subtask, or work log being added,
+// changed, or deleted, numbered in the order they happened so that a client
+// can ask for what came after the last one it saw
+type BoardChange struct {
+	Seq        int64     `json:"seq"`
+	EntityType string    `json:"entity_type"` // one of the Entity types
+	EntityID   string    `json:"entity_id"`
+	CategoryID string    `json:"category_id"` // the category's own ID for a category
+	Kind       string    `json:"kind"`
+	At         time.Time `json:"at"`
+}
+
+// HookToken lets simple clients such as phone shortcuts act as a user
+// through a secret URL instead of signing in. Only a hash of the secret is
+// kept. A token scoped to a category reaches only that category, so a
+// script can be given one board without the rest.
+type HookToken struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`                  // what the user called it, e.g. the device
+	CategoryID string    `json:"category_id,omitempty"` // empty for the whole board
+	TokenHash  string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"` // zero if never used
+}
+
+// SyncPeer is another compass instance that a board is mirrored with. A
+// peer with a URL is pushed to and pulled from on a schedule; one without
+// only answers the other side's requests. Both sides sign with Secret.
+type SyncPeer struct {
+	ID         string    `json:"id"`
+	CategoryID string    `json:"category_id"` // the board, which has the same ID on both sides
+	Name       string    `json:"name"`
+	URL        string    `json:"url"` // the peer's base URL; empty if it only connects to us
+	Secret     string    `json:"-"`
+	PushedSeq  int64     `json:"pushed_seq"` // our last change the peer has
+	PulledSeq  int64     `json:"pulled_seq"` // the peer's last change we have
+	CreatedAt  time.Time `json:"created_at"`
+	SyncedAt   time.Time `json:"synced_at"`  // zero if never synced
+	LastError  string    `json:"last_error"` // empty if the last sync worked
+}
+
+// Entities a board's change log records
+const (
+	SyncCategory = "category"
+	SyncTask     = "task"
+	SyncSubtask  = "subtask"
+)
+
+// SyncChange is one entry in a board's change log: an entity as it became,
+// or its deletion. When two instances change the same entity, the later
+// change wins.
+type SyncChange struct {
+	Seq        int64           `json:"seq"`
+	EntityType string          `json:"entity_type"` // SyncCategory, SyncTask, or SyncSubtask
+	EntityID   string          `json:"entity_id"`
+	Deleted    bool            `json:"deleted,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+// QueuedNotification is an event held for a user's daily digest on one
+// channel
+type QueuedNotification struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Channel   string    `json:"channel"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PushSubscription is a browser's Web Push endpoint for one user. P256dh
+// and Auth are the browser's keys for encrypting payloads, base64url
+// encoded as the browser hands them out.
+type PushSubscription struct {
+	Endpoint  string    `json:"endpoint"`
+	UserID    string    `json:"user_id"`
+	P256dh    string    `json:"p256dh"`
+	Auth      string    `json:"auth"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Mail is an email waiting to be sent. Failed attempts are retried later,
+// until the mailer gives up on it.
+type Mail struct {
+	ID            int64     `json:"id"`
+	To            string    `json:"to"`
+	Subject       string    `json:"subject"`
+	Text          string    `json:"text"`
+	HTML          string    `json:"html"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Push services a user can point notifications at
+const (
+	ServiceNtfy   = "ntfy"
+	ServiceGotify = "gotify"
+)
+
+// NotifyService is where a user has notifications pushed on a self-hosted
+// or public push service. Topic is only used by ntfy; Token is an ntfy
+// access token, which is optional, or a Gotify application token. AppURL is
+// the address compass was reached at when it was set up, for notifications
+// to link back to.
+type NotifyService struct {
+	UserID    string    `json:"user_id"`
+	Service   string    `json:"service"`
+	URL       string    `json:"url"`
+	Topic     string    `json:"topic"`
+	Token     string    `json:"-"`
+	AppURL    string    `json:"app_url"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -2,29 +2,459 @@ package domain
 
 import "time"
 
+// Store persists the board. Update* methods write every editable field as
+// given, including empty strings; callers merge partial changes into the
+// fetched entity first. Each update is recorded as a revision.
 type Store interface {
 	GetCategories() ([]*Category, error)
 	GetCategory(id string) (*Category, error)
-	AddCategory(name string) (*Category, error)
-	UpdateCategory(cat *Category) (*Category, error)
-	DeleteCategory(id string) (*Category, error)
+	// AddCategory adds a category belonging to ownerID, or to nobody in
+	// particular when it is empty.
+	AddCategory(name string, ownerID string) (*Category, error)
+	UpdateCategory(cat *Category, actor string) (*Category, error)
+	// MergeCategories moves everything in one category into another,
+	// after the target's own tasks, then removes the emptied category
+	MergeCategories(fromID, intoID, actor string) (*Category, error)
+	DeleteCategory(id string, actor string) (*TrashEntry, error)
 	ReorderCategories(ids []string) error
 
 	GetTask(id string) (*Task, error)
 	AddTask(catID string, name string) (*Task, error)
-	UpdateTask(task *Task) (*Task, error)
-	DeleteTask(id string) (*Task, error)
+
+	// FindSimilarTasks lists up to limit tasks in the category whose names
+	// are at least minSimilarity alike to name, the closest first
+	FindSimilarTasks(catID, name string, minSimilarity float64, limit int) ([]*SimilarTask, error)
+
+	// Search finds the categories, tasks, subtasks, and work logs whose
+	// names or text contain every word of query, the last as a prefix so
+	// results can follow typing, best matches first and names counting
+	// most. A blank query finds nothing.
+	Search(query string) ([]*SearchResult, error)
+	UpdateTask(task *Task, actor string) (*Task, error)
+	DeleteTask(id string, actor string) (*TrashEntry, error)
 	ReorderTasks(catID string, taskIDs []string) error
 
 	GetSubtask(id string) (*Subtask, error)
 	AddSubtask(taskID string, name string) (*Subtask, error)
-	UpdateSubtask(sub *Subtask) (*Subtask, error)
-	DeleteSubtask(id string) (*Subtask, error)
+	UpdateSubtask(sub *Subtask, actor string) (*Subtask, error)
+	DeleteSubtask(id string, actor string) (*TrashEntry, error)
 	ReorderSubtasks(taskID string, subIDs []string) error
 
-	AddWorkLogForTask(taskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time) (*WorkLog, error)
-	AddWorkLogForSubtask(subtaskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time) (*WorkLog, error)
+	AddWorkLogForTask(taskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time, author string) (*WorkLog, error)
+	AddWorkLogForSubtask(subtaskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time, author string) (*WorkLog, error)
 	GetWorkLogsForSubtask(subtaskID string) ([]*WorkLog, error)
 	GetWorkLogsForTask(taskID string) ([]*WorkLog, error)
 	GetWorkLogsForCategory(categoryID string) ([]*WorkLog, error)
+	// UpdateWorkLog edits a work log in place, recording the edit in the
+	// audit log. CorrectWorkLog instead leaves the original untouched and
+	// appends an adjustment entry that brings its effective hours to
+	// hoursWorked.
+	UpdateWorkLog(id string, hoursWorked float64, workDescription string, actor string) (*WorkLog, error)
+	CorrectWorkLog(id string, hoursWorked float64, workDescription string, actor string) (*WorkLog, error)
+	// GetRecentWork lists the tasks and subtasks author last logged work
+	// on, most recent first, one entry each.
+	GetRecentWork(author string, limit int) ([]*RecentWork, error)
+
+	// AddAttachment records an uploaded file against a work log if WorkLogID
+	// is set, otherwise against the task. IDs and times are assigned here.
+	AddAttachment(a *Attachment) (*Attachment, error)
+	GetAttachment(id string) (*Attachment, error)
+	DeleteAttachment(id string) (*Attachment, error)
+
+	// AddLink validates and records a link on a task. Previews are cached
+	// per URL: GetStaleLinkURLs lists URLs whose preview is missing or was
+	// fetched before the given time, and SaveLinkPreview replaces one.
+	AddLink(l *Link) (*Link, error)
+	DeleteLink(id string) (*Link, error)
+	GetLink(id string) (*Link, error)
+	GetLinkPreview(url string) (*LinkPreview, error)
+	SaveLinkPreview(p *LinkPreview) error
+	GetStaleLinkURLs(before time.Time, limit int) ([]string, error)
+
+	// ResolveTaskRef finds the task a [[task:...]] reference points at. A
+	// prefix matching more than one task is ErrConflict. Backlinks to a task
+	// come with GetTask, from an index kept up to date on every write.
+	ResolveTaskRef(ref string) (*TaskRef, error)
+
+	// SavePushSubscription stores a subscription, moving the endpoint to
+	// sub.UserID if another user had it. DeletePushSubscription removes
+	// userID's subscription at endpoint; an empty userID removes it for
+	// whoever has it, for endpoints the push service reports gone.
+	SavePushSubscription(sub *PushSubscription) error
+	DeletePushSubscription(userID string, endpoint string) error
+	GetPushSubscriptions(userID string) ([]*PushSubscription, error)
+	// GetPushSubscribers lists the users with at least one subscription.
+	GetPushSubscribers() ([]string, error)
+	// SaveNotifyService sets where a user's notifications go on a push
+	// service, replacing what they had there. GetNotifyService is
+	// ErrNotFound if they have not set one up, and GetNotifyServiceUsers
+	// lists those who have.
+	SaveNotifyService(svc *NotifyService) error
+	GetNotifyService(userID string, service string) (*NotifyService, error)
+	DeleteNotifyService(userID string, service string) error
+	GetNotifyServiceUsers(service string) ([]string, error)
+	// GetEmailUsers lists the users with an email address set.
+	GetEmailUsers() ([]string, error)
+
+	// QueueMail holds m to be sent at m.NextAttemptAt, or at once if that is
+	// unset. GetDueMail lists up to limit messages due by now, oldest first;
+	// each is then deleted once sent or rescheduled by RetryMail.
+	QueueMail(m *Mail) error
+	GetDueMail(now time.Time, limit int) ([]*Mail, error)
+	RetryMail(id int64, next time.Time, lastErr string) error
+	DeleteMail(id int64) error
+
+	// AddNudge sets a reminder on a task; the caller computes NextAt.
+	// GetDueNudges lists nudges due at or before now, and AdvanceNudge
+	// moves one to its next occurrence. Nudges come with GetTask.
+	AddNudge(n *Nudge) (*Nudge, error)
+	GetNudge(id string) (*Nudge, error)
+	DeleteNudge(id string) error
+	GetDueNudges(now time.Time) ([]*Nudge, error)
+	AdvanceNudge(id string, next time.Time) error
+
+	// GetTimers lists the user's timers, oldest first. A timer is only
+	// ever reached through its user; another's is ErrNotFound. StartTimer
+	// starts one on a task, which is ErrConflict while another is started
+	// unless the user has opted into MultipleTimers. PauseTimer and
+	// ResumeTimer are no-ops on a timer already paused or running.
+	// TouchTimers records a heartbeat for all the user's timers, first
+	// pausing them at their last one if that was longer ago than the user's
+	// idle threshold. StopTimer removes a timer and returns it as it stood.
+	GetTimers(userID string) ([]*Timer, error)
+	GetTimer(userID, id string) (*Timer, error)
+	StartTimer(userID, taskID, name string) (*Timer, error)
+	PauseTimer(userID, id string) (*Timer, error)
+	ResumeTimer(userID, id string) (*Timer, error)
+	TouchTimers(userID string) ([]*Timer, error)
+	StopTimer(userID, id string) (*Timer, error)
+	// PauseIdleTimers pauses the running timers not heard from within their
+	// users' idle thresholds as of now, each at its last heartbeat.
+	PauseIdleTimers(now time.Time) (int, error)
+
+	// AddNotification puts n in its user's inbox. GetNotifications lists
+	// the newest first. Marking another user's notification read is
+	// ErrNotFound.
+	AddNotification(n *Notification) error
+	GetNotifications(userID string, limit int) ([]*Notification, error)
+	CountUnreadNotifications(userID string) (int, error)
+	MarkNotificationRead(userID string, id string) error
+	MarkAllNotificationsRead(userID string) error
+	// GetUsers lists everyone known to the board: those who have saved
+	// preferences or logged work.
+	GetUsers() ([]string, error)
+
+	// ExportAccount gathers everything kept about a user, read in one
+	// transaction. A user the board knows nothing about gets an empty
+	// export rather than ErrNotFound.
+	ExportAccount(userID string) (*AccountExport, error)
+
+	// AddHookToken records a token for t.UserID under t.TokenHash, scoped
+	// to t.CategoryID if set; an unknown category is ErrNotFound. Deleting
+	// the category deletes the tokens scoped to it. GetHookTokenByHash
+	// finds the token a hook URL carries and notes that it was used.
+	// Deleting another user's token is ErrNotFound.
+	AddHookToken(t *HookToken) (*HookToken, error)
+	GetHookTokens(userID string) ([]*HookToken, error)
+	GetHookTokenByHash(hash string) (*HookToken, error)
+	DeleteHookToken(userID string, id string) error
+	// GetTaskEvents lists up to limit events of a kind after the one
+	// numbered since, oldest first; with since 0 it lists the latest.
+	// Events for tasks since deleted are dropped.
+	GetTaskEvents(kind string, since int64, limit int) ([]*TaskEvent, error)
+	// GetBoardChanges lists up to limit changes after the one numbered
+	// since, oldest first. GetBoardChangeRange gives the first change still
+	// kept and the last one made, so a client whose place has been pruned
+	// away can tell it missed some; the first is last+1 when none are kept.
+	// PruneBoardChanges forgets those made before a time.
+	GetBoardChanges(since int64, limit int) ([]*BoardChange, error)
+	GetBoardChangeRange() (first, last int64, err error)
+	PruneBoardChanges(before time.Time) (int, error)
+	// A stats link publishes BoardStats under a token, one per user.
+	// SetStatsToken replaces the user's token, or removes it if empty;
+	// GetStatsToken is empty if they have none. GetStatsTokenUser finds
+	// whose a token is.
+	SetStatsToken(userID string, token string) error
+	GetStatsToken(userID string) (string, error)
+	GetStatsTokenUser(token string) (string, error)
+	// GetBoardStats totals the board but for the hidden categories, with
+	// the hours userID logged since.
+	GetBoardStats(userID string, hidden []string, since time.Time) (*BoardStats, error)
+	// GetLoggedHours lists the hours logged from up to to, oldest first.
+	GetLoggedHours(from, to time.Time) ([]*LoggedHours, error)
+	// A category's share token lets its progress be embedded on other
+	// sites. SetCategoryShareToken replaces it, or stops sharing if empty.
+	SetCategoryShareToken(catID string, token string) error
+	GetCategoryByShareToken(token string) (*Category, error)
+	// A category's alert token lets monitoring systems add tasks to it, and
+	// is stored hashed. SetCategoryAlertToken replaces it, or turns alerts
+	// off if empty.
+	SetCategoryAlertToken(catID string, tokenHash string) error
+	GetCategoryByAlertToken(tokenHash string) (*Category, error)
+
+	// QueueNotification holds n for the user's next digest.
+	// TakeQueuedNotifications removes and returns the user's notifications
+	// queued before the given time, oldest first. GetDigestRecipients lists
+	// users with anything queued.
+	QueueNotification(n *QueuedNotification) error
+	TakeQueuedNotifications(userID string, before time.Time) ([]*QueuedNotification, error)
+	GetDigestRecipients() ([]string, error)
+
+	// GetQueue lists the user's focus queue in order. AddToQueue puts a
+	// task at the end, and does nothing if it is already queued.
+	// ReorderQueue takes every queued task ID in the new order, failing
+	// with ErrStaleOrder otherwise.
+	GetQueue(userID string) ([]*QueuedTask, error)
+	AddToQueue(userID string, taskID string) error
+	RemoveFromQueue(userID string, taskID string) error
+	ReorderQueue(userID string, taskIDs []string) error
+
+	// AddDependency makes a task wait on another in the same category,
+	// failing with ErrConflict if that would make a cycle. Dependencies
+	// come with the task in DependsOn.
+	AddDependency(taskID string, dependsOnID string) error
+	RemoveDependency(taskID string, dependsOnID string) error
+
+	// Contexts are GTD-style tags saying where a task can be done. They are
+	// stored without the @, lowercased; setting a task's contexts replaces
+	// them all and returns the cleaned set.
+	SetTaskContexts(taskID string, contexts []string) ([]string, error)
+	GetContexts() ([]string, error) // every context in use, alphabetically
+	GetContextTaskIDs(context string) ([]string, error)
+
+	// Tags label tasks across categories. A name already taken, in any
+	// case, is ErrConflict. Tasks come with their tags, alphabetically;
+	// GetTasksByTag lists the tasks carrying one in board order. Tagging a
+	// task again, or untagging one without the tag, changes nothing.
+	AddTag(name, color string) (*Tag, error)
+	GetTags() ([]*Tag, error) // alphabetically, each with how many tasks carry it
+	GetTag(id string) (*Tag, error)
+	GetTagByName(name string) (*Tag, error)
+	UpdateTag(tag *Tag) (*Tag, error)
+	DeleteTag(id string) error
+	TagTask(taskID, tagID string) error
+	UntagTask(taskID, tagID string) error
+	GetTasksByTag(tagID string) ([]*Task, error)
+
+	// ReforecastTask sets a task's estimate, keeping the change in its
+	// estimate history with the hours logged on it so far, and records a
+	// revision as any edit does. GetTask loads the history, newest first.
+	ReforecastTask(taskID string, estimate float64, actor string) (*Task, error)
+
+	// Review. In a category that requires review, RequestReview marks a
+	// task ready and ApproveTask finishes it at 100%; the store refuses to
+	// finish it any other way, with ErrConflict, and one taken back under
+	// 100% after approval must be reviewed again. ReturnTask sends a task
+	// that is ready back to be worked on, with a note saying why. A task
+	// in the wrong state for the move is ErrConflict. Each move is kept as
+	// a ReviewTransition, which GetTask loads newest first. Who may approve
+	// is for the caller to decide.
+	RequestReview(taskID, actor string) (*Task, error)
+	ApproveTask(taskID, actor string) (*Task, error)
+	ReturnTask(taskID, note, actor string) (*Task, error)
+
+	// GetBlockedTasks lists blocked tasks, the longest stuck first
+	GetBlockedTasks() ([]*BlockedTask, error)
+
+	// GetLastWorked maps task IDs to when work was last logged on them,
+	// counting their subtasks
+	GetLastWorked() (map[string]time.Time, error)
+
+	// A category's definition of done is copied onto each task created in
+	// it. Setting a task to 100% fails with ErrConflict while any of its
+	// checks is unchecked, as does unchecking one on a finished task.
+	AddDoneCriterion(categoryID string, text string) (*DoneCriterion, error)
+	DeleteDoneCriterion(id string) error
+	SetTaskCheck(id string, checked bool) (*TaskCheck, error)
+
+	// Aging rules act on tasks that go a number of days without work
+	// logged. GetAgingDue lists each rule and task it has come due for as of
+	// now; RecordAging marks one acted on, so it is not due again until more
+	// work is logged, and writes it to the audit log.
+	AddAgingRule(categoryID string, days int, action string) (*AgingRule, error)
+	DeleteAgingRule(id string) error
+	GetAgingDue(now time.Time) ([]*AgingDue, error)
+	RecordAging(ruleID, taskID, actor, summary string) error
+
+	// Weekly digests append what happened in a category each week to its
+	// description. GetWeeklyDigestsDue lists the categories with digests on
+	// that are yet to have the week starting at start written;
+	// GetCategoryWeek sums up a category's week up to end.
+	// AppendWeeklyDigest adds entry to the description as a revision by
+	// actor, and to the audit log, marking the week written; an empty entry
+	// only marks it.
+	GetWeeklyDigestsDue(start time.Time) ([]string, error)
+	GetCategoryWeek(categoryID string, start, end time.Time) (*CategoryWeek, error)
+	AppendWeeklyDigest(categoryID string, start time.Time, entry, actor string) error
+
+	// Boards are mirrored with sync peers by exchanging change logs.
+	// RecordSyncChanges compares a board with its last recorded state and
+	// logs what changed since; GetSyncChanges reads the log after since.
+	// ApplySyncChanges merges a
+	// peer's changes, keeping whichever side changed an entity last, and
+	// reports how many it applied. Adding a peer for a board that does not
+	// exist yet creates an empty one, belonging to actor, for the first sync
+	// to fill in.
+	AddSyncPeer(p *SyncPeer, actor string) (*SyncPeer, error)
+	GetSyncPeer(id string) (*SyncPeer, error)
+	GetSyncPeers(categoryID string) ([]*SyncPeer, error)
+	GetAllSyncPeers() ([]*SyncPeer, error)
+	DeleteSyncPeer(id string) error
+	RecordSyncResult(id string, pushedSeq, pulledSeq int64, syncErr string) error
+	RecordSyncChanges(categoryID string) error
+	GetSyncChanges(categoryID string, since int64, limit int) ([]*SyncChange, error)
+	ApplySyncChanges(peerID string, changes []*SyncChange) (int, error)
+
+	// Objectives are quarterly goals, listed with their key results and
+	// the tasks linked to those. Deleting an objective deletes its key
+	// results. GetTaskKeyResults lists the key results a task counts towards.
+	GetObjectives(quarter string) ([]*Objective, error)
+	AddObjective(o *Objective) (*Objective, error)
+	DeleteObjective(id string) error
+	AddKeyResult(kr *KeyResult) (*KeyResult, error)
+	GetKeyResult(id string) (*KeyResult, error)
+	UpdateKeyResult(kr *KeyResult) error
+	DeleteKeyResult(id string) error
+	LinkKeyResult(keyResultID string, taskID string) error
+	UnlinkKeyResult(keyResultID string, taskID string) error
+	GetTaskKeyResults(taskID string) ([]*KeyResult, error)
+
+	// Time blocks plan a user's day. Adding or moving a block that overlaps
+	// another of theirs on the same day fails with ErrConflict, as does
+	// moving one that has already been logged as work.
+	GetTimeBlocks(userID string, day string) ([]*TimeBlock, error)
+	GetTimeBlock(id string) (*TimeBlock, error)
+	AddTimeBlock(b *TimeBlock) (*TimeBlock, error)
+	MoveTimeBlock(id string, start, end int) (*TimeBlock, error)
+	DeleteTimeBlock(id string) error
+	SetTimeBlockWorkLog(id string, workLogID string) error
+
+	// GetAllocations lists the tasks the user planned hours for in the week
+	// starting on week, and any other task they logged work on that week,
+	// with the hours logged between from and to. SetAllocation replaces the
+	// planned hours; zero removes the task from the week.
+	GetAllocations(userID string, week string, from, to time.Time) ([]*Allocation, error)
+	// GetWorkloads lists everyone's plans for the weeks starting from the
+	// Monday from up to before to, both YYYY-MM-DD, by week and then user.
+	// Only unfinished tasks count, so someone whose planned tasks are all
+	// done has no workload that week.
+	GetWorkloads(from, to string) ([]*Workload, error)
+
+	// AddCommitEvent records a commit reported by the user's git hook. A
+	// commit with a SHA already recorded for them is not added again, and
+	// returns ErrConflict. One not linked to a task takes the task of the
+	// latest commit before it on the same repository and branch.
+	AddCommitEvent(c *CommitEvent) (*CommitEvent, error)
+	// GetPendingCommits lists the user's commits that have been neither
+	// logged as work nor dismissed, oldest first.
+	GetPendingCommits(userID string) ([]*CommitEvent, error)
+	// SettleCommits takes the user's commits out of those pending, once
+	// they have been logged as work or dismissed. They are kept, so a
+	// commit reported again stays settled.
+	SettleCommits(userID string, ids []string) error
+	SetAllocation(userID string, taskID string, week string, hours float64) error
+
+	// Deleted entities move to the trash and can be restored until purged.
+	GetTrashEntry(id string) (*TrashEntry, error)
+	RestoreTrashEntry(id string, actor string) (*TrashEntry, error)
+	PurgeTrash(before time.Time) (int, error)
+
+	// AuditSignIn records a sign-in event, one of the AuditLogin actions,
+	// for the client at address.
+	AuditSignIn(action, address, summary string) error
+	// GetChangeAuthors lists who edited the board, logged work, or made an
+	// audited change since, most active first.
+	GetChangeAuthors(since time.Time) ([]string, error)
+
+	// ExportDump reads the whole board at one point in time. ImportDump
+	// restores a dump into an empty board, failing with ErrConflict if
+	// anything is already there.
+	ExportDump() (*Dump, error)
+	ImportDump(dump *Dump, actor string) error
+
+	// ExportWorkspace reads the board at one point in time as a Workspace.
+	// ImportWorkspace adds a Workspace in one transaction, keeping its IDs
+	// and giving anything without one a new one, or adds none of it if any
+	// part is invalid. The categories belong to actor, and it only runs
+	// while actor has none of their own.
+	ExportWorkspace() (*Workspace, error)
+	ImportWorkspace(ws *Workspace, actor string) error
+	// ImportVault adds tasks read from a markdown vault to a category.
+	// Ones imported into it before, matched by Source, are not added
+	// again, but gain any new checkboxes and complete the subtasks whose
+	// boxes have since been ticked.
+	ImportVault(categoryID string, tasks []*VaultTask, actor string) (*VaultImport, error)
+	// AddAlertTask adds a task to a category for a firing alert, unless a
+	// task in it for the same fingerprint is still open, in which case that
+	// task is returned and added is false.
+	AddAlertTask(categoryID string, alert *Alert, actor string) (task *Task, added bool, err error)
+
+	// GetRevisions lists an entity's revisions, newest first.
+	GetRevisions(entityType string, entityID string) ([]*Revision, error)
+	GetRevision(id int64) (*Revision, error)
+
+	// SaveSnapshot stores board as the snapshot for takenAt's day, replacing
+	// an earlier one from the same day. It does nothing and returns false if
+	// board is identical to the latest snapshot.
+	SaveSnapshot(takenAt time.Time, board []byte) (bool, error)
+	// GetSnapshot returns the latest snapshot taken on or before asOf's day.
+	GetSnapshot(asOf time.Time) (*Snapshot, error)
+	// GetSnapshots lists the snapshots for from's day through to's, oldest
+	// first, led by the one still in force on from's day if it is older.
+	GetSnapshots(from, to time.Time) ([]*Snapshot, error)
+
+	// GetPreferences returns the user's preferences, or defaults if none are saved.
+	GetPreferences(userID string) (*Preferences, error)
+	UpdatePreferences(prefs *Preferences) (*Preferences, error)
+	// ProvisionUser saves the display name and email of a user who has no
+	// preferences yet, reporting whether it did. Values that would not be
+	// accepted as preferences are left blank rather than refused.
+	ProvisionUser(prefs *Preferences) (bool, error)
+
+	// GetAccounts lists the users with saved preferences, by handle.
+	GetAccounts() ([]*Account, error)
+	// GetAccount returns ErrNotFound for a user with no saved preferences.
+	GetAccount(handle string) (*Account, error)
+	// CreateAccount saves a user's preferences ahead of their first
+	// sign-in and shares account.Categories with them. It returns
+	// ErrConflict if they already have preferences.
+	CreateAccount(account *Account) (*Account, error)
+	// UpdateAccount changes a user's display name, email, and whether they
+	// are active, leaving their other preferences and categories alone.
+	UpdateAccount(account *Account) (*Account, error)
+
+	// GetGroups lists groups by name, with their members.
+	GetGroups() ([]*Group, error)
+	// AddGroup returns ErrConflict if another group has the name.
+	AddGroup(name string) (*Group, error)
+	DeleteGroup(id string) error
+	// AddGroupMember and RemoveGroupMember change who is in a group, and
+	// so who sees the categories shared with it, from their next request.
+	AddGroupMember(groupID, userID string) error
+	RemoveGroupMember(groupID, userID string) error
+
+	// GetCategoryAccess returns who a category is shared with.
+	GetCategoryAccess(categoryID string) (*CategoryAccess, error)
+	// ShareCategory shares a category with a user, and ShareCategoryWithGroup
+	// with everyone in a group. Sharing twice is not an error.
+	ShareCategory(categoryID, userID string) error
+	UnshareCategory(categoryID, userID string) error
+	ShareCategoryWithGroup(categoryID, groupID string) error
+	UnshareCategoryWithGroup(categoryID, groupID string) error
+	// GetHiddenCategoryIDs lists the categories shared with others but not
+	// with the user, directly or through any group they are in. A user's
+	// own categories are never hidden from them.
+	GetHiddenCategoryIDs(userID string) ([]string, error)
+	// GetOthersCategoryIDs lists the categories that belong to someone
+	// else and are not shared with the user.
+	GetOthersCategoryIDs(userID string) ([]string, error)
+	// GetOwningCategoryID returns the category an entity (by its Entity*
+	// type) is in, and so who it belongs to: everything on the board
+	// belongs to its category's owner. A revision is in the category of
+	// what it is a revision of.
+	GetOwningCategoryID(entityType, id string) (string, error)
+
+	// Seed populates an empty board with sample data for first-run
+	// onboarding, owned by owner.
+	Seed(owner string) error
 }
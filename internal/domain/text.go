@@ -0,0 +1,405 @@
+package domain
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Length limits are counted in characters (runes) after normalization.
+const (
+	MaxNameLength        = 200
+	MaxDescriptionLength = 10000
+)
+
+// NormalizeName cleans a single-line name: invalid UTF-8 is replaced, the
+// text is NFC-normalized, line breaks and other control characters become
+// spaces, runs of whitespace collapse, and the ends are trimmed. It does not
+// reject empty names; callers decide whether a blank name is allowed.
+func NormalizeName(s string) (string, error) {
+	s = norm.NFC.String(strings.ToValidUTF8(s, "�"))
+	s = strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+
+	if n := utf8.RuneCountInString(s); n > MaxNameLength {
+		return "", fmt.Errorf("%w: name is %d characters, the limit is %d", ErrInvalid, n, MaxNameLength)
+	}
+	return s, nil
+}
+
+// NormalizeDescription cleans multi-line free text: invalid UTF-8 is
+// replaced, the text is NFC-normalized, line endings become "\n", control
+// characters other than newline and tab are dropped, and surrounding
+// whitespace is trimmed.
+func NormalizeDescription(s string) (string, error) {
+	s = norm.NFC.String(strings.ToValidUTF8(s, "�"))
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+
+	if n := utf8.RuneCountInString(s); n > MaxDescriptionLength {
+		return "", fmt.Errorf("%w: description is %d characters, the limit is %d", ErrInvalid, n, MaxDescriptionLength)
+	}
+	return s, nil
+}
+
+// CleanName normalizes the name of a category, task, or subtask, which may
+// not be blank.
+func CleanName(s string) (string, error) {
+	s, err := NormalizeName(s)
+	if err != nil {
+		return "", err
+	}
+	if s == "" {
+		return "", fmt.Errorf("%w: name cannot be empty", ErrInvalid)
+	}
+	return s, nil
+}
+
+// Trigrams lists the distinct three-character runs of a name, ignoring case
+// and runs of spaces, the way the task name search index splits it
+func Trigrams(s string) []string {
+	s, _ = NormalizeName(s)
+	r := []rune(strings.ToLower(s))
+	seen := make(map[string]bool)
+	var grams []string
+	for i := 0; i+3 <= len(r); i++ {
+		if g := string(r[i : i+3]); !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
+
+// Similarity is how alike two names are, from 0 to 1: the trigrams they
+// share, counted once for each name, over all the trigrams of both
+func Similarity(a, b string) float64 {
+	ga, gb := Trigrams(a), Trigrams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, g := range ga {
+		if slices.Contains(gb, g) {
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(len(ga)+len(gb))
+}
+
+// taskRefPattern matches a reference to a task in free text, written
+// [[task:ID]] with either the full ID or a prefix of at least 8 characters.
+var taskRefPattern = regexp.MustCompile(`\[\[task:([0-9a-f-]{8,36})\]\]`)
+
+// imagePattern matches an image embedded in free text, written as the
+// markdown ![alt](/attachments/ID) that pasting an image inserts. Only
+// attachments are recognized, never outside URLs.
+var imagePattern = regexp.MustCompile(`!\[([^\]\n]*)\]\(/attachments/([0-9a-f-]{36})\)`)
+
+var markupPattern = regexp.MustCompile(taskRefPattern.String() + "|" + imagePattern.String())
+
+// TextSegment is a run of plain text, a task reference, or an embedded image
+type TextSegment struct {
+	Text    string // the literal text; for markup, as written
+	Ref     string // the referenced ID or prefix, if this is a task reference
+	ImageID string // the attachment ID, if this is an image
+	Alt     string // the image's alternative text
+}
+
+// SplitText breaks text into plain runs, task references, and images, in
+// order
+func SplitText(text string) []TextSegment {
+	var segments []TextSegment
+	last := 0
+	for _, m := range markupPattern.FindAllStringSubmatchIndex(text, -1) {
+		if m[0] > last {
+			segments = append(segments, TextSegment{Text: text[last:m[0]]})
+		}
+		seg := TextSegment{Text: text[m[0]:m[1]]}
+		if m[2] >= 0 {
+			seg.Ref = text[m[2]:m[3]]
+		} else {
+			seg.Alt, seg.ImageID = text[m[4]:m[5]], text[m[6]:m[7]]
+		}
+		segments = append(segments, seg)
+		last = m[1]
+	}
+	if last < len(text) {
+		segments = append(segments, TextSegment{Text: text[last:]})
+	}
+	return segments
+}
+
+// ImageMarkup is how an attachment is embedded in free text
+func ImageMarkup(alt, attachmentID string) string {
+	alt = strings.NewReplacer("[", "", "]", "", "\n", " ").Replace(alt)
+	return "![" + alt + "](/attachments/" + attachmentID + ")"
+}
+
+// TaskRefs lists the distinct task references in text
+func TaskRefs(text string) []string {
+	var refs []string
+	for _, m := range taskRefPattern.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(refs, m[1]) {
+			refs = append(refs, m[1])
+		}
+	}
+	return refs
+}
+
+// branchTaskRefPattern matches a task ID prefix in a branch name, e.g.
+// "fix/3f2a9c1e-login"
+var branchTaskRefPattern = regexp.MustCompile(`(?:^|[^0-9a-f])([0-9a-f]{8})(?:[^0-9a-f]|$)`)
+
+// CommitTaskRefs lists the task references in a commit, from the
+// [[task:...]] references in its message and then any task ID prefix in its
+// branch name
+func CommitTaskRefs(message, branch string) []string {
+	refs := TaskRefs(message)
+	for _, m := range branchTaskRefPattern.FindAllStringSubmatch(strings.ToLower(branch), -1) {
+		if !slices.Contains(refs, m[1]) {
+			refs = append(refs, m[1])
+		}
+	}
+	return refs
+}
+
+// ShortTaskRef is the shortest reference to a task that is normally unique
+func ShortTaskRef(id string) string {
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "[[task:" + id + "]]"
+}
+
+func normalizeNamed(name, description *string) error {
+	n, err := CleanName(*name)
+	if err != nil {
+		return err
+	}
+	d, err := NormalizeDescription(*description)
+	if err != nil {
+		return err
+	}
+	*name, *description = n, d
+	return nil
+}
+
+// Normalize cleans the category's text fields in place and checks its
+// color and icon against the palettes
+func (c *Category) Normalize() error {
+	if c.Color != "" && !slices.Contains(CategoryColors, c.Color) {
+		return fmt.Errorf("%w: unknown category color %q", ErrInvalid, c.Color)
+	}
+	if c.Icon != "" && !slices.Contains(CategoryIcons, c.Icon) {
+		return fmt.Errorf("%w: unknown category icon %q", ErrInvalid, c.Icon)
+	}
+	if c.WIPLimit < 0 {
+		return fmt.Errorf("%w: a work-in-progress limit cannot be negative", ErrInvalid)
+	}
+	return normalizeNamed(&c.Name, &c.Description)
+}
+
+// Normalize cleans the tag's name in place and checks its color against
+// the palette categories use
+func (t *Tag) Normalize() error {
+	if t.Color != "" && !slices.Contains(CategoryColors, t.Color) {
+		return fmt.Errorf("%w: unknown tag color %q", ErrInvalid, t.Color)
+	}
+	name, err := CleanName(t.Name)
+	if err != nil {
+		return err
+	}
+	t.Name = name
+	return nil
+}
+
+// Normalize cleans the task's text fields in place
+func (t *Task) Normalize() error {
+	if t.Estimate < 0 {
+		return fmt.Errorf("%w: estimate cannot be negative", ErrInvalid)
+	}
+	for _, date := range []*string{&t.StartDate, &t.DueDate} {
+		if err := normalizeDate(date); err != nil {
+			return err
+		}
+	}
+	if t.StartDate != "" && t.DueDate != "" && t.DueDate < t.StartDate {
+		return fmt.Errorf("%w: a task cannot be due before it starts", ErrInvalid)
+	}
+	if t.Priority < PriorityNormal || t.Priority > PriorityUrgent {
+		return fmt.Errorf("%w: unknown priority %d", ErrInvalid, t.Priority)
+	}
+	if t.Size != "" && !slices.Contains(TaskSizes, t.Size) {
+		return fmt.Errorf("%w: unknown task size %q", ErrInvalid, t.Size)
+	}
+	if err := t.normalizeBlocked(); err != nil {
+		return err
+	}
+	var err error
+	if t.Flag, err = NormalizeName(t.Flag); err != nil {
+		return err
+	}
+	return normalizeNamed(&t.Name, &t.Description)
+}
+
+// MaxContextLength is the longest a context tag can be, in characters
+const MaxContextLength = 40
+
+var contextPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}][\p{Ll}\p{Lo}\p{N}_-]*$`)
+
+// NormalizeContext cleans a context tag such as "@Deep-Work" to the form it
+// is stored in, "deep-work": without the @, lowercased. A blank tag stays
+// blank; one with spaces or punctuation is rejected.
+func NormalizeContext(s string) (string, error) {
+	s = strings.ToLower(norm.NFC.String(strings.TrimSpace(s)))
+	s = strings.TrimPrefix(s, "@")
+	if s == "" {
+		return "", nil
+	}
+	if n := utf8.RuneCountInString(s); n > MaxContextLength {
+		return "", fmt.Errorf("%w: context is %d characters, the limit is %d", ErrInvalid, n, MaxContextLength)
+	}
+	if !contextPattern.MatchString(s) {
+		return "", fmt.Errorf("%w: contexts are single words like @home or @deep-work, not %q", ErrInvalid, s)
+	}
+	return s, nil
+}
+
+// NormalizeEmail checks an address notifications can be emailed to, such
+// as "ada@example.com", and trims it. A blank address stays blank; one with
+// a display name, or that is not an address at all, is rejected.
+func NormalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", fmt.Errorf("%w: %q is not an email address", ErrInvalid, s)
+	}
+	return s, nil
+}
+
+// NormalizeLanguage checks a BCP 47 language tag such as "en-GB" and puts
+// it in canonical form. A blank tag stays blank.
+func NormalizeLanguage(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not a language tag", ErrInvalid, s)
+	}
+	return tag.String(), nil
+}
+
+// normalizeBlocked cleans the reason a task is blocked and who it waits on.
+// A blocked task needs a reason; an unblocked one keeps neither.
+func (t *Task) normalizeBlocked() error {
+	if !t.Blocked {
+		t.BlockedReason, t.WaitingOn = "", ""
+		return nil
+	}
+	var err error
+	if t.BlockedReason, err = NormalizeName(t.BlockedReason); err != nil {
+		return err
+	}
+	if t.BlockedReason == "" {
+		return fmt.Errorf("%w: say why the task is blocked", ErrInvalid)
+	}
+	t.WaitingOn, err = NormalizeName(t.WaitingOn)
+	return err
+}
+
+// Normalize cleans the objective's text fields in place and checks its quarter
+func (o *Objective) Normalize() error {
+	if _, err := QuarterStart(o.Quarter); err != nil {
+		return err
+	}
+	return normalizeNamed(&o.Name, &o.Description)
+}
+
+// Normalize cleans the key result's name and unit in place and checks its
+// measure. A key result measured by hand needs a target to measure against.
+func (kr *KeyResult) Normalize() error {
+	if !kr.FromTasks && kr.Target <= 0 {
+		return fmt.Errorf("%w: a key result measured by hand needs a target above zero", ErrInvalid)
+	}
+	if kr.Target < 0 || kr.Current < 0 {
+		return fmt.Errorf("%w: key result values cannot be negative", ErrInvalid)
+	}
+	name, err := CleanName(kr.Name)
+	if err != nil {
+		return err
+	}
+	unit, err := NormalizeName(kr.Unit)
+	if err != nil {
+		return err
+	}
+	kr.Name, kr.Unit = name, unit
+	return nil
+}
+
+// Normalize cleans the subtask's text fields in place
+func (s *Subtask) Normalize() error {
+	if s.Estimate < 0 {
+		return fmt.Errorf("%w: estimate cannot be negative", ErrInvalid)
+	}
+	if err := normalizeDate(&s.DueDate); err != nil {
+		return err
+	}
+	return normalizeNamed(&s.Name, &s.Description)
+}
+
+// normalizeDate trims a YYYY-MM-DD date in place, which may be blank
+func normalizeDate(date *string) error {
+	*date = strings.TrimSpace(*date)
+	if _, err := time.Parse(time.DateOnly, *date); *date != "" && err != nil {
+		return fmt.Errorf("%w: dates must be written as YYYY-MM-DD", ErrInvalid)
+	}
+	return nil
+}
+
+// Normalize validates the link's kind and URL. Only absolute http and https
+// URLs are accepted, so a link can never run script when followed.
+func (l *Link) Normalize() error {
+	if l.Kind == "" {
+		l.Kind = LinkOther
+	}
+	if !slices.Contains(LinkKinds, l.Kind) {
+		return fmt.Errorf("%w: unknown link kind %q", ErrInvalid, l.Kind)
+	}
+
+	u, err := url.Parse(strings.TrimSpace(l.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: links must be http or https URLs", ErrInvalid)
+	}
+	l.URL = u.String()
+	if n := utf8.RuneCountInString(l.URL); n > MaxDescriptionLength {
+		return fmt.Errorf("%w: URL is %d characters, the limit is %d", ErrInvalid, n, MaxDescriptionLength)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Turn the compost", "Turn the compost"},
+		{"trimmed", "  Turn the compost\t", "Turn the compost"},
+		{"collapsed spaces", "Turn   the  compost", "Turn the compost"},
+		{"line breaks", "Turn\r\nthe\ncompost", "Turn the compost"},
+		{"control characters", "Turn\x00the\x1bcompost\x7f", "Turn the compost"},
+		{"composed", "cafe\u0301", "caf\u00e9"},
+		{"hangul jamo", "\u1112\u1161\u11ab", "\ud55c"},
+		{"emoji", "Ship it 🚀", "Ship it 🚀"},
+		{"emoji sequence", "\U0001f469\u200d\U0001f469\u200d\U0001f467 family dinner", "\U0001f469\u200d\U0001f469\u200d\U0001f467 family dinner"},
+		{"flag", "🇳🇿 trip", "🇳🇿 trip"},
+		{"skin tone", "👋🏽 wave", "👋🏽 wave"},
+		{"hebrew", "  שלום עולם ", "שלום עולם"},
+		{"arabic", "مرحبا بالعالم", "مرحبا بالعالم"},
+		{"mixed direction", "Release v2 — גרסה 2", "Release v2 — גרסה 2"},
+		{"direction marks", "\u200fשלום\u200e", "\u200fשלום\u200e"},
+		{"invalid utf-8", "bad \xff\xfe bytes", "bad � bytes"},
+		{"blank", " \t\r\n ", ""},
+		{"markup", "<b>bold</b>", "<b>bold</b>"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeName(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDescription(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Turned half the pile.", "Turned half the pile."},
+		{"trimmed", "\n\n  Turned half.  \n", "Turned half."},
+		{"line endings", "one\r\ntwo\rthree\nfour", "one\ntwo\nthree\nfour"},
+		{"tabs kept", "a\tb", "a\tb"},
+		{"control characters dropped", "a\x00b\x1bc\x7fd", "abcd"},
+		{"composed", "cafe\u0301", "caf\u00e9"},
+		{"emoji", "Done 🎉\n✅ tested", "Done 🎉\n✅ tested"},
+		{"rtl paragraphs", "שלום\nمرحبا", "שלום\nمرحبا"},
+		{"invalid utf-8", "\xc3\x28", "�("},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeDescription(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("NormalizeDescription(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestLengthLimits checks that limits count characters after normalizing,
+// not bytes or what was sent
+func TestLengthLimits(t *testing.T) {
+	cases := []struct {
+		name      string
+		normalize func(string) (string, error)
+		in        string
+		ok        bool
+	}{
+		{"name at the limit", NormalizeName, strings.Repeat("a", MaxNameLength), true},
+		{"name over the limit", NormalizeName, strings.Repeat("a", MaxNameLength+1), false},
+		{"multibyte name at the limit", NormalizeName, strings.Repeat("ש", MaxNameLength), true},
+		{"emoji name at the limit", NormalizeName, strings.Repeat("🚀", MaxNameLength), true},
+		{"emoji name over the limit", NormalizeName, strings.Repeat("🚀", MaxNameLength+1), false},
+		{"padding doesn't count", NormalizeName, strings.Repeat(" ", 10*MaxNameLength) + "a" + strings.Repeat("\n", 10*MaxNameLength), true},
+		{"collapsing whitespace first", NormalizeName, strings.Repeat("a ", MaxNameLength/2) + strings.Repeat("  ", MaxNameLength), true},
+		{"decomposed accents compose first", NormalizeName, strings.Repeat("e\u0301", MaxNameLength), true},
+		{"combining marks that don't compose", NormalizeName, "a" + strings.Repeat("\u0301", MaxNameLength), false},
+		{"dropped controls don't count", NormalizeDescription, strings.Repeat("\x00", MaxDescriptionLength) + "a", true},
+		{"description at the limit", NormalizeDescription, strings.Repeat("a", MaxDescriptionLength), true},
+		{"description over the limit", NormalizeDescription, strings.Repeat("a", MaxDescriptionLength+1), false},
+		{"huge description", NormalizeDescription, strings.Repeat("🚀 ", 1<<20), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.normalize(tc.in)
+			if tc.ok && err != nil {
+				t.Errorf("refused: %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrInvalid) {
+				t.Errorf("got %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestCleanNameRefusesBlank(t *testing.T) {
+	for _, in := range []string{"", "   ", "\n\t", "\x00\x01", " "} {
+		if _, err := CleanName(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("CleanName(%q) = %v, want ErrInvalid", in, err)
+		}
+	}
+}
+
+func TestNormalizeContext(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"@Deep-Work", "deep-work", true},
+		{"  @home ", "home", true},
+		{"", "", true},
+		{"@בית", "בית", true},
+		{"@日本", "日本", true},
+		{"@two words", "", false},
+		{"@🚀", "", false},
+		{"@" + strings.Repeat("a", MaxContextLength+1), "", false},
+	}
+	for _, tc := range cases {
+		got, err := NormalizeContext(tc.in)
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Errorf("NormalizeContext(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+		if !tc.ok && !errors.Is(err, ErrInvalid) {
+			t.Errorf("NormalizeContext(%q) = %q, %v; want ErrInvalid", tc.in, got, err)
+		}
+	}
+}
+
+func TestTaskNormalize(t *testing.T) {
+	task := Task{
+		Name:        "  cafe\u0301 🚀 ",
+		Description: "line\r\nbreak\x00",
+	}
+	if err := task.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if task.Name != "caf\u00e9 🚀" || task.Description != "line\nbreak" {
+		t.Errorf("got %q and %q", task.Name, task.Description)
+	}
+
+	task.Name = strings.Repeat("x", MaxNameLength+1)
+	if err := task.Normalize(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("a name over the limit was kept: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Package fixtures describes boards of sample data: the onboarding board new
+// users can add, and larger scenarios for development, tests, and benchmarks.
+// A fixture is plain data; the store decides how to insert it.
+package fixtures
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Board is a complete set of categories to load into an empty store
+type Board struct {
+	Categories []Category
+}
+
+type Category struct {
+	Name        string
+	Description string
+	Tasks       []Task
+}
+
+// Task completion is ignored when the task has subtasks, as it is rolled up
+// from theirs.
+type Task struct {
+	Name        string
+	Description string
+	Completion  int
+	Subtasks    []Subtask
+	WorkLogs    []WorkLog
+}
+
+type Subtask struct {
+	Name       string
+	Completion int
+	WorkLogs   []WorkLog
+}
+
+type WorkLog struct {
+	Hours       float64
+	Description string
+	Completion  int
+	Author      string
+	At          time.Time
+}
+
+// Scenarios are the boards that can be loaded by name, smallest first
+var Scenarios = []string{"small", "large", "demo"}
+
+// Scenario builds the named board with its history ending at now. The same
+// name and now always give the same board.
+func Scenario(name string, now time.Time) (*Board, error) {
+	switch name {
+	case "small":
+		return Small(now), nil
+	case "large":
+		return Large(now), nil
+	case "demo":
+		return Demo(now), nil
+	}
+	return nil, fmt.Errorf("unknown scenario %q, expected one of %v", name, Scenarios)
+}
+
+// IsScenario reports whether name is one of Scenarios
+func IsScenario(name string) bool {
+	return slices.Contains(Scenarios, name)
+}
+
+// Onboarding is the sample board offered to new users. It is meant to show
+// off sliders, subtask roll-ups, and work logs rather than to be realistic.
+func Onboarding(now time.Time) *Board {
+	yesterday := now.Add(-24 * time.Hour)
+	return &Board{Categories: []Category{
+		{
+			Name:        "Getting Started",
+			Description: "A few examples of how Compass tracks progress. Delete this category whenever you like.",
+			Tasks: []Task{
+				{
+					Name:        "Drag a slider",
+					Description: "Tasks are sliders, not checkboxes. 100% means done, in whatever way makes sense to you.",
+					Completion:  40,
+					WorkLogs: []WorkLog{
+						{Hours: 0.5, Description: "Moved the slider a little to see how it feels", Completion: 40, At: yesterday},
+					},
+				},
+				{
+					Name:        "Break a task into subtasks",
+					Description: "When a task has subtasks, its progress comes from theirs.",
+					Subtasks: []Subtask{
+						{Name: "Open the task details", Completion: 100},
+						{Name: "Add a subtask", Completion: 50},
+						{Name: "Log some work", Completion: 0},
+					},
+				},
+				{
+					Name:        "Reorder things",
+					Description: "Drag the handle that appears on hover to reorder categories, tasks, and subtasks.",
+				},
+			},
+		},
+		{
+			Name:        "Home",
+			Description: "Everything around the house.",
+			Tasks: []Task{
+				{
+					Name: "Paint the spare room",
+					Subtasks: []Subtask{
+						{Name: "Pick a colour", Completion: 100},
+						{Name: "Prep the walls", Completion: 30},
+						{Name: "Two coats", Completion: 0},
+					},
+				},
+				{
+					Name:        "Fix the garden gate",
+					Description: "The latch sticks in wet weather.",
+					Completion:  80,
+					WorkLogs: []WorkLog{
+						{Hours: 1.5, Description: "Replaced the hinge screws; latch still needs oil", Completion: 80, At: yesterday},
+					},
+				},
+			},
+		},
+	}}
+}
@@ -0,0 +1,208 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Generated boards draw from a fixed seed so that they are identical on
+// every run.
+const seed = 4194
+
+// shape controls the size of a generated board
+type shape struct {
+	categories int
+	tasks      int // per category
+	subtasks   int // at most, per task; about a third of tasks have none
+	days       int // how far back work logs go
+	authors    []string
+}
+
+// Small is a board big enough to click around: three categories, a dozen
+// tasks, and two weeks of work logs by one person.
+func Small(now time.Time) *Board {
+	return generate(now, shape{categories: 3, tasks: 4, subtasks: 3, days: 14, authors: []string{"alice"}})
+}
+
+// Large is a board for exercising performance: hundreds of tasks and half a
+// year of work logs by a small team.
+func Large(now time.Time) *Board {
+	return generate(now, shape{categories: 20, tasks: 25, subtasks: 6, days: 180, authors: []string{"alice", "bob", "carol", "dave"}})
+}
+
+// Demo is a realistic board for showing the app to people: a few projects
+// that read like real work, with three months of history from a team of
+// three.
+func Demo(now time.Time) *Board {
+	r := rand.New(rand.NewPCG(seed, seed))
+	authors := []string{"alice", "bob", "carol"}
+
+	board := &Board{Categories: []Category{
+		{
+			Name:        "Website relaunch",
+			Description: "New marketing site, launching before the spring conference.",
+			Tasks: []Task{
+				{Name: "Content audit", Description: "Decide what moves over, what gets rewritten, and what is retired.", Completion: 100},
+				{Name: "Design system", Subtasks: []Subtask{
+					{Name: "Type scale", Completion: 100},
+					{Name: "Colour tokens", Completion: 100},
+					{Name: "Component library", Completion: 60},
+				}},
+				{Name: "Page templates", Subtasks: []Subtask{
+					{Name: "Home", Completion: 80},
+					{Name: "Pricing", Completion: 40},
+					{Name: "Blog index and post", Completion: 20},
+				}},
+				{Name: "Redirects from old URLs", Completion: 10},
+				{Name: "Launch checklist", Description: "Analytics, sitemap, social cards, uptime checks."},
+			},
+		},
+		{
+			Name:        "Mobile app 2.0",
+			Description: "Offline support and the new onboarding flow.",
+			Tasks: []Task{
+				{Name: "Offline storage", Subtasks: []Subtask{
+					{Name: "Pick a sync strategy", Completion: 100},
+					{Name: "Local database schema", Completion: 70},
+					{Name: "Conflict resolution", Completion: 15},
+				}},
+				{Name: "Onboarding flow", Completion: 55, Description: "Three screens, skippable, with the permission prompts last."},
+				{Name: "Crash reporting", Completion: 90},
+				{Name: "Beta with twenty customers", Completion: 0},
+			},
+		},
+		{
+			Name:        "Operations",
+			Description: "Keeping the lights on.",
+			Tasks: []Task{
+				{Name: "Move backups off-site", Completion: 100},
+				{Name: "Upgrade the database", Subtasks: []Subtask{
+					{Name: "Test restore on staging", Completion: 100},
+					{Name: "Schedule the maintenance window", Completion: 100},
+					{Name: "Run the upgrade", Completion: 0},
+				}},
+				{Name: "Quarterly access review", Completion: 35},
+			},
+		},
+		{
+			Name:        "Hiring",
+			Description: "Two engineers and a designer this quarter.",
+			Tasks: []Task{
+				{Name: "Write job descriptions", Completion: 100},
+				{Name: "Backend engineer", Completion: 60, Description: "Final round with two candidates."},
+				{Name: "Product designer", Completion: 25},
+				{Name: "Onboarding plan for new starters", Completion: 5},
+			},
+		},
+	}}
+
+	for c := range board.Categories {
+		for t := range board.Categories[c].Tasks {
+			task := &board.Categories[c].Tasks[t]
+			for s := range task.Subtasks {
+				sub := &task.Subtasks[s]
+				sub.WorkLogs = history(r, now, 90, sub.Completion, authors)
+			}
+			if len(task.Subtasks) == 0 {
+				task.WorkLogs = history(r, now, 90, task.Completion, authors)
+			}
+		}
+	}
+	return board
+}
+
+func generate(now time.Time, sh shape) *Board {
+	r := rand.New(rand.NewPCG(seed, seed))
+	board := &Board{}
+	for range sh.categories {
+		cat := Category{
+			Name:        fmt.Sprintf("%s %s", pick(r, adjectives), pick(r, projects)),
+			Description: pick(r, descriptions),
+		}
+		for range sh.tasks {
+			task := Task{Name: fmt.Sprintf("%s the %s", pick(r, verbs), pick(r, things))}
+			if n := r.IntN(sh.subtasks + 1); r.IntN(3) > 0 && n > 0 {
+				for range n {
+					sub := Subtask{
+						Name:       fmt.Sprintf("%s the %s", pick(r, verbs), pick(r, things)),
+						Completion: completion(r),
+					}
+					sub.WorkLogs = history(r, now, sh.days, sub.Completion, sh.authors)
+					task.Subtasks = append(task.Subtasks, sub)
+				}
+			} else {
+				task.Completion = completion(r)
+				task.WorkLogs = history(r, now, sh.days, task.Completion, sh.authors)
+			}
+			cat.Tasks = append(cat.Tasks, task)
+		}
+		board.Categories = append(board.Categories, cat)
+	}
+	return board
+}
+
+// completion favours the ends of the range, as real boards do: most work
+// is either not started or finished.
+func completion(r *rand.Rand) int {
+	switch r.IntN(4) {
+	case 0:
+		return 0
+	case 1:
+		return 100
+	}
+	return 5 * (1 + r.IntN(19))
+}
+
+// history makes work logs spread over the last days, in order, whose
+// estimates climb to the final completion. Unstarted work has no history.
+func history(r *rand.Rand, now time.Time, days, final int, authors []string) []WorkLog {
+	if final == 0 {
+		return nil
+	}
+	n := 1 + r.IntN(1+final/15)
+	start := now.AddDate(0, 0, -days).Truncate(24 * time.Hour)
+
+	var logs []WorkLog
+	offsets := make([]int, n)
+	for i := range offsets {
+		offsets[i] = r.IntN(days)
+	}
+	slices.Sort(offsets)
+	for i, day := range offsets {
+		at := start.AddDate(0, 0, day).Add(time.Duration(9+r.IntN(9))*time.Hour + time.Duration(r.IntN(60))*time.Minute)
+		logs = append(logs, WorkLog{
+			Hours:       float64(1+r.IntN(16)) / 4,
+			Description: pick(r, notes),
+			Completion:  final * (i + 1) / n,
+			Author:      authors[r.IntN(len(authors))],
+			At:          at,
+		})
+	}
+	return logs
+}
+
+func pick(r *rand.Rand, words []string) string {
+	return words[r.IntN(len(words))]
+}
+
+var (
+	adjectives   = []string{"Quarterly", "Internal", "Customer", "Platform", "Spring", "Legacy", "Shared", "Regional", "Mobile", "Billing"}
+	projects     = []string{"Roadmap", "Migration", "Launch", "Cleanup", "Research", "Redesign", "Rollout", "Audit", "Integration", "Tooling"}
+	descriptions = []string{"", "", "Owned by the core team.", "Carried over from last quarter.", "Needs a decision on scope.", "Waiting on the vendor for parts of this."}
+	verbs        = []string{"Draft", "Review", "Ship", "Refactor", "Test", "Document", "Prototype", "Migrate", "Measure", "Plan", "Fix", "Publish"}
+	things       = []string{"API", "dashboard", "onboarding", "invoices", "search", "importer", "settings page", "release notes", "schema", "permissions", "reports", "pipeline", "style guide", "backlog"}
+	notes        = []string{
+		"Worked through the first half",
+		"Paired on the tricky part",
+		"Addressed review comments",
+		"Blocked for a while on access, then sorted it",
+		"Wrote it up and shared for feedback",
+		"Small fixes",
+		"Investigated the failing case",
+		"Cleaned up after the last change",
+		"Met with stakeholders",
+		"Got most of the way there",
+	}
+)
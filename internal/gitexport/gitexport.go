@@ -0,0 +1,209 @@
+// Package gitexport keeps a git repository of the board as markdown files,
+// laid out as boardfile lays them out. Each export that finds the board
+// changed makes one commit, credited to whoever made the changes, so the
+// repository is a readable history of the board and a copy of it that
+// needs nothing but git to read.
+package gitexport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/boardfile"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// committer is who commits; the authors are the board's users
+const (
+	committerName  = "compass"
+	committerEmail = "compass@localhost"
+)
+
+// Exporter writes the board into a git working tree and commits it
+type Exporter struct {
+	dir   string
+	git   string
+	store domain.Store
+	clock domain.Clock
+}
+
+// New prepares dir as the mirror, creating it and a git repository in it if
+// they are missing. The git command must be installed.
+func New(dir string, store domain.Store, clock domain.Clock) (*Exporter, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errors.New("the git mirror needs git installed")
+	}
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	e := &Exporter{dir: dir, git: git, store: store, clock: clock}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if _, err := e.run(context.Background(), nil, "init", "--quiet"); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Export writes the board as it is now and commits it if anything changed.
+// Work logs are dated in UTC, since the mirror is not any one user's.
+func (e *Exporter) Export(ctx context.Context) error {
+	categories, err := e.store.GetCategories()
+	if err != nil {
+		return err
+	}
+	lastWorked, err := e.store.GetLastWorked()
+	if err != nil {
+		return err
+	}
+	root := boardfile.Tree(categories, lastWorked)
+	for i, folder := range root.Children {
+		c := categories[i]
+		if c.WorkLogs, err = e.store.GetWorkLogsForCategory(c.ID); err != nil {
+			return err
+		}
+		folder.Children = boardfile.CategoryFiles(c, lastWorked, time.UTC)
+	}
+	if err := e.write(root); err != nil {
+		return err
+	}
+
+	if _, err := e.run(ctx, nil, "add", "--all"); err != nil {
+		return err
+	}
+	changed, err := e.run(ctx, nil, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// Credit whoever changed the board since the last commit
+	since := time.Time{}
+	if out, err := e.run(ctx, nil, "log", "-1", "--format=%ct"); err == nil {
+		if ct, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			since = time.Unix(ct, 0)
+		}
+	}
+	authors, err := e.store.GetChangeAuthors(since)
+	if err != nil {
+		return err
+	}
+	author := committerName
+	if len(authors) > 0 {
+		author = authors[0]
+	}
+	message := commitMessage(strings.Split(strings.TrimRight(string(changed), "\x00"), "\x00"), authors)
+
+	now := strconv.FormatInt(e.clock.Now().Unix(), 10) + " +0000"
+	_, err = e.run(ctx, []string{
+		"GIT_AUTHOR_NAME=" + author,
+		"GIT_AUTHOR_EMAIL=" + email(author),
+		"GIT_AUTHOR_DATE=" + now,
+		"GIT_COMMITTER_NAME=" + committerName,
+		"GIT_COMMITTER_EMAIL=" + committerEmail,
+		"GIT_COMMITTER_DATE=" + now,
+	}, "commit", "--quiet", "--no-verify", "--message", message)
+	return err
+}
+
+// write replaces the working tree with root's folders, leaving .git and
+// anything else hidden alone
+func (e *Exporter) write(root *boardfile.File) error {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(e.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	for _, folder := range root.Children {
+		path := filepath.Join(e.dir, folder.Name)
+		if err := os.Mkdir(path, 0o755); err != nil {
+			return err
+		}
+		for _, f := range folder.Children {
+			if err := os.WriteFile(filepath.Join(path, f.Name), f.Content, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run runs git in the mirror with env added to compass's own
+func (e *Exporter) run(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, e.git, args...)
+	cmd.Dir = e.dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// commitMessage names the categories that changed, and credits any other
+// authors with Co-authored-by trailers
+func commitMessage(paths []string, authors []string) string {
+	seen := map[string]bool{}
+	var folders []string
+	for _, p := range paths {
+		folder, _, _ := strings.Cut(p, "/")
+		if !seen[folder] {
+			seen[folder] = true
+			folders = append(folders, folder)
+		}
+	}
+	sort.Strings(folders)
+
+	var b strings.Builder
+	switch len(folders) {
+	case 1:
+		fmt.Fprintf(&b, "Update %s\n", folders[0])
+	case 2:
+		fmt.Fprintf(&b, "Update %s and %s\n", folders[0], folders[1])
+	default:
+		fmt.Fprintf(&b, "Update %d categories\n\n", len(folders))
+		for _, f := range folders {
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+	}
+	if len(authors) > 1 {
+		b.WriteString("\n")
+		for _, a := range authors[1:] {
+			fmt.Fprintf(&b, "Co-authored-by: %s <%s>\n", a, email(a))
+		}
+	}
+	return b.String()
+}
+
+// email is the address git records for a user. Users are known by ID, which
+// is an address for some sign-in providers; the rest get a placeholder.
+func email(userID string) string {
+	if strings.Contains(userID, "@") {
+		return userID
+	}
+	return userID + "@" + committerName + ".invalid"
+}
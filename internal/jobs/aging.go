@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// agingActor is who aging rules act as in task history and the audit log
+const agingActor = "aging rules"
+
+// ApplyAgingRules flags tasks, or raises their priority, once they go longer
+// than their category's aging rules allow without work logged. Each rule
+// acts on a task once per stretch without work.
+func ApplyAgingRules(store domain.Store, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "apply aging rules",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			due, err := store.GetAgingDue(clock.Now())
+			if err != nil {
+				return err
+			}
+			for _, d := range due {
+				task, err := store.GetTask(d.TaskID)
+				if errors.Is(err, domain.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				changed := *task
+				var summary string
+				switch d.Rule.Action {
+				case domain.AgingFlag:
+					changed.Flag = fmt.Sprintf("No work logged in %d days", d.Rule.Days)
+					summary = "flagged: " + changed.Flag
+				case domain.AgingEscalate:
+					changed.Priority = min(task.Priority+1, domain.PriorityUrgent)
+					summary = fmt.Sprintf("priority %s after %d days without work", domain.PriorityNames[changed.Priority], d.Rule.Days)
+				}
+				if changed.Flag != task.Flag || changed.Priority != task.Priority {
+					if _, err := store.UpdateTask(&changed, agingActor); err != nil {
+						return err
+					}
+				}
+				if err := store.RecordAging(d.Rule.ID, task.ID, agingActor, summary); err != nil {
+					return err
+				}
+			}
+			if len(due) > 0 {
+				log.Printf("job apply aging rules: acted on %d tasks", len(due))
+			}
+			return nil
+		},
+	}
+}
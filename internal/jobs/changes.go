@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// changeRetention is how long the board's change log is kept. A client away
+// for longer reloads everything instead of catching up.
+const changeRetention = 7 * 24 * time.Hour
+
+// PruneBoardChanges forgets changes old enough that no client still
+// catching up should need them.
+func PruneBoardChanges(store domain.Store, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "prune board changes",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			n, err := store.PruneBoardChanges(clock.Now().Add(-changeRetention))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("job prune board changes: removed %d entries", n)
+			}
+			return nil
+		},
+	}
+}
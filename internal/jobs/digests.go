@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// digestActor is who weekly digests are written by in category history and
+// the audit log
+const digestActor = "weekly digest"
+
+// digestMaxTasks is how many finished tasks a digest names before it only
+// counts the rest
+const digestMaxTasks = 20
+
+// WriteWeeklyDigests appends last week's finished tasks and logged hours to
+// the description of each category with weekly digests on, once the week
+// is over, building a running journal of the work. Quiet weeks are skipped.
+func WriteWeeklyDigests(store domain.Store, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "write weekly digests",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			end := domain.WeekStart(clock.Now())
+			start := end.AddDate(0, 0, -7)
+			due, err := store.GetWeeklyDigestsDue(start)
+			if err != nil {
+				return err
+			}
+			written := 0
+			for _, id := range due {
+				week, err := store.GetCategoryWeek(id, start, end)
+				if err != nil {
+					return err
+				}
+				entry := weeklyDigest(week)
+				cat, err := store.GetCategory(id)
+				if errors.Is(err, domain.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				// A description with no room left misses the week rather
+				// than holding up every later one
+				if entry != "" && utf8.RuneCountInString(cat.Description)+2+utf8.RuneCountInString(entry) > domain.MaxDescriptionLength {
+					log.Printf("job write weekly digests: no room left in %q for the week of %s", cat.Name, start.Format(time.DateOnly))
+					entry = ""
+				}
+				if err := store.AppendWeeklyDigest(id, start, entry, digestActor); err != nil {
+					return err
+				}
+				if entry != "" {
+					written++
+				}
+			}
+			if written > 0 {
+				log.Printf("job write weekly digests: wrote %d digests", written)
+			}
+			return nil
+		},
+	}
+}
+
+// weeklyDigest writes a week up as a description entry, with the tasks
+// finished as references that link to them, or nothing for a quiet week
+func weeklyDigest(week *domain.CategoryWeek) string {
+	if len(week.Completed) == 0 && week.Hours == 0 {
+		return ""
+	}
+
+	var parts []string
+	if n := len(week.Completed); n > 0 {
+		refs := make([]string, 0, min(n, digestMaxTasks))
+		for _, t := range week.Completed[:min(n, digestMaxTasks)] {
+			refs = append(refs, fmt.Sprintf("[[task:%s]]", t.ID))
+		}
+		finished := "finished " + strings.Join(refs, ", ")
+		if n > digestMaxTasks {
+			finished += fmt.Sprintf(" and %d more", n-digestMaxTasks)
+		}
+		parts = append(parts, finished)
+	}
+	if week.Hours != 0 {
+		unit := "hours"
+		if week.Hours == 1 {
+			unit = "hour"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s logged", strconv.FormatFloat(math.Round(week.Hours*100)/100, 'f', -1, 64), unit))
+	}
+	return fmt.Sprintf("Week of %s: %s", week.Start.Format(time.DateOnly), strings.Join(parts, "; "))
+}
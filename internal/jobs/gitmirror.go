@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/gitexport"
+)
+
+// MirrorToGit writes the board to its git mirror every five minutes,
+// committing whatever changed in that time as one batch
+func MirrorToGit(exporter *gitexport.Exporter) Job {
+	return Job{
+		Name:     "git mirror",
+		Interval: 5 * time.Minute,
+		Run:      exporter.Export,
+	}
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/preview"
+)
+
+// linkPreviewMaxAge is how long a cached link preview is trusted
+const linkPreviewMaxAge = 7 * 24 * time.Hour
+
+// RefreshLinkPreviews fetches previews for links that have none yet and
+// refetches those older than a week, a batch at a time.
+func RefreshLinkPreviews(store domain.Store, fetcher *preview.Fetcher, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "refresh link previews",
+		Interval: 15 * time.Minute,
+		Run: func(ctx context.Context) error {
+			urls, err := store.GetStaleLinkURLs(clock.Now().Add(-linkPreviewMaxAge), 50)
+			if err != nil {
+				return err
+			}
+			for _, u := range urls {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err := preview.Refresh(ctx, store, fetcher, clock, u); err != nil {
+					log.Printf("job refresh link previews: %v", err)
+				}
+			}
+			return nil
+		},
+	}
+}
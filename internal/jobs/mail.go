@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/mail"
+)
+
+// SendMail works through the outgoing mail queue, retrying what the mail
+// server turned away before
+func SendMail(mailer *mail.Mailer) Job {
+	return Job{
+		Name:     "send mail",
+		Interval: time.Minute,
+		Run:      mailer.SendQueued,
+	}
+}
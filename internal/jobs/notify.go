@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+)
+
+// SendDigests delivers daily notification digests. It runs often enough
+// that each user's digest goes out within a quarter hour of their chosen
+// time.
+func SendDigests(dispatcher *notify.Dispatcher, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "send notification digests",
+		Interval: 15 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return dispatcher.SendDigests(ctx, clock.Now())
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+)
+
+// SendNudges sends the task reminders that have come due and schedules
+// their next occurrence. A nudge on a finished task is removed instead.
+func SendNudges(store domain.Store, dispatcher *notify.Dispatcher, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "send nudges",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			now := clock.Now()
+			due, err := store.GetDueNudges(now)
+			if err != nil {
+				return err
+			}
+			for _, n := range due {
+				task, err := store.GetTask(n.TaskID)
+				if errors.Is(err, domain.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if task.Completion >= 100 {
+					if err := store.DeleteNudge(n.ID); err != nil {
+						return err
+					}
+					continue
+				}
+
+				dispatcher.Notify(ctx, []string{n.UserID}, notify.Event{
+					Kind:  notify.KindReminder,
+					Title: "Reminder: " + task.Name,
+					Body:  fmt.Sprintf("%d%% complete", task.Completion),
+					URL:   "/tasks/" + task.ID + "/details",
+				})
+
+				prefs, err := store.GetPreferences(n.UserID)
+				if err != nil {
+					return err
+				}
+				if err := store.AdvanceNudge(n.ID, n.Next(now, prefs.Location())); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,57 @@
+// Package jobs runs periodic background work against the store.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic work. Run is called once when the runner starts
+// and then every Interval; it should be idempotent, since a restart runs it
+// again early.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Runner runs jobs on their intervals until its context is cancelled
+type Runner struct {
+	jobs []Job
+}
+
+func NewRunner(jobs ...Job) *Runner {
+	return &Runner{jobs: jobs}
+}
+
+// Run blocks until ctx is cancelled and every job has returned. Failures are
+// logged and retried at the next interval.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range r.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runEvery(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+func runEvery(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil {
+			log.Printf("job %s: %v", job.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// DailySnapshot saves a copy of the whole board once a day. It checks
+// hourly and keeps the last state of each day, so a snapshot reflects the
+// end of the day even if the server was down at midnight; unchanged boards
+// are not stored again.
+func DailySnapshot(store domain.Store, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "daily snapshot",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			board, err := boardJSON(store)
+			if err != nil {
+				return err
+			}
+			saved, err := store.SaveSnapshot(clock.Now(), board)
+			if err != nil {
+				return err
+			}
+			if saved {
+				log.Printf("job daily snapshot: saved %d bytes", len(board))
+			}
+			return nil
+		},
+	}
+}
+
+// boardJSON serializes every category, including private ones, with its
+// tasks, subtasks, and work logs.
+func boardJSON(store domain.Store) ([]byte, error) {
+	cats, err := store.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	if cats == nil {
+		cats = []*domain.Category{}
+	}
+	for _, c := range cats {
+		if c.WorkLogs, err = store.GetWorkLogsForCategory(c.ID); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(cats)
+}
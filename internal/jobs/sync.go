@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/mirror"
+)
+
+// syncEvery is how often boards are pushed to and pulled from the peers
+// this instance connects to
+const syncEvery = 5 * time.Minute
+
+// SyncBoards records what changed on every shared board each minute, so a
+// change is timed close to when it was made when it meets a conflicting one,
+// and syncs with each peer that has a URL every five minutes. A failed sync
+// is recorded on the peer and tried again next time.
+func SyncBoards(store domain.Store, client *mirror.Client, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "sync boards",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			peers, err := store.GetAllSyncPeers()
+			if err != nil {
+				return err
+			}
+			recorded := map[string]bool{}
+			for _, p := range peers {
+				if recorded[p.CategoryID] {
+					continue
+				}
+				recorded[p.CategoryID] = true
+				if err := store.RecordSyncChanges(p.CategoryID); err != nil {
+					return err
+				}
+			}
+			for _, p := range peers {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if p.URL == "" || clock.Now().Sub(p.SyncedAt) < syncEvery {
+					continue
+				}
+				if err := client.Sync(ctx, p); err != nil {
+					log.Printf("job sync boards: %s: %v", p.Name, err)
+				}
+			}
+			return nil
+		},
+	}
+}
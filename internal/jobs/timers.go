@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// PauseIdleTimers pauses the timers of users whose browsers have stopped
+// sending heartbeats, such as when a laptop is closed with a timer running,
+// so the time away is not logged as work.
+func PauseIdleTimers(store domain.Store, clock domain.Clock) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "pause idle timers",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			n, err := store.PauseIdleTimers(clock.Now())
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("job pause idle timers: paused %d", n)
+			}
+			return nil
+		},
+	}
+}
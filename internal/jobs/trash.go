@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// PurgeTrash permanently removes deleted entities once they have been in
+// the trash longer than retention.
+func PurgeTrash(store domain.Store, clock domain.Clock, retention time.Duration) Job {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	return Job{
+		Name:     "purge trash",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			n, err := store.PurgeTrash(clock.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("job purge trash: removed %d entries", n)
+			}
+			return nil
+		},
+	}
+}
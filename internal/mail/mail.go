@@ -0,0 +1,283 @@
+// Package mail sends email through an SMTP server. Messages are rendered
+// from templates, as plain text with an HTML alternative, and queued in the
+// store so a mail server that is down or refusing only delays them.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+const (
+	dialTimeout = 30 * time.Second
+	batchSize   = 50
+	// maxAttempts gives up on a message after about a day of
+	// retries, backing off from a minute to six hours between them
+	maxAttempts = 12
+	maxBackoff  = 6 * time.Hour
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+// Config says how to reach the SMTP server. Port 465 is spoken to over
+// TLS from the start; any other port is upgraded with STARTTLS when the
+// server offers it, which it must before a password is sent.
+type Config struct {
+	Host     string
+	Port     int // defaults to 587
+	Username string
+	Password string
+	// From is the sender, e.g. "Compass <compass@example.com>"
+	From string
+	// LinkBase is the address compass is reached at, e.g.
+	// https://compass.example.com, for links in messages
+	LinkBase string
+}
+
+// Message is what the templates fill in: "notification" for events on the
+// board, and "account" for changes to a user's account they should know
+// about. Link and Settings are full URLs.
+type Message struct {
+	Title    string
+	Body     string
+	Link     string // optional
+	Settings string
+}
+
+// Mailer renders and sends email
+type Mailer struct {
+	cfg   Config
+	from  *mail.Address
+	store domain.Store
+	clock domain.Clock
+	text  *texttemplate.Template
+	html  *htmltemplate.Template
+}
+
+// New creates a Mailer queueing in store. Nothing is sent until the queue
+// is worked through with SendQueued.
+func New(cfg Config, store domain.Store, clock domain.Clock) (*Mailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("an SMTP host is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	cfg.LinkBase = strings.TrimSuffix(cfg.LinkBase, "/")
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", cfg.From, err)
+	}
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	text, err := texttemplate.ParseFS(templateFiles, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.ParseFS(templateFiles, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	return &Mailer{cfg: cfg, from: from, store: store, clock: clock, text: text, html: html}, nil
+}
+
+// Link makes a path in the app into a URL a message can link to
+func (m *Mailer) Link(path string) string {
+	return m.cfg.LinkBase + path
+}
+
+// Queue renders the named template for data and queues it for to
+func (m *Mailer) Queue(to, name string, data any) error {
+	msg, err := m.render(to, name, data)
+	if err != nil {
+		return err
+	}
+	return m.store.QueueMail(msg)
+}
+
+// Send renders the named template for data and sends it to to straight
+// away, for messages whose sender is waiting to hear whether it worked
+func (m *Mailer) Send(ctx context.Context, to, name string, data any) error {
+	msg, err := m.render(to, name, data)
+	if err != nil {
+		return err
+	}
+	return m.deliver(ctx, msg)
+}
+
+// SendQueued sends the messages that are due. A message that fails is
+// retried later, and dropped once it has failed too many times.
+func (m *Mailer) SendQueued(ctx context.Context) error {
+	queued, err := m.store.GetDueMail(m.clock.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+	for _, msg := range queued {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := m.deliver(ctx, msg)
+		if err == nil {
+			if err := m.store.DeleteMail(msg.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Attempts+1 >= maxAttempts {
+			log.Printf("mail to %s: giving up after %d attempts: %v", msg.To, msg.Attempts+1, err)
+			if err := m.store.DeleteMail(msg.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		backoff := min(time.Minute<<msg.Attempts, maxBackoff)
+		if err := m.store.RetryMail(msg.ID, m.clock.Now().Add(backoff), err.Error()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render fills in the named template's subject, text, and html parts
+func (m *Mailer) render(to, name string, data any) (*domain.Mail, error) {
+	var subject, text, html bytes.Buffer
+	if err := m.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, err
+	}
+	if err := m.text.ExecuteTemplate(&text, name+".text", data); err != nil {
+		return nil, err
+	}
+	if err := m.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, err
+	}
+	return &domain.Mail{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// compose writes msg as a MIME message with text and HTML alternatives
+func (m *Mailer) compose(msg *domain.Mail) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	_, domainPart, _ := strings.Cut(m.from.Address, "@")
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", m.clock.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domainPart)
+	fmt.Fprintf(&out, "Auto-Submitted: auto-generated\r\n")
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// deliver hands msg to the SMTP server
+func (m *Mailer) deliver(ctx context.Context, msg *domain.Mail) error {
+	data, err := m.compose(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if m.cfg.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && m.cfg.Port != 465 {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		// PlainAuth refuses to send the password unencrypted
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
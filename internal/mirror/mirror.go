@@ -0,0 +1,209 @@
+// Package mirror keeps a board in step with the same board on another
+// compass instance. Each instance keeps a change log of the board; a sync
+// pushes ours to the peer and pulls theirs, and every request and response
+// is signed with a secret both sides were given.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/safehttp"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>"
+	SignatureHeader = "X-Compass-Signature"
+	// MaxSkew is how far a signature's time may be from the receiver's
+	// clock, which keeps captured requests from being replayed later
+	MaxSkew = 5 * time.Minute
+	// BatchSize is the most changes sent in one request or response
+	BatchSize = 500
+	// MaxBodyBytes bounds one batch on the wire
+	MaxBodyBytes = 8 << 20
+
+	requestTimeout = 30 * time.Second
+)
+
+// ErrBadSignature is returned for a request or response that was not
+// signed with the peer's secret
+var ErrBadSignature = errors.New("missing or invalid signature")
+
+// Batch is a run of changes from a board's log, oldest first
+type Batch struct {
+	Changes []*domain.SyncChange `json:"changes"`
+}
+
+// Path is where an instance serves a board's change log
+func Path(boardID string) string {
+	return "/sync/" + url.PathEscape(boardID) + "/changes"
+}
+
+// Sign signs a request to target (its path and query), or the response to
+// one, made at t
+func Sign(secret string, t time.Time, method, target string, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, method, target, body)
+}
+
+// Verify checks a signature made by Sign less than MaxSkew from now
+func Verify(secret, signature string, now time.Time, method, target string, body []byte) error {
+	var ts, sig string
+	for _, part := range strings.Split(signature, ",") {
+		switch k, v, _ := strings.Cut(part, "="); k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > MaxSkew || skew < -MaxSkew {
+		return fmt.Errorf("%w: the clocks differ by %s", ErrBadSignature, skew.Round(time.Second))
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, method, target, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func mac(secret, ts, method, target string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s\n%s\n%s\n", ts, method, target)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Client syncs boards with the peers they are shared with
+type Client struct {
+	store domain.Store
+	clock domain.Clock
+	http  *http.Client
+}
+
+// NewClient creates a Client. Unless allowPrivate is set it only connects
+// to public addresses, as with any other URL a user types in.
+func NewClient(store domain.Store, clock domain.Clock, allowPrivate bool) *Client {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	client := safehttp.NewClient(requestTimeout)
+	if allowPrivate {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Client{store: store, clock: clock, http: client}
+}
+
+// Sync brings the peer's copy of the board and ours level: it records what
+// changed here, pushes every change the peer has not had, then pulls and
+// applies theirs. How far it got, and any error, is recorded on the peer.
+func (c *Client) Sync(ctx context.Context, peer *domain.SyncPeer) (err error) {
+	if peer.URL == "" {
+		return fmt.Errorf("%w: %s connects to us, not the other way", domain.ErrInvalid, peer.Name)
+	}
+	pushed, pulled := peer.PushedSeq, peer.PulledSeq
+	defer func() {
+		var msg string
+		if err != nil {
+			msg = err.Error()
+		}
+		if recordErr := c.store.RecordSyncResult(peer.ID, pushed, pulled, msg); err == nil {
+			err = recordErr
+		}
+	}()
+
+	if err := c.store.RecordSyncChanges(peer.CategoryID); err != nil {
+		return err
+	}
+	for {
+		changes, err := c.store.GetSyncChanges(peer.CategoryID, pushed, BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			break
+		}
+		body, err := json.Marshal(Batch{Changes: changes})
+		if err != nil {
+			return err
+		}
+		if _, err := c.do(ctx, peer, http.MethodPost, Path(peer.CategoryID), body); err != nil {
+			return fmt.Errorf("pushing: %w", err)
+		}
+		pushed = changes[len(changes)-1].Seq
+		if len(changes) < BatchSize {
+			break
+		}
+	}
+
+	for {
+		target := Path(peer.CategoryID) + "?since=" + strconv.FormatInt(pulled, 10)
+		body, err := c.do(ctx, peer, http.MethodGet, target, nil)
+		if err != nil {
+			return fmt.Errorf("pulling: %w", err)
+		}
+		var batch Batch
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return fmt.Errorf("pulling: %w", err)
+		}
+		if len(batch.Changes) == 0 {
+			break
+		}
+		if _, err := c.store.ApplySyncChanges(peer.ID, batch.Changes); err != nil {
+			return err
+		}
+		pulled = batch.Changes[len(batch.Changes)-1].Seq
+		if len(batch.Changes) < BatchSize {
+			break
+		}
+	}
+	return nil
+}
+
+// do makes a signed request to the peer and returns the body of its signed
+// response
+func (c *Client) do(ctx context.Context, peer *domain.SyncPeer, method, target string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(peer.URL, "/")+target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(SignatureHeader, Sign(peer.Secret, c.clock.Now(), method, target, body))
+	req.Header.Set("User-Agent", "compass-sync/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := Verify(peer.Secret, resp.Header.Get(SignatureHeader), c.clock.Now(), method, target, data); err != nil {
+		return nil, fmt.Errorf("response: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/mail"
+)
+
+// Email sends notifications to the address each user has given in their
+// settings. Messages are queued, so a slow mail server never holds up
+// whatever caused them.
+type Email struct {
+	store  domain.Store
+	mailer *mail.Mailer
+}
+
+// NewEmail creates an email channel sending through mailer
+func NewEmail(store domain.Store, mailer *mail.Mailer) *Email {
+	return &Email{store: store, mailer: mailer}
+}
+
+func (m *Email) Name() string {
+	return "email"
+}
+
+func (m *Email) Subscribers() ([]string, error) {
+	return m.store.GetEmailUsers()
+}
+
+func (m *Email) Send(ctx context.Context, userID string, e Event) error {
+	prefs, err := m.store.GetPreferences(userID)
+	if err != nil || prefs.Email == "" {
+		return err
+	}
+	msg := mail.Message{
+		Title:    e.Title,
+		Body:     e.Body,
+		Settings: m.mailer.Link("/settings"),
+	}
+	if e.URL != "" {
+		msg.Link = m.mailer.Link(e.URL)
+	}
+	return m.mailer.Queue(prefs.Email, "notification", msg)
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// gotifyPriority shows notifications on Android without making a sound for
+// every one; Gotify's scale runs 0 to 10
+const gotifyPriority = 5
+
+// Gotify sends notifications to the Gotify server each user has set up
+// (https://gotify.net), as an application whose token they created there
+type Gotify struct {
+	store  domain.Store
+	client *http.Client
+}
+
+// NewGotify creates a Gotify channel. Unless allowPrivate is set it only
+// connects to servers on public addresses.
+func NewGotify(store domain.Store, allowPrivate bool) *Gotify {
+	return &Gotify{store: store, client: serviceClient(allowPrivate)}
+}
+
+func (g *Gotify) Name() string {
+	return domain.ServiceGotify
+}
+
+func (g *Gotify) Subscribers() ([]string, error) {
+	return g.store.GetNotifyServiceUsers(domain.ServiceGotify)
+}
+
+// Send posts e to userID's Gotify server, if they have one
+func (g *Gotify) Send(ctx context.Context, userID string, e Event) error {
+	svc, err := g.store.GetNotifyService(userID, domain.ServiceGotify)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	msg := map[string]any{
+		"title":    e.Title,
+		"message":  message(e),
+		"priority": gotifyPriority,
+	}
+	if url := link(svc, e); url != "" {
+		msg["extras"] = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": url}},
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(svc.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", svc.Token)
+	return post(g.client, req)
+}
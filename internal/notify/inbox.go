@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Inbox keeps notifications in the app, so they are seen even by users with
+// every other channel turned off
+type Inbox struct {
+	store domain.Store
+}
+
+// NewInbox creates an Inbox stored in store
+func NewInbox(store domain.Store) *Inbox {
+	return &Inbox{store: store}
+}
+
+func (i *Inbox) Name() string {
+	return "inbox"
+}
+
+func (i *Inbox) Subscribers() ([]string, error) {
+	return i.store.GetUsers()
+}
+
+func (i *Inbox) Send(ctx context.Context, userID string, e Event) error {
+	return i.store.AddNotification(&domain.Notification{
+		UserID: userID,
+		Kind:   e.Kind,
+		Title:  e.Title,
+		Body:   e.Body,
+		URL:    e.URL,
+	})
+}
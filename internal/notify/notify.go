@@ -0,0 +1,208 @@
+// Package notify tells users about things that happened on the board,
+// through whichever channels are configured.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Event kinds
+const (
+	KindReminder   = "reminder"   // a reminder the user set comes due
+	KindAssignment = "assignment" // the user was given something to do
+	KindActivity   = "activity"   // someone else changed the shared board
+	KindDigest     = "digest"     // a summary of events held back for the day
+)
+
+// Kinds lists the event kinds users can choose how to receive
+var Kinds = []string{KindActivity, KindAssignment, KindReminder}
+
+// digestPreview is how many events a digest lists before summarizing
+const digestPreview = 5
+
+// Event is one notification. Actor is never notified of their own actions.
+type Event struct {
+	Kind  string `json:"kind"`
+	Actor string `json:"-"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"` // path to open when the notification is clicked
+}
+
+// Channel delivers notifications to users
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, userID string, e Event) error
+	// Subscribers lists the users this channel can reach
+	Subscribers() ([]string, error)
+}
+
+// Dispatcher fans events out to channels, following each recipient's
+// notification preferences. A nil Dispatcher drops everything, so callers
+// need not check whether notifications are enabled.
+type Dispatcher struct {
+	store    domain.Store
+	channels []Channel
+}
+
+// NewDispatcher creates a Dispatcher sending through channels, reading
+// preferences from and queueing digests in store
+func NewDispatcher(store domain.Store, channels ...Channel) *Dispatcher {
+	return &Dispatcher{store: store, channels: channels}
+}
+
+// Channels lists the names of the configured channels
+func (d *Dispatcher) Channels() []string {
+	if d == nil {
+		return nil
+	}
+	names := make([]string, len(d.channels))
+	for i, c := range d.channels {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Channel finds a configured channel by name, or nil if there is none
+func (d *Dispatcher) Channel(name string) Channel {
+	if d == nil {
+		return nil
+	}
+	for _, c := range d.channels {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Notify sends e to each recipient on every channel they have not turned it
+// off for, or queues it for their digest. Failures are logged rather than
+// returned: a notification is never worth failing a request.
+func (d *Dispatcher) Notify(ctx context.Context, recipients []string, e Event) {
+	if d == nil {
+		return
+	}
+	for _, userID := range recipients {
+		if userID == e.Actor {
+			continue
+		}
+		prefs, err := d.store.GetPreferences(userID)
+		if err != nil {
+			log.Printf("notify %s: reading preferences: %v", userID, err)
+			continue
+		}
+		for _, c := range d.channels {
+			switch prefs.Notifications.Delivery(e.Kind, c.Name()) {
+			case domain.DeliverOff:
+				continue
+			case domain.DeliverDigest:
+				err = d.store.QueueNotification(&domain.QueuedNotification{
+					UserID:  userID,
+					Channel: c.Name(),
+					Kind:    e.Kind,
+					Title:   e.Title,
+					Body:    e.Body,
+					URL:     e.URL,
+				})
+			default:
+				err = c.Send(ctx, userID, e)
+			}
+			if err != nil {
+				log.Printf("notify %s to %s: %v", c.Name(), userID, err)
+			}
+		}
+	}
+}
+
+// Broadcast sends e to everyone any channel can reach
+func (d *Dispatcher) Broadcast(ctx context.Context, e Event) {
+	if d == nil {
+		return
+	}
+	var everyone []string
+	for _, c := range d.channels {
+		users, err := c.Subscribers()
+		if err != nil {
+			log.Printf("notify %s: listing subscribers: %v", c.Name(), err)
+			continue
+		}
+		for _, u := range users {
+			if !slices.Contains(everyone, u) {
+				everyone = append(everyone, u)
+			}
+		}
+	}
+	d.Notify(ctx, everyone, e)
+}
+
+// SendDigests delivers, as one summary per channel, everything queued for
+// each user before their most recent digest hour. Anything queued since
+// waits for the next one.
+func (d *Dispatcher) SendDigests(ctx context.Context, now time.Time) error {
+	if d == nil {
+		return nil
+	}
+	users, err := d.store.GetDigestRecipients()
+	if err != nil {
+		return err
+	}
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		prefs, err := d.store.GetPreferences(userID)
+		if err != nil {
+			return err
+		}
+		queued, err := d.store.TakeQueuedNotifications(userID, prefs.LastDigest(now))
+		if err != nil {
+			return err
+		}
+
+		byChannel := map[string][]*domain.QueuedNotification{}
+		for _, n := range queued {
+			byChannel[n.Channel] = append(byChannel[n.Channel], n)
+		}
+		for name, events := range byChannel {
+			i := slices.IndexFunc(d.channels, func(c Channel) bool { return c.Name() == name })
+			if i < 0 {
+				log.Printf("notify digest for %s: channel %s is no longer configured", userID, name)
+				continue
+			}
+			if err := d.channels[i].Send(ctx, userID, digest(events)); err != nil {
+				log.Printf("notify digest %s to %s: %v", name, userID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// digest summarizes queued events as a single event
+func digest(events []*domain.QueuedNotification) Event {
+	if len(events) == 1 {
+		n := events[0]
+		return Event{Kind: KindDigest, Title: n.Title, Body: n.Body, URL: n.URL}
+	}
+
+	var lines []string
+	for _, n := range events[:min(len(events), digestPreview)] {
+		lines = append(lines, n.Title)
+	}
+	if more := len(events) - digestPreview; more > 0 {
+		lines = append(lines, fmt.Sprintf("and %d more", more))
+	}
+	return Event{
+		Kind:  KindDigest,
+		Title: fmt.Sprintf("%d updates since your last digest", len(events)),
+		Body:  strings.Join(lines, "\n"),
+		URL:   "/",
+	}
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/safehttp"
+)
+
+const serviceTimeout = 10 * time.Second
+
+// DefaultNtfyServer is where ntfy topics are published when a user names
+// no server of their own
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// Ntfy publishes notifications to the ntfy topic each user has set up
+// (https://ntfy.sh), on the public server or their own
+type Ntfy struct {
+	store  domain.Store
+	client *http.Client
+}
+
+// NewNtfy creates an ntfy channel. Unless allowPrivate is set it only
+// connects to servers on public addresses, as with any other URL a user
+// types in.
+func NewNtfy(store domain.Store, allowPrivate bool) *Ntfy {
+	return &Ntfy{store: store, client: serviceClient(allowPrivate)}
+}
+
+func (n *Ntfy) Name() string {
+	return domain.ServiceNtfy
+}
+
+func (n *Ntfy) Subscribers() ([]string, error) {
+	return n.store.GetNotifyServiceUsers(domain.ServiceNtfy)
+}
+
+// Send publishes e to userID's topic, if they have one. It uses ntfy's JSON
+// form, which unlike headers carries any title.
+func (n *Ntfy) Send(ctx context.Context, userID string, e Event) error {
+	svc, err := n.store.GetNotifyService(userID, domain.ServiceNtfy)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"topic":   svc.Topic,
+		"title":   e.Title,
+		"message": message(e),
+		"click":   link(svc, e),
+		"tags":    []string{e.Kind},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(svc.URL, "/"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if svc.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+svc.Token)
+	}
+	return post(n.client, req)
+}
+
+// serviceClient is the client for push services at users' addresses
+func serviceClient(allowPrivate bool) *http.Client {
+	if allowPrivate {
+		return &http.Client{Timeout: serviceTimeout}
+	}
+	return safehttp.NewClient(serviceTimeout)
+}
+
+// post sends req, turning a refusal into an error that quotes the service
+func post(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// message is the body of a notification, which push services require
+func message(e Event) string {
+	if e.Body != "" {
+		return e.Body
+	}
+	return e.Title
+}
+
+// link is where opening a notification goes, if compass's address is known
+func link(svc *domain.NotifyService, e Event) string {
+	if svc.AppURL == "" || e.URL == "" {
+		return ""
+	}
+	return svc.AppURL + e.URL
+}
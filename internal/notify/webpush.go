@@ -0,0 +1,234 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/safehttp"
+)
+
+const (
+	pushTimeout = 10 * time.Second
+	pushTTL     = 24 * time.Hour // how long the push service holds a message for an offline browser
+	recordSize  = 4096
+)
+
+var b64 = base64.RawURLEncoding
+
+// WebPush sends notifications to browsers with the Web Push protocol
+// (RFC 8030), identifying the server with VAPID (RFC 8292) and encrypting
+// payloads as RFC 8291 describes.
+type WebPush struct {
+	store   domain.Store
+	key     *ecdsa.PrivateKey
+	subject string // contact for push services, a mailto: or https: URL
+	client  *http.Client
+}
+
+// NewWebPush creates a Web Push channel signing with key, which must be a
+// P-256 key, and naming subject as the operator's contact
+func NewWebPush(store domain.Store, key *ecdsa.PrivateKey, subject string) (*WebPush, error) {
+	if _, err := key.ECDH(); err != nil || key.Curve.Params().Name != "P-256" {
+		return nil, errors.New("VAPID keys must be P-256")
+	}
+	return &WebPush{
+		store:   store,
+		key:     key,
+		subject: subject,
+		client:  safehttp.NewClient(pushTimeout),
+	}, nil
+}
+
+// PublicKey is the application server key browsers subscribe with
+func (p *WebPush) PublicKey() string {
+	pub, _ := p.key.PublicKey.ECDH()
+	return b64.EncodeToString(pub.Bytes())
+}
+
+func (p *WebPush) Name() string {
+	return "push"
+}
+
+func (p *WebPush) Subscribers() ([]string, error) {
+	return p.store.GetPushSubscribers()
+}
+
+// Send pushes e to every browser userID has subscribed. Subscriptions the
+// push service reports as gone are deleted.
+func (p *WebPush) Send(ctx context.Context, userID string, e Event) error {
+	subs, err := p.store.GetPushSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		status, err := p.push(ctx, sub, payload)
+		if status == http.StatusNotFound || status == http.StatusGone {
+			err = p.store.DeletePushSubscription("", sub.Endpoint)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *WebPush) push(ctx context.Context, sub *domain.PushSubscription, payload []byte) (int, error) {
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return 0, err
+	}
+	auth, err := p.vapid(sub.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("push to %s: %s", req.URL.Host, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// vapid returns the Authorization header for a push to endpoint: a signed
+// JWT naming the push service's origin, plus our public key.
+func (p *WebPush) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + b64.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, p.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the raw 32-byte big-endian r and s, not ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return "vapid t=" + signingInput + "." + b64.EncodeToString(sig) + ", k=" + p.PublicKey(), nil
+}
+
+// encrypt seals payload for the subscription's browser as a single
+// aes128gcm record (RFC 8188) keyed as RFC 8291 describes
+func encrypt(sub *domain.PushSubscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := b64.DecodeString(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("subscription key: %w", err)
+	}
+	authSecret, err := b64.DecodeString(sub.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("subscription auth: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("subscription key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	prkKey, err := hkdf.Extract(sha256.New, ecdhSecret, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublicBytes) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length, key ID (our public key)
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(payload)+1+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+
+	// 0x02 marks the last (and only) record
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > recordSize {
+		return nil, errors.New("notification payload is too large")
+	}
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
@@ -0,0 +1,139 @@
+// Package planning schedules a category's tasks around their dependencies.
+// Each task takes the part of its estimate that is not yet done, and can
+// start once everything it depends on has finished.
+package planning
+
+import (
+	"errors"
+	"math"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// ErrCycle is returned when tasks depend on each other in a loop, so no
+// order can satisfy them all
+var ErrCycle = errors.New("dependencies form a cycle")
+
+// Slot is where a task falls in the schedule, in hours from now. Slack is
+// how long the task can slip without delaying the whole category.
+type Slot struct {
+	Duration       float64
+	EarliestStart  float64
+	EarliestFinish float64
+	LatestStart    float64
+	LatestFinish   float64
+	Slack          float64
+	Critical       bool
+}
+
+// Schedule is the result of a critical path analysis
+type Schedule struct {
+	Slots  map[string]Slot
+	Length float64  // hours until every task could be finished
+	Path   []string // critical task IDs, in the order they run
+}
+
+// epsilon absorbs floating point error when comparing hours
+const epsilon = 1e-9
+
+// Remaining is the hours of a task's estimate that are not yet done
+func Remaining(t *domain.Task) float64 {
+	return t.Estimate * float64(100-t.Completion) / 100
+}
+
+// CriticalPath schedules tasks as early as their dependencies allow, then
+// works back from the end to find how late each could start. Tasks with no
+// slack and work left to do are critical. Dependencies on tasks outside the
+// list are ignored.
+func CriticalPath(tasks []*domain.Task) (*Schedule, error) {
+	order, err := topological(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &Schedule{Slots: make(map[string]Slot, len(tasks))}
+
+	// Forward pass: earliest start is when the last dependency finishes
+	for _, t := range order {
+		slot := Slot{Duration: Remaining(t)}
+		for _, dep := range t.DependsOn {
+			if before, ok := schedule.Slots[dep]; ok {
+				slot.EarliestStart = math.Max(slot.EarliestStart, before.EarliestFinish)
+			}
+		}
+		slot.EarliestFinish = slot.EarliestStart + slot.Duration
+		schedule.Length = math.Max(schedule.Length, slot.EarliestFinish)
+		schedule.Slots[t.ID] = slot
+	}
+
+	// Backward pass: latest finish is when the first dependent must start
+	latestFinish := make(map[string]float64, len(tasks))
+	for _, t := range order {
+		latestFinish[t.ID] = schedule.Length
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		t := order[i]
+		slot := schedule.Slots[t.ID]
+		slot.LatestFinish = latestFinish[t.ID]
+		slot.LatestStart = slot.LatestFinish - slot.Duration
+		slot.Slack = slot.LatestStart - slot.EarliestStart
+		if slot.Slack < epsilon {
+			slot.Slack = 0
+			slot.Critical = slot.Duration > 0
+		}
+		schedule.Slots[t.ID] = slot
+		for _, dep := range t.DependsOn {
+			if finish, ok := latestFinish[dep]; ok {
+				latestFinish[dep] = math.Min(finish, slot.LatestStart)
+			}
+		}
+	}
+
+	for _, t := range order {
+		if schedule.Slots[t.ID].Critical {
+			schedule.Path = append(schedule.Path, t.ID)
+		}
+	}
+	return schedule, nil
+}
+
+// topological orders tasks so each comes after everything it depends on,
+// keeping board order where dependencies allow
+func topological(tasks []*domain.Task) ([]*domain.Task, error) {
+	byID := make(map[string]*domain.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	waiting := make(map[string]int, len(tasks)) // unfinished dependencies
+	dependents := make(map[string][]string)     // reverse edges
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			if _, ok := byID[dep]; ok {
+				waiting[t.ID]++
+				dependents[dep] = append(dependents[dep], t.ID)
+			}
+		}
+	}
+
+	order := make([]*domain.Task, 0, len(tasks))
+	placed := make(map[string]bool, len(tasks))
+	for len(order) < len(tasks) {
+		progressed := false
+		for _, t := range tasks {
+			if placed[t.ID] || waiting[t.ID] > 0 {
+				continue
+			}
+			placed[t.ID] = true
+			order = append(order, t)
+			for _, id := range dependents[t.ID] {
+				waiting[id]--
+			}
+			progressed = true
+		}
+		if !progressed {
+			return nil, ErrCycle
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,163 @@
+package planning
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Energy levels for Suggest, how much focus there is to spend
+const (
+	EnergyLow    = "low"
+	EnergyMedium = "medium"
+	EnergyHigh   = "high"
+)
+
+// Energies lists the energy levels, lowest first
+var Energies = []string{EnergyLow, EnergyMedium, EnergyHigh}
+
+// sittings is roughly how many minutes one sitting on a task of each size
+// takes
+var sittings = map[string]float64{
+	domain.SizeSmall:  30,
+	domain.SizeMedium: 90,
+	domain.SizeLarge:  180,
+}
+
+// SuggestOptions are what Suggest knows about the time and energy on hand
+// and about how each task has been treated
+type SuggestOptions struct {
+	Minutes    int    // time available; 0 if open-ended
+	Energy     string // one of Energies; empty if unsaid
+	Now        time.Time
+	Queue      map[string]int       // task ID to its 1-based place on the focus queue
+	LastWorked map[string]time.Time // task ID to when it was last worked on
+	Include    func(*domain.Task) bool
+}
+
+// Suggestion is a task worth picking up, with why
+type Suggestion struct {
+	Task    *domain.Task `json:"task"`
+	Score   float64      `json:"score"`
+	Reasons []string     `json:"reasons"`
+}
+
+// Suggest scores every task that can be started now and fits the time and
+// energy on hand, best first. Deadlines weigh the most, then priority, the
+// focus queue, and the critical path, then tasks that were started and left
+// to go stale.
+func Suggest(categories []*domain.Category, opts SuggestOptions) []Suggestion {
+	y, m, d := opts.Now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, opts.Now.Location())
+
+	var suggestions []Suggestion
+	for _, c := range categories {
+		done := make(map[string]bool, len(c.Tasks))
+		for _, t := range c.Tasks {
+			done[t.ID] = t.Completion >= 100
+		}
+		critical := make(map[string]bool)
+		if schedule, err := CriticalPath(c.Tasks); err == nil {
+			for _, id := range schedule.Path {
+				critical[id] = true
+			}
+		}
+
+		for _, t := range c.Tasks {
+			if done[t.ID] || t.Blocked || (opts.Include != nil && !opts.Include(t)) {
+				continue
+			}
+			if slices.ContainsFunc(t.DependsOn, func(id string) bool { return !done[id] }) {
+				continue
+			}
+			if !fitsEnergy(t, opts.Energy) || !fitsTime(t, opts.Minutes) {
+				continue
+			}
+			suggestions = append(suggestions, score(t, critical[t.ID], today, opts))
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b Suggestion) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return suggestions
+}
+
+// score adds up why a task is worth doing now
+func score(t *domain.Task, critical bool, today time.Time, opts SuggestOptions) Suggestion {
+	s := Suggestion{Task: t}
+	add := func(points float64, reason string) {
+		s.Score += points
+		s.Reasons = append(s.Reasons, reason)
+	}
+
+	if due, err := time.ParseInLocation(time.DateOnly, t.DueDate, today.Location()); err == nil {
+		switch days := int(math.Round(due.Sub(today).Hours() / 24)); {
+		case days < 0:
+			add(40+math.Min(float64(-days), 10), fmt.Sprintf("overdue by %s", plural(-days, "day")))
+		case days == 0:
+			add(40, "due today")
+		case days <= 7:
+			add(float64(35-4*days), fmt.Sprintf("due in %s", plural(days, "day")))
+		}
+	}
+	if t.Priority > domain.PriorityNormal {
+		add(float64(15*t.Priority), domain.PriorityNames[t.Priority]+" priority")
+	}
+	if place, ok := opts.Queue[t.ID]; ok {
+		add(math.Max(float64(30-3*(place-1)), 10), fmt.Sprintf("#%d on your queue", place))
+	}
+	if critical {
+		add(15, "on the critical path")
+	}
+	if t.InProgress() {
+		add(10, "already started")
+		if last, ok := opts.LastWorked[t.ID]; ok {
+			if days := int(today.Sub(last).Hours() / 24); days >= 7 {
+				add(math.Min(float64(days), 20), fmt.Sprintf("untouched for %s", plural(days, "day")))
+			}
+		}
+	}
+	switch {
+	case t.Size == domain.SizeSmall && opts.Energy == EnergyLow,
+		t.Size == domain.SizeLarge && opts.Energy == EnergyHigh:
+		add(5, "a good use of your energy")
+	}
+	return s
+}
+
+// fitsEnergy keeps large tasks for high energy and medium ones for at least
+// medium. Unsized tasks fit any energy.
+func fitsEnergy(t *domain.Task, energy string) bool {
+	switch energy {
+	case EnergyLow:
+		return t.Size == "" || t.Size == domain.SizeSmall
+	case EnergyMedium:
+		return t.Size != domain.SizeLarge
+	}
+	return true
+}
+
+// fitsTime checks a sitting on the task, or whatever is left of its estimate
+// if that is less, can be done in the time available
+func fitsTime(t *domain.Task, minutes int) bool {
+	if minutes <= 0 {
+		return true
+	}
+	need, sized := sittings[t.Size]
+	if left := Remaining(t) * 60; left > 0 && (!sized || left < need) {
+		need = left
+	}
+	return need <= float64(minutes)
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
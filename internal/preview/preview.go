@@ -0,0 +1,134 @@
+// Package preview fetches titles and favicons for links. Pages are fetched
+// from the server, never the browser, so viewers do not leak requests to
+// third parties and the results can be cached.
+package preview
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/safehttp"
+)
+
+const (
+	maxPageBytes    = 512 << 10
+	maxFaviconBytes = 64 << 10
+	fetchTimeout    = 10 * time.Second
+)
+
+var (
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	linkPattern  = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?is)([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// Fetcher retrieves previews over HTTP
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher creates a Fetcher that only connects to public addresses
+func NewFetcher() *Fetcher {
+	return &Fetcher{client: safehttp.NewClient(fetchTimeout)}
+}
+
+// Fetch returns the preview of rawURL. A page without a title or favicon is
+// not an error; the preview just has those fields empty.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*domain.LinkPreview, error) {
+	p := &domain.LinkPreview{URL: rawURL}
+
+	body, final, contentType, err := f.get(ctx, rawURL, maxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	iconURL := &url.URL{Scheme: final.Scheme, Host: final.Host, Path: "/favicon.ico"}
+	if strings.HasPrefix(contentType, "text/html") {
+		if m := titlePattern.FindSubmatch(body); m != nil {
+			p.Title = html.UnescapeString(string(m[1]))
+		}
+		if href := iconHref(body); href != "" {
+			if u, err := final.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				iconURL = u
+			}
+		}
+	}
+
+	// A missing favicon is common and not worth failing the preview over
+	if icon, _, _, err := f.get(ctx, iconURL.String(), maxFaviconBytes); err == nil {
+		if t := http.DetectContentType(icon); strings.HasPrefix(t, "image/") {
+			p.Favicon, p.FaviconType = icon, t
+		}
+	}
+	return p, nil
+}
+
+// get fetches at most limit bytes of rawURL, returning the URL it ended up
+// at after redirects
+func (f *Fetcher) get(ctx context.Context, rawURL string, limit int64) ([]byte, *url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	req.Header.Set("User-Agent", "compass-link-preview/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return body, resp.Request.URL, resp.Header.Get("Content-Type"), nil
+}
+
+// iconHref finds the first <link rel="icon"> (or "shortcut icon") in a page
+func iconHref(page []byte) string {
+	for _, tag := range linkPattern.FindAll(page, -1) {
+		var rel, href string
+		for _, m := range attrPattern.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(string(m[2]), `"'`))
+			switch strings.ToLower(string(m[1])) {
+			case "rel":
+				rel = value
+			case "href":
+				href = value
+			}
+		}
+		for _, token := range strings.Fields(strings.ToLower(rel)) {
+			if token == "icon" && href != "" {
+				return href
+			}
+		}
+	}
+	return ""
+}
+
+// Refresh fetches the preview of rawURL and caches it. Failures are cached
+// too, as an empty preview, so an unreachable URL is retried on the normal
+// refresh schedule instead of on every pass.
+func Refresh(ctx context.Context, store domain.Store, f *Fetcher, clock domain.Clock, rawURL string) error {
+	p, fetchErr := f.Fetch(ctx, rawURL)
+	if fetchErr != nil {
+		p = &domain.LinkPreview{URL: rawURL}
+	}
+	p.FetchedAt = clock.Now()
+	if err := store.SaveLinkPreview(p); err != nil {
+		return err
+	}
+	return fetchErr
+}
@@ -0,0 +1,42 @@
+// Package report forwards server failures, such as panics while serving a
+// request, to somewhere an operator will see them.
+package report
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Panic is a recovered panic and the request it happened in
+type Panic struct {
+	RequestID string
+	Method    string
+	URL       string
+	Value     any // what was passed to panic
+	Stack     []byte
+	At        time.Time
+}
+
+// Reporter sends a panic somewhere. Reports are best effort: a reporter
+// logs its own failures rather than returning them, since there is nobody
+// left to handle them.
+type Reporter interface {
+	ReportPanic(ctx context.Context, p *Panic)
+}
+
+// Log writes panics, with their stacks, to the standard logger
+type Log struct{}
+
+func (Log) ReportPanic(_ context.Context, p *Panic) {
+	log.Printf("panic serving %s %s [request %s]: %v\n%s", p.Method, p.URL, p.RequestID, p.Value, p.Stack)
+}
+
+// Multi sends each panic to every reporter in turn
+type Multi []Reporter
+
+func (m Multi) ReportPanic(ctx context.Context, p *Panic) {
+	for _, r := range m {
+		r.ReportPanic(ctx, p)
+	}
+}
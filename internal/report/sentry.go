@@ -0,0 +1,110 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const sentryTimeout = 10 * time.Second
+
+// Sentry sends panics to a Sentry-compatible error tracker (Sentry itself,
+// GlitchTip, Bugsink, ...) using the store endpoint and a DSN.
+type Sentry struct {
+	endpoint string // the project's store API URL
+	key      string // the DSN's public key
+	client   *http.Client
+}
+
+// NewSentry parses a DSN of the form https://KEY@HOST/PROJECT_ID
+func NewSentry(dsn string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("invalid DSN: expected https://KEY@HOST/PROJECT_ID")
+	}
+	// The project ID is the last path segment; anything before it is a
+	// prefix the tracker is served under
+	prefix, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = project[:i+1], project[i+1:]
+	}
+	if project == "" {
+		return nil, errors.New("invalid DSN: missing project ID")
+	}
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + prefix + "api/" + project + "/store/"}
+	return &Sentry{
+		endpoint: endpoint.String(),
+		key:      u.User.Username(),
+		client:   &http.Client{Timeout: sentryTimeout},
+	}, nil
+}
+
+// sentryEvent is the subset of Sentry's event payload we fill in
+type sentryEvent struct {
+	EventID   string            `json:"event_id"`
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Platform  string            `json:"platform"`
+	Logger    string            `json:"logger"`
+	Message   string            `json:"message"`
+	Tags      map[string]string `json:"tags"`
+	Request   map[string]string `json:"request"`
+	Extra     map[string]string `json:"extra"`
+}
+
+func (s *Sentry) ReportPanic(ctx context.Context, p *Panic) {
+	if err := s.send(ctx, p); err != nil {
+		log.Printf("Failed to report panic [request %s] to Sentry: %v", p.RequestID, err)
+	}
+}
+
+func (s *Sentry) send(ctx context.Context, p *Panic) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	event := sentryEvent{
+		EventID:   hex.EncodeToString(id),
+		Timestamp: p.At.UTC().Format(time.RFC3339),
+		Level:     "fatal",
+		Platform:  "go",
+		Logger:    "compass",
+		Message:   fmt.Sprintf("panic: %v", p.Value),
+		Tags:      map[string]string{"request_id": p.RequestID},
+		Request:   map[string]string{"method": p.Method, "url": p.URL},
+		Extra:     map[string]string{"stack": string(p.Stack)},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=compass/1, sentry_key="+s.key)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import "git.sr.ht/~jakintosh/compass/internal/domain"
+
+// accountQuery selects a user's rows, bound as ?1, for part of an export
+type accountQuery struct {
+	rows  *[]map[string]any
+	query string
+}
+
+// accountQueries are the parts of an account export. Columns holding
+// secrets are left out.
+func accountQueries(e *domain.AccountExport) []accountQuery {
+	return []accountQuery{
+		{&e.WorkLogs, "SELECT * FROM work_logs WHERE author = ?1 ORDER BY created_at, rowid"},
+		{&e.Revisions, "SELECT * FROM revisions WHERE author = ?1 ORDER BY id"},
+		{&e.AuditEntries, "SELECT * FROM audit_log WHERE actor = ?1 ORDER BY id"},
+		{&e.Trash, "SELECT id, entity_type, entity_id, category_id, task_id, name, deleted_at FROM trash WHERE deleted_by = ?1 ORDER BY deleted_at"},
+		{&e.Attachments, "SELECT id, task_id, work_log_id, filename, content_type, size, created_at FROM attachments WHERE uploaded_by = ?1 ORDER BY created_at"},
+		{&e.Links, "SELECT * FROM links WHERE added_by = ?1 ORDER BY created_at"},
+		{&e.Estimates, "SELECT * FROM estimate_changes WHERE author = ?1 ORDER BY created_at"},
+		{&e.Reviews, "SELECT * FROM review_transitions WHERE author = ?1 ORDER BY created_at"},
+		{&e.Commits, "SELECT * FROM commit_events WHERE user_id = ?1 ORDER BY committed_at"},
+
+		{&e.Nudges, "SELECT * FROM nudges WHERE user_id = ?1 ORDER BY created_at"},
+		{&e.Queue, "SELECT * FROM queue_items WHERE user_id = ?1 ORDER BY sort_order"},
+		{&e.TimeBlocks, "SELECT * FROM time_blocks WHERE user_id = ?1 ORDER BY day, start_minute"},
+		{&e.Allocations, "SELECT * FROM allocations WHERE user_id = ?1 ORDER BY week, task_id"},
+		{&e.Timers, "SELECT * FROM timers WHERE user_id = ?1"},
+		{&e.Notifications, "SELECT * FROM notifications WHERE user_id = ?1 ORDER BY created_at"},
+		{&e.PendingDigest, "SELECT * FROM notification_queue WHERE user_id = ?1 ORDER BY created_at"},
+
+		{&e.HookTokens, "SELECT id, name, category_id, created_at, last_used_at FROM hook_tokens WHERE user_id = ?1 ORDER BY created_at"},
+		{&e.PushSubscriptions, "SELECT endpoint, created_at FROM push_subscriptions WHERE user_id = ?1 ORDER BY created_at"},
+		{&e.NotifyServices, "SELECT service, url, topic, app_url, token != '' AS has_token, created_at FROM notify_services WHERE user_id = ?1 ORDER BY service"},
+		{&e.StatsLinks, "SELECT * FROM stats_links WHERE user_id = ?1"},
+		{&e.SharedCategories, "SELECT m.category_id, c.name, m.added_at FROM category_members m JOIN categories c ON c.id = m.category_id WHERE m.user_id = ?1 ORDER BY m.added_at"},
+		{&e.Groups, "SELECT m.group_id, g.name, m.added_at FROM group_members m JOIN groups g ON g.id = m.group_id WHERE m.user_id = ?1 ORDER BY m.added_at"},
+	}
+}
+
+func (s *SQLiteStore) ExportAccount(userID string) (*domain.AccountExport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	export := &domain.AccountExport{
+		Version:    domain.AccountExportVersion,
+		ExportedAt: s.clock.Now(),
+		Subject:    userID,
+	}
+	prefs, err := selectRows(tx, "SELECT * FROM preferences WHERE user_id = ?1", userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefs) > 0 {
+		export.Preferences = prefs[0]
+	}
+
+	for _, q := range accountQueries(export) {
+		rows, err := selectRows(tx, q.query, userID)
+		if err != nil {
+			return nil, err
+		}
+		// Empty lists rather than nulls, for whoever reads the file
+		if rows == nil {
+			rows = []map[string]any{}
+		}
+		*q.rows = rows
+	}
+	return export, nil
+}
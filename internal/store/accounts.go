@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetAccounts() ([]*domain.Account, error) {
+	return s.getAccounts("")
+}
+
+func (s *SQLiteStore) GetAccount(handle string) (*domain.Account, error) {
+	accounts, err := s.getAccounts("WHERE p.user_id = ?1", handle)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("account %w", domain.ErrNotFound)
+	}
+	return accounts[0], nil
+}
+
+// getAccounts lists the accounts whose preferences match where, with the
+// categories shared with each
+func (s *SQLiteStore) getAccounts(where string, args ...any) ([]*domain.Account, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			p.user_id,
+			p.display_name,
+			p.email,
+			p.deactivated_at IS NULL,
+			COALESCE(GROUP_CONCAT(m.category_id), '')
+		FROM preferences p
+		LEFT JOIN category_members m ON m.user_id = p.user_id
+		`+where+`
+		GROUP BY p.user_id
+		ORDER BY p.user_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*domain.Account
+	for rows.Next() {
+		var a domain.Account
+		var categories string
+		if err := rows.Scan(&a.Handle, &a.DisplayName, &a.Email, &a.Active, &categories); err != nil {
+			return nil, err
+		}
+		a.Categories = []string{}
+		if categories != "" {
+			a.Categories = strings.Split(categories, ",")
+		}
+		accounts = append(accounts, &a)
+	}
+	return accounts, rows.Err()
+}
+
+// cleanHandle trims a user's handle, which may not be blank
+func cleanHandle(handle string) (string, error) {
+	handle = strings.TrimSpace(handle)
+	if handle == "" || len(handle) > 254 || strings.ContainsFunc(handle, unicode.IsControl) {
+		return "", fmt.Errorf("%w: a user needs a handle of up to 254 characters", domain.ErrInvalid)
+	}
+	return handle, nil
+}
+
+func (s *SQLiteStore) CreateAccount(account *domain.Account) (*domain.Account, error) {
+	handle, err := cleanHandle(account.Handle)
+	if err != nil {
+		return nil, err
+	}
+	displayName, err := domain.NormalizeName(account.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	email, err := domain.NormalizeEmail(account.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deactivatedAt sql.NullInt64
+	if !account.Active {
+		deactivatedAt = sql.NullInt64{Int64: s.clock.Now().Unix(), Valid: true}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO preferences (user_id, display_name, email, deactivated_at)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT(user_id) DO NOTHING
+		RETURNING user_id`,
+		handle,
+		displayName,
+		email,
+		deactivatedAt,
+	).Scan(&handle)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: user %s already exists", domain.ErrConflict, handle)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now().Unix()
+	for _, catID := range account.Categories {
+		// Selecting from categories makes the insert a no-op for an unknown
+		// category, which surfaces below as sql.ErrNoRows
+		var added string
+		err := tx.QueryRow(`
+			INSERT INTO category_members (category_id, user_id, added_at)
+			SELECT id, ?2, ?3
+			FROM categories
+			WHERE id = ?1
+			ON CONFLICT DO NOTHING
+			RETURNING category_id`,
+			catID,
+			handle,
+			now,
+		).Scan(&added)
+		if err != nil {
+			return nil, notFound(err, "category")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetAccount(handle)
+}
+
+func (s *SQLiteStore) UpdateAccount(account *domain.Account) (*domain.Account, error) {
+	displayName, err := domain.NormalizeName(account.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	email, err := domain.NormalizeEmail(account.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// A user already deactivated keeps the time it happened
+	var handle string
+	err = s.db.QueryRow(`
+		UPDATE preferences
+		SET display_name = ?2,
+			email = ?3,
+			deactivated_at = CASE WHEN ?4 THEN NULL ELSE COALESCE(deactivated_at, ?5) END
+		WHERE user_id = ?1
+		RETURNING user_id`,
+		account.Handle,
+		displayName,
+		email,
+		account.Active,
+		s.clock.Now().Unix(),
+	).Scan(&handle)
+	if err != nil {
+		return nil, notFound(err, "account")
+	}
+	return s.GetAccount(handle)
+}
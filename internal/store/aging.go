@@ -0,0 +1,148 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddAgingRule(categoryID string, days int, action string) (*domain.AgingRule, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("%w: an aging rule needs at least 1 day", domain.ErrInvalid)
+	}
+	if !slices.Contains(domain.AgingActions, action) {
+		return nil, fmt.Errorf("%w: unknown aging action %q", domain.ErrInvalid, action)
+	}
+
+	// Selecting from the category makes the insert a no-op when it does
+	// not exist
+	var r domain.AgingRule
+	err := s.db.QueryRow(`
+		INSERT INTO aging_rules (id, category_id, days, action)
+		SELECT ?1, id, ?2, ?3
+		FROM categories
+		WHERE id = ?4
+		RETURNING id, category_id, days, action`,
+		s.ids.NewID(),
+		days,
+		action,
+		categoryID,
+	).Scan(&r.ID, &r.CategoryID, &r.Days, &r.Action)
+	if err != nil {
+		return nil, notFound(err, "category")
+	}
+	return &r, nil
+}
+
+func (s *SQLiteStore) DeleteAgingRule(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM aging_rules WHERE id = ?1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM aging_runs WHERE rule_id = ?1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAgingDue finds unfinished tasks that have gone at least a rule's days
+// since they were made or last had work logged, and that the rule has not
+// acted on since
+func (s *SQLiteStore) GetAgingDue(now time.Time) ([]*domain.AgingDue, error) {
+	rows, err := s.db.Query(`
+		WITH idle AS (
+			SELECT
+				t.id,
+				t.category_id,
+				MAX(t.created_at, COALESCE(MAX(w.created_at), 0)) AS since
+			FROM tasks t
+			LEFT JOIN work_logs w ON w.task_id = t.id
+			WHERE t.completion < 100
+			GROUP BY t.id
+		)
+		SELECT
+			r.id,
+			r.category_id,
+			r.days,
+			r.action,
+			i.id
+		FROM aging_rules r
+		JOIN idle i ON i.category_id = r.category_id
+		LEFT JOIN aging_runs a ON a.rule_id = r.id AND a.task_id = i.id
+		WHERE i.since <= ?1 - r.days * 86400
+			AND (a.at IS NULL OR a.at < i.since)
+		ORDER BY r.days, i.id`,
+		now.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*domain.AgingDue
+	for rows.Next() {
+		var r domain.AgingRule
+		var d domain.AgingDue
+		if err := rows.Scan(&r.ID, &r.CategoryID, &r.Days, &r.Action, &d.TaskID); err != nil {
+			return nil, err
+		}
+		d.Rule = &r
+		due = append(due, &d)
+	}
+	return due, rows.Err()
+}
+
+func (s *SQLiteStore) RecordAging(ruleID, taskID, actor, summary string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO aging_runs (rule_id, task_id, at)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT (rule_id, task_id) DO UPDATE SET at = excluded.at`,
+		ruleID,
+		taskID,
+		s.clock.Now().Unix(),
+	); err != nil {
+		return err
+	}
+	if err := s.audit(tx, actor, "age", domain.EntityTask, taskID, summary); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getAgingRules lists a category's aging rules, shortest first
+func (s *SQLiteStore) getAgingRules(categoryID string) ([]*domain.AgingRule, error) {
+	rows, err := s.db.Query(`
+		SELECT id, category_id, days, action
+		FROM aging_rules
+		WHERE category_id = ?1
+		ORDER BY days, rowid`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*domain.AgingRule
+	for rows.Next() {
+		var r domain.AgingRule
+		if err := rows.Scan(&r.ID, &r.CategoryID, &r.Days, &r.Action); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &r)
+	}
+	return rules, rows.Err()
+}
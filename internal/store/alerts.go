@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) SetCategoryAlertToken(catID string, tokenHash string) error {
+	err := s.db.QueryRow(`
+		UPDATE categories
+		SET alert_token_hash = ?2
+		WHERE id = ?1
+		RETURNING id`,
+		catID,
+		tokenHash,
+	).Scan(&catID)
+	return notFound(err, "category")
+}
+
+func (s *SQLiteStore) GetCategoryByAlertToken(tokenHash string) (*domain.Category, error) {
+	var id string
+	if err := s.db.QueryRow(`
+		SELECT id
+		FROM categories
+		WHERE alert_token_hash = ?1 AND alert_token_hash != ''`,
+		tokenHash,
+	).Scan(&id); err != nil {
+		return nil, notFound(err, "category")
+	}
+	return s.GetCategory(id)
+}
+
+func (s *SQLiteStore) AddAlertTask(categoryID string, alert *domain.Alert, actor string) (*domain.Task, bool, error) {
+	name, err := domain.CleanName(alert.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	source := alert.Fingerprint
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var taskID string
+	err = tx.QueryRow(`
+		SELECT id
+		FROM tasks
+		WHERE category_id = ?1 AND source = ?2 AND completion < 100
+		ORDER BY created_at DESC
+		LIMIT 1`,
+		categoryID,
+		source,
+	).Scan(&taskID)
+	switch {
+	case err == nil:
+		tx.Rollback()
+		task, err := s.GetTask(taskID)
+		return task, false, err
+	case err != sql.ErrNoRows:
+		return nil, false, err
+	}
+
+	// Selecting from categories makes the insert a no-op for an unknown
+	// category, which surfaces below as sql.ErrNoRows
+	err = tx.QueryRow(`
+		INSERT INTO tasks (id, category_id, name, description, sort_order, created_at, source)
+		SELECT ?1, id, ?3, ?4, (SELECT COALESCE(MAX(sort_order), -1) + 1 FROM tasks WHERE category_id = ?2), ?5, ?6
+		FROM categories
+		WHERE id = ?2
+		RETURNING id`,
+		s.ids.NewID(),
+		categoryID,
+		name,
+		alert.Description,
+		s.clock.Now().Unix(),
+		source,
+	).Scan(&taskID)
+	if err != nil {
+		return nil, false, notFound(err, "category")
+	}
+	if err := s.copyDoneCriteria(tx, taskID, categoryID); err != nil {
+		return nil, false, err
+	}
+	if err := refreshCategoryCompletion(tx, categoryID); err != nil {
+		return nil, false, err
+	}
+	if err := s.audit(tx, actor, "alert", domain.EntityTask, taskID, "added for alert "+source); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	task, err := s.GetTask(taskID)
+	return task, true, err
+}
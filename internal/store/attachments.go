@@ -0,0 +1,153 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+const attachmentColumns = `
+	id,
+	task_id,
+	work_log_id,
+	filename,
+	content_type,
+	size,
+	has_thumbnail,
+	uploaded_by,
+	created_at`
+
+func (s *SQLiteStore) AddAttachment(a *domain.Attachment) (*domain.Attachment, error) {
+	filename, err := domain.CleanName(a.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Selecting from the parent makes the insert a no-op when it does not
+	// exist, and takes the task from the work log so the two always agree
+	parent, table, what := a.TaskID, "tasks", "task"
+	taskExpr, workLogExpr := "id", "NULL"
+	if a.WorkLogID != "" {
+		parent, table, what = a.WorkLogID, "work_logs", "work log"
+		taskExpr, workLogExpr = "task_id", "id"
+	}
+
+	row := s.db.QueryRow(`
+		INSERT INTO attachments (`+attachmentColumns+`)
+		SELECT ?1, `+taskExpr+`, `+workLogExpr+`, ?2, ?3, ?4, ?5, ?6, ?7
+		FROM `+table+`
+		WHERE id = ?8
+		RETURNING`+attachmentColumns,
+		s.ids.NewID(),
+		filename,
+		a.ContentType,
+		a.Size,
+		a.HasThumbnail,
+		a.UploadedBy,
+		s.clock.Now().Unix(),
+		parent,
+	)
+	added, err := scanAttachment(row)
+	if err != nil {
+		return nil, notFound(err, what)
+	}
+	return added, nil
+}
+
+func (s *SQLiteStore) GetAttachment(id string) (*domain.Attachment, error) {
+	a, err := scanAttachment(s.db.QueryRow(`
+		SELECT`+attachmentColumns+`
+		FROM attachments
+		WHERE id = ?1`,
+		id,
+	))
+	if err != nil {
+		return nil, notFound(err, "attachment")
+	}
+	return a, nil
+}
+
+// DeleteAttachment removes the metadata and returns it, so the caller can
+// delete the blob.
+func (s *SQLiteStore) DeleteAttachment(id string) (*domain.Attachment, error) {
+	a, err := scanAttachment(s.db.QueryRow(`
+		DELETE FROM attachments
+		WHERE id = ?1
+		RETURNING`+attachmentColumns,
+		id,
+	))
+	if err != nil {
+		return nil, notFound(err, "attachment")
+	}
+	return a, nil
+}
+
+// getAttachments lists attachments matching where, oldest first
+func (s *SQLiteStore) getAttachments(where string, args ...any) ([]*domain.Attachment, error) {
+	rows, err := s.db.Query(`
+		SELECT`+attachmentColumns+`
+		FROM attachments
+		WHERE `+where+`
+		ORDER BY created_at ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*domain.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// attachToWorkLogs fills in the attachments of logs, which must be every
+// work log matching where
+func (s *SQLiteStore) attachToWorkLogs(logs []*domain.WorkLog, where string, args ...any) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	attachments, err := s.getAttachments("work_log_id IN (SELECT id FROM work_logs WHERE "+where+")", args...)
+	if err != nil {
+		return err
+	}
+	byLog := make(map[string]*domain.WorkLog, len(logs))
+	for _, wl := range logs {
+		byLog[wl.ID] = wl
+	}
+	for _, a := range attachments {
+		if wl, ok := byLog[a.WorkLogID]; ok {
+			wl.Attachments = append(wl.Attachments, a)
+		}
+	}
+	return nil
+}
+
+func scanAttachment(row interface{ Scan(...any) error }) (*domain.Attachment, error) {
+	var a domain.Attachment
+	var workLogID sql.NullString
+	var createdAt int64
+	if err := row.Scan(
+		&a.ID,
+		&a.TaskID,
+		&workLogID,
+		&a.Filename,
+		&a.ContentType,
+		&a.Size,
+		&a.HasThumbnail,
+		&a.UploadedBy,
+		&createdAt,
+	); err != nil {
+		return nil, err
+	}
+	a.WorkLogID = workLogID.String
+	a.CreatedAt = time.Unix(createdAt, 0).UTC()
+	return &a, nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// dumpTables are the tables a dump covers, parents before children
+var dumpTables = []string{"categories", "tasks", "subtasks", "work_logs", "attachments", "links", "nudges", "task_dependencies", "objectives", "key_results", "key_result_tasks", "done_criteria", "task_checks", "task_contexts", "tags", "task_tags", "estimate_changes", "review_transitions", "aging_rules", "aging_runs"}
+
+func dumpRows(d *domain.Dump) map[string]*[]map[string]any {
+	return map[string]*[]map[string]any{
+		"categories":  &d.Categories,
+		"tasks":       &d.Tasks,
+		"subtasks":    &d.Subtasks,
+		"work_logs":   &d.WorkLogs,
+		"attachments": &d.Attachments,
+		"links":       &d.Links,
+		"nudges":      &d.Nudges,
+
+		"task_dependencies":  &d.Dependencies,
+		"objectives":         &d.Objectives,
+		"key_results":        &d.KeyResults,
+		"key_result_tasks":   &d.GoalLinks,
+		"done_criteria":      &d.DoneCriteria,
+		"task_checks":        &d.TaskChecks,
+		"task_contexts":      &d.TaskContexts,
+		"tags":               &d.Tags,
+		"task_tags":          &d.TaskTags,
+		"estimate_changes":   &d.Estimates,
+		"review_transitions": &d.Reviews,
+		"aging_rules":        &d.AgingRules,
+		"aging_runs":         &d.AgingRuns,
+	}
+}
+
+// ExportDump reads every board table inside one transaction, so the dump is
+// consistent even while others are editing.
+func (s *SQLiteStore) ExportDump() (*domain.Dump, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	dump := &domain.Dump{
+		Version:    domain.DumpVersion,
+		ExportedAt: s.clock.Now(),
+	}
+	rows := dumpRows(dump)
+	for _, table := range dumpTables {
+		// table is always one of the constants above
+		if *rows[table], err = selectRows(tx, fmt.Sprintf("SELECT * FROM %s ORDER BY rowid", table)); err != nil {
+			return nil, err
+		}
+	}
+	return dump, nil
+}
+
+// ImportDump inserts a dump's rows as they are, in one transaction. It only
+// runs against an empty board, so IDs can never collide with existing ones.
+func (s *SQLiteStore) ImportDump(dump *domain.Dump, actor string) error {
+	if dump.Version < 1 || dump.Version > domain.DumpVersion {
+		return fmt.Errorf("%w: unsupported dump version %d", domain.ErrInvalid, dump.Version)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: board is not empty", domain.ErrConflict)
+	}
+
+	// Work log corrections reference other work logs in no particular order
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+	rows := dumpRows(dump)
+	for _, table := range dumpTables {
+		if err := insertRows(tx, table, *rows[table]); err != nil {
+			return fmt.Errorf("importing %s: %w", table, err)
+		}
+	}
+
+	for _, batch := range []struct {
+		entityType string
+		rows       []map[string]any
+	}{
+		{domain.EntityCategory, dump.Categories},
+		{domain.EntityTask, dump.Tasks},
+		{domain.EntitySubtask, dump.Subtasks},
+		{domain.EntityWorkLog, dump.WorkLogs},
+	} {
+		for _, row := range batch.rows {
+			if err := indexRefs(tx, batch.entityType, fmt.Sprint(row["id"])); err != nil {
+				return err
+			}
+		}
+	}
+	for _, row := range dump.Categories {
+		if err := refreshCategoryCompletion(tx, fmt.Sprint(row["id"])); err != nil {
+			return err
+		}
+	}
+
+	summary := fmt.Sprintf("%d categories, %d tasks, %d work logs", len(dump.Categories), len(dump.Tasks), len(dump.WorkLogs))
+	if err := s.audit(tx, actor, "import", "board", "", summary); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
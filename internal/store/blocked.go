@@ -0,0 +1,58 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// GetBlockedTasks lists every blocked task, the longest stuck first
+func (s *SQLiteStore) GetBlockedTasks() ([]*domain.BlockedTask, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id,
+			t.name,
+			c.id,
+			c.name,
+			t.blocked_reason,
+			t.waiting_on,
+			t.blocked_at
+		FROM tasks t
+		JOIN categories c ON t.category_id = c.id
+		WHERE t.blocked_at > 0
+		ORDER BY t.blocked_at, c.sort_order, t.sort_order`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*domain.BlockedTask
+	for rows.Next() {
+		var t domain.BlockedTask
+		var since int64
+		if err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.CategoryID,
+			&t.CategoryName,
+			&t.Reason,
+			&t.WaitingOn,
+			&since,
+		); err != nil {
+			return nil, err
+		}
+		t.Since = time.Unix(since, 0)
+		tasks = append(tasks, &t)
+	}
+	return tasks, rows.Err()
+}
+
+// setBlocked fills in whether a task is blocked from its blocked_at column,
+// which is 0 while it is not
+func setBlocked(t *domain.Task, blockedAt int64) {
+	t.Blocked = blockedAt > 0
+	if t.Blocked {
+		t.BlockedSince = time.Unix(blockedAt, 0)
+	}
+}
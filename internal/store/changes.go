@@ -0,0 +1,69 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetBoardChanges(since int64, limit int) ([]*domain.BoardChange, error) {
+	rows, err := s.db.Query(`
+		SELECT seq, entity_type, entity_id, category_id, kind, at
+		FROM board_changes
+		WHERE seq > ?1
+		ORDER BY seq
+		LIMIT ?2`,
+		since,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*domain.BoardChange
+	for rows.Next() {
+		var c domain.BoardChange
+		var at int64
+		if err := rows.Scan(
+			&c.Seq,
+			&c.EntityType,
+			&c.EntityID,
+			&c.CategoryID,
+			&c.Kind,
+			&at,
+		); err != nil {
+			return nil, err
+		}
+		c.At = time.Unix(at, 0).UTC()
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+func (s *SQLiteStore) GetBoardChangeRange() (first, last int64, err error) {
+	// The sequence outlives the rows it numbered, so pruning everything
+	// does not start the count again
+	err = s.db.QueryRow(`
+		SELECT
+			COALESCE((SELECT MIN(seq) FROM board_changes), seq + 1),
+			seq
+		FROM (
+			SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'board_changes'), 0) AS seq
+		)`,
+	).Scan(&first, &last)
+	return first, last, err
+}
+
+func (s *SQLiteStore) PruneBoardChanges(before time.Time) (int, error) {
+	res, err := s.db.Exec(`
+		DELETE FROM board_changes
+		WHERE at < ?1`,
+		before.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddDoneCriterion(categoryID string, text string) (*domain.DoneCriterion, error) {
+	text, err := domain.CleanName(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Selecting from the category makes the insert a no-op when it does
+	// not exist
+	var c domain.DoneCriterion
+	err = s.db.QueryRow(`
+		INSERT INTO done_criteria (id, category_id, text, sort_order)
+		SELECT
+			?1,
+			id,
+			?2,
+			COALESCE((SELECT MAX(sort_order) + 1 FROM done_criteria WHERE category_id = ?3), 0)
+		FROM categories
+		WHERE id = ?3
+		RETURNING id, category_id, text`,
+		s.ids.NewID(),
+		text,
+		categoryID,
+	).Scan(&c.ID, &c.CategoryID, &c.Text)
+	if err != nil {
+		return nil, notFound(err, "category")
+	}
+	return &c, nil
+}
+
+func (s *SQLiteStore) DeleteDoneCriterion(id string) error {
+	_, err := s.db.Exec("DELETE FROM done_criteria WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) SetTaskCheck(id string, checked bool) (*domain.TaskCheck, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var c domain.TaskCheck
+	var categoryID string
+	var completion int
+	if err := tx.QueryRow(`
+		UPDATE task_checks
+		SET checked = ?1
+		WHERE id = ?2
+		RETURNING
+			id,
+			task_id,
+			text,
+			checked,
+			(SELECT category_id FROM tasks WHERE id = task_id),
+			(SELECT completion FROM tasks WHERE id = task_id)`,
+		checked,
+		id,
+	).Scan(&c.ID, &c.TaskID, &c.Text, &c.Checked, &categoryID, &completion); err != nil {
+		return nil, notFound(err, "check")
+	}
+	if !checked && completion >= 100 {
+		return nil, fmt.Errorf("%w: the task is finished; reopen it before unchecking %q", domain.ErrConflict, c.Text)
+	}
+
+	// A task whose subtasks are all done was held at 99% until now
+	if err := refreshTaskCompletion(tx, c.TaskID, categoryID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// copyDoneCriteria gives a new task its own unchecked copy of its category's
+// definition of done
+func (s *SQLiteStore) copyDoneCriteria(tx *sql.Tx, taskID, categoryID string) error {
+	rows, err := tx.Query(`
+		SELECT text
+		FROM done_criteria
+		WHERE category_id = ?1
+		ORDER BY sort_order`,
+		categoryID,
+	)
+	if err != nil {
+		return err
+	}
+	var texts []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			rows.Close()
+			return err
+		}
+		texts = append(texts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, text := range texts {
+		if _, err := tx.Exec(`
+			INSERT INTO task_checks (id, task_id, text, sort_order)
+			VALUES (?1, ?2, ?3, ?4)`,
+			s.ids.NewID(),
+			taskID,
+			text,
+			i,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDone fails with ErrConflict if completion would finish a task that
+// still has unchecked items on its definition of done
+func checkDone(tx *sql.Tx, taskID string, completion int) error {
+	if completion < 100 {
+		return nil
+	}
+	var open int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM task_checks
+		WHERE task_id = ?1 AND NOT checked`,
+		taskID,
+	).Scan(&open); err != nil {
+		return err
+	}
+	if open > 0 {
+		return fmt.Errorf("%w: check off the definition of done first (%d left)", domain.ErrConflict, open)
+	}
+	return nil
+}
+
+// getDoneCriteria lists a category's definition of done in order
+func (s *SQLiteStore) getDoneCriteria(categoryID string) ([]*domain.DoneCriterion, error) {
+	rows, err := s.db.Query(`
+		SELECT id, category_id, text
+		FROM done_criteria
+		WHERE category_id = ?1
+		ORDER BY sort_order`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var criteria []*domain.DoneCriterion
+	for rows.Next() {
+		var c domain.DoneCriterion
+		if err := rows.Scan(&c.ID, &c.CategoryID, &c.Text); err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, &c)
+	}
+	return criteria, rows.Err()
+}
+
+// getTaskChecks lists a task's checklist in order
+func (s *SQLiteStore) getTaskChecks(taskID string) ([]*domain.TaskCheck, error) {
+	rows, err := s.db.Query(`
+		SELECT id, task_id, text, checked
+		FROM task_checks
+		WHERE task_id = ?1
+		ORDER BY sort_order`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*domain.TaskCheck
+	for rows.Next() {
+		var c domain.TaskCheck
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Text, &c.Checked); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &c)
+	}
+	return checks, rows.Err()
+}
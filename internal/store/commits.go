@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddCommitEvent(c *domain.CommitEvent) (*domain.CommitEvent, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if c.SHA != "" {
+		var n int
+		if err := tx.QueryRow(
+			"SELECT COUNT(*) FROM commit_events WHERE user_id = ?1 AND sha = ?2",
+			c.UserID,
+			c.SHA,
+		).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, fmt.Errorf("%w: commit %s has already been reported", domain.ErrConflict, c.SHA)
+		}
+	}
+
+	var taskID sql.NullString
+	if c.TaskID != "" {
+		taskID = sql.NullString{String: c.TaskID, Valid: true}
+	} else {
+		// Carry on with the task the branch was last seen working on
+		err := tx.QueryRow(`
+			SELECT task_id
+			FROM commit_events
+			WHERE user_id = ?1 AND repo = ?2 AND branch = ?3 AND committed_at <= ?4
+			ORDER BY committed_at DESC
+			LIMIT 1`,
+			c.UserID,
+			c.Repo,
+			c.Branch,
+			c.CommittedAt.Unix(),
+		).Scan(&taskID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	id := s.ids.NewID()
+	if _, err := tx.Exec(`
+		INSERT INTO commit_events (id, user_id, task_id, repo, branch, sha, message, committed_at, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)`,
+		id,
+		c.UserID,
+		taskID,
+		c.Repo,
+		c.Branch,
+		c.SHA,
+		c.Message,
+		c.CommittedAt.Unix(),
+		s.clock.Now().Unix(),
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	commits, err := s.getCommitEvents("e.id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	return commits[0], nil
+}
+
+func (s *SQLiteStore) GetPendingCommits(userID string) ([]*domain.CommitEvent, error) {
+	return s.getCommitEvents("e.user_id = ?1 AND NOT e.settled", userID)
+}
+
+func (s *SQLiteStore) SettleCommits(userID string, ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.Exec(
+			"UPDATE commit_events SET settled = 1 WHERE id = ?1 AND user_id = ?2",
+			id,
+			userID,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) getCommitEvents(where string, args ...any) ([]*domain.CommitEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			e.id,
+			e.user_id,
+			COALESCE(t.id, ''),
+			COALESCE(t.name, ''),
+			e.repo,
+			e.branch,
+			e.sha,
+			e.message,
+			e.committed_at,
+			e.created_at
+		FROM commit_events e
+		LEFT JOIN tasks t ON t.id = e.task_id
+		WHERE `+where+`
+		ORDER BY e.committed_at, e.created_at`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commits []*domain.CommitEvent
+	for rows.Next() {
+		var c domain.CommitEvent
+		var committedAt, createdAt int64
+		if err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.TaskID,
+			&c.TaskName,
+			&c.Repo,
+			&c.Branch,
+			&c.SHA,
+			&c.Message,
+			&committedAt,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		c.CommittedAt = time.Unix(committedAt, 0)
+		c.CreatedAt = time.Unix(createdAt, 0)
+		commits = append(commits, &c)
+	}
+	return commits, rows.Err()
+}
@@ -0,0 +1,15 @@
+package store
+
+import (
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/store/storetest"
+)
+
+func TestSQLiteStoreConforms(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) domain.Store {
+		s, _ := newTestStore(t)
+		return s
+	})
+}
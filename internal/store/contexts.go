@@ -0,0 +1,103 @@
+package store
+
+import (
+	"database/sql"
+	"slices"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) SetTaskContexts(taskID string, contexts []string) ([]string, error) {
+	var clean []string
+	for _, c := range contexts {
+		c, err := domain.NormalizeContext(c)
+		if err != nil {
+			return nil, err
+		}
+		if c != "" && !slices.Contains(clean, c) {
+			clean = append(clean, c)
+		}
+	}
+	slices.Sort(clean)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM tasks WHERE id = ?1)",
+		taskID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, notFound(sql.ErrNoRows, "task")
+	}
+
+	if _, err := tx.Exec("DELETE FROM task_contexts WHERE task_id = ?1", taskID); err != nil {
+		return nil, err
+	}
+	for _, c := range clean {
+		if _, err := tx.Exec(`
+			INSERT INTO task_contexts (task_id, context)
+			VALUES (?1, ?2)`,
+			taskID,
+			c,
+		); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return clean, nil
+}
+
+func (s *SQLiteStore) GetContexts() ([]string, error) {
+	return s.getContexts(`
+		SELECT DISTINCT context
+		FROM task_contexts
+		ORDER BY context`,
+	)
+}
+
+func (s *SQLiteStore) GetContextTaskIDs(context string) ([]string, error) {
+	return s.getContexts(`
+		SELECT task_id
+		FROM task_contexts
+		WHERE context = ?1`,
+		context,
+	)
+}
+
+func (s *SQLiteStore) getTaskContexts(taskID string) ([]string, error) {
+	return s.getContexts(`
+		SELECT context
+		FROM task_contexts
+		WHERE task_id = ?1
+		ORDER BY context`,
+		taskID,
+	)
+}
+
+// getContexts lists the single column of strings query selects
+func (s *SQLiteStore) getContexts(query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
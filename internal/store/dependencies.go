@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddDependency(taskID string, dependsOnID string) error {
+	if taskID == dependsOnID {
+		return fmt.Errorf("%w: a task cannot depend on itself", domain.ErrInvalid)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var sameCategory bool
+	if err := tx.QueryRow(`
+		SELECT t.category_id = d.category_id
+		FROM tasks t, tasks d
+		WHERE t.id = ?1 AND d.id = ?2`,
+		taskID,
+		dependsOnID,
+	).Scan(&sameCategory); err != nil {
+		return notFound(err, "task")
+	}
+	if !sameCategory {
+		return fmt.Errorf("%w: a task can only depend on tasks in its own category", domain.ErrInvalid)
+	}
+
+	// Anything the other task already waits on, however indirectly, cannot
+	// also wait on this one
+	var cycle bool
+	if err := tx.QueryRow(`
+		WITH RECURSIVE upstream(id) AS (
+			SELECT ?2
+			UNION
+			SELECT d.depends_on_id
+			FROM task_dependencies d
+			JOIN upstream u ON d.task_id = u.id
+		)
+		SELECT EXISTS (SELECT 1 FROM upstream WHERE id = ?1)`,
+		taskID,
+		dependsOnID,
+	).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return fmt.Errorf("%w: that task already waits on this one", domain.ErrConflict)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO task_dependencies (task_id, depends_on_id)
+		VALUES (?1, ?2)
+		ON CONFLICT DO NOTHING`,
+		taskID,
+		dependsOnID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) RemoveDependency(taskID string, dependsOnID string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM task_dependencies
+		WHERE task_id = ?1 AND depends_on_id = ?2`,
+		taskID,
+		dependsOnID,
+	)
+	return err
+}
+
+// getDependencies maps task IDs to what they depend on, for the tasks t
+// matching where, in board order
+func (s *SQLiteStore) getDependencies(where string, args ...any) (map[string][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT d.task_id, d.depends_on_id
+		FROM task_dependencies d
+		JOIN tasks t ON d.task_id = t.id
+		JOIN tasks o ON d.depends_on_id = o.id
+		WHERE `+where+`
+		ORDER BY o.sort_order`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependsOn := make(map[string][]string)
+	for rows.Next() {
+		var taskID, otherID string
+		if err := rows.Scan(&taskID, &otherID); err != nil {
+			return nil, err
+		}
+		dependsOn[taskID] = append(dependsOn[taskID], otherID)
+	}
+	return dependsOn, rows.Err()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetWeeklyDigestsDue(start time.Time) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT id
+		FROM categories
+		WHERE weekly_digest AND digest_week < ?1
+		ORDER BY sort_order`,
+		start.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetCategoryWeek counts a task as finished in the week when it last
+// reached 100% then and is still finished
+func (s *SQLiteStore) GetCategoryWeek(categoryID string, start, end time.Time) (*domain.CategoryWeek, error) {
+	week := domain.CategoryWeek{Start: start}
+	if err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(hours_worked), 0)
+		FROM work_logs
+		WHERE category_id = ?1
+			AND created_at >= ?2
+			AND created_at < ?3`,
+		categoryID,
+		start.Unix(),
+		end.Unix(),
+	).Scan(&week.Hours); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT t.id, t.name, MAX(e.at) AS at
+		FROM task_events e
+		JOIN tasks t ON t.id = e.task_id
+		WHERE t.category_id = ?1
+			AND t.completion >= 100
+			AND e.kind = 'completed'
+		GROUP BY t.id
+		HAVING at >= ?2 AND at < ?3
+		ORDER BY at, t.id`,
+		categoryID,
+		start.Unix(),
+		end.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t domain.Task
+		var at int64
+		if err := rows.Scan(&t.ID, &t.Name, &at); err != nil {
+			return nil, err
+		}
+		t.CategoryID = categoryID
+		t.Completion = 100
+		week.Completed = append(week.Completed, &t)
+	}
+	return &week, rows.Err()
+}
+
+func (s *SQLiteStore) AppendWeeklyDigest(categoryID string, start time.Time, entry, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var description string
+	if err := tx.QueryRow(
+		"SELECT description FROM categories WHERE id = ?1",
+		categoryID,
+	).Scan(&description); err != nil {
+		return notFound(err, "category")
+	}
+	week := start.Format(time.DateOnly)
+	if entry == "" {
+		if _, err := tx.Exec(
+			"UPDATE categories SET digest_week = ?2 WHERE id = ?1",
+			categoryID,
+			week,
+		); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if description != "" {
+		description += "\n\n"
+	}
+	description, err = domain.NormalizeDescription(description + entry)
+	if err != nil {
+		return err
+	}
+	if err := s.recordBaseline(tx, domain.EntityCategory, categoryID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE categories
+		SET description = ?2,
+			digest_week = ?3
+		WHERE id = ?1`,
+		categoryID,
+		description,
+		week,
+	); err != nil {
+		return err
+	}
+	if err := indexRefs(tx, domain.EntityCategory, categoryID); err != nil {
+		return err
+	}
+	if err := s.recordRevision(tx, domain.EntityCategory, categoryID, actor); err != nil {
+		return err
+	}
+	if err := s.audit(tx, actor, "digest", domain.EntityCategory, categoryID, fmt.Sprintf("week of %s", week)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
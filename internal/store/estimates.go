@@ -0,0 +1,93 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) ReforecastTask(taskID string, estimate float64, actor string) (*domain.Task, error) {
+	if estimate <= 0 {
+		return nil, fmt.Errorf("%w: a re-forecast estimate must be more than zero hours", domain.ErrInvalid)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.recordBaseline(tx, domain.EntityTask, taskID); err != nil {
+		return nil, err
+	}
+
+	var previous, logged float64
+	var categoryID string
+	if err := tx.QueryRow(`
+		SELECT
+			t.estimate,
+			t.category_id,
+			(SELECT COALESCE(SUM(hours_worked), 0) FROM work_logs WHERE task_id = t.id)
+		FROM tasks t
+		WHERE t.id = ?1`,
+		taskID,
+	).Scan(&previous, &categoryID, &logged); err != nil {
+		return nil, notFound(err, "task")
+	}
+
+	if _, err := tx.Exec("UPDATE tasks SET estimate = ?2 WHERE id = ?1", taskID, estimate); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO estimate_changes (id, task_id, previous, estimate, hours_logged, author, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+		s.ids.NewID(),
+		taskID,
+		previous,
+		estimate,
+		logged,
+		actor,
+		s.clock.Now().Unix(),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordRevision(tx, domain.EntityTask, taskID, actor); err != nil {
+		return nil, err
+	}
+	// Estimates weigh tasks in their category's completion
+	if err := refreshCategoryCompletion(tx, categoryID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetTask(taskID)
+}
+
+func (s *SQLiteStore) getEstimateChanges(taskID string) ([]*domain.EstimateChange, error) {
+	rows, err := s.db.Query(`
+		SELECT id, task_id, previous, estimate, hours_logged, author, created_at
+		FROM estimate_changes
+		WHERE task_id = ?1
+		ORDER BY created_at DESC, rowid DESC`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*domain.EstimateChange
+	for rows.Next() {
+		var c domain.EstimateChange
+		var createdAt int64
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Previous, &c.Estimate, &c.HoursLogged, &c.Author, &createdAt); err != nil {
+			return nil, err
+		}
+		c.CreatedAt = time.Unix(createdAt, 0).UTC()
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
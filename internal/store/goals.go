@@ -0,0 +1,283 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetObjectives(quarter string) ([]*domain.Objective, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, description, quarter, created_at
+		FROM objectives
+		WHERE quarter = ?1
+		ORDER BY created_at, id`,
+		quarter,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objectives []*domain.Objective
+	byID := make(map[string]*domain.Objective)
+	for rows.Next() {
+		var o domain.Objective
+		var createdAt int64
+		if err := rows.Scan(&o.ID, &o.Name, &o.Description, &o.Quarter, &createdAt); err != nil {
+			return nil, err
+		}
+		o.CreatedAt = time.Unix(createdAt, 0)
+		objectives = append(objectives, &o)
+		byID[o.ID] = &o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keyResults, err := s.getKeyResults(`
+		kr.objective_id IN (SELECT id FROM objectives WHERE quarter = ?1)`,
+		quarter,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, kr := range keyResults {
+		o := byID[kr.ObjectiveID]
+		o.KeyResults = append(o.KeyResults, kr)
+	}
+	return objectives, nil
+}
+
+func (s *SQLiteStore) AddObjective(o *domain.Objective) (*domain.Objective, error) {
+	clean := *o
+	if err := clean.Normalize(); err != nil {
+		return nil, err
+	}
+
+	clean.ID = s.ids.NewID()
+	clean.CreatedAt = time.Unix(s.clock.Now().Unix(), 0)
+	if _, err := s.db.Exec(`
+		INSERT INTO objectives (id, name, description, quarter, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5)`,
+		clean.ID,
+		clean.Name,
+		clean.Description,
+		clean.Quarter,
+		clean.CreatedAt.Unix(),
+	); err != nil {
+		return nil, err
+	}
+	return &clean, nil
+}
+
+func (s *SQLiteStore) DeleteObjective(id string) error {
+	_, err := s.db.Exec("DELETE FROM objectives WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) AddKeyResult(kr *domain.KeyResult) (*domain.KeyResult, error) {
+	clean := *kr
+	if err := clean.Normalize(); err != nil {
+		return nil, err
+	}
+
+	// Selecting from the objective makes the insert a no-op when it does
+	// not exist
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO key_results (
+			id,
+			objective_id,
+			name,
+			target,
+			current,
+			unit,
+			from_tasks,
+			created_at
+		)
+		SELECT ?1, id, ?2, ?3, ?4, ?5, ?6, ?7
+		FROM objectives
+		WHERE id = ?8
+		RETURNING id`,
+		s.ids.NewID(),
+		clean.Name,
+		clean.Target,
+		clean.Current,
+		clean.Unit,
+		clean.FromTasks,
+		s.clock.Now().Unix(),
+		clean.ObjectiveID,
+	).Scan(&id)
+	if err != nil {
+		return nil, notFound(err, "objective")
+	}
+	return s.GetKeyResult(id)
+}
+
+func (s *SQLiteStore) GetKeyResult(id string) (*domain.KeyResult, error) {
+	keyResults, err := s.getKeyResults("kr.id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyResults) == 0 {
+		return nil, notFound(sql.ErrNoRows, "key result")
+	}
+	return keyResults[0], nil
+}
+
+func (s *SQLiteStore) UpdateKeyResult(kr *domain.KeyResult) error {
+	clean := *kr
+	if err := clean.Normalize(); err != nil {
+		return err
+	}
+
+	var id string
+	err := s.db.QueryRow(`
+		UPDATE key_results
+		SET name = ?1, target = ?2, current = ?3, unit = ?4, from_tasks = ?5
+		WHERE id = ?6
+		RETURNING id`,
+		clean.Name,
+		clean.Target,
+		clean.Current,
+		clean.Unit,
+		clean.FromTasks,
+		clean.ID,
+	).Scan(&id)
+	return notFound(err, "key result")
+}
+
+func (s *SQLiteStore) DeleteKeyResult(id string) error {
+	_, err := s.db.Exec("DELETE FROM key_results WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) LinkKeyResult(keyResultID string, taskID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM key_results WHERE id = ?1)",
+		keyResultID,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return notFound(sql.ErrNoRows, "key result")
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	var id string
+	err = tx.QueryRow(`
+		INSERT INTO key_result_tasks (key_result_id, task_id)
+		SELECT ?1, id
+		FROM tasks
+		WHERE id = ?2
+		ON CONFLICT DO UPDATE SET task_id = excluded.task_id
+		RETURNING task_id`,
+		keyResultID,
+		taskID,
+	).Scan(&id)
+	if err != nil {
+		return notFound(err, "task")
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) UnlinkKeyResult(keyResultID string, taskID string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM key_result_tasks
+		WHERE key_result_id = ?1 AND task_id = ?2`,
+		keyResultID,
+		taskID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetTaskKeyResults(taskID string) ([]*domain.KeyResult, error) {
+	return s.getKeyResults(`
+		kr.id IN (SELECT key_result_id FROM key_result_tasks WHERE task_id = ?1)`,
+		taskID,
+	)
+}
+
+// getKeyResults lists the key results matching where, each with its linked
+// tasks, in the order they were added
+func (s *SQLiteStore) getKeyResults(where string, args ...any) ([]*domain.KeyResult, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			kr.id,
+			kr.objective_id,
+			o.name,
+			kr.name,
+			kr.target,
+			kr.current,
+			kr.unit,
+			kr.from_tasks
+		FROM key_results kr
+		JOIN objectives o ON kr.objective_id = o.id
+		WHERE `+where+`
+		ORDER BY kr.created_at, kr.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keyResults []*domain.KeyResult
+	byID := make(map[string]*domain.KeyResult)
+	for rows.Next() {
+		var kr domain.KeyResult
+		if err := rows.Scan(
+			&kr.ID,
+			&kr.ObjectiveID,
+			&kr.Objective,
+			&kr.Name,
+			&kr.Target,
+			&kr.Current,
+			&kr.Unit,
+			&kr.FromTasks,
+		); err != nil {
+			return nil, err
+		}
+		keyResults = append(keyResults, &kr)
+		byID[kr.ID] = &kr
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tasks, err := s.db.Query(`
+		SELECT l.key_result_id, t.id, t.name, c.name, t.completion, t.estimate
+		FROM key_result_tasks l
+		JOIN key_results kr ON l.key_result_id = kr.id
+		JOIN tasks t ON l.task_id = t.id
+		JOIN categories c ON t.category_id = c.id
+		WHERE `+where+`
+		ORDER BY c.sort_order, t.sort_order`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer tasks.Close()
+
+	for tasks.Next() {
+		var keyResultID string
+		var t domain.LinkedTask
+		if err := tasks.Scan(&keyResultID, &t.ID, &t.Name, &t.CategoryName, &t.Completion, &t.Estimate); err != nil {
+			return nil, err
+		}
+		kr := byID[keyResultID]
+		kr.Tasks = append(kr.Tasks, &t)
+	}
+	return keyResults, tasks.Err()
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetGroups() ([]*domain.Group, error) {
+	return s.getGroups("")
+}
+
+// getGroups lists the groups matching where, by name, with their members
+func (s *SQLiteStore) getGroups(where string, args ...any) ([]*domain.Group, error) {
+	rows, err := s.db.Query(`
+		SELECT g.id, g.name, COALESCE(m.user_id, '')
+		FROM groups g
+		LEFT JOIN group_members m ON m.group_id = g.id
+		`+where+`
+		ORDER BY g.name COLLATE NOCASE, g.id, m.user_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*domain.Group
+	for rows.Next() {
+		var id, name, member string
+		if err := rows.Scan(&id, &name, &member); err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].ID != id {
+			groups = append(groups, &domain.Group{ID: id, Name: name, Members: []string{}})
+		}
+		if member != "" {
+			g := groups[len(groups)-1]
+			g.Members = append(g.Members, member)
+		}
+	}
+	return groups, rows.Err()
+}
+
+func (s *SQLiteStore) AddGroup(name string) (*domain.Group, error) {
+	name, err := domain.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	err = s.db.QueryRow(`
+		INSERT INTO groups (id, name, created_at)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT(name) DO NOTHING
+		RETURNING id`,
+		s.ids.NewID(),
+		name,
+		s.clock.Now().Unix(),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: there is already a group named %q", domain.ErrConflict, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Group{ID: id, Name: name, Members: []string{}}, nil
+}
+
+func (s *SQLiteStore) DeleteGroup(id string) error {
+	err := s.db.QueryRow("DELETE FROM groups WHERE id = ?1 RETURNING id", id).Scan(&id)
+	return notFound(err, "group")
+}
+
+func (s *SQLiteStore) AddGroupMember(groupID, userID string) error {
+	userID, err := cleanHandle(userID)
+	if err != nil {
+		return err
+	}
+
+	// Selecting from groups makes the insert a no-op for an unknown group;
+	// an existing member is told apart by the second query
+	res, err := s.db.Exec(`
+		INSERT INTO group_members (group_id, user_id, added_at)
+		SELECT id, ?2, ?3
+		FROM groups
+		WHERE id = ?1
+		ON CONFLICT DO NOTHING`,
+		groupID,
+		userID,
+		s.clock.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err := s.db.QueryRow("SELECT id FROM groups WHERE id = ?1", groupID).Scan(&groupID)
+		return notFound(err, "group")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RemoveGroupMember(groupID, userID string) error {
+	err := s.db.QueryRow(`
+		DELETE FROM group_members
+		WHERE group_id = ?1 AND user_id = ?2
+		RETURNING user_id`,
+		groupID,
+		userID,
+	).Scan(&userID)
+	return notFound(err, "group member")
+}
+
+func (s *SQLiteStore) GetCategoryAccess(categoryID string) (*domain.CategoryAccess, error) {
+	if err := s.db.QueryRow("SELECT id FROM categories WHERE id = ?1", categoryID).Scan(&categoryID); err != nil {
+		return nil, notFound(err, "category")
+	}
+
+	access := &domain.CategoryAccess{Users: []string{}}
+	rows, err := s.db.Query(`
+		SELECT user_id
+		FROM category_members
+		WHERE category_id = ?1
+		ORDER BY user_id`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		access.Users = append(access.Users, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	access.Groups, err = s.getGroups("WHERE g.id IN (SELECT group_id FROM category_groups WHERE category_id = ?1)", categoryID)
+	if err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+func (s *SQLiteStore) ShareCategory(categoryID, userID string) error {
+	userID, err := cleanHandle(userID)
+	if err != nil {
+		return err
+	}
+	return s.share(`
+		INSERT INTO category_members (category_id, user_id, added_at)
+		SELECT id, ?2, ?3
+		FROM categories
+		WHERE id = ?1
+		ON CONFLICT DO NOTHING`,
+		categoryID, userID)
+}
+
+func (s *SQLiteStore) UnshareCategory(categoryID, userID string) error {
+	err := s.db.QueryRow(`
+		DELETE FROM category_members
+		WHERE category_id = ?1 AND user_id = ?2
+		RETURNING user_id`,
+		categoryID,
+		userID,
+	).Scan(&userID)
+	return notFound(err, "share")
+}
+
+func (s *SQLiteStore) ShareCategoryWithGroup(categoryID, groupID string) error {
+	if err := s.db.QueryRow("SELECT id FROM groups WHERE id = ?1", groupID).Scan(&groupID); err != nil {
+		return notFound(err, "group")
+	}
+	return s.share(`
+		INSERT INTO category_groups (category_id, group_id, added_at)
+		SELECT id, ?2, ?3
+		FROM categories
+		WHERE id = ?1
+		ON CONFLICT DO NOTHING`,
+		categoryID, groupID)
+}
+
+func (s *SQLiteStore) UnshareCategoryWithGroup(categoryID, groupID string) error {
+	err := s.db.QueryRow(`
+		DELETE FROM category_groups
+		WHERE category_id = ?1 AND group_id = ?2
+		RETURNING group_id`,
+		categoryID,
+		groupID,
+	).Scan(&groupID)
+	return notFound(err, "share")
+}
+
+// share runs an insert that selects the category by ?1, telling an unknown
+// category, ErrNotFound, from one already shared, which is fine
+func (s *SQLiteStore) share(insert string, categoryID, with string) error {
+	res, err := s.db.Exec(insert, categoryID, with, s.clock.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err := s.db.QueryRow("SELECT id FROM categories WHERE id = ?1", categoryID).Scan(&categoryID)
+		return notFound(err, "category")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetHiddenCategoryIDs(userID string) ([]string, error) {
+	return s.categoryIDs(`
+		SELECT id
+		FROM categories c
+		WHERE (
+			EXISTS (SELECT 1 FROM category_members WHERE category_id = c.id)
+			OR EXISTS (SELECT 1 FROM category_groups WHERE category_id = c.id)
+		)
+		AND owner_id != ?1
+		AND NOT EXISTS (
+			SELECT 1 FROM category_members
+			WHERE category_id = c.id AND user_id = ?1
+		)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM category_groups cg
+			JOIN group_members gm ON gm.group_id = cg.group_id
+			WHERE cg.category_id = c.id AND gm.user_id = ?1
+		)`,
+		userID,
+	)
+}
+
+func (s *SQLiteStore) GetOthersCategoryIDs(userID string) ([]string, error) {
+	return s.categoryIDs(`
+		SELECT id
+		FROM categories c
+		WHERE owner_id NOT IN ('', ?1)
+		AND NOT EXISTS (
+			SELECT 1 FROM category_members
+			WHERE category_id = c.id AND user_id = ?1
+		)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM category_groups cg
+			JOIN group_members gm ON gm.group_id = cg.group_id
+			WHERE cg.category_id = c.id AND gm.user_id = ?1
+		)`,
+		userID,
+	)
+}
+
+func (s *SQLiteStore) GetOwningCategoryID(entityType, id string) (string, error) {
+	var query string
+	args := []any{id}
+	switch entityType {
+	case domain.EntityCategory:
+		query = "SELECT id FROM categories WHERE id = ?1"
+	case domain.EntityTask:
+		query = "SELECT category_id FROM tasks WHERE id = ?1"
+	case domain.EntitySubtask:
+		query = "SELECT category_id FROM subtasks WHERE id = ?1"
+	case domain.EntityWorkLog:
+		query = "SELECT category_id FROM work_logs WHERE id = ?1"
+	case domain.EntityRevision:
+		query = `
+			SELECT COALESCE(CASE r.entity_type
+				WHEN ?2 THEN r.entity_id
+				WHEN ?3 THEN (SELECT category_id FROM tasks WHERE id = r.entity_id)
+				WHEN ?4 THEN (SELECT category_id FROM subtasks WHERE id = r.entity_id)
+			END, '')
+			FROM revisions r
+			WHERE r.id = ?1`
+		args = append(args, domain.EntityCategory, domain.EntityTask, domain.EntitySubtask)
+	case domain.EntityAttachment:
+		query = "SELECT t.category_id FROM attachments a JOIN tasks t ON t.id = a.task_id WHERE a.id = ?1"
+	case domain.EntityLink:
+		query = "SELECT t.category_id FROM links l JOIN tasks t ON t.id = l.task_id WHERE l.id = ?1"
+	case domain.EntityCheck:
+		query = "SELECT t.category_id FROM task_checks c JOIN tasks t ON t.id = c.task_id WHERE c.id = ?1"
+	case domain.EntityNudge:
+		query = "SELECT t.category_id FROM nudges n JOIN tasks t ON t.id = n.task_id WHERE n.id = ?1"
+	case domain.EntityDoneCriterion:
+		query = "SELECT category_id FROM done_criteria WHERE id = ?1"
+	case domain.EntityAgingRule:
+		query = "SELECT category_id FROM aging_rules WHERE id = ?1"
+	case domain.EntityTimeBlock:
+		query = "SELECT t.category_id FROM time_blocks b JOIN tasks t ON t.id = b.task_id WHERE b.id = ?1"
+	case domain.EntitySyncPeer:
+		query = "SELECT category_id FROM sync_peers WHERE id = ?1"
+	default:
+		return "", fmt.Errorf("%w: unknown entity type %q", domain.ErrInvalid, entityType)
+	}
+	var categoryID string
+	err := s.db.QueryRow(query, args...).Scan(&categoryID)
+	return categoryID, notFound(err, entityType)
+}
+
+// categoryIDs runs a query selecting category IDs
+func (s *SQLiteStore) categoryIDs(query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
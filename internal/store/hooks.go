@@ -0,0 +1,190 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddHookToken(t *domain.HookToken) (*domain.HookToken, error) {
+	name, err := domain.CleanName(t.Name)
+	if err != nil {
+		return nil, err
+	}
+	if t.TokenHash == "" {
+		return nil, fmt.Errorf("%w: hook token has no hash", domain.ErrInvalid)
+	}
+	if t.CategoryID != "" {
+		var id string
+		err := s.db.QueryRow("SELECT id FROM categories WHERE id = ?1", t.CategoryID).Scan(&id)
+		if err != nil {
+			return nil, notFound(err, "category")
+		}
+	}
+
+	var id string
+	if err := s.db.QueryRow(`
+		INSERT INTO hook_tokens (
+			id,
+			user_id,
+			name,
+			category_id,
+			token_hash,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, NULLIF(?4, ''), ?5, ?6)
+		RETURNING id`,
+		s.ids.NewID(),
+		t.UserID,
+		name,
+		t.CategoryID,
+		t.TokenHash,
+		s.clock.Now().Unix(),
+	).Scan(&id); err != nil {
+		return nil, err
+	}
+	tokens, err := s.getHookTokens("id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	return tokens[0], nil
+}
+
+func (s *SQLiteStore) GetHookTokens(userID string) ([]*domain.HookToken, error) {
+	return s.getHookTokens("user_id = ?1", userID)
+}
+
+func (s *SQLiteStore) GetHookTokenByHash(hash string) (*domain.HookToken, error) {
+	var id string
+	err := s.db.QueryRow(`
+		UPDATE hook_tokens
+		SET last_used_at = ?1
+		WHERE token_hash = ?2
+		RETURNING id`,
+		s.clock.Now().Unix(),
+		hash,
+	).Scan(&id)
+	if err != nil {
+		return nil, notFound(err, "hook token")
+	}
+	tokens, err := s.getHookTokens("id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	return tokens[0], nil
+}
+
+func (s *SQLiteStore) DeleteHookToken(userID string, id string) error {
+	err := s.db.QueryRow(`
+		DELETE FROM hook_tokens
+		WHERE id = ?1 AND user_id = ?2
+		RETURNING id`,
+		id,
+		userID,
+	).Scan(&id)
+	return notFound(err, "hook token")
+}
+
+func (s *SQLiteStore) GetTaskEvents(kind string, since int64, limit int) ([]*domain.TaskEvent, error) {
+	order := "ASC"
+	if since == 0 {
+		order = "DESC"
+	}
+	rows, err := s.db.Query(`
+		SELECT
+			e.seq,
+			e.kind,
+			e.at,
+			t.id,
+			t.name,
+			c.id,
+			c.name,
+			t.completion
+		FROM task_events e
+		JOIN tasks t ON t.id = e.task_id
+		JOIN categories c ON c.id = t.category_id
+		WHERE e.kind = ?1 AND e.seq > ?2
+		ORDER BY e.seq `+order+`
+		LIMIT ?3`,
+		kind,
+		since,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.TaskEvent
+	for rows.Next() {
+		var e domain.TaskEvent
+		var at int64
+		if err := rows.Scan(
+			&e.Seq,
+			&e.Kind,
+			&at,
+			&e.TaskID,
+			&e.TaskName,
+			&e.CategoryID,
+			&e.CategoryName,
+			&e.Completion,
+		); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(at, 0).UTC()
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if since == 0 {
+		slices.Reverse(events)
+	}
+	return events, nil
+}
+
+func (s *SQLiteStore) getHookTokens(where string, args ...any) ([]*domain.HookToken, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			user_id,
+			name,
+			COALESCE(category_id, ''),
+			token_hash,
+			created_at,
+			last_used_at
+		FROM hook_tokens
+		WHERE `+where+`
+		ORDER BY created_at ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*domain.HookToken
+	for rows.Next() {
+		var t domain.HookToken
+		var createdAt, lastUsedAt int64
+		if err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.Name,
+			&t.CategoryID,
+			&t.TokenHash,
+			&createdAt,
+			&lastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = time.Unix(createdAt, 0).UTC()
+		if lastUsedAt != 0 {
+			t.LastUsedAt = time.Unix(lastUsedAt, 0).UTC()
+		}
+		tokens = append(tokens, &t)
+	}
+	return tokens, rows.Err()
+}
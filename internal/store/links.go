@@ -0,0 +1,205 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Links are read joined with their preview, so Title and HasFavicon come
+// along for free
+const linkColumns = `
+	l.id,
+	l.task_id,
+	l.kind,
+	l.url,
+	l.added_by,
+	l.created_at,
+	COALESCE(p.title, ''),
+	p.favicon IS NOT NULL`
+
+func (s *SQLiteStore) AddLink(l *domain.Link) (*domain.Link, error) {
+	if err := l.Normalize(); err != nil {
+		return nil, err
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO links (
+			id,
+			task_id,
+			kind,
+			url,
+			added_by,
+			created_at
+		)
+		SELECT ?1, id, ?2, ?3, ?4, ?5
+		FROM tasks
+		WHERE id = ?6
+		RETURNING id`,
+		s.ids.NewID(),
+		l.Kind,
+		l.URL,
+		l.AddedBy,
+		s.clock.Now().Unix(),
+		l.TaskID,
+	).Scan(&id)
+	if err != nil {
+		return nil, notFound(err, "task")
+	}
+	return s.GetLink(id)
+}
+
+func (s *SQLiteStore) GetLink(id string) (*domain.Link, error) {
+	links, err := s.getLinks("l.id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, notFound(sql.ErrNoRows, "link")
+	}
+	return links[0], nil
+}
+
+// DeleteLink removes a link and returns it. The cached preview stays, as
+// other links may share it.
+func (s *SQLiteStore) DeleteLink(id string) (*domain.Link, error) {
+	l, err := s.GetLink(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec("DELETE FROM links WHERE id = ?1", id); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *SQLiteStore) GetLinkPreview(url string) (*domain.LinkPreview, error) {
+	var p domain.LinkPreview
+	var fetchedAt int64
+	err := s.db.QueryRow(`
+		SELECT
+			url,
+			title,
+			favicon,
+			favicon_type,
+			fetched_at
+		FROM link_previews
+		WHERE url = ?1`,
+		url,
+	).Scan(
+		&p.URL,
+		&p.Title,
+		&p.Favicon,
+		&p.FaviconType,
+		&fetchedAt,
+	)
+	if err != nil {
+		return nil, notFound(err, "link preview")
+	}
+	p.FetchedAt = time.Unix(fetchedAt, 0).UTC()
+	return &p, nil
+}
+
+func (s *SQLiteStore) SaveLinkPreview(p *domain.LinkPreview) error {
+	// Page titles are not ours to reject, so trim rather than fail
+	title := []rune(p.Title)
+	if len(title) > domain.MaxNameLength {
+		title = title[:domain.MaxNameLength]
+	}
+	clean, err := domain.NormalizeName(string(title))
+	if err != nil {
+		return err
+	}
+	var favicon any
+	if len(p.Favicon) > 0 {
+		favicon = p.Favicon
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO link_previews (
+			url,
+			title,
+			favicon,
+			favicon_type,
+			fetched_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT(url) DO UPDATE SET
+			title = excluded.title,
+			favicon = excluded.favicon,
+			favicon_type = excluded.favicon_type,
+			fetched_at = excluded.fetched_at`,
+		p.URL,
+		clean,
+		favicon,
+		p.FaviconType,
+		p.FetchedAt.Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetStaleLinkURLs(before time.Time, limit int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT l.url
+		FROM links l
+		LEFT JOIN link_previews p ON p.url = l.url
+		WHERE p.fetched_at IS NULL OR p.fetched_at < ?1
+		ORDER BY COALESCE(p.fetched_at, 0) ASC
+		LIMIT ?2`,
+		before.Unix(),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// getLinks lists links matching where (over links l), oldest first
+func (s *SQLiteStore) getLinks(where string, args ...any) ([]*domain.Link, error) {
+	rows, err := s.db.Query(`
+		SELECT`+linkColumns+`
+		FROM links l
+		LEFT JOIN link_previews p ON p.url = l.url
+		WHERE `+where+`
+		ORDER BY l.created_at ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*domain.Link
+	for rows.Next() {
+		var l domain.Link
+		var createdAt int64
+		if err := rows.Scan(
+			&l.ID,
+			&l.TaskID,
+			&l.Kind,
+			&l.URL,
+			&l.AddedBy,
+			&createdAt,
+			&l.Title,
+			&l.HasFavicon,
+		); err != nil {
+			return nil, err
+		}
+		l.CreatedAt = time.Unix(createdAt, 0).UTC()
+		links = append(links, &l)
+	}
+	return links, rows.Err()
+}
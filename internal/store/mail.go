@@ -0,0 +1,116 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetEmailUsers() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT user_id
+		FROM preferences
+		WHERE email != ''
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) QueueMail(m *domain.Mail) error {
+	now := s.clock.Now()
+	next := m.NextAttemptAt
+	if next.IsZero() {
+		next = now
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO mail_queue (recipient, subject, text_body, html_body, next_attempt_at, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)`,
+		m.To,
+		m.Subject,
+		m.Text,
+		m.HTML,
+		next.Unix(),
+		now.Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetDueMail(now time.Time, limit int) ([]*domain.Mail, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			recipient,
+			subject,
+			text_body,
+			html_body,
+			attempts,
+			next_attempt_at,
+			last_error,
+			created_at
+		FROM mail_queue
+		WHERE next_attempt_at <= ?1
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?2`,
+		now.Unix(),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queued []*domain.Mail
+	for rows.Next() {
+		var m domain.Mail
+		var nextAt, createdAt int64
+		if err := rows.Scan(
+			&m.ID,
+			&m.To,
+			&m.Subject,
+			&m.Text,
+			&m.HTML,
+			&m.Attempts,
+			&nextAt,
+			&m.LastError,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		m.NextAttemptAt = time.Unix(nextAt, 0).UTC()
+		m.CreatedAt = time.Unix(createdAt, 0).UTC()
+		queued = append(queued, &m)
+	}
+	return queued, rows.Err()
+}
+
+func (s *SQLiteStore) RetryMail(id int64, next time.Time, lastErr string) error {
+	err := s.db.QueryRow(`
+		UPDATE mail_queue
+		SET attempts = attempts + 1,
+			next_attempt_at = ?2,
+			last_error = ?3
+		WHERE id = ?1
+		RETURNING id`,
+		id,
+		next.Unix(),
+		lastErr,
+	).Scan(&id)
+	return notFound(err, "mail")
+}
+
+func (s *SQLiteStore) DeleteMail(id int64) error {
+	_, err := s.db.Exec("DELETE FROM mail_queue WHERE id = ?1", id)
+	return err
+}
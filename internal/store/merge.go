@@ -0,0 +1,145 @@
+package store
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// MergeCategories moves everything in one category into another and removes
+// the emptied one. Its tasks follow the target's own, in their old order,
+// with their subtasks, work logs, and contexts. Its definition of done and
+// aging rules are added where the target has no match. Each moved task and
+// the merge itself are written to the audit log.
+func (s *SQLiteStore) MergeCategories(fromID, intoID, actor string) (*domain.Category, error) {
+	if fromID == intoID {
+		return nil, fmt.Errorf("%w: cannot merge a category into itself", domain.ErrInvalid)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var fromName, intoName string
+	if err := tx.QueryRow("SELECT name FROM categories WHERE id = ?1", fromID).Scan(&fromName); err != nil {
+		return nil, notFound(err, "category")
+	}
+	if err := tx.QueryRow("SELECT name FROM categories WHERE id = ?1", intoID).Scan(&intoName); err != nil {
+		return nil, notFound(err, "category")
+	}
+
+	rows, err := tx.Query(`
+		SELECT id
+		FROM tasks
+		WHERE category_id = ?1
+		ORDER BY sort_order, rowid`,
+		fromID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var taskIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taskIDs = append(taskIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Moved tasks go after the target's, which are renumbered from 0 too
+	var next int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM tasks
+		WHERE category_id = ?1`,
+		intoID,
+	).Scan(&next); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE tasks
+		SET sort_order = r.n
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY sort_order, rowid) - 1 AS n
+			FROM tasks
+			WHERE category_id = ?1) r
+		WHERE tasks.id = r.id`,
+		intoID,
+	); err != nil {
+		return nil, err
+	}
+	for i, id := range taskIDs {
+		if _, err := tx.Exec(`
+			UPDATE tasks
+			SET category_id = ?1, sort_order = ?2
+			WHERE id = ?3`,
+			intoID,
+			next+i,
+			id,
+		); err != nil {
+			return nil, err
+		}
+		if err := s.audit(tx, actor, "move", domain.EntityTask, id, fmt.Sprintf("merged from %s into %s", fromName, intoName)); err != nil {
+			return nil, err
+		}
+	}
+	for _, table := range []string{"subtasks", "work_logs"} {
+		// table is always one of the constants above
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET category_id = ?1 WHERE category_id = ?2", table), intoID, fromID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE done_criteria
+		SET category_id = ?1,
+			sort_order = sort_order + COALESCE((SELECT MAX(sort_order) + 1 FROM done_criteria WHERE category_id = ?1), 0)
+		WHERE category_id = ?2
+			AND text NOT IN (SELECT text FROM done_criteria WHERE category_id = ?1)`,
+		intoID,
+		fromID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE aging_rules
+		SET category_id = ?1
+		WHERE category_id = ?2
+			AND NOT EXISTS (
+				SELECT 1
+				FROM aging_rules a
+				WHERE a.category_id = ?1
+					AND a.days = aging_rules.days
+					AND a.action = aging_rules.action)`,
+		intoID,
+		fromID,
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?1", fromID); err != nil {
+		return nil, err
+	}
+	if err := refreshCategoryCompletion(tx, intoID); err != nil {
+		return nil, err
+	}
+	summary := fmt.Sprintf("merged %s (%d tasks) into %s", fromName, len(taskIDs), intoName)
+	if err := s.audit(tx, actor, "merge", domain.EntityCategory, intoID, summary); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, actor, "merge", domain.EntityCategory, fromID, summary); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetCategory(intoID)
+}
@@ -0,0 +1,791 @@
+package store
+
+import "fmt"
+
+// migrations change the base schema created in migrate. They run in order and
+// the database's user_version records how many have been applied, so entries
+// must never be edited or reordered once released; append new ones instead.
+var migrations = []string{
+	// 1: work log attribution
+	`ALTER TABLE work_logs ADD COLUMN author TEXT NOT NULL DEFAULT '';`,
+
+	// 2: locally configured display names
+	`ALTER TABLE preferences ADD COLUMN display_name TEXT NOT NULL DEFAULT '';`,
+
+	// 3: clear rows orphaned while foreign keys were only enabled on the
+	// first pooled connection
+	`DELETE FROM tasks
+		WHERE category_id NOT IN (SELECT id FROM categories);
+	DELETE FROM subtasks
+		WHERE task_id NOT IN (SELECT id FROM tasks)
+		OR category_id NOT IN (SELECT id FROM categories);
+	DELETE FROM work_logs
+		WHERE category_id NOT IN (SELECT id FROM categories)
+		OR task_id NOT IN (SELECT id FROM tasks)
+		OR (subtask_id IS NOT NULL AND subtask_id NOT IN (SELECT id FROM subtasks));`,
+
+	// 4: cached category roll-up, kept current by refreshCategoryCompletion
+	`ALTER TABLE categories ADD COLUMN completion INTEGER NOT NULL DEFAULT 0;
+	UPDATE categories
+		SET completion = COALESCE((
+			SELECT SUM(completion) / COUNT(*)
+			FROM tasks
+			WHERE category_id = categories.id
+		), 0);`,
+
+	// 5: per-user display timezone
+	`ALTER TABLE preferences ADD COLUMN timezone TEXT NOT NULL DEFAULT '';`,
+
+	// 6: soft delete via a trash of snapshots, and the audit log
+	`CREATE TABLE trash (
+		id TEXT PRIMARY KEY,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		category_id TEXT NOT NULL,
+		task_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		deleted_by TEXT NOT NULL DEFAULT '',
+		deleted_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_trash_deleted_at ON trash(deleted_at DESC);
+
+	CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		at INTEGER NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		summary TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);`,
+
+	// 7: revision history for categories, tasks, and subtasks
+	`CREATE TABLE revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		completion INTEGER NOT NULL DEFAULT 0,
+		public INTEGER NOT NULL DEFAULT 0,
+		author TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_revisions_entity ON revisions(entity_type, entity_id, id DESC);`,
+
+	// 8: work log adjustment entries
+	`ALTER TABLE work_logs ADD COLUMN corrects_id TEXT REFERENCES work_logs(id) ON DELETE CASCADE;
+	CREATE INDEX idx_work_logs_corrects ON work_logs(corrects_id);`,
+
+	// 9: daily board snapshots
+	`CREATE TABLE snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		day TEXT NOT NULL UNIQUE,
+		taken_at INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		board TEXT NOT NULL
+	);`,
+
+	// 10: file attachments on tasks and work logs
+	`CREATE TABLE attachments (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		work_log_id TEXT,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		has_thumbnail INTEGER NOT NULL DEFAULT 0,
+		uploaded_by TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY(work_log_id) REFERENCES work_logs(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_attachments_task ON attachments(task_id);
+	CREATE INDEX idx_attachments_work_log ON attachments(work_log_id);`,
+
+	// 11: typed links on tasks, with previews cached per URL
+	`CREATE TABLE links (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		url TEXT NOT NULL,
+		added_by TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_links_task ON links(task_id);
+	CREATE TABLE link_previews (
+		url TEXT PRIMARY KEY,
+		title TEXT NOT NULL DEFAULT '',
+		favicon BLOB,
+		favicon_type TEXT NOT NULL DEFAULT '',
+		fetched_at INTEGER NOT NULL
+	);`,
+
+	// 12: index of [[task:...]] references, for backlinks. The syntax is
+	// new, so there is nothing to backfill.
+	`CREATE TABLE task_refs (
+		source_type TEXT NOT NULL,
+		source_id TEXT NOT NULL,
+		target_id TEXT NOT NULL,
+		PRIMARY KEY(source_type, source_id, target_id)
+	);
+	CREATE INDEX idx_task_refs_target ON task_refs(target_id);`,
+
+	// 13: Web Push subscriptions
+	`CREATE TABLE push_subscriptions (
+		endpoint TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		p256dh TEXT NOT NULL,
+		auth TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_push_subscriptions_user ON push_subscriptions(user_id);`,
+	// 14: notification preferences and the digest queue
+	`ALTER TABLE preferences ADD COLUMN notifications TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE preferences ADD COLUMN digest_hour INTEGER NOT NULL DEFAULT 8;
+	CREATE TABLE notification_queue (
+		id INTEGER PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		title TEXT NOT NULL,
+		body TEXT NOT NULL,
+		url TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_notification_queue_user ON notification_queue(user_id, created_at);`,
+	// 15: nudges, standing reminders on tasks
+	`CREATE TABLE nudges (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		weekday INTEGER NOT NULL,
+		hour INTEGER NOT NULL,
+		minute INTEGER NOT NULL,
+		next_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_nudges_task ON nudges(task_id);
+	CREATE INDEX idx_nudges_next ON nudges(next_at);`,
+	// 16: in-app notification inbox
+	`CREATE TABLE notifications (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		title TEXT NOT NULL,
+		body TEXT NOT NULL,
+		url TEXT NOT NULL,
+		read INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_notifications_user ON notifications(user_id, created_at DESC);`,
+	// 17: secret hook URLs for shortcuts
+	`CREATE TABLE hook_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at INTEGER NOT NULL,
+		last_used_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX idx_hook_tokens_user ON hook_tokens(user_id);`,
+
+	// 18: personal focus queues of tasks from any category
+	`CREATE TABLE queue_items (
+		user_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		sort_order INTEGER NOT NULL,
+		added_at INTEGER NOT NULL,
+		PRIMARY KEY(user_id, task_id),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_queue_items_task ON queue_items(task_id);`,
+
+	// 19: time blocks for planning a day
+	`CREATE TABLE time_blocks (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		day TEXT NOT NULL,
+		start_minute INTEGER NOT NULL,
+		end_minute INTEGER NOT NULL,
+		work_log_id TEXT,
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY(work_log_id) REFERENCES work_logs(id) ON DELETE SET NULL
+	);
+	CREATE INDEX idx_time_blocks_user_day ON time_blocks(user_id, day);
+	CREATE INDEX idx_time_blocks_task ON time_blocks(task_id);
+	CREATE INDEX idx_time_blocks_work_log ON time_blocks(work_log_id);`,
+
+	// 20: weekly capacity and hours planned per task per week
+	`ALTER TABLE preferences ADD COLUMN weekly_capacity REAL NOT NULL DEFAULT 0;
+	CREATE TABLE allocations (
+		user_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		week TEXT NOT NULL,
+		hours REAL NOT NULL,
+		PRIMARY KEY(user_id, week, task_id),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_allocations_task ON allocations(task_id);`,
+
+	// 21: effort estimates and dependencies between tasks
+	`ALTER TABLE tasks ADD COLUMN estimate REAL NOT NULL DEFAULT 0;
+	CREATE TABLE task_dependencies (
+		task_id TEXT NOT NULL,
+		depends_on_id TEXT NOT NULL,
+		PRIMARY KEY(task_id, depends_on_id),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY(depends_on_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_task_dependencies_depends_on ON task_dependencies(depends_on_id);`,
+
+	// 22: start and due dates on tasks
+	`ALTER TABLE tasks ADD COLUMN start_date TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN due_date TEXT NOT NULL DEFAULT '';`,
+
+	// 23: quarterly objectives, their key results, and the tasks behind them
+	`CREATE TABLE objectives (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		quarter TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_objectives_quarter ON objectives(quarter);
+	CREATE TABLE key_results (
+		id TEXT PRIMARY KEY,
+		objective_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		target REAL NOT NULL DEFAULT 0,
+		current REAL NOT NULL DEFAULT 0,
+		unit TEXT NOT NULL DEFAULT '',
+		from_tasks INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(objective_id) REFERENCES objectives(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_key_results_objective ON key_results(objective_id);
+	CREATE TABLE key_result_tasks (
+		key_result_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		PRIMARY KEY(key_result_id, task_id),
+		FOREIGN KEY(key_result_id) REFERENCES key_results(id) ON DELETE CASCADE,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_key_result_tasks_task ON key_result_tasks(task_id);`,
+
+	// 24: subtask estimates, which weigh completion roll-ups. Tasks with
+	// subtasks take their average, as nothing is estimated yet, and
+	// categories are weighted by task estimate as in refreshCategoryCompletion.
+	`ALTER TABLE subtasks ADD COLUMN estimate REAL NOT NULL DEFAULT 0;
+	UPDATE tasks
+		SET completion = (
+			SELECT SUM(completion) / COUNT(*)
+			FROM subtasks
+			WHERE task_id = tasks.id
+		)
+		WHERE EXISTS (SELECT 1 FROM subtasks WHERE task_id = tasks.id);
+	UPDATE categories
+		SET completion = COALESCE((
+			SELECT CAST(SUM(completion * weight) / SUM(weight) + 1e-9 AS INTEGER)
+			FROM (
+				SELECT
+					completion,
+					CASE WHEN estimate > 0 THEN estimate ELSE (
+						SELECT COALESCE(AVG(estimate), 1)
+						FROM tasks
+						WHERE category_id = categories.id AND estimate > 0
+					) END AS weight
+				FROM tasks
+				WHERE category_id = categories.id
+			)
+		), 0);`,
+
+	// 25: category accent color and icon
+	`ALTER TABLE categories ADD COLUMN color TEXT NOT NULL DEFAULT '';
+	ALTER TABLE categories ADD COLUMN icon TEXT NOT NULL DEFAULT '';`,
+
+	// 26: definition-of-done checklists, per category and copied per task
+	`CREATE TABLE done_criteria (
+		id TEXT PRIMARY KEY,
+		category_id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		sort_order INTEGER NOT NULL,
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_done_criteria_category ON done_criteria(category_id, sort_order);
+	CREATE TABLE task_checks (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		checked INTEGER NOT NULL DEFAULT 0,
+		sort_order INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_task_checks_task ON task_checks(task_id, sort_order);`,
+
+	// 27: blocked tasks; blocked_at is 0 while the task is not blocked
+	`ALTER TABLE tasks ADD COLUMN blocked_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE tasks ADD COLUMN blocked_reason TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN waiting_on TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_tasks_blocked ON tasks(blocked_at) WHERE blocked_at > 0;`,
+
+	// 28: work-in-progress limit per category; 0 means no limit
+	`ALTER TABLE categories ADD COLUMN wip_limit INTEGER NOT NULL DEFAULT 0;`,
+
+	// 29: GTD-style contexts on tasks, and the one each user is working in
+	`CREATE TABLE task_contexts (
+		task_id TEXT NOT NULL,
+		context TEXT NOT NULL,
+		PRIMARY KEY (task_id, context),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_task_contexts_context ON task_contexts(context);
+	ALTER TABLE preferences ADD COLUMN context TEXT NOT NULL DEFAULT '';`,
+
+	// 30: task size labels
+	`ALTER TABLE tasks ADD COLUMN size TEXT NOT NULL DEFAULT '';`,
+
+	// 31: task priorities and flags, and aging rules that set them. Tasks
+	// made before now count as made now, so rules don't fire on them all at
+	// once. Aging runs record when a rule last acted on a task.
+	`ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE tasks ADD COLUMN flag TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+	UPDATE tasks SET created_at = CAST(strftime('%s', 'now') AS INTEGER);
+	CREATE TABLE aging_rules (
+		id TEXT PRIMARY KEY,
+		category_id TEXT NOT NULL,
+		days INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_aging_rules_category ON aging_rules(category_id);
+	CREATE TABLE aging_runs (
+		rule_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		at INTEGER NOT NULL,
+		PRIMARY KEY (rule_id, task_id),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);`,
+
+	// 32: trigram search index over task names, for spotting duplicates.
+	// Triggers keep it in step with every write to tasks.
+	`CREATE VIRTUAL TABLE task_names USING fts5(
+		name,
+		content = 'tasks',
+		content_rowid = 'rowid',
+		tokenize = 'trigram'
+	);
+	CREATE TRIGGER task_names_insert AFTER INSERT ON tasks BEGIN
+		INSERT INTO task_names (rowid, name) VALUES (new.rowid, new.name);
+	END;
+	CREATE TRIGGER task_names_delete AFTER DELETE ON tasks BEGIN
+		INSERT INTO task_names (task_names, rowid, name) VALUES ('delete', old.rowid, old.name);
+	END;
+	CREATE TRIGGER task_names_update AFTER UPDATE OF name ON tasks BEGIN
+		INSERT INTO task_names (task_names, rowid, name) VALUES ('delete', old.rowid, old.name);
+		INSERT INTO task_names (rowid, name) VALUES (new.rowid, new.name);
+	END;
+	INSERT INTO task_names (task_names) VALUES ('rebuild');`,
+
+	// 33: board sync. sync_state is what each entity on a synced board
+	// was last seen as; sync_changes logs each change to it, for peers to
+	// read from where they left off.
+	`CREATE TABLE sync_peers (
+		id TEXT PRIMARY KEY,
+		category_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL DEFAULT '',
+		secret TEXT NOT NULL,
+		pushed_seq INTEGER NOT NULL DEFAULT 0,
+		pulled_seq INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		synced_at INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_sync_peers_category ON sync_peers(category_id);
+	CREATE TABLE sync_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		category_id TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		deleted INTEGER NOT NULL DEFAULT 0,
+		data TEXT NOT NULL DEFAULT '',
+		changed_at INTEGER NOT NULL,
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_sync_changes_category ON sync_changes(category_id, seq);
+	CREATE TABLE sync_state (
+		category_id TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		changed_at INTEGER NOT NULL,
+		PRIMARY KEY (category_id, entity_type, entity_id),
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);`,
+
+	// 34: where tasks and subtasks imported from a markdown vault came
+	// from, so importing again updates them instead of adding copies
+	`ALTER TABLE tasks ADD COLUMN source TEXT NOT NULL DEFAULT '';
+	ALTER TABLE subtasks ADD COLUMN source TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_tasks_source ON tasks(category_id, source) WHERE source != '';`,
+
+	// 35: task events for automations to poll. Triggers catch every way a
+	// task is added or reaches 100%, including through its subtasks.
+	`CREATE TABLE task_events (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_task_events_kind ON task_events(kind, seq);
+	CREATE INDEX idx_task_events_task ON task_events(task_id);
+	CREATE TRIGGER task_events_added AFTER INSERT ON tasks BEGIN
+		INSERT INTO task_events (task_id, kind, at) VALUES (new.id, 'added', unixepoch());
+	END;
+	CREATE TRIGGER task_events_completed AFTER UPDATE OF completion ON tasks
+	WHEN new.completion >= 100 AND old.completion < 100 BEGIN
+		INSERT INTO task_events (task_id, kind, at) VALUES (new.id, 'completed', unixepoch());
+	END;`,
+
+	// 36: stats links. Their tokens are published by design, so they are
+	// kept as they are, to be shown again.
+	`CREATE TABLE stats_links (
+		user_id TEXT PRIMARY KEY,
+		token TEXT NOT NULL UNIQUE,
+		created_at INTEGER NOT NULL
+	);`,
+
+	// 37: share tokens for embedding a category's progress elsewhere
+	`ALTER TABLE categories ADD COLUMN share_token TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX idx_categories_share_token ON categories(share_token) WHERE share_token != '';`,
+	// 38: ntfy and Gotify settings, one of each per user
+	`CREATE TABLE notify_services (
+		user_id TEXT NOT NULL,
+		service TEXT NOT NULL,
+		url TEXT NOT NULL,
+		topic TEXT NOT NULL DEFAULT '',
+		token TEXT NOT NULL DEFAULT '',
+		app_url TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (user_id, service)
+	);`,
+	// 39: email addresses for notifications, and the outgoing mail queue
+	`ALTER TABLE preferences ADD COLUMN email TEXT NOT NULL DEFAULT '';
+	CREATE TABLE mail_queue (
+		id INTEGER PRIMARY KEY,
+		recipient TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		text_body TEXT NOT NULL,
+		html_body TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_mail_queue_next ON mail_queue(next_attempt_at);`,
+
+	// 40: commits reported by users' git hooks, to suggest work logs from
+	`CREATE TABLE commit_events (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		task_id TEXT,
+		repo TEXT NOT NULL,
+		branch TEXT NOT NULL,
+		sha TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL,
+		committed_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		settled INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE SET NULL
+	);
+	CREATE INDEX idx_commit_events_user ON commit_events(user_id, committed_at);
+	CREATE INDEX idx_commit_events_task ON commit_events(task_id);
+	CREATE UNIQUE INDEX idx_commit_events_sha ON commit_events(user_id, sha) WHERE sha != '';`,
+
+	// 41: webhooks that turn monitoring alerts into tasks in a category
+	`ALTER TABLE categories ADD COLUMN alert_token_hash TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX idx_categories_alert_token ON categories(alert_token_hash) WHERE alert_token_hash != '';`,
+
+	// 42: the category pages clipped from the browser go to
+	`ALTER TABLE preferences ADD COLUMN clip_category TEXT NOT NULL DEFAULT '';`,
+
+	// 43: accounts managed by an identity provider, which can turn them off
+	// and share categories with them as they are created
+	`ALTER TABLE preferences ADD COLUMN deactivated_at INTEGER;
+
+	CREATE TABLE category_members (
+		category_id TEXT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL,
+		added_at INTEGER NOT NULL,
+		PRIMARY KEY (category_id, user_id)
+	);
+	CREATE INDEX idx_category_members_user ON category_members(user_id);`,
+
+	// 44: groups of users that categories can be shared with
+	`CREATE TABLE groups (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE group_members (
+		group_id TEXT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL,
+		added_at INTEGER NOT NULL,
+		PRIMARY KEY (group_id, user_id)
+	);
+	CREATE INDEX idx_group_members_user ON group_members(user_id);
+	CREATE TABLE category_groups (
+		category_id TEXT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+		group_id TEXT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+		added_at INTEGER NOT NULL,
+		PRIMARY KEY (category_id, group_id)
+	);
+	CREATE INDEX idx_category_groups_group ON category_groups(group_id);`,
+
+	// 45: hook tokens scoped to one category, which go with it
+	`ALTER TABLE hook_tokens ADD COLUMN category_id TEXT REFERENCES categories(id) ON DELETE CASCADE;`,
+
+	// 46: the language dates and numbers are written for
+	`ALTER TABLE preferences ADD COLUMN language TEXT NOT NULL DEFAULT '';`,
+
+	// 47: a log of every change to the board, for clients long-polling to
+	// stay current. It keeps no foreign keys, so deletions are logged too.
+	`CREATE TABLE board_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		category_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_board_changes_at ON board_changes(at);
+	CREATE TRIGGER board_changes_categories_added AFTER INSERT ON categories BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('category', new.id, new.id, 'added', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_categories_changed AFTER UPDATE ON categories BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('category', new.id, new.id, 'changed', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_categories_deleted AFTER DELETE ON categories BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('category', old.id, old.id, 'deleted', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_tasks_added AFTER INSERT ON tasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('task', new.id, new.category_id, 'added', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_tasks_changed AFTER UPDATE ON tasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('task', new.id, new.category_id, 'changed', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_tasks_deleted AFTER DELETE ON tasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('task', old.id, old.category_id, 'deleted', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_subtasks_added AFTER INSERT ON subtasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('subtask', new.id, new.category_id, 'added', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_subtasks_changed AFTER UPDATE ON subtasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('subtask', new.id, new.category_id, 'changed', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_subtasks_deleted AFTER DELETE ON subtasks BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('subtask', old.id, old.category_id, 'deleted', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_work_logs_added AFTER INSERT ON work_logs BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('work_log', new.id, new.category_id, 'added', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_work_logs_changed AFTER UPDATE ON work_logs BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('work_log', new.id, new.category_id, 'changed', unixepoch());
+	END;
+	CREATE TRIGGER board_changes_work_logs_deleted AFTER DELETE ON work_logs BEGIN
+		INSERT INTO board_changes (entity_type, entity_id, category_id, kind, at)
+		VALUES ('work_log', old.id, old.category_id, 'deleted', unixepoch());
+	END;`,
+
+	// 48: who a category belongs to, which its tasks, subtasks, and work
+	// logs go with. Categories from before are nobody's.
+	`ALTER TABLE categories ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_categories_owner ON categories(owner_id);`,
+
+	// 49: deadlines for subtasks, as tasks have
+	`ALTER TABLE subtasks ADD COLUMN due_date TEXT NOT NULL DEFAULT '';`,
+
+	// 50: weekly digests of a category's activity, and the start of the
+	// last week one was written for
+	`ALTER TABLE categories ADD COLUMN weekly_digest INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE categories ADD COLUMN digest_week TEXT NOT NULL DEFAULT '';`,
+
+	// 51: full-text search over the names and text of categories, tasks,
+	// subtasks, and work logs. Triggers keep it in step with every write.
+	`CREATE VIRTUAL TABLE search_index USING fts5(
+		entity_type UNINDEXED,
+		entity_id UNINDEXED,
+		name,
+		body,
+		tokenize = 'porter unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER search_categories_insert AFTER INSERT ON categories BEGIN
+		INSERT INTO search_index (entity_type, entity_id, name, body) VALUES ('category', new.id, new.name, new.description);
+	END;
+	CREATE TRIGGER search_categories_update AFTER UPDATE OF name, description ON categories BEGIN
+		UPDATE search_index SET name = new.name, body = new.description WHERE entity_type = 'category' AND entity_id = new.id;
+	END;
+	CREATE TRIGGER search_categories_delete AFTER DELETE ON categories BEGIN
+		DELETE FROM search_index WHERE entity_type = 'category' AND entity_id = old.id;
+	END;
+	INSERT INTO search_index (entity_type, entity_id, name, body) SELECT 'category', id, name, description FROM categories;
+	CREATE TRIGGER search_tasks_insert AFTER INSERT ON tasks BEGIN
+		INSERT INTO search_index (entity_type, entity_id, name, body) VALUES ('task', new.id, new.name, new.description);
+	END;
+	CREATE TRIGGER search_tasks_update AFTER UPDATE OF name, description ON tasks BEGIN
+		UPDATE search_index SET name = new.name, body = new.description WHERE entity_type = 'task' AND entity_id = new.id;
+	END;
+	CREATE TRIGGER search_tasks_delete AFTER DELETE ON tasks BEGIN
+		DELETE FROM search_index WHERE entity_type = 'task' AND entity_id = old.id;
+	END;
+	INSERT INTO search_index (entity_type, entity_id, name, body) SELECT 'task', id, name, description FROM tasks;
+	CREATE TRIGGER search_subtasks_insert AFTER INSERT ON subtasks BEGIN
+		INSERT INTO search_index (entity_type, entity_id, name, body) VALUES ('subtask', new.id, new.name, new.description);
+	END;
+	CREATE TRIGGER search_subtasks_update AFTER UPDATE OF name, description ON subtasks BEGIN
+		UPDATE search_index SET name = new.name, body = new.description WHERE entity_type = 'subtask' AND entity_id = new.id;
+	END;
+	CREATE TRIGGER search_subtasks_delete AFTER DELETE ON subtasks BEGIN
+		DELETE FROM search_index WHERE entity_type = 'subtask' AND entity_id = old.id;
+	END;
+	INSERT INTO search_index (entity_type, entity_id, name, body) SELECT 'subtask', id, name, description FROM subtasks;
+	CREATE TRIGGER search_work_logs_insert AFTER INSERT ON work_logs BEGIN
+		INSERT INTO search_index (entity_type, entity_id, name, body) VALUES ('work_log', new.id, '', new.work_description);
+	END;
+	CREATE TRIGGER search_work_logs_update AFTER UPDATE OF work_description ON work_logs BEGIN
+		UPDATE search_index SET body = new.work_description WHERE entity_type = 'work_log' AND entity_id = new.id;
+	END;
+	CREATE TRIGGER search_work_logs_delete AFTER DELETE ON work_logs BEGIN
+		DELETE FROM search_index WHERE entity_type = 'work_log' AND entity_id = old.id;
+	END;
+	INSERT INTO search_index (entity_type, entity_id, name, body) SELECT 'work_log', id, '', work_description FROM work_logs;`,
+
+	// 52: subtasks that are only done or not
+	`ALTER TABLE tasks ADD COLUMN subtask_checkboxes INTEGER NOT NULL DEFAULT 0;`,
+
+	// 53: work timers, paused when the browser stops checking in
+	`CREATE TABLE timers (
+		user_id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL,
+		elapsed INTEGER NOT NULL DEFAULT 0,
+		running_since INTEGER,
+		last_seen INTEGER NOT NULL,
+		idle_paused INTEGER NOT NULL DEFAULT 0,
+		started_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_timers_task ON timers(task_id);
+	ALTER TABLE preferences ADD COLUMN idle_minutes INTEGER NOT NULL DEFAULT 15;`,
+
+	// 54: several named timers at once, for users who ask for them
+	`ALTER TABLE timers RENAME TO single_timers;
+	CREATE TABLE timers (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		task_id TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		elapsed INTEGER NOT NULL DEFAULT 0,
+		running_since INTEGER,
+		last_seen INTEGER NOT NULL,
+		idle_paused INTEGER NOT NULL DEFAULT 0,
+		started_at INTEGER NOT NULL,
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);
+	INSERT INTO timers (id, user_id, task_id, elapsed, running_since, last_seen, idle_paused, started_at)
+	SELECT lower(hex(randomblob(16))), user_id, task_id, elapsed, running_since, last_seen, idle_paused, started_at
+	FROM single_timers;
+	DROP TABLE single_timers;
+	CREATE INDEX idx_timers_user ON timers(user_id, started_at);
+	CREATE INDEX idx_timers_task ON timers(task_id);
+	ALTER TABLE preferences ADD COLUMN multiple_timers INTEGER NOT NULL DEFAULT 0;`,
+
+	// 55: tags, labels for tasks that cut across categories
+	`CREATE TABLE tags (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		color TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE task_tags (
+		task_id TEXT NOT NULL,
+		tag_id TEXT NOT NULL,
+		PRIMARY KEY (task_id, tag_id),
+		FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX idx_task_tags_tag ON task_tags(tag_id);`,
+
+	// 56: the history of re-forecast task estimates
+	`CREATE TABLE estimate_changes (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		previous REAL NOT NULL,
+		estimate REAL NOT NULL,
+		hours_logged REAL NOT NULL,
+		author TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_estimate_changes_task ON estimate_changes(task_id, created_at);`,
+
+	// 57: finishing tasks by review, per category, and the history of reviews
+	`ALTER TABLE categories ADD COLUMN requires_review INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE tasks ADD COLUMN review TEXT NOT NULL DEFAULT '';
+	CREATE TABLE review_transitions (
+		id TEXT PRIMARY KEY,
+		task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		from_state TEXT NOT NULL,
+		to_state TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_review_transitions_task ON review_transitions(task_id, created_at);`,
+}
+
+func (s *SQLiteStore) applyMigrations() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version;").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d;", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
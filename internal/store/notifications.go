@@ -0,0 +1,227 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) QueueNotification(n *domain.QueuedNotification) error {
+	_, err := s.db.Exec(`
+		INSERT INTO notification_queue (
+			user_id,
+			channel,
+			kind,
+			title,
+			body,
+			url,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+		n.UserID,
+		n.Channel,
+		n.Kind,
+		n.Title,
+		n.Body,
+		n.URL,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) TakeQueuedNotifications(userID string, before time.Time) ([]*domain.QueuedNotification, error) {
+	rows, err := s.db.Query(`
+		DELETE FROM notification_queue
+		WHERE user_id = ?1 AND created_at < ?2
+		RETURNING
+			id,
+			user_id,
+			channel,
+			kind,
+			title,
+			body,
+			url,
+			created_at`,
+		userID,
+		before.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queued []*domain.QueuedNotification
+	for rows.Next() {
+		var n domain.QueuedNotification
+		var createdAt int64
+		if err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.Channel,
+			&n.Kind,
+			&n.Title,
+			&n.Body,
+			&n.URL,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = time.Unix(createdAt, 0).UTC()
+		queued = append(queued, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not promise any order
+	slices.SortFunc(queued, func(a, b *domain.QueuedNotification) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return queued, nil
+}
+
+func (s *SQLiteStore) GetDigestRecipients() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT user_id
+		FROM notification_queue
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) AddNotification(n *domain.Notification) error {
+	_, err := s.db.Exec(`
+		INSERT INTO notifications (
+			id,
+			user_id,
+			kind,
+			title,
+			body,
+			url,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+		s.ids.NewID(),
+		n.UserID,
+		n.Kind,
+		n.Title,
+		n.Body,
+		n.URL,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetNotifications(userID string, limit int) ([]*domain.Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			user_id,
+			kind,
+			title,
+			body,
+			url,
+			read,
+			created_at
+		FROM notifications
+		WHERE user_id = ?1
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ?2`,
+		userID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		var n domain.Notification
+		var createdAt int64
+		if err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.Kind,
+			&n.Title,
+			&n.Body,
+			&n.URL,
+			&n.Read,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = time.Unix(createdAt, 0).UTC()
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *SQLiteStore) CountUnreadNotifications(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = ?1 AND read = 0`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStore) MarkNotificationRead(userID string, id string) error {
+	err := s.db.QueryRow(`
+		UPDATE notifications
+		SET read = 1
+		WHERE id = ?1 AND user_id = ?2
+		RETURNING id`,
+		id,
+		userID,
+	).Scan(&id)
+	return notFound(err, "notification")
+}
+
+func (s *SQLiteStore) MarkAllNotificationsRead(userID string) error {
+	_, err := s.db.Exec(`
+		UPDATE notifications
+		SET read = 1
+		WHERE user_id = ?1 AND read = 0`,
+		userID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetUsers() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT user_id FROM preferences
+		UNION
+		SELECT author FROM work_logs WHERE author != ''
+		ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddNudge(n *domain.Nudge) (*domain.Nudge, error) {
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO nudges (
+			id,
+			task_id,
+			user_id,
+			weekday,
+			hour,
+			minute,
+			next_at,
+			created_at
+		)
+		SELECT ?1, id, ?2, ?3, ?4, ?5, ?6, ?7
+		FROM tasks
+		WHERE id = ?8
+		RETURNING id`,
+		s.ids.NewID(),
+		n.UserID,
+		n.Weekday,
+		n.Hour,
+		n.Minute,
+		n.NextAt.Unix(),
+		s.clock.Now().Unix(),
+		n.TaskID,
+	).Scan(&id)
+	if err != nil {
+		return nil, notFound(err, "task")
+	}
+	return s.GetNudge(id)
+}
+
+func (s *SQLiteStore) GetNudge(id string) (*domain.Nudge, error) {
+	nudges, err := s.getNudges("id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(nudges) == 0 {
+		return nil, notFound(sql.ErrNoRows, "nudge")
+	}
+	return nudges[0], nil
+}
+
+func (s *SQLiteStore) DeleteNudge(id string) error {
+	_, err := s.db.Exec("DELETE FROM nudges WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) GetDueNudges(now time.Time) ([]*domain.Nudge, error) {
+	return s.getNudges("next_at <= ?1", now.Unix())
+}
+
+func (s *SQLiteStore) AdvanceNudge(id string, next time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE nudges
+		SET next_at = ?1
+		WHERE id = ?2`,
+		next.Unix(),
+		id,
+	)
+	return err
+}
+
+func (s *SQLiteStore) getNudges(where string, args ...any) ([]*domain.Nudge, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			task_id,
+			user_id,
+			weekday,
+			hour,
+			minute,
+			next_at,
+			created_at
+		FROM nudges
+		WHERE `+where+`
+		ORDER BY next_at ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nudges []*domain.Nudge
+	for rows.Next() {
+		var n domain.Nudge
+		var nextAt, createdAt int64
+		if err := rows.Scan(
+			&n.ID,
+			&n.TaskID,
+			&n.UserID,
+			&n.Weekday,
+			&n.Hour,
+			&n.Minute,
+			&nextAt,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		n.NextAt = time.Unix(nextAt, 0).UTC()
+		n.CreatedAt = time.Unix(createdAt, 0).UTC()
+		nudges = append(nudges, &n)
+	}
+	return nudges, rows.Err()
+}
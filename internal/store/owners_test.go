@@ -0,0 +1,63 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Every way categories get onto a board records whose they are, so that on
+// private boards they stay with that user
+
+func TestSeedBelongsToWhoAskedForIt(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Seed("ana"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "categories", "owner_id != 'ana'"); n != 0 {
+		t.Errorf("%d sample categories don't belong to ana", n)
+	}
+	hidden, err := s.GetOthersCategoryIDs("bea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "categories", "1"); len(hidden) != n {
+		t.Errorf("bea would see %d of ana's %d sample categories", n-len(hidden), n)
+	}
+}
+
+func TestImportBelongsToTheImporter(t *testing.T) {
+	s, _ := newTestStore(t)
+	addBoard(t, s, "Garden")
+
+	ws := &domain.Workspace{
+		Version:    domain.WorkspaceVersion,
+		Categories: []*domain.WorkspaceCategory{{Name: "Kitchen", Tasks: []*domain.WorkspaceTask{{Name: "Descale the kettle"}}}},
+	}
+	if err := s.ImportWorkspace(ws, "bea"); err != nil {
+		t.Fatalf("importing beside another user's board: %v", err)
+	}
+	if n := count(t, s, "categories", "owner_id = 'bea'"); n != 1 {
+		t.Errorf("%d imported categories belong to bea, want 1", n)
+	}
+
+	again := &domain.Workspace{
+		Version:    domain.WorkspaceVersion,
+		Categories: []*domain.WorkspaceCategory{{Name: "Shed"}},
+	}
+	if err := s.ImportWorkspace(again, "bea"); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("importing over bea's own board: got %v, want ErrConflict", err)
+	}
+}
+
+func TestJoinedBoardBelongsToWhoJoinedIt(t *testing.T) {
+	s, _ := newTestStore(t)
+	board := "00000000-0000-4000-8000-00000000beef"
+	if _, err := s.AddSyncPeer(&domain.SyncPeer{CategoryID: board, Name: "Allotment", Secret: "shh"}, "bea"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "categories", "id = ?1 AND owner_id = 'bea'", board); n != 1 {
+		t.Errorf("the joined board doesn't belong to bea")
+	}
+}
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetTimeBlocks(userID string, day string) ([]*domain.TimeBlock, error) {
+	return getTimeBlocks(s.db, "b.user_id = ?1 AND b.day = ?2", userID, day)
+}
+
+func (s *SQLiteStore) GetTimeBlock(id string) (*domain.TimeBlock, error) {
+	blocks, err := getTimeBlocks(s.db, "b.id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, notFound(sql.ErrNoRows, "time block")
+	}
+	return blocks[0], nil
+}
+
+func (s *SQLiteStore) AddTimeBlock(b *domain.TimeBlock) (*domain.TimeBlock, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkOverlap(tx, b.UserID, b.Day, b.Start, b.End, ""); err != nil {
+		return nil, err
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	var id string
+	err = tx.QueryRow(`
+		INSERT INTO time_blocks (
+			id,
+			user_id,
+			task_id,
+			day,
+			start_minute,
+			end_minute,
+			created_at
+		)
+		SELECT ?1, ?2, id, ?3, ?4, ?5, ?6
+		FROM tasks
+		WHERE id = ?7
+		RETURNING id`,
+		s.ids.NewID(),
+		b.UserID,
+		b.Day,
+		b.Start,
+		b.End,
+		s.clock.Now().Unix(),
+		b.TaskID,
+	).Scan(&id)
+	if err != nil {
+		return nil, notFound(err, "task")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetTimeBlock(id)
+}
+
+func (s *SQLiteStore) MoveTimeBlock(id string, start, end int) (*domain.TimeBlock, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blocks, err := getTimeBlocks(tx, "b.id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, notFound(sql.ErrNoRows, "time block")
+	}
+	b := blocks[0]
+	if b.WorkLogID != "" {
+		return nil, fmt.Errorf("%w: the block has already been logged as work", domain.ErrConflict)
+	}
+
+	b.Start, b.End = start, end
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkOverlap(tx, b.UserID, b.Day, b.Start, b.End, b.ID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE time_blocks
+		SET start_minute = ?1, end_minute = ?2
+		WHERE id = ?3`,
+		b.Start,
+		b.End,
+		b.ID,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *SQLiteStore) DeleteTimeBlock(id string) error {
+	_, err := s.db.Exec("DELETE FROM time_blocks WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) SetTimeBlockWorkLog(id string, workLogID string) error {
+	_, err := s.db.Exec(`
+		UPDATE time_blocks
+		SET work_log_id = ?1
+		WHERE id = ?2`,
+		workLogID,
+		id,
+	)
+	return err
+}
+
+// checkOverlap returns ErrConflict if the user already has a block on day
+// that overlaps start to end, other than the block being moved
+func checkOverlap(tx *sql.Tx, userID, day string, start, end int, exceptID string) error {
+	var name string
+	var otherStart, otherEnd int
+	err := tx.QueryRow(`
+		SELECT t.name, b.start_minute, b.end_minute
+		FROM time_blocks b
+		JOIN tasks t ON b.task_id = t.id
+		WHERE b.user_id = ?1
+			AND b.day = ?2
+			AND b.start_minute < ?4
+			AND b.end_minute > ?3
+			AND b.id != ?5
+		ORDER BY b.start_minute
+		LIMIT 1`,
+		userID,
+		day,
+		start,
+		end,
+		exceptID,
+	).Scan(&name, &otherStart, &otherEnd)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: overlaps %q from %s to %s", domain.ErrConflict, name, minutesClock(otherStart), minutesClock(otherEnd))
+}
+
+// minutesClock writes minutes past midnight as HH:MM
+func minutesClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func getTimeBlocks(q querier, where string, args ...any) ([]*domain.TimeBlock, error) {
+	rows, err := q.Query(`
+		SELECT
+			b.id,
+			b.user_id,
+			b.task_id,
+			t.name,
+			b.day,
+			b.start_minute,
+			b.end_minute,
+			COALESCE(b.work_log_id, ''),
+			b.created_at
+		FROM time_blocks b
+		JOIN tasks t ON b.task_id = t.id
+		WHERE `+where+`
+		ORDER BY b.start_minute ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []*domain.TimeBlock
+	for rows.Next() {
+		var b domain.TimeBlock
+		var createdAt int64
+		if err := rows.Scan(
+			&b.ID,
+			&b.UserID,
+			&b.TaskID,
+			&b.TaskName,
+			&b.Day,
+			&b.Start,
+			&b.End,
+			&b.WorkLogID,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = time.Unix(createdAt, 0).UTC()
+		blocks = append(blocks, &b)
+	}
+	return blocks, rows.Err()
+}
+
+func (s *SQLiteStore) GetAllocations(userID string, week string, from, to time.Time) ([]*domain.Allocation, error) {
+	rows, err := s.db.Query(`
+		WITH logged AS (
+			SELECT task_id, SUM(hours_worked) AS hours
+			FROM work_logs
+			WHERE author = ?1
+				AND created_at >= ?3
+				AND created_at < ?4
+			GROUP BY task_id
+		)
+		SELECT
+			t.id,
+			t.name,
+			c.name,
+			COALESCE(a.hours, 0),
+			COALESCE(l.hours, 0)
+		FROM tasks t
+		JOIN categories c ON t.category_id = c.id
+		LEFT JOIN allocations a ON a.task_id = t.id AND a.user_id = ?1 AND a.week = ?2
+		LEFT JOIN logged l ON l.task_id = t.id
+		WHERE a.task_id IS NOT NULL OR l.task_id IS NOT NULL
+		ORDER BY COALESCE(a.hours, 0) DESC, t.name`,
+		userID,
+		week,
+		from.Unix(),
+		to.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []*domain.Allocation
+	for rows.Next() {
+		a := domain.Allocation{Week: week}
+		if err := rows.Scan(
+			&a.TaskID,
+			&a.TaskName,
+			&a.CategoryName,
+			&a.Planned,
+			&a.Logged,
+		); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, &a)
+	}
+	return allocations, rows.Err()
+}
+
+func (s *SQLiteStore) SetAllocation(userID string, taskID string, week string, hours float64) error {
+	if _, err := time.Parse(time.DateOnly, week); err != nil {
+		return fmt.Errorf("%w: week must be written as YYYY-MM-DD", domain.ErrInvalid)
+	}
+	if hours < 0 || hours > 168 {
+		return fmt.Errorf("%w: planned hours must be between 0 and 168", domain.ErrInvalid)
+	}
+
+	if hours == 0 {
+		_, err := s.db.Exec(`
+			DELETE FROM allocations
+			WHERE user_id = ?1 AND task_id = ?2 AND week = ?3`,
+			userID,
+			taskID,
+			week,
+		)
+		return err
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO allocations (user_id, task_id, week, hours)
+		SELECT ?1, id, ?2, ?3
+		FROM tasks
+		WHERE id = ?4
+		ON CONFLICT (user_id, week, task_id) DO UPDATE SET hours = excluded.hours
+		RETURNING task_id`,
+		userID,
+		week,
+		hours,
+		taskID,
+	).Scan(&id)
+	return notFound(err, "task")
+}
+
+func (s *SQLiteStore) GetWorkloads(from, to string) ([]*domain.Workload, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			a.week,
+			a.user_id,
+			COALESCE(p.weekly_capacity, 0),
+			t.id,
+			t.name,
+			c.id,
+			c.name,
+			a.hours,
+			t.estimate * (100 - t.completion) / 100.0
+		FROM allocations a
+		JOIN tasks t ON t.id = a.task_id
+		JOIN categories c ON c.id = t.category_id
+		LEFT JOIN preferences p ON p.user_id = a.user_id
+		WHERE a.week >= ?1 AND a.week < ?2 AND t.completion < 100
+		ORDER BY a.week, a.user_id, a.hours DESC, t.name`,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workloads []*domain.Workload
+	for rows.Next() {
+		var week, userID string
+		var capacity float64
+		var t domain.PlannedTask
+		if err := rows.Scan(
+			&week,
+			&userID,
+			&capacity,
+			&t.TaskID,
+			&t.TaskName,
+			&t.CategoryID,
+			&t.CategoryName,
+			&t.Planned,
+			&t.Remaining,
+		); err != nil {
+			return nil, err
+		}
+		if n := len(workloads); n == 0 || workloads[n-1].Week != week || workloads[n-1].UserID != userID {
+			workloads = append(workloads, &domain.Workload{UserID: userID, Week: week, Capacity: capacity})
+		}
+		w := workloads[len(workloads)-1]
+		w.Tasks = append(w.Tasks, &t)
+	}
+	return workloads, rows.Err()
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) SavePushSubscription(sub *domain.PushSubscription) error {
+	_, err := s.db.Exec(`
+		INSERT INTO push_subscriptions (
+			endpoint,
+			user_id,
+			p256dh,
+			auth,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT(endpoint) DO UPDATE SET
+			user_id = excluded.user_id,
+			p256dh = excluded.p256dh,
+			auth = excluded.auth`,
+		sub.Endpoint,
+		sub.UserID,
+		sub.P256dh,
+		sub.Auth,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeletePushSubscription(userID string, endpoint string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM push_subscriptions
+		WHERE endpoint = ?1 AND (?2 = '' OR user_id = ?2)`,
+		endpoint,
+		userID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetPushSubscriptions(userID string) ([]*domain.PushSubscription, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			endpoint,
+			user_id,
+			p256dh,
+			auth,
+			created_at
+		FROM push_subscriptions
+		WHERE user_id = ?1
+		ORDER BY created_at ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*domain.PushSubscription
+	for rows.Next() {
+		var sub domain.PushSubscription
+		var createdAt int64
+		if err := rows.Scan(
+			&sub.Endpoint,
+			&sub.UserID,
+			&sub.P256dh,
+			&sub.Auth,
+			&createdAt,
+		); err != nil {
+			return nil, err
+		}
+		sub.CreatedAt = time.Unix(createdAt, 0).UTC()
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *SQLiteStore) GetPushSubscribers() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT user_id
+		FROM push_subscriptions
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) SaveNotifyService(svc *domain.NotifyService) error {
+	_, err := s.db.Exec(`
+		INSERT INTO notify_services (
+			user_id,
+			service,
+			url,
+			topic,
+			token,
+			app_url,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+		ON CONFLICT(user_id, service) DO UPDATE SET
+			url = excluded.url,
+			topic = excluded.topic,
+			token = excluded.token,
+			app_url = excluded.app_url,
+			created_at = excluded.created_at`,
+		svc.UserID,
+		svc.Service,
+		svc.URL,
+		svc.Topic,
+		svc.Token,
+		svc.AppURL,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetNotifyService(userID string, service string) (*domain.NotifyService, error) {
+	var svc domain.NotifyService
+	var createdAt int64
+	err := s.db.QueryRow(`
+		SELECT
+			user_id,
+			service,
+			url,
+			topic,
+			token,
+			app_url,
+			created_at
+		FROM notify_services
+		WHERE user_id = ?1 AND service = ?2`,
+		userID,
+		service,
+	).Scan(
+		&svc.UserID,
+		&svc.Service,
+		&svc.URL,
+		&svc.Topic,
+		&svc.Token,
+		&svc.AppURL,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, notFound(err, service+" settings")
+	}
+	svc.CreatedAt = time.Unix(createdAt, 0).UTC()
+	return &svc, nil
+}
+
+func (s *SQLiteStore) DeleteNotifyService(userID string, service string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM notify_services
+		WHERE user_id = ?1 AND service = ?2`,
+		userID,
+		service,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetNotifyServiceUsers(service string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT user_id
+		FROM notify_services
+		WHERE service = ?1
+		ORDER BY user_id`,
+		service,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetQueue(userID string) ([]*domain.QueuedTask, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id,
+			t.name,
+			c.id,
+			c.name,
+			t.completion,
+			q.added_at
+		FROM queue_items q
+		JOIN tasks t ON q.task_id = t.id
+		JOIN categories c ON t.category_id = c.id
+		WHERE q.user_id = ?1
+		ORDER BY q.sort_order, q.added_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queue []*domain.QueuedTask
+	for rows.Next() {
+		var q domain.QueuedTask
+		var addedAt int64
+		if err := rows.Scan(
+			&q.TaskID,
+			&q.TaskName,
+			&q.CategoryID,
+			&q.CategoryName,
+			&q.Completion,
+			&addedAt,
+		); err != nil {
+			return nil, err
+		}
+		q.AddedAt = time.Unix(addedAt, 0)
+		queue = append(queue, &q)
+	}
+	return queue, rows.Err()
+}
+
+func (s *SQLiteStore) AddToQueue(userID string, taskID string) error {
+	// Selecting from the task inserts, and returns, nothing when it does
+	// not exist; a task already queued keeps its place
+	var id string
+	err := s.db.QueryRow(`
+		INSERT INTO queue_items (user_id, task_id, sort_order, added_at)
+		SELECT
+			?1,
+			t.id,
+			COALESCE((SELECT MAX(sort_order) + 1 FROM queue_items WHERE user_id = ?1), 0),
+			?2
+		FROM tasks t
+		WHERE t.id = ?3
+		ON CONFLICT (user_id, task_id) DO UPDATE SET user_id = excluded.user_id
+		RETURNING task_id`,
+		userID,
+		s.clock.Now().Unix(),
+		taskID,
+	).Scan(&id)
+	return notFound(err, "task")
+}
+
+func (s *SQLiteStore) RemoveFromQueue(userID string, taskID string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM queue_items
+		WHERE user_id = ?1 AND task_id = ?2`,
+		userID,
+		taskID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ReorderQueue(userID string, taskIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkOrder(tx, taskIDs, `
+		SELECT task_id
+		FROM queue_items
+		WHERE user_id = ?1`,
+		userID,
+	); err != nil {
+		return err
+	}
+
+	for i, id := range taskIDs {
+		if _, err := tx.Exec(`
+			UPDATE queue_items
+			SET sort_order = ?1
+			WHERE user_id = ?2 AND task_id = ?3`,
+			i,
+			userID,
+			id,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
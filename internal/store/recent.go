@@ -0,0 +1,69 @@
+package store
+
+import (
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) GetRecentWork(author string, limit int) ([]*domain.RecentWork, error) {
+	rows, err := s.db.Query(`
+		WITH latest AS (
+			SELECT
+				task_id,
+				subtask_id,
+				category_id,
+				hours_worked,
+				created_at,
+				ROW_NUMBER() OVER (
+					PARTITION BY task_id, subtask_id
+					ORDER BY created_at DESC, rowid DESC
+				) AS n
+			FROM work_logs
+			WHERE author = ?1 AND corrects_id IS NULL
+		)
+		SELECT
+			w.task_id,
+			COALESCE(w.subtask_id, ''),
+			t.name,
+			COALESCE(st.name, ''),
+			c.name,
+			COALESCE(st.completion, t.completion),
+			w.hours_worked,
+			w.created_at
+		FROM latest w
+		JOIN tasks t ON t.id = w.task_id
+		JOIN categories c ON c.id = w.category_id
+		LEFT JOIN subtasks st ON st.id = w.subtask_id
+		WHERE w.n = 1
+		ORDER BY w.created_at DESC
+		LIMIT ?2`,
+		author,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recent []*domain.RecentWork
+	for rows.Next() {
+		var r domain.RecentWork
+		var lastLoggedAt int64
+		if err := rows.Scan(
+			&r.TaskID,
+			&r.SubtaskID,
+			&r.TaskName,
+			&r.SubtaskName,
+			&r.CategoryName,
+			&r.Completion,
+			&r.LastHours,
+			&lastLoggedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.LastLoggedAt = time.Unix(lastLoggedAt, 0).UTC()
+		recent = append(recent, &r)
+	}
+	return recent, rows.Err()
+}
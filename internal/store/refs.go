@@ -0,0 +1,180 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// refSources maps entity types to the table and column whose text is
+// scanned for task references
+var refSources = map[string]struct{ table, column string }{
+	domain.EntityCategory: {"categories", "description"},
+	domain.EntityTask:     {"tasks", "description"},
+	domain.EntitySubtask:  {"subtasks", "description"},
+	domain.EntityWorkLog:  {"work_logs", "work_description"},
+}
+
+// indexRefs replaces the recorded references of an entity with the ones in
+// its current text. Call it after every write to that text. References to
+// tasks that do not exist, or prefixes matching several, are not recorded.
+func indexRefs(tx *sql.Tx, entityType, id string) error {
+	src := refSources[entityType]
+
+	var text string
+	err := tx.QueryRow(fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE id = ?1`,
+		src.column,
+		src.table),
+		id,
+	).Scan(&text)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM task_refs
+		WHERE source_type = ?1 AND source_id = ?2`,
+		entityType,
+		id,
+	); err != nil {
+		return err
+	}
+
+	for _, ref := range domain.TaskRefs(text) {
+		// The pattern only admits hex digits and dashes, so ref cannot
+		// smuggle LIKE wildcards in
+		if _, err := tx.Exec(`
+			INSERT INTO task_refs (source_type, source_id, target_id)
+			SELECT ?1, ?2, MIN(id)
+			FROM tasks
+			WHERE id LIKE ?3 || '%'
+			HAVING COUNT(*) = 1
+			ON CONFLICT DO NOTHING`,
+			entityType,
+			id,
+			ref,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ResolveTaskRef(ref string) (*domain.TaskRef, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id,
+			t.name,
+			t.category_id,
+			t.public AND c.public
+		FROM tasks t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.id LIKE ?1 || '%'
+		LIMIT 2`,
+		ref,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []*domain.TaskRef
+	for rows.Next() {
+		var r domain.TaskRef
+		if err := rows.Scan(&r.ID, &r.Name, &r.CategoryID, &r.Visible); err != nil {
+			return nil, err
+		}
+		found = append(found, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, notFound(sql.ErrNoRows, "task")
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%w: %q matches more than one task", domain.ErrConflict, ref)
+}
+
+// getBacklinks lists what refers to a task, grouped by kind of source. A
+// task mentioning itself, or its own work logs mentioning it, are not
+// backlinks.
+func (s *SQLiteStore) getBacklinks(taskID string) ([]*domain.Backlink, error) {
+	rows, err := s.db.Query(`
+		SELECT 'category', c.id, c.name, '', '', c.public
+		FROM task_refs r
+		JOIN categories c ON c.id = r.source_id
+		WHERE r.source_type = 'category' AND r.target_id = ?1
+
+		UNION ALL
+		SELECT 'task', t.id, t.name, t.id, '', t.public AND c.public
+		FROM task_refs r
+		JOIN tasks t ON t.id = r.source_id
+		JOIN categories c ON c.id = t.category_id
+		WHERE r.source_type = 'task' AND r.target_id = ?1 AND t.id <> ?1
+
+		UNION ALL
+		SELECT 'subtask', st.id, st.name, st.task_id, st.id, st.public AND t.public AND c.public
+		FROM task_refs r
+		JOIN subtasks st ON st.id = r.source_id
+		JOIN tasks t ON t.id = st.task_id
+		JOIN categories c ON c.id = t.category_id
+		WHERE r.source_type = 'subtask' AND r.target_id = ?1
+
+		UNION ALL
+		SELECT 'work_log', w.id, COALESCE(st.name, t.name), w.task_id, COALESCE(w.subtask_id, ''),
+			COALESCE(st.public, 1) AND t.public AND c.public
+		FROM task_refs r
+		JOIN work_logs w ON w.id = r.source_id
+		JOIN tasks t ON t.id = w.task_id
+		JOIN categories c ON c.id = t.category_id
+		LEFT JOIN subtasks st ON st.id = w.subtask_id
+		WHERE r.source_type = 'work_log' AND r.target_id = ?1 AND w.task_id <> ?1`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backlinks []*domain.Backlink
+	for rows.Next() {
+		var b domain.Backlink
+		if err := rows.Scan(
+			&b.SourceType,
+			&b.SourceID,
+			&b.Name,
+			&b.TaskID,
+			&b.SubtaskID,
+			&b.Visible,
+		); err != nil {
+			return nil, err
+		}
+		backlinks = append(backlinks, &b)
+	}
+	return backlinks, rows.Err()
+}
+
+// purgeOrphanRefs drops references from entities that no longer exist,
+// including ones still in the trash; restoring those indexes them again.
+func purgeOrphanRefs(tx *sql.Tx) error {
+	for entityType, src := range refSources {
+		if _, err := tx.Exec(fmt.Sprintf(`
+			DELETE FROM task_refs
+			WHERE source_type = ?1
+				AND source_id NOT IN (SELECT id FROM %s)`,
+			src.table),
+			entityType,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) RequestReview(taskID, actor string) (*domain.Task, error) {
+	return s.moveReview(taskID, domain.ReviewNone, domain.ReviewReady, "", actor)
+}
+
+func (s *SQLiteStore) ApproveTask(taskID, actor string) (*domain.Task, error) {
+	return s.moveReview(taskID, domain.ReviewReady, domain.ReviewApproved, "", actor)
+}
+
+func (s *SQLiteStore) ReturnTask(taskID, note, actor string) (*domain.Task, error) {
+	note, err := domain.NormalizeDescription(note)
+	if err != nil {
+		return nil, err
+	}
+	return s.moveReview(taskID, domain.ReviewReady, domain.ReviewNone, note, actor)
+}
+
+// moveReview moves a task from one review state to another. Only marking a
+// task ready needs its category to require review, so tasks left ready when
+// review is turned off can still be approved or sent back.
+func (s *SQLiteStore) moveReview(taskID, from, to, note, actor string) (*domain.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.recordBaseline(tx, domain.EntityTask, taskID); err != nil {
+		return nil, err
+	}
+
+	var required bool
+	var state, categoryID string
+	if err := tx.QueryRow(`
+		SELECT c.requires_review, t.review, t.category_id
+		FROM tasks t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.id = ?1`,
+		taskID,
+	).Scan(&required, &state, &categoryID); err != nil {
+		return nil, notFound(err, "task")
+	}
+	if from == domain.ReviewNone && !required {
+		return nil, fmt.Errorf("%w: this category doesn't review its tasks", domain.ErrConflict)
+	}
+	if state != from {
+		return nil, fmt.Errorf("%w: the task is %s", domain.ErrConflict, domain.ReviewStateNames[state])
+	}
+	if to == domain.ReviewApproved {
+		if err := checkDone(tx, taskID, 100); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE tasks
+		SET review = ?2,
+			completion = CASE WHEN ?2 = ?3 THEN 100 ELSE completion END
+		WHERE id = ?1`,
+		taskID,
+		to,
+		domain.ReviewApproved,
+	); err != nil {
+		return nil, err
+	}
+	if err := s.addReviewTransition(tx, taskID, from, to, note, actor); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordRevision(tx, domain.EntityTask, taskID, actor); err != nil {
+		return nil, err
+	}
+	if err := refreshCategoryCompletion(tx, categoryID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetTask(taskID)
+}
+
+// applyReview holds a change in a task's completion, about to be made from
+// what it is now, to its category's review. Finishing a task there other
+// than by approving it fails with ErrConflict; taking an approved task back
+// under 100% returns it to being worked on.
+func (s *SQLiteStore) applyReview(tx *sql.Tx, taskID string, completion int, actor string) error {
+	var required bool
+	var state string
+	var current int
+	if err := tx.QueryRow(`
+		SELECT c.requires_review, t.review, t.completion
+		FROM tasks t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.id = ?1`,
+		taskID,
+	).Scan(&required, &state, &current); err != nil {
+		return notFound(err, "task")
+	}
+
+	switch {
+	case completion >= 100 && current < 100 && required && state != domain.ReviewApproved:
+		return fmt.Errorf("%w: tasks in this category are finished by an owner approving them; mark it ready for review", domain.ErrConflict)
+	case completion < 100 && state == domain.ReviewApproved:
+		if _, err := tx.Exec("UPDATE tasks SET review = ?2 WHERE id = ?1", taskID, domain.ReviewNone); err != nil {
+			return err
+		}
+		return s.addReviewTransition(tx, taskID, state, domain.ReviewNone, "", actor)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) addReviewTransition(tx *sql.Tx, taskID, from, to, note, actor string) error {
+	_, err := tx.Exec(`
+		INSERT INTO review_transitions (id, task_id, from_state, to_state, note, author, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+		s.ids.NewID(),
+		taskID,
+		from,
+		to,
+		note,
+		actor,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) getReviewTransitions(taskID string) ([]*domain.ReviewTransition, error) {
+	rows, err := s.db.Query(`
+		SELECT id, task_id, from_state, to_state, note, author, created_at
+		FROM review_transitions
+		WHERE task_id = ?1
+		ORDER BY created_at DESC, rowid DESC`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []*domain.ReviewTransition
+	for rows.Next() {
+		var rt domain.ReviewTransition
+		var createdAt int64
+		if err := rows.Scan(&rt.ID, &rt.TaskID, &rt.From, &rt.To, &rt.Note, &rt.Author, &createdAt); err != nil {
+			return nil, err
+		}
+		rt.CreatedAt = time.Unix(createdAt, 0).UTC()
+		transitions = append(transitions, &rt)
+	}
+	return transitions, rows.Err()
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// revisionSources maps entity types to the table holding them and the
+// completion expression to record; category completion is derived, so it is
+// not part of a category's revisions.
+var revisionSources = map[string]struct{ table, completion string }{
+	domain.EntityCategory: {"categories", "0"},
+	domain.EntityTask:     {"tasks", "t.completion"},
+	domain.EntitySubtask:  {"subtasks", "t.completion"},
+}
+
+// recordBaseline saves an entity's current state as its first revision if
+// it has none yet, so the version from before revision tracking (or from
+// creation) is never lost to the first edit. Call it before updating.
+func (s *SQLiteStore) recordBaseline(tx *sql.Tx, entityType, id string) error {
+	src := revisionSources[entityType]
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO revisions (entity_type, entity_id, name, description, completion, public, author, created_at)
+		SELECT ?1, t.id, t.name, t.description, %s, t.public, '', ?3
+		FROM %s t
+		WHERE t.id = ?2
+			AND NOT EXISTS (
+				SELECT 1
+				FROM revisions
+				WHERE entity_type = ?1 AND entity_id = ?2)`,
+		src.completion,
+		src.table),
+		entityType,
+		id,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+// recordRevision saves an entity's current state as a revision by actor,
+// unless it is identical to the latest revision. Call it after updating.
+func (s *SQLiteStore) recordRevision(tx *sql.Tx, entityType, id, actor string) error {
+	src := revisionSources[entityType]
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO revisions (entity_type, entity_id, name, description, completion, public, author, created_at)
+		SELECT ?1, t.id, t.name, t.description, %[1]s, t.public, ?3, ?4
+		FROM %[2]s t
+		WHERE t.id = ?2
+			AND NOT EXISTS (
+				SELECT 1
+				FROM (
+					SELECT name, description, completion, public
+					FROM revisions
+					WHERE entity_type = ?1 AND entity_id = ?2
+					ORDER BY id DESC
+					LIMIT 1) r
+				WHERE r.name = t.name
+					AND r.description = t.description
+					AND r.completion = %[1]s
+					AND r.public = t.public)`,
+		src.completion,
+		src.table),
+		entityType,
+		id,
+		actor,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetRevisions(entityType string, entityID string) ([]*domain.Revision, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			entity_type,
+			entity_id,
+			name,
+			description,
+			completion,
+			public,
+			author,
+			created_at
+		FROM revisions
+		WHERE entity_type = ?1 AND entity_id = ?2
+		ORDER BY id DESC`,
+		entityType,
+		entityID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*domain.Revision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *SQLiteStore) GetRevision(id int64) (*domain.Revision, error) {
+	rev, err := scanRevision(s.db.QueryRow(`
+		SELECT
+			id,
+			entity_type,
+			entity_id,
+			name,
+			description,
+			completion,
+			public,
+			author,
+			created_at
+		FROM revisions
+		WHERE id = ?1`,
+		id,
+	))
+	if err != nil {
+		return nil, notFound(err, "revision")
+	}
+	return rev, nil
+}
+
+func scanRevision(row interface{ Scan(...any) error }) (*domain.Revision, error) {
+	var rev domain.Revision
+	var createdAt int64
+	if err := row.Scan(
+		&rev.ID,
+		&rev.EntityType,
+		&rev.EntityID,
+		&rev.Name,
+		&rev.Description,
+		&rev.Completion,
+		&rev.Public,
+		&rev.Author,
+		&createdAt,
+	); err != nil {
+		return nil, err
+	}
+	rev.CreatedAt = time.Unix(createdAt, 0).UTC()
+	return &rev, nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// searchLimit caps how many results a search returns
+const searchLimit = 50
+
+func (s *SQLiteStore) Search(query string) ([]*domain.SearchResult, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	// Each word is quoted as an FTS5 string, so nothing typed is read as
+	// query syntax, and the last is matched as a prefix
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	terms[len(terms)-1] += "*"
+
+	rows, err := s.db.Query(`
+		SELECT
+			i.entity_type,
+			i.entity_id,
+			COALESCE(c.id, t.category_id, st.category_id, w.category_id),
+			COALESCE(st.task_id, w.task_id, ''),
+			COALESCE(w.subtask_id, ''),
+			COALESCE(c.name, t.name, st.name, wt.name),
+			snippet(search_index, -1, ?2, ?3, '…', 16)
+		FROM search_index i
+		LEFT JOIN categories c ON i.entity_type = 'category' AND c.id = i.entity_id
+		LEFT JOIN tasks t ON i.entity_type = 'task' AND t.id = i.entity_id
+		LEFT JOIN subtasks st ON i.entity_type = 'subtask' AND st.id = i.entity_id
+		LEFT JOIN work_logs w ON i.entity_type = 'work_log' AND w.id = i.entity_id
+		LEFT JOIN tasks wt ON wt.id = w.task_id
+		WHERE search_index MATCH ?1
+			AND COALESCE(c.id, t.id, st.id, w.id) IS NOT NULL
+		ORDER BY bm25(search_index, 0, 0, 10, 1)
+		LIMIT ?4`,
+		strings.Join(terms, " "),
+		domain.SearchMatchStart,
+		domain.SearchMatchEnd,
+		searchLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.SearchResult
+	for rows.Next() {
+		var r domain.SearchResult
+		if err := rows.Scan(
+			&r.EntityType,
+			&r.ID,
+			&r.CategoryID,
+			&r.TaskID,
+			&r.SubtaskID,
+			&r.Name,
+			&r.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
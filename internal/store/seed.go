@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/fixtures"
+)
+
+// Seed inserts the onboarding sample board, owned by owner. It refuses to run
+// if any categories exist so that it can never mix sample data into a board
+// that is already in use.
+func (s *SQLiteStore) Seed(owner string) error {
+	return s.LoadFixture(fixtures.Onboarding(s.clock.Now()), owner)
+}
+
+// LoadFixture inserts a fixture board, including its work log history, in
+// one transaction, its categories owned by owner (or by no one if it is
+// empty). Like Seed, it only runs against an empty board.
+func (s *SQLiteStore) LoadFixture(board *fixtures.Board, owner string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: board is not empty", domain.ErrConflict)
+	}
+
+	for catOrder, cat := range board.Categories {
+		catID := s.ids.NewID()
+		if _, err := tx.Exec(`
+			INSERT INTO categories (id, name, description, sort_order, owner_id)
+			VALUES (?1, ?2, ?3, ?4, ?5)`,
+			catID,
+			cat.Name,
+			cat.Description,
+			catOrder,
+			owner,
+		); err != nil {
+			return err
+		}
+
+		for taskOrder, task := range cat.Tasks {
+			taskID := s.ids.NewID()
+			if len(task.Subtasks) > 0 {
+				sum := 0
+				for _, sub := range task.Subtasks {
+					sum += sub.Completion
+				}
+				task.Completion = sum / len(task.Subtasks)
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO tasks (id, category_id, name, description, completion, sort_order, created_at)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+				taskID,
+				catID,
+				task.Name,
+				task.Description,
+				task.Completion,
+				taskOrder,
+				s.clock.Now().Unix(),
+			); err != nil {
+				return err
+			}
+			if err := s.insertFixtureLogs(tx, catID, taskID, nil, task.WorkLogs); err != nil {
+				return err
+			}
+
+			for subOrder, sub := range task.Subtasks {
+				subID := s.ids.NewID()
+				if _, err := tx.Exec(`
+					INSERT INTO subtasks (id, task_id, category_id, name, completion, sort_order)
+					VALUES (?1, ?2, ?3, ?4, ?5, ?6)`,
+					subID,
+					taskID,
+					catID,
+					sub.Name,
+					sub.Completion,
+					subOrder,
+				); err != nil {
+					return err
+				}
+				if err := s.insertFixtureLogs(tx, catID, taskID, subID, sub.WorkLogs); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := refreshCategoryCompletion(tx, catID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertFixtureLogs writes work logs with the timestamps the fixture gives
+// them. subtaskID is nil for logs against the task itself.
+func (s *SQLiteStore) insertFixtureLogs(tx *sql.Tx, catID, taskID string, subtaskID any, logs []fixtures.WorkLog) error {
+	for _, wl := range logs {
+		if _, err := tx.Exec(`
+			INSERT INTO work_logs (
+				id,
+				category_id,
+				task_id,
+				subtask_id,
+				hours_worked,
+				work_description,
+				completion_estimate,
+				author,
+				created_at)
+			VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)`,
+			s.ids.NewID(),
+			catID,
+			taskID,
+			subtaskID,
+			wl.Hours,
+			wl.Description,
+			wl.Completion,
+			wl.Author,
+			wl.At.Unix(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// similarCandidates caps how many index matches are scored, best ranked first
+const similarCandidates = 50
+
+// FindSimilarTasks asks the trigram index for tasks sharing any trigram with
+// name, then keeps those whose names are alike enough overall
+func (s *SQLiteStore) FindSimilarTasks(catID, name string, minSimilarity float64, limit int) ([]*domain.SimilarTask, error) {
+	grams := domain.Trigrams(name)
+	if len(grams) == 0 {
+		return nil, nil
+	}
+	// Each trigram is quoted as an FTS5 string, which escapes quotes by
+	// doubling them
+	terms := make([]string, len(grams))
+	for i, g := range grams {
+		terms[i] = `"` + strings.ReplaceAll(g, `"`, `""`) + `"`
+	}
+
+	rows, err := s.db.Query(`
+		SELECT t.id, t.name, t.completion
+		FROM task_names n
+		JOIN tasks t ON t.rowid = n.rowid
+		WHERE task_names MATCH ?1 AND t.category_id = ?2
+		ORDER BY n.rank
+		LIMIT ?3`,
+		strings.Join(terms, " OR "),
+		catID,
+		similarCandidates,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []*domain.SimilarTask
+	for rows.Next() {
+		var t domain.SimilarTask
+		if err := rows.Scan(&t.ID, &t.Name, &t.Completion); err != nil {
+			return nil, err
+		}
+		if t.Similarity = domain.Similarity(name, t.Name); t.Similarity >= minSimilarity {
+			similar = append(similar, &t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(similar, func(a, b *domain.SimilarTask) int {
+		return cmp.Compare(b.Similarity, a.Similarity)
+	})
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar, nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+const snapshotDay = "2006-01-02"
+
+func (s *SQLiteStore) SaveSnapshot(takenAt time.Time, board []byte) (bool, error) {
+	sum := sha256.Sum256(board)
+	hash := hex.EncodeToString(sum[:])
+	takenAt = takenAt.UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var latest string
+	err = tx.QueryRow(`
+		SELECT hash
+		FROM snapshots
+		ORDER BY day DESC
+		LIMIT 1`,
+	).Scan(&latest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if latest == hash {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO snapshots (day, taken_at, hash, board)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT(day) DO UPDATE
+			SET taken_at = excluded.taken_at,
+				hash = excluded.hash,
+				board = excluded.board`,
+		takenAt.Format(snapshotDay),
+		takenAt.Unix(),
+		hash,
+		string(board),
+	); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLiteStore) GetSnapshot(asOf time.Time) (*domain.Snapshot, error) {
+	var snap domain.Snapshot
+	var takenAt int64
+	var board string
+	if err := s.db.QueryRow(`
+		SELECT
+			id,
+			day,
+			taken_at,
+			hash,
+			board
+		FROM snapshots
+		WHERE day <= ?1
+		ORDER BY day DESC
+		LIMIT 1`,
+		asOf.UTC().Format(snapshotDay),
+	).Scan(
+		&snap.ID,
+		&snap.Day,
+		&takenAt,
+		&snap.Hash,
+		&board,
+	); err != nil {
+		return nil, notFound(err, "snapshot")
+	}
+	snap.TakenAt = time.Unix(takenAt, 0).UTC()
+	snap.Board = []byte(board)
+	return &snap, nil
+}
+
+func (s *SQLiteStore) GetSnapshots(from, to time.Time) ([]*domain.Snapshot, error) {
+	first := from.UTC().Format(snapshotDay)
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			day,
+			taken_at,
+			hash,
+			board
+		FROM snapshots
+		WHERE day >= COALESCE((SELECT MAX(day) FROM snapshots WHERE day <= ?1), ?1)
+			AND day <= ?2
+		ORDER BY day ASC`,
+		first,
+		to.UTC().Format(snapshotDay),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []*domain.Snapshot
+	for rows.Next() {
+		var snap domain.Snapshot
+		var takenAt int64
+		var board string
+		if err := rows.Scan(
+			&snap.ID,
+			&snap.Day,
+			&takenAt,
+			&snap.Hash,
+			&board,
+		); err != nil {
+			return nil, err
+		}
+		snap.TakenAt = time.Unix(takenAt, 0).UTC()
+		snap.Board = []byte(board)
+		snaps = append(snaps, &snap)
+	}
+	return snaps, rows.Err()
+}
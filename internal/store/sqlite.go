@@ -2,23 +2,44 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"git.sr.ht/~jakintosh/compass/internal/domain"
-	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
 type SQLiteStore struct {
-	db *sql.DB
+	db    *sql.DB
+	clock domain.Clock
+	ids   domain.IDGenerator
 }
 
-func NewSQLiteStore(path string, wal bool) (*SQLiteStore, error) {
+// NewSQLiteStore opens the database at path. Timestamps come from clock, or
+// the system clock when it is nil, and new IDs from ids, or random UUIDs when
+// it is nil.
+func NewSQLiteStore(path string, wal bool, clock domain.Clock, ids domain.IDGenerator) (*SQLiteStore, error) {
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
+	if ids == nil {
+		ids = domain.RandomIDs{}
+	}
+
 	const busyTimeoutMS = 5000
 
-	db, err := sql.Open("sqlite", path)
+	// Connection pragmas go in the DSN rather than through Exec so that every
+	// connection the pool opens gets them, not just the first one. Without
+	// foreign_keys on a reconnected handle, deletes would leave orphans.
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(%d)", path, busyTimeoutMS)
+	if wal {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -26,29 +47,22 @@ func NewSQLiteStore(path string, wal bool) (*SQLiteStore, error) {
 	// Serialize writes to avoid overlapping write transactions.
 	db.SetMaxOpenConns(1)
 
-	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
+	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
-	if wal {
-		if _, err := db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
-			db.Close()
-			return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
-		}
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	if _, err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d;", busyTimeoutMS)); err != nil {
+	var foreignKeys bool
+	if err := db.QueryRow("PRAGMA foreign_keys;").Scan(&foreignKeys); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
+		return nil, fmt.Errorf("failed to check foreign keys: %w", err)
 	}
-
-	if err := db.Ping(); err != nil {
+	if !foreignKeys {
 		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, errors.New("foreign key enforcement is not available")
 	}
 
-	s := &SQLiteStore{db: db}
+	s := &SQLiteStore{db: db, clock: clock, ids: ids}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
@@ -57,6 +71,11 @@ func NewSQLiteStore(path string, wal bool) (*SQLiteStore, error) {
 	return s, nil
 }
 
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
 func (s *SQLiteStore) migrate() error {
 	_, err := s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS categories (
@@ -109,8 +128,16 @@ func (s *SQLiteStore) migrate() error {
 		CREATE INDEX IF NOT EXISTS idx_work_logs_task ON work_logs(task_id);
 		CREATE INDEX IF NOT EXISTS idx_work_logs_subtask ON work_logs(subtask_id);
 		CREATE INDEX IF NOT EXISTS idx_work_logs_created_at ON work_logs(created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS preferences (
+			user_id TEXT PRIMARY KEY,
+			accessible INTEGER NOT NULL DEFAULT 0
+		);
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.applyMigrations()
 }
 
 func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
@@ -120,7 +147,12 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			id,
 			name,
 			description,
-			public
+			public,
+			color,
+			icon,
+			wip_limit,
+			completion,
+			owner_id
 		FROM categories
 		ORDER BY sort_order ASC`,
 	)
@@ -136,6 +168,11 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			&c.Name,
 			&c.Description,
 			&c.Public,
+			&c.Color,
+			&c.Icon,
+			&c.WIPLimit,
+			&c.Completion,
+			&c.OwnerID,
 		); err != nil {
 			categoryRows.Close()
 			return nil, err
@@ -160,7 +197,20 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			t.description,
 			t.completion,
 			t.public,
-			c.public AS parent_public
+			t.estimate,
+			t.start_date,
+			t.due_date,
+			t.blocked_at,
+			t.blocked_reason,
+			t.waiting_on,
+			t.size,
+			t.subtask_checkboxes,
+			t.priority,
+			t.flag,
+			t.review,
+			t.created_at,
+			c.public AS parent_public,
+			c.requires_review AS review_required
 		FROM tasks t
 		JOIN categories c ON t.category_id = c.id
 		ORDER BY t.sort_order ASC`,
@@ -173,6 +223,7 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 	var allTasks []*domain.Task
 	for taskRows.Next() {
 		var t domain.Task
+		var blockedAt, createdAt int64
 		if err := taskRows.Scan(
 			&t.ID,
 			&t.CategoryID,
@@ -180,11 +231,26 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			&t.Description,
 			&t.Completion,
 			&t.Public,
+			&t.Estimate,
+			&t.StartDate,
+			&t.DueDate,
+			&blockedAt,
+			&t.BlockedReason,
+			&t.WaitingOn,
+			&t.Size,
+			&t.SubtaskCheckboxes,
+			&t.Priority,
+			&t.Flag,
+			&t.Review,
+			&createdAt,
 			&t.ParentPublic,
+			&t.ReviewRequired,
 		); err != nil {
 			taskRows.Close()
 			return nil, err
 		}
+		setBlocked(&t, blockedAt)
+		t.CreatedAt = time.Unix(createdAt, 0)
 		t.Subtasks = []*domain.Subtask{}
 		tasksByCat[t.CategoryID] = append(tasksByCat[t.CategoryID], &t)
 		allTasks = append(allTasks, &t)
@@ -207,7 +273,10 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			s.description,
 			s.completion,
 			s.public,
-			(c.public AND t.public) AS parent_public
+			s.estimate,
+			s.due_date,
+			(c.public AND t.public) AS parent_public,
+			t.subtask_checkboxes
 		FROM subtasks s
 		JOIN tasks t ON s.task_id = t.id
 		JOIN categories c ON s.category_id = c.id
@@ -228,7 +297,10 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 			&sub.Description,
 			&sub.Completion,
 			&sub.Public,
+			&sub.Estimate,
+			&sub.DueDate,
 			&sub.ParentPublic,
+			&sub.Checkbox,
 		); err != nil {
 			return nil, err
 		}
@@ -242,11 +314,22 @@ func (s *SQLiteStore) GetCategories() ([]*domain.Category, error) {
 		return nil, err
 	}
 
+	dependsOn, err := s.getDependencies("1")
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.getTaskTags("1")
+	if err != nil {
+		return nil, err
+	}
+
 	// Assemble
 	for _, t := range allTasks {
 		if subs, ok := subsByTask[t.ID]; ok {
 			t.Subtasks = subs
 		}
+		t.DependsOn = dependsOn[t.ID]
+		t.Tags = tags[t.ID]
 	}
 
 	for _, c := range categories {
@@ -265,7 +348,16 @@ func (s *SQLiteStore) GetCategory(id string) (*domain.Category, error) {
 			id,
 			name,
 			description,
-			public
+			public,
+			color,
+			icon,
+			wip_limit,
+			completion,
+			share_token,
+			alert_token_hash != '',
+			owner_id,
+			weekly_digest,
+			requires_review
 		FROM categories
 		WHERE id = ?1`,
 		id,
@@ -275,8 +367,17 @@ func (s *SQLiteStore) GetCategory(id string) (*domain.Category, error) {
 		&c.Name,
 		&c.Description,
 		&c.Public,
+		&c.Color,
+		&c.Icon,
+		&c.WIPLimit,
+		&c.Completion,
+		&c.ShareToken,
+		&c.ReceivesAlerts,
+		&c.OwnerID,
+		&c.WeeklyDigest,
+		&c.RequiresReview,
 	); err != nil {
-		return nil, err
+		return nil, notFound(err, "category")
 	}
 
 	tasks, err := s.getTasksForCategory(c.ID)
@@ -285,6 +386,15 @@ func (s *SQLiteStore) GetCategory(id string) (*domain.Category, error) {
 	}
 
 	c.Tasks = tasks
+	if c.DoneCriteria, err = s.getDoneCriteria(c.ID); err != nil {
+		return nil, err
+	}
+	if c.AgingRules, err = s.getAgingRules(c.ID); err != nil {
+		return nil, err
+	}
+	if c.SyncPeers, err = s.GetSyncPeers(c.ID); err != nil {
+		return nil, err
+	}
 	return &c, nil
 }
 
@@ -297,7 +407,20 @@ func (s *SQLiteStore) getTasksForCategory(catID string) ([]*domain.Task, error)
 			t.description,
 			t.completion,
 			t.public,
-			c.public AS parent_public
+			t.estimate,
+			t.start_date,
+			t.due_date,
+			t.blocked_at,
+			t.blocked_reason,
+			t.waiting_on,
+			t.size,
+			t.subtask_checkboxes,
+			t.priority,
+			t.flag,
+			t.review,
+			t.created_at,
+			c.public AS parent_public,
+			c.requires_review AS review_required
 		FROM tasks t
 		JOIN categories c ON t.category_id = c.id
 		WHERE t.category_id = ?1
@@ -311,6 +434,7 @@ func (s *SQLiteStore) getTasksForCategory(catID string) ([]*domain.Task, error)
 	var tasks []*domain.Task
 	for taskRows.Next() {
 		var t domain.Task
+		var blockedAt, createdAt int64
 		if err := taskRows.Scan(
 			&t.ID,
 			&t.CategoryID,
@@ -318,11 +442,26 @@ func (s *SQLiteStore) getTasksForCategory(catID string) ([]*domain.Task, error)
 			&t.Description,
 			&t.Completion,
 			&t.Public,
+			&t.Estimate,
+			&t.StartDate,
+			&t.DueDate,
+			&blockedAt,
+			&t.BlockedReason,
+			&t.WaitingOn,
+			&t.Size,
+			&t.SubtaskCheckboxes,
+			&t.Priority,
+			&t.Flag,
+			&t.Review,
+			&createdAt,
 			&t.ParentPublic,
+			&t.ReviewRequired,
 		); err != nil {
 			taskRows.Close()
 			return nil, err
 		}
+		setBlocked(&t, blockedAt)
+		t.CreatedAt = time.Unix(createdAt, 0)
 
 		tasks = append(tasks, &t)
 	}
@@ -334,12 +473,22 @@ func (s *SQLiteStore) getTasksForCategory(catID string) ([]*domain.Task, error)
 		return nil, err
 	}
 
+	dependsOn, err := s.getDependencies("t.category_id = ?1", catID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.getTaskTags("t.category_id = ?1", catID)
+	if err != nil {
+		return nil, err
+	}
 	for _, t := range tasks {
 		subs, err := s.getSubtasksForTask(t.ID)
 		if err != nil {
 			return nil, err
 		}
 		t.Subtasks = subs
+		t.DependsOn = dependsOn[t.ID]
+		t.Tags = tags[t.ID]
 	}
 	return tasks, nil
 }
@@ -354,7 +503,10 @@ func (s *SQLiteStore) getSubtasksForTask(taskID string) ([]*domain.Subtask, erro
 			s.description,
 			s.completion,
 			s.public,
-			(c.public AND t.public) AS parent_public
+			s.estimate,
+			s.due_date,
+			(c.public AND t.public) AS parent_public,
+			t.subtask_checkboxes
 		FROM subtasks s
 		JOIN tasks t ON s.task_id = t.id
 		JOIN categories c ON s.category_id = c.id
@@ -378,7 +530,10 @@ func (s *SQLiteStore) getSubtasksForTask(taskID string) ([]*domain.Subtask, erro
 			&sub.Description,
 			&sub.Completion,
 			&sub.Public,
+			&sub.Estimate,
+			&sub.DueDate,
 			&sub.ParentPublic,
+			&sub.Checkbox,
 		); err != nil {
 			return nil, err
 		}
@@ -387,8 +542,12 @@ func (s *SQLiteStore) getSubtasksForTask(taskID string) ([]*domain.Subtask, erro
 	return subs, nil
 }
 
-func (s *SQLiteStore) AddCategory(name string) (*domain.Category, error) {
-	id := uuid.NewString()
+func (s *SQLiteStore) AddCategory(name string, ownerID string) (*domain.Category, error) {
+	name, err := domain.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	id := s.ids.NewID()
 
 	var minOrder sql.NullInt64
 	s.db.QueryRow("SELECT MIN(sort_order) FROM categories").Scan(&minOrder)
@@ -396,21 +555,32 @@ func (s *SQLiteStore) AddCategory(name string) (*domain.Category, error) {
 
 	var cat domain.Category
 	if err := s.db.QueryRow(`
-		INSERT INTO categories (id, name, sort_order)
-		VALUES (?1, ?2, ?3)
+		INSERT INTO categories (id, name, sort_order, owner_id)
+		VALUES (?1, ?2, ?3, ?4)
 		RETURNING
 			id,
 			name,
 			description,
-			public`,
+			public,
+			color,
+			icon,
+			wip_limit,
+			completion,
+			owner_id`,
 		id,
 		name,
 		order,
+		ownerID,
 	).Scan(
 		&cat.ID,
 		&cat.Name,
 		&cat.Description,
 		&cat.Public,
+		&cat.Color,
+		&cat.Icon,
+		&cat.WIPLimit,
+		&cat.Completion,
+		&cat.OwnerID,
 	); err != nil {
 		return nil, err
 	}
@@ -419,29 +589,79 @@ func (s *SQLiteStore) AddCategory(name string) (*domain.Category, error) {
 	return &cat, nil
 }
 
-func (s *SQLiteStore) UpdateCategory(cat *domain.Category) (*domain.Category, error) {
+func (s *SQLiteStore) UpdateCategory(cat *domain.Category, actor string) (*domain.Category, error) {
+	clean := *cat
+	if err := clean.Normalize(); err != nil {
+		return nil, err
+	}
+	cat = &clean
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.recordBaseline(tx, domain.EntityCategory, cat.ID); err != nil {
+		return nil, err
+	}
+
 	var updated domain.Category
-	if err := s.db.QueryRow(
+	if err := tx.QueryRow(
 		`UPDATE categories
 			SET name = ?1,
 				description = ?2,
-				public = ?3
-			WHERE id = ?4
+				public = ?3,
+				color = ?4,
+				icon = ?5,
+				wip_limit = ?7,
+				weekly_digest = ?8,
+				requires_review = ?9
+			WHERE id = ?6
 		RETURNING
 			id,
 			name,
 			description,
-			public`,
+			public,
+			color,
+			icon,
+			wip_limit,
+			completion,
+			owner_id,
+			weekly_digest,
+			requires_review`,
 		cat.Name,
 		cat.Description,
 		cat.Public,
+		cat.Color,
+		cat.Icon,
 		cat.ID,
+		cat.WIPLimit,
+		cat.WeeklyDigest,
+		cat.RequiresReview,
 	).Scan(
 		&updated.ID,
 		&updated.Name,
 		&updated.Description,
 		&updated.Public,
+		&updated.Color,
+		&updated.Icon,
+		&updated.WIPLimit,
+		&updated.Completion,
+		&updated.OwnerID,
+		&updated.WeeklyDigest,
+		&updated.RequiresReview,
 	); err != nil {
+		return nil, notFound(err, "category")
+	}
+
+	if err := s.recordRevision(tx, domain.EntityCategory, updated.ID, actor); err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityCategory, updated.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
@@ -454,26 +674,6 @@ func (s *SQLiteStore) UpdateCategory(cat *domain.Category) (*domain.Category, er
 	return &updated, nil
 }
 
-func (s *SQLiteStore) DeleteCategory(id string) (*domain.Category, error) {
-	var removed domain.Category
-	if err := s.db.QueryRow(`
-		DELETE FROM categories
-		WHERE id = ?1
-		RETURNING
-			id,
-			name,
-			description`,
-		id,
-	).Scan(
-		&removed.ID,
-		&removed.Name,
-		&removed.Description,
-	); err != nil {
-		return nil, err
-	}
-	return &removed, nil
-}
-
 func (s *SQLiteStore) ReorderCategories(ids []string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -481,6 +681,13 @@ func (s *SQLiteStore) ReorderCategories(ids []string) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkOrder(tx, ids, `
+		SELECT id
+		FROM categories`,
+	); err != nil {
+		return err
+	}
+
 	for i, id := range ids {
 		if _, err := tx.Exec(`
 			UPDATE categories
@@ -495,8 +702,114 @@ func (s *SQLiteStore) ReorderCategories(ids []string) error {
 	return tx.Commit()
 }
 
+// refreshCategoryCompletion recomputes the cached average of a category's
+// task completion, weighted by estimate (see weightedCompletion). It must run
+// in the same transaction as any change to those tasks so the stored value
+// never drifts from them.
+func refreshCategoryCompletion(tx *sql.Tx, catID string) error {
+	_, err := tx.Exec(`
+		WITH items AS (
+			SELECT completion, estimate
+			FROM tasks
+			WHERE category_id = ?1
+		)
+		UPDATE categories
+		SET completion = COALESCE(`+weightedCompletion+`, 0)
+		WHERE id = ?1`,
+		catID,
+	)
+	return err
+}
+
+// refreshTaskCompletion recomputes a task's completion from its subtasks,
+// weighted by estimate, then its category's. A task without subtasks keeps
+// the completion it was given. One with unchecked items on its definition of
+// done, or waiting on approval in a category that requires review, stops at
+// 99%.
+func refreshTaskCompletion(tx *sql.Tx, taskID, catID string) error {
+	if _, err := tx.Exec(`
+		WITH items AS (
+			SELECT completion, estimate
+			FROM subtasks
+			WHERE task_id = ?1
+		)
+		UPDATE tasks
+		SET completion = MIN(`+weightedCompletion+`, CASE
+			WHEN EXISTS (SELECT 1 FROM task_checks WHERE task_id = ?1 AND NOT checked) THEN 99
+			WHEN review != 'approved' AND (SELECT requires_review FROM categories WHERE id = tasks.category_id) THEN 99
+			ELSE 100
+		END)
+		WHERE id = ?1 AND EXISTS (SELECT 1 FROM items)`,
+		taskID,
+	); err != nil {
+		return err
+	}
+	return refreshCategoryCompletion(tx, catID)
+}
+
+// weightedCompletion averages the completion of the rows in items, weighting
+// each by its estimate. Unestimated rows weigh as much as the average
+// estimated one, so with no estimates at all it is a plain average; it is
+// NULL when there are no rows. This is domain.WeightedCompletion in SQL. The
+// tiny nudge keeps float error from truncating 100 to 99.
+const weightedCompletion = `(
+	SELECT CAST(SUM(completion * weight) / SUM(weight) + 1e-9 AS INTEGER)
+	FROM (
+		SELECT
+			completion,
+			CASE WHEN estimate > 0 THEN estimate ELSE (
+				SELECT COALESCE(AVG(estimate), 1) FROM items WHERE estimate > 0
+			) END AS weight
+		FROM items
+	)
+)`
+
+// notFound translates a missing row into domain.ErrNotFound, naming what was
+// looked up. Other errors pass through unchanged.
+func notFound(err error, what string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %w", what, domain.ErrNotFound)
+	}
+	return err
+}
+
+// checkOrder verifies that ids is a permutation of the rows returned by query,
+// so that a reorder can neither drop items nor pull in another parent's.
+func checkOrder(tx *sql.Tx, ids []string, query string, args ...any) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	current := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		current[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(current) {
+		return domain.ErrStaleOrder
+	}
+	for _, id := range ids {
+		if !current[id] {
+			return domain.ErrStaleOrder
+		}
+		// Clearing each match also rejects duplicates
+		delete(current, id)
+	}
+	return nil
+}
+
 func (s *SQLiteStore) GetTask(id string) (*domain.Task, error) {
 	var t domain.Task
+	var blockedAt, createdAt int64
 	err := s.db.QueryRow(`
 		SELECT
 			t.id,
@@ -505,7 +818,20 @@ func (s *SQLiteStore) GetTask(id string) (*domain.Task, error) {
 			t.description,
 			t.completion,
 			t.public,
-			c.public AS parent_public
+			t.estimate,
+			t.start_date,
+			t.due_date,
+			t.blocked_at,
+			t.blocked_reason,
+			t.waiting_on,
+			t.size,
+			t.subtask_checkboxes,
+			t.priority,
+			t.flag,
+			t.review,
+			t.created_at,
+			c.public AS parent_public,
+			c.requires_review AS review_required
 		FROM tasks t
 		JOIN categories c ON t.category_id = c.id
 		WHERE t.id = ?1`,
@@ -517,35 +843,100 @@ func (s *SQLiteStore) GetTask(id string) (*domain.Task, error) {
 		&t.Description,
 		&t.Completion,
 		&t.Public,
+		&t.Estimate,
+		&t.StartDate,
+		&t.DueDate,
+		&blockedAt,
+		&t.BlockedReason,
+		&t.WaitingOn,
+		&t.Size,
+		&t.SubtaskCheckboxes,
+		&t.Priority,
+		&t.Flag,
+		&t.Review,
+		&createdAt,
 		&t.ParentPublic,
+		&t.ReviewRequired,
 	)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "task")
 	}
+	setBlocked(&t, blockedAt)
+	t.CreatedAt = time.Unix(createdAt, 0)
 	subs, err := s.getSubtasksForTask(t.ID)
 	if err != nil {
 		return nil, err
 	}
 	t.Subtasks = subs
+	if t.Attachments, err = s.getAttachments("task_id = ?1 AND work_log_id IS NULL", t.ID); err != nil {
+		return nil, err
+	}
+	if t.Links, err = s.getLinks("l.task_id = ?1", t.ID); err != nil {
+		return nil, err
+	}
+	if t.Backlinks, err = s.getBacklinks(t.ID); err != nil {
+		return nil, err
+	}
+	if t.Nudges, err = s.getNudges("task_id = ?1", t.ID); err != nil {
+		return nil, err
+	}
+	dependsOn, err := s.getDependencies("t.id = ?1", t.ID)
+	if err != nil {
+		return nil, err
+	}
+	t.DependsOn = dependsOn[t.ID]
+	if t.Checklist, err = s.getTaskChecks(t.ID); err != nil {
+		return nil, err
+	}
+	if t.Contexts, err = s.getTaskContexts(t.ID); err != nil {
+		return nil, err
+	}
+	tags, err := s.getTaskTags("t.id = ?1", t.ID)
+	if err != nil {
+		return nil, err
+	}
+	t.Tags = tags[t.ID]
+	if t.EstimateChanges, err = s.getEstimateChanges(t.ID); err != nil {
+		return nil, err
+	}
+	if t.ReviewTransitions, err = s.getReviewTransitions(t.ID); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
 func (s *SQLiteStore) AddTask(catID string, name string) (*domain.Task, error) {
-	id := uuid.NewString()
+	name, err := domain.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	id := s.ids.NewID()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
 	var maxOrder sql.NullInt64
-	s.db.QueryRow(`
+	if err := tx.QueryRow(`
 		SELECT MAX(sort_order)
 		FROM tasks
 		WHERE category_id = ?1`,
 		catID,
-	).Scan(&maxOrder)
+	).Scan(&maxOrder); err != nil {
+		return nil, err
+	}
 	order := int(maxOrder.Int64) + 1
 
+	// Selecting from categories makes the insert a no-op for an unknown
+	// category, which surfaces below as sql.ErrNoRows.
 	var task domain.Task
-	if err := s.db.QueryRow(`
-		INSERT INTO tasks (id, category_id, name, sort_order)
-		VALUES (?1, ?2, ?3, ?4)
+	if err := tx.QueryRow(`
+		INSERT INTO tasks (id, category_id, name, sort_order, created_at)
+		SELECT ?1, id, ?3, ?4, ?5
+		FROM categories
+		WHERE id = ?2
 		RETURNING
 			id,
 			category_id,
@@ -557,6 +948,7 @@ func (s *SQLiteStore) AddTask(catID string, name string) (*domain.Task, error) {
 		catID,
 		name,
 		order,
+		s.clock.Now().Unix(),
 	).Scan(
 		&task.ID,
 		&task.CategoryID,
@@ -565,6 +957,17 @@ func (s *SQLiteStore) AddTask(catID string, name string) (*domain.Task, error) {
 		&task.Completion,
 		&task.Public,
 	); err != nil {
+		return nil, notFound(err, "category")
+	}
+	if err := s.copyDoneCriteria(tx, task.ID, catID); err != nil {
+		return nil, err
+	}
+
+	if err := refreshCategoryCompletion(tx, catID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
@@ -572,27 +975,88 @@ func (s *SQLiteStore) AddTask(catID string, name string) (*domain.Task, error) {
 	return &task, nil
 }
 
-func (s *SQLiteStore) UpdateTask(task *domain.Task) (*domain.Task, error) {
+func (s *SQLiteStore) UpdateTask(task *domain.Task, actor string) (*domain.Task, error) {
+	clean := *task
+	if err := clean.Normalize(); err != nil {
+		return nil, err
+	}
+	task = &clean
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.recordBaseline(tx, domain.EntityTask, task.ID); err != nil {
+		return nil, err
+	}
+	if err := s.applyReview(tx, task.ID, task.Completion, actor); err != nil {
+		return nil, err
+	}
+
+	// A task stays blocked since the first time it was marked blocked, however
+	// often its reason changes
 	var updated domain.Task
-	if err := s.db.QueryRow(`
+	var blockedAt, createdAt int64
+	if err := tx.QueryRow(`
 		UPDATE tasks
 		SET name = ?1,
 			description = ?2,
-			completion = ?3,
-			public = ?4
-		WHERE id = ?5
+			completion = CASE
+				WHEN EXISTS (SELECT 1 FROM subtasks WHERE task_id = ?8) THEN completion
+				ELSE ?3
+			END,
+			public = ?4,
+			estimate = ?5,
+			start_date = ?6,
+			due_date = ?7,
+			blocked_at = CASE
+				WHEN NOT ?9 THEN 0
+				WHEN blocked_at > 0 THEN blocked_at
+				ELSE ?12
+			END,
+			blocked_reason = ?10,
+			waiting_on = ?11,
+			size = ?13,
+			priority = ?14,
+			flag = ?15,
+			subtask_checkboxes = ?16
+		WHERE id = ?8
 		RETURNING
 			id,
 			category_id,
 			name,
 			description,
 			completion,
-			public`,
+			public,
+			estimate,
+			start_date,
+			due_date,
+			blocked_at,
+			blocked_reason,
+			waiting_on,
+			size,
+			priority,
+			flag,
+			subtask_checkboxes,
+			created_at`,
 		task.Name,
 		task.Description,
 		task.Completion,
 		task.Public,
+		task.Estimate,
+		task.StartDate,
+		task.DueDate,
 		task.ID,
+		task.Blocked,
+		task.BlockedReason,
+		task.WaitingOn,
+		s.clock.Now().Unix(),
+		task.Size,
+		task.Priority,
+		task.Flag,
+		task.SubtaskCheckboxes,
 	).Scan(
 		&updated.ID,
 		&updated.CategoryID,
@@ -600,38 +1064,43 @@ func (s *SQLiteStore) UpdateTask(task *domain.Task) (*domain.Task, error) {
 		&updated.Description,
 		&updated.Completion,
 		&updated.Public,
+		&updated.Estimate,
+		&updated.StartDate,
+		&updated.DueDate,
+		&blockedAt,
+		&updated.BlockedReason,
+		&updated.WaitingOn,
+		&updated.Size,
+		&updated.Priority,
+		&updated.Flag,
+		&updated.SubtaskCheckboxes,
+		&createdAt,
 	); err != nil {
+		return nil, notFound(err, "task")
+	}
+	setBlocked(&updated, blockedAt)
+	updated.CreatedAt = time.Unix(createdAt, 0)
+	if err := checkDone(tx, updated.ID, updated.Completion); err != nil {
 		return nil, err
 	}
-	updated.Subtasks = task.Subtasks
-	return &updated, nil
-}
 
-func (s *SQLiteStore) DeleteTask(id string) (*domain.Task, error) {
-	var removed domain.Task
-	if err := s.db.QueryRow(`
-		DELETE FROM tasks
-		WHERE id = ?1
-		RETURNING
-			id,
-			category_id,
-			name,
-			description,
-			completion`,
-		id,
-	).Scan(
-		&removed.ID,
-		&removed.CategoryID,
-		&removed.Name,
-		&removed.Description,
-		&removed.Completion,
-	); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("task not found")
-		}
+	if err := s.recordRevision(tx, domain.EntityTask, updated.ID, actor); err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityTask, updated.ID); err != nil {
 		return nil, err
 	}
-	return &removed, nil
+
+	if err := refreshCategoryCompletion(tx, updated.CategoryID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	updated.Subtasks = task.Subtasks
+	return &updated, nil
 }
 
 func (s *SQLiteStore) ReorderTasks(catID string, taskIDs []string) error {
@@ -641,6 +1110,15 @@ func (s *SQLiteStore) ReorderTasks(catID string, taskIDs []string) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkOrder(tx, taskIDs, `
+		SELECT id
+		FROM tasks
+		WHERE category_id = ?1`,
+		catID,
+	); err != nil {
+		return err
+	}
+
 	for i, id := range taskIDs {
 		if _, err := tx.Exec(`
 			UPDATE tasks
@@ -667,7 +1145,10 @@ func (s *SQLiteStore) GetSubtask(id string) (*domain.Subtask, error) {
 			s.description,
 			s.completion,
 			s.public,
-			(c.public AND t.public) AS parent_public
+			s.estimate,
+			s.due_date,
+			(c.public AND t.public) AS parent_public,
+			t.subtask_checkboxes
 		FROM subtasks s
 		JOIN tasks t ON s.task_id = t.id
 		JOIN categories c ON s.category_id = c.id
@@ -681,16 +1162,23 @@ func (s *SQLiteStore) GetSubtask(id string) (*domain.Subtask, error) {
 		&sub.Description,
 		&sub.Completion,
 		&sub.Public,
+		&sub.Estimate,
+		&sub.DueDate,
 		&sub.ParentPublic,
+		&sub.Checkbox,
 	)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "subtask")
 	}
 	return &sub, nil
 }
 
 func (s *SQLiteStore) AddSubtask(taskID string, name string) (*domain.Subtask, error) {
-	id := uuid.NewString()
+	name, err := domain.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	id := s.ids.NewID()
 
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -736,6 +1224,9 @@ func (s *SQLiteStore) AddSubtask(taskID string, name string) (*domain.Subtask, e
 		&sub.Completion,
 		&sub.Public,
 	); err != nil {
+		return nil, notFound(err, "task")
+	}
+	if err := refreshTaskCompletion(tx, taskID, sub.CategoryID); err != nil {
 		return nil, err
 	}
 
@@ -746,15 +1237,50 @@ func (s *SQLiteStore) AddSubtask(taskID string, name string) (*domain.Subtask, e
 	return &sub, nil
 }
 
-func (s *SQLiteStore) UpdateSubtask(sub *domain.Subtask) (*domain.Subtask, error) {
+func (s *SQLiteStore) UpdateSubtask(sub *domain.Subtask, actor string) (*domain.Subtask, error) {
+	clean := *sub
+	if err := clean.Normalize(); err != nil {
+		return nil, err
+	}
+	sub = &clean
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// A checkbox subtask is done or not. One left part-way done from
+	// before its task switched keeps that until it is ticked or cleared.
+	var checkbox bool
+	var current int
+	if err := tx.QueryRow(`
+		SELECT t.subtask_checkboxes, s.completion
+		FROM subtasks s
+		JOIN tasks t ON t.id = s.task_id
+		WHERE s.id = ?1`,
+		sub.ID,
+	).Scan(&checkbox, &current); err != nil {
+		return nil, notFound(err, "subtask")
+	}
+	if checkbox && sub.Completion != current && sub.Completion != 0 && sub.Completion != 100 {
+		return nil, fmt.Errorf("%w: this task's subtasks are checkboxes, so completion is 0 or 100", domain.ErrInvalid)
+	}
+
+	if err := s.recordBaseline(tx, domain.EntitySubtask, sub.ID); err != nil {
+		return nil, err
+	}
+
 	var updated domain.Subtask
-	if err := s.db.QueryRow(`
+	if err := tx.QueryRow(`
 		UPDATE subtasks
 		SET name = ?1,
 			description = ?2,
 			completion = ?3,
-			public = ?4
-		WHERE id = ?5
+			public = ?4,
+			estimate = ?5,
+			due_date = ?7
+		WHERE id = ?6
 		RETURNING
 			id,
 			task_id,
@@ -762,12 +1288,16 @@ func (s *SQLiteStore) UpdateSubtask(sub *domain.Subtask) (*domain.Subtask, error
 			name,
 			description,
 			completion,
-			public`,
+			public,
+			estimate,
+			due_date`,
 		sub.Name,
 		sub.Description,
 		sub.Completion,
 		sub.Public,
+		sub.Estimate,
 		sub.ID,
+		sub.DueDate,
 	).Scan(
 		&updated.ID,
 		&updated.TaskID,
@@ -776,41 +1306,26 @@ func (s *SQLiteStore) UpdateSubtask(sub *domain.Subtask) (*domain.Subtask, error
 		&updated.Description,
 		&updated.Completion,
 		&updated.Public,
+		&updated.Estimate,
+		&updated.DueDate,
 	); err != nil {
+		return nil, notFound(err, "subtask")
+	}
+	updated.Checkbox = checkbox
+	if err := refreshTaskCompletion(tx, updated.TaskID, updated.CategoryID); err != nil {
 		return nil, err
 	}
 
-	return &updated, nil
-}
-
-func (s *SQLiteStore) DeleteSubtask(id string) (*domain.Subtask, error) {
-	var removed domain.Subtask
-	if err := s.db.QueryRow(`
-		DELETE FROM subtasks
-		WHERE id = ?1
-		RETURNING
-			id,
-			task_id,
-			category_id,
-			name,
-			description,
-			completion`,
-		id,
-	).Scan(
-		&removed.ID,
-		&removed.TaskID,
-		&removed.CategoryID,
-		&removed.Name,
-		&removed.Description,
-		&removed.Completion,
-	); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("subtask not found")
-		}
+	if err := s.recordRevision(tx, domain.EntitySubtask, updated.ID, actor); err != nil {
 		return nil, err
 	}
-
-	return &removed, nil
+	if err := indexRefs(tx, domain.EntitySubtask, updated.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &updated, nil
 }
 
 func (s *SQLiteStore) ReorderSubtasks(taskID string, subIDs []string) error {
@@ -820,6 +1335,15 @@ func (s *SQLiteStore) ReorderSubtasks(taskID string, subIDs []string) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkOrder(tx, subIDs, `
+		SELECT id
+		FROM subtasks
+		WHERE task_id = ?1`,
+		taskID,
+	); err != nil {
+		return err
+	}
+
 	for i, id := range subIDs {
 		if _, err := tx.Exec(`
 			UPDATE subtasks
@@ -835,9 +1359,14 @@ func (s *SQLiteStore) ReorderSubtasks(taskID string, subIDs []string) error {
 	return tx.Commit()
 }
 
-func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time) (*domain.WorkLog, error) {
-	id := uuid.NewString()
-	timestamp := time.Now()
+func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time, author string) (*domain.WorkLog, error) {
+	workDescription, err := domain.NormalizeDescription(workDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.ids.NewID()
+	timestamp := s.clock.Now()
 	if customTime != nil {
 		timestamp = *customTime
 	}
@@ -861,7 +1390,8 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at)
+			created_at,
+			author)
 		SELECT
 			?1,
 			category_id,
@@ -870,7 +1400,8 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 			?3,
 			?4,
 			?5,
-			?6
+			?6,
+			?7
 		FROM tasks
 		WHERE id = ?2
 		RETURNING
@@ -881,13 +1412,15 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at`,
+			created_at,
+			author`,
 		id,
 		taskID,
 		hoursWorked,
 		workDescription,
 		completionEstimate,
 		timestamp.Unix(),
+		author,
 	).Scan(
 		&wl.ID,
 		&wl.CategoryID,
@@ -897,12 +1430,19 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 		&wl.WorkDescription,
 		&wl.CompletionEstimate,
 		&createdAtUnix,
+		&wl.Author,
 	); err != nil {
+		return nil, notFound(err, "task")
+	}
+	if err := checkDone(tx, taskID, completionEstimate); err != nil {
+		return nil, err
+	}
+	if err := s.applyReview(tx, taskID, completionEstimate, author); err != nil {
 		return nil, err
 	}
 
 	wl.SubtaskID = subtaskIDNull.String
-	wl.CreatedAt = time.Unix(createdAtUnix, 0)
+	wl.CreatedAt = time.Unix(createdAtUnix, 0).UTC()
 
 	if _, err := tx.Exec(`
 		UPDATE tasks
@@ -914,6 +1454,13 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 		return nil, err
 	}
 
+	if err := refreshCategoryCompletion(tx, wl.CategoryID); err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityWorkLog, wl.ID); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -921,9 +1468,14 @@ func (s *SQLiteStore) AddWorkLogForTask(taskID string, hoursWorked float64, work
 	return &wl, nil
 }
 
-func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time) (*domain.WorkLog, error) {
-	id := uuid.NewString()
-	timestamp := time.Now()
+func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64, workDescription string, completionEstimate int, customTime *time.Time, author string) (*domain.WorkLog, error) {
+	workDescription, err := domain.NormalizeDescription(workDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.ids.NewID()
+	timestamp := s.clock.Now()
 	if customTime != nil {
 		timestamp = *customTime
 	}
@@ -947,7 +1499,8 @@ func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at
+			created_at,
+			author
 		)
 		SELECT
 			?1,
@@ -957,7 +1510,8 @@ func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64
 			?3,
 			?4,
 			?5,
-			?6
+			?6,
+			?7
 		FROM subtasks
 		WHERE id = ?2
 		RETURNING
@@ -968,13 +1522,15 @@ func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at`,
+			created_at,
+			author`,
 		id,
 		subtaskID,
 		hoursWorked,
 		workDescription,
 		completionEstimate,
 		timestamp.Unix(),
+		author,
 	).Scan(
 		&wl.ID,
 		&wl.CategoryID,
@@ -984,22 +1540,33 @@ func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64
 		&wl.WorkDescription,
 		&wl.CompletionEstimate,
 		&createdAtUnix,
+		&wl.Author,
 	); err != nil {
-		return nil, err
+		return nil, notFound(err, "subtask")
 	}
 
 	wl.SubtaskID = subtaskIDNull.String
-	wl.CreatedAt = time.Unix(createdAtUnix, 0)
+	wl.CreatedAt = time.Unix(createdAtUnix, 0).UTC()
 
+	// Short of 100, a checkbox subtask is not done
 	if _, err := tx.Exec(`
 		UPDATE subtasks
-		SET completion = ?1
+		SET completion = CASE
+			WHEN ?1 < 100 AND (SELECT subtask_checkboxes FROM tasks WHERE id = subtasks.task_id) THEN 0
+			ELSE ?1
+		END
 		WHERE id = ?2`,
 		completionEstimate,
 		subtaskID,
 	); err != nil {
 		return nil, err
 	}
+	if err := refreshTaskCompletion(tx, wl.TaskID, wl.CategoryID); err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityWorkLog, wl.ID); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
@@ -1008,13 +1575,173 @@ func (s *SQLiteStore) AddWorkLogForSubtask(subtaskID string, hoursWorked float64
 	return &wl, nil
 }
 
+func (s *SQLiteStore) UpdateWorkLog(id string, hoursWorked float64, workDescription string, actor string) (*domain.WorkLog, error) {
+	workDescription, err := domain.NormalizeDescription(workDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var prevHours float64
+	if err := tx.QueryRow(`
+		SELECT hours_worked
+		FROM work_logs
+		WHERE id = ?1`,
+		id,
+	).Scan(&prevHours); err != nil {
+		return nil, notFound(err, "work log")
+	}
+
+	rows, err := tx.Query(`
+		UPDATE work_logs
+		SET hours_worked = ?1,
+			work_description = ?2
+		WHERE id = ?3
+		RETURNING
+			id,
+			category_id,
+			task_id,
+			subtask_id,
+			hours_worked,
+			work_description,
+			completion_estimate,
+			created_at,
+			author,
+			corrects_id`,
+		hoursWorked,
+		workDescription,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.scanWorkLogs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("%.2fh → %.2fh", prevHours, hoursWorked)
+	if err := s.audit(tx, actor, "edit", "work_log", id, summary); err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityWorkLog, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return logs[0], nil
+}
+
+func (s *SQLiteStore) CorrectWorkLog(id string, hoursWorked float64, workDescription string, actor string) (*domain.WorkLog, error) {
+	workDescription, err := domain.NormalizeDescription(workDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Corrections always hang off the original entry, so a chain is one
+	// level deep and its effective hours are the sum of the whole chain
+	var originalID string
+	if err := tx.QueryRow(`
+		SELECT COALESCE(corrects_id, id)
+		FROM work_logs
+		WHERE id = ?1`,
+		id,
+	).Scan(&originalID); err != nil {
+		return nil, notFound(err, "work log")
+	}
+
+	var effectiveHours float64
+	if err := tx.QueryRow(`
+		SELECT SUM(hours_worked)
+		FROM work_logs
+		WHERE id = ?1 OR corrects_id = ?1`,
+		originalID,
+	).Scan(&effectiveHours); err != nil {
+		return nil, err
+	}
+
+	// The adjustment carries the original's completion estimate and leaves
+	// the task's completion alone; it only corrects the record
+	rows, err := tx.Query(`
+		INSERT INTO work_logs (
+			id,
+			category_id,
+			task_id,
+			subtask_id,
+			hours_worked,
+			work_description,
+			completion_estimate,
+			created_at,
+			author,
+			corrects_id)
+		SELECT
+			?1,
+			category_id,
+			task_id,
+			subtask_id,
+			?2,
+			?3,
+			completion_estimate,
+			?4,
+			?5,
+			id
+		FROM work_logs
+		WHERE id = ?6
+		RETURNING
+			id,
+			category_id,
+			task_id,
+			subtask_id,
+			hours_worked,
+			work_description,
+			completion_estimate,
+			created_at,
+			author,
+			corrects_id`,
+		s.ids.NewID(),
+		hoursWorked-effectiveHours,
+		workDescription,
+		s.clock.Now().Unix(),
+		actor,
+		originalID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.scanWorkLogs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := indexRefs(tx, domain.EntityWorkLog, logs[0].ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return logs[0], nil
+}
+
 func (s *SQLiteStore) scanWorkLogs(rows *sql.Rows) ([]*domain.WorkLog, error) {
 	defer rows.Close()
 	var logs []*domain.WorkLog
 	for rows.Next() {
 		var wl domain.WorkLog
 		var createdAt int64
-		var subtaskID sql.NullString
+		var subtaskID, correctsID sql.NullString
 		if err := rows.Scan(
 			&wl.ID,
 			&wl.CategoryID,
@@ -1024,11 +1751,14 @@ func (s *SQLiteStore) scanWorkLogs(rows *sql.Rows) ([]*domain.WorkLog, error) {
 			&wl.WorkDescription,
 			&wl.CompletionEstimate,
 			&createdAt,
+			&wl.Author,
+			&correctsID,
 		); err != nil {
 			return nil, err
 		}
 		wl.SubtaskID = subtaskID.String
-		wl.CreatedAt = time.Unix(createdAt, 0)
+		wl.CorrectsID = correctsID.String
+		wl.CreatedAt = time.Unix(createdAt, 0).UTC()
 		logs = append(logs, &wl)
 	}
 	return logs, rows.Err()
@@ -1044,14 +1774,23 @@ func (s *SQLiteStore) GetWorkLogsForSubtask(subtaskID string) ([]*domain.WorkLog
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at
+			created_at,
+			author,
+			corrects_id
 		FROM work_logs
 		WHERE subtask_id = ?1
 		ORDER BY created_at DESC`, subtaskID)
 	if err != nil {
 		return nil, err
 	}
-	return s.scanWorkLogs(rows)
+	logs, err := s.scanWorkLogs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachToWorkLogs(logs, "subtask_id = ?1", subtaskID); err != nil {
+		return nil, err
+	}
+	return logs, nil
 }
 
 func (s *SQLiteStore) GetWorkLogsForTask(taskID string) ([]*domain.WorkLog, error) {
@@ -1064,7 +1803,9 @@ func (s *SQLiteStore) GetWorkLogsForTask(taskID string) ([]*domain.WorkLog, erro
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at
+			created_at,
+			author,
+			corrects_id
 		FROM work_logs
 		WHERE task_id = ?1
 		ORDER BY created_at DESC`,
@@ -1072,7 +1813,14 @@ func (s *SQLiteStore) GetWorkLogsForTask(taskID string) ([]*domain.WorkLog, erro
 	if err != nil {
 		return nil, err
 	}
-	return s.scanWorkLogs(rows)
+	logs, err := s.scanWorkLogs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachToWorkLogs(logs, "task_id = ?1", taskID); err != nil {
+		return nil, err
+	}
+	return logs, nil
 }
 
 func (s *SQLiteStore) GetWorkLogsForCategory(categoryID string) ([]*domain.WorkLog, error) {
@@ -1085,7 +1833,9 @@ func (s *SQLiteStore) GetWorkLogsForCategory(categoryID string) ([]*domain.WorkL
 			hours_worked,
 			work_description,
 			completion_estimate,
-			created_at
+			created_at,
+			author,
+			corrects_id
 		FROM work_logs
 		WHERE category_id = ?1
 		ORDER BY created_at DESC`,
@@ -1093,5 +1843,199 @@ func (s *SQLiteStore) GetWorkLogsForCategory(categoryID string) ([]*domain.WorkL
 	if err != nil {
 		return nil, err
 	}
-	return s.scanWorkLogs(rows)
+	logs, err := s.scanWorkLogs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachToWorkLogs(logs, "category_id = ?1", categoryID); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (s *SQLiteStore) GetPreferences(userID string) (*domain.Preferences, error) {
+	prefs := domain.Preferences{UserID: userID, DigestHour: domain.DefaultDigestHour, IdleMinutes: domain.DefaultIdleMinutes}
+	var notifications string
+	err := s.db.QueryRow(`
+		SELECT
+			accessible,
+			display_name,
+			timezone,
+			language,
+			notifications,
+			digest_hour,
+			weekly_capacity,
+			context,
+			email,
+			clip_category,
+			idle_minutes,
+			multiple_timers,
+			deactivated_at IS NOT NULL
+		FROM preferences
+		WHERE user_id = ?1`,
+		userID,
+	).Scan(
+		&prefs.Accessible,
+		&prefs.DisplayName,
+		&prefs.Timezone,
+		&prefs.Language,
+		&notifications,
+		&prefs.DigestHour,
+		&prefs.WeeklyCapacity,
+		&prefs.Context,
+		&prefs.Email,
+		&prefs.ClipCategory,
+		&prefs.IdleMinutes,
+		&prefs.MultipleTimers,
+		&prefs.Deactivated,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(notifications), &prefs.Notifications); err != nil {
+		return nil, fmt.Errorf("notification preferences for %s: %w", userID, err)
+	}
+	return &prefs, nil
+}
+
+func (s *SQLiteStore) ProvisionUser(prefs *domain.Preferences) (bool, error) {
+	displayName, err := domain.NormalizeName(prefs.DisplayName)
+	if err != nil {
+		displayName = ""
+	}
+	email, err := domain.NormalizeEmail(prefs.Email)
+	if err != nil {
+		email = ""
+	}
+
+	var userID string
+	err = s.db.QueryRow(`
+		INSERT INTO preferences (user_id, display_name, email)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT(user_id) DO NOTHING
+		RETURNING user_id`,
+		prefs.UserID,
+		displayName,
+		email,
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLiteStore) UpdatePreferences(prefs *domain.Preferences) (*domain.Preferences, error) {
+	displayName, err := domain.NormalizeName(prefs.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.Timezone != "" {
+		if _, err := time.LoadLocation(prefs.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", domain.ErrInvalid, prefs.Timezone)
+		}
+	}
+	lang, err := domain.NormalizeLanguage(prefs.Language)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.DigestHour < 0 || prefs.DigestHour > 23 {
+		return nil, fmt.Errorf("%w: digest hour must be between 0 and 23", domain.ErrInvalid)
+	}
+	if prefs.WeeklyCapacity < 0 || prefs.WeeklyCapacity > 168 {
+		return nil, fmt.Errorf("%w: weekly capacity must be between 0 and 168 hours", domain.ErrInvalid)
+	}
+	if prefs.IdleMinutes < 0 || prefs.IdleMinutes > 24*60 {
+		return nil, fmt.Errorf("%w: idle minutes must be between 0 and a day", domain.ErrInvalid)
+	}
+	for _, channels := range prefs.Notifications {
+		for _, mode := range channels {
+			if !slices.Contains(domain.DeliveryModes, mode) {
+				return nil, fmt.Errorf("%w: unknown delivery mode %q", domain.ErrInvalid, mode)
+			}
+		}
+	}
+	context, err := domain.NormalizeContext(prefs.Context)
+	if err != nil {
+		return nil, err
+	}
+	email, err := domain.NormalizeEmail(prefs.Email)
+	if err != nil {
+		return nil, err
+	}
+	clipCategory, err := domain.NormalizeName(prefs.ClipCategory)
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := json.Marshal(prefs.Notifications)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.Notifications == nil {
+		notifications = []byte("{}")
+	}
+
+	var updated domain.Preferences
+	if err := s.db.QueryRow(`
+		INSERT INTO preferences (user_id, accessible, display_name, timezone, language, notifications, digest_hour, weekly_capacity, context, email, clip_category, idle_minutes, multiple_timers)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13)
+		ON CONFLICT(user_id) DO UPDATE
+			SET accessible = excluded.accessible,
+				display_name = excluded.display_name,
+				timezone = excluded.timezone,
+				language = excluded.language,
+				notifications = excluded.notifications,
+				digest_hour = excluded.digest_hour,
+				weekly_capacity = excluded.weekly_capacity,
+				context = excluded.context,
+				email = excluded.email,
+				clip_category = excluded.clip_category,
+				idle_minutes = excluded.idle_minutes,
+				multiple_timers = excluded.multiple_timers
+		RETURNING
+			user_id,
+			accessible,
+			display_name,
+			timezone,
+			language,
+			digest_hour,
+			weekly_capacity,
+			context,
+			email,
+			clip_category,
+			idle_minutes,
+			multiple_timers`,
+		prefs.UserID,
+		prefs.Accessible,
+		displayName,
+		prefs.Timezone,
+		lang,
+		string(notifications),
+		prefs.DigestHour,
+		prefs.WeeklyCapacity,
+		context,
+		email,
+		clipCategory,
+		prefs.IdleMinutes,
+		prefs.MultipleTimers,
+	).Scan(
+		&updated.UserID,
+		&updated.Accessible,
+		&updated.DisplayName,
+		&updated.Timezone,
+		&updated.Language,
+		&updated.DigestHour,
+		&updated.WeeklyCapacity,
+		&updated.Context,
+		&updated.Email,
+		&updated.ClipCategory,
+		&updated.IdleMinutes,
+		&updated.MultipleTimers,
+	); err != nil {
+		return nil, err
+	}
+	updated.Notifications = prefs.Notifications
+	return &updated, nil
 }
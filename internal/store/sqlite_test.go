@@ -0,0 +1,179 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// newTestStore opens a store over a database in a temporary directory, with
+// a clock that only moves when the test moves it
+func newTestStore(t *testing.T) (*SQLiteStore, *domain.FakeClock) {
+	t.Helper()
+	clock := domain.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "compass.db"), false, clock, domain.NewSeededIDs(1))
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, clock
+}
+
+// count is the number of rows in table matching where
+func count(t *testing.T, s *SQLiteStore, table, where string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+where, args...).Scan(&n); err != nil {
+		t.Fatalf("counting %s: %v", table, err)
+	}
+	return n
+}
+
+// board is a category holding a task with a subtask, and work logged on
+// both
+type board struct {
+	category *domain.Category
+	task     *domain.Task
+	subtask  *domain.Subtask
+}
+
+func addBoard(t *testing.T, s *SQLiteStore, name string) board {
+	t.Helper()
+	c, err := s.AddCategory(name, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.AddTask(c.ID, "Turn the compost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := s.AddSubtask(task.ID, "Find the fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkLogForTask(task.ID, 1, "Turned half", 50, nil, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkLogForSubtask(sub.ID, 0.5, "Looked in the shed", 100, nil, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	return board{c, task, sub}
+}
+
+func TestForeignKeysAreEnforced(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	var on bool
+	if err := s.db.QueryRow("PRAGMA foreign_keys").Scan(&on); err != nil {
+		t.Fatal(err)
+	}
+	if !on {
+		t.Fatal("foreign keys are off")
+	}
+	if _, err := s.AddTask("00000000-0000-4000-8000-000000000000", "Nowhere"); err == nil {
+		t.Error("added a task to a category that doesn't exist")
+	}
+}
+
+func TestDeletingCategoryCascades(t *testing.T) {
+	s, _ := newTestStore(t)
+	doomed := addBoard(t, s, "Garden")
+	kept := addBoard(t, s, "Kitchen")
+
+	if _, err := s.DeleteCategory(doomed.category.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		table string
+		where string
+	}{
+		{"tasks", "category_id = ?1"},
+		{"subtasks", "category_id = ?1"},
+		{"work_logs", "category_id = ?1"},
+	} {
+		if n := count(t, s, tc.table, tc.where, doomed.category.ID); n != 0 {
+			t.Errorf("%d %s left behind in the deleted category", n, tc.table)
+		}
+		if n := count(t, s, tc.table, tc.where, kept.category.ID); n == 0 {
+			t.Errorf("the %s of the other category went with it", tc.table)
+		}
+	}
+}
+
+func TestDeletingTaskCascades(t *testing.T) {
+	s, _ := newTestStore(t)
+	b := addBoard(t, s, "Garden")
+
+	if _, err := s.DeleteTask(b.task.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "subtasks", "task_id = ?1", b.task.ID); n != 0 {
+		t.Errorf("%d subtasks left behind", n)
+	}
+	if n := count(t, s, "work_logs", "task_id = ?1", b.task.ID); n != 0 {
+		t.Errorf("%d work logs left behind", n)
+	}
+}
+
+// TestOrphanPurge runs the migration that clears rows orphaned while foreign
+// keys were off against a database with some
+func TestOrphanPurge(t *testing.T) {
+	s, _ := newTestStore(t)
+	kept := addBoard(t, s, "Kitchen")
+	orphaned := addBoard(t, s, "Garden")
+
+	if _, err := s.db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("DELETE FROM categories WHERE id = ?1", orphaned.category.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("DELETE FROM subtasks WHERE id = ?1", kept.subtask.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, s, "tasks", "category_id = ?1", orphaned.category.ID); n == 0 {
+		t.Fatal("deleting with foreign keys off should have left orphans to purge")
+	}
+
+	if _, err := s.db.Exec(migrations[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		what  string
+		table string
+		where string
+		arg   string
+		want  int
+	}{
+		{"tasks of the deleted category", "tasks", "category_id = ?1", orphaned.category.ID, 0},
+		{"subtasks of the deleted category", "subtasks", "category_id = ?1", orphaned.category.ID, 0},
+		{"work logs of the deleted category", "work_logs", "category_id = ?1", orphaned.category.ID, 0},
+		{"work logs of the deleted subtask", "work_logs", "subtask_id = ?1", kept.subtask.ID, 0},
+		{"the other category's task", "tasks", "id = ?1", kept.task.ID, 1},
+		{"the other task's own work log", "work_logs", "task_id = ?1 AND subtask_id IS NULL", kept.task.ID, 1},
+	} {
+		if n := count(t, s, tc.table, tc.where, tc.arg); n != tc.want {
+			t.Errorf("%s: %d rows, want %d", tc.what, n, tc.want)
+		}
+	}
+
+	var violations int
+	rows, err := s.db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		violations++
+	}
+	rows.Close()
+	if violations != 0 {
+		t.Errorf("%d foreign key violations remain", violations)
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) SetStatsToken(userID string, token string) error {
+	if token == "" {
+		_, err := s.db.Exec("DELETE FROM stats_links WHERE user_id = ?1", userID)
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO stats_links (user_id, token, created_at)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			token = excluded.token,
+			created_at = excluded.created_at`,
+		userID,
+		token,
+		s.clock.Now().Unix(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetStatsToken(userID string) (string, error) {
+	var token string
+	err := s.db.QueryRow("SELECT token FROM stats_links WHERE user_id = ?1", userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return token, err
+}
+
+func (s *SQLiteStore) GetStatsTokenUser(token string) (string, error) {
+	var userID string
+	err := s.db.QueryRow("SELECT user_id FROM stats_links WHERE token = ?1", token).Scan(&userID)
+	if err != nil {
+		return "", notFound(err, "stats link")
+	}
+	return userID, nil
+}
+
+func (s *SQLiteStore) GetBoardStats(userID string, hidden []string, since time.Time) (*domain.BoardStats, error) {
+	// The hidden IDs go in as one JSON array, however many there are
+	hiddenJSON, err := json.Marshal(append([]string{}, hidden...))
+	if err != nil {
+		return nil, err
+	}
+
+	var stats domain.BoardStats
+	if err := s.db.QueryRow(`
+		WITH
+			hidden AS (SELECT value AS id FROM json_each(?3)),
+			items AS (
+				SELECT completion, estimate
+				FROM tasks
+				WHERE category_id NOT IN hidden
+			)
+		SELECT
+			(SELECT COUNT(*) FROM categories WHERE id NOT IN hidden),
+			(SELECT COUNT(*) FROM items),
+			(SELECT COUNT(*) FROM items WHERE completion < 100),
+			COALESCE(`+weightedCompletion+`, 0),
+			(SELECT COALESCE(SUM(hours_worked), 0) FROM work_logs WHERE author = ?1 AND created_at >= ?2)`,
+		userID,
+		since.Unix(),
+		string(hiddenJSON),
+	).Scan(
+		&stats.Categories,
+		&stats.Tasks,
+		&stats.OpenTasks,
+		&stats.Completion,
+		&stats.HoursThisWeek,
+	); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *SQLiteStore) SetCategoryShareToken(catID string, token string) error {
+	err := s.db.QueryRow(`
+		UPDATE categories
+		SET share_token = ?2
+		WHERE id = ?1
+		RETURNING id`,
+		catID,
+		token,
+	).Scan(&catID)
+	return notFound(err, "category")
+}
+
+func (s *SQLiteStore) GetCategoryByShareToken(token string) (*domain.Category, error) {
+	var id string
+	if err := s.db.QueryRow(`
+		SELECT id
+		FROM categories
+		WHERE share_token = ?1 AND share_token != ''`,
+		token,
+	).Scan(&id); err != nil {
+		return nil, notFound(err, "category")
+	}
+	return s.GetCategory(id)
+}
+
+func (s *SQLiteStore) GetLoggedHours(from, to time.Time) ([]*domain.LoggedHours, error) {
+	rows, err := s.db.Query(`
+		SELECT created_at, hours_worked, author, category_id
+		FROM work_logs
+		WHERE created_at >= ?1 AND created_at < ?2
+		ORDER BY created_at ASC`,
+		from.Unix(),
+		to.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logged []*domain.LoggedHours
+	for rows.Next() {
+		var l domain.LoggedHours
+		var at int64
+		if err := rows.Scan(&at, &l.Hours, &l.Author, &l.CategoryID); err != nil {
+			return nil, err
+		}
+		l.At = time.Unix(at, 0).UTC()
+		logged = append(logged, &l)
+	}
+	return logged, rows.Err()
+}
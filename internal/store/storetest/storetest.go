@@ -0,0 +1,448 @@
+// Package storetest holds the behaviour every domain.Store must share, as
+// tables of checks a backend's own tests run against fresh stores of its
+// kind. A check sees only the domain.Store interface.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Open returns an empty store for t, closed when t ends
+type Open func(t *testing.T) domain.Store
+
+// Check is one behaviour, tried against a store of its own
+type Check struct {
+	Name string
+	Run  func(t *testing.T, s domain.Store)
+}
+
+// Run tries every check against a fresh store from open
+func Run(t *testing.T, open Open) {
+	for _, group := range []struct {
+		name   string
+		checks []Check
+	}{
+		{"ordering", Ordering},
+		{"cascades", Cascades},
+		{"roll-ups", RollUps},
+		{"concurrency", Concurrency},
+	} {
+		t.Run(group.name, func(t *testing.T) {
+			for _, c := range group.checks {
+				t.Run(c.Name, func(t *testing.T) {
+					c.Run(t, open(t))
+				})
+			}
+		})
+	}
+}
+
+// Ordering covers where new items go and how reorders and merges move them
+var Ordering = []Check{
+	{"categories are added at the top", func(t *testing.T, s domain.Store) {
+		for _, name := range []string{"Kitchen", "Garden", "Shed"} {
+			category(t, s, name)
+		}
+		categoryOrder(t, s, "Shed", "Garden", "Kitchen")
+	}},
+	{"tasks and subtasks are added at the bottom", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		first := task(t, s, c.ID, "Turn the compost")
+		task(t, s, c.ID, "Sow the beans")
+		subtask(t, s, first.ID, "Find the fork")
+		subtask(t, s, first.ID, "Wheel the barrow")
+		taskOrder(t, s, c.ID, "Turn the compost", "Sow the beans")
+		subtaskOrder(t, s, first.ID, "Find the fork", "Wheel the barrow")
+	}},
+	{"reorders take the order given", func(t *testing.T, s domain.Store) {
+		kitchen := category(t, s, "Kitchen")
+		garden := category(t, s, "Garden")
+		compost := task(t, s, garden.ID, "Turn the compost")
+		beans := task(t, s, garden.ID, "Sow the beans")
+		fork := subtask(t, s, compost.ID, "Find the fork")
+		barrow := subtask(t, s, compost.ID, "Wheel the barrow")
+
+		if err := s.ReorderCategories([]string{kitchen.ID, garden.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReorderTasks(garden.ID, []string{beans.ID, compost.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReorderSubtasks(compost.ID, []string{barrow.ID, fork.ID}); err != nil {
+			t.Fatal(err)
+		}
+		categoryOrder(t, s, "Kitchen", "Garden")
+		taskOrder(t, s, garden.ID, "Sow the beans", "Turn the compost")
+		subtaskOrder(t, s, compost.ID, "Wheel the barrow", "Find the fork")
+	}},
+	{"a stale reorder is refused", func(t *testing.T, s domain.Store) {
+		kitchen := category(t, s, "Kitchen")
+		kettle := task(t, s, kitchen.ID, "Descale the kettle")
+		garden := category(t, s, "Garden")
+		compost := task(t, s, garden.ID, "Turn the compost")
+		beans := task(t, s, garden.ID, "Sow the beans")
+
+		for _, ids := range [][]string{
+			{beans.ID},
+			{beans.ID, beans.ID},
+			{beans.ID, compost.ID, kettle.ID},
+			{beans.ID, kettle.ID},
+		} {
+			if err := s.ReorderTasks(garden.ID, ids); !errors.Is(err, domain.ErrStaleOrder) {
+				t.Errorf("reordering to %v: got %v, want ErrStaleOrder", ids, err)
+			}
+		}
+		taskOrder(t, s, garden.ID, "Turn the compost", "Sow the beans")
+	}},
+	{"merging puts the moved tasks after the target's own", func(t *testing.T, s domain.Store) {
+		shed := category(t, s, "Shed")
+		task(t, s, shed.ID, "Oil the shears")
+		garden := category(t, s, "Garden")
+		task(t, s, garden.ID, "Turn the compost")
+		task(t, s, garden.ID, "Sow the beans")
+
+		if _, err := s.MergeCategories(shed.ID, garden.ID, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		taskOrder(t, s, garden.ID, "Turn the compost", "Sow the beans", "Oil the shears")
+		categoryOrder(t, s, "Garden")
+	}},
+}
+
+// Cascades covers what goes with a deleted or merged item
+var Cascades = []Check{
+	{"deleting a category takes everything in it", func(t *testing.T, s domain.Store) {
+		kitchen := board(t, s, "Kitchen")
+		garden := board(t, s, "Garden")
+
+		if _, err := s.DeleteCategory(garden.category.ID, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetCategory(garden.category.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("getting the category: got %v, want ErrNotFound", err)
+		}
+		if _, err := s.GetTask(garden.task.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("getting its task: got %v, want ErrNotFound", err)
+		}
+		if _, err := s.GetSubtask(garden.subtask.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("getting its subtask: got %v, want ErrNotFound", err)
+		}
+		workLogs(t, s, garden.category.ID, 0)
+		workLogs(t, s, kitchen.category.ID, 2)
+		categoryOrder(t, s, "Kitchen")
+	}},
+	{"deleting a task takes its subtasks and work logs", func(t *testing.T, s domain.Store) {
+		b := board(t, s, "Garden")
+		other := task(t, s, b.category.ID, "Sow the beans")
+
+		if _, err := s.DeleteTask(b.task.ID, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetSubtask(b.subtask.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("getting its subtask: got %v, want ErrNotFound", err)
+		}
+		workLogs(t, s, b.category.ID, 0)
+		taskOrder(t, s, b.category.ID, other.Name)
+	}},
+	{"merging moves subtasks and work logs with their tasks", func(t *testing.T, s domain.Store) {
+		shed := board(t, s, "Shed")
+		garden := board(t, s, "Garden")
+
+		if _, err := s.MergeCategories(shed.category.ID, garden.category.ID, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetCategory(shed.category.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("getting the merged category: got %v, want ErrNotFound", err)
+		}
+		sub, err := s.GetSubtask(shed.subtask.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.CategoryID != garden.category.ID {
+			t.Errorf("the subtask is in %s, want %s", sub.CategoryID, garden.category.ID)
+		}
+		workLogs(t, s, garden.category.ID, 4)
+	}},
+}
+
+// RollUps covers the completion the store keeps for tasks and categories
+var RollUps = []Check{
+	{"a task without subtasks keeps its own completion", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		tk := task(t, s, c.ID, "Turn the compost")
+		if _, err := s.AddWorkLogForTask(tk.ID, 1, "Turned half", 50, nil, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		completion(t, s, tk.ID, 50)
+		categoryCompletion(t, s, c.ID, 50)
+	}},
+	{"a task's completion is its subtasks' average", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		tk := task(t, s, c.ID, "Turn the compost")
+		fork := subtask(t, s, tk.ID, "Find the fork")
+		subtask(t, s, tk.ID, "Wheel the barrow")
+
+		if _, err := s.AddWorkLogForSubtask(fork.ID, 0.5, "Found it", 100, nil, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		completion(t, s, tk.ID, 50)
+		categoryCompletion(t, s, c.ID, 50)
+	}},
+	{"estimates weigh subtasks", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		tk := task(t, s, c.ID, "Turn the compost")
+		fork := subtask(t, s, tk.ID, "Find the fork")
+		barrow := subtask(t, s, tk.ID, "Wheel the barrow")
+		estimate(t, s, fork.ID, 3, 100)
+		estimate(t, s, barrow.ID, 1, 0)
+		completion(t, s, tk.ID, 75)
+	}},
+	{"a category's completion is its tasks' average", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		for i, done := range []int{100, 50, 0} {
+			tk := task(t, s, c.ID, fmt.Sprintf("Bed %d", i))
+			if _, err := s.AddWorkLogForTask(tk.ID, 1, "Dug", done, nil, "ana"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		categoryCompletion(t, s, c.ID, 50)
+	}},
+	{"deleting a subtask rolls the rest back up", func(t *testing.T, s domain.Store) {
+		c := category(t, s, "Garden")
+		tk := task(t, s, c.ID, "Turn the compost")
+		fork := subtask(t, s, tk.ID, "Find the fork")
+		barrow := subtask(t, s, tk.ID, "Wheel the barrow")
+		if _, err := s.AddWorkLogForSubtask(fork.ID, 0.5, "Found it", 100, nil, "ana"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.DeleteSubtask(barrow.ID, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		completion(t, s, tk.ID, 100)
+		categoryCompletion(t, s, c.ID, 100)
+	}},
+}
+
+// Concurrency covers writes arriving at once
+var Concurrency = []Check{
+	{"tasks added at once all land", func(t *testing.T, s domain.Store) {
+		const writers, each = 8, 5
+		c := category(t, s, "Garden")
+
+		errs := make(chan error, writers*each)
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := range each {
+					if _, err := s.AddTask(c.ID, fmt.Sprintf("Bed %d-%d", i, n)); err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+
+		got, err := s.GetCategory(c.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Tasks) != writers*each {
+			t.Fatalf("%d tasks, want %d", len(got.Tasks), writers*each)
+		}
+		ids := make([]string, len(got.Tasks))
+		for i, tk := range got.Tasks {
+			ids[len(ids)-1-i] = tk.ID
+		}
+		if err := s.ReorderTasks(c.ID, ids); err != nil {
+			t.Errorf("reversing the tasks: %v", err)
+		}
+	}},
+	{"work logged at once is all counted", func(t *testing.T, s domain.Store) {
+		const writers, each = 8, 5
+		c := category(t, s, "Garden")
+		tk := task(t, s, c.ID, "Turn the compost")
+
+		errs := make(chan error, writers*each)
+		var wg sync.WaitGroup
+		for range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range each {
+					if _, err := s.AddWorkLogForTask(tk.ID, 0.25, "Turned some", 10, nil, "ana"); err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+
+		logs := workLogs(t, s, c.ID, writers*each)
+		var hours float64
+		for _, l := range logs {
+			hours += l.HoursWorked
+		}
+		if hours != 0.25*writers*each {
+			t.Errorf("%v hours logged, want %v", hours, 0.25*writers*each)
+		}
+	}},
+}
+
+// fixture is a category holding a task with a subtask, and work logged on
+// both
+type fixture struct {
+	category *domain.Category
+	task     *domain.Task
+	subtask  *domain.Subtask
+}
+
+func board(t *testing.T, s domain.Store, name string) fixture {
+	t.Helper()
+	c := category(t, s, name)
+	tk := task(t, s, c.ID, "Turn the compost")
+	sub := subtask(t, s, tk.ID, "Find the fork")
+	if _, err := s.AddWorkLogForTask(tk.ID, 1, "Turned half", 50, nil, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddWorkLogForSubtask(sub.ID, 0.5, "Looked in the shed", 100, nil, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	return fixture{category: c, task: tk, subtask: sub}
+}
+
+func category(t *testing.T, s domain.Store, name string) *domain.Category {
+	t.Helper()
+	c, err := s.AddCategory(name, "ana")
+	if err != nil {
+		t.Fatalf("adding category %q: %v", name, err)
+	}
+	return c
+}
+
+func task(t *testing.T, s domain.Store, categoryID, name string) *domain.Task {
+	t.Helper()
+	tk, err := s.AddTask(categoryID, name)
+	if err != nil {
+		t.Fatalf("adding task %q: %v", name, err)
+	}
+	return tk
+}
+
+func subtask(t *testing.T, s domain.Store, taskID, name string) *domain.Subtask {
+	t.Helper()
+	sub, err := s.AddSubtask(taskID, name)
+	if err != nil {
+		t.Fatalf("adding subtask %q: %v", name, err)
+	}
+	return sub
+}
+
+// estimate sets a subtask's estimate and completion
+func estimate(t *testing.T, s domain.Store, subtaskID string, hours float64, done int) {
+	t.Helper()
+	sub, err := s.GetSubtask(subtaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Estimate = hours
+	sub.Completion = done
+	if _, err := s.UpdateSubtask(sub, "ana"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func workLogs(t *testing.T, s domain.Store, categoryID string, want int) []*domain.WorkLog {
+	t.Helper()
+	logs, err := s.GetWorkLogsForCategory(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != want {
+		t.Errorf("%d work logs in the category, want %d", len(logs), want)
+	}
+	return logs
+}
+
+func completion(t *testing.T, s domain.Store, taskID string, want int) {
+	t.Helper()
+	tk, err := s.GetTask(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Completion != want {
+		t.Errorf("the task is %d%% done, want %d%%", tk.Completion, want)
+	}
+}
+
+func categoryCompletion(t *testing.T, s domain.Store, categoryID string, want int) {
+	t.Helper()
+	c, err := s.GetCategory(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Completion != want {
+		t.Errorf("the category is %d%% done, want %d%%", c.Completion, want)
+	}
+}
+
+func categoryOrder(t *testing.T, s domain.Store, want ...string) {
+	t.Helper()
+	categories, err := s.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("categories %v, want %v", names, want)
+	}
+}
+
+func taskOrder(t *testing.T, s domain.Store, categoryID string, want ...string) {
+	t.Helper()
+	c, err := s.GetCategory(categoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tk := range c.Tasks {
+		names = append(names, tk.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("tasks %v, want %v", names, want)
+	}
+}
+
+func subtaskOrder(t *testing.T, s domain.Store, taskID string, want ...string) {
+	t.Helper()
+	tk, err := s.GetTask(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sub := range tk.Subtasks {
+		names = append(names, sub.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("subtasks %v, want %v", names, want)
+	}
+}
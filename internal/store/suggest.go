@@ -0,0 +1,29 @@
+package store
+
+import "time"
+
+// GetLastWorked maps task IDs to when work was last logged on them or any of
+// their subtasks. Tasks that were never worked on are left out.
+func (s *SQLiteStore) GetLastWorked() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`
+		SELECT task_id, MAX(created_at)
+		FROM work_logs
+		WHERE corrects_id IS NULL
+		GROUP BY task_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lastWorked := make(map[string]time.Time)
+	for rows.Next() {
+		var taskID string
+		var at int64
+		if err := rows.Scan(&taskID, &at); err != nil {
+			return nil, err
+		}
+		lastWorked[taskID] = time.Unix(at, 0)
+	}
+	return lastWorked, rows.Err()
+}
@@ -0,0 +1,783 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// placeholderBoardName names a board joined before its first sync
+const placeholderBoardName = "Shared board"
+
+// What a board's change log carries of each entity. Work logs, checklists,
+// and the rest stay with the instance they were made on.
+type (
+	syncedCategory struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+		Color       string `json:"color"`
+		Icon        string `json:"icon"`
+	}
+	syncedTask struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Completion  int     `json:"completion"`
+		Public      bool    `json:"public"`
+		SortOrder   int     `json:"sort_order"`
+		Estimate    float64 `json:"estimate"`
+		StartDate   string  `json:"start_date"`
+		DueDate     string  `json:"due_date"`
+		Size        string  `json:"size"`
+		Priority    int     `json:"priority"`
+
+		SubtaskCheckboxes bool `json:"subtask_checkboxes,omitempty"`
+	}
+	syncedSubtask struct {
+		TaskID      string  `json:"task_id"`
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Completion  int     `json:"completion"`
+		Public      bool    `json:"public"`
+		SortOrder   int     `json:"sort_order"`
+		Estimate    float64 `json:"estimate"`
+		DueDate     string  `json:"due_date,omitempty"` // left out when blank, so boards from before hash the same
+	}
+)
+
+// syncEntity is an entity as the change log sees it, keyed by type and ID
+type syncEntity struct {
+	Type string
+	ID   string
+	Data []byte
+	Hash string
+}
+
+func newSyncEntity(entityType, id string, v any) (syncEntity, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return syncEntity{}, err
+	}
+	sum := sha256.Sum256(data)
+	return syncEntity{Type: entityType, ID: id, Data: data, Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+func (s *SQLiteStore) AddSyncPeer(p *domain.SyncPeer, actor string) (*domain.SyncPeer, error) {
+	name, err := domain.CleanName(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p.CategoryID == "" {
+		return nil, fmt.Errorf("%w: a sync peer needs a board", domain.ErrInvalid)
+	}
+	if p.Secret == "" {
+		return nil, fmt.Errorf("%w: a sync peer needs a secret", domain.ErrInvalid)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// A board being joined is created empty, belonging to whoever joined it
+	// and recorded as changed at the epoch so that anything the peer has
+	// wins over it
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?1)", p.CategoryID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		var minOrder sql.NullInt64
+		if err := tx.QueryRow("SELECT MIN(sort_order) FROM categories").Scan(&minOrder); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO categories (id, name, sort_order, owner_id)
+			VALUES (?1, ?2, ?3, ?4)`,
+			p.CategoryID,
+			placeholderBoardName,
+			minOrder.Int64-1,
+			actor,
+		); err != nil {
+			return nil, err
+		}
+		current, err := currentSyncEntities(tx, p.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if err := putSyncState(tx, p.CategoryID, current[0], time.Unix(0, 0)); err != nil {
+			return nil, err
+		}
+	}
+
+	var id string
+	if err := tx.QueryRow(`
+		INSERT INTO sync_peers (id, category_id, name, url, secret, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+		RETURNING id`,
+		s.ids.NewID(),
+		p.CategoryID,
+		name,
+		p.URL,
+		p.Secret,
+		s.clock.Now().Unix(),
+	).Scan(&id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetSyncPeer(id)
+}
+
+func (s *SQLiteStore) GetSyncPeer(id string) (*domain.SyncPeer, error) {
+	peers, err := s.getSyncPeers("id = ?1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("sync peer %w", domain.ErrNotFound)
+	}
+	return peers[0], nil
+}
+
+func (s *SQLiteStore) GetSyncPeers(categoryID string) ([]*domain.SyncPeer, error) {
+	return s.getSyncPeers("category_id = ?1", categoryID)
+}
+
+func (s *SQLiteStore) GetAllSyncPeers() ([]*domain.SyncPeer, error) {
+	return s.getSyncPeers("1")
+}
+
+func (s *SQLiteStore) DeleteSyncPeer(id string) error {
+	err := s.db.QueryRow(`
+		DELETE FROM sync_peers
+		WHERE id = ?1
+		RETURNING id`,
+		id,
+	).Scan(&id)
+	return notFound(err, "sync peer")
+}
+
+// RecordSyncResult notes how far a sync with the peer got and whether it
+// failed
+func (s *SQLiteStore) RecordSyncResult(id string, pushedSeq, pulledSeq int64, syncErr string) error {
+	err := s.db.QueryRow(`
+		UPDATE sync_peers
+		SET pushed_seq = ?1,
+			pulled_seq = ?2,
+			last_error = ?3,
+			synced_at = ?4
+		WHERE id = ?5
+		RETURNING id`,
+		pushedSeq,
+		pulledSeq,
+		syncErr,
+		s.clock.Now().Unix(),
+		id,
+	).Scan(&id)
+	return notFound(err, "sync peer")
+}
+
+func (s *SQLiteStore) getSyncPeers(where string, args ...any) ([]*domain.SyncPeer, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id,
+			category_id,
+			name,
+			url,
+			secret,
+			pushed_seq,
+			pulled_seq,
+			created_at,
+			synced_at,
+			last_error
+		FROM sync_peers
+		WHERE `+where+`
+		ORDER BY created_at, rowid`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peers []*domain.SyncPeer
+	for rows.Next() {
+		var p domain.SyncPeer
+		var createdAt, syncedAt int64
+		if err := rows.Scan(
+			&p.ID,
+			&p.CategoryID,
+			&p.Name,
+			&p.URL,
+			&p.Secret,
+			&p.PushedSeq,
+			&p.PulledSeq,
+			&createdAt,
+			&syncedAt,
+			&p.LastError,
+		); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = time.Unix(createdAt, 0)
+		if syncedAt > 0 {
+			p.SyncedAt = time.Unix(syncedAt, 0)
+		}
+		peers = append(peers, &p)
+	}
+	return peers, rows.Err()
+}
+
+// RecordSyncChanges logs every entity on the board that differs from its
+// recorded state as changed now, and every one that has gone as deleted
+func (s *SQLiteStore) RecordSyncChanges(categoryID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := currentSyncEntities(tx, categoryID)
+	if err != nil {
+		return err
+	}
+	recorded, err := syncStates(tx, categoryID)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	seen := make(map[syncKey]bool, len(current))
+	for _, e := range current {
+		key := syncKey{e.Type, e.ID}
+		seen[key] = true
+		if st, ok := recorded[key]; ok && st.Hash == e.Hash {
+			continue
+		}
+		if err := logSyncChange(tx, categoryID, e, now); err != nil {
+			return err
+		}
+	}
+	for key, st := range recorded {
+		if seen[key] || st.Hash == "" {
+			continue
+		}
+		gone := syncEntity{Type: key.Type, ID: key.ID}
+		if err := logSyncChange(tx, categoryID, gone, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetSyncChanges(categoryID string, since int64, limit int) ([]*domain.SyncChange, error) {
+	rows, err := s.db.Query(`
+		SELECT seq, entity_type, entity_id, deleted, data, changed_at
+		FROM sync_changes
+		WHERE category_id = ?1 AND seq > ?2
+		ORDER BY seq
+		LIMIT ?3`,
+		categoryID,
+		since,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*domain.SyncChange{}
+	for rows.Next() {
+		var c domain.SyncChange
+		var data string
+		var changedAt int64
+		if err := rows.Scan(&c.Seq, &c.EntityType, &c.EntityID, &c.Deleted, &data, &changedAt); err != nil {
+			return nil, err
+		}
+		if !c.Deleted {
+			c.Data = json.RawMessage(data)
+		}
+		c.ChangedAt = time.Unix(changedAt, 0)
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+// ApplySyncChanges merges a batch from the peer into its board. An entity
+// changed on both sides keeps the later change; a tie goes to the change
+// whose content hashes higher, so both sides settle on the same one, and a
+// deletion loses a tie to an edit. The board itself is never deleted by a
+// peer.
+func (s *SQLiteStore) ApplySyncChanges(peerID string, changes []*domain.SyncChange) (int, error) {
+	peer, err := s.GetSyncPeer(peerID)
+	if err != nil {
+		return 0, err
+	}
+	board := peer.CategoryID
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	recorded, err := syncStates(tx, board)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	tasks := map[string]bool{}
+	for _, c := range changes {
+		e, err := decodeSyncChange(board, c)
+		if err != nil {
+			return 0, err
+		}
+		if e.Type == domain.SyncCategory && (e.ID != board || c.Deleted) {
+			continue
+		}
+		key := syncKey{e.Type, e.ID}
+		if r, ok := recorded[key]; ok {
+			if r.Hash == e.Hash {
+				continue
+			}
+			at := c.ChangedAt.Unix()
+			if r.ChangedAt > at || (r.ChangedAt == at && r.Hash > e.Hash) {
+				continue
+			}
+		}
+
+		taskID, err := s.applySyncEntity(tx, board, e)
+		if err != nil {
+			return 0, err
+		}
+		if taskID != "" {
+			tasks[taskID] = true
+		}
+		if err := logSyncChange(tx, board, e, c.ChangedAt); err != nil {
+			return 0, err
+		}
+		recorded[key] = syncState{Hash: e.Hash, ChangedAt: c.ChangedAt.Unix()}
+		applied++
+	}
+	if applied == 0 {
+		return 0, nil
+	}
+
+	for id := range tasks {
+		if err := refreshTaskCompletion(tx, id, board); err != nil {
+			return 0, err
+		}
+	}
+	if err := refreshCategoryCompletion(tx, board); err != nil {
+		return 0, err
+	}
+	summary := fmt.Sprintf("applied %d changes from %s", applied, peer.Name)
+	if err := s.audit(tx, "sync: "+peer.Name, "sync", domain.EntityCategory, board, summary); err != nil {
+		return 0, err
+	}
+	return applied, tx.Commit()
+}
+
+// decodeSyncChange checks a peer's change and puts its data in the form
+// this instance would record it in, so that its hash matches once applied
+func decodeSyncChange(board string, c *domain.SyncChange) (syncEntity, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %s %s: %s", domain.ErrInvalid, c.EntityType, c.EntityID, reason)
+	}
+	if c.EntityID == "" {
+		return syncEntity{}, invalid("no ID")
+	}
+	if c.Deleted {
+		switch c.EntityType {
+		case domain.SyncCategory, domain.SyncTask, domain.SyncSubtask:
+			return syncEntity{Type: c.EntityType, ID: c.EntityID}, nil
+		}
+		return syncEntity{}, invalid("unknown entity")
+	}
+
+	switch c.EntityType {
+	case domain.SyncCategory:
+		var v syncedCategory
+		if err := json.Unmarshal(c.Data, &v); err != nil {
+			return syncEntity{}, invalid(err.Error())
+		}
+		cat := domain.Category{Name: v.Name, Description: v.Description, Color: v.Color, Icon: v.Icon}
+		if err := cat.Normalize(); err != nil {
+			return syncEntity{}, err
+		}
+		v.Name, v.Description = cat.Name, cat.Description
+		return newSyncEntity(c.EntityType, c.EntityID, v)
+
+	case domain.SyncTask:
+		var v syncedTask
+		if err := json.Unmarshal(c.Data, &v); err != nil {
+			return syncEntity{}, invalid(err.Error())
+		}
+		if v.Completion < 0 || v.Completion > 100 {
+			return syncEntity{}, invalid("completion out of range")
+		}
+		t := domain.Task{
+			Name:        v.Name,
+			Description: v.Description,
+			Estimate:    v.Estimate,
+			StartDate:   v.StartDate,
+			DueDate:     v.DueDate,
+			Size:        v.Size,
+			Priority:    v.Priority,
+		}
+		if err := t.Normalize(); err != nil {
+			return syncEntity{}, err
+		}
+		v.Name, v.Description, v.StartDate, v.DueDate = t.Name, t.Description, t.StartDate, t.DueDate
+		return newSyncEntity(c.EntityType, c.EntityID, v)
+
+	case domain.SyncSubtask:
+		var v syncedSubtask
+		if err := json.Unmarshal(c.Data, &v); err != nil {
+			return syncEntity{}, invalid(err.Error())
+		}
+		if v.Completion < 0 || v.Completion > 100 {
+			return syncEntity{}, invalid("completion out of range")
+		}
+		st := domain.Subtask{Name: v.Name, Description: v.Description, Estimate: v.Estimate, DueDate: v.DueDate}
+		if err := st.Normalize(); err != nil {
+			return syncEntity{}, err
+		}
+		v.Name, v.Description, v.DueDate = st.Name, st.Description, st.DueDate
+		return newSyncEntity(c.EntityType, c.EntityID, v)
+	}
+	return syncEntity{}, invalid("unknown entity")
+}
+
+// applySyncEntity writes a peer's change to the board, returning the task
+// whose completion may need refreshing. Changes to tasks and subtasks that
+// live outside the board are ignored.
+func (s *SQLiteStore) applySyncEntity(tx *sql.Tx, board string, e syncEntity) (string, error) {
+	switch {
+	case e.Type == domain.SyncCategory:
+		var v syncedCategory
+		if err := json.Unmarshal(e.Data, &v); err != nil {
+			return "", err
+		}
+		_, err := tx.Exec(`
+			UPDATE categories
+			SET name = ?1,
+				description = ?2,
+				public = ?3,
+				color = ?4,
+				icon = ?5
+			WHERE id = ?6`,
+			v.Name,
+			v.Description,
+			v.Public,
+			v.Color,
+			v.Icon,
+			board,
+		)
+		return "", err
+
+	case e.Type == domain.SyncTask && e.Hash == "":
+		_, err := tx.Exec("DELETE FROM tasks WHERE id = ?1 AND category_id = ?2", e.ID, board)
+		return "", err
+
+	case e.Type == domain.SyncTask:
+		var v syncedTask
+		if err := json.Unmarshal(e.Data, &v); err != nil {
+			return "", err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO tasks (
+				id,
+				category_id,
+				name,
+				description,
+				completion,
+				public,
+				sort_order,
+				estimate,
+				start_date,
+				due_date,
+				size,
+				priority,
+				subtask_checkboxes,
+				created_at
+			)
+			VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14)
+			ON CONFLICT (id) DO UPDATE SET
+				name = excluded.name,
+				description = excluded.description,
+				completion = excluded.completion,
+				public = excluded.public,
+				sort_order = excluded.sort_order,
+				estimate = excluded.estimate,
+				start_date = excluded.start_date,
+				due_date = excluded.due_date,
+				size = excluded.size,
+				priority = excluded.priority,
+				subtask_checkboxes = excluded.subtask_checkboxes
+			WHERE tasks.category_id = excluded.category_id`,
+			e.ID,
+			board,
+			v.Name,
+			v.Description,
+			v.Completion,
+			v.Public,
+			v.SortOrder,
+			v.Estimate,
+			v.StartDate,
+			v.DueDate,
+			v.Size,
+			v.Priority,
+			v.SubtaskCheckboxes,
+			s.clock.Now().Unix(),
+		)
+		return e.ID, err
+
+	case e.Type == domain.SyncSubtask && e.Hash == "":
+		var taskID string
+		err := tx.QueryRow(`
+			DELETE FROM subtasks
+			WHERE id = ?1 AND category_id = ?2
+			RETURNING task_id`,
+			e.ID,
+			board,
+		).Scan(&taskID)
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return taskID, err
+
+	default:
+		var v syncedSubtask
+		if err := json.Unmarshal(e.Data, &v); err != nil {
+			return "", err
+		}
+		// Selecting from the task makes the insert a no-op when it is not
+		// on the board
+		_, err := tx.Exec(`
+			INSERT INTO subtasks (
+				id,
+				task_id,
+				category_id,
+				name,
+				description,
+				completion,
+				public,
+				sort_order,
+				estimate,
+				due_date
+			)
+			SELECT ?1, id, category_id, ?2, ?3, ?4, ?5, ?6, ?7, ?10
+			FROM tasks
+			WHERE id = ?8 AND category_id = ?9
+			ON CONFLICT (id) DO UPDATE SET
+				task_id = excluded.task_id,
+				name = excluded.name,
+				description = excluded.description,
+				completion = excluded.completion,
+				public = excluded.public,
+				sort_order = excluded.sort_order,
+				estimate = excluded.estimate,
+				due_date = excluded.due_date
+			WHERE subtasks.category_id = excluded.category_id`,
+			e.ID,
+			v.Name,
+			v.Description,
+			v.Completion,
+			v.Public,
+			v.SortOrder,
+			v.Estimate,
+			v.TaskID,
+			board,
+			v.DueDate,
+		)
+		return v.TaskID, err
+	}
+}
+
+// currentSyncEntities reads the board as the change log sees it: the board
+// first, then its tasks, then their subtasks, so a peer applying them in
+// order always has a subtask's task first
+func currentSyncEntities(tx *sql.Tx, categoryID string) ([]syncEntity, error) {
+	var c syncedCategory
+	if err := tx.QueryRow(`
+		SELECT name, description, public, color, icon
+		FROM categories
+		WHERE id = ?1`,
+		categoryID,
+	).Scan(&c.Name, &c.Description, &c.Public, &c.Color, &c.Icon); err != nil {
+		return nil, notFound(err, "category")
+	}
+	board, err := newSyncEntity(domain.SyncCategory, categoryID, c)
+	if err != nil {
+		return nil, err
+	}
+	entities := []syncEntity{board}
+
+	rows, err := tx.Query(`
+		SELECT
+			id,
+			name,
+			description,
+			completion,
+			public,
+			sort_order,
+			estimate,
+			start_date,
+			due_date,
+			size,
+			priority,
+			subtask_checkboxes
+		FROM tasks
+		WHERE category_id = ?1
+		ORDER BY sort_order, rowid`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var t syncedTask
+		if err := rows.Scan(
+			&id,
+			&t.Name,
+			&t.Description,
+			&t.Completion,
+			&t.Public,
+			&t.SortOrder,
+			&t.Estimate,
+			&t.StartDate,
+			&t.DueDate,
+			&t.Size,
+			&t.Priority,
+			&t.SubtaskCheckboxes,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e, err := newSyncEntity(domain.SyncTask, id, t)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT id, task_id, name, description, completion, public, sort_order, estimate, due_date
+		FROM subtasks
+		WHERE category_id = ?1
+		ORDER BY task_id, sort_order, rowid`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var st syncedSubtask
+		if err := rows.Scan(
+			&id,
+			&st.TaskID,
+			&st.Name,
+			&st.Description,
+			&st.Completion,
+			&st.Public,
+			&st.SortOrder,
+			&st.Estimate,
+			&st.DueDate,
+		); err != nil {
+			return nil, err
+		}
+		e, err := newSyncEntity(domain.SyncSubtask, id, st)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+type syncKey struct {
+	Type string
+	ID   string
+}
+
+// syncState is what was last recorded of an entity; Hash is empty once it
+// is deleted
+type syncState struct {
+	Hash      string
+	ChangedAt int64
+}
+
+func syncStates(tx *sql.Tx, categoryID string) (map[syncKey]syncState, error) {
+	rows, err := tx.Query(`
+		SELECT entity_type, entity_id, hash, changed_at
+		FROM sync_state
+		WHERE category_id = ?1`,
+		categoryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := map[syncKey]syncState{}
+	for rows.Next() {
+		var entityType, id string
+		var st syncState
+		if err := rows.Scan(&entityType, &id, &st.Hash, &st.ChangedAt); err != nil {
+			return nil, err
+		}
+		states[syncKey{entityType, id}] = st
+	}
+	return states, rows.Err()
+}
+
+// logSyncChange appends the entity to the board's change log and records it
+// as the entity's latest state. An entity without data is a deletion.
+func logSyncChange(tx *sql.Tx, categoryID string, e syncEntity, at time.Time) error {
+	if _, err := tx.Exec(`
+		INSERT INTO sync_changes (category_id, entity_type, entity_id, deleted, data, changed_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)`,
+		categoryID,
+		e.Type,
+		e.ID,
+		e.Hash == "",
+		string(e.Data),
+		at.Unix(),
+	); err != nil {
+		return err
+	}
+	return putSyncState(tx, categoryID, e, at)
+}
+
+func putSyncState(tx *sql.Tx, categoryID string, e syncEntity, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO sync_state (category_id, entity_type, entity_id, hash, changed_at)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (category_id, entity_type, entity_id) DO UPDATE SET
+			hash = excluded.hash,
+			changed_at = excluded.changed_at`,
+		categoryID,
+		e.Type,
+		e.ID,
+		e.Hash,
+		at.Unix(),
+	)
+	return err
+}
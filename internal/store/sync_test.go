@@ -0,0 +1,45 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func TestSyncedTaskIsCreatedOnTheStoreClock(t *testing.T) {
+	s, clock := newTestStore(t)
+	c, err := s.AddCategory("Garden", "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := s.AddSyncPeer(&domain.SyncPeer{CategoryID: c.ID, Name: "Allotment", Secret: "shh"}, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(3 * time.Hour)
+	data, err := json.Marshal(map[string]any{"name": "Turn the compost", "completion": 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "00000000-0000-4000-8000-00000000beef"
+	if _, err := s.ApplySyncChanges(peer.ID, []*domain.SyncChange{{
+		Seq:        1,
+		EntityType: domain.SyncTask,
+		EntityID:   id,
+		Data:       data,
+		ChangedAt:  clock.Now(),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := s.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("created at %v, want %v", task.CreatedAt, clock.Now())
+	}
+}
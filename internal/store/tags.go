@@ -0,0 +1,244 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) AddTag(name, color string) (*domain.Tag, error) {
+	tag := domain.Tag{ID: s.ids.NewID(), Name: name, Color: color}
+	if err := tag.Normalize(); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`
+		INSERT INTO tags (id, name, color, created_at)
+		VALUES (?1, ?2, ?3, ?4)`,
+		tag.ID,
+		tag.Name,
+		tag.Color,
+		s.clock.Now().Unix(),
+	); err != nil {
+		return nil, tagConflict(err, tag.Name)
+	}
+	return &tag, nil
+}
+
+func (s *SQLiteStore) GetTags() ([]*domain.Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id,
+			t.name,
+			t.color,
+			(SELECT COUNT(*) FROM task_tags tt WHERE tt.tag_id = t.id)
+		FROM tags t
+		ORDER BY t.name COLLATE NOCASE`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*domain.Tag
+	for rows.Next() {
+		var t domain.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.Tasks); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &t)
+	}
+	return tags, rows.Err()
+}
+
+func (s *SQLiteStore) GetTag(id string) (*domain.Tag, error) {
+	return s.getTag("id = ?1", id)
+}
+
+func (s *SQLiteStore) GetTagByName(name string) (*domain.Tag, error) {
+	name, err := domain.NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.getTag("name = ?1", name)
+}
+
+func (s *SQLiteStore) getTag(where string, args ...any) (*domain.Tag, error) {
+	var t domain.Tag
+	err := s.db.QueryRow(`
+		SELECT id, name, color
+		FROM tags
+		WHERE `+where,
+		args...,
+	).Scan(&t.ID, &t.Name, &t.Color)
+	if err != nil {
+		return nil, notFound(err, "tag")
+	}
+	return &t, nil
+}
+
+func (s *SQLiteStore) UpdateTag(tag *domain.Tag) (*domain.Tag, error) {
+	if err := tag.Normalize(); err != nil {
+		return nil, err
+	}
+	var updated domain.Tag
+	err := s.db.QueryRow(`
+		UPDATE tags
+		SET name = ?2,
+			color = ?3
+		WHERE id = ?1
+		RETURNING id, name, color`,
+		tag.ID,
+		tag.Name,
+		tag.Color,
+	).Scan(&updated.ID, &updated.Name, &updated.Color)
+	if err != nil {
+		return nil, notFound(tagConflict(err, tag.Name), "tag")
+	}
+	return &updated, nil
+}
+
+func (s *SQLiteStore) DeleteTag(id string) error {
+	_, err := s.db.Exec("DELETE FROM tags WHERE id = ?1", id)
+	return err
+}
+
+func (s *SQLiteStore) TagTask(taskID, tagID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taskExists, tagExists bool
+	if err := tx.QueryRow(`
+		SELECT
+			EXISTS (SELECT 1 FROM tasks WHERE id = ?1),
+			EXISTS (SELECT 1 FROM tags WHERE id = ?2)`,
+		taskID,
+		tagID,
+	).Scan(&taskExists, &tagExists); err != nil {
+		return err
+	}
+	if !taskExists {
+		return notFound(sql.ErrNoRows, "task")
+	}
+	if !tagExists {
+		return notFound(sql.ErrNoRows, "tag")
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO task_tags (task_id, tag_id)
+		VALUES (?1, ?2)
+		ON CONFLICT DO NOTHING`,
+		taskID,
+		tagID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) UntagTask(taskID, tagID string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM task_tags
+		WHERE task_id = ?1 AND tag_id = ?2`,
+		taskID,
+		tagID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetTasksByTag(tagID string) ([]*domain.Task, error) {
+	if _, err := s.GetTag(tagID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		SELECT t.id
+		FROM task_tags tt
+		JOIN tasks t ON t.id = tt.task_id
+		JOIN categories c ON c.id = t.category_id
+		WHERE tt.tag_id = ?1
+		ORDER BY c.sort_order, t.sort_order`,
+		tagID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	tasks := []*domain.Task{}
+	for _, id := range ids {
+		task, err := s.GetTask(id)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// getTaskTags maps the tasks matched by where, on tasks t, to their tags
+func (s *SQLiteStore) getTaskTags(where string, args ...any) (map[string][]*domain.Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT tt.task_id, g.id, g.name, g.color
+		FROM task_tags tt
+		JOIN tasks t ON t.id = tt.task_id
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE `+where+`
+		ORDER BY g.name COLLATE NOCASE`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]*domain.Tag)
+	for rows.Next() {
+		var taskID string
+		var g domain.Tag
+		if err := rows.Scan(&taskID, &g.ID, &g.Name, &g.Color); err != nil {
+			return nil, err
+		}
+		tags[taskID] = append(tags[taskID], &g)
+	}
+	return tags, rows.Err()
+}
+
+// tagConflict reports a tag name already taken as a conflict
+func tagConflict(err error, name string) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("%w: there is already a tag named %q", domain.ErrConflict, name)
+	}
+	return err
+}
+
+// existingTags drops the task_tags rows whose tag no longer exists
+func existingTags(tx *sql.Tx, rows []map[string]any) ([]map[string]any, error) {
+	var kept []map[string]any
+	for _, row := range rows {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tags WHERE id = ?1)", fmt.Sprint(row["tag_id"])).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// pauseIdleTimers pauses the running timers, of one user or of everyone if
+// ?2 is empty, whose last heartbeat is further before ?1 than their users'
+// idle thresholds. They count up to that heartbeat and no further.
+const pauseIdleTimers = `
+	UPDATE timers
+	SET elapsed = elapsed + MAX(last_seen - running_since, 0),
+		running_since = NULL,
+		idle_paused = 1
+	WHERE running_since IS NOT NULL
+		AND (?2 = '' OR user_id = ?2)
+		AND id IN (
+			SELECT t.id
+			FROM timers t
+			LEFT JOIN preferences p ON p.user_id = t.user_id
+			WHERE COALESCE(p.idle_minutes, ?3) > 0
+				AND t.last_seen < ?1 - 60 * COALESCE(p.idle_minutes, ?3)
+		)`
+
+func (s *SQLiteStore) GetTimers(userID string) ([]*domain.Timer, error) {
+	return s.getTimers("t.user_id = ?1", userID)
+}
+
+func (s *SQLiteStore) GetTimer(userID, id string) (*domain.Timer, error) {
+	timers, err := s.getTimers("t.user_id = ?1 AND t.id = ?2", userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(timers) == 0 {
+		return nil, notFound(sql.ErrNoRows, "timer")
+	}
+	return timers[0], nil
+}
+
+func (s *SQLiteStore) getTimers(where string, args ...any) ([]*domain.Timer, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id,
+			t.user_id,
+			t.task_id,
+			k.name,
+			t.name,
+			t.elapsed,
+			t.running_since,
+			t.last_seen,
+			t.idle_paused,
+			t.started_at
+		FROM timers t
+		JOIN tasks k ON k.id = t.task_id
+		WHERE `+where+`
+		ORDER BY t.started_at, t.rowid`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timers []*domain.Timer
+	for rows.Next() {
+		var t domain.Timer
+		var elapsed, lastSeen, startedAt int64
+		var runningSince sql.NullInt64
+		if err := rows.Scan(
+			&t.ID,
+			&t.UserID,
+			&t.TaskID,
+			&t.TaskName,
+			&t.Name,
+			&elapsed,
+			&runningSince,
+			&lastSeen,
+			&t.IdlePaused,
+			&startedAt,
+		); err != nil {
+			return nil, err
+		}
+		t.Elapsed = time.Duration(elapsed) * time.Second
+		if runningSince.Valid {
+			since := time.Unix(runningSince.Int64, 0).UTC()
+			t.RunningSince = &since
+		}
+		t.LastSeen = time.Unix(lastSeen, 0).UTC()
+		t.StartedAt = time.Unix(startedAt, 0).UTC()
+		timers = append(timers, &t)
+	}
+	return timers, rows.Err()
+}
+
+func (s *SQLiteStore) StartTimer(userID, taskID, name string) (*domain.Timer, error) {
+	if name != "" {
+		var err error
+		if name, err = domain.NormalizeName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var started int
+	var running sql.NullString
+	var multiple bool
+	if err := tx.QueryRow(`
+		SELECT
+			COUNT(*),
+			(SELECT k.name FROM timers t JOIN tasks k ON k.id = t.task_id WHERE t.user_id = ?1 LIMIT 1),
+			COALESCE((SELECT multiple_timers FROM preferences WHERE user_id = ?1), 0)
+		FROM timers
+		WHERE user_id = ?1`,
+		userID,
+	).Scan(&started, &running, &multiple); err != nil {
+		return nil, err
+	}
+	if started > 0 && !multiple {
+		return nil, fmt.Errorf("%w: a timer is already started on %q; stop it first", domain.ErrConflict, running.String)
+	}
+
+	// Selecting from the task makes the insert a no-op when it does not exist
+	id := s.ids.NewID()
+	now := s.clock.Now().Unix()
+	if err := tx.QueryRow(`
+		INSERT INTO timers (id, user_id, task_id, name, running_since, last_seen, started_at)
+		SELECT ?1, ?2, id, ?4, ?5, ?5, ?5
+		FROM tasks
+		WHERE id = ?3
+		RETURNING id`,
+		id,
+		userID,
+		taskID,
+		name,
+		now,
+	).Scan(&id); err != nil {
+		return nil, notFound(err, "task")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetTimer(userID, id)
+}
+
+func (s *SQLiteStore) PauseTimer(userID, id string) (*domain.Timer, error) {
+	if _, err := s.db.Exec(`
+		UPDATE timers
+		SET elapsed = elapsed + MAX(?3 - running_since, 0),
+			running_since = NULL,
+			idle_paused = 0
+		WHERE user_id = ?1 AND id = ?2 AND running_since IS NOT NULL`,
+		userID,
+		id,
+		s.clock.Now().Unix(),
+	); err != nil {
+		return nil, err
+	}
+	return s.GetTimer(userID, id)
+}
+
+func (s *SQLiteStore) ResumeTimer(userID, id string) (*domain.Timer, error) {
+	if _, err := s.db.Exec(`
+		UPDATE timers
+		SET running_since = ?3,
+			last_seen = ?3,
+			idle_paused = 0
+		WHERE user_id = ?1 AND id = ?2 AND running_since IS NULL`,
+		userID,
+		id,
+		s.clock.Now().Unix(),
+	); err != nil {
+		return nil, err
+	}
+	return s.GetTimer(userID, id)
+}
+
+func (s *SQLiteStore) TouchTimers(userID string) ([]*domain.Timer, error) {
+	now := s.clock.Now().Unix()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// A heartbeat after a long silence, say from a laptop waking up, does
+	// not vouch for the time in between
+	if _, err := tx.Exec(pauseIdleTimers, now, userID, domain.DefaultIdleMinutes); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE timers
+		SET last_seen = ?2
+		WHERE user_id = ?1 AND running_since IS NOT NULL`,
+		userID,
+		now,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetTimers(userID)
+}
+
+func (s *SQLiteStore) StopTimer(userID, id string) (*domain.Timer, error) {
+	t, err := s.GetTimer(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec("DELETE FROM timers WHERE user_id = ?1 AND id = ?2", userID, id); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *SQLiteStore) PauseIdleTimers(now time.Time) (int, error) {
+	res, err := s.db.Exec(pauseIdleTimers, now.Unix(), "", domain.DefaultIdleMinutes)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,633 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// snapshot holds the raw rows of a deleted subtree, keyed by column name, so
+// that a restore puts back exactly what was removed including sort order,
+// visibility, and work logs. Columns added by later migrations simply fall
+// back to their defaults when an older snapshot is restored.
+type snapshot struct {
+	Categories  []map[string]any `json:"categories,omitempty"`
+	Tasks       []map[string]any `json:"tasks,omitempty"`
+	Subtasks    []map[string]any `json:"subtasks,omitempty"`
+	WorkLogs    []map[string]any `json:"work_logs,omitempty"`
+	Attachments []map[string]any `json:"attachments,omitempty"`
+	Links       []map[string]any `json:"links,omitempty"`
+	Nudges      []map[string]any `json:"nudges,omitempty"`
+
+	DoneCriteria []map[string]any `json:"done_criteria,omitempty"`
+	TaskChecks   []map[string]any `json:"task_checks,omitempty"`
+	TaskContexts []map[string]any `json:"task_contexts,omitempty"`
+	TaskTags     []map[string]any `json:"task_tags,omitempty"`
+	Estimates    []map[string]any `json:"estimate_changes,omitempty"`
+	Reviews      []map[string]any `json:"review_transitions,omitempty"`
+	AgingRules   []map[string]any `json:"aging_rules,omitempty"`
+	AgingRuns    []map[string]any `json:"aging_runs,omitempty"`
+}
+
+func (s *SQLiteStore) DeleteCategory(id string, actor string) (*domain.TrashEntry, error) {
+	return s.trash(domain.EntityCategory, id, actor)
+}
+
+func (s *SQLiteStore) DeleteTask(id string, actor string) (*domain.TrashEntry, error) {
+	return s.trash(domain.EntityTask, id, actor)
+}
+
+func (s *SQLiteStore) DeleteSubtask(id string, actor string) (*domain.TrashEntry, error) {
+	return s.trash(domain.EntitySubtask, id, actor)
+}
+
+// trash snapshots an entity and everything beneath it into the trash, then
+// deletes it, all in one transaction.
+func (s *SQLiteStore) trash(entityType, id, actor string) (*domain.TrashEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry := domain.TrashEntry{
+		ID:         s.ids.NewID(),
+		EntityType: entityType,
+		EntityID:   id,
+		DeletedBy:  actor,
+		DeletedAt:  s.clock.Now(),
+	}
+
+	var snap snapshot
+	switch entityType {
+	case domain.EntityCategory:
+		if err := tx.QueryRow(`
+			SELECT name
+			FROM categories
+			WHERE id = ?1`,
+			id,
+		).Scan(&entry.Name); err != nil {
+			return nil, notFound(err, "category")
+		}
+		entry.CategoryID = id
+		if snap.Categories, err = selectRows(tx, "SELECT * FROM categories WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Tasks, err = selectRows(tx, "SELECT * FROM tasks WHERE category_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Subtasks, err = selectRows(tx, "SELECT * FROM subtasks WHERE category_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.WorkLogs, err = selectRows(tx, "SELECT * FROM work_logs WHERE category_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Attachments, err = selectRows(tx, "SELECT * FROM attachments WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.Links, err = selectRows(tx, "SELECT * FROM links WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.Nudges, err = selectRows(tx, "SELECT * FROM nudges WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.DoneCriteria, err = selectRows(tx, "SELECT * FROM done_criteria WHERE category_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskChecks, err = selectRows(tx, "SELECT * FROM task_checks WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskContexts, err = selectRows(tx, "SELECT * FROM task_contexts WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskTags, err = selectRows(tx, "SELECT * FROM task_tags WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.Estimates, err = selectRows(tx, "SELECT * FROM estimate_changes WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.Reviews, err = selectRows(tx, "SELECT * FROM review_transitions WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if snap.AgingRules, err = selectRows(tx, "SELECT * FROM aging_rules WHERE category_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.AgingRuns, err = selectRows(tx, "SELECT * FROM aging_runs WHERE task_id IN (SELECT id FROM tasks WHERE category_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM categories WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+
+	case domain.EntityTask:
+		if err := tx.QueryRow(`
+			SELECT
+				name,
+				category_id
+			FROM tasks
+			WHERE id = ?1`,
+			id,
+		).Scan(&entry.Name, &entry.CategoryID); err != nil {
+			return nil, notFound(err, "task")
+		}
+		if snap.Tasks, err = selectRows(tx, "SELECT * FROM tasks WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Subtasks, err = selectRows(tx, "SELECT * FROM subtasks WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.WorkLogs, err = selectRows(tx, "SELECT * FROM work_logs WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Attachments, err = selectRows(tx, "SELECT * FROM attachments WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Links, err = selectRows(tx, "SELECT * FROM links WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Nudges, err = selectRows(tx, "SELECT * FROM nudges WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskChecks, err = selectRows(tx, "SELECT * FROM task_checks WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskContexts, err = selectRows(tx, "SELECT * FROM task_contexts WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.TaskTags, err = selectRows(tx, "SELECT * FROM task_tags WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Estimates, err = selectRows(tx, "SELECT * FROM estimate_changes WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Reviews, err = selectRows(tx, "SELECT * FROM review_transitions WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.AgingRuns, err = selectRows(tx, "SELECT * FROM aging_runs WHERE task_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM tasks WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+		if err := refreshCategoryCompletion(tx, entry.CategoryID); err != nil {
+			return nil, err
+		}
+
+	case domain.EntitySubtask:
+		if err := tx.QueryRow(`
+			SELECT
+				name,
+				category_id,
+				task_id
+			FROM subtasks
+			WHERE id = ?1`,
+			id,
+		).Scan(&entry.Name, &entry.CategoryID, &entry.TaskID); err != nil {
+			return nil, notFound(err, "subtask")
+		}
+		if snap.Subtasks, err = selectRows(tx, "SELECT * FROM subtasks WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.WorkLogs, err = selectRows(tx, "SELECT * FROM work_logs WHERE subtask_id = ?1", id); err != nil {
+			return nil, err
+		}
+		if snap.Attachments, err = selectRows(tx, "SELECT * FROM attachments WHERE work_log_id IN (SELECT id FROM work_logs WHERE subtask_id = ?1)", id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM subtasks WHERE id = ?1", id); err != nil {
+			return nil, err
+		}
+		if err := refreshTaskCompletion(tx, entry.TaskID, entry.CategoryID); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown entity type %q", domain.ErrInvalid, entityType)
+	}
+
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO trash (
+			id,
+			entity_type,
+			entity_id,
+			category_id,
+			task_id,
+			name,
+			payload,
+			deleted_by,
+			deleted_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)`,
+		entry.ID,
+		entry.EntityType,
+		entry.EntityID,
+		entry.CategoryID,
+		entry.TaskID,
+		entry.Name,
+		string(payload),
+		entry.DeletedBy,
+		entry.DeletedAt.Unix(),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit(tx, actor, "delete", entityType, id, entry.Name); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *SQLiteStore) GetTrashEntry(id string) (*domain.TrashEntry, error) {
+	entry, _, err := getTrashEntry(s.db, id)
+	return entry, err
+}
+
+// RestoreTrashEntry puts a deleted subtree back where it was. It fails with
+// domain.ErrConflict if the entity's parent has since been deleted too.
+func (s *SQLiteStore) RestoreTrashEntry(id string, actor string) (*domain.TrashEntry, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry, payload, err := getTrashEntry(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentExists bool
+	switch entry.EntityType {
+	case domain.EntityCategory:
+		parentExists = true
+	case domain.EntityTask:
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?1)", entry.CategoryID).Scan(&parentExists)
+	case domain.EntitySubtask:
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = ?1)", entry.TaskID).Scan(&parentExists)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !parentExists {
+		return nil, fmt.Errorf("%w: the parent of %q no longer exists", domain.ErrConflict, entry.Name)
+	}
+
+	var snap snapshot
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&snap); err != nil {
+		return nil, fmt.Errorf("corrupt trash entry %s: %w", id, err)
+	}
+
+	if err := makeRoom(tx, entry, snap); err != nil {
+		return nil, err
+	}
+
+	// Work log corrections reference other work logs in the same batch, in
+	// no particular order, so check foreign keys only at commit
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return nil, err
+	}
+
+	// Tags deleted while the task was in the trash are not brought back
+	if snap.TaskTags, err = existingTags(tx, snap.TaskTags); err != nil {
+		return nil, err
+	}
+
+	// Parents before children, so only those cross references are deferred
+	for _, batch := range []struct {
+		table string
+		rows  []map[string]any
+	}{
+		{"categories", snap.Categories},
+		{"tasks", snap.Tasks},
+		{"subtasks", snap.Subtasks},
+		{"work_logs", snap.WorkLogs},
+		{"attachments", snap.Attachments},
+		{"links", snap.Links},
+		{"nudges", snap.Nudges},
+		{"done_criteria", snap.DoneCriteria},
+		{"task_checks", snap.TaskChecks},
+		{"task_contexts", snap.TaskContexts},
+		{"task_tags", snap.TaskTags},
+		{"estimate_changes", snap.Estimates},
+		{"review_transitions", snap.Reviews},
+		{"aging_rules", snap.AgingRules},
+		{"aging_runs", snap.AgingRuns},
+	} {
+		if err := insertRows(tx, batch.table, batch.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// References from the restored text may have been purged meanwhile
+	for _, batch := range []struct {
+		entityType string
+		rows       []map[string]any
+	}{
+		{domain.EntityCategory, snap.Categories},
+		{domain.EntityTask, snap.Tasks},
+		{domain.EntitySubtask, snap.Subtasks},
+		{domain.EntityWorkLog, snap.WorkLogs},
+	} {
+		for _, row := range batch.rows {
+			if err := indexRefs(tx, batch.entityType, fmt.Sprint(row["id"])); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if entry.EntityType == domain.EntitySubtask {
+		err = refreshTaskCompletion(tx, entry.TaskID, entry.CategoryID)
+	} else {
+		err = refreshCategoryCompletion(tx, entry.CategoryID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM trash WHERE id = ?1", id); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, actor, "restore", entry.EntityType, entry.EntityID, entry.Name); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// makeRoom shifts the restored entity's siblings down so it goes back to
+// its old position rather than colliding with whatever took its place.
+func makeRoom(tx *sql.Tx, entry *domain.TrashEntry, snap snapshot) error {
+	var rows []map[string]any
+	var query, scope string
+	switch entry.EntityType {
+	case domain.EntityCategory:
+		rows = snap.Categories
+		query = `
+			UPDATE categories
+			SET sort_order = sort_order + 1
+			WHERE sort_order >= ?1`
+	case domain.EntityTask:
+		rows, scope = snap.Tasks, entry.CategoryID
+		query = `
+			UPDATE tasks
+			SET sort_order = sort_order + 1
+			WHERE sort_order >= ?1 AND category_id = ?2`
+	case domain.EntitySubtask:
+		rows, scope = snap.Subtasks, entry.TaskID
+		query = `
+			UPDATE subtasks
+			SET sort_order = sort_order + 1
+			WHERE sort_order >= ?1 AND task_id = ?2`
+	}
+
+	for _, row := range rows {
+		if row["id"] != entry.EntityID {
+			continue
+		}
+		n, ok := row["sort_order"].(json.Number)
+		if !ok {
+			return nil
+		}
+		pos, err := n.Int64()
+		if err != nil {
+			return nil
+		}
+		_, err = tx.Exec(query, pos, scope)
+		return err
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes trash entries older than before. Undo and
+// restore are no longer possible for them; the audit log keeps its record.
+// Blobs of any attachments in them are left in blob storage.
+func (s *SQLiteStore) PurgeTrash(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		DELETE FROM trash
+		WHERE deleted_at < ?1`,
+		before.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := purgeOrphanRefs(tx); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getTrashEntry(q queryRower, id string) (*domain.TrashEntry, []byte, error) {
+	var entry domain.TrashEntry
+	var payload string
+	var deletedAt int64
+	if err := q.QueryRow(`
+		SELECT
+			id,
+			entity_type,
+			entity_id,
+			category_id,
+			task_id,
+			name,
+			payload,
+			deleted_by,
+			deleted_at
+		FROM trash
+		WHERE id = ?1`,
+		id,
+	).Scan(
+		&entry.ID,
+		&entry.EntityType,
+		&entry.EntityID,
+		&entry.CategoryID,
+		&entry.TaskID,
+		&entry.Name,
+		&payload,
+		&entry.DeletedBy,
+		&deletedAt,
+	); err != nil {
+		return nil, nil, notFound(err, "trash entry")
+	}
+	entry.DeletedAt = time.Unix(deletedAt, 0).UTC()
+	return &entry, []byte(payload), nil
+}
+
+// audit appends to the audit log inside the caller's transaction, so a
+// change is never recorded without happening or vice versa.
+func (s *SQLiteStore) audit(tx *sql.Tx, actor, action, entityType, entityID, summary string) error {
+	_, err := tx.Exec(`
+		INSERT INTO audit_log (at, actor, action, entity_type, entity_id, summary)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)`,
+		s.clock.Now().Unix(),
+		actor,
+		action,
+		entityType,
+		entityID,
+		summary,
+	)
+	return err
+}
+
+func (s *SQLiteStore) AuditSignIn(action, address, summary string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (at, action, entity_type, entity_id, summary)
+		VALUES (?1, ?2, 'client', ?3, ?4)`,
+		s.clock.Now().Unix(),
+		action,
+		address,
+		summary,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetChangeAuthors(since time.Time) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT who
+		FROM (
+			SELECT author AS who FROM revisions WHERE created_at >= ?1
+			UNION ALL
+			SELECT author FROM work_logs WHERE created_at >= ?1
+			UNION ALL
+			SELECT actor FROM audit_log WHERE at >= ?1 AND entity_type != 'client')
+		WHERE who != ''
+		GROUP BY who
+		ORDER BY COUNT(*) DESC, who`,
+		since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []string
+	for rows.Next() {
+		var who string
+		if err := rows.Scan(&who); err != nil {
+			return nil, err
+		}
+		authors = append(authors, who)
+	}
+	return authors, rows.Err()
+}
+
+// selectRows reads whole rows as column name to value maps
+func selectRows(tx *sql.Tx, query string, args ...any) ([]map[string]any, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []map[string]any
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			row[col] = vals[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// insertRows writes snapshot rows back into table. Only columns the table
+// still has are written, and column names come from the schema rather than
+// the payload, so a snapshot can never inject SQL.
+func insertRows(tx *sql.Tx, table string, rows []map[string]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// PRAGMA does not accept bound parameters; table is always a constant
+	info, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for info.Next() {
+		var name string
+		if err := info.Scan(&name); err != nil {
+			info.Close()
+			return err
+		}
+		columns = append(columns, name)
+	}
+	if err := info.Close(); err != nil {
+		return err
+	}
+	sort.Strings(columns)
+
+	for _, row := range rows {
+		var cols, params []string
+		var args []any
+		for _, col := range columns {
+			val, ok := row[col]
+			if !ok {
+				continue
+			}
+			if n, ok := val.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					val = i
+				} else if f, err := n.Float64(); err == nil {
+					val = f
+				}
+			}
+			cols = append(cols, col)
+			args = append(args, val)
+			params = append(params, fmt.Sprintf("?%d", len(args)))
+		}
+		if _, err := tx.Exec(fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			table,
+			strings.Join(cols, ", "),
+			strings.Join(params, ", "),
+		), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func TestRestoreCategoryBringsBackEverything(t *testing.T) {
+	s, _ := newTestStore(t)
+	addBoard(t, s, "Kitchen")
+	b := addBoard(t, s, "Garden")
+	addBoard(t, s, "Shed")
+
+	entry, err := s.DeleteCategory(b.category.ID, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTrashEntry(entry.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetTask(b.task.ID); err != nil {
+		t.Errorf("the task wasn't restored: %v", err)
+	}
+	if _, err := s.GetSubtask(b.subtask.ID); err != nil {
+		t.Errorf("the subtask wasn't restored: %v", err)
+	}
+	logs, err := s.GetWorkLogsForCategory(b.category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Errorf("%d work logs restored, want 2", len(logs))
+	}
+	// Categories are added at the top
+	checkCategoryOrder(t, s, "Shed", "Garden", "Kitchen")
+
+	if _, err := s.GetTrashEntry(entry.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("the entry is still in the trash: %v", err)
+	}
+}
+
+func TestRestoreTaskWhileCategoryIsTrashed(t *testing.T) {
+	s, _ := newTestStore(t)
+	b := addBoard(t, s, "Garden")
+
+	taskEntry, err := s.DeleteTask(b.task.ID, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	categoryEntry, err := s.DeleteCategory(b.category.ID, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RestoreTrashEntry(taskEntry.ID, "ana"); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("restoring into a trashed category: got %v, want ErrConflict", err)
+	}
+	for _, table := range []string{"tasks", "subtasks", "work_logs"} {
+		if n := count(t, s, table, "1"); n != 0 {
+			t.Errorf("the refused restore left %d %s behind", n, table)
+		}
+	}
+	if _, err := s.GetTrashEntry(taskEntry.ID); err != nil {
+		t.Errorf("the refused restore took the entry out of the trash: %v", err)
+	}
+
+	// The category comes back without the task trashed before it, which can
+	// then follow
+	if _, err := s.RestoreTrashEntry(categoryEntry.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetTask(b.task.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("the task trashed on its own came back with its category: %v", err)
+	}
+	if _, err := s.RestoreTrashEntry(taskEntry.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSubtask(b.subtask.ID); err != nil {
+		t.Errorf("the task's subtask wasn't restored: %v", err)
+	}
+	if n := count(t, s, "work_logs", "task_id = ?1", b.task.ID); n != 2 {
+		t.Errorf("%d of the task's work logs restored, want 2", n)
+	}
+}
+
+func TestRestoreSubtaskWhileTaskIsTrashed(t *testing.T) {
+	s, _ := newTestStore(t)
+	b := addBoard(t, s, "Garden")
+
+	subtaskEntry, err := s.DeleteSubtask(b.subtask.ID, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteTask(b.task.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RestoreTrashEntry(subtaskEntry.ID, "ana"); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("restoring into a trashed task: got %v, want ErrConflict", err)
+	}
+	if n := count(t, s, "subtasks", "1"); n != 0 {
+		t.Errorf("the refused restore left %d subtasks behind", n)
+	}
+}
+
+func TestRestoreTwice(t *testing.T) {
+	s, _ := newTestStore(t)
+	b := addBoard(t, s, "Garden")
+
+	entry, err := s.DeleteTask(b.task.ID, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTrashEntry(entry.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTrashEntry(entry.ID, "ana"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("restoring again: got %v, want ErrNotFound", err)
+	}
+	if n := count(t, s, "tasks", "id = ?1", b.task.ID); n != 1 {
+		t.Errorf("%d copies of the task", n)
+	}
+}
+
+func TestRestoreTaskToItsPlace(t *testing.T) {
+	s, _ := newTestStore(t)
+	c, err := s.AddCategory("Garden", "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, name := range []string{"Dig", "Plant", "Water"} {
+		task, err := s.AddTask(c.ID, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	entry, err := s.DeleteTask(ids[1], "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddTask(c.ID, "Harvest"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTrashEntry(entry.ID, "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetCategory(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, task := range got.Tasks {
+		names = append(names, task.Name)
+	}
+	if want := []string{"Dig", "Plant", "Water", "Harvest"}; !slices.Equal(names, want) {
+		t.Errorf("tasks %v, want %v", names, want)
+	}
+}
+
+func checkCategoryOrder(t *testing.T, s *SQLiteStore, want ...string) {
+	t.Helper()
+	categories, err := s.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("categories %v, want %v", names, want)
+	}
+}
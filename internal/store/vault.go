@@ -0,0 +1,124 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) ImportVault(categoryID string, tasks []*domain.VaultTask, actor string) (*domain.VaultImport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var catName string
+	if err := tx.QueryRow("SELECT name FROM categories WHERE id = ?1", categoryID).Scan(&catName); err != nil {
+		return nil, notFound(err, "category")
+	}
+
+	var result domain.VaultImport
+	for _, vt := range tasks {
+		name, err := domain.CleanName(vt.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", vt.File, err)
+		}
+
+		var taskID string
+		err = tx.QueryRow(`
+			SELECT id
+			FROM tasks
+			WHERE category_id = ?1 AND source = ?2`,
+			categoryID,
+			vt.Source,
+		).Scan(&taskID)
+		switch {
+		case err == sql.ErrNoRows:
+			taskID = s.ids.NewID()
+			if _, err := tx.Exec(`
+				INSERT INTO tasks (id, category_id, name, description, sort_order, created_at, source)
+				VALUES (?1, ?2, ?3, ?4, (SELECT COALESCE(MAX(sort_order), -1) + 1 FROM tasks WHERE category_id = ?2), ?5, ?6)`,
+				taskID,
+				categoryID,
+				name,
+				"Imported from "+vt.File,
+				s.clock.Now().Unix(),
+				vt.Source,
+			); err != nil {
+				return nil, err
+			}
+			if err := s.copyDoneCriteria(tx, taskID, categoryID); err != nil {
+				return nil, err
+			}
+			if err := s.audit(tx, actor, "import", domain.EntityTask, taskID, "imported from "+vt.File); err != nil {
+				return nil, err
+			}
+			result.TasksAdded++
+		case err != nil:
+			return nil, err
+		}
+
+		for _, item := range vt.Items {
+			itemName, err := domain.CleanName(item.Name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", vt.File, err)
+			}
+			completion := 0
+			if item.Done {
+				completion = 100
+			}
+
+			var subID string
+			var current int
+			err = tx.QueryRow(`
+				SELECT id, completion
+				FROM subtasks
+				WHERE task_id = ?1 AND source = ?2`,
+				taskID,
+				item.Source,
+			).Scan(&subID, &current)
+			switch {
+			case err == sql.ErrNoRows:
+				if _, err := tx.Exec(`
+					INSERT INTO subtasks (id, task_id, category_id, name, completion, sort_order, source)
+					VALUES (?1, ?2, ?3, ?4, ?5, (SELECT COALESCE(MAX(sort_order), -1) + 1 FROM subtasks WHERE task_id = ?2), ?6)`,
+					s.ids.NewID(),
+					taskID,
+					categoryID,
+					itemName,
+					completion,
+					item.Source,
+				); err != nil {
+					return nil, err
+				}
+				result.SubtasksAdded++
+			case err != nil:
+				return nil, err
+			case item.Done && current < 100:
+				// Progress made here is kept unless the box says it is done
+				if _, err := tx.Exec("UPDATE subtasks SET completion = 100 WHERE id = ?1", subID); err != nil {
+					return nil, err
+				}
+				result.Completed++
+			}
+		}
+		if err := refreshTaskCompletion(tx, taskID, categoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := refreshCategoryCompletion(tx, categoryID); err != nil {
+		return nil, err
+	}
+	summary := fmt.Sprintf("imported a vault into %s: %d tasks and %d subtasks added, %d subtasks completed",
+		catName, result.TasksAdded, result.SubtasksAdded, result.Completed)
+	if err := s.audit(tx, actor, "import", domain.EntityCategory, categoryID, summary); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
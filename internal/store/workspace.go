@@ -0,0 +1,445 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *SQLiteStore) ExportWorkspace() (*domain.Workspace, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ws := &domain.Workspace{
+		Version:    domain.WorkspaceVersion,
+		ExportedAt: s.clock.Now(),
+		Categories: []*domain.WorkspaceCategory{},
+	}
+
+	categories := map[string]*domain.WorkspaceCategory{}
+	err = eachRow(tx, `
+		SELECT id, name, description, public, color, icon, wip_limit, sort_order
+		FROM categories
+		ORDER BY sort_order, rowid`,
+		func(rows *sql.Rows) error {
+			c := domain.WorkspaceCategory{Tasks: []*domain.WorkspaceTask{}}
+			if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Public, &c.Color, &c.Icon, &c.WIPLimit, &c.SortOrder); err != nil {
+				return err
+			}
+			categories[c.ID] = &c
+			ws.Categories = append(ws.Categories, &c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := map[string]*domain.WorkspaceTask{}
+	err = eachRow(tx, `
+		SELECT
+			id,
+			category_id,
+			name,
+			description,
+			completion,
+			public,
+			estimate,
+			start_date,
+			due_date,
+			size,
+			priority,
+			blocked_reason,
+			waiting_on,
+			subtask_checkboxes,
+			sort_order,
+			created_at
+		FROM tasks
+		ORDER BY sort_order, rowid`,
+		func(rows *sql.Rows) error {
+			t := domain.WorkspaceTask{
+				Subtasks: []*domain.WorkspaceSubtask{},
+				WorkLogs: []*domain.WorkspaceWorkLog{},
+			}
+			var categoryID string
+			var createdAt int64
+			if err := rows.Scan(
+				&t.ID,
+				&categoryID,
+				&t.Name,
+				&t.Description,
+				&t.Completion,
+				&t.Public,
+				&t.Estimate,
+				&t.StartDate,
+				&t.DueDate,
+				&t.Size,
+				&t.Priority,
+				&t.BlockedReason,
+				&t.WaitingOn,
+				&t.SubtaskCheckboxes,
+				&t.SortOrder,
+				&createdAt,
+			); err != nil {
+				return err
+			}
+			t.CreatedAt = time.Unix(createdAt, 0).UTC()
+			if c := categories[categoryID]; c != nil {
+				tasks[t.ID] = &t
+				c.Tasks = append(c.Tasks, &t)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	subtasks := map[string]*domain.WorkspaceSubtask{}
+	err = eachRow(tx, `
+		SELECT id, task_id, name, description, completion, public, estimate, due_date, sort_order
+		FROM subtasks
+		ORDER BY sort_order, rowid`,
+		func(rows *sql.Rows) error {
+			sub := domain.WorkspaceSubtask{WorkLogs: []*domain.WorkspaceWorkLog{}}
+			var taskID string
+			if err := rows.Scan(&sub.ID, &taskID, &sub.Name, &sub.Description, &sub.Completion, &sub.Public, &sub.Estimate, &sub.DueDate, &sub.SortOrder); err != nil {
+				return err
+			}
+			if t := tasks[taskID]; t != nil {
+				subtasks[sub.ID] = &sub
+				t.Subtasks = append(t.Subtasks, &sub)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachRow(tx, `
+		SELECT
+			id,
+			task_id,
+			COALESCE(subtask_id, ''),
+			hours_worked,
+			work_description,
+			completion_estimate,
+			created_at,
+			author,
+			COALESCE(corrects_id, '')
+		FROM work_logs
+		ORDER BY created_at, rowid`,
+		func(rows *sql.Rows) error {
+			var wl domain.WorkspaceWorkLog
+			var taskID, subtaskID string
+			var createdAt int64
+			if err := rows.Scan(
+				&wl.ID,
+				&taskID,
+				&subtaskID,
+				&wl.HoursWorked,
+				&wl.WorkDescription,
+				&wl.CompletionEstimate,
+				&createdAt,
+				&wl.Author,
+				&wl.CorrectsID,
+			); err != nil {
+				return err
+			}
+			wl.CreatedAt = time.Unix(createdAt, 0).UTC()
+			if sub := subtasks[subtaskID]; sub != nil {
+				sub.WorkLogs = append(sub.WorkLogs, &wl)
+			} else if t := tasks[taskID]; t != nil && subtaskID == "" {
+				t.WorkLogs = append(t.WorkLogs, &wl)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// eachRow runs query in tx and calls scan for each row
+func eachRow(tx *sql.Tx, query string, scan func(*sql.Rows) error) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLiteStore) ImportWorkspace(ws *domain.Workspace, actor string) error {
+	if ws.Version < 1 || ws.Version > domain.WorkspaceVersion {
+		return fmt.Errorf("%w: unsupported workspace version %d", domain.ErrInvalid, ws.Version)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the importer's own board must be empty; on private boards,
+	// everyone else's is none of theirs
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM categories WHERE owner_id = ?1", actor).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: board is not empty", domain.ErrConflict)
+	}
+
+	// Corrections may come before the work logs they correct
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+	for _, c := range ws.Categories {
+		if err := s.importCategory(tx, c, actor); err != nil {
+			return err
+		}
+	}
+
+	// References are indexed once everything they may point at is in
+	var tasks, logs int
+	for _, c := range ws.Categories {
+		if err := indexRefs(tx, domain.EntityCategory, c.ID); err != nil {
+			return err
+		}
+		for _, t := range c.Tasks {
+			if err := indexRefs(tx, domain.EntityTask, t.ID); err != nil {
+				return err
+			}
+			if err := indexWorkLogRefs(tx, t.WorkLogs); err != nil {
+				return err
+			}
+			for _, sub := range t.Subtasks {
+				if err := indexRefs(tx, domain.EntitySubtask, sub.ID); err != nil {
+					return err
+				}
+				if err := indexWorkLogRefs(tx, sub.WorkLogs); err != nil {
+					return err
+				}
+				logs += len(sub.WorkLogs)
+			}
+			logs += len(t.WorkLogs)
+		}
+		tasks += len(c.Tasks)
+	}
+
+	summary := fmt.Sprintf("workspace of %d categories, %d tasks, %d work logs", len(ws.Categories), tasks, logs)
+	if err := s.audit(tx, actor, "import", "board", "", summary); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// importCategory adds a workspace category with everything under it, owned
+// by owner, checking each entity as the board's own edits would
+func (s *SQLiteStore) importCategory(tx *sql.Tx, wc *domain.WorkspaceCategory, owner string) error {
+	if wc.ID == "" {
+		wc.ID = s.ids.NewID()
+	}
+	c := domain.Category{
+		Name:        wc.Name,
+		Description: wc.Description,
+		Color:       wc.Color,
+		Icon:        wc.Icon,
+		WIPLimit:    wc.WIPLimit,
+	}
+	if err := c.Normalize(); err != nil {
+		return fmt.Errorf("category %q: %w", wc.Name, err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO categories (id, name, description, public, color, icon, wip_limit, sort_order, owner_id)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)`,
+		wc.ID,
+		c.Name,
+		c.Description,
+		wc.Public,
+		c.Color,
+		c.Icon,
+		c.WIPLimit,
+		wc.SortOrder,
+		owner,
+	); err != nil {
+		return fmt.Errorf("%w: category %q: %v", domain.ErrInvalid, wc.Name, err)
+	}
+
+	for _, wt := range wc.Tasks {
+		if wt.ID == "" {
+			wt.ID = s.ids.NewID()
+		}
+		t := domain.Task{
+			Name:          wt.Name,
+			Description:   wt.Description,
+			Estimate:      wt.Estimate,
+			StartDate:     wt.StartDate,
+			DueDate:       wt.DueDate,
+			Size:          wt.Size,
+			Priority:      wt.Priority,
+			Blocked:       wt.BlockedReason != "",
+			BlockedReason: wt.BlockedReason,
+			WaitingOn:     wt.WaitingOn,
+		}
+		if err := t.Normalize(); err != nil {
+			return fmt.Errorf("task %q: %w", wt.Name, err)
+		}
+		if wt.Completion < 0 || wt.Completion > 100 {
+			return fmt.Errorf("%w: task %q: completion must be between 0 and 100", domain.ErrInvalid, wt.Name)
+		}
+		var blockedAt int64
+		if t.Blocked {
+			blockedAt = s.clock.Now().Unix()
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO tasks (
+				id,
+				category_id,
+				name,
+				description,
+				completion,
+				public,
+				estimate,
+				start_date,
+				due_date,
+				size,
+				priority,
+				blocked_at,
+				blocked_reason,
+				waiting_on,
+				subtask_checkboxes,
+				sort_order,
+				created_at
+			)
+			VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17)`,
+			wt.ID,
+			wc.ID,
+			t.Name,
+			t.Description,
+			wt.Completion,
+			wt.Public,
+			t.Estimate,
+			t.StartDate,
+			t.DueDate,
+			t.Size,
+			t.Priority,
+			blockedAt,
+			t.BlockedReason,
+			t.WaitingOn,
+			wt.SubtaskCheckboxes,
+			wt.SortOrder,
+			wt.CreatedAt.Unix(),
+		); err != nil {
+			return fmt.Errorf("%w: task %q: %v", domain.ErrInvalid, wt.Name, err)
+		}
+		if err := s.importWorkLogs(tx, wc.ID, wt.ID, "", wt.WorkLogs); err != nil {
+			return err
+		}
+
+		for _, wsub := range wt.Subtasks {
+			if wsub.ID == "" {
+				wsub.ID = s.ids.NewID()
+			}
+			sub := domain.Subtask{
+				Name:        wsub.Name,
+				Description: wsub.Description,
+				Estimate:    wsub.Estimate,
+				DueDate:     wsub.DueDate,
+			}
+			if err := sub.Normalize(); err != nil {
+				return fmt.Errorf("subtask %q: %w", wsub.Name, err)
+			}
+			if wsub.Completion < 0 || wsub.Completion > 100 {
+				return fmt.Errorf("%w: subtask %q: completion must be between 0 and 100", domain.ErrInvalid, wsub.Name)
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO subtasks (id, task_id, category_id, name, description, completion, public, estimate, due_date, sort_order)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)`,
+				wsub.ID,
+				wt.ID,
+				wc.ID,
+				sub.Name,
+				sub.Description,
+				wsub.Completion,
+				wsub.Public,
+				sub.Estimate,
+				sub.DueDate,
+				wsub.SortOrder,
+			); err != nil {
+				return fmt.Errorf("%w: subtask %q: %v", domain.ErrInvalid, wsub.Name, err)
+			}
+			if err := s.importWorkLogs(tx, wc.ID, wt.ID, wsub.ID, wsub.WorkLogs); err != nil {
+				return err
+			}
+		}
+
+		if err := refreshTaskCompletion(tx, wt.ID, wc.ID); err != nil {
+			return err
+		}
+	}
+
+	return refreshCategoryCompletion(tx, wc.ID)
+}
+
+// importWorkLogs adds the work logged on a workspace task or subtask
+func (s *SQLiteStore) importWorkLogs(tx *sql.Tx, categoryID, taskID, subtaskID string, logs []*domain.WorkspaceWorkLog) error {
+	for _, wl := range logs {
+		if wl.ID == "" {
+			wl.ID = s.ids.NewID()
+		}
+		description, err := domain.NormalizeDescription(wl.WorkDescription)
+		if err != nil {
+			return fmt.Errorf("work log %s: %w", wl.ID, err)
+		}
+		if wl.CompletionEstimate < 0 || wl.CompletionEstimate > 100 {
+			return fmt.Errorf("%w: work log %s: completion estimate must be between 0 and 100", domain.ErrInvalid, wl.ID)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO work_logs (
+				id,
+				category_id,
+				task_id,
+				subtask_id,
+				hours_worked,
+				work_description,
+				completion_estimate,
+				created_at,
+				author,
+				corrects_id
+			)
+			VALUES (?1, ?2, ?3, NULLIF(?4, ''), ?5, ?6, ?7, ?8, ?9, NULLIF(?10, ''))`,
+			wl.ID,
+			categoryID,
+			taskID,
+			subtaskID,
+			wl.HoursWorked,
+			description,
+			wl.CompletionEstimate,
+			wl.CreatedAt.Unix(),
+			wl.Author,
+			wl.CorrectsID,
+		); err != nil {
+			return fmt.Errorf("%w: work log %s: %v", domain.ErrInvalid, wl.ID, err)
+		}
+	}
+	return nil
+}
+
+func indexWorkLogRefs(tx *sql.Tx, logs []*domain.WorkspaceWorkLog) error {
+	for _, wl := range logs {
+		if err := indexRefs(tx, domain.EntityWorkLog, wl.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package vault reads the checkboxes in a markdown vault, such as an
+// Obsidian or Logseq one, for importing as tasks. A checkbox is a list item
+// like "- [ ] call the plumber", or "- [x] ..." once it is done; each
+// heading with checkboxes under it becomes a task with a subtask for each.
+package vault
+
+import (
+	"bufio"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// maxFileSize skips files too big to be notes, such as a mislabeled export
+const maxFileSize = 4 << 20
+
+var (
+	checkbox = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+	heading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	// blockRef is an Obsidian block ID at the end of a line, e.g. "^a1b2c3"
+	blockRef = regexp.MustCompile(`\s+\^[A-Za-z0-9-]+$`)
+)
+
+// Parse reads every markdown file in fsys, in path order. Hidden files and
+// folders such as .obsidian and .trash are skipped, as are Logseq's own
+// logseq folder of settings and backups and files too big to be notes, and
+// so are checkboxes in code blocks and front matter. Checkboxes above a
+// file's first heading belong to a task named after the file.
+func Parse(fsys fs.FS) ([]*domain.VaultTask, error) {
+	var tasks []*domain.VaultTask
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if p != "." && strings.HasPrefix(name, ".") || d.IsDir() && name == "logseq" {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.EqualFold(path.Ext(name), ".md") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, ParseFile(p, string(data))...)
+		return nil
+	})
+	return tasks, err
+}
+
+// ParseFile finds the tasks in one file of a vault, at path p within it
+func ParseFile(p, text string) []*domain.VaultTask {
+	title := strings.TrimSuffix(path.Base(p), path.Ext(p))
+
+	var tasks []*domain.VaultTask
+	var headings []string // the heading at each level above the current line
+	var current *domain.VaultTask
+	seen := map[string]int{}
+	fenced := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 0; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Front matter is YAML, where # starts a comment
+		if n == 0 && trimmed == "---" {
+			for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "---" {
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		if m := heading.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+			for len(headings) < level {
+				headings = append(headings, "")
+			}
+			headings = append(headings[:level-1], m[2])
+			current = nil
+			continue
+		}
+
+		m := checkbox.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		item := strings.TrimSpace(blockRef.ReplaceAllString(m[2], ""))
+		if item == "" {
+			continue
+		}
+		if current == nil {
+			current = newTask(p, title, headings)
+			tasks = append(tasks, current)
+		}
+
+		// Repeats of the same text under one heading are told apart by
+		// their order
+		source := current.Source + "\n" + item
+		seen[source]++
+		if k := seen[source]; k > 1 {
+			source += " #" + strconv.Itoa(k)
+		}
+		current.Items = append(current.Items, domain.VaultItem{
+			Source: source,
+			Name:   clip(item),
+			Done:   m[1] != " ",
+		})
+	}
+	return tasks
+}
+
+// newTask starts the task for the checkboxes under the innermost of
+// headings, named after it and the file it is in
+func newTask(p, title string, headings []string) *domain.VaultTask {
+	var trail []string
+	for _, h := range headings {
+		if h != "" {
+			trail = append(trail, h)
+		}
+	}
+	name := title
+	if len(trail) > 0 {
+		name = title + ": " + trail[len(trail)-1]
+	}
+	return &domain.VaultTask{
+		Source: p + "#" + strings.Join(trail, "/"),
+		File:   p,
+		Name:   clip(name),
+	}
+}
+
+// clip shortens a name to the longest a task or subtask can have
+func clip(s string) string {
+	if r := []rune(s); len(r) > domain.MaxNameLength {
+		return strings.TrimSpace(string(r[:domain.MaxNameLength]))
+	}
+	return s
+}
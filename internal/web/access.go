@@ -0,0 +1,127 @@
+package web
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// routeEntities name what a route's {id} is by the route's first segment,
+// pathEntities what its other wildcards name, and formEntities what a
+// submitted field names
+var (
+	routeEntities = map[string]string{
+		"categories":    domain.EntityCategory,
+		"tasks":         domain.EntityTask,
+		"subtasks":      domain.EntitySubtask,
+		"work-logs":     domain.EntityWorkLog,
+		"revisions":     domain.EntityRevision,
+		"attachments":   domain.EntityAttachment,
+		"links":         domain.EntityLink,
+		"checks":        domain.EntityCheck,
+		"nudges":        domain.EntityNudge,
+		"done-criteria": domain.EntityDoneCriterion,
+		"aging-rules":   domain.EntityAgingRule,
+		"blocks":        domain.EntityTimeBlock,
+		"sync-peers":    domain.EntitySyncPeer,
+	}
+	pathEntities = map[string]string{
+		"other": domain.EntityTask, // /tasks/{id}/dependencies/{other}
+		"task":  domain.EntityTask, // /key-results/{id}/tasks/{task}
+	}
+	formEntities = map[string]string{
+		"category_id": domain.EntityCategory,
+		"into":        domain.EntityCategory,
+		"board":       domain.EntityCategory,
+		"task_id":     domain.EntityTask,
+		"subtask_id":  domain.EntitySubtask,
+	}
+)
+
+// inReach reports whether everything a request names, by its route's {id},
+// pathEntities, and the fields in formEntities, is in a category the user
+// can see.
+// requireAuth turns away the rest as not found, so no change can reach
+// into a category hidden from the user. Unknown IDs are left for the
+// handler to answer.
+func (s *Server) inReach(auth AuthContext, r *http.Request) bool {
+	if len(auth.hidden) == 0 {
+		return true
+	}
+	reaches := func(entity, id string) bool {
+		categoryID, err := s.store.GetOwningCategoryID(entity, id)
+		return err != nil || auth.CanSee(categoryID)
+	}
+
+	if id := r.PathValue("id"); id != "" {
+		pattern := r.Pattern
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			pattern = path
+		}
+		pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, apiPrefix), "/")
+		first, _, _ := strings.Cut(pattern, "/")
+		if entity, ok := routeEntities[first]; ok && !reaches(entity, id) {
+			return false
+		}
+	}
+	for wildcard, entity := range pathEntities {
+		if id := r.PathValue(wildcard); id != "" && !reaches(entity, id) {
+			return false
+		}
+	}
+	for field, entity := range formEntities {
+		if id := r.FormValue(field); id != "" && !reaches(entity, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// accessOf is what userID can see, for requests made for them by a token
+// rather than a session
+func (s *Server) accessOf(userID string) AuthContext {
+	return s.withAccess(AuthContext{IsAuthenticated: true, Handle: userID, IsAdmin: s.admins[userID]})
+}
+
+// hiddenIDs lists the categories hidden from the viewer
+func (a AuthContext) hiddenIDs() []string {
+	return slices.Sorted(maps.Keys(a.hidden))
+}
+
+// requireSharer is requireAdmin for changing who a category is shared
+// with, which on private boards its owner may do as well
+func (s *Server) requireSharer(w http.ResponseWriter, r *http.Request) (AuthContext, bool) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok || auth.IsAdmin {
+		return auth, ok
+	}
+	if s.privateBoards {
+		if cat, err := s.store.GetCategory(r.PathValue("id")); err == nil && cat.OwnerID == auth.Handle {
+			return auth, true
+		}
+	}
+	http.Error(w, "Only admins and the category's owner can share it", http.StatusForbidden)
+	return auth, false
+}
+
+// withHiddenInPlace completes an order of the categories the user can see
+// with the ones hidden from them, each kept in the place it has now, so a
+// user can reorder their board without knowing what else is on it
+func withHiddenInPlace(current []*domain.Category, ids []string, auth AuthContext) []string {
+	order := make([]string, 0, len(current))
+	next := 0
+	for _, c := range current {
+		switch {
+		case !auth.CanSee(c.ID):
+			order = append(order, c.ID)
+		case next < len(ids):
+			order = append(order, ids[next])
+			next++
+		}
+	}
+	// Anything left over makes the order stale, which the store reports
+	return append(order, ids[next:]...)
+}
@@ -0,0 +1,187 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// TestPrivateBoardsRefuseEverythingOnThem checks that on private boards
+// nothing hanging off someone else's category can be changed by its own ID,
+// not only the categories, tasks, and subtasks themselves
+func TestPrivateBoardsRefuseEverythingOnThem(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{PrivateBoards: true})
+	diary := ts.category("ana", "Diary")
+	criterion, err := ts.store.AddDoneCriterion(diary.ID, "Proofread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := ts.store.AddAgingRule(diary.ID, 7, domain.AgingFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := ts.task(diary.ID, "Write it up")
+	entry.Name = "Write it all up"
+	if _, err := ts.store.UpdateTask(entry, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := ts.store.GetRevisions(domain.EntityTask, entry.ID)
+	if err != nil || len(revisions) == 0 {
+		t.Fatalf("no revisions to restore: %v", err)
+	}
+	entry, err = ts.store.GetTask(entry.ID)
+	if err != nil || len(entry.Checklist) == 0 {
+		t.Fatalf("no checklist to tick: %v", err)
+	}
+	attachment, err := ts.store.AddAttachment(&domain.Attachment{TaskID: entry.ID, Filename: "draft.txt", ContentType: "text/plain", UploadedBy: "ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := ts.store.AddLink(&domain.Link{TaskID: entry.ID, Kind: domain.LinkRepo, URL: "https://example.com/diary", AddedBy: "ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nudge, err := ts.store.AddNudge(&domain.Nudge{TaskID: entry.ID, UserID: "ana", Weekday: domain.EveryDay, Hour: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ts.store.AddTimeBlock(&domain.TimeBlock{UserID: "ana", TaskID: entry.ID, Day: "2026-03-02", Start: 540, End: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer, err := ts.store.AddSyncPeer(&domain.SyncPeer{CategoryID: diary.ID, Name: "Laptop", Secret: "shh"}, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garden := ts.category("bea", "Garden")
+	compost := ts.task(garden.ID, "Turn the compost")
+
+	for _, tc := range []struct {
+		method, target string
+		form           url.Values
+	}{
+		{http.MethodPost, "/revisions/" + strconv.FormatInt(revisions[0].ID, 10) + "/restore", nil},
+		{http.MethodDelete, "/attachments/" + attachment.ID, nil},
+		{http.MethodDelete, "/links/" + link.ID, nil},
+		{http.MethodPost, "/checks/" + entry.Checklist[0].ID, nil},
+		{http.MethodDelete, "/nudges/" + nudge.ID, nil},
+		{http.MethodDelete, "/done-criteria/" + criterion.ID, nil},
+		{http.MethodDelete, "/aging-rules/" + rule.ID, nil},
+		{http.MethodDelete, "/blocks/" + block.ID, nil},
+		{http.MethodDelete, "/tasks/" + compost.ID + "/dependencies/" + entry.ID, nil},
+		{http.MethodPost, "/sync-peers", url.Values{"board": {diary.ID}, "name": {"Mine now"}}},
+		{http.MethodPost, "/sync-peers/" + peer.ID + "/sync", nil},
+		{http.MethodDelete, "/sync-peers/" + peer.ID, nil},
+	} {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			expect(t, ts.do("bea", tc.method, tc.target, tc.form), http.StatusNotFound)
+		})
+	}
+
+	got, err := ts.store.GetTask(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Write it all up" {
+		t.Errorf("the revision was restored")
+	}
+	if got.Checklist[0].Checked {
+		t.Errorf("the check was ticked")
+	}
+	if _, err := ts.store.GetNudge(nudge.ID); err != nil {
+		t.Errorf("the nudge was deleted: %v", err)
+	}
+	if peers, err := ts.store.GetSyncPeers(diary.ID); err != nil || len(peers) != 1 {
+		t.Errorf("the board's peers changed: %d, %v", len(peers), err)
+	}
+}
+
+// TestPrivateBoardsHideWhatIsOnThem checks that pages and totals outside
+// the board itself don't show someone else's category either
+func TestPrivateBoardsHideWhatIsOnThem(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{PrivateBoards: true})
+	diary := ts.category("ana", "Diary")
+	entry := ts.task(diary.ID, "Write it up")
+	entry.Name = "Write it all up"
+	if _, err := ts.store.UpdateTask(entry, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ts.store.AddSubtask(entry.ID, "Proofread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.task(diary.ID, "Buy a pen")
+
+	garden := ts.category("bea", "Garden")
+	ts.task(garden.ID, "Turn the compost")
+
+	for _, target := range []string{
+		"/categories/" + diary.ID + "/history",
+		"/tasks/" + entry.ID + "/history",
+		"/subtasks/" + sub.ID + "/history",
+		"/categories/" + diary.ID + "/import",
+		"/categories/" + diary.ID + "/merge",
+	} {
+		t.Run(target, func(t *testing.T) {
+			expect(t, ts.do("bea", http.MethodGet, target, nil), http.StatusNotFound)
+		})
+	}
+
+	t.Run("merge targets", func(t *testing.T) {
+		w := ts.do("bea", http.MethodGet, "/categories/"+garden.ID+"/merge", nil)
+		expect(t, w, http.StatusOK)
+		if strings.Contains(w.Body.String(), "Diary") {
+			t.Errorf("another user's category is offered to merge into")
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		if err := ts.store.SetStatsToken("bea", "bea-stats"); err != nil {
+			t.Fatal(err)
+		}
+		var stats domain.BoardStats
+		expect(t, ts.api("", http.MethodGet, "/stats.json?token=bea-stats", nil, &stats), http.StatusOK)
+		if stats.Categories != 1 || stats.Tasks != 1 {
+			t.Errorf("bea's totals count %d categories and %d tasks, want 1 and 1", stats.Categories, stats.Tasks)
+		}
+	})
+
+	t.Run("grafana", func(t *testing.T) {
+		if _, err := ts.store.AddWorkLogForTask(entry.ID, 2, "Drafted", 50, nil, "ana"); err != nil {
+			t.Fatal(err)
+		}
+		token := ts.hookURL("bea", "")
+		from := ts.clock.Now().Add(-time.Hour).Format(time.RFC3339)
+		to := ts.clock.Now().Add(time.Hour).Format(time.RFC3339)
+		body := `{"range": {"from": "` + from + `", "to": "` + to + `"}, "targets": [{"target": "open_tasks"}, {"target": "hours_per_day"}]}`
+		r := httptest.NewRequest(http.MethodPost, "/grafana/"+token+"/query", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := ts.serve(r)
+		expect(t, w, http.StatusOK)
+
+		var series []grafanaSeries
+		if err := json.NewDecoder(w.Body).Decode(&series); err != nil {
+			t.Fatal(err)
+		}
+		if len(series) != 2 || len(series[0].Datapoints) == 0 || len(series[1].Datapoints) == 0 {
+			t.Fatalf("unexpected series: %+v", series)
+		}
+		if open := series[0].Datapoints[len(series[0].Datapoints)-1][0]; open != 1 {
+			t.Errorf("bea's open tasks are %v, want 1", open)
+		}
+		for _, p := range series[1].Datapoints {
+			if p[0] != 0 {
+				t.Errorf("bea's chart counts %v hours logged on ana's board", p[0])
+			}
+		}
+	})
+}
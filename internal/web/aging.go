@@ -0,0 +1,42 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (s *Server) handleAddAgingRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil {
+		http.Error(w, "days must be a whole number", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.AddAgingRule(id, days, r.FormValue("action")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
+
+// handleDeleteAgingRule stops the rule; flags and priorities it already set
+// stay as they are
+func (s *Server) handleDeleteAgingRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteAgingRule(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/")
+}
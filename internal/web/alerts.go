@@ -0,0 +1,124 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/alerts"
+)
+
+// alertActor is who tasks added for alerts are credited to
+const alertActor = "alerts"
+
+// alertResult says what became of each alert in a webhook: the task it
+// added, the open task it was already on, or that it was resolved
+type alertResult struct {
+	Fingerprint string `json:"fingerprint"`
+	TaskID      string `json:"task_id,omitempty"`
+	Status      string `json:"status"` // "added", "open", or "resolved"
+}
+
+// handleAlertWebhook turns the firing alerts an Alertmanager or Uptime Kuma
+// webhook carries into tasks in the category its token is for. An alert
+// that already has an open task there adds nothing, and resolved alerts
+// are left for whoever works the task to close.
+func (s *Server) handleAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	cat, err := s.store.GetCategoryByAlertToken(hashHookToken(r.PathValue("token")))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	parsed, err := alerts.Parse(body)
+	if errors.Is(err, alerts.ErrUnknownFormat) {
+		http.Error(w, "Send an Alertmanager or Uptime Kuma webhook", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	results := []alertResult{}
+	for _, a := range parsed {
+		result := alertResult{Fingerprint: a.Fingerprint, Status: "resolved"}
+		if !a.Resolved {
+			task, added, err := s.store.AddAlertTask(cat.ID, a, alertActor)
+			if err != nil {
+				storeError(w, err)
+				return
+			}
+			result.TaskID, result.Status = task.ID, "open"
+			if added {
+				result.Status = "added"
+			}
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": results})
+}
+
+// handleCreateAlertWebhook gives the category a new alert webhook, retiring
+// any it had, and shows its URL this once
+func (s *Server) handleCreateAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	if err := s.store.SetCategoryAlertToken(id, hashHookToken(token)); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	cat, err := s.store.GetCategory(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if cat.WorkLogs, err = s.store.GetWorkLogsForCategory(id); err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewCategoryView(cat, false, auth)
+	if cat.ShareToken != "" {
+		view.Embed = NewEmbedView(baseURL(r), cat.ShareToken, cat.Name)
+	}
+	view.AlertURL = baseURL(r) + "/alerts/" + token
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+	if err := s.presentation.RenderSlideoverWithDetails(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleDeleteAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.SetCategoryAlertToken(id, ""); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiPrefix is where the JSON API is served. Its routes are the board's own
+// handlers, which answer with JSON instead of HTML for any request under it.
+const apiPrefix = "/api/v1"
+
+// maxAPIBody is how large a JSON request body may be
+const maxAPIBody = 1 << 20
+
+// apiRoutes mounts the board's handlers under apiPrefix. Requests are
+// authorized by the session cookie like the pages are, and changes need the
+// CSRF token from /api/v1/me in the X-CSRF-Token header.
+func (s *Server) apiRoutes() {
+	routes := map[string]http.HandlerFunc{
+		"GET /me": s.handleAPIMe,
+
+		"GET /categories":             s.handleIndex,
+		"POST /categories":            s.handleCreateCategory,
+		"POST /categories/reorder":    s.handleReorderCategories,
+		"GET /categories/{id}":        s.handleGetCategoryDetails,
+		"PATCH /categories/{id}":      s.handleUpdateCategory,
+		"DELETE /categories/{id}":     s.handleDeleteCategory,
+		"POST /categories/{id}/tasks": s.handleCreateTask,
+
+		"POST /tasks/reorder":           s.handleReorderTasks,
+		"GET /tasks/{id}":               s.handleGetTaskDetails,
+		"PATCH /tasks/{id}":             s.handleUpdateTask,
+		"DELETE /tasks/{id}":            s.handleDeleteTask,
+		"POST /tasks/{id}/subtasks":     s.handleCreateSubtask,
+		"POST /tasks/{id}/work-logs":    s.handleCreateTaskWorkLog,
+		"POST /tasks/{id}/tags":         s.handleTagTask,
+		"POST /tasks/{id}/reforecast":   s.handleReforecastTask,
+		"POST /tasks/{id}/review":       s.handleRequestReview,
+		"POST /tasks/{id}/approve":      s.handleApproveTask,
+		"POST /tasks/{id}/return":       s.handleReturnTask,
+		"DELETE /tasks/{id}/tags/{tag}": s.handleUntagTask,
+
+		"POST /subtasks/reorder":        s.handleReorderSubtasks,
+		"GET /subtasks/{id}":            s.handleGetSubtaskDetails,
+		"PATCH /subtasks/{id}":          s.handleUpdateSubtask,
+		"DELETE /subtasks/{id}":         s.handleDeleteSubtask,
+		"POST /subtasks/{id}/work-logs": s.handleCreateSubtaskWorkLog,
+
+		"GET /tags":            s.handleGetTags,
+		"POST /tags":           s.handleCreateTag,
+		"PATCH /tags/{id}":     s.handleUpdateTag,
+		"DELETE /tags/{id}":    s.handleDeleteTag,
+		"GET /tags/{id}/tasks": s.handleGetTagTasks,
+
+		"PATCH /work-logs/{id}": s.handleUpdateWorkLog,
+		"POST /undo/{token}":    s.handleUndo,
+		"GET /changes":          s.handleGetChanges,
+		"GET /search":           s.handleSearch,
+		"GET /workload":         s.handleGetWorkload,
+	}
+	for pattern, handler := range routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		s.router.HandleFunc(method+" "+apiPrefix+path, s.api(handler))
+	}
+	s.router.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		apiError(w, r, http.StatusNotFound, "No such API route")
+	})
+}
+
+// isAPIRequest reports whether r was made to the JSON API
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+// api negotiates an API request before handing it to the board's handler:
+// the client must accept JSON, and a JSON body is read into the request's
+// form so the handler reads it as it would a submitted form.
+func (s *Server) api(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r.Header.Get("Accept")) {
+			apiError(w, r, http.StatusNotAcceptable, "The API only answers with application/json")
+			return
+		}
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch ct {
+		case "", "application/x-www-form-urlencoded":
+		case "application/json":
+			values, err := jsonForm(http.MaxBytesReader(w, r.Body, maxAPIBody))
+			if err != nil {
+				apiError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			r.PostForm = values
+			r.Form = r.URL.Query()
+			for k, vs := range values {
+				r.Form[k] = append(vs, r.Form[k]...)
+			}
+		default:
+			apiError(w, r, http.StatusUnsupportedMediaType, "Send a JSON object or a form, not "+ct)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// acceptsJSON reports whether an Accept header allows a JSON answer. No
+// header accepts anything.
+func acceptsJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+// jsonForm reads a flat JSON object, if any, as form values. True and false become
+// a checked and an unchecked box, null clears a field, and an array sends
+// a field once per element, as the reorder handlers expect of their ids.
+func jsonForm(body io.Reader) (url.Values, error) {
+	var fields map[string]any
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(&fields); errors.Is(err, io.EOF) {
+		return url.Values{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("the body must be a JSON object: %v", err)
+	}
+	values := url.Values{}
+	for k, v := range fields {
+		if vs, ok := v.([]any); ok {
+			for _, e := range vs {
+				s, err := formValue(e)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", k, err)
+				}
+				values.Add(k, s)
+			}
+			continue
+		}
+		s, err := formValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		values.Set(k, s)
+	}
+	return values, nil
+}
+
+func formValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "on", nil
+		}
+		return "off", nil
+	}
+	return "", fmt.Errorf("expected a string, number, or boolean")
+}
+
+// handleAPIMe tells an API client who it is signed in as, with the CSRF
+// token its changes must carry
+func (s *Server) handleAPIMe(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"handle":     auth.Handle,
+		"csrf_token": auth.CSRFToken,
+		"is_admin":   auth.IsAdmin,
+	})
+}
+
+// created answers an API request that made something, with where to find it
+func created(w http.ResponseWriter, path string, v any) {
+	w.Header().Set("Location", apiPrefix+path)
+	writeJSON(w, http.StatusCreated, v)
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// APIError is what an API client gets back when a request fails, always as
+// the "error" member of the response object so a client can tell it from a
+// result without looking at the status code
+type APIError struct {
+	Code      string       `json:"code"`             // stable across releases, for programs to branch on
+	Message   string       `json:"message"`          // for people; may change
+	Fields    []FieldError `json:"fields,omitempty"` // which submitted fields were wrong
+	RequestID string       `json:"request_id"`       // to quote in a bug report
+}
+
+// FieldError is a problem with one submitted field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error reads the field and its problem as a sentence, for plain-text answers
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Unwrap makes a field error invalid input, as the store's errors are
+func (e *FieldError) Unwrap() error {
+	return domain.ErrInvalid
+}
+
+// errorCodes name the statuses API errors are answered with
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// apiError answers a failed request: API clients get an APIError, and
+// everyone else the message as plain text, as http.Error would.
+func apiError(w http.ResponseWriter, r *http.Request, status int, message string, fields ...FieldError) {
+	if !parseRequestContext(r).WantsJSON {
+		http.Error(w, message, status)
+		return
+	}
+	writeJSON(w, status, map[string]APIError{"error": {
+		Code:      errorCode(status),
+		Message:   message,
+		Fields:    fields,
+		RequestID: requestID(r),
+	}})
+}
+
+// apiStoreError is storeError for handlers API clients call. A field error
+// anywhere in err's chain is listed as such.
+func apiStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var fields []FieldError
+	if fe := (*FieldError)(nil); errors.As(err, &fe) {
+		fields = append(fields, *fe)
+	}
+	apiError(w, r, storeErrorStatus(err), err.Error(), fields...)
+}
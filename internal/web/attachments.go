@@ -0,0 +1,391 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/blob"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+const (
+	thumbnailSize     = 160  // pixels, longest side
+	maxThumbnailInput = 40e6 // pixels; larger images get no thumbnail
+)
+
+// uploadPolicy says what an upload endpoint accepts. Types maps each
+// accepted sniffed content type to the type the file is stored and served
+// as; sniffing cannot tell audio-only containers from video.
+type uploadPolicy struct {
+	field   string
+	types   map[string]string
+	maxSize int64 // bytes
+}
+
+var (
+	attachmentPolicy = uploadPolicy{
+		field: "file",
+		types: map[string]string{
+			"image/png":                 "image/png",
+			"image/jpeg":                "image/jpeg",
+			"image/gif":                 "image/gif",
+			"image/webp":                "image/webp",
+			"application/pdf":           "application/pdf",
+			"application/zip":           "application/zip",
+			"text/plain; charset=utf-8": "text/plain; charset=utf-8",
+		},
+		maxSize: 10 << 20,
+	}
+	pastedImagePolicy = uploadPolicy{
+		field: "image",
+		types: map[string]string{
+			"image/png":  "image/png",
+			"image/jpeg": "image/jpeg",
+			"image/gif":  "image/gif",
+			"image/webp": "image/webp",
+		},
+		maxSize: 10 << 20,
+	}
+	voiceMemoPolicy = uploadPolicy{
+		field: "audio",
+		types: map[string]string{
+			"audio/mpeg":      "audio/mpeg",
+			"audio/wave":      "audio/wav",
+			"audio/aiff":      "audio/aiff",
+			"application/ogg": "audio/ogg",
+			"video/webm":      "audio/webm", // what MediaRecorder makes in most browsers
+			"video/mp4":       "audio/mp4",  // and in Safari
+		},
+		maxSize: 5 << 20,
+	}
+)
+
+// inlineTypes are served for display in the page; everything else downloads
+var inlineTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"audio/mpeg": true,
+	"audio/wav":  true,
+	"audio/aiff": true,
+	"audio/ogg":  true,
+	"audio/webm": true,
+	"audio/mp4":  true,
+}
+
+func thumbnailKey(id string) string {
+	return id + ".thumb"
+}
+
+func (s *Server) handleUploadTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	s.uploadAttachment(w, r, &domain.Attachment{TaskID: r.PathValue("id")})
+}
+
+func (s *Server) handleUploadWorkLogAttachment(w http.ResponseWriter, r *http.Request) {
+	s.uploadAttachment(w, r, &domain.Attachment{WorkLogID: r.PathValue("id")})
+}
+
+// uploadAttachment stores the "file" part of a multipart upload
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request, a *domain.Attachment) {
+	added, ok := s.receiveAttachment(w, r, a, attachmentPolicy)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+added.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+// receiveAttachment stores a multipart upload as the attachment a, if the
+// policy accepts it. The type is sniffed from the contents rather than
+// trusted from the client. On failure it has already written the response.
+func (s *Server) receiveAttachment(w http.ResponseWriter, r *http.Request, a *domain.Attachment, policy uploadPolicy) (*domain.Attachment, bool) {
+	// Leave room for the other form fields around the file, and parse before
+	// requireAuth so an oversized upload is reported as such, not as a
+	// missing CSRF token
+	r.Body = http.MaxBytesReader(w, r.Body, policy.maxSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Uploads here are limited to %d MB", policy.maxSize>>20), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Expected a multipart upload", http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return nil, false
+	}
+	if s.blobs == nil {
+		http.Error(w, "Attachments are not configured on this server", http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	file, header, err := r.FormFile(policy.field)
+	if err != nil {
+		http.Error(w, "Missing "+policy.field, http.StatusBadRequest)
+		return nil, false
+	}
+	defer file.Close()
+
+	if header.Size > policy.maxSize {
+		http.Error(w, fmt.Sprintf("Uploads here are limited to %d MB", policy.maxSize>>20), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	sniffed := http.DetectContentType(data)
+	contentType, ok := policy.types[sniffed]
+	if !ok {
+		http.Error(w, "Unsupported file type: "+sniffed, http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	a.Filename = filepath.Base(header.Filename)
+	a.ContentType = contentType
+	a.UploadedBy = auth.Handle
+	added, err := s.saveAttachment(a, data)
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	return added, true
+}
+
+// saveAttachment records a and stores data as its contents, with a
+// thumbnail if it is an image
+func (s *Server) saveAttachment(a *domain.Attachment, data []byte) (*domain.Attachment, error) {
+	thumb := makeThumbnail(data)
+	a.Size = int64(len(data))
+	a.HasThumbnail = thumb != nil
+
+	added, err := s.store.AddAttachment(a)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.blobs.Put(added.ID, bytes.NewReader(data)); err != nil {
+		s.store.DeleteAttachment(added.ID)
+		return nil, err
+	}
+	if thumb != nil {
+		if err := s.blobs.Put(thumbnailKey(added.ID), bytes.NewReader(thumb)); err != nil {
+			s.store.DeleteAttachment(added.ID)
+			s.blobs.Delete(added.ID)
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+// makeThumbnail returns a JPEG no larger than thumbnailSize on either side,
+// or nil if data is not an image the standard library can decode.
+func makeThumbnail(data []byte) []byte {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxThumbnailInput {
+		return nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	b := src.Bounds()
+	scale := float64(thumbnailSize) / float64(max(b.Dx(), b.Dy()))
+	if scale > 1 {
+		scale = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(b.Dx())*scale)), max(1, int(float64(b.Dy())*scale))))
+
+	// Nearest neighbour is plenty for a preview this size
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			dst.Set(x, y, src.At(b.Min.X+int(float64(x)/scale), b.Min.Y+int(float64(y)/scale)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// attachmentForViewer loads an attachment the viewer may see
+func (s *Server) attachmentForViewer(w http.ResponseWriter, r *http.Request) (*domain.Attachment, bool) {
+	a, err := s.store.GetAttachment(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	if !s.canViewTask(w, r, a.TaskID) {
+		return nil, false
+	}
+	return a, true
+}
+
+// canViewTask reports whether the viewer may see what hangs off a task:
+// anyone signed in who can see its category, or anyone at all when the
+// task is public. Otherwise it answers 404, so private tasks are not
+// revealed to exist.
+func (s *Server) canViewTask(w http.ResponseWriter, r *http.Request, taskID string) bool {
+	auth := s.getAuthContext(w, r)
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		storeError(w, err)
+		return false
+	}
+	if !auth.CanSee(task.CategoryID) || !auth.IsAuthenticated && (!task.Public || !task.ParentPublic) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGetAttachment(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.attachmentForViewer(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := "attachment"
+	if inlineTypes[a.ContentType] {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	s.serveBlob(w, r, a.ID)
+}
+
+func (s *Server) handleGetAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.attachmentForViewer(w, r)
+	if !ok {
+		return
+	}
+	if !a.HasThumbnail {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	s.serveBlob(w, r, thumbnailKey(a.ID))
+}
+
+// serveBlob sends a blob as the response. Uploads are untrusted, so the
+// browser is told not to sniff them and not to run anything they contain.
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, key string) {
+	if s.blobs == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	rc, err := s.blobs.Open(key)
+	if errors.Is(err, blob.ErrNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+
+	// Range requests let audio players seek, and some will not play without
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, rs)
+		return
+	}
+	io.Copy(w, rc)
+}
+
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	a, err := s.store.DeleteAttachment(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if s.blobs != nil {
+		s.blobs.Delete(a.ID)
+		s.blobs.Delete(thumbnailKey(a.ID))
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+a.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+func (s *Server) handlePasteTaskImage(w http.ResponseWriter, r *http.Request) {
+	s.pasteImage(w, r, r.PathValue("id"))
+}
+
+func (s *Server) handlePasteSubtaskImage(w http.ResponseWriter, r *http.Request) {
+	sub, err := s.store.GetSubtask(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.pasteImage(w, r, sub.TaskID)
+}
+
+// pasteImage stores an image pasted into a description editor as an
+// attachment on taskID and answers with the markup to insert at the cursor.
+func (s *Server) pasteImage(w http.ResponseWriter, r *http.Request, taskID string) {
+	added, ok := s.receiveAttachment(w, r, &domain.Attachment{TaskID: taskID}, pastedImagePolicy)
+	if !ok {
+		return
+	}
+
+	alt := strings.TrimSuffix(added.Filename, filepath.Ext(added.Filename))
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	io.WriteString(w, domain.ImageMarkup(alt, added.ID))
+}
+
+// handleUploadVoiceMemo attaches a short recording to a work log, for
+// narrating what was done instead of typing it.
+func (s *Server) handleUploadVoiceMemo(w http.ResponseWriter, r *http.Request) {
+	added, ok := s.receiveAttachment(w, r, &domain.Attachment{WorkLogID: r.PathValue("id")}, voiceMemoPolicy)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+added.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
@@ -0,0 +1,252 @@
+package web
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.sr.ht/~jakintosh/compass/internal/backup"
+	"git.sr.ht/~jakintosh/compass/internal/blob"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+const (
+	minPassphraseLength = 8
+	maxBackupSize       = 4 << 30
+
+	dumpEntry  = "dump.json"
+	blobPrefix = "blobs/"
+)
+
+// backupBlobKeys lists the blobs behind the attachments in dump: each file,
+// and its thumbnail if it has one
+func backupBlobKeys(dump *domain.Dump) []string {
+	var keys []string
+	for _, row := range dump.Attachments {
+		id, ok := row["id"].(string)
+		if !ok {
+			continue
+		}
+		keys = append(keys, id)
+		if fmt.Sprint(row["has_thumbnail"]) == "1" {
+			keys = append(keys, thumbnailKey(id))
+		}
+	}
+	return keys
+}
+
+// handleExportBackup downloads the whole board and its attachments as a tar
+// archive encrypted with the passphrase given. Once the download has
+// started a failure can only be logged; the archive is then left without
+// its final chunk, so it will not open rather than restore partially.
+func (s *Server) handleExportBackup(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	passphrase := r.PostForm.Get("passphrase")
+	if utf8.RuneCountInString(passphrase) < minPassphraseLength {
+		http.Error(w, fmt.Sprintf("The passphrase must be at least %d characters", minPassphraseLength), http.StatusBadRequest)
+		return
+	}
+	if passphrase != r.PostForm.Get("passphrase_confirm") {
+		http.Error(w, "The passphrases do not match", http.StatusBadRequest)
+		return
+	}
+
+	dump, err := s.store.ExportDump()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := "compass-" + dump.ExportedAt.Format("2006-01-02") + backup.Extension
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	enc, err := backup.Encrypt(w, passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.writeBackup(enc, dump, data); err != nil {
+		log.Printf("Failed to export backup: %v", err)
+		return
+	}
+	if err := enc.Close(); err != nil {
+		log.Printf("Failed to export backup: %v", err)
+	}
+}
+
+func (s *Server) writeBackup(dst io.Writer, dump *domain.Dump, data []byte) error {
+	tw := tar.NewWriter(dst)
+	if err := writeTarFile(tw, dumpEntry, data, dump.ExportedAt); err != nil {
+		return err
+	}
+
+	if s.blobs != nil {
+		for _, key := range backupBlobKeys(dump) {
+			rc, err := s.blobs.Open(key)
+			if errors.Is(err, blob.ErrNotFound) {
+				log.Printf("Backup: attachment blob %s is missing, skipping it", key)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			contents, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := writeTarFile(tw, blobPrefix+key, contents, dump.ExportedAt); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o600,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// handleRestoreBackup loads an encrypted backup into an empty board. The
+// dump goes in first, in one transaction, so a backup that does not fit
+// leaves nothing behind; attachment files follow it. Only blobs the dump
+// refers to are written.
+func (s *Server) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBackupSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Backups are limited to %d GB", maxBackupSize>>30), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Expected a multipart upload", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	file, _, err := r.FormFile("backup")
+	if err != nil {
+		http.Error(w, "Missing backup", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	plain, err := backup.Decrypt(file, r.PostForm.Get("passphrase"))
+	if err != nil {
+		backupError(w, err)
+		return
+	}
+	tr := tar.NewReader(plain)
+
+	header, err := tr.Next()
+	if err != nil {
+		backupError(w, err)
+		return
+	}
+	if header.Name != dumpEntry {
+		http.Error(w, "The backup does not start with a dump", http.StatusBadRequest)
+		return
+	}
+	var dump domain.Dump
+	dec := json.NewDecoder(tr)
+	dec.UseNumber()
+	if err := dec.Decode(&dump); err != nil {
+		backupError(w, err)
+		return
+	}
+	if err := s.store.ImportDump(&dump, auth.Handle); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, key := range backupBlobKeys(&dump) {
+		wanted[key] = true
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "The board was restored, but not all attachments: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		key, ok := strings.CutPrefix(header.Name, blobPrefix)
+		if !ok || !wanted[key] || s.blobs == nil {
+			continue
+		}
+		if err := s.blobs.Put(key, tr); err != nil {
+			http.Error(w, "The board was restored, but not all attachments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	redirectBack(w, r, "/")
+}
+
+// backupError reports a backup that could not be read
+func backupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, backup.ErrPassphrase):
+		http.Error(w, "Wrong passphrase, or the backup is damaged", http.StatusBadRequest)
+	case errors.Is(err, backup.ErrFormat):
+		http.Error(w, "That file is not a Compass backup", http.StatusBadRequest)
+	default:
+		http.Error(w, "Could not read the backup: "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// handleExportAccount downloads everything kept about the signed-in user as
+// JSON, for data portability requests. Unlike a backup it is not
+// encrypted, and covers one person rather than the board.
+func (s *Server) handleExportAccount(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	export, err := s.store.ExportAccount(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	filename := "compass-account-" + export.ExportedAt.Format("2006-01-02") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, export)
+}
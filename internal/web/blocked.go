@@ -0,0 +1,29 @@
+package web
+
+import "net/http"
+
+func (s *Server) handleGetBlocked(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	tasks, err := s.store.GetBlockedTasks()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewBlockedView(tasks, s.clock.Now(), auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderBlocked(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// inboxCategoryName is the category captured tasks land in. It is created
+// on first use and can be renamed back or reordered like any other.
+const inboxCategoryName = "Inbox"
+
+// capture creates a task from outside the board: a shortcut, a share, a
+// hook. Without a name the first line of the description is used.
+func (s *Server) capture(name, description, actor string) (*domain.Task, error) {
+	cat, err := s.inboxCategory(actor)
+	if err != nil {
+		return nil, err
+	}
+	return s.captureInto(cat.ID, name, description, actor)
+}
+
+// captureInto is capture into a category other than the inbox
+func (s *Server) captureInto(categoryID, name, description, actor string) (*domain.Task, error) {
+	if strings.TrimSpace(name) == "" {
+		name, _, _ = strings.Cut(strings.TrimSpace(description), "\n")
+		if r := []rune(name); len(r) > domain.MaxNameLength {
+			name = string(r[:domain.MaxNameLength-1]) + "…"
+		}
+	}
+	name, err := domain.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.AddTask(categoryID, name)
+	if err != nil {
+		return nil, err
+	}
+	if description == "" {
+		return task, nil
+	}
+	task.Description = description
+	return s.store.UpdateTask(task, actor)
+}
+
+// inboxCategory finds the category the user's captures go to, creating it
+// if needed
+func (s *Server) inboxCategory(user string) (*domain.Category, error) {
+	return s.categoryNamed(inboxCategoryName, user)
+}
+
+// categoryNamed finds the category with a name, ignoring case, creating it
+// for owner if needed. On private boards only owner's own categories are
+// looked at, so each user gets their own; an empty owner means the ones
+// that are everyone's.
+func (s *Server) categoryNamed(name, owner string) (*domain.Category, error) {
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if s.privateBoards && c.OwnerID != owner {
+			continue
+		}
+		if strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+	}
+	return s.store.AddCategory(name, owner)
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/vault"
+)
+
+// maxVaultSize bounds an uploaded vault, attachments and all
+const maxVaultSize = 64 << 20
+
+// handleGetCategoryImport shows the form for importing a vault
+func (s *Server) handleGetCategoryImport(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	cat, err := s.store.GetCategory(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if !auth.CanSee(cat.ID) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	s.renderCategoryImport(w, r, auth, NewCategoryImportView(cat, auth))
+}
+
+// handleImportCategory imports the checkboxes in an uploaded vault, a zip
+// of its folder or a single note, into the category
+func (s *Server) handleImportCategory(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVaultSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Vaults are limited to %d MB", maxVaultSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Expected a multipart upload", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	file, header, err := r.FormFile("vault")
+	if err != nil {
+		http.Error(w, "Missing vault", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var tasks []*domain.VaultTask
+	switch strings.ToLower(path.Ext(header.Filename)) {
+	case ".zip":
+		zr, err := zip.NewReader(file, header.Size)
+		if err != nil {
+			http.Error(w, "The vault is not a readable zip file", http.StatusBadRequest)
+			return
+		}
+		if tasks, err = vault.Parse(zr); err != nil {
+			http.Error(w, "The vault could not be read: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case ".md":
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		tasks = vault.ParseFile(path.Base(header.Filename), string(data))
+	default:
+		http.Error(w, "Upload a vault as a .zip of its folder, or a single .md note", http.StatusBadRequest)
+		return
+	}
+	if len(tasks) == 0 {
+		http.Error(w, "No checkboxes were found to import", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	result, err := s.store.ImportVault(id, tasks, auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	cat, err := s.store.GetCategory(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewCategoryImportView(cat, auth)
+	view.Found = len(tasks)
+	view.Result = result
+
+	if parseRequestContext(r).IsHTMX {
+		// The board gets the new tasks alongside the report
+		var buf bytes.Buffer
+		if err := s.presentation.RenderCategoryOOB(&buf, NewCategoryView(cat, true, auth)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.presentation.RenderCategoryImport(&buf, view); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(buf.Bytes())
+		return
+	}
+	s.renderCategoryImport(w, r, auth, view)
+}
+
+func (s *Server) renderCategoryImport(w http.ResponseWriter, r *http.Request, auth AuthContext, view CategoryImportView) {
+	if parseRequestContext(r).IsHTMX {
+		if err := s.presentation.RenderCategoryImport(w, view); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.renderIndex(w, auth, view)
+}
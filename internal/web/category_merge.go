@@ -0,0 +1,80 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// handleGetCategoryMerge asks which category to merge this one into
+func (s *Server) handleGetCategoryMerge(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view, ok := NewCategoryMergeView(id, categories, auth)
+	if !ok {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderCategoryMerge(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleMergeCategory merges the category into the one named by "into",
+// then shows that one
+func (s *Server) handleMergeCategory(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	cat, err := s.store.MergeCategories(id, r.FormValue("into"), auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		http.Redirect(w, r, "/categories/"+cat.ID+"/details", http.StatusSeeOther)
+		return
+	}
+
+	if cat.WorkLogs, err = s.store.GetWorkLogsForCategory(cat.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := s.presentation.RenderCategoryDeleteOOB(&buf, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.presentation.RenderCategoryOOB(&buf, NewCategoryView(cat, true, auth)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.presentation.RenderSlideoverWithDetails(&buf, NewCategoryView(cat, false, auth)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// A long-poll for changes waits changesWait unless the client asks for less,
+// and never more than changesMaxWait, which stays under the minute many
+// proxies allow a quiet response. The store is checked every changesPoll
+// meanwhile, which catches changes made by jobs and hooks as well as by
+// other requests.
+const (
+	changesWait    = 25 * time.Second
+	changesMaxWait = 55 * time.Second
+	changesPoll    = time.Second
+	changesLimit   = 100
+)
+
+// ChangesResponse is one round of long-polling. Cursor is what to send as
+// since next time.
+type ChangesResponse struct {
+	Cursor  int64                 `json:"cursor"`
+	Changes []*domain.BoardChange `json:"changes"`
+	Reset   bool                  `json:"reset,omitempty"` // since was too old to catch up from; reload everything
+}
+
+// handleGetChanges answers as soon as the board changes after since, or
+// with no changes once the wait is up. Without since it answers at once
+// with where the board is now, for a client to start from.
+func (s *Server) handleGetChanges(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	first, last, err := s.store.GetBoardChangeRange()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	query := r.URL.Query()
+	if !query.Has("since") {
+		writeJSON(w, http.StatusOK, ChangesResponse{Cursor: last, Changes: []*domain.BoardChange{}})
+		return
+	}
+	since, err := strconv.ParseInt(query.Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		apiError(w, r, http.StatusBadRequest, "Invalid since value", FieldError{Field: "since", Message: "must be a cursor from an earlier answer"})
+		return
+	}
+	// A cursor from before the oldest change kept, or from another
+	// database, cannot be caught up from
+	if since < first-1 || since > last {
+		writeJSON(w, http.StatusOK, ChangesResponse{Cursor: last, Changes: []*domain.BoardChange{}, Reset: true})
+		return
+	}
+	wait := changesWait
+	if v := query.Get("timeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			apiError(w, r, http.StatusBadRequest, "Invalid timeout value", FieldError{Field: "timeout", Message: "must be a whole number of seconds"})
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, changesMaxWait)
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	poll := time.NewTicker(changesPoll)
+	defer poll.Stop()
+	for {
+		changes, err := s.store.GetBoardChanges(since, changesLimit)
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		// Changes to categories the user cannot see move the cursor on
+		// without being told
+		visible := []*domain.BoardChange{}
+		for _, c := range changes {
+			since = c.Seq
+			if auth.CanSee(c.CategoryID) {
+				visible = append(visible, c)
+			}
+		}
+		if len(visible) > 0 {
+			writeJSON(w, http.StatusOK, ChangesResponse{Cursor: since, Changes: visible})
+			return
+		}
+		if len(changes) == changesLimit {
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout.C:
+			writeJSON(w, http.StatusOK, ChangesResponse{Cursor: since, Changes: visible})
+			return
+		case <-poll.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package web
+
+import "net/http"
+
+func (s *Server) handleAddDoneCriterion(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if _, err := s.store.AddDoneCriterion(id, r.FormValue("text")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
+
+// handleDeleteDoneCriterion stops adding the item to new tasks; tasks that
+// already have it keep their copy
+func (s *Server) handleDeleteDoneCriterion(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteDoneCriterion(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/")
+}
+
+// handleSetTaskCheck checks or unchecks an item of a task's definition of
+// done. Checking the last one can finish a task with subtasks, so the
+// category is re-rendered too.
+func (s *Server) handleSetTaskCheck(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var checked bool
+	newFormPatch(r.PostForm).Checkbox("checked", &checked)
+	check, err := s.store.SetTaskCheck(r.PathValue("id"), checked)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.renderTaskCategory(w, r, ctx, auth, check.TaskID)
+}
+
+// detailsChanged finishes a change that only the open details show
+func detailsChanged(w http.ResponseWriter, r *http.Request, ctx RequestContext, fallback string) {
+	if !ctx.IsHTMX {
+		redirectBack(w, r, fallback)
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// clipRequest is a page sent from a browser extension. Screenshot is a data
+// URL, as browsers' captureVisibleTab gives it.
+type clipRequest struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	Selection  string `json:"selection"`
+	Screenshot string `json:"screenshot"`
+	Category   string `json:"category"`
+}
+
+// handleHookClip saves a page from the browser as a task to read or act on:
+// named after the page's title, quoting any text selected on it, with a
+// link to it and a screenshot attached. It goes in the category named in
+// the request, or else the one the user chose in settings; a URL scoped to
+// a category always clips into that one.
+func (s *Server) handleHookClip(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req clipRequest
+	body := http.MaxBytesReader(w, r.Body, pastedImagePolicy.maxSize*4/3+1<<20)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Screenshots are limited to %d MB", pastedImagePolicy.maxSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	link := &domain.Link{Kind: domain.LinkDoc, URL: req.URL, AddedBy: t.UserID}
+	if err := link.Normalize(); err != nil {
+		storeError(w, err)
+		return
+	}
+	screenshot, contentType, err := decodeScreenshot(req.Screenshot)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Title)
+	if name == "" {
+		u, _ := url.Parse(link.URL)
+		name = u.Host + u.Path
+	}
+	if runes := []rune(name); len(runes) > domain.MaxNameLength {
+		name = string(runes[:domain.MaxNameLength-1]) + "…"
+	}
+
+	prefs, err := s.store.GetPreferences(t.UserID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	fallback := prefs.ClipCategory
+	if fallback == "" {
+		fallback = domain.DefaultClipCategory
+	}
+	cat, ok := s.hookCategory(w, t, req.Category, fallback)
+	if !ok {
+		return
+	}
+
+	task, err := s.store.AddTask(cat.ID, name)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if quote := quoteSelection(req.Selection); quote != "" {
+		task.Description = quote
+		if task, err = s.store.UpdateTask(task, t.UserID); err != nil {
+			storeError(w, err)
+			return
+		}
+	}
+
+	link.TaskID = task.ID
+	if link, err = s.store.AddLink(link); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.fetchPreview(link)
+
+	// A server without attachment storage still takes the page
+	if screenshot != nil && s.blobs != nil {
+		if _, err := s.saveAttachment(&domain.Attachment{
+			TaskID:      task.ID,
+			Filename:    "screenshot." + strings.TrimPrefix(contentType, "image/"),
+			ContentType: contentType,
+			UploadedBy:  t.UserID,
+		}, screenshot); err != nil {
+			storeError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"task":     task,
+		"category": cat.Name,
+		"url":      baseURL(r) + "/tasks/" + task.ID + "/details",
+	})
+}
+
+// decodeScreenshot reads a data URL of an image, checking its type from the
+// contents. An empty one is no screenshot.
+func decodeScreenshot(dataURL string) ([]byte, string, error) {
+	if dataURL == "" {
+		return nil, "", nil
+	}
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, "", errors.New("screenshot must be a base64 data URL")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", errors.New("screenshot is not valid base64")
+	}
+	if int64(len(data)) > pastedImagePolicy.maxSize {
+		return nil, "", fmt.Errorf("screenshots are limited to %d MB", pastedImagePolicy.maxSize>>20)
+	}
+	sniffed := http.DetectContentType(data)
+	contentType, ok := pastedImagePolicy.types[sniffed]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported screenshot type: %s", sniffed)
+	}
+	return data, contentType, nil
+}
+
+// quoteSelection writes text selected on a page as a markdown quote, cut
+// short if it would not fit in a description
+func quoteSelection(text string) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	quote := []rune(strings.Join(lines, "\n"))
+	if len(quote) > domain.MaxDescriptionLength {
+		return string(quote[:domain.MaxDescriptionLength-1]) + "…"
+	}
+	return string(quote)
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// warnThenConfirm answers a change a check has stopped with a warning that
+// can resend it with the check overridden: JSON clients get a conflict
+// carrying message and fields, HTMX a dialog from render laid over the page,
+// and plain forms the board with page open beside it
+func (s *Server) warnThenConfirm(w http.ResponseWriter, r *http.Request, auth AuthContext, message string, render func(io.Writer) error, page any, fields ...FieldError) {
+	ctx := parseRequestContext(r)
+	switch {
+	case ctx.WantsJSON:
+		apiError(w, r, http.StatusConflict, message, fields...)
+	case ctx.IsHTMX:
+		w.Header().Set("HX-Retarget", "body")
+		w.Header().Set("HX-Reswap", "beforeend")
+		if err := render(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	default:
+		s.renderIndexStatus(w, http.StatusConflict, auth, page)
+	}
+}
+
+// carriedFields lists a form's values, but for skip, so a warning can resend
+// them with skip set
+func carriedFields(form url.Values, skip string) []FormField {
+	var fields []FormField
+	for _, name := range slices.Sorted(maps.Keys(form)) {
+		if name == skip {
+			continue
+		}
+		for _, v := range form[name] {
+			fields = append(fields, FormField{Name: name, Value: v})
+		}
+	}
+	return fields
+}
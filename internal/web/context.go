@@ -1,6 +1,9 @@
 package web
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
 
 type RequestContext struct {
 	IsHTMX      bool   // HX-Request header present
@@ -9,6 +12,7 @@ type RequestContext struct {
 	TriggerName string // HX-Trigger-Name
 	TargetID    string // HX-Target - where response will land
 	Boosted     bool   // HX-Boosted - was this a boosted link/form?
+	WantsJSON   bool   // Accept asks for JSON, or the request was made to the API
 }
 
 func parseRequestContext(r *http.Request) RequestContext {
@@ -19,5 +23,19 @@ func parseRequestContext(r *http.Request) RequestContext {
 		TriggerName: r.Header.Get("HX-Trigger-Name"),
 		TargetID:    r.Header.Get("HX-Target"),
 		Boosted:     r.Header.Get("HX-Boosted") == "true",
+		WantsJSON:   strings.Contains(r.Header.Get("Accept"), "application/json") || isAPIRequest(r),
 	}
 }
+
+// isMobileClient reports whether a request should get the mobile layout. An
+// explicit viewport hint cookie (set by app.js) wins; otherwise the client
+// hint and then the user agent are consulted.
+func isMobileClient(r *http.Request) bool {
+	if c, err := r.Cookie("viewport"); err == nil {
+		return c.Value == "narrow"
+	}
+	if hint := r.Header.Get("Sec-CH-UA-Mobile"); hint != "" {
+		return hint == "?1"
+	}
+	return strings.Contains(r.UserAgent(), "Mobi")
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// handleSetTaskContexts replaces a task's contexts with those listed in the
+// form, separated by spaces or commas
+func (s *Server) handleSetTaskContexts(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	contexts := strings.FieldsFunc(r.FormValue("contexts"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if _, err := s.store.SetTaskContexts(id, contexts); err != nil {
+		storeError(w, err)
+		return
+	}
+	// The task may have joined or left the context the board is filtered to
+	s.renderTaskCategory(w, r, ctx, s.withPreferences(auth), id)
+}
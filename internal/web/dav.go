@@ -0,0 +1,212 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/boardfile"
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// The board over WebDAV is a read-only tree of boardfile's files. They are
+// rendered from the store on each request, so they are always current.
+const (
+	davAllow    = "OPTIONS, GET, HEAD, PROPFIND"
+	davMarkdown = "text/markdown; charset=utf-8"
+)
+
+// handleDAV serves the board to WebDAV clients, authorized by a hook token
+// in the URL so it can be mounted without signing in
+func (s *Server) handleDAV(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", davAllow)
+		return
+	case http.MethodGet, http.MethodHead, "PROPFIND":
+	default:
+		w.Header().Set("Allow", davAllow)
+		http.Error(w, "The board is read-only over WebDAV", http.StatusMethodNotAllowed)
+		return
+	}
+
+	loc := time.Local
+	if prefs, err := s.store.GetPreferences(t.UserID); err == nil {
+		loc = prefs.Location()
+	}
+	segments := davSegments(r.PathValue("path"))
+	node, err := s.davLookup(segments, loc, s.hookReach(t))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	// Folders are addressed with a trailing slash, as clients expect
+	href := "/dav/" + r.PathValue("token") + "/"
+	for _, seg := range segments {
+		href += url.PathEscape(seg) + "/"
+	}
+	if !node.Dir {
+		href = strings.TrimSuffix(href, "/")
+	}
+
+	if r.Method == "PROPFIND" {
+		davPropfind(w, r, href, node)
+		return
+	}
+	if node.Dir {
+		// A browser opening a folder gets a plain listing
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, c := range node.Children {
+			if c.Dir {
+				fmt.Fprintf(w, "%s/\n", c.Name)
+			} else {
+				fmt.Fprintln(w, c.Name)
+			}
+		}
+		return
+	}
+	w.Header().Set("Content-Type", davMarkdown)
+	http.ServeContent(w, r, node.Name, node.Modified, bytes.NewReader(node.Content))
+}
+
+// davSegments splits a path in the tree into its folder and file names
+func davSegments(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// davLookup finds the node at a path, with its children if it is a folder.
+// Only the category a path leads into is rendered, and only the categories
+// the hook reaches are in the tree.
+func (s *Server) davLookup(segments []string, loc *time.Location, reach func(categoryID string) bool) (*boardfile.File, error) {
+	if len(segments) > 2 {
+		return nil, fmt.Errorf("file %w", domain.ErrNotFound)
+	}
+
+	all, err := s.store.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	var categories []*domain.Category
+	for _, c := range all {
+		if reach(c.ID) {
+			categories = append(categories, c)
+		}
+	}
+	lastWorked, err := s.store.GetLastWorked()
+	if err != nil {
+		return nil, err
+	}
+
+	root := boardfile.Tree(categories, lastWorked)
+	if len(segments) == 0 {
+		return root, nil
+	}
+
+	for i, folder := range root.Children {
+		if folder.Name != segments[0] {
+			continue
+		}
+		c := categories[i]
+		if c.WorkLogs, err = s.store.GetWorkLogsForCategory(c.ID); err != nil {
+			return nil, err
+		}
+		folder.Children = boardfile.CategoryFiles(c, lastWorked, loc)
+		if len(segments) == 1 {
+			return folder, nil
+		}
+		for _, f := range folder.Children {
+			if f.Name == segments[1] {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("file %w", domain.ErrNotFound)
+}
+
+// WebDAV PROPFIND responses, RFC 4918 section 9.1. Every request is
+// answered with the same properties, which clients accept in place of the
+// ones they asked for.
+type (
+	davMultistatus struct {
+		XMLName   xml.Name      `xml:"D:multistatus"`
+		XMLNS     string        `xml:"xmlns:D,attr"`
+		Responses []davResponse `xml:"D:response"`
+	}
+	davResponse struct {
+		Href     string      `xml:"D:href"`
+		Propstat davPropstat `xml:"D:propstat"`
+	}
+	davPropstat struct {
+		Prop   davProp `xml:"D:prop"`
+		Status string  `xml:"D:status"`
+	}
+	davProp struct {
+		DisplayName   string          `xml:"D:displayname"`
+		ResourceType  davResourceType `xml:"D:resourcetype"`
+		LastModified  string          `xml:"D:getlastmodified,omitempty"`
+		ContentLength string          `xml:"D:getcontentlength,omitempty"`
+		ContentType   string          `xml:"D:getcontenttype,omitempty"`
+		ETag          string          `xml:"D:getetag,omitempty"`
+	}
+	davResourceType struct {
+		Collection *struct{} `xml:"D:collection"`
+	}
+)
+
+// davPropfind describes a node, and with any depth but 0 its children
+func davPropfind(w http.ResponseWriter, r *http.Request, href string, node *boardfile.File) {
+	ms := davMultistatus{XMLNS: "DAV:", Responses: []davResponse{davDescribe(href, node)}}
+	if node.Dir && r.Header.Get("Depth") != "0" {
+		for _, c := range node.Children {
+			childHref := href + url.PathEscape(c.Name)
+			if c.Dir {
+				childHref += "/"
+			}
+			ms.Responses = append(ms.Responses, davDescribe(childHref, c))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(ms)
+}
+
+func davDescribe(href string, node *boardfile.File) davResponse {
+	prop := davProp{DisplayName: node.Name}
+	if !node.Modified.IsZero() {
+		prop.LastModified = node.Modified.UTC().Format(http.TimeFormat)
+	}
+	if node.Dir {
+		prop.ResourceType.Collection = &struct{}{}
+	} else {
+		prop.ContentLength = strconv.Itoa(len(node.Content))
+		prop.ContentType = davMarkdown
+		sum := sha256.Sum256(node.Content)
+		prop.ETag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	}
+	return davResponse{
+		Href:     href,
+		Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"},
+	}
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+)
+
+func (s *Server) handleAddDependency(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.AddDependency(id, r.FormValue("depends_on_id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.renderTaskCategory(w, r, ctx, auth, id)
+}
+
+func (s *Server) handleRemoveDependency(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.RemoveDependency(id, r.PathValue("other")); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.renderTaskCategory(w, r, ctx, auth, id)
+}
+
+// renderTaskCategory answers a change to a task with its whole category,
+// since a new or removed dependency can move the critical path through any
+// of its tasks, and a checked item can finish the task
+func (s *Server) renderTaskCategory(w http.ResponseWriter, r *http.Request, ctx RequestContext, auth AuthContext, taskID string) {
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+taskID+"/details")
+		return
+	}
+
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	cat, err := s.store.GetCategory(task.CategoryID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := s.presentation.RenderCategoryOOB(&buf, NewCategoryView(cat, true, auth)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Names at least this alike are offered as possible duplicates, at most
+// maxDuplicates of them
+const (
+	duplicateSimilarity = 0.5
+	maxDuplicates       = 5
+)
+
+// duplicatesFound stops a request that would add a task named like ones
+// already in the category, answering with the likely duplicates and a way
+// to resend the same request with duplicate_ok set. It reports whether it
+// did. The request's form must already be parsed.
+func (s *Server) duplicatesFound(w http.ResponseWriter, r *http.Request, auth AuthContext, categoryID, name string) bool {
+	if r.PostForm.Get("duplicate_ok") == "on" {
+		return false
+	}
+	// Failing to check is no reason to refuse the task
+	similar, err := s.store.FindSimilarTasks(categoryID, name, duplicateSimilarity, maxDuplicates)
+	if err != nil || len(similar) == 0 {
+		return false
+	}
+
+	names := make([]string, len(similar))
+	view := DuplicateWarningView{
+		AuthContext: auth,
+		Name:        name,
+		URL:         r.URL.RequestURI(),
+		Fields:      carriedFields(r.PostForm, "duplicate_ok"),
+		ReturnURL:   r.PostForm.Get("return_to"),
+	}
+	for i, t := range similar {
+		names[i] = t.Name
+		view.Tasks = append(view.Tasks, DuplicateView{
+			AuthContext: auth,
+			Name:        t.Name,
+			Completion:  t.Completion,
+			DetailsURL:  "/tasks/" + t.ID + "/details",
+		})
+	}
+	// Accessible forms always post to the path itself
+	page := view
+	page.URL = r.URL.Path
+
+	s.warnThenConfirm(w, r, auth,
+		"The category has tasks like this already: "+strings.Join(names, ", ")+"; send duplicate_ok to add it anyway",
+		func(w io.Writer) error { return s.presentation.RenderDuplicateWarning(w, view) },
+		page,
+		FieldError{Field: "name", Message: "is like a task already in the category"})
+	return true
+}
+
+// newTaskName is the name a task gets when none is given
+func newTaskName(name string) string {
+	if name = strings.TrimSpace(name); name == "" {
+		return "New Task"
+	}
+	return name
+}
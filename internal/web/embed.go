@@ -0,0 +1,78 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+)
+
+// embedCategory finds the category a share token in the path is for
+func (s *Server) embedCategory(w http.ResponseWriter, r *http.Request) (EmbedProgressView, bool) {
+	cat, err := s.store.GetCategoryByShareToken(r.PathValue("token"))
+	if err != nil {
+		storeError(w, err)
+		return EmbedProgressView{}, false
+	}
+	// Embeds are fetched through caches like GitHub's image proxy, which
+	// would otherwise keep showing old progress
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return NewEmbedProgressView(cat), true
+}
+
+// handleEmbedProgressSVG serves a shared category's progress as a badge
+func (s *Server) handleEmbedProgressSVG(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.embedCategory(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := s.presentation.RenderEmbedProgressSVG(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleEmbedProgressPage serves a shared category's progress as a small
+// page to put in an iframe
+func (s *Server) handleEmbedProgressPage(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.embedCategory(w, r)
+	if !ok {
+		return
+	}
+	if err := s.presentation.RenderEmbedProgressPage(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleShareCategory gives the category a share token, replacing any it
+// had, so its progress can be embedded
+func (s *Server) handleShareCategory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	token := make([]byte, 18)
+	rand.Read(token)
+	if err := s.store.SetCategoryShareToken(id, base64.RawURLEncoding.EncodeToString(token)); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
+
+func (s *Server) handleUnshareCategory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.SetCategoryShareToken(id, ""); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
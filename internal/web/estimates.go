@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleReforecastTask takes the new estimate offered after a work log used
+// up most of the old one, keeping the change in the task's estimate history
+func (s *Server) handleReforecastTask(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	taskID := r.PathValue("id")
+
+	estimate, err := strconv.ParseFloat(r.FormValue("estimate"), 64)
+	if err != nil {
+		apiError(w, r, http.StatusBadRequest, "Invalid estimate value", FieldError{Field: "estimate", Message: "must be a number of hours"})
+		return
+	}
+	task, err := s.store.ReforecastTask(taskID, estimate, auth.Handle)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, task)
+		return
+	}
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+taskID+"/details")
+		return
+	}
+	// The estimate weighs the task in its category's completion
+	w.Header().Set("HX-Trigger", "detailsChanged, boardChanged")
+}
+
+// needsReforecast reports whether the hours logged on a task have used up
+// most of its estimate
+func (s *Server) needsReforecast(taskID string) bool {
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		return false
+	}
+	if task.WorkLogs, err = s.store.GetWorkLogsForTask(taskID); err != nil {
+		return false
+	}
+	return task.NeedsReforecast()
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Pages listen at /events to hear about the board changing the moment it
+// does. Requests that change something announce it as they succeed; changes
+// made outside a request, by jobs and syncing, are caught by checking the
+// store's change log every eventsPoll while anyone is listening. A comment
+// goes out every eventsKeepAlive so proxies don't close a quiet stream, and
+// a stream ends after eventsMaxAge for the page to reconnect, which checks
+// its session again.
+const (
+	eventsPoll      = 5 * time.Second
+	eventsKeepAlive = 25 * time.Second
+	eventsMaxAge    = 15 * time.Minute
+)
+
+// broker tells the pages listening at /events that something changed. It
+// carries no content: what a page shows depends on who is looking, so each
+// listener renders its own.
+type broker struct {
+	store domain.Store
+
+	mu        sync.Mutex
+	listeners map[chan struct{}]bool
+	seen      int64         // the last change logged that listeners were told of
+	stop      chan struct{} // ends the watch once nobody is listening
+}
+
+func newBroker(store domain.Store) *broker {
+	return &broker{store: store, listeners: make(map[chan struct{}]bool)}
+}
+
+// subscribe returns a channel signaled after each change. A signal arriving
+// while another waits is merged into it, so a listener slow to render
+// renders once for a burst of changes.
+func (b *broker) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners[ch] = true
+	if b.stop == nil {
+		b.stop = make(chan struct{})
+		go b.watch(b.stop)
+	}
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.listeners, ch)
+	if len(b.listeners) == 0 && b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+// publish tells every listener that something changed, which covers
+// everything in the change log so far
+func (b *broker) publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.listeners) == 0 {
+		return
+	}
+	if _, last, err := b.store.GetBoardChangeRange(); err == nil {
+		b.seen = max(b.seen, last)
+	}
+	b.notify()
+}
+
+func (b *broker) notify() {
+	for ch := range b.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch publishes the changes that reach the store's change log with no
+// request announcing them
+func (b *broker) watch(stop chan struct{}) {
+	if _, last, err := b.store.GetBoardChangeRange(); err == nil {
+		b.mu.Lock()
+		b.seen = last
+		b.mu.Unlock()
+	}
+	tick := time.NewTicker(eventsPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+
+		_, last, err := b.store.GetBoardChangeRange()
+		if err != nil {
+			continue
+		}
+		b.mu.Lock()
+		if last > b.seen {
+			b.seen = last
+			b.notify()
+		}
+		b.mu.Unlock()
+	}
+}
+
+// withEvents announces every request that changes something, once it
+// succeeds, to the pages listening at /events. Timer heartbeats are left
+// out: every page with a timer running sends one a minute, and they change
+// nothing on the board.
+func (s *Server) withEvents(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status < http.StatusBadRequest && !strings.HasSuffix(r.URL.Path, "/timers/heartbeat") {
+			s.events.publish()
+		}
+	})
+}
+
+// handleEvents streams the board to a page as server-sent events, for the
+// htmx sse extension. Each board event carries the categories list to swap
+// in out of band, narrowed by the tag query parameters as the page is.
+// Sharing is looked up afresh for every event, as it would be on a reload.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := rc.Flush(); err != nil {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	listener := s.events.subscribe()
+	defer s.events.unsubscribe(listener)
+
+	maxAge := time.NewTimer(eventsMaxAge)
+	defer maxAge.Stop()
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-maxAge.C:
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-listener:
+			if s.deactivated(auth.Handle) {
+				return
+			}
+			if err := s.writeBoardEvent(w, auth); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeBoardEvent sends the board as the viewer sees it now
+func (s *Server) writeBoardEvent(w io.Writer, auth AuthContext) error {
+	auth.hidden = nil
+	auth = s.withAccess(auth)
+
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		return err
+	}
+	catViews := make([]CategoryView, len(categories))
+	for i, c := range categories {
+		catViews[i] = NewCategoryView(c, false, auth)
+	}
+	var buf bytes.Buffer
+	if err := s.presentation.RenderBoardEvent(&buf, catViews, auth); err != nil {
+		return err
+	}
+
+	// An event's data is one line per data field
+	var event strings.Builder
+	event.WriteString("event: board\n")
+	for line := range strings.Lines(strings.TrimSpace(buf.String())) {
+		event.WriteString("data: " + strings.TrimRight(line, "\r\n") + "\n")
+	}
+	event.WriteString("\n")
+	_, err = io.WriteString(w, event.String())
+	return err
+}
+
+// statusWriter notes the status a handler answered with
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status, sw.wroteHeader = status, true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
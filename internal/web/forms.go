@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// formPatch applies a partial update from a submitted form. Fields the form
+// did not send are left untouched, while fields sent empty are cleared, so a
+// form can blank out a description without touching anything else.
+type formPatch struct {
+	values url.Values
+}
+
+// newFormPatch reads only the request body, so query parameters such as the
+// CSRF token never count as submitted fields.
+func newFormPatch(values url.Values) formPatch {
+	return formPatch{values: values}
+}
+
+func (p formPatch) has(key string) bool {
+	_, ok := p.values[key]
+	return ok
+}
+
+// last returns the final value sent for key; checkboxes are paired with a
+// hidden input of the same name, and the checked box comes last.
+func (p formPatch) last(key string) string {
+	vs := p.values[key]
+	return vs[len(vs)-1]
+}
+
+// Name sets dst if key was sent. Names may not be blank.
+func (p formPatch) Name(key string, dst *string) error {
+	if !p.has(key) {
+		return nil
+	}
+	name := strings.TrimSpace(p.last(key))
+	if name == "" {
+		return &FieldError{Field: key, Message: "cannot be empty"}
+	}
+	*dst = name
+	return nil
+}
+
+// Text sets dst if key was sent, including to the empty string.
+func (p formPatch) Text(key string, dst *string) {
+	if p.has(key) {
+		*dst = p.last(key)
+	}
+}
+
+// TextSince is Text with an optimistic concurrency check. Forms send the
+// value the editor started from as key+"_base"; if dst has since been changed
+// by someone else to something other than what was submitted, dst is left
+// alone and the submitted text is returned with conflict set, so the caller
+// can offer a merge instead of overwriting the other edit.
+func (p formPatch) TextSince(key string, dst *string) (mine string, conflict bool) {
+	if !p.has(key) {
+		return "", false
+	}
+	mine = p.last(key)
+	if p.has(key+"_base") && !sameText(p.last(key+"_base"), *dst) && !sameText(mine, *dst) {
+		return mine, true
+	}
+	*dst = mine
+	return "", false
+}
+
+// sameText compares submitted text with stored text, which is normalized
+func sameText(submitted, stored string) bool {
+	normalized, err := domain.NormalizeDescription(submitted)
+	return err == nil && normalized == stored
+}
+
+// Int sets dst if key was sent.
+func (p formPatch) Int(key string, dst *int) error {
+	if !p.has(key) {
+		return nil
+	}
+	val, err := strconv.Atoi(p.last(key))
+	if err != nil {
+		return &FieldError{Field: key, Message: "must be a whole number"}
+	}
+	*dst = val
+	return nil
+}
+
+// Float sets dst if key was sent, treating a blank field as zero.
+func (p formPatch) Float(key string, dst *float64) error {
+	if !p.has(key) {
+		return nil
+	}
+	value := strings.TrimSpace(p.last(key))
+	if value == "" {
+		*dst = 0
+		return nil
+	}
+	val, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return &FieldError{Field: key, Message: "must be a number"}
+	}
+	*dst = val
+	return nil
+}
+
+// Checkbox sets dst if key was sent. Forms render a hidden "off" input ahead
+// of the checkbox so that unchecking it is still a submitted value.
+func (p formPatch) Checkbox(key string, dst *bool) {
+	if p.has(key) {
+		*dst = p.last(key) == "on"
+	}
+}
@@ -0,0 +1,244 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *Server) handleGetCurrentGoals(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	http.Redirect(w, r, "/goals/"+domain.Quarter(s.clock.Now().In(auth.Location())), http.StatusSeeOther)
+}
+
+func (s *Server) handleGetGoals(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	quarter := r.PathValue("quarter")
+	start, err := domain.QuarterStart(quarter)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	objectives, err := s.store.GetObjectives(quarter)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	current := domain.Quarter(s.clock.Now().In(auth.Location()))
+	view := NewGoalsView(start, objectives, categories, current, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderGoals(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleAddObjective(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	quarter := r.PathValue("quarter")
+
+	if _, err := s.store.AddObjective(&domain.Objective{
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+		Quarter:     quarter,
+	}); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals/"+quarter)
+}
+
+func (s *Server) handleDeleteObjective(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteObjective(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+func (s *Server) handleAddKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kr := domain.KeyResult{ObjectiveID: r.PathValue("id")}
+	patch := newFormPatch(r.PostForm)
+	patch.Text("name", &kr.Name)
+	patch.Text("unit", &kr.Unit)
+	patch.Checkbox("from_tasks", &kr.FromTasks)
+	if err := patch.Float("target", &kr.Target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.AddKeyResult(&kr); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+// handleUpdateKeyResult records progress entered by hand, or switches how
+// the key result is measured
+func (s *Server) handleUpdateKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	kr, err := s.store.GetKeyResult(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch := newFormPatch(r.PostForm)
+	if err := patch.Name("name", &kr.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch.Text("unit", &kr.Unit)
+	patch.Checkbox("from_tasks", &kr.FromTasks)
+	if err := patch.Float("target", &kr.Target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := patch.Float("current", &kr.Current); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.UpdateKeyResult(kr); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+func (s *Server) handleDeleteKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteKeyResult(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+func (s *Server) handleLinkKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.LinkKeyResult(r.PathValue("id"), r.FormValue("task_id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+// handleLinkTaskKeyResult links from the task's side, choosing the key result
+func (s *Server) handleLinkTaskKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.LinkKeyResult(r.FormValue("key_result_id"), id); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/tasks/"+id+"/details")
+}
+
+func (s *Server) handleUnlinkKeyResult(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.UnlinkKeyResult(r.PathValue("id"), r.PathValue("task")); err != nil {
+		storeError(w, err)
+		return
+	}
+	goalsChanged(w, r, ctx, "/goals")
+}
+
+// goalsChanged finishes a change to objectives or key results. Links are
+// made from both the goals page and task details, so both are refreshed.
+func goalsChanged(w http.ResponseWriter, r *http.Request, ctx RequestContext, fallback string) {
+	if !ctx.IsHTMX {
+		redirectBack(w, r, fallback)
+		return
+	}
+	w.Header().Set("HX-Trigger", "goalsChanged, detailsChanged")
+}
+
+// taskKeyResults lists the key results a task counts towards, and those of
+// the current quarter it could be linked to. A failed lookup just leaves the
+// section empty.
+func (s *Server) taskKeyResults(auth AuthContext, taskID string) ([]TaskKeyResultView, []KeyResultOption) {
+	if !auth.IsAuthenticated {
+		return nil, nil
+	}
+	linked, err := s.store.GetTaskKeyResults(taskID)
+	if err != nil {
+		return nil, nil
+	}
+	objectives, err := s.store.GetObjectives(domain.Quarter(s.clock.Now().In(auth.Location())))
+	if err != nil {
+		objectives = nil
+	}
+	return NewTaskKeyResultViews(taskID, linked, objectives, auth)
+}
+
+// quarterLabel writes 2026-Q4 as Q4 2026
+func quarterLabel(quarter string) string {
+	year, q, _ := strings.Cut(quarter, "-")
+	return q + " " + year
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Metrics served to Grafana's JSON data source, one value per day in the
+// hook owner's timezone, over the categories the owner can see. Completion
+// and open tasks come from the daily snapshots, and today's from the board
+// as it is now.
+const (
+	grafanaHours      = "hours_per_day"
+	grafanaCompletion = "completion"
+	grafanaOpenTasks  = "open_tasks"
+)
+
+// grafanaMaxDays bounds the range of a query, since every day in it is
+// worked out from a snapshot
+const grafanaMaxDays = 3 * 366
+
+// grafanaMetric is a metric as the data source lists it: older versions of
+// the plugin read text, newer ones label
+type grafanaMetric struct {
+	Text  string `json:"text"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+var grafanaMetrics = []grafanaMetric{
+	{Text: "Hours logged per day", Label: "Hours logged per day", Value: grafanaHours},
+	{Text: "Board completion (%)", Label: "Board completion (%)", Value: grafanaCompletion},
+	{Text: "Open tasks", Label: "Open tasks", Value: grafanaOpenTasks},
+}
+
+// grafanaQuery is the part of a data source query compass reads
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a metric's values as [value, unix milliseconds] pairs
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// handleGrafanaHealth answers the data source's connection test
+func (s *Server) handleGrafanaHealth(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok || !s.hookAllows(w, t, "") {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "OK")
+}
+
+// handleGrafanaMetrics lists the metrics a panel can choose from
+func (s *Server) handleGrafanaMetrics(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok || !s.hookAllows(w, t, "") {
+		return
+	}
+	writeJSON(w, http.StatusOK, grafanaMetrics)
+}
+
+// handleGrafanaQuery answers a panel's query with a series per target, in
+// the order they were asked for
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok || !s.hookAllows(w, t, "") {
+		return
+	}
+
+	var q grafanaQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&q); err != nil {
+		http.Error(w, "Invalid query", http.StatusBadRequest)
+		return
+	}
+	from, to := q.Range.From, q.Range.To
+	if from.IsZero() || !to.After(from) {
+		http.Error(w, "The query needs a range from one time to a later one", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > grafanaMaxDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("The range can be at most %d days", grafanaMaxDays), http.StatusBadRequest)
+		return
+	}
+
+	loc := time.Local
+	if prefs, err := s.store.GetPreferences(t.UserID); err == nil {
+		loc = prefs.Location()
+	}
+	owner := s.accessOf(t.UserID)
+	now := s.clock.Now()
+	if to.After(now) {
+		to = now
+	}
+	var days []time.Time
+	start := from.In(loc)
+	for d := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc); d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	series := []grafanaSeries{}
+	for _, target := range q.Targets {
+		if target.Hide {
+			continue
+		}
+		var points [][2]float64
+		var err error
+		switch target.Target {
+		case grafanaHours:
+			points, err = s.grafanaHours(days, owner)
+		case grafanaCompletion, grafanaOpenTasks:
+			points, err = s.grafanaBoard(days, now, target.Target, owner)
+		default:
+			http.Error(w, fmt.Sprintf("Unknown metric %q", target.Target), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		series = append(series, grafanaSeries{Target: target.Target, Datapoints: points})
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+// grafanaHours totals the hours logged on each day, by everyone, in the
+// categories owner can see
+func (s *Server) grafanaHours(days []time.Time, owner AuthContext) ([][2]float64, error) {
+	points := [][2]float64{}
+	if len(days) == 0 {
+		return points, nil
+	}
+	logged, err := s.store.GetLoggedHours(days[0], days[len(days)-1].AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	i := 0
+	for _, d := range days {
+		next := d.AddDate(0, 0, 1)
+		var hours float64
+		for ; i < len(logged) && logged[i].At.Before(next); i++ {
+			if owner.CanSee(logged[i].CategoryID) {
+				hours += logged[i].Hours
+			}
+		}
+		points = append(points, [2]float64{hours, float64(d.UnixMilli())})
+	}
+	return points, nil
+}
+
+// grafanaBoard reads the completion or open task count of the categories
+// owner can see at the end of each day, from the snapshot in force then.
+// Days before the first snapshot have no value, and today's is the board as
+// it is now.
+func (s *Server) grafanaBoard(days []time.Time, now time.Time, metric string, owner AuthContext) ([][2]float64, error) {
+	points := [][2]float64{}
+	if len(days) == 0 {
+		return points, nil
+	}
+	snaps, err := s.store.GetSnapshots(days[0], days[len(days)-1].AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	i := -1
+	var stats *domain.BoardStats
+	for _, d := range days {
+		end := d.AddDate(0, 0, 1)
+		if now.Before(end) {
+			if stats, err = s.store.GetBoardStats("", owner.hiddenIDs(), now); err != nil {
+				return nil, err
+			}
+		} else {
+			// The last snapshot taken by the end of the day
+			day := end.Add(-time.Second).UTC().Format("2006-01-02")
+			moved := false
+			for i+1 < len(snaps) && snaps[i+1].Day <= day {
+				i++
+				moved = true
+			}
+			if i < 0 {
+				continue
+			}
+			if moved {
+				if stats, err = snapshotStats(snaps[i], owner); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		value := float64(stats.Completion)
+		if metric == grafanaOpenTasks {
+			value = float64(stats.OpenTasks)
+		}
+		points = append(points, [2]float64{value, float64(d.UnixMilli())})
+	}
+	return points, nil
+}
+
+// snapshotStats totals the categories owner can see in a snapshot's board,
+// as GetBoardStats totals the live one
+func snapshotStats(snap *domain.Snapshot, owner AuthContext) (*domain.BoardStats, error) {
+	var categories []*domain.Category
+	if err := json.Unmarshal(snap.Board, &categories); err != nil {
+		return nil, fmt.Errorf("snapshot of %s: %w", snap.Day, err)
+	}
+	stats := &domain.BoardStats{}
+	var completions []int
+	var estimates []float64
+	for _, c := range categories {
+		if !owner.CanSee(c.ID) {
+			continue
+		}
+		stats.Categories++
+		for _, t := range c.Tasks {
+			stats.Tasks++
+			if t.Completion < 100 {
+				stats.OpenTasks++
+			}
+			completions = append(completions, t.Completion)
+			estimates = append(estimates, t.Estimate)
+		}
+	}
+	stats.Completion = domain.WeightedCompletion(completions, estimates)
+	return stats, nil
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// requireAdmin is requireAuth for the things only admins manage
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) (AuthContext, bool) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return auth, false
+	}
+	if !auth.IsAdmin {
+		http.Error(w, "Only admins can manage groups and sharing", http.StatusForbidden)
+		return auth, false
+	}
+	return auth, true
+}
+
+func (s *Server) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+	if !auth.IsAdmin {
+		http.Error(w, "Only admins can manage groups", http.StatusForbidden)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	groups, err := s.store.GetGroups()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewGroupsView(groups, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderGroups(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAdmin(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if _, err := s.store.AddGroup(r.FormValue("name")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/groups")
+}
+
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAdmin(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := s.store.DeleteGroup(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/groups")
+}
+
+func (s *Server) handleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAdmin(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := s.store.AddGroupMember(r.PathValue("id"), r.FormValue("user")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/groups")
+}
+
+func (s *Server) handleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAdmin(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := s.store.RemoveGroupMember(r.PathValue("id"), r.FormValue("user")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/groups")
+}
+
+// handleShareCategoryAccess shares a category with the group or user the form
+// names. The first share hides the category from everyone else.
+func (s *Server) handleShareCategoryAccess(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireSharer(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	var err error
+	if group := r.FormValue("group"); group != "" {
+		err = s.store.ShareCategoryWithGroup(id, group)
+	} else {
+		err = s.store.ShareCategory(id, strings.TrimSpace(r.FormValue("user")))
+	}
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
+
+// handleUnshareCategoryAccess stops sharing a category with the group or
+// user the form names. Once it is shared with nobody, everyone sees it
+// again.
+func (s *Server) handleUnshareCategoryAccess(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireSharer(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	var err error
+	if group := r.FormValue("group"); group != "" {
+		err = s.store.UnshareCategoryWithGroup(id, group)
+	} else {
+		err = s.store.UnshareCategory(id, r.FormValue("user"))
+	}
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/categories/"+id+"/details")
+}
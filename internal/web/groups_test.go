@@ -0,0 +1,59 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestGroupSharedCategoriesStayHidden checks that the reports listing tasks
+// from across the board leave out categories shared with a group the
+// viewer isn't in
+func TestGroupSharedCategoriesStayHidden(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{Admins: []string{"ana"}})
+	payroll := ts.category("ana", "Payroll")
+	blocked := ts.task(payroll.ID, "Reconcile March")
+	blocked.Blocked, blocked.BlockedReason = true, "Waiting on the bank"
+	if _, err := ts.store.UpdateTask(blocked, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	ts.task(payroll.ID, "Pay the invoices")
+
+	group, err := ts.store.AddGroup("Finance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.AddGroupMember(group.ID, "bea"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.store.ShareCategoryWithGroup(payroll.ID, group.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ page, task string }{
+		{"/blocked", "Reconcile March"},
+		{"/suggest?minutes=240", "Pay the invoices"},
+	} {
+		t.Run(tc.page, func(t *testing.T) {
+			w := ts.do("bea", http.MethodGet, tc.page, nil)
+			expect(t, w, http.StatusOK)
+			if !strings.Contains(w.Body.String(), tc.task) {
+				t.Errorf("a member of the group can't see the task")
+			}
+
+			w = ts.do("cal", http.MethodGet, tc.page, nil)
+			expect(t, w, http.StatusOK)
+			if strings.Contains(w.Body.String(), tc.task) {
+				t.Errorf("the task shows to someone outside the group")
+			}
+		})
+	}
+
+	// Leaving the group hides it from the next request
+	if err := ts.store.RemoveGroupMember(group.ID, "bea"); err != nil {
+		t.Fatal(err)
+	}
+	if w := ts.do("bea", http.MethodGet, "/blocked", nil); strings.Contains(w.Body.String(), "Reconcile March") {
+		t.Errorf("the task still shows after leaving the group")
+	}
+}
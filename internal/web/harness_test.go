@@ -0,0 +1,145 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/store"
+)
+
+// testServer is a Server over a SQLite database in a temporary directory,
+// signed in to the way a trusted proxy would: each request names its user in
+// the Remote-User header and comes from loopback.
+type testServer struct {
+	t      *testing.T
+	server *Server
+	store  *store.SQLiteStore
+	clock  *domain.FakeClock
+	auth   AuthConfig
+}
+
+// newTestServer starts a server for t. opts may set anything but the store,
+// Auth, and Clock, which the harness provides.
+func newTestServer(t *testing.T, opts ServerOptions) *testServer {
+	t.Helper()
+
+	clock := domain.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "compass.db"), false, clock, domain.NewSeededIDs(1))
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	proxy := &HeaderAuth{Key: key}
+	opts.Auth = proxy.Auth()
+	opts.Clock = clock
+
+	server, err := NewServer(st, opts)
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	return &testServer{t: t, server: server, store: st, clock: clock, auth: opts.Auth}
+}
+
+// csrf is the CSRF token the server expects from handle
+func (ts *testServer) csrf(handle string) string {
+	return ts.auth.Verifier.(*headerVerifier).csrf(handle)
+}
+
+// request builds a request from handle, or from nobody if handle is empty,
+// carrying its CSRF token. A non-nil form is sent as the body.
+func (ts *testServer) request(handle, method, target string, form url.Values) *http.Request {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.RemoteAddr = "127.0.0.1:41234"
+	if handle != "" {
+		r.Header.Set("Remote-User", handle)
+		r.Header.Set(csrfHeader, ts.csrf(handle))
+	}
+	return r
+}
+
+// serve answers r
+func (ts *testServer) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ts.server.ServeHTTP(w, r)
+	return w
+}
+
+// do sends a plain browser request, as a form posts without scripts
+func (ts *testServer) do(handle, method, target string, form url.Values) *httptest.ResponseRecorder {
+	return ts.serve(ts.request(handle, method, target, form))
+}
+
+// htmx sends the request htmx would
+func (ts *testServer) htmx(handle, method, target string, form url.Values) *httptest.ResponseRecorder {
+	r := ts.request(handle, method, target, form)
+	r.Header.Set("HX-Request", "true")
+	r.Header.Set("HX-Current-URL", "http://example.com/")
+	return ts.serve(r)
+}
+
+// api sends a request to the JSON API, decoding a successful answer into v
+// when v is non-nil
+func (ts *testServer) api(handle, method, target string, form url.Values, v any) *httptest.ResponseRecorder {
+	r := ts.request(handle, method, target, form)
+	r.Header.Set("Accept", "application/json")
+	w := ts.serve(r)
+	if v != nil && w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			ts.t.Fatalf("%s %s: decoding %q: %v", method, target, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+// category adds a category as handle, failing the test if it can't
+func (ts *testServer) category(handle, name string) *domain.Category {
+	ts.t.Helper()
+	c, err := ts.store.AddCategory(name, handle)
+	if err != nil {
+		ts.t.Fatalf("adding category %q: %v", name, err)
+	}
+	return c
+}
+
+// task adds a task to categoryID, failing the test if it can't
+func (ts *testServer) task(categoryID, title string) *domain.Task {
+	ts.t.Helper()
+	task, err := ts.store.AddTask(categoryID, title)
+	if err != nil {
+		ts.t.Fatalf("adding task %q: %v", title, err)
+	}
+	return task
+}
+
+// expect fails the test unless w answered with status
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		body := w.Body.String()
+		if len(body) > 500 {
+			body = body[:500] + "…"
+		}
+		t.Fatalf("status %d, want %d: %s", w.Code, status, body)
+	}
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.sr.ht/~jakintosh/consent/pkg/client"
+	"git.sr.ht/~jakintosh/consent/pkg/tokens"
+)
+
+// headerTokenLifetime is how long the token standing in for a proxy's say-so
+// lasts. One is made for every request, so it only needs to outlive that.
+const headerTokenLifetime = time.Minute
+
+// Headers that name the signed-in user, in the order they are tried when
+// none is configured: Authelia's, then oauth2-proxy's
+var (
+	defaultUserHeaders  = []string{"Remote-User", "X-Auth-Request-Email"}
+	defaultNameHeaders  = []string{"Remote-Name", "X-Auth-Request-Preferred-Username"}
+	defaultEmailHeaders = []string{"Remote-Email", "X-Auth-Request-Email"}
+)
+
+// HeaderAuth signs users in by trusting a reverse proxy that authenticates
+// them itself, such as Authelia or oauth2-proxy in front of an LDAP
+// directory, and names the user in a request header. The header is only
+// believed from the proxy's own address; from anywhere else the request is
+// anonymous, so clients cannot name themselves. There are no session
+// cookies: every request carries the proxy's word, and CSRF tokens are
+// derived from the user's handle with Key.
+type HeaderAuth struct {
+	// Key signs the tokens handed to the rest of the server and keys CSRF
+	// tokens. Keep it across restarts so open pages keep working.
+	Key *ecdsa.PrivateKey
+
+	// TrustedProxies are the addresses the proxy connects from. Empty
+	// trusts only loopback, for a proxy on the same host.
+	TrustedProxies []netip.Prefix
+
+	// UserHeader names the user's handle. Optional; by default
+	// Remote-User, then X-Auth-Request-Email.
+	UserHeader string
+
+	// LoginURL and LogoutURL are the proxy's sign-in and sign-out pages.
+	// Optional; behind a proxy that guards every page, nobody reaches the
+	// app signed out.
+	LoginURL  string
+	LogoutURL string
+}
+
+// Auth returns the configuration for signing in with h. Users are provisioned
+// on their first request with the name and email the proxy sends.
+func (h *HeaderAuth) Auth() AuthConfig {
+	issuer, _ := tokens.InitServer(h.Key, "compass")
+	mac := hmac.New(sha256.New, []byte("compass header auth csrf"))
+	mac.Write(h.Key.D.Bytes())
+
+	loginURL := h.LoginURL
+	if loginURL == "" {
+		loginURL = "/"
+	}
+	return AuthConfig{
+		Verifier: &headerVerifier{
+			auth:   h,
+			issuer: issuer,
+			secret: mac.Sum(nil),
+		},
+		LoginURL:    loginURL,
+		LogoutURL:   h.LogoutURL,
+		Provisioner: h,
+	}
+}
+
+// Profile returns the display name and email the proxy sent for the user
+func (h *HeaderAuth) Profile(r *http.Request) (displayName, email string) {
+	return firstHeader(r, defaultNameHeaders), firstHeader(r, defaultEmailHeaders)
+}
+
+// handle returns the user the proxy vouches for, or "" if the request did
+// not come through it or names nobody
+func (h *HeaderAuth) handle(r *http.Request) string {
+	if !h.trusted(r) {
+		return ""
+	}
+	var handle string
+	if h.UserHeader != "" {
+		handle = strings.TrimSpace(r.Header.Get(h.UserHeader))
+	} else {
+		handle = firstHeader(r, defaultUserHeaders)
+	}
+	if len(handle) > 254 || strings.ContainsFunc(handle, unicode.IsControl) {
+		return ""
+	}
+	return handle
+}
+
+// trusted reports whether r came straight from the proxy
+func (h *HeaderAuth) trusted(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if len(h.TrustedProxies) == 0 {
+		return addr.IsLoopback()
+	}
+	for _, p := range h.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstHeader returns the first of names that r has a value for
+func firstHeader(r *http.Request, names []string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(r.Header.Get(name)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// headerVerifier is the client.Verifier for HeaderAuth
+type headerVerifier struct {
+	auth   *HeaderAuth
+	issuer tokens.Issuer
+	secret []byte
+}
+
+func (v *headerVerifier) VerifyAuthorization(w http.ResponseWriter, r *http.Request) (*client.AccessToken, error) {
+	handle := v.auth.handle(r)
+	if handle == "" {
+		return nil, client.ErrTokenAbsent
+	}
+	token, err := v.issuer.IssueAccessToken(handle, []string{"compass"}, headerTokenLifetime)
+	if err != nil {
+		return nil, client.ErrTokenInvalid
+	}
+	return token, nil
+}
+
+func (v *headerVerifier) VerifyAuthorizationGetCSRF(w http.ResponseWriter, r *http.Request) (*client.AccessToken, string, error) {
+	token, err := v.VerifyAuthorization(w, r)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, v.csrf(token.Subject()), nil
+}
+
+func (v *headerVerifier) VerifyAuthorizationCheckCSRF(w http.ResponseWriter, r *http.Request, csrf string) (*client.AccessToken, string, error) {
+	token, err := v.VerifyAuthorization(w, r)
+	if err != nil {
+		return nil, "", err
+	}
+	want := v.csrf(token.Subject())
+	if subtle.ConstantTimeCompare([]byte(csrf), []byte(want)) != 1 {
+		return nil, "", client.ErrCSRFInvalid
+	}
+	return token, want, nil
+}
+
+// csrf is the CSRF token for handle. It never changes, but only the server
+// can work it out, and a cross-site form cannot read it from the page.
+func (v *headerVerifier) csrf(handle string) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(handle))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
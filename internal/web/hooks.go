@@ -0,0 +1,476 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// hashHookToken is how hook tokens are stored and looked up
+func hashHookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// baseURL is the origin the request was made to, for URLs that leave the
+// browser
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// hookToken authorizes a hook request by the token in its URL. Unknown
+// tokens are simply not found, so revoked URLs look like they never
+// existed.
+func (s *Server) hookToken(w http.ResponseWriter, r *http.Request) (*domain.HookToken, bool) {
+	t, err := s.store.GetHookTokenByHash(hashHookToken(r.PathValue("token")))
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	if s.deactivated(t.UserID) {
+		http.Error(w, "This account has been deactivated", http.StatusForbidden)
+		return nil, false
+	}
+	return t, true
+}
+
+// hookReach is where what a hook token reaches is decided, for every hook
+// route. A token scoped to a category reaches only that category, and no
+// token reaches a category its owner cannot see. The empty ID stands for
+// the whole board, which only unscoped tokens reach.
+func (s *Server) hookReach(t *domain.HookToken) func(categoryID string) bool {
+	owner := s.accessOf(t.UserID)
+	return func(categoryID string) bool {
+		if t.CategoryID != "" && categoryID != t.CategoryID {
+			return false
+		}
+		return categoryID == "" || owner.CanSee(categoryID)
+	}
+}
+
+// hookAllows refuses a hook request for a category out of the token's
+// reach
+func (s *Server) hookAllows(w http.ResponseWriter, t *domain.HookToken, categoryID string) bool {
+	if s.hookReach(t)(categoryID) {
+		return true
+	}
+	if categoryID == "" {
+		http.Error(w, "This URL is limited to one category and cannot reach the whole board", http.StatusForbidden)
+	} else {
+		http.Error(w, "This URL cannot reach that category", http.StatusForbidden)
+	}
+	return false
+}
+
+// hookCategory finds the category a hook adds to by name, creating it if
+// needed, or fallback when no name is given. A scoped token adds to its
+// own category unless it names another, which is refused before anything
+// is created.
+func (s *Server) hookCategory(w http.ResponseWriter, t *domain.HookToken, name, fallback string) (*domain.Category, bool) {
+	name = strings.TrimSpace(name)
+	if t.CategoryID != "" {
+		cat, err := s.store.GetCategory(t.CategoryID)
+		if err != nil {
+			storeError(w, err)
+			return nil, false
+		}
+		if name != "" && !strings.EqualFold(name, cat.Name) {
+			http.Error(w, "This URL cannot reach that category", http.StatusForbidden)
+			return nil, false
+		}
+		return cat, s.hookAllows(w, t, cat.ID)
+	}
+
+	if name == "" {
+		name = fallback
+	}
+	cat, err := s.categoryNamed(name, t.UserID)
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	return cat, s.hookAllows(w, t, cat.ID)
+}
+
+// hookTask finds the task a hook names by ID or prefix, if it is in reach
+func (s *Server) hookTask(w http.ResponseWriter, t *domain.HookToken, ref string) (*domain.Task, bool) {
+	found, err := s.store.ResolveTaskRef(ref)
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	if !s.hookAllows(w, t, found.CategoryID) {
+		return nil, false
+	}
+	task, err := s.store.GetTask(found.ID)
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	return task, true
+}
+
+// hookFields reads a hook's input from either a form or a flat JSON object,
+// whichever the shortcut app finds easier to send
+func hookFields(w http.ResponseWriter, r *http.Request) (map[string]string, error) {
+	fields := map[string]string{}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+		var body map[string]any
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil {
+			return nil, err
+		}
+		for k, v := range body {
+			switch v := v.(type) {
+			case string:
+				fields[k] = v
+			case float64:
+				fields[k] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return fields, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	for k := range r.Form {
+		fields[k] = r.Form.Get(k)
+	}
+	return fields, nil
+}
+
+// hookTriggerLimit is how many events a trigger answers with at most
+const hookTriggerLimit = 50
+
+type hookAction struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Description string            `json:"description"`
+	Fields      map[string]string `json:"fields"`
+}
+
+// handleHookDocs describes what a hook URL can do, so it can be pasted into
+// a shortcut app and checked before wiring anything up
+func (s *Server) handleHookDocs(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+	base := baseURL(r) + "/hooks/" + r.PathValue("token")
+	note := "Send fields as a form or as a JSON object. Keep this URL secret; it acts as you."
+	var scope string
+	if t.CategoryID != "" {
+		cat, err := s.store.GetCategory(t.CategoryID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		scope = cat.Name
+		note += " It only reaches the " + cat.Name + " category: tasks are added there, other categories are refused, and Grafana, which charts the whole board, is not available."
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":     t.Name,
+		"user":     t.UserID,
+		"category": scope,
+		"note":     note,
+		"actions": []hookAction{
+			{
+				Method:      http.MethodPost,
+				URL:         base + "/capture",
+				Description: "Add a task to the " + inboxCategoryName + " category",
+				Fields: map[string]string{
+					"name":        "task name; defaults to the first line of description",
+					"description": "optional",
+				},
+			},
+			{
+				Method:      http.MethodPost,
+				URL:         base + "/clip",
+				Description: "Save a web page from a browser extension as a task in your reading category (" + domain.DefaultClipCategory + " unless changed in settings), linking to it; send a JSON object",
+				Fields: map[string]string{
+					"url":        "the page's address",
+					"title":      "optional; names the task, which is otherwise named after the address",
+					"selection":  "optional; text selected on the page, quoted in the description",
+					"screenshot": "optional; an image as a data URL, as captureVisibleTab gives it, attached to the task",
+					"category":   "optional; a category name to use instead, created if missing",
+				},
+			},
+			{
+				Method:      http.MethodPost,
+				URL:         base + "/work-logs",
+				Description: "Log hours on a task, leaving its completion as it is",
+				Fields: map[string]string{
+					"task":        "task ID, or its first 8 or more characters",
+					"hours":       "hours worked, e.g. 1.5",
+					"description": "optional",
+				},
+			},
+			{
+				Method:      http.MethodPost,
+				URL:         base + "/progress",
+				Description: "Set a task's completion; tasks with subtasks follow them instead",
+				Fields: map[string]string{
+					"task":       "task ID, or its first 8 or more characters",
+					"completion": "0 to 100; defaults to 100, marking it done",
+				},
+			},
+			{
+				Method:      http.MethodPost,
+				URL:         base + "/commits",
+				Description: "Report a commit from a git hook; commits are grouped into work sessions to confirm at " + baseURL(r) + "/work-sessions",
+				Fields: map[string]string{
+					"repo":      "repository name",
+					"branch":    "optional; a task ID's first 8 characters in it links the commit to that task",
+					"message":   "commit message; a [[task:...]] reference in it links the commit to that task",
+					"timestamp": "optional; RFC 3339 or unix seconds, as git log formats %cI or %ct; defaults to now",
+					"sha":       "optional; a commit reported again with the same sha is refused",
+				},
+			},
+			{
+				Method:      "PROPFIND",
+				URL:         baseURL(r) + "/dav/" + r.PathValue("token") + "/",
+				Description: "Browse the board read-only over WebDAV, a folder per category and a markdown file per task",
+			},
+			{
+				Method:      http.MethodPost,
+				URL:         baseURL(r) + "/grafana/" + r.PathValue("token") + "/query",
+				Description: "Chart hours logged per day, board completion, and open tasks in Grafana; add " + baseURL(r) + "/grafana/" + r.PathValue("token") + " as a JSON data source",
+			},
+		},
+		"triggers": []hookAction{
+			{
+				Method:      http.MethodGet,
+				URL:         base + "/triggers/new-tasks",
+				Description: "Tasks added to the board, newest first, each with a unique id to deduplicate by",
+				Fields: map[string]string{
+					"since": "optional; the largest seq already seen, to get only what is newer",
+				},
+			},
+			{
+				Method:      http.MethodGet,
+				URL:         base + "/triggers/completed-tasks",
+				Description: "Tasks reaching 100%, newest first; a task reopened and finished again appears again",
+				Fields: map[string]string{
+					"since": "optional; the largest seq already seen, to get only what is newer",
+				},
+			},
+		},
+	})
+}
+
+// hookEvent is a task event as automation platforms poll for it: with an
+// id that is unique to it, and a link to the task
+type hookEvent struct {
+	ID string `json:"id"`
+	*domain.TaskEvent
+	URL string `json:"url"`
+}
+
+// handleHookTrigger answers a poll for task events of a kind, newest first,
+// as Zapier and similar platforms expect. Without since it gives the
+// latest, which is what they sample when a trigger is set up.
+func (s *Server) handleHookTrigger(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := s.hookToken(w, r)
+		if !ok {
+			return
+		}
+
+		var since int64
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+				http.Error(w, "since must be an event's seq", http.StatusBadRequest)
+				return
+			}
+		}
+		events, err := s.store.GetTaskEvents(kind, since, hookTriggerLimit)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+
+		reach := s.hookReach(t)
+		items := []hookEvent{}
+		for _, e := range slices.Backward(events) {
+			if !reach(e.CategoryID) {
+				continue
+			}
+			items = append(items, hookEvent{
+				ID:        strconv.FormatInt(e.Seq, 10),
+				TaskEvent: e,
+				URL:       baseURL(r) + "/tasks/" + e.TaskID + "/details",
+			})
+		}
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+func (s *Server) handleHookCapture(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+	fields, err := hookFields(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cat, ok := s.hookCategory(w, t, "", inboxCategoryName)
+	if !ok {
+		return
+	}
+	task, err := s.captureInto(cat.ID, fields["name"], fields["description"], t.UserID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, task)
+}
+
+func (s *Server) handleHookWorkLog(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+	fields, err := hookFields(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	hours, err := strconv.ParseFloat(fields["hours"], 64)
+	if err != nil || hours <= 0 {
+		http.Error(w, "Invalid hours value", http.StatusBadRequest)
+		return
+	}
+	task, ok := s.hookTask(w, t, fields["task"])
+	if !ok {
+		return
+	}
+
+	workLog, err := s.store.AddWorkLogForTask(task.ID, hours, fields["description"], task.Completion, nil, t.UserID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.notifyWorkLogged(AuthContext{Handle: t.UserID}, workLog)
+	writeJSON(w, http.StatusCreated, workLog)
+}
+
+func (s *Server) handleHookProgress(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+	fields, err := hookFields(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	completion := 100
+	if v := fields["completion"]; v != "" {
+		if completion, err = strconv.Atoi(v); err != nil || completion < 0 || completion > 100 {
+			http.Error(w, "Invalid completion value", http.StatusBadRequest)
+			return
+		}
+	}
+	task, ok := s.hookTask(w, t, fields["task"])
+	if !ok {
+		return
+	}
+	if len(task.Subtasks) > 0 {
+		http.Error(w, "The task's completion follows its subtasks", http.StatusConflict)
+		return
+	}
+
+	task.Completion = completion
+	if task, err = s.store.UpdateTask(task, t.UserID); err != nil {
+		storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) handleCreateHook(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = "Shortcut"
+	}
+	categoryID := r.FormValue("category")
+	if categoryID != "" && !auth.CanSee(categoryID) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	hook, err := s.store.AddHookToken(&domain.HookToken{
+		UserID:     auth.Handle,
+		Name:       name,
+		CategoryID: categoryID,
+		TokenHash:  hashHookToken(token),
+	})
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	reach := "the board"
+	if hook.CategoryID != "" {
+		reach = "one category"
+	}
+	s.accountNotice(auth.Handle, "A shortcut URL was created for your account",
+		fmt.Sprintf("A shortcut URL named %q was created. Apps given it can add tasks, log work, and read %s as you without signing in.", hook.Name, reach))
+
+	base := baseURL(r) + "/hooks/" + token
+	s.renderSettings(w, r, auth, &NewHookView{
+		Name:       hook.Name,
+		DocsURL:    base,
+		CaptureURL: base + "/capture",
+		WorkLogURL: base + "/work-logs",
+		FilesURL:   baseURL(r) + "/dav/" + token + "/",
+		GrafanaURL: baseURL(r) + "/grafana/" + token,
+		CommitsURL: base + "/commits",
+	})
+}
+
+func (s *Server) handleDeleteHook(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteHookToken(auth.Handle, r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// hookURL adds a hook token for handle, scoped to categoryID unless it is
+// empty, and returns the secret part of its URLs
+func (ts *testServer) hookURL(handle, categoryID string) string {
+	ts.t.Helper()
+	token := "token-" + handle + "-" + categoryID
+	if _, err := ts.store.AddHookToken(&domain.HookToken{
+		UserID:     handle,
+		Name:       "test",
+		CategoryID: categoryID,
+		TokenHash:  hashHookToken(token),
+	}); err != nil {
+		ts.t.Fatalf("adding hook token: %v", err)
+	}
+	return token
+}
+
+// TestScopedHookStaysInItsCategory checks that a token scoped to one
+// category is refused everywhere else a hook reaches
+func TestScopedHookStaysInItsCategory(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	garden := ts.category("ana", "Garden")
+	kitchen := ts.category("ana", "Kitchen")
+	inGarden := ts.task(garden.ID, "Turn the compost")
+	inKitchen := ts.task(kitchen.ID, "Descale the kettle")
+	token := ts.hookURL("ana", garden.ID)
+
+	refused := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		status int
+	}{
+		{"work log on another category's task", http.MethodPost, "/hooks/" + token + "/work-logs", url.Values{"task": {inKitchen.ID}, "hours": {"1"}}, http.StatusForbidden},
+		{"progress on another category's task", http.MethodPost, "/hooks/" + token + "/progress", url.Values{"task": {inKitchen.ID}, "completion": {"50"}}, http.StatusForbidden},
+		{"grafana health", http.MethodGet, "/grafana/" + token, nil, http.StatusForbidden},
+		{"grafana metrics", http.MethodPost, "/grafana/" + token + "/metrics", nil, http.StatusForbidden},
+		{"grafana query", http.MethodPost, "/grafana/" + token + "/query", nil, http.StatusForbidden},
+		{"dav folder of another category", http.MethodGet, "/dav/" + token + "/Kitchen/", nil, http.StatusNotFound},
+		{"dav file of another category", http.MethodGet, "/dav/" + token + "/Kitchen/README.md", nil, http.StatusNotFound},
+		{"dav listing of another category", "PROPFIND", "/dav/" + token + "/Kitchen", nil, http.StatusNotFound},
+	}
+	for _, tc := range refused {
+		t.Run(tc.name, func(t *testing.T) {
+			expect(t, ts.do("", tc.method, tc.target, tc.form), tc.status)
+		})
+	}
+
+	t.Run("clip into another category", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/hooks/"+token+"/clip", strings.NewReader(`{"url": "https://example.com/bread", "title": "Bread", "category": "Kitchen"}`))
+		r.Header.Set("Content-Type", "application/json")
+		expect(t, ts.serve(r), http.StatusForbidden)
+	})
+	t.Run("commit naming another category's task", func(t *testing.T) {
+		var commit domain.CommitEvent
+		form := url.Values{"repo": {"kettle"}, "message": {"Descale " + domain.ShortTaskRef(inKitchen.ID)}}
+		expect(t, ts.api("", http.MethodPost, "/hooks/"+token+"/commits", form, &commit), http.StatusCreated)
+		if commit.TaskID != "" {
+			t.Errorf("the commit was linked to the other category's task")
+		}
+	})
+
+	logs, err := ts.store.GetWorkLogsForTask(inKitchen.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 0 {
+		t.Errorf("a refused hook logged %d entries on the other category's task", len(logs))
+	}
+
+	t.Run("its own category", func(t *testing.T) {
+		expect(t, ts.do("", http.MethodPost, "/hooks/"+token+"/work-logs", url.Values{"task": {inGarden.ID}, "hours": {"1"}}), http.StatusCreated)
+
+		var captured domain.Task
+		expect(t, ts.api("", http.MethodPost, "/hooks/"+token+"/capture", url.Values{"name": {"Buy seed"}}, &captured), http.StatusCreated)
+		if captured.CategoryID != garden.ID {
+			t.Errorf("a capture went outside the token's category")
+		}
+
+		w := ts.do("", http.MethodGet, "/dav/"+token+"/", nil)
+		expect(t, w, http.StatusOK)
+		if !strings.Contains(w.Body.String(), "Garden/") || strings.Contains(w.Body.String(), "Kitchen") {
+			t.Errorf("the dav root should list only the token's category: %q", w.Body.String())
+		}
+	})
+}
+
+// TestUnscopedHookReachesOnlyWhatItsOwnerSees checks that a whole-board
+// token still can't reach a private category its owner isn't shared
+func TestUnscopedHookReachesOnlyWhatItsOwnerSees(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{PrivateBoards: true})
+	mine := ts.category("ana", "Garden")
+	theirs := ts.category("bea", "Diary")
+	inTheirs := ts.task(theirs.ID, "Write it up")
+	ts.task(mine.ID, "Turn the compost")
+	token := ts.hookURL("ana", "")
+
+	expect(t, ts.do("", http.MethodPost, "/hooks/"+token+"/work-logs", url.Values{"task": {inTheirs.ID}, "hours": {"1"}}), http.StatusForbidden)
+	expect(t, ts.do("", http.MethodGet, "/dav/"+token+"/Diary/", nil), http.StatusNotFound)
+	expect(t, ts.do("", http.MethodGet, "/grafana/"+token, nil), http.StatusOK)
+
+	w := ts.do("", http.MethodGet, "/dav/"+token+"/", nil)
+	expect(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), "Diary") {
+		t.Errorf("the dav root lists a category the token's owner can't see")
+	}
+}
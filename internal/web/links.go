@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/preview"
+)
+
+func (s *Server) handleAddLink(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	link, err := s.store.AddLink(&domain.Link{
+		TaskID:  r.PathValue("id"),
+		Kind:    r.FormValue("kind"),
+		URL:     r.FormValue("url"),
+		AddedBy: auth.Handle,
+	})
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	s.fetchPreview(link)
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+link.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+// fetchPreview fetches a new link's preview now rather than waiting for the
+// refresh job; until it lands the link shows its host name
+func (s *Server) fetchPreview(link *domain.Link) {
+	if s.previews == nil || link.Title != "" {
+		return
+	}
+	go func() {
+		if err := preview.Refresh(context.Background(), s.store, s.previews, s.clock, link.URL); err != nil {
+			log.Printf("link preview: %v", err)
+		}
+	}()
+}
+
+func (s *Server) handleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	link, err := s.store.DeleteLink(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+link.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+// handleGetLinkFavicon serves the cached favicon of a link's site, so pages
+// never load images from the linked sites directly.
+func (s *Server) handleGetLinkFavicon(w http.ResponseWriter, r *http.Request) {
+	link, err := s.store.GetLink(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if !s.canViewTask(w, r, link.TaskID) {
+		return
+	}
+
+	p, err := s.store.GetLinkPreview(link.URL)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if len(p.Favicon) == 0 || !strings.HasPrefix(p.FaviconType, "image/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", p.FaviconType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(p.Favicon)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Write(p.Favicon)
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// locales are the languages dates and numbers can be written for, the
+// first being the fallback. The interface itself is in English, so month
+// names are too; what changes is the order of day and month, the clock,
+// and the decimal separator.
+var locales = []language.Tag{
+	language.AmericanEnglish,
+	language.BritishEnglish,
+	language.Danish,
+	language.Dutch,
+	language.Finnish,
+	language.French,
+	language.German,
+	language.Italian,
+	language.Polish,
+	language.Portuguese,
+	language.Spanish,
+	language.Swedish,
+}
+
+var localeMatcher = language.NewMatcher(locales)
+
+// matchLanguage picks the locale closest to the tags given, most preferred
+// first
+func matchLanguage(tags ...language.Tag) language.Tag {
+	_, i, _ := localeMatcher.Match(tags...)
+	return locales[i]
+}
+
+// dateStyle is how one locale writes dates and times, as time layouts
+type dateStyle struct {
+	Date     string // a day with its year
+	DateTime string // a moment, without the year
+	Time     string
+	Short    string // a day, as on a chart's axis
+	Long     string // a day, as in a heading
+}
+
+var (
+	monthFirst = dateStyle{Date: "Jan 2, 2006", DateTime: "Jan 2, 3:04 PM", Time: "3:04 PM", Short: "Jan 2", Long: "January 2"}
+	dayFirst   = dateStyle{Date: "2 Jan 2006", DateTime: "2 Jan, 15:04", Time: "15:04", Short: "2 Jan", Long: "2 January"}
+)
+
+// Locale is how the viewer reads dates and numbers: in which language's
+// conventions, and in which zone
+type Locale struct {
+	Language language.Tag
+	Location *time.Location // nil means the server's zone
+}
+
+type localeKey struct{}
+
+// withLocale works out the request's locale from the lang and tz cookies,
+// then the Accept-Language header, so that even signed-out pages read
+// naturally. A signed-in user's preferences are applied over it, with the
+// rest of their settings, when their AuthContext is built.
+func (s *Server) withLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), localeKey{}, negotiateLocale(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// negotiateLocale reads the locale a request asks for. The tz cookie is set
+// by app.js from the browser's own zone.
+func negotiateLocale(r *http.Request) Locale {
+	var wanted []language.Tag
+	if c, err := r.Cookie("lang"); err == nil {
+		if tag, err := language.Parse(c.Value); err == nil {
+			wanted = append(wanted, tag)
+		}
+	}
+	if accepted, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil {
+		wanted = append(wanted, accepted...)
+	}
+
+	locale := Locale{Language: matchLanguage(wanted...)}
+	if c, err := r.Cookie("tz"); err == nil && c.Value != "" {
+		if loc, err := time.LoadLocation(c.Value); err == nil {
+			locale.Location = loc
+		}
+	}
+	return locale
+}
+
+// setLanguageCookie remembers the language a user chose in this browser,
+// so that pages they see signed out are written the same way. Choosing to
+// follow the browser clears it.
+func setLanguageCookie(w http.ResponseWriter, r *http.Request, lang string) {
+	cookie := &http.Cookie{
+		Name:     "lang",
+		Value:    lang,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if lang == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// localeOf is the locale withLocale found for a request, or the fallback
+// for one that did not pass through it
+func localeOf(r *http.Request) Locale {
+	if locale, ok := r.Context().Value(localeKey{}).(Locale); ok {
+		return locale
+	}
+	return Locale{Language: locales[0]}
+}
+
+// language is the locale the viewer's dates and numbers are written for
+func (a AuthContext) language() language.Tag {
+	if a.lang == language.Und {
+		return locales[0]
+	}
+	return a.lang
+}
+
+func (a AuthContext) dateStyle() dateStyle {
+	if a.language() == language.AmericanEnglish {
+		return monthFirst
+	}
+	return dayFirst
+}
+
+// FormatDate writes a day with its year in the viewer's zone and style
+func (a AuthContext) FormatDate(t time.Time) string {
+	return t.In(a.Location()).Format(a.dateStyle().Date)
+}
+
+// FormatDateTime writes a moment in the viewer's zone and style
+func (a AuthContext) FormatDateTime(t time.Time) string {
+	return t.In(a.Location()).Format(a.dateStyle().DateTime)
+}
+
+// FormatTime writes a time of day in the viewer's zone and style
+func (a AuthContext) FormatTime(t time.Time) string {
+	return t.In(a.Location()).Format(a.dateStyle().Time)
+}
+
+// FormatShortDate writes a day briefly, as on a chart's axis. The day is
+// written as it is, since days are already in the viewer's zone.
+func (a AuthContext) FormatShortDate(day time.Time) string {
+	return day.Format(a.dateStyle().Short)
+}
+
+// FormatLongDate writes a day in full, as in a heading
+func (a AuthContext) FormatLongDate(day time.Time) string {
+	return day.Format(a.dateStyle().Long)
+}
+
+// FormatHours writes hours for reading, in the viewer's conventions. Form
+// values are written with formatHours instead, which the browser and the
+// server both parse.
+func (a AuthContext) FormatHours(h float64) string {
+	return a.printer().Sprint(number.Decimal(h, number.MaxFractionDigits(2)))
+}
+
+// printer writes numbers in the viewer's conventions
+func (a AuthContext) printer() *message.Printer {
+	return message.NewPrinter(a.language())
+}
+
+// withLocale sets the conventions and zone the context writes dates in
+func (a AuthContext) withLocale(locale Locale) AuthContext {
+	a.lang = locale.Language
+	a.location = locale.Location
+	return a
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/consent/pkg/client"
+)
+
+const (
+	loginCookie  = "compass_login"
+	loginTimeout = 10 * time.Minute // how long a sign-in may take at the consent server
+)
+
+// TokenExchanger trades an authorization code for session tokens and sets
+// them as cookies; *client.Client is one
+type TokenExchanger interface {
+	RefreshTokens(code string) (*client.AccessToken, *client.RefreshToken, bool)
+	SetTokenCookies(w http.ResponseWriter, accessToken *client.AccessToken, refreshToken *client.RefreshToken)
+}
+
+// ConsentLogin signs users in through a consent server. Starting a sign-in
+// remembers, in a short-lived cookie, the page the user was on; the callback
+// only accepts a code in a browser that started a sign-in in the last
+// loginTimeout, then returns to that page.
+//
+// This does not protect against login CSRF. The consent server sends users
+// back with nothing but the code, and the cookie holds only the return
+// path, so nothing ties a code to the sign-in it completes: a page that
+// sends the victim's browser to /auth/login once can then hand it a code of
+// the attacker's own. Once the consent server can echo a value back, the
+// code should be tied to a nonce kept on this side.
+type ConsentLogin struct {
+	ServerURL string         // the consent server, e.g. https://consent.example.com
+	Service   string         // the name this app is registered under, its client ID
+	Tokens    TokenExchanger // redeems the code the server sends back
+
+	// RedirectURL is the callback as registered with the consent server.
+	// Optional; consent servers that take it from the registration ignore
+	// it.
+	RedirectURL string
+}
+
+// Auth returns the configuration for signing in with l: the login button
+// starts at /auth/login and the server sends users back to /auth/callback.
+func (l *ConsentLogin) Auth(verifier client.Verifier) AuthConfig {
+	return AuthConfig{
+		Verifier:  verifier,
+		LoginURL:  "/auth/login",
+		LogoutURL: strings.TrimSuffix(l.ServerURL, "/") + "/logout",
+		Routes: map[string]http.HandlerFunc{
+			"GET /auth/login":    l.handleStart,
+			"GET /auth/callback": l.handleCallback,
+		},
+		Renewer: l.Tokens,
+	}
+}
+
+// AuthorizeURL is the consent server's login page for this app
+func (l *ConsentLogin) AuthorizeURL() string {
+	q := url.Values{}
+	q.Set("service", l.Service)
+	q.Set("client_id", l.Service)
+	if l.RedirectURL != "" {
+		q.Set("redirect_uri", l.RedirectURL)
+	}
+	return strings.TrimSuffix(l.ServerURL, "/") + "/login?" + q.Encode()
+}
+
+func (l *ConsentLogin) handleStart(w http.ResponseWriter, r *http.Request) {
+	returnTo := r.URL.Query().Get("return_to")
+	if !localPath(returnTo) {
+		returnTo = "/"
+	}
+
+	// Lax, not Strict, so the cookie comes along on the redirect back from
+	// the consent server
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(returnTo)),
+		Path:     "/auth/",
+		MaxAge:   int(loginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, l.AuthorizeURL(), http.StatusSeeOther)
+}
+
+// handleCallback redeems the code the consent server sent back, in a
+// browser that has recently started a sign-in; it has no way to tell whose
+// sign-in the code is from. On failure no cookies are set, which the
+// sign-in guard counts against the client.
+func (l *ConsentLogin) handleCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		http.Error(w, "This sign-in was not started here, or took too long. Please sign in again.", http.StatusBadRequest)
+		return
+	}
+	returnTo, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || !localPath(string(returnTo)) {
+		http.Error(w, "Malformed sign-in cookie. Please sign in again.", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	code := q.Get("auth_code")
+	if code == "" {
+		code = q.Get("code")
+	}
+	if code == "" {
+		http.Error(w, "The consent server did not send an authorization code.", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, refreshToken, ok := l.Tokens.RefreshTokens(code)
+	if !ok {
+		http.Error(w, "Sign-in failed. Please try again.", http.StatusUnauthorized)
+		return
+	}
+	l.Tokens.SetTokenCookies(w, accessToken, refreshToken)
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/auth/", MaxAge: -1})
+	http.Redirect(w, r, string(returnTo), http.StatusSeeOther)
+}
+
+// localPath reports whether p is a path on this site, safe to redirect to
+func localPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.HasPrefix(p, "/\\")
+}
+
+// loginRedirect sends someone who must sign in to the login page, asking to
+// come back to the page they wanted
+func loginRedirect(w http.ResponseWriter, r *http.Request, auth AuthContext) {
+	target := auth.LoginURL
+	if strings.HasPrefix(target, "/") && r.Method == http.MethodGet {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + "return_to=" + url.QueryEscape(r.URL.RequestURI())
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
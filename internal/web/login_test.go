@@ -0,0 +1,58 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jakintosh/consent/pkg/client"
+)
+
+// fakeExchanger accepts one code and sets a session cookie for it
+type fakeExchanger struct{ code string }
+
+func (f *fakeExchanger) RefreshTokens(code string) (*client.AccessToken, *client.RefreshToken, bool) {
+	return nil, nil, code == f.code
+}
+
+func (f *fakeExchanger) SetTokenCookies(w http.ResponseWriter, _ *client.AccessToken, _ *client.RefreshToken) {
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: "signed-in"})
+}
+
+// TestCallbackNeedsTheLoginCookie checks that a code is only redeemed in a
+// browser that has started a sign-in, and returns it to where it started
+func TestCallbackNeedsTheLoginCookie(t *testing.T) {
+	l := &ConsentLogin{ServerURL: "https://consent.example", Service: "compass", Tokens: &fakeExchanger{code: "good"}}
+
+	w := httptest.NewRecorder()
+	l.handleStart(w, httptest.NewRequest(http.MethodGet, "/auth/login?return_to=/tasks/1/details", nil))
+	expect(t, w, http.StatusSeeOther)
+	if loc := w.Header().Get("Location"); !strings.HasPrefix(loc, "https://consent.example/login?") {
+		t.Fatalf("sent to %q", loc)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != loginCookie {
+		t.Fatalf("the login cookie wasn't set: %v", cookies)
+	}
+
+	callback := func(code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/callback?auth_code="+code, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		l.handleCallback(w, r)
+		return w
+	}
+
+	expect(t, callback("good", nil), http.StatusBadRequest)
+	expect(t, callback("good", &http.Cookie{Name: loginCookie, Value: "not base64!"}), http.StatusBadRequest)
+	expect(t, callback("bad", cookies[0]), http.StatusUnauthorized)
+
+	w = callback("good", cookies[0])
+	expect(t, w, http.StatusSeeOther)
+	if loc := w.Header().Get("Location"); loc != "/tasks/1/details" {
+		t.Errorf("returned to %q, want where the sign-in started", loc)
+	}
+}
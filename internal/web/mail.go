@@ -0,0 +1,73 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/mail"
+)
+
+// handleTestEmail emails the user at the address in their settings, so
+// they can check it without waiting for something to happen. It is sent at
+// once rather than queued, so a failure can be shown.
+func (s *Server) handleTestEmail(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	if s.mail == nil {
+		http.Error(w, "Email is not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	prefs, err := s.store.GetPreferences(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if prefs.Email == "" {
+		http.Error(w, "Save an email address first", http.StatusBadRequest)
+		return
+	}
+	err = s.mail.Send(r.Context(), prefs.Email, "notification", mail.Message{
+		Title:    "Email notifications are working",
+		Body:     "This is how Compass will tell you about activity and reminders.",
+		Link:     s.mail.Link("/settings"),
+		Settings: s.mail.Link("/settings"),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("The test could not be sent: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Sent to %s.", prefs.Email)
+}
+
+// accountNotice emails a user about a change to their account they should
+// know about, such as a new way to act as them, if they have an address.
+// Failures are logged; the change itself has already happened.
+func (s *Server) accountNotice(userID, title, body string) {
+	if s.mail == nil {
+		return
+	}
+	prefs, err := s.store.GetPreferences(userID)
+	if err != nil || prefs.Email == "" {
+		return
+	}
+	err = s.mail.Queue(prefs.Email, "account", mail.Message{
+		Title:    title,
+		Body:     body,
+		Settings: s.mail.Link("/settings"),
+	})
+	if err != nil {
+		log.Printf("account notice to %s: %v", userID, err)
+	}
+}
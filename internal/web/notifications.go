@@ -0,0 +1,85 @@
+package web
+
+import "net/http"
+
+// inboxLimit is how many notifications the inbox shows
+const inboxLimit = 50
+
+func (s *Server) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	notifications, err := s.store.GetNotifications(auth.Handle, inboxLimit)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewNotificationsView(notifications, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderNotifications(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleGetNotificationBell renders the header bell, which polls this to
+// keep its unread count current
+func (s *Server) handleGetNotificationBell(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := s.presentation.RenderNotificationBell(w, auth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.MarkNotificationRead(auth.Handle, r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/notifications")
+		return
+	}
+	w.Header().Set("HX-Trigger", "notificationsChanged")
+}
+
+func (s *Server) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.MarkAllNotificationsRead(auth.Handle); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/notifications")
+		return
+	}
+	w.Header().Set("HX-Trigger", "notificationsChanged")
+}
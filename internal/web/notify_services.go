@@ -0,0 +1,150 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+)
+
+// ntfyTopic is what ntfy accepts as a topic name
+var ntfyTopic = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+// notifyService reads which push service a request is about, answering
+// 404 for any other
+func notifyService(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch service := r.PathValue("service"); service {
+	case domain.ServiceNtfy, domain.ServiceGotify:
+		return service, true
+	}
+	http.Error(w, "Not found", http.StatusNotFound)
+	return "", false
+}
+
+// handleSaveNotifyService sets where the user's notifications go on ntfy or
+// Gotify. Leaving the token empty keeps the one already saved.
+func (s *Server) handleSaveNotifyService(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	service, ok := notifyService(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	svc := &domain.NotifyService{
+		UserID:  auth.Handle,
+		Service: service,
+		URL:     strings.TrimSpace(r.FormValue("url")),
+		Topic:   strings.TrimSpace(r.FormValue("topic")),
+		Token:   strings.TrimSpace(r.FormValue("token")),
+		AppURL:  baseURL(r),
+	}
+	if svc.Token == "" {
+		if old, err := s.store.GetNotifyService(auth.Handle, service); err == nil {
+			svc.Token = old.Token
+		}
+	}
+	if svc.URL == "" && service == domain.ServiceNtfy {
+		svc.URL = notify.DefaultNtfyServer
+	}
+	if u, err := url.Parse(svc.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		http.Error(w, "The server's address must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case service == domain.ServiceNtfy && !ntfyTopic.MatchString(svc.Topic):
+		http.Error(w, "The topic must be up to 64 letters, digits, dashes, and underscores", http.StatusBadRequest)
+		return
+	case service == domain.ServiceGotify && svc.Token == "":
+		http.Error(w, "Gotify needs the token of an application created on the server", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SaveNotifyService(svc); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
+
+// handleDeleteNotifyService stops sending the user's notifications to ntfy
+// or Gotify
+func (s *Server) handleDeleteNotifyService(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	service, ok := notifyService(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteNotifyService(auth.Handle, service); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
+
+// handleTestNotifyService sends a notification to the user's ntfy topic or
+// Gotify server, so they can check the setup without waiting for something
+// to happen. A failure quotes what the service said.
+func (s *Server) handleTestNotifyService(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	service, ok := notifyService(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if _, err := s.store.GetNotifyService(auth.Handle, service); err != nil {
+		storeError(w, err)
+		return
+	}
+	channel := s.notifier.Channel(service)
+	if channel == nil {
+		http.Error(w, "Notifications are not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+	err := channel.Send(r.Context(), auth.Handle, notify.Event{
+		Kind:  notify.KindActivity,
+		Title: "Notifications are working",
+		Body:  "This is how Compass will tell you about activity and reminders.",
+		URL:   "/settings",
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("The test could not be sent: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "Sent. It should arrive in a moment.")
+}
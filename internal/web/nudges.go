@@ -0,0 +1,82 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *Server) handleAddNudge(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	weekday, err := strconv.Atoi(r.FormValue("weekday"))
+	if err != nil {
+		http.Error(w, "weekday must be a whole number", http.StatusBadRequest)
+		return
+	}
+	at, err := time.Parse("15:04", r.FormValue("time"))
+	if err != nil {
+		http.Error(w, "time must be written as HH:MM", http.StatusBadRequest)
+		return
+	}
+	nudge := &domain.Nudge{
+		TaskID:  r.PathValue("id"),
+		UserID:  auth.Handle,
+		Weekday: weekday,
+		Hour:    at.Hour(),
+		Minute:  at.Minute(),
+	}
+	if err := nudge.Validate(); err != nil {
+		storeError(w, err)
+		return
+	}
+	nudge.NextAt = nudge.Next(s.clock.Now(), auth.Location())
+
+	nudge, err = s.store.AddNudge(nudge)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+nudge.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+func (s *Server) handleDeleteNudge(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	// Only the owner may see, and so remove, a nudge
+	nudge, err := s.store.GetNudge(r.PathValue("id"))
+	if err == nil && nudge.UserID != auth.Handle {
+		err = domain.ErrNotFound
+	}
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if err := s.store.DeleteNudge(nudge.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+nudge.TaskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *Server) handleGetToday(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	today := "/plan/" + s.clock.Now().In(auth.Location()).Format(time.DateOnly)
+	if r.URL.RawQuery != "" {
+		today += "?" + r.URL.RawQuery // keeps a preselected task
+	}
+	http.Redirect(w, r, today, http.StatusSeeOther)
+}
+
+func (s *Server) handleGetPlan(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	day, err := time.ParseInLocation(time.DateOnly, r.PathValue("date"), auth.Location())
+	if err != nil {
+		http.Error(w, "date must be written as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	blocks, err := s.store.GetTimeBlocks(auth.Handle, day.Format(time.DateOnly))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	prefs, err := s.store.GetPreferences(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	week := domain.WeekStart(day)
+	allocations, err := s.store.GetAllocations(auth.Handle, week.Format(time.DateOnly), week, week.AddDate(0, 0, 7))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewPlanView(day, blocks, categories, r.URL.Query().Get("task"), s.clock.Now(), auth)
+	view.Week = NewWeekView(week, allocations, prefs.WeeklyCapacity, view.URL+"/allocations", auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderPlan(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleAddTimeBlock(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	start, end, err := parseBlockTimes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	block, err := s.store.AddTimeBlock(&domain.TimeBlock{
+		UserID: auth.Handle,
+		TaskID: r.FormValue("task_id"),
+		Day:    r.PathValue("date"),
+		Start:  start,
+		End:    end,
+	})
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/plan/"+block.Day)
+		return
+	}
+	w.Header().Set("HX-Trigger", "planChanged")
+}
+
+// handleSetAllocation plans hours on a task for the week containing the
+// date; zero hours takes the task off the week's plan
+func (s *Server) handleSetAllocation(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	day, err := time.ParseInLocation(time.DateOnly, r.PathValue("date"), auth.Location())
+	if err != nil {
+		http.Error(w, "date must be written as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	var hours float64 // a cleared field removes the task
+	if value := strings.TrimSpace(r.FormValue("hours")); value != "" {
+		if hours, err = strconv.ParseFloat(value, 64); err != nil {
+			http.Error(w, "hours must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	week := domain.WeekStart(day).Format(time.DateOnly)
+	if err := s.store.SetAllocation(auth.Handle, r.FormValue("task_id"), week, hours); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/plan/"+r.PathValue("date"))
+		return
+	}
+	w.Header().Set("HX-Trigger", "planChanged")
+}
+
+func (s *Server) handleMoveTimeBlock(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	block, ok := s.ownTimeBlock(w, r, auth)
+	if !ok {
+		return
+	}
+	start, end, err := parseBlockTimes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.MoveTimeBlock(block.ID, start, end); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/plan/"+block.Day)
+		return
+	}
+	w.Header().Set("HX-Trigger", "planChanged")
+}
+
+func (s *Server) handleDeleteTimeBlock(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	block, ok := s.ownTimeBlock(w, r, auth)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteTimeBlock(block.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/plan/"+block.Day)
+		return
+	}
+	w.Header().Set("HX-Trigger", "planChanged")
+}
+
+// handleLogTimeBlock turns a block that is over into a work log on its task,
+// timed at the end of the block and leaving the task's completion as it is
+func (s *Server) handleLogTimeBlock(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	block, ok := s.ownTimeBlock(w, r, auth)
+	if !ok {
+		return
+	}
+	if block.WorkLogID != "" {
+		storeError(w, fmt.Errorf("%w: the block has already been logged", domain.ErrConflict))
+		return
+	}
+	endsAt := block.EndsAt(auth.Location())
+	if endsAt.After(s.clock.Now()) {
+		storeError(w, fmt.Errorf("%w: the block is not over yet", domain.ErrConflict))
+		return
+	}
+
+	task, err := s.store.GetTask(block.TaskID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	workLog, err := s.store.AddWorkLogForTask(task.ID, block.Hours(), r.FormValue("work_description"), task.Completion, &endsAt, auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if err := s.store.SetTimeBlockWorkLog(block.ID, workLog.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.notifyWorkLogged(auth, workLog)
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/plan/"+block.Day)
+		return
+	}
+	w.Header().Set("HX-Trigger", "planChanged, detailsChanged")
+}
+
+// ownTimeBlock loads the block named in the path, treating anyone else's
+// block as missing
+func (s *Server) ownTimeBlock(w http.ResponseWriter, r *http.Request, auth AuthContext) (*domain.TimeBlock, bool) {
+	block, err := s.store.GetTimeBlock(r.PathValue("id"))
+	if err == nil && block.UserID != auth.Handle {
+		err = domain.ErrNotFound
+	}
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	return block, true
+}
+
+// parseBlockTimes reads the start and end of a block as HH:MM, where the end
+// may be 24:00
+func parseBlockTimes(r *http.Request) (start, end int, err error) {
+	if start, err = parseMinutes(r.FormValue("start")); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseMinutes(r.FormValue("end")); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseMinutes(value string) (int, error) {
+	hh, mm, ok := strings.Cut(value, ":")
+	hours, herr := strconv.Atoi(hh)
+	minutes, merr := strconv.Atoi(mm)
+	if !ok || herr != nil || merr != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > domain.MinutesPerDay {
+		return 0, errors.New("times must be written as HH:MM")
+	}
+	return hours*60 + minutes, nil
+}
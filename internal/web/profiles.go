@@ -0,0 +1,109 @@
+package web
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"strings"
+	"sync"
+	"unicode"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Profile is how a user is shown in attribution chips
+type Profile struct {
+	Handle      string
+	DisplayName string
+	Initials    string
+	Color       template.CSS // Derived from the handle so it is stable everywhere
+}
+
+// ProfileCache resolves handles to profiles. Display names come from each
+// user's preferences; they are cached so that rendering a long work log does
+// not cost one query per entry.
+type ProfileCache struct {
+	store   domain.Store
+	mu      sync.RWMutex
+	entries map[string]Profile
+}
+
+// NewProfileCache creates an empty cache backed by store
+func NewProfileCache(store domain.Store) *ProfileCache {
+	return &ProfileCache{
+		store:   store,
+		entries: make(map[string]Profile),
+	}
+}
+
+// Resolve returns the profile for handle. A nil cache or failed lookup falls
+// back to showing the handle itself.
+func (c *ProfileCache) Resolve(handle string) Profile {
+	if handle == "" {
+		return Profile{}
+	}
+	if c == nil {
+		return newProfile(handle, "")
+	}
+
+	c.mu.RLock()
+	p, ok := c.entries[handle]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	displayName := ""
+	if prefs, err := c.store.GetPreferences(handle); err == nil {
+		displayName = prefs.DisplayName
+	}
+	p = newProfile(handle, displayName)
+
+	c.mu.Lock()
+	c.entries[handle] = p
+	c.mu.Unlock()
+	return p
+}
+
+// Invalidate drops the cached profile for handle, e.g. after a rename
+func (c *ProfileCache) Invalidate(handle string) {
+	c.mu.Lock()
+	delete(c.entries, handle)
+	c.mu.Unlock()
+}
+
+func newProfile(handle, displayName string) Profile {
+	if displayName == "" {
+		displayName = handle
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(handle))
+
+	return Profile{
+		Handle:      handle,
+		DisplayName: displayName,
+		Initials:    initials(displayName),
+		Color:       template.CSS(fmt.Sprintf("hsl(%d, 55%%, 45%%)", h.Sum32()%360)),
+	}
+}
+
+// initials takes the first letter of up to two words, e.g. "Ada Lovelace" -> "AL"
+func initials(name string) string {
+	var out []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				out = append(out, unicode.ToUpper(r))
+				break
+			}
+		}
+		if len(out) == 2 {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return "?"
+	}
+	return string(out)
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+)
+
+// handleServiceWorker serves the service worker from the root, which is the
+// widest scope it may control
+func (s *Server) handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFileFS(w, r, staticFiles, "js/sw.js")
+}
+
+// pushSubscriptionJSON is what PushSubscription.toJSON() produces in the
+// browser
+type pushSubscriptionJSON struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+func (s *Server) handleSubscribePush(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	if s.push == nil {
+		http.Error(w, "Push notifications are not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body pushSubscriptionJSON
+	if err := json.NewDecoder(io.LimitReader(r.Body, 8<<10)).Decode(&body); err != nil {
+		http.Error(w, "Invalid subscription", http.StatusBadRequest)
+		return
+	}
+
+	// Keys are checked here so a bad one fails now rather than on every push
+	u, err := url.Parse(body.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		http.Error(w, "Subscription endpoint must be an https URL", http.StatusBadRequest)
+		return
+	}
+	if key, err := base64.RawURLEncoding.DecodeString(body.Keys.P256dh); err != nil || len(key) != 65 {
+		http.Error(w, "Invalid subscription key", http.StatusBadRequest)
+		return
+	}
+	if secret, err := base64.RawURLEncoding.DecodeString(body.Keys.Auth); err != nil || len(secret) != 16 {
+		http.Error(w, "Invalid subscription auth secret", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SavePushSubscription(&domain.PushSubscription{
+		Endpoint: body.Endpoint,
+		UserID:   auth.Handle,
+		P256dh:   body.Keys.P256dh,
+		Auth:     body.Keys.Auth,
+	}); err != nil {
+		storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUnsubscribePush(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var body pushSubscriptionJSON
+	if err := json.NewDecoder(io.LimitReader(r.Body, 8<<10)).Decode(&body); err != nil || body.Endpoint == "" {
+		http.Error(w, "Invalid subscription", http.StatusBadRequest)
+		return
+	}
+	if err := s.store.DeletePushSubscription(auth.Handle, body.Endpoint); err != nil {
+		storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTestPush sends a notification to the user's own browsers, so they
+// can check the setup without waiting for something to happen
+func (s *Server) handleTestPush(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	if s.push == nil {
+		http.Error(w, "Push notifications are not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := s.push.Send(r.Context(), auth.Handle, notify.Event{
+		Kind:  notify.KindActivity,
+		Title: "Notifications are working",
+		Body:  "This is how Compass will tell you about activity and reminders.",
+		URL:   "/settings",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyWorkLogged tells everyone else who gets notifications that work was
+// logged. It returns at once; lookups and delivery happen in the background.
+func (s *Server) notifyWorkLogged(auth AuthContext, wl *domain.WorkLog) {
+	if s.notifier == nil {
+		return
+	}
+	go func() {
+		task, err := s.store.GetTask(wl.TaskID)
+		if err != nil {
+			return
+		}
+		name, target := task.Name, "/tasks/"+task.ID+"/details"
+		for _, sub := range task.Subtasks {
+			if sub.ID == wl.SubtaskID {
+				name, target = task.Name+" › "+sub.Name, "/subtasks/"+sub.ID+"/details"
+			}
+		}
+
+		body := name
+		if wl.WorkDescription != "" {
+			body += ": " + wl.WorkDescription
+		}
+		s.notifier.Broadcast(context.Background(), notify.Event{
+			Kind:  notify.KindActivity,
+			Actor: auth.Handle,
+			Title: fmt.Sprintf("%s logged %.1fh", s.profiles.Resolve(auth.Handle).DisplayName, wl.HoursWorked),
+			Body:  body,
+			URL:   target,
+		})
+	}()
+}
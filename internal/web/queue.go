@@ -0,0 +1,146 @@
+package web
+
+import (
+	"net/http"
+)
+
+func (s *Server) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	queue, err := s.store.GetQueue(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewQueueView(queue, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderQueue(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleAddToQueue(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.AddToQueue(auth.Handle, id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+id+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged, queueChanged")
+}
+
+func (s *Server) handleRemoveFromQueue(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := s.store.RemoveFromQueue(auth.Handle, id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/queue")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged, queueChanged")
+}
+
+func (s *Server) handleReorderQueue(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		return // Nothing to do
+	}
+
+	if err := s.store.ReorderQueue(auth.Handle, ids); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/queue")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleMoveInQueue moves a task one place up or down the queue, for
+// accessible mode where there is no dragging
+func (s *Server) handleMoveInQueue(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	queue, err := s.store.GetQueue(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	ids := make([]string, len(queue))
+	for i, q := range queue {
+		ids[i] = q.TaskID
+	}
+	ids, _ = moveID(ids, r.PathValue("id"), r.FormValue("direction"))
+
+	if err := s.store.ReorderQueue(auth.Handle, ids); err != nil {
+		storeError(w, err)
+		return
+	}
+	redirectBack(w, r, "/queue")
+}
+
+// isQueued reports whether the task is on the user's focus queue. A failed
+// lookup just shows the task as not queued.
+func (s *Server) isQueued(auth AuthContext, taskID string) bool {
+	if !auth.IsAuthenticated {
+		return false
+	}
+	queue, err := s.store.GetQueue(auth.Handle)
+	if err != nil {
+		return false
+	}
+	for _, q := range queue {
+		if q.TaskID == taskID {
+			return true
+		}
+	}
+	return false
+}
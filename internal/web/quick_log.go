@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// quickLogRecent is how many recent tasks the quick-log page suggests
+const quickLogRecent = 8
+
+// handleGetQuickLog serves the mobile quick-log page: the tasks the user
+// worked on lately, each a tap away from logging hours on it. API clients
+// asking for JSON get the suggestions alone.
+func (s *Server) handleGetQuickLog(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if !auth.IsAuthenticated {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	recent, err := s.store.GetRecentWork(auth.Handle, quickLogRecent)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, map[string]any{"recent": recent})
+		return
+	}
+	view := NewQuickLogView(recent, r.URL.Query().Has("logged"), auth)
+	if err := s.presentation.RenderQuickLog(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleQuickLog logs hours against a task or subtask without touching its
+// completion or asking for a description
+func (s *Server) handleQuickLog(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	hoursWorked, err := strconv.ParseFloat(r.FormValue("hours_worked"), 64)
+	if err != nil || hoursWorked <= 0 {
+		apiError(w, r, http.StatusBadRequest, "Invalid hours_worked value", FieldError{Field: "hours_worked", Message: "must be a number above zero"})
+		return
+	}
+
+	// The log keeps the current completion, so only the hours change
+	var workLog *domain.WorkLog
+	if subtaskID := r.FormValue("subtask_id"); subtaskID != "" {
+		var sub *domain.Subtask
+		if sub, err = s.store.GetSubtask(subtaskID); err == nil {
+			workLog, err = s.store.AddWorkLogForSubtask(sub.ID, hoursWorked, "", sub.Completion, nil, auth.Handle)
+		}
+	} else {
+		var task *domain.Task
+		if task, err = s.store.GetTask(r.FormValue("task_id")); err == nil {
+			workLog, err = s.store.AddWorkLogForTask(task.ID, hoursWorked, "", task.Completion, nil, auth.Handle)
+		}
+	}
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	s.notifyWorkLogged(auth, workLog)
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusCreated, workLog)
+		return
+	}
+	http.Redirect(w, r, "/m/log?logged", http.StatusSeeOther)
+}
@@ -0,0 +1,332 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// maxRecordedBody caps how much of each body is kept. HTMX fragments are
+// far smaller; anything larger is an upload or a download and is truncated.
+const maxRecordedBody = 256 << 10
+
+// recordingsShown is how many exchanges the viewer lists
+const recordingsShown = 200
+
+// Exchange is one recorded request and its response
+type Exchange struct {
+	Seq             int           `json:"seq"`
+	At              time.Time     `json:"at"`
+	Duration        time.Duration `json:"duration"`
+	Method          string        `json:"method"`
+	URL             string        `json:"url"`
+	RequestHeaders  http.Header   `json:"request_headers"`
+	RequestBody     string        `json:"request_body,omitempty"`
+	Status          int           `json:"status"`
+	ResponseHeaders http.Header   `json:"response_headers"`
+	ResponseBody    string        `json:"response_body,omitempty"`
+	OOB             []OOBFragment `json:"oob,omitempty"`
+}
+
+// OOBFragment is an element in a response that HTMX swaps in out of band
+type OOBFragment struct {
+	Target string `json:"target"` // the element's id
+	Swap   string `json:"swap"`   // the hx-swap-oob value
+}
+
+// recorder writes every exchange to a directory, one JSON file each, with
+// secrets removed. It is for debugging in development only.
+type recorder struct {
+	dir   string
+	clock domain.Clock
+
+	mu  sync.Mutex
+	seq int
+}
+
+func newRecorder(dir string, clock domain.Clock) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	// Carry on numbering from an earlier run
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	rec := &recorder{dir: dir, clock: clock}
+	for _, name := range names {
+		if n, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(name), ".json")); err == nil && n > rec.seq {
+			rec.seq = n
+		}
+	}
+	return rec, nil
+}
+
+func (rec *recorder) next() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.seq++
+	return rec.seq
+}
+
+// wrap records everything next serves except static files and the viewer
+func (rec *recorder) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/dev/http") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		}
+
+		at, start := rec.clock.Now(), time.Now()
+		rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		ex := &Exchange{
+			Seq:             rec.next(),
+			At:              at,
+			Duration:        time.Since(start),
+			Method:          r.Method,
+			URL:             sanitizeURL(r.URL),
+			RequestHeaders:  sanitizeHeaders(r.Header),
+			RequestBody:     sanitizeBody(r.Header.Get("Content-Type"), reqBody),
+			Status:          rw.status,
+			ResponseHeaders: sanitizeHeaders(w.Header()),
+			ResponseBody:    sanitizeBody(w.Header().Get("Content-Type"), rw.body.Bytes()),
+		}
+		ex.OOB = findOOB(ex.ResponseBody)
+		if err := rec.save(ex); err != nil {
+			log.Printf("Failed to record %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+func (rec *recorder) save(ex *Exchange) error {
+	// Markup stays readable on disk for grepping
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ex); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(rec.dir, fmt.Sprintf("%06d.json", ex.Seq)), buf.Bytes(), 0o600)
+}
+
+func (rec *recorder) load(seq int) (*Exchange, error) {
+	data, err := os.ReadFile(filepath.Join(rec.dir, fmt.Sprintf("%06d.json", seq)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ex Exchange
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, err
+	}
+	return &ex, nil
+}
+
+// recent loads the latest exchanges, newest first
+func (rec *recorder) recent(limit int) ([]*Exchange, error) {
+	rec.mu.Lock()
+	last := rec.seq
+	rec.mu.Unlock()
+
+	var exchanges []*Exchange
+	for seq := last; seq > 0 && len(exchanges) < limit; seq-- {
+		ex, err := rec.load(seq)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, nil
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status, rw.wroteHeader = status, true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	if room := maxRecordedBody + 1 - rw.body.Len(); room > 0 {
+		rw.body.Write(p[:min(len(p), room)])
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recordingWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijacking is not supported")
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+const redacted = "[redacted]"
+
+// secretHeaders never reach disk
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token", "Proxy-Authorization"}
+
+// secretFields are query and form fields whose values never reach disk
+var secretFields = []string{"csrf", "token", "password", "secret", "code", "state"}
+
+// secretMarkup matches tokens rendered into pages: CSRF hidden inputs, meta
+// tags, and query strings, and the secret part of hook URLs
+var secretMarkup = regexp.MustCompile(`(csrf=|name="csrf" value="|name="csrf-token" content="|"csrf":\s*"|/hooks/)[A-Za-z0-9_\-]+`)
+
+func sanitizeHeaders(h http.Header) http.Header {
+	clean := h.Clone()
+	for _, name := range secretHeaders {
+		if _, ok := clean[name]; ok {
+			clean[name] = []string{redacted}
+		}
+	}
+	return clean
+}
+
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.Path = secretMarkup.ReplaceAllString(u.Path, "${1}"+redacted)
+	clean.RawPath = ""
+	clean.RawQuery = sanitizeValues(u.Query()).Encode()
+	return clean.String()
+}
+
+func sanitizeValues(v url.Values) url.Values {
+	for key := range v {
+		if slices.Contains(secretFields, strings.ToLower(key)) {
+			v[key] = []string{redacted}
+		}
+	}
+	return v
+}
+
+// sanitizeBody keeps text bodies with their secrets removed, and only
+// describes binary ones
+func sanitizeBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	note := ""
+	if len(body) > maxRecordedBody {
+		body, note = body[:maxRecordedBody], "\n[truncated]"
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if v, err := url.ParseQuery(string(body)); err == nil {
+			return sanitizeValues(v).Encode() + note
+		}
+	case strings.HasPrefix(contentType, "text/"),
+		strings.HasPrefix(contentType, "application/json"),
+		strings.HasPrefix(contentType, "application/manifest+json"):
+		return secretMarkup.ReplaceAllString(string(body), "${1}"+redacted) + note
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return fmt.Sprintf("[%s body, %d bytes]", mediaType, len(body))
+}
+
+var (
+	oobElement = regexp.MustCompile(`<[a-zA-Z][^>]*\bhx-swap-oob="([^"]*)"[^>]*>`)
+	idAttr     = regexp.MustCompile(`\bid="([^"]*)"`)
+)
+
+// findOOB lists the out-of-band swaps in an HTML response
+func findOOB(body string) []OOBFragment {
+	var fragments []OOBFragment
+	for _, m := range oobElement.FindAllStringSubmatch(body, -1) {
+		f := OOBFragment{Swap: m[1]}
+		if id := idAttr.FindStringSubmatch(m[0]); id != nil {
+			f.Target = id[1]
+		}
+		// hx-swap-oob="outerHTML:#target" names its target explicitly
+		if _, target, ok := strings.Cut(m[1], ":"); ok {
+			f.Target = strings.TrimPrefix(target, "#")
+		}
+		fragments = append(fragments, f)
+	}
+	return fragments
+}
+
+func (s *Server) handleGetRecordings(w http.ResponseWriter, r *http.Request) {
+	exchanges, err := s.recorder.recent(recordingsShown)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewRecordingsView(exchanges, s.recorder.dir)
+	if err := s.presentation.RenderRecordings(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	if err != nil {
+		http.Error(w, "Invalid recording", http.StatusBadRequest)
+		return
+	}
+	ex, err := s.recorder.load(seq)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if err := s.presentation.RenderRecording(w, NewRecordingView(ex)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+
+	"git.sr.ht/~jakintosh/compass/internal/report"
+)
+
+type requestIDKey struct{}
+
+// validRequestID limits which incoming X-Request-ID values are trusted, so a
+// proxy's ID can be carried through without letting clients inject log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID is the ID the request was tagged with by withRecovery
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withRecovery tags every request with an ID and turns a panic in any
+// handler into an error response, reporting it rather than letting it take
+// down the connection with nothing logged against the request.
+func (s *Server) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		tw := &trackingWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// The handler means to abort the response; net/http
+				// handles that quietly
+				panic(v)
+			}
+
+			if !tw.wroteHeader {
+				s.renderInternalError(w, r, id)
+			}
+			// Reporting may go over the network; don't hold up the response
+			go s.reporter.ReportPanic(context.WithoutCancel(r.Context()), &report.Panic{
+				RequestID: id,
+				Method:    r.Method,
+				URL:       r.URL.Path,
+				Value:     v,
+				Stack:     debug.Stack(),
+				At:        s.clock.Now(),
+			})
+		}()
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// renderInternalError answers a request that failed on our side, in the form
+// the client asked for, with the request ID to quote in a bug report
+func (s *Server) renderInternalError(w http.ResponseWriter, r *http.Request, id string) {
+	reqCtx := parseRequestContext(r)
+	switch {
+	case reqCtx.WantsJSON:
+		apiError(w, r, http.StatusInternalServerError, "internal error")
+	case reqCtx.IsHTMX:
+		// app.js shows this as a toast
+		http.Error(w, "Something went wrong. Reference: "+id, http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		if err := s.presentation.RenderError(w, ErrorView{RequestID: id}); err != nil {
+			io.WriteString(w, "Something went wrong. Reference: "+id)
+		}
+	}
+}
+
+// trackingWriter notes whether a response has started, after which an error
+// page can no longer replace it
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *trackingWriter) WriteHeader(status int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *trackingWriter) Write(p []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *trackingWriter) Flush() {
+	tw.wroteHeader = true
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"html/template"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// TaskRefCache resolves [[task:...]] references for one request, so a task
+// mentioned all over a page is looked up once
+type TaskRefCache struct {
+	store   domain.Store
+	entries map[string]*domain.TaskRef
+}
+
+// NewTaskRefCache creates an empty cache backed by store
+func NewTaskRefCache(store domain.Store) *TaskRefCache {
+	return &TaskRefCache{
+		store:   store,
+		entries: make(map[string]*domain.TaskRef),
+	}
+}
+
+// Resolve returns the referenced task, or nil if the reference is dangling,
+// ambiguous, or the cache is nil
+func (c *TaskRefCache) Resolve(ref string) *domain.TaskRef {
+	if c == nil {
+		return nil
+	}
+	if r, ok := c.entries[ref]; ok {
+		return r
+	}
+	r, err := c.store.ResolveTaskRef(ref)
+	if err != nil {
+		r = nil
+	}
+	c.entries[ref] = r
+	return r
+}
+
+// TaskRefView is a reference the viewer can follow
+type TaskRefView struct {
+	Name       string
+	URL        string
+	Accessible bool
+}
+
+// followRef resolves ref for the viewer; anonymous viewers can only follow
+// references to public tasks
+func followRef(ref string, auth AuthContext) (TaskRefView, bool) {
+	r := auth.refs.Resolve(ref)
+	if r == nil || !(auth.IsAuthenticated || r.Visible) {
+		return TaskRefView{}, false
+	}
+	return TaskRefView{
+		Name:       r.Name,
+		URL:        "/tasks/" + r.ID + "/details",
+		Accessible: auth.Accessible,
+	}, true
+}
+
+// renderText escapes text, turns the task references in it the viewer can
+// follow into links, and shows embedded images; the rest stays as written.
+// The result is sanitized like all rich text; see sanitize.go.
+func renderText(text string, auth AuthContext) template.HTML {
+	var b strings.Builder
+	for _, seg := range domain.SplitText(text) {
+		if seg.ImageID != "" {
+			src := template.HTMLEscapeString("/attachments/" + seg.ImageID)
+			b.WriteString(`<a href="` + src + `" target="_blank" rel="noopener"><img class="inline-image" src="` + src + `" alt="` + template.HTMLEscapeString(seg.Alt) + `" loading="lazy"></a>`)
+			continue
+		}
+		ref, ok := followRef(seg.Ref, auth)
+		if seg.Ref == "" || !ok {
+			b.WriteString(template.HTMLEscapeString(seg.Text))
+			continue
+		}
+		url := template.HTMLEscapeString(ref.URL)
+		b.WriteString(`<a class="task-ref" href="` + url + `"`)
+		if !auth.Accessible {
+			b.WriteString(` hx-get="` + url + `" hx-target="#slideover-container" hx-swap="innerHTML"`)
+		}
+		b.WriteString(`>` + template.HTMLEscapeString(ref.Name) + `</a>`)
+	}
+	return sanitizeHTML(b.String())
+}
+
+// refViews lists the tasks text refers to that the viewer can follow
+func refViews(text string, auth AuthContext) []TaskRefView {
+	var views []TaskRefView
+	for _, ref := range domain.TaskRefs(text) {
+		if v, ok := followRef(ref, auth); ok {
+			views = append(views, v)
+		}
+	}
+	return views
+}
+
+// BacklinkView is something that refers to the task being viewed
+type BacklinkView struct {
+	Kind string
+	Name string
+	URL  string
+}
+
+// newBacklinkViews lists the backlinks the viewer may see
+func newBacklinkViews(backlinks []*domain.Backlink, auth AuthContext) []BacklinkView {
+	var views []BacklinkView
+	for _, b := range backlinks {
+		if !auth.IsAuthenticated && !b.Visible {
+			continue
+		}
+		view := BacklinkView{Name: b.Name}
+		switch b.SourceType {
+		case domain.EntityCategory:
+			view.Kind, view.URL = "Category", "/categories/"+b.SourceID+"/details"
+		case domain.EntityTask:
+			view.Kind, view.URL = "Task", "/tasks/"+b.TaskID+"/details"
+		case domain.EntitySubtask:
+			view.Kind, view.URL = "Subtask", "/subtasks/"+b.SubtaskID+"/details"
+		case domain.EntityWorkLog:
+			view.Kind, view.URL = "Work log", "/tasks/"+b.TaskID+"/details"
+			if b.SubtaskID != "" {
+				view.URL = "/subtasks/" + b.SubtaskID + "/details"
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
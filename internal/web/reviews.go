@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// handleRequestReview marks a task in a category that requires review as
+// ready for its owner to approve
+func (s *Server) handleRequestReview(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+	task, err := s.store.RequestReview(r.PathValue("id"), auth.Handle)
+	s.reviewed(w, r, task, err)
+}
+
+// handleApproveTask finishes a task that is ready for review. Only the
+// category's owner and admins may.
+func (s *Server) handleApproveTask(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireApprover(w, r)
+	if !ok {
+		return
+	}
+	task, err := s.store.ApproveTask(r.PathValue("id"), auth.Handle)
+	s.reviewed(w, r, task, err)
+}
+
+// handleReturnTask sends a task that is ready for review back to be worked
+// on, with a note saying why. Only the category's owner and admins may.
+func (s *Server) handleReturnTask(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireApprover(w, r)
+	if !ok {
+		return
+	}
+	task, err := s.store.ReturnTask(r.PathValue("id"), r.FormValue("note"), auth.Handle)
+	s.reviewed(w, r, task, err)
+}
+
+// requireApprover is requireAuth for the owner of the category the task
+// named by the route is in, or an admin
+func (s *Server) requireApprover(w http.ResponseWriter, r *http.Request) (AuthContext, bool) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return auth, false
+	}
+	task, err := s.store.GetTask(r.PathValue("id"))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return auth, false
+	}
+	cat, err := s.store.GetCategory(task.CategoryID)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return auth, false
+	}
+	if !canApprove(auth, cat) {
+		apiError(w, r, http.StatusForbidden, "Only the category's owner and admins can review its tasks")
+		return auth, false
+	}
+	return auth, true
+}
+
+// reviewed answers a move in a task's review
+func (s *Server) reviewed(w http.ResponseWriter, r *http.Request, task *domain.Task, err error) {
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	ctx := parseRequestContext(r)
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, task)
+		return
+	}
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+task.ID+"/details")
+		return
+	}
+	// Approving finishes the task, which shows on the board
+	w.Header().Set("HX-Trigger", "detailsChanged, boardChanged")
+}
@@ -0,0 +1,181 @@
+package web
+
+// User text reaches pages in two ways. Almost all of it goes through
+// html/template, which escapes for the context it lands in; nothing in the
+// templates marks user text as safe. The exception is rich text (task
+// references and embedded images today, Markdown later), which is built as
+// HTML in Go. That HTML must escape every piece of user text as it is
+// written, and is then passed through sanitizeHTML as a last line of
+// defense, so that a mistake in a renderer cannot become script on the page.
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// attrRule checks an attribute value, returning the value to write
+type attrRule func(string) (string, bool)
+
+func anyValue(v string) (string, bool) { return v, true }
+
+func oneOf(allowed ...string) attrRule {
+	return func(v string) (string, bool) {
+		return v, slices.Contains(allowed, v)
+	}
+}
+
+// localURL accepts only paths on this site
+func localURL(v string) (string, bool) {
+	u := safeURL(v)
+	return u, strings.HasPrefix(u, "/")
+}
+
+func linkURL(v string) (string, bool) {
+	u := safeURL(v)
+	return u, u != ""
+}
+
+// richTextTags are the elements rich text may contain, and the attributes
+// each may carry. Anything else is dropped, keeping its text; attributes
+// not listed, including every event handler and style, are removed.
+var richTextTags = map[string]map[string]attrRule{
+	"a": {
+		"href":      linkURL,
+		"title":     anyValue,
+		"class":     oneOf("task-ref"),
+		"target":    oneOf("_blank"),
+		"rel":       oneOf("noopener", "noopener noreferrer"),
+		"hx-get":    localURL,
+		"hx-target": oneOf("#slideover-container"),
+		"hx-swap":   oneOf("innerHTML"),
+	},
+	"img": {
+		"src":     localURL,
+		"alt":     anyValue,
+		"title":   anyValue,
+		"class":   oneOf("inline-image"),
+		"loading": oneOf("lazy"),
+	},
+	"p": {}, "br": {}, "hr": {},
+	"em": {}, "strong": {}, "del": {}, "code": {}, "pre": {},
+	"blockquote": {}, "ul": {}, "ol": {}, "li": {},
+	"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+}
+
+// voidTags never have content or a closing tag
+var voidTags = []string{"br", "hr", "img"}
+
+// droppedWithContent are removed along with everything inside them, since
+// their text is code rather than prose
+var droppedWithContent = []string{"script", "style", "iframe", "object", "embed", "template", "noscript", "textarea", "title", "svg", "math"}
+
+var (
+	tagPattern  = regexp.MustCompile(`(?s)<!--.*?(?:-->|$)|<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	attrPattern = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+)
+
+// sanitizeHTML reduces rendered rich text to richTextTags. Text is
+// re-escaped, tags are rebuilt from their allowed attributes, and closing
+// tags are balanced so a fragment cannot break the page around it.
+func sanitizeHTML(s string) template.HTML {
+	var b strings.Builder
+	var open []string
+	dropping := ""
+
+	writeText := func(t string) {
+		if dropping == "" {
+			b.WriteString(html.EscapeString(html.UnescapeString(t)))
+		}
+	}
+
+	last := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(s, -1) {
+		writeText(s[last:m[0]])
+		last = m[1]
+		if m[4] < 0 {
+			continue // a comment
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+
+		if dropping != "" {
+			if closing && name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		if slices.Contains(droppedWithContent, name) {
+			if !closing && !strings.HasSuffix(strings.TrimSpace(s[m[6]:m[7]]), "/") {
+				dropping = name
+			}
+			continue
+		}
+		attrs, ok := richTextTags[name]
+		if !ok {
+			continue
+		}
+
+		if closing {
+			if i := slices.Index(open, name); i >= 0 && !slices.Contains(voidTags, name) {
+				// Close anything left open inside it first
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+			}
+			continue
+		}
+
+		b.WriteString("<" + name)
+		for _, a := range attrPattern.FindAllStringSubmatch(s[m[6]:m[7]], -1) {
+			attr := strings.ToLower(a[1])
+			rule, ok := attrs[attr]
+			if !ok {
+				continue
+			}
+			value, ok := rule(html.UnescapeString(a[2] + a[3] + a[4]))
+			if !ok {
+				continue
+			}
+			b.WriteString(" " + attr + `="` + html.EscapeString(value) + `"`)
+		}
+		b.WriteString(">")
+		if !slices.Contains(voidTags, name) {
+			open = append(open, name)
+		}
+	}
+	writeText(s[last:])
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return template.HTML(b.String())
+}
+
+// safeURL returns u if it is safe to follow from rendered text: a path on
+// this site, or an absolute http, https, or mailto URL. Anything else,
+// javascript: and data: URLs included, gives "".
+func safeURL(u string) string {
+	u = strings.TrimSpace(u)
+	if strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") && !strings.HasPrefix(u, `/\`) {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		if parsed.Host == "" {
+			return ""
+		}
+		return parsed.String()
+	case "mailto":
+		return parsed.String()
+	}
+	return ""
+}
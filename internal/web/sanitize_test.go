@@ -0,0 +1,183 @@
+package web
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Turn the compost", "Turn the compost"},
+		{"text is re-escaped", "a < b & c > d", "a &lt; b &amp; c &gt; d"},
+		{"allowed tags kept", "<p><em>soon</em></p>", "<p><em>soon</em></p>"},
+		{"script dropped with its content", "a<script>alert(1)</script>b", "ab"},
+		{"script in caps", "a<SCRIPT>alert(1)</SCRIPT>b", "ab"},
+		{"unclosed script drops the rest", "a<script>alert(1)", "a"},
+		{"self-closed script", `a<script src="/x.js"/>b`, "ab"},
+		{"style dropped with its content", "<style>body{display:none}</style>x", "x"},
+		{"iframe dropped", `<iframe src="https://evil.example"></iframe>x`, "x"},
+		{"svg dropped", `<svg onload="alert(1)"><script>alert(1)</script></svg>x`, "x"},
+		{"unknown tag dropped, text kept", "<marquee>hi</marquee>", "hi"},
+		{"comment dropped", "a<!-- <script>alert(1)</script> -->b", "ab"},
+		{"unclosed comment", "a<!-- <script>", "a"},
+		{"event handler removed", `<img src="/attachments/x" onerror="alert(1)">`, `<img src="/attachments/x">`},
+		{"unquoted event handler", `<img src=/attachments/x onerror=alert(1)>`, `<img src="/attachments/x">`},
+		{"mixed case event handler", `<p OnClick="alert(1)">x</p>`, `<p>x</p>`},
+		{"style attribute removed", `<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript href in caps", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript href with spaces", `<a href="  javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript href with a tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"entity-encoded javascript href", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"hex-encoded javascript href", `<a href="&#x6A;&#x61;vascript&#x3A;alert(1)">x</a>`, `<a>x</a>`},
+		{"data href", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a>x</a>`},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"protocol-relative href", `<a href="//evil.example/">x</a>`, `<a>x</a>`},
+		{"backslash href", `<a href="/\evil.example/">x</a>`, `<a>x</a>`},
+		{"http href kept", `<a href="https://example.com/a?b=1&amp;c=2">x</a>`, `<a href="https://example.com/a?b=1&amp;c=2">x</a>`},
+		{"outside image", `<img src="https://evil.example/track.gif">`, `<img>`},
+		{"hx-get elsewhere", `<a hx-get="https://evil.example/">x</a>`, `<a>x</a>`},
+		{"unlisted hx attribute", `<a hx-post="/categories" hx-trigger="load">x</a>`, `<a>x</a>`},
+		{"entity-encoded tags stay text", "&lt;script&gt;alert(1)&lt;/script&gt;", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"double-encoded tags stay text", "&amp;lt;script&amp;gt;", "&amp;lt;script&amp;gt;"},
+		{"attribute breakout", `<img alt='"><script>alert(1)</script>'>`, `<img alt="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">`},
+		{"unclosed tags balanced", "<strong><em>x", "<strong><em>x</em></strong>"},
+		{"stray closing tag", "</p></div>x", "x"},
+		{"closing tag closes those inside it", "<p><em>x</p>y", "<p><em>x</em></p>y"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(sanitizeHTML(tc.in)); got != tc.want {
+				t.Errorf("sanitizeHTML(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"/tasks/1/details", "/tasks/1/details"},
+		{"https://example.com/", "https://example.com/"},
+		{"mailto:ana@example.com", "mailto:ana@example.com"},
+		{"javascript:alert(1)", ""},
+		{"JAVASCRIPT:alert(1)", ""},
+		{" javascript:alert(1)", ""},
+		{"data:text/html;base64,PHNjcmlwdD4=", ""},
+		{"//evil.example/", ""},
+		{`/\evil.example/`, ""},
+		{"https:///nohost", ""},
+		{"ftp://example.com/", ""},
+		{"relative/path", ""},
+	}
+	for _, tc := range cases {
+		if got := safeURL(tc.in); got != tc.want {
+			t.Errorf("safeURL(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// payloads are written into every field a user controls, to check none of
+// them reaches a page as markup
+var payloads = []string{
+	`<script>alert(1)</script>`,
+	`<img src=x onerror=alert(1)>`,
+	`"><svg onload=alert(1)>`,
+	`' onmouseover='alert(1)`,
+	`<a href="javascript:alert(1)">click</a>`,
+	`&lt;script&gt;alert(1)&lt;/script&gt;`,
+	`[[task:00000000]]<script>alert(1)</script>`,
+	`![x" onerror="alert(1)](/attachments/00000000-0000-4000-8000-000000000000)`,
+}
+
+// TestUserTextIsEscaped writes each payload into the names, descriptions,
+// and work log text it can reach, then looks for it unescaped in the pages
+// and fragments that show them
+func TestUserTextIsEscaped(t *testing.T) {
+	for _, payload := range payloads {
+		t.Run(payload, func(t *testing.T) {
+			ts := newTestServer(t, ServerOptions{})
+			c := ts.category("ana", "Garden")
+			task := ts.task(c.ID, "Turn the compost")
+			sub, err := ts.store.AddSubtask(task.ID, "Find the fork")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, change := range []struct {
+				target string
+				form   url.Values
+			}{
+				{"/categories/" + c.ID, url.Values{"name": {payload}, "description": {payload}}},
+				{"/tasks/" + task.ID, url.Values{"name": {payload}, "description": {payload}}},
+				{"/subtasks/" + sub.ID, url.Values{"name": {payload}, "description": {payload}}},
+				{"/tasks/" + task.ID + "/work-logs", url.Values{"hours_worked": {"1"}, "work_description": {payload}, "completion_estimate": {"10"}}},
+				{"/subtasks/" + sub.ID + "/work-logs", url.Values{"hours_worked": {"1"}, "work_description": {payload}, "completion_estimate": {"10"}}},
+			} {
+				method := http.MethodPatch
+				if strings.HasSuffix(change.target, "/work-logs") {
+					method = http.MethodPost
+				}
+				w := ts.htmx("ana", method, change.target, change.form)
+				expect(t, w, http.StatusOK)
+				checkEscaped(t, change.target, w.Body.String())
+			}
+
+			for _, page := range []string{
+				"/",
+				"/categories/" + c.ID + "/details",
+				"/tasks/" + task.ID + "/details",
+				"/subtasks/" + sub.ID + "/details",
+				"/search?q=alert",
+			} {
+				w := ts.do("ana", http.MethodGet, page, nil)
+				expect(t, w, http.StatusOK)
+				checkEscaped(t, page, w.Body.String())
+			}
+		})
+	}
+}
+
+var (
+	markupTag  = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9-]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	markupAttr = regexp.MustCompile(`([^\s"'=<>/]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+)
+
+// checkEscaped fails if body has markup only a payload could have put
+// there: a script, event handler, or javascript: URL that runs alert. The
+// payloads' text may appear anywhere, as long as it is escaped.
+func checkEscaped(t *testing.T, where, body string) {
+	t.Helper()
+	for _, m := range markupTag.FindAllStringSubmatchIndex(body, -1) {
+		tag := strings.ToLower(body[m[2]:m[3]])
+		if tag == "script" {
+			end := strings.Index(body[m[1]:], "</script>")
+			if end < 0 {
+				end = len(body) - m[1]
+			}
+			if strings.Contains(body[m[1]:m[1]+end], "alert(") {
+				t.Errorf("%s: a script from a payload: %s", where, body[m[0]:m[1]+end])
+			}
+		}
+		for _, a := range markupAttr.FindAllStringSubmatch(body[m[4]:m[5]], -1) {
+			name := strings.ToLower(a[1])
+			value := strings.ToLower(html.UnescapeString(a[2] + a[3] + a[4]))
+			if strings.HasPrefix(name, "on") && strings.Contains(value, "alert(") {
+				t.Errorf("%s: an event handler from a payload: %s", where, body[m[0]:m[1]])
+			}
+			if (name == "href" || name == "src") && strings.HasPrefix(strings.TrimSpace(value), "javascript:") {
+				t.Errorf("%s: a javascript: URL from a payload: %s", where, body[m[0]:m[1]])
+			}
+		}
+	}
+}
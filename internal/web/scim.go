@@ -0,0 +1,422 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// The SCIM schemas compass speaks. The extension carries the categories
+// shared with a user, which a provisioning request may set in place of the
+// server's defaults.
+const (
+	scimUserSchema      = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimCompassSchema   = "urn:ietf:params:scim:schemas:extension:compass:2.0:User"
+	scimListSchema      = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema     = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimContentType     = "application/scim+json"
+	scimMaxResults      = 200
+	scimUserPathPrefix  = "/scim/v2/Users/"
+	scimRequestBodySize = 64 << 10
+)
+
+// scimUser is a user as SCIM represents one. The handle is both the id and
+// the userName, so an identity provider names users the way the sign-in
+// does.
+type scimUser struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	UserName    string       `json:"userName"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Name        *scimName    `json:"name,omitempty"`
+	Emails      []scimEmail  `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Compass     *scimCompass `json:"urn:ietf:params:scim:schemas:extension:compass:2.0:User,omitempty"`
+	Meta        *scimMeta    `json:"meta,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimCompass struct {
+	Categories []string `json:"categories"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// displayName is the name to show for u: its displayName, or else the
+// name it was given in parts
+func (u *scimUser) displayName() string {
+	if u.DisplayName != "" || u.Name == nil {
+		return u.DisplayName
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// email is u's primary address, or its first
+func (u *scimUser) email() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+func newSCIMUser(baseURL string, a *domain.Account) *scimUser {
+	u := &scimUser{
+		Schemas:     []string{scimUserSchema, scimCompassSchema},
+		ID:          a.Handle,
+		UserName:    a.Handle,
+		DisplayName: a.DisplayName,
+		Active:      &a.Active,
+		Compass:     &scimCompass{Categories: a.Categories},
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Location:     baseURL + scimUserPathPrefix + url.PathEscape(a.Handle),
+		},
+	}
+	if a.Email != "" {
+		u.Emails = []scimEmail{{Value: a.Email, Type: "work", Primary: true}}
+	}
+	return u
+}
+
+// scimAuth checks the bearer token an identity provider presents against
+// the provisioning token
+func (s *Server) scimAuth(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(hashHookToken(token)), []byte(hashHookToken(s.provisioningToken))) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="compass"`)
+		scimError(w, http.StatusUnauthorized, "", "A valid provisioning token is required")
+		return false
+	}
+	return true
+}
+
+// scimFilterPattern matches the one filter identity providers send to see
+// whether a user exists
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*userName\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+func (s *Server) handleSCIMListUsers(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	accounts, err := s.store.GetAccounts()
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	if filter := q.Get("filter"); filter != "" {
+		m := scimFilterPattern.FindStringSubmatch(filter)
+		if m == nil {
+			scimError(w, http.StatusBadRequest, "invalidFilter", `Only filters of the form userName eq "handle" are supported`)
+			return
+		}
+		userName, err := strconv.Unquote(`"` + m[1] + `"`)
+		if err != nil {
+			scimError(w, http.StatusBadRequest, "invalidFilter", "Malformed userName")
+			return
+		}
+		var matched []*domain.Account
+		for _, a := range accounts {
+			if strings.EqualFold(a.Handle, userName) {
+				matched = append(matched, a)
+			}
+		}
+		accounts = matched
+	}
+
+	start, _ := strconv.Atoi(q.Get("startIndex"))
+	start = max(start, 1)
+	count, err := strconv.Atoi(q.Get("count"))
+	if err != nil || count > scimMaxResults {
+		count = scimMaxResults
+	}
+	page := accounts[min(start-1, len(accounts)):]
+	page = page[:min(max(count, 0), len(page))]
+
+	resources := make([]*scimUser, len(page))
+	for i, a := range page {
+		resources[i] = newSCIMUser(baseURL(r), a)
+	}
+	writeSCIM(w, http.StatusOK, map[string]any{
+		"schemas":      []string{scimListSchema},
+		"totalResults": len(accounts),
+		"startIndex":   start,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func (s *Server) handleSCIMGetUser(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+	account, err := s.store.GetAccount(r.PathValue("id"))
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, newSCIMUser(baseURL(r), account))
+}
+
+// handleSCIMCreateUser sets up an account ahead of its first sign-in,
+// sharing the server's default categories with it unless the request
+// names others
+func (s *Server) handleSCIMCreateUser(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+	var u scimUser
+	if !readSCIM(w, r, &u) {
+		return
+	}
+
+	account := &domain.Account{
+		Handle:      u.UserName,
+		DisplayName: u.displayName(),
+		Email:       u.email(),
+		Active:      u.Active == nil || *u.Active,
+	}
+	if u.Compass != nil {
+		account.Categories = u.Compass.Categories
+	} else {
+		for _, name := range s.defaultCategories {
+			cat, err := s.categoryNamed(name, "")
+			if err != nil {
+				scimStoreError(w, err)
+				return
+			}
+			account.Categories = append(account.Categories, cat.ID)
+		}
+	}
+
+	account, err := s.store.CreateAccount(account)
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	s.profiles.Invalidate(account.Handle)
+	user := newSCIMUser(baseURL(r), account)
+	w.Header().Set("Location", user.Meta.Location)
+	writeSCIM(w, http.StatusCreated, user)
+}
+
+// handleSCIMReplaceUser replaces the attributes compass keeps for a user.
+// The handle cannot change, since everything the user did is credited to it.
+func (s *Server) handleSCIMReplaceUser(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+	var u scimUser
+	if !readSCIM(w, r, &u) {
+		return
+	}
+	handle := r.PathValue("id")
+	if u.UserName != "" && u.UserName != handle {
+		scimError(w, http.StatusBadRequest, "mutability", "userName cannot be changed")
+		return
+	}
+	s.updateSCIMUser(w, r, &domain.Account{
+		Handle:      handle,
+		DisplayName: u.displayName(),
+		Email:       u.email(),
+		Active:      u.Active == nil || *u.Active,
+	})
+}
+
+// scimPatch is a SCIM PATCH request. Identity providers mostly use it to
+// turn accounts off and on.
+type scimPatch struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+func (s *Server) handleSCIMPatchUser(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+	var patch scimPatch
+	if !readSCIM(w, r, &patch) {
+		return
+	}
+	account, err := s.store.GetAccount(r.PathValue("id"))
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+
+	for _, op := range patch.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+		case "remove":
+			op.Value = json.RawMessage(`""`)
+		default:
+			scimError(w, http.StatusBadRequest, "invalidSyntax", "Unknown operation "+strconv.Quote(op.Op))
+			return
+		}
+
+		// Without a path, the value is an object of attributes to set
+		values := map[string]json.RawMessage{}
+		if op.Path == "" {
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				scimError(w, http.StatusBadRequest, "invalidValue", "An operation without a path needs an object value")
+				return
+			}
+		} else {
+			values[op.Path] = op.Value
+		}
+		for path, value := range values {
+			if !applySCIMPatch(account, path, value) {
+				scimError(w, http.StatusBadRequest, "invalidPath", "Cannot change "+strconv.Quote(path))
+				return
+			}
+		}
+	}
+	s.updateSCIMUser(w, r, account)
+}
+
+// applySCIMPatch sets the attribute at path on a, reporting whether it is
+// one compass keeps and value suits it
+func applySCIMPatch(a *domain.Account, path string, value json.RawMessage) bool {
+	var str string
+	switch {
+	case strings.EqualFold(path, "active"):
+		// Some identity providers send the boolean as a string
+		var b bool
+		if err := json.Unmarshal(value, &b); err == nil {
+			a.Active = b
+			return true
+		}
+		if err := json.Unmarshal(value, &str); err != nil {
+			return false
+		}
+		b, err := strconv.ParseBool(str)
+		a.Active = b
+		return err == nil
+	case strings.EqualFold(path, "displayName"), strings.EqualFold(path, "name.formatted"):
+		if err := json.Unmarshal(value, &str); err != nil {
+			return false
+		}
+		a.DisplayName = str
+		return true
+	case strings.EqualFold(path, "emails"):
+		var emails []scimEmail
+		if err := json.Unmarshal(value, &emails); err == nil {
+			a.Email = (&scimUser{Emails: emails}).email()
+			return true
+		}
+		if err := json.Unmarshal(value, &str); err != nil {
+			return false
+		}
+		a.Email = str
+		return true
+	case strings.HasPrefix(strings.ToLower(path), "emails[") && strings.HasSuffix(path, "].value"):
+		if err := json.Unmarshal(value, &str); err != nil {
+			return false
+		}
+		a.Email = str
+		return true
+	}
+	return false
+}
+
+// handleSCIMDeleteUser turns an account off rather than removing it, so
+// the work credited to the user keeps their name
+func (s *Server) handleSCIMDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !s.scimAuth(w, r) {
+		return
+	}
+	account, err := s.store.GetAccount(r.PathValue("id"))
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	account.Active = false
+	if _, err := s.store.UpdateAccount(account); err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) updateSCIMUser(w http.ResponseWriter, r *http.Request, account *domain.Account) {
+	account, err := s.store.UpdateAccount(account)
+	if err != nil {
+		scimStoreError(w, err)
+		return
+	}
+	s.profiles.Invalidate(account.Handle)
+	writeSCIM(w, http.StatusOK, newSCIMUser(baseURL(r), account))
+}
+
+// readSCIM decodes a request body into v, answering with an error if it
+// cannot
+func readSCIM(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, scimRequestBodySize)).Decode(v); err != nil {
+		scimError(w, http.StatusBadRequest, "invalidSyntax", "Invalid JSON")
+		return false
+	}
+	return true
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// scimError answers with a SCIM error. scimType is optional.
+func scimError(w http.ResponseWriter, status int, scimType, detail string) {
+	body := map[string]any{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	writeSCIM(w, status, body)
+}
+
+func scimStoreError(w http.ResponseWriter, err error) {
+	status := storeErrorStatus(err)
+	scimType := ""
+	switch status {
+	case http.StatusConflict:
+		scimType = "uniqueness"
+	case http.StatusBadRequest:
+		scimType = "invalidValue"
+	}
+	scimError(w, status, scimType, err.Error())
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// searchDropdownID is the header's live results dropdown, which is sent the
+// results alone
+const searchDropdownID = "search-dropdown"
+
+// handleSearch finds what matches q across the board the user can see
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if !auth.IsAuthenticated {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	results, err := s.store.Search(query)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		visible := []*domain.SearchResult{}
+		for _, res := range results {
+			if auth.CanSee(res.CategoryID) {
+				res.Snippet = stripMatches(res.Snippet)
+				visible = append(visible, res)
+			}
+		}
+		writeJSON(w, http.StatusOK, visible)
+		return
+	}
+
+	view := NewSearchView(query, results, auth)
+	if ctx.IsHTMX && ctx.TargetID == searchDropdownID {
+		if err := s.presentation.RenderSearchResults(w, view); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderSearch(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,14 +2,24 @@ package web
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"git.sr.ht/~jakintosh/compass/internal/blob"
 	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/mail"
+	"git.sr.ht/~jakintosh/compass/internal/mirror"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+	"git.sr.ht/~jakintosh/compass/internal/preview"
+	"git.sr.ht/~jakintosh/compass/internal/report"
 	"git.sr.ht/~jakintosh/consent/pkg/client"
+	"golang.org/x/text/language"
 )
 
 // AuthConfig configures authentication for the server.
@@ -24,13 +34,83 @@ type AuthConfig struct {
 	// LogoutURL is where the logout button should send users
 	LogoutURL string
 
-	// Routes are mode-specific handlers to register (e.g., /dev/login, /auth/callback)
+	// Routes are mode-specific sign-in handlers to register (e.g.,
+	// /dev/login, /auth/callback), guarded against brute force
 	Routes map[string]http.HandlerFunc
+
+	// ExtraRoutes are mode-specific handlers that don't sign anyone in
+	// (e.g., /dev/logout, /dev/clock), registered without the guard
+	ExtraRoutes map[string]http.HandlerFunc
+
+	// Renewer refreshes sessions shortly before their access token
+	// expires. Optional; without it tokens are refreshed once expired.
+	Renewer TokenExchanger
+
+	// Provisioner fills in the profile of users the first time they are
+	// seen. Optional; without it users start with default preferences.
+	Provisioner Provisioner
+}
+
+// Provisioner reads what the sign-in vouches for about a user, for the
+// preferences of someone signing in for the first time
+type Provisioner interface {
+	Profile(r *http.Request) (displayName, email string)
 }
 
 // ServerOptions configures the web server
 type ServerOptions struct {
-	Auth AuthConfig // Required; Verifier must be non-nil
+	Auth  AuthConfig   // Required; Verifier must be non-nil
+	Clock domain.Clock // Optional; defaults to the system clock
+
+	// WorkLogLedger makes work logs append-only: instead of editing an
+	// entry in place, a change is recorded as a linked adjustment entry.
+	WorkLogLedger bool
+	// Blobs holds attachment contents. Optional; without it uploads are
+	// refused.
+	Blobs blob.Store
+	// Previews fetches link titles and favicons as links are added.
+	// Optional; without it previews wait for the refresh job.
+	Previews *preview.Fetcher
+	// Notifier delivers notifications about activity. Optional; nil
+	// sends nothing.
+	Notifier *notify.Dispatcher
+	// Push lets browsers subscribe to Web Push. Optional; it should also
+	// be one of the Notifier's channels.
+	Push *notify.WebPush
+	// Mail sends account notices and test messages. Optional; it should
+	// also feed one of the Notifier's channels.
+	Mail *mail.Mailer
+	// Sync syncs boards with peers on demand. Optional; without it boards
+	// only sync on the background job's schedule.
+	Sync *mirror.Client
+	// RecordDir, when set, saves every request and response there with
+	// secrets removed, browsable at /dev/http. For development only.
+	RecordDir string
+	// Reporter is told about panics while serving requests. Optional;
+	// they are always logged.
+	Reporter report.Reporter
+	// ClientIPHeader names the header a trusted reverse proxy puts the
+	// client's address in, such as X-Forwarded-For or X-Real-IP. Optional;
+	// without it the connection's address is used. Only set it behind a
+	// proxy that overwrites the header, or clients can choose their own.
+	ClientIPHeader string
+	// ProvisioningToken lets an identity provider manage accounts through
+	// the SCIM API under /scim/v2, presenting it as a bearer token.
+	// Optional; without it the API is off.
+	ProvisioningToken string
+	// DefaultCategories names the categories shared with each account the
+	// identity provider creates. Missing ones are created.
+	DefaultCategories []string
+	// Admins are the handles of the users who manage groups and share
+	// categories with them. Optional; without any, categories stay shared
+	// with everyone.
+	Admins []string
+	// Landing shows signed-out visitors a page with the instance's name and
+	// a login button, instead of the public parts of the board. Optional.
+	Landing *Landing
+	// PrivateBoards hides the categories a user adds from everyone but
+	// them, admins, and whoever they share them with.
+	PrivateBoards bool
 }
 
 type Server struct {
@@ -38,6 +118,27 @@ type Server struct {
 	router       *http.ServeMux
 	presentation *Presentation
 	auth         AuthConfig
+	profiles     *ProfileCache
+	clock        domain.Clock
+	ledger       bool
+	blobs        blob.Store
+	previews     *preview.Fetcher
+	notifier     *notify.Dispatcher
+	push         *notify.WebPush
+	mail         *mail.Mailer
+	sync         *mirror.Client
+	recorder     *recorder
+	reporter     report.Reporter
+	signIn       *signInGuard
+	handler      http.Handler
+	events       *broker
+	provisioned  sync.Map // handles provisioned, or found to need none, since starting
+
+	provisioningToken string
+	defaultCategories []string
+	admins            map[string]bool
+	landing           *Landing
+	privateBoards     bool
 }
 
 func NewServer(store domain.Store, opts ServerOptions) (*Server, error) {
@@ -49,27 +150,68 @@ func NewServer(store domain.Store, opts ServerOptions) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = domain.SystemClock{}
+	}
 	s := &Server{
 		store:        store,
 		router:       http.NewServeMux(),
 		presentation: pres,
 		auth:         opts.Auth,
+		profiles:     NewProfileCache(store),
+		clock:        clock,
+		ledger:       opts.WorkLogLedger,
+		blobs:        opts.Blobs,
+		previews:     opts.Previews,
+		notifier:     opts.Notifier,
+		push:         opts.Push,
+		mail:         opts.Mail,
+		sync:         opts.Sync,
+		reporter:     report.Log{},
+		signIn:       newSignInGuard(store, clock, opts.ClientIPHeader),
+		events:       newBroker(store),
+
+		provisioningToken: opts.ProvisioningToken,
+		defaultCategories: opts.DefaultCategories,
+		admins:            make(map[string]bool, len(opts.Admins)),
+		landing:           opts.Landing,
+		privateBoards:     opts.PrivateBoards,
+	}
+	for _, handle := range opts.Admins {
+		s.admins[handle] = true
+	}
+	if opts.Reporter != nil {
+		s.reporter = report.Multi{report.Log{}, opts.Reporter}
+	}
+	if opts.RecordDir != "" {
+		if s.recorder, err = newRecorder(opts.RecordDir, clock); err != nil {
+			return nil, err
+		}
 	}
 	s.routes()
+
+	s.handler = s.withRecovery(s.withSession(s.withLocale(s.withEvents(s.router))))
+	if s.recorder != nil {
+		s.handler = s.recorder.wrap(s.handler)
+	}
 	return s, nil
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.router.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
 
 func (s *Server) routes() {
 	// Static Files
-	fs := http.FileServer(http.Dir("internal/web/static"))
-	s.router.Handle("/static/", http.StripPrefix("/static/", fs))
+	s.router.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(staticFiles)))
 
-	// Auth routes (mode-specific: /dev/login, /dev/logout, /auth/callback, etc.)
+	// Auth routes (mode-specific: /dev/login, /auth/callback, etc.), the
+	// sign-in ones guarded against brute force
 	for path, handler := range s.auth.Routes {
+		s.router.HandleFunc(path, s.signIn.wrap(handler))
+	}
+	for path, handler := range s.auth.ExtraRoutes {
 		s.router.HandleFunc(path, handler)
 	}
 
@@ -77,9 +219,23 @@ func (s *Server) routes() {
 	s.router.HandleFunc("GET /{$}", s.handleIndex)
 
 	// API/HTMX Routes
+	s.router.HandleFunc("POST /seed", s.handleSeed)
 	s.router.HandleFunc("POST /categories", s.handleCreateCategory)
 	s.router.HandleFunc("PATCH /categories/{id}", s.handleUpdateCategory)
 	s.router.HandleFunc("GET /categories/{id}/details", s.handleGetCategoryDetails)
+	s.router.HandleFunc("GET /categories/{id}/merge", s.handleGetCategoryMerge)
+	s.router.HandleFunc("POST /categories/{id}/merge", s.handleMergeCategory)
+	s.router.HandleFunc("GET /categories/{id}/import", s.handleGetCategoryImport)
+	s.router.HandleFunc("POST /categories/{id}/embed", s.handleShareCategory)
+	s.router.HandleFunc("DELETE /categories/{id}/embed", s.handleUnshareCategory)
+	s.router.HandleFunc("POST /categories/{id}/embed/delete", s.handleUnshareCategory)
+	s.router.HandleFunc("GET /embed/{token}/progress.svg", s.handleEmbedProgressSVG)
+	s.router.HandleFunc("GET /embed/{token}/progress.html", s.handleEmbedProgressPage)
+	s.router.HandleFunc("POST /categories/{id}/alerts", s.handleCreateAlertWebhook)
+	s.router.HandleFunc("DELETE /categories/{id}/alerts", s.handleDeleteAlertWebhook)
+	s.router.HandleFunc("POST /categories/{id}/alerts/delete", s.handleDeleteAlertWebhook)
+	s.router.HandleFunc("POST /alerts/{token}", s.handleAlertWebhook)
+	s.router.HandleFunc("POST /categories/{id}/import", s.handleImportCategory)
 	s.router.HandleFunc("POST /categories/{id}/tasks", s.handleCreateTask)
 	s.router.HandleFunc("PATCH /tasks/{id}", s.handleUpdateTask)
 	s.router.HandleFunc("GET /tasks/{id}/details", s.handleGetTaskDetails)
@@ -92,10 +248,236 @@ func (s *Server) routes() {
 	s.router.HandleFunc("DELETE /categories/{id}", s.handleDeleteCategory)
 	s.router.HandleFunc("DELETE /tasks/{id}", s.handleDeleteTask)
 	s.router.HandleFunc("DELETE /subtasks/{id}", s.handleDeleteSubtask)
+	s.router.HandleFunc("POST /undo/{token}", s.handleUndo)
+	s.router.HandleFunc("GET /categories/{id}/history", s.handleGetHistory(domain.EntityCategory))
+	s.router.HandleFunc("GET /tasks/{id}/history", s.handleGetHistory(domain.EntityTask))
+	s.router.HandleFunc("GET /subtasks/{id}/history", s.handleGetHistory(domain.EntitySubtask))
+	s.router.HandleFunc("POST /revisions/{id}/restore", s.handleRestoreRevision)
+	s.router.HandleFunc("GET /changes", s.handleGetChanges)
+	s.router.HandleFunc("GET /events", s.handleEvents)
+
+	// The same handlers as JSON, for other clients
+	s.apiRoutes()
+
+	// Plain form fallbacks for accessible mode (no JS, so no PATCH/DELETE)
+	s.router.HandleFunc("POST /categories/{id}", s.handleUpdateCategory)
+	s.router.HandleFunc("POST /tasks/{id}", s.handleUpdateTask)
+	s.router.HandleFunc("POST /subtasks/{id}", s.handleUpdateSubtask)
+	s.router.HandleFunc("POST /categories/{id}/delete", s.handleDeleteCategory)
+	s.router.HandleFunc("POST /tasks/{id}/delete", s.handleDeleteTask)
+	s.router.HandleFunc("POST /subtasks/{id}/delete", s.handleDeleteSubtask)
+	s.router.HandleFunc("POST /categories/{id}/move", s.handleMoveCategory)
+	s.router.HandleFunc("POST /tasks/{id}/move", s.handleMoveTask)
+	s.router.HandleFunc("POST /subtasks/{id}/move", s.handleMoveSubtask)
+	s.router.HandleFunc("POST /preferences", s.handleUpdatePreferences)
+	s.router.HandleFunc("GET /settings", s.handleGetSettings)
 
 	// Work Log Routes
 	s.router.HandleFunc("POST /tasks/{id}/work-logs", s.handleCreateTaskWorkLog)
 	s.router.HandleFunc("POST /subtasks/{id}/work-logs", s.handleCreateSubtaskWorkLog)
+	s.router.HandleFunc("POST /tasks/{id}/reforecast", s.handleReforecastTask)
+	s.router.HandleFunc("POST /tasks/{id}/review", s.handleRequestReview)
+	s.router.HandleFunc("POST /tasks/{id}/approve", s.handleApproveTask)
+	s.router.HandleFunc("POST /tasks/{id}/return", s.handleReturnTask)
+	s.router.HandleFunc("POST /work-logs/{id}", s.handleUpdateWorkLog)
+	s.router.HandleFunc("GET /m/log", s.handleGetQuickLog)
+	s.router.HandleFunc("POST /m/log", s.handleQuickLog)
+	s.router.HandleFunc("GET /manifest.webmanifest", s.handleManifest)
+	s.router.HandleFunc("GET /share", s.handleGetShare)
+	s.router.HandleFunc("POST /share", s.handleShare)
+
+	// Attachment Routes
+	s.router.HandleFunc("POST /tasks/{id}/attachments", s.handleUploadTaskAttachment)
+	s.router.HandleFunc("POST /work-logs/{id}/attachments", s.handleUploadWorkLogAttachment)
+	s.router.HandleFunc("POST /work-logs/{id}/voice-memos", s.handleUploadVoiceMemo)
+	s.router.HandleFunc("POST /tasks/{id}/images", s.handlePasteTaskImage)
+	s.router.HandleFunc("POST /subtasks/{id}/images", s.handlePasteSubtaskImage)
+	s.router.HandleFunc("GET /attachments/{id}", s.handleGetAttachment)
+	s.router.HandleFunc("GET /attachments/{id}/thumbnail", s.handleGetAttachmentThumbnail)
+	s.router.HandleFunc("DELETE /attachments/{id}", s.handleDeleteAttachment)
+	s.router.HandleFunc("POST /attachments/{id}/delete", s.handleDeleteAttachment)
+
+	// Link Routes
+	s.router.HandleFunc("POST /tasks/{id}/links", s.handleAddLink)
+	s.router.HandleFunc("GET /links/{id}/favicon", s.handleGetLinkFavicon)
+	s.router.HandleFunc("DELETE /links/{id}", s.handleDeleteLink)
+	s.router.HandleFunc("POST /links/{id}/delete", s.handleDeleteLink)
+
+	// Nudge Routes
+	s.router.HandleFunc("POST /tasks/{id}/nudges", s.handleAddNudge)
+	s.router.HandleFunc("DELETE /nudges/{id}", s.handleDeleteNudge)
+	s.router.HandleFunc("POST /nudges/{id}/delete", s.handleDeleteNudge)
+
+	// Timeline Routes
+	s.router.HandleFunc("GET /timeline/{id}", s.handleGetTimeline)
+	s.router.HandleFunc("GET /timeline/{id}/chart.svg", s.handleGetTimelineSVG)
+
+	// Dependency Routes
+	s.router.HandleFunc("POST /tasks/{id}/dependencies", s.handleAddDependency)
+	s.router.HandleFunc("DELETE /tasks/{id}/dependencies/{other}", s.handleRemoveDependency)
+	s.router.HandleFunc("POST /tasks/{id}/dependencies/{other}/delete", s.handleRemoveDependency)
+
+	// Search
+	s.router.HandleFunc("GET /search", s.handleSearch)
+
+	// Focus queue
+	s.router.HandleFunc("GET /queue", s.handleGetQueue)
+	s.router.HandleFunc("POST /queue/reorder", s.handleReorderQueue)
+	s.router.HandleFunc("POST /queue/{id}/move", s.handleMoveInQueue)
+	s.router.HandleFunc("POST /tasks/{id}/queue", s.handleAddToQueue)
+	s.router.HandleFunc("DELETE /tasks/{id}/queue", s.handleRemoveFromQueue)
+	s.router.HandleFunc("POST /tasks/{id}/queue/delete", s.handleRemoveFromQueue)
+
+	// Day planner
+	s.router.HandleFunc("GET /plan", s.handleGetToday)
+	s.router.HandleFunc("GET /plan/{date}", s.handleGetPlan)
+	s.router.HandleFunc("POST /plan/{date}/blocks", s.handleAddTimeBlock)
+	s.router.HandleFunc("POST /plan/{date}/allocations", s.handleSetAllocation)
+	s.router.HandleFunc("PATCH /blocks/{id}", s.handleMoveTimeBlock)
+	s.router.HandleFunc("POST /blocks/{id}", s.handleMoveTimeBlock)
+	s.router.HandleFunc("POST /blocks/{id}/log", s.handleLogTimeBlock)
+	s.router.HandleFunc("DELETE /blocks/{id}", s.handleDeleteTimeBlock)
+	s.router.HandleFunc("POST /blocks/{id}/delete", s.handleDeleteTimeBlock)
+	s.router.HandleFunc("GET /workload", s.handleGetWorkload)
+
+	// Objectives and key results
+	s.router.HandleFunc("GET /goals", s.handleGetCurrentGoals)
+	s.router.HandleFunc("GET /goals/{quarter}", s.handleGetGoals)
+	s.router.HandleFunc("POST /goals/{quarter}/objectives", s.handleAddObjective)
+	s.router.HandleFunc("DELETE /objectives/{id}", s.handleDeleteObjective)
+	s.router.HandleFunc("POST /objectives/{id}/delete", s.handleDeleteObjective)
+	s.router.HandleFunc("POST /objectives/{id}/key-results", s.handleAddKeyResult)
+	s.router.HandleFunc("PATCH /key-results/{id}", s.handleUpdateKeyResult)
+	s.router.HandleFunc("POST /key-results/{id}", s.handleUpdateKeyResult)
+	s.router.HandleFunc("DELETE /key-results/{id}", s.handleDeleteKeyResult)
+	s.router.HandleFunc("POST /key-results/{id}/delete", s.handleDeleteKeyResult)
+	s.router.HandleFunc("POST /key-results/{id}/tasks", s.handleLinkKeyResult)
+	s.router.HandleFunc("POST /tasks/{id}/key-results", s.handleLinkTaskKeyResult)
+	s.router.HandleFunc("DELETE /key-results/{id}/tasks/{task}", s.handleUnlinkKeyResult)
+	s.router.HandleFunc("POST /key-results/{id}/tasks/{task}/delete", s.handleUnlinkKeyResult)
+
+	// Contexts
+	s.router.HandleFunc("POST /tasks/{id}/contexts", s.handleSetTaskContexts)
+
+	// Tags
+	s.router.HandleFunc("GET /tags", s.handleGetTags)
+	s.router.HandleFunc("POST /tags", s.handleCreateTag)
+	s.router.HandleFunc("PATCH /tags/{id}", s.handleUpdateTag)
+	s.router.HandleFunc("POST /tags/{id}", s.handleUpdateTag)
+	s.router.HandleFunc("DELETE /tags/{id}", s.handleDeleteTag)
+	s.router.HandleFunc("POST /tags/{id}/delete", s.handleDeleteTag)
+	s.router.HandleFunc("GET /tags/{id}/tasks", s.handleGetTagTasks)
+	s.router.HandleFunc("POST /tasks/{id}/tags", s.handleTagTask)
+	s.router.HandleFunc("DELETE /tasks/{id}/tags/{tag}", s.handleUntagTask)
+	s.router.HandleFunc("POST /tasks/{id}/tags/{tag}/delete", s.handleUntagTask)
+
+	// Blocked report
+	s.router.HandleFunc("GET /blocked", s.handleGetBlocked)
+	s.router.HandleFunc("GET /work-sessions", s.handleGetWorkSessions)
+	s.router.HandleFunc("POST /work-sessions/log", s.handleLogWorkSession)
+	s.router.HandleFunc("POST /work-sessions/dismiss", s.handleDismissWorkSession)
+
+	// Timers
+	s.router.HandleFunc("GET /timers", s.handleGetTimers)
+	s.router.HandleFunc("POST /tasks/{id}/timers", s.handleStartTimer)
+	s.router.HandleFunc("POST /timers/heartbeat", s.handleTimerHeartbeat)
+	s.router.HandleFunc("POST /timers/{id}/pause", s.handlePauseTimer)
+	s.router.HandleFunc("POST /timers/{id}/resume", s.handleResumeTimer)
+	s.router.HandleFunc("POST /timers/{id}/stop", s.handleStopTimer)
+
+	// Pick something to work on
+	s.router.HandleFunc("GET /suggest", s.handleGetSuggest)
+
+	// Definition of done
+	s.router.HandleFunc("POST /categories/{id}/done-criteria", s.handleAddDoneCriterion)
+	s.router.HandleFunc("DELETE /done-criteria/{id}", s.handleDeleteDoneCriterion)
+	s.router.HandleFunc("POST /done-criteria/{id}/delete", s.handleDeleteDoneCriterion)
+
+	// Aging rules
+	s.router.HandleFunc("POST /categories/{id}/aging-rules", s.handleAddAgingRule)
+	s.router.HandleFunc("DELETE /aging-rules/{id}", s.handleDeleteAgingRule)
+	s.router.HandleFunc("POST /aging-rules/{id}/delete", s.handleDeleteAgingRule)
+	s.router.HandleFunc("POST /checks/{id}", s.handleSetTaskCheck)
+
+	// Notification Inbox Routes
+	s.router.HandleFunc("GET /notifications", s.handleGetNotifications)
+	s.router.HandleFunc("GET /notifications/bell", s.handleGetNotificationBell)
+	s.router.HandleFunc("POST /notifications/read", s.handleMarkAllNotificationsRead)
+	s.router.HandleFunc("POST /notifications/{id}/read", s.handleMarkNotificationRead)
+
+	// Hook Routes, authorized by the token in the URL rather than a session
+	s.router.HandleFunc("GET /hooks/{token}", s.handleHookDocs)
+	s.router.HandleFunc("POST /hooks/{token}/capture", s.handleHookCapture)
+	s.router.HandleFunc("POST /hooks/{token}/work-logs", s.handleHookWorkLog)
+	s.router.HandleFunc("POST /hooks/{token}/progress", s.handleHookProgress)
+	s.router.HandleFunc("POST /hooks/{token}/commits", s.handleHookCommit)
+	s.router.HandleFunc("POST /hooks/{token}/clip", s.handleHookClip)
+	s.router.HandleFunc("GET /hooks/{token}/triggers/new-tasks", s.handleHookTrigger(domain.TaskEventAdded))
+	s.router.HandleFunc("GET /hooks/{token}/triggers/completed-tasks", s.handleHookTrigger(domain.TaskEventCompleted))
+	s.router.HandleFunc("/dav/{token}", s.handleDAV)
+	s.router.HandleFunc("/dav/{token}/{path...}", s.handleDAV)
+	s.router.HandleFunc("GET /grafana/{token}", s.handleGrafanaHealth)
+	s.router.HandleFunc("GET /grafana/{token}/{$}", s.handleGrafanaHealth)
+	s.router.HandleFunc("POST /grafana/{token}/search", s.handleGrafanaMetrics)
+	s.router.HandleFunc("POST /grafana/{token}/metrics", s.handleGrafanaMetrics)
+	s.router.HandleFunc("POST /grafana/{token}/query", s.handleGrafanaQuery)
+	s.router.HandleFunc("POST /settings/hooks", s.handleCreateHook)
+	s.router.HandleFunc("DELETE /settings/hooks/{id}", s.handleDeleteHook)
+	s.router.HandleFunc("POST /settings/hooks/{id}/delete", s.handleDeleteHook)
+	s.router.HandleFunc("GET /stats.json", s.handleStats)
+	s.router.HandleFunc("POST /settings/stats", s.handleCreateStatsLink)
+	s.router.HandleFunc("DELETE /settings/stats", s.handleDeleteStatsLink)
+	s.router.HandleFunc("POST /settings/stats/delete", s.handleDeleteStatsLink)
+
+	// Sync Routes, authorized by the peer's signature rather than a session
+	s.router.HandleFunc("GET /sync/{board}/changes", s.handleGetSyncChanges)
+	s.router.HandleFunc("POST /sync/{board}/changes", s.handlePushSyncChanges)
+	s.router.HandleFunc("POST /sync-peers", s.handleAddSyncPeer)
+	s.router.HandleFunc("POST /sync-peers/{id}/sync", s.handleSyncNow)
+	s.router.HandleFunc("DELETE /sync-peers/{id}", s.handleDeleteSyncPeer)
+	s.router.HandleFunc("POST /sync-peers/{id}/delete", s.handleDeleteSyncPeer)
+	s.router.HandleFunc("POST /settings/backup", s.handleExportBackup)
+	s.router.HandleFunc("POST /settings/restore", s.handleRestoreBackup)
+	s.router.HandleFunc("GET /account/export", s.handleExportAccount)
+	s.router.HandleFunc("GET /export", s.handleExportWorkspace)
+	s.router.HandleFunc("POST /import", s.handleImportWorkspace)
+
+	// Push Notification Routes
+	s.router.HandleFunc("GET /sw.js", s.handleServiceWorker)
+	s.router.HandleFunc("POST /push/subscriptions", s.handleSubscribePush)
+	s.router.HandleFunc("DELETE /push/subscriptions", s.handleUnsubscribePush)
+	s.router.HandleFunc("POST /push/test", s.handleTestPush)
+	s.router.HandleFunc("POST /settings/notify/{service}", s.handleSaveNotifyService)
+	s.router.HandleFunc("DELETE /settings/notify/{service}", s.handleDeleteNotifyService)
+	s.router.HandleFunc("POST /settings/notify/{service}/delete", s.handleDeleteNotifyService)
+	s.router.HandleFunc("POST /settings/notify/{service}/test", s.handleTestNotifyService)
+	s.router.HandleFunc("POST /settings/email/test", s.handleTestEmail)
+
+	// Groups and Sharing
+	s.router.HandleFunc("GET /groups", s.handleGetGroups)
+	s.router.HandleFunc("POST /groups", s.handleCreateGroup)
+	s.router.HandleFunc("DELETE /groups/{id}", s.handleDeleteGroup)
+	s.router.HandleFunc("POST /groups/{id}/delete", s.handleDeleteGroup)
+	s.router.HandleFunc("POST /groups/{id}/members", s.handleAddGroupMember)
+	s.router.HandleFunc("POST /groups/{id}/members/delete", s.handleRemoveGroupMember)
+	s.router.HandleFunc("POST /categories/{id}/access", s.handleShareCategoryAccess)
+	s.router.HandleFunc("POST /categories/{id}/access/delete", s.handleUnshareCategoryAccess)
+
+	// Account Provisioning, only with a token to present
+	if s.provisioningToken != "" {
+		s.router.HandleFunc("GET /scim/v2/Users", s.handleSCIMListUsers)
+		s.router.HandleFunc("POST /scim/v2/Users", s.handleSCIMCreateUser)
+		s.router.HandleFunc("GET /scim/v2/Users/{id}", s.handleSCIMGetUser)
+		s.router.HandleFunc("PUT /scim/v2/Users/{id}", s.handleSCIMReplaceUser)
+		s.router.HandleFunc("PATCH /scim/v2/Users/{id}", s.handleSCIMPatchUser)
+		s.router.HandleFunc("DELETE /scim/v2/Users/{id}", s.handleSCIMDeleteUser)
+	}
+
+	// Recording Viewer, only when recording
+	if s.recorder != nil {
+		s.router.HandleFunc("GET /dev/http", s.handleGetRecordings)
+		s.router.HandleFunc("GET /dev/http/{seq}", s.handleGetRecording)
+	}
 }
 
 // getAuthContext attempts to verify auth and returns context with CSRF token.
@@ -106,53 +488,241 @@ func (s *Server) getAuthContext(w http.ResponseWriter, r *http.Request) AuthCont
 		IsAuthenticated: false,
 		LoginURL:        s.auth.LoginURL,
 		LogoutURL:       s.auth.LogoutURL,
+		Mobile:          isMobileClient(r),
+		WorkLogLedger:   s.ledger,
+		profiles:        s.profiles,
+		refs:            NewTaskRefCache(s.store),
+		Tags:            filterTags(r),
 	}
+	ctx = ctx.withLocale(localeOf(r))
 
 	accessToken, csrfToken, err := s.auth.Verifier.VerifyAuthorizationGetCSRF(w, r)
 	if err != nil {
-		return ctx
+		return s.withAccess(ctx)
+	}
+
+	s.provision(r, accessToken.Subject())
+	if s.deactivated(accessToken.Subject()) {
+		return s.withAccess(ctx)
 	}
 
 	ctx.IsAuthenticated = true
 	ctx.Handle = accessToken.Subject()
 	ctx.CSRFToken = csrfToken
+	ctx.IsAdmin = s.admins[ctx.Handle]
+	if unread, err := s.store.CountUnreadNotifications(ctx.Handle); err == nil {
+		ctx.UnreadNotifications = unread
+	}
+	ctx = s.withPreferences(ctx)
+	if timers, err := s.store.GetTimers(ctx.Handle); err == nil {
+		ctx.Timers = NewTimerViews(timers, s.clock.Now(), ctx)
+	}
+	if tags, err := s.store.GetTags(); err == nil {
+		ctx.AllTags = tags
+	}
+	return s.withAccess(ctx)
+}
+
+// withAccess hides the categories shared with others from the user, and on
+// private boards the ones belonging to others too. Nothing is shared with
+// someone signed out, so they see neither, nor anything at all when there
+// is a landing page. It is worked out on every request, so joining or
+// leaving a group takes effect at once. Admins see everything.
+// A failed lookup hides nothing rather than failing the request.
+func (s *Server) withAccess(ctx AuthContext) AuthContext {
+	if ctx.IsAdmin {
+		return ctx
+	}
+	if !ctx.IsAuthenticated && s.landing != nil {
+		ctx.loginRequired = true
+		return ctx
+	}
+	ids, err := s.store.GetHiddenCategoryIDs(ctx.Handle)
+	if err != nil {
+		return ctx
+	}
+	if s.privateBoards {
+		if others, err := s.store.GetOthersCategoryIDs(ctx.Handle); err == nil {
+			ids = append(ids, others...)
+		}
+	}
+	if len(ids) == 0 {
+		return ctx
+	}
+	ctx.hidden = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		ctx.hidden[id] = true
+	}
 	return ctx
 }
 
+// withPreferences applies the authenticated user's display preferences,
+// their language and zone taking the place of what the browser asked for.
+// A failed lookup falls back to defaults rather than failing the request.
+func (s *Server) withPreferences(ctx AuthContext) AuthContext {
+	prefs, err := s.store.GetPreferences(ctx.Handle)
+	if err != nil {
+		return ctx
+	}
+	ctx.Accessible = prefs.Accessible
+	ctx.MultipleTimers = prefs.MultipleTimers
+	if prefs.Timezone != "" {
+		ctx.location = prefs.Location()
+	}
+	if prefs.Language != "" {
+		ctx.lang = matchLanguage(language.Make(prefs.Language))
+	}
+	if contexts, err := s.store.GetContexts(); err == nil {
+		ctx.Contexts = contexts
+	}
+	if prefs.Context != "" {
+		if ids, err := s.store.GetContextTaskIDs(prefs.Context); err == nil {
+			ctx.Context = prefs.Context
+			ctx.inContext = make(map[string]bool, len(ids))
+			for _, id := range ids {
+				ctx.inContext[id] = true
+			}
+		}
+	}
+	if ctx.Accessible {
+		// Accessible mode is already a single-column, no-script layout
+		ctx.Mobile = false
+	}
+	return ctx
+}
+
+// provision saves the profile the sign-in vouches for as the preferences of
+// a user seeing compass for the first time. A failure is logged and tried
+// again on the next request.
+func (s *Server) provision(r *http.Request, handle string) {
+	if s.auth.Provisioner == nil {
+		return
+	}
+	if _, done := s.provisioned.Load(handle); done {
+		return
+	}
+	displayName, email := s.auth.Provisioner.Profile(r)
+	created, err := s.store.ProvisionUser(&domain.Preferences{
+		UserID:      handle,
+		DisplayName: displayName,
+		Email:       email,
+	})
+	if err != nil {
+		log.Printf("provisioning %s: %v", handle, err)
+		return
+	}
+	if created {
+		s.profiles.Invalidate(handle)
+	}
+	s.provisioned.Store(handle, true)
+}
+
+// deactivated reports whether the identity provider has turned the user's
+// account off
+func (s *Server) deactivated(handle string) bool {
+	prefs, err := s.store.GetPreferences(handle)
+	return err == nil && prefs.Deactivated
+}
+
 // requireAuth verifies auth and CSRF for destructive operations.
 // Returns auth context and true if authorized, writes error response if not.
 func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request) (AuthContext, bool) {
-	// Get CSRF from request (form value or query param)
+	// Get CSRF from request (form value, query param, or header)
 	csrf := r.FormValue("csrf")
 	if csrf == "" {
 		csrf = r.URL.Query().Get("csrf")
 	}
+	if csrf == "" {
+		csrf = r.Header.Get(csrfHeader)
+	}
 
 	accessToken, csrfToken, err := s.auth.Verifier.VerifyAuthorizationCheckCSRF(w, r, csrf)
 	if err == client.ErrCSRFInvalid {
-		http.Error(w, "CSRF validation failed", http.StatusForbidden)
+		apiError(w, r, http.StatusForbidden, "CSRF validation failed", FieldError{Field: "csrf", Message: "does not match the session"})
 		return AuthContext{}, false
 	}
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		s.sessionExpired(w, r)
+		return AuthContext{}, false
+	}
+	if csrfToken != csrf {
+		// The session was renewed on the way in
+		w.Header().Set(csrfHeader, csrfToken)
+	}
+	s.provision(r, accessToken.Subject())
+	if s.deactivated(accessToken.Subject()) {
+		apiError(w, r, http.StatusForbidden, "This account has been deactivated")
 		return AuthContext{}, false
 	}
 
-	return AuthContext{
+	auth := s.withAccess(s.withPreferences(AuthContext{
 		IsAuthenticated: true,
 		Handle:          accessToken.Subject(),
 		CSRFToken:       csrfToken,
 		LoginURL:        s.auth.LoginURL,
 		LogoutURL:       s.auth.LogoutURL,
-	}, true
+		Mobile:          isMobileClient(r),
+		WorkLogLedger:   s.ledger,
+		IsAdmin:         s.admins[accessToken.Subject()],
+		profiles:        s.profiles,
+		refs:            NewTaskRefCache(s.store),
+	}.withLocale(localeOf(r))))
+	if !s.inReach(auth, r) {
+		apiError(w, r, http.StatusNotFound, "Not found")
+		return AuthContext{}, false
+	}
+	return auth, true
+}
+
+// renderDescriptionConflict answers an edit that lost a race with the merge
+// form. HTMX requests have it swapped in place of the description field; the
+// original request expected no content, so this is still a 200.
+func (s *Server) renderDescriptionConflict(w http.ResponseWriter, r *http.Request, view DescriptionMergeView) {
+	ctx := parseRequestContext(r)
+	if ctx.WantsJSON {
+		apiError(w, r, http.StatusConflict, "The description was changed while this edit was made", FieldError{Field: "description", Message: "was changed by someone else; send it again with description_base set to the current text"})
+		return
+	}
+
+	if ctx.IsHTMX {
+		w.Header().Set("HX-Retarget", "#description-form-"+view.ID)
+		w.Header().Set("HX-Reswap", "outerHTML")
+		if err := s.presentation.RenderDescriptionMerge(w, view); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.renderIndexStatus(w, http.StatusConflict, view.AuthContext, view)
+}
+
+// redirectBack ends a non-HTMX request with a redirect to the form's
+// return_to field when it names a local path, or to fallback otherwise.
+func redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	target := r.FormValue("return_to")
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		target = fallback
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if auth.loginRequired {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Sign in to see this board")
+			return
+		}
+		if err := s.presentation.RenderLanding(w, NewLandingView(s.landing, auth)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
 
 	cats, err := s.store.GetCategories()
 	if err != nil {
-		http.Error(w, "Failed to load categories", http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
@@ -160,6 +730,16 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if !auth.IsAuthenticated {
 		cats = filterPublicCategories(cats)
 	}
+	if ctx.WantsJSON {
+		visible := []*domain.Category{}
+		for _, c := range cats {
+			if auth.CanSee(c.ID) {
+				visible = append(visible, c)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"categories": visible})
+		return
+	}
 
 	// Convert to view models
 	catViews := make([]CategoryView, len(cats))
@@ -167,7 +747,39 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		catViews[i] = NewCategoryView(c, false, auth)
 	}
 
-	if err := s.presentation.RenderIndex(w, catViews, auth); err != nil {
+	// Non-HTMX deletions land here with the trash entry to offer undo for
+	var undo *UndoView
+	if token := r.URL.Query().Get("undo"); token != "" && auth.IsAuthenticated {
+		if entry, err := s.store.GetTrashEntry(token); err == nil {
+			undo = NewUndoView(entry, auth, s.clock.Now())
+		}
+	}
+
+	if err := s.presentation.RenderIndex(w, catViews, auth, undo); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// renderIndex renders the board with details open beside it, which is what
+// a page gets when it is asked for without HTMX
+func (s *Server) renderIndex(w http.ResponseWriter, auth AuthContext, details any) {
+	s.renderIndexStatus(w, http.StatusOK, auth, details)
+}
+
+// renderIndexStatus is renderIndex answering with status, for warnings that
+// stop a change
+func (s *Server) renderIndexStatus(w http.ResponseWriter, status int, auth AuthContext, details any) {
+	cats, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	catViews := make([]CategoryView, len(cats))
+	for i, c := range cats {
+		catViews[i] = NewCategoryView(c, false, auth)
+	}
+	w.WriteHeader(status)
+	if err := s.presentation.RenderIndexWithDetails(w, catViews, auth, details); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -201,21 +813,62 @@ func filterPublicCategories(cats []*domain.Category) []*domain.Category {
 	return result
 }
 
-func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleSeed(w http.ResponseWriter, r *http.Request) {
 	auth, ok := s.requireAuth(w, r)
 	if !ok {
 		return
 	}
 
 	ctx := parseRequestContext(r)
-	cat, err := s.store.AddCategory("New Category")
+	if err := s.store.Seed(auth.Handle); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/")
+		return
+	}
+
+	cats, err := s.store.GetCategories()
 	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	catViews := make([]CategoryView, len(cats))
+	for i, c := range cats {
+		catViews[i] = NewCategoryView(c, false, auth)
+	}
+
+	if err := s.presentation.RenderCategoryList(w, catViews, auth); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = "New Category"
+	}
+	cat, err := s.store.AddCategory(name, auth.Handle)
+	if err != nil {
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		created(w, "/categories/"+cat.ID, cat)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/categories/"+cat.ID+"/details")
 		return
 	}
 
@@ -240,32 +893,51 @@ func (s *Server) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	cat, err := s.store.GetCategory(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if name := r.FormValue("name"); name != "" {
-		cat.Name = name
-	} else if desc := r.FormValue("description"); desc != "" {
-		cat.Description = desc
-	} else {
-		// Public toggle form - checkbox sends "on" when checked, nothing when unchecked
-		cat.Public = r.FormValue("public") == "on"
+	patch := newFormPatch(r.PostForm)
+	if err := patch.Name("name", &cat.Name); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if mine, conflict := patch.TextSince("description", &cat.Description); conflict {
+		s.renderDescriptionConflict(w, r, NewDescriptionMergeView(cat.ID, cat.Name, "/categories/"+cat.ID, "/categories/"+cat.ID+"/details", mine, cat.Description, auth))
+		return
+	}
+	patch.Checkbox("public", &cat.Public)
+	patch.Checkbox("weekly_digest", &cat.WeeklyDigest)
+	requiresReview := cat.RequiresReview
+	patch.Checkbox("requires_review", &cat.RequiresReview)
+	if cat.RequiresReview != requiresReview && !canApprove(auth, cat) {
+		apiError(w, r, http.StatusForbidden, "Only the category's owner and admins can change whether its tasks are reviewed")
+		return
+	}
+	patch.Text("color", &cat.Color)
+	patch.Text("icon", &cat.Icon)
+	if err := patch.Int("wip_limit", &cat.WIPLimit); err != nil {
+		apiStoreError(w, r, err)
+		return
 	}
 
-	cat, err = s.store.UpdateCategory(cat)
+	cat, err = s.store.UpdateCategory(cat, auth.Handle)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, cat)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 
@@ -283,46 +955,62 @@ func (s *Server) handleGetCategoryDetails(w http.ResponseWriter, r *http.Request
 
 	cat, err := s.store.GetCategory(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
-	// Private items are not accessible to unauthenticated users
-	if !auth.IsAuthenticated && !cat.Public {
-		http.Error(w, "Not found", http.StatusNotFound)
+	// Private items are not accessible to unauthenticated users, nor
+	// categories shared with others to anyone else
+	if !auth.IsAuthenticated && !cat.Public || !auth.CanSee(cat.ID) {
+		apiError(w, r, http.StatusNotFound, "Not found")
 		return
 	}
 
 	// Fetch work logs for category
 	workLogs, err := s.store.GetWorkLogsForCategory(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	cat.WorkLogs = workLogs
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, cat)
+		return
+	}
+
+	view := NewCategoryView(cat, false, auth)
+	if auth.IsAuthenticated && cat.ShareToken != "" {
+		view.Embed = NewEmbedView(baseURL(r), cat.ShareToken, cat.Name)
+	}
+	if auth.IsAuthenticated {
+		access, err := s.store.GetCategoryAccess(id)
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		owner := ""
+		if s.privateBoards {
+			owner = cat.OwnerID
+		}
+		var groups []*domain.Group
+		if auth.IsAdmin || owner != "" && owner == auth.Handle {
+			if groups, err = s.store.GetGroups(); err != nil {
+				apiStoreError(w, r, err)
+				return
+			}
+		}
+		view.Access = NewCategoryAccessView(access, groups, owner, auth)
+	}
 
 	if ctx.IsHTMX {
-		if err := s.presentation.RenderCategoryDetails(w, NewCategoryView(cat, false, auth)); err != nil {
+		if err := s.presentation.RenderCategoryDetails(w, view); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// Deep Linking: Render full page with details open
-	cats, err := s.store.GetCategories()
-	if err != nil {
-		http.Error(w, "Failed to load categories", http.StatusInternalServerError)
-		return
-	}
-
-	catViews := make([]CategoryView, len(cats))
-	for i, c := range cats {
-		catViews[i] = NewCategoryView(c, false, auth)
-	}
-
-	if err := s.presentation.RenderIndexWithDetails(w, catViews, auth, NewCategoryView(cat, false, auth)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	s.renderIndex(w, auth, view)
 }
 
 func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
@@ -334,21 +1022,42 @@ func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	ctx := parseRequestContext(r)
 	catID := r.PathValue("id")
 
-	task, err := s.store.AddTask(catID, "New Task")
+	if err := r.ParseForm(); err != nil {
+		apiError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	name := newTaskName(r.PostForm.Get("name"))
+	if r.PostForm.Get("name") != "" && s.duplicatesFound(w, r, auth, catID, name) {
+		return
+	}
+
+	task, err := s.store.AddTask(catID, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
+	// Keep the new task in view when the board is filtered to a context
+	if auth.Context != "" {
+		if task.Contexts, err = s.store.SetTaskContexts(task.ID, []string{auth.Context}); err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		auth.inContext[task.ID] = true
+	}
 
+	if ctx.WantsJSON {
+		created(w, "/tasks/"+task.ID, task)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/tasks/"+task.ID+"/details")
 		return
 	}
 
 	// Re-fetch category and render it as OOB
 	cat, err := s.store.GetCategory(catID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
@@ -377,45 +1086,69 @@ func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.store.GetTask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Handle form field updates - only one field per form submission
-	if name := r.FormValue("name"); name != "" {
-		task.Name = name
-	} else if desc := r.FormValue("description"); desc != "" {
-		task.Description = desc
-	} else if comp := r.FormValue("completion"); comp != "" {
-		val, err := strconv.Atoi(comp)
-		if err == nil {
-			task.Completion = val
-		}
-	} else {
-		// Public toggle form
-		task.Public = r.FormValue("public") == "on"
+	started := task.InProgress()
+	patch := newFormPatch(r.PostForm)
+	if err := patch.Name("name", &task.Name); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if mine, conflict := patch.TextSince("description", &task.Description); conflict {
+		s.renderDescriptionConflict(w, r, NewDescriptionMergeView(task.ID, task.Name, "/tasks/"+task.ID, "/tasks/"+task.ID+"/details", mine, task.Description, auth))
+		return
+	}
+	patch.Text("start_date", &task.StartDate)
+	patch.Text("due_date", &task.DueDate)
+	patch.Text("size", &task.Size)
+	patch.Text("flag", &task.Flag)
+	if err := patch.Int("priority", &task.Priority); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if err := patch.Float("estimate", &task.Estimate); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if err := patch.Int("completion", &task.Completion); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	patch.Checkbox("public", &task.Public)
+	patch.Checkbox("subtask_checkboxes", &task.SubtaskCheckboxes)
+	patch.Checkbox("blocked", &task.Blocked)
+	patch.Text("blocked_reason", &task.BlockedReason)
+	patch.Text("waiting_on", &task.WaitingOn)
+	if !started && task.InProgress() && len(task.Subtasks) == 0 && s.wipExceeded(w, r, auth, task.CategoryID) {
+		return
 	}
 
-	task, err = s.store.UpdateTask(task)
+	task, err = s.store.UpdateTask(task, auth.Handle)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, task)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 
 	// Re-fetch category and render it as OOB
 	cat, err := s.store.GetCategory(task.CategoryID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
@@ -435,21 +1168,27 @@ func (s *Server) handleGetSubtaskDetails(w http.ResponseWriter, r *http.Request)
 
 	sub, err := s.store.GetSubtask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	// Fetch work logs for subtask
 	workLogs, err := s.store.GetWorkLogsForSubtask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	sub.WorkLogs = workLogs
 
-	// Private items are not accessible to unauthenticated users
-	if !auth.IsAuthenticated && !sub.ParentPublic {
-		http.Error(w, "Not found", http.StatusNotFound)
+	// Private items are not accessible to unauthenticated users, nor
+	// categories shared with others to anyone else
+	if !auth.IsAuthenticated && !sub.ParentPublic || !auth.CanSee(sub.CategoryID) {
+		apiError(w, r, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, sub)
 		return
 	}
 
@@ -463,20 +1202,7 @@ func (s *Server) handleGetSubtaskDetails(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Deep Linking: Render full page with details open
-	cats, err := s.store.GetCategories()
-	if err != nil {
-		http.Error(w, "Failed to load categories", http.StatusInternalServerError)
-		return
-	}
-
-	catViews := make([]CategoryView, len(cats))
-	for i, c := range cats {
-		catViews[i] = NewCategoryView(c, false, auth)
-	}
-
-	if err := s.presentation.RenderIndexWithDetails(w, catViews, auth, subtaskView); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	s.renderIndex(w, auth, subtaskView)
 }
 
 func (s *Server) handleGetTaskDetails(w http.ResponseWriter, r *http.Request) {
@@ -486,25 +1212,41 @@ func (s *Server) handleGetTaskDetails(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.store.GetTask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	// Fetch work logs for task
 	workLogs, err := s.store.GetWorkLogsForTask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	task.WorkLogs = workLogs
 
-	// Private items are not accessible to unauthenticated users
-	if !auth.IsAuthenticated && (!task.ParentPublic || !task.Public) {
-		http.Error(w, "Not found", http.StatusNotFound)
+	// Private items are not accessible to unauthenticated users, nor
+	// categories shared with others to anyone else
+	if !auth.IsAuthenticated && (!task.ParentPublic || !task.Public) || !auth.CanSee(task.CategoryID) {
+		apiError(w, r, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, task)
 		return
 	}
 
 	taskView := NewTaskView(task, false, auth)
+	taskView.Queued = s.isQueued(auth, id)
+	if r.URL.Query().Has("reforecast") && auth.IsAuthenticated {
+		taskView.Reforecast = newReforecastView(task, auth)
+	}
+	taskView.KeyResults, taskView.KeyResultOptions = s.taskKeyResults(auth, id)
+	if cat, err := s.store.GetCategory(task.CategoryID); err == nil {
+		taskView.planAmong(task, cat.Tasks)
+		taskView.Color = cat.Color
+		taskView.CanApprove = canApprove(auth, cat)
+	}
 
 	if ctx.IsHTMX {
 		if err := s.presentation.RenderTaskDetails(w, taskView); err != nil {
@@ -514,20 +1256,7 @@ func (s *Server) handleGetTaskDetails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Deep Linking: Render full page with details open
-	cats, err := s.store.GetCategories()
-	if err != nil {
-		http.Error(w, "Failed to load categories", http.StatusInternalServerError)
-		return
-	}
-
-	catViews := make([]CategoryView, len(cats))
-	for i, c := range cats {
-		catViews[i] = NewCategoryView(c, false, auth)
-	}
-
-	if err := s.presentation.RenderIndexWithDetails(w, catViews, auth, taskView); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	s.renderIndex(w, auth, taskView)
 }
 
 func (s *Server) handleCreateSubtask(w http.ResponseWriter, r *http.Request) {
@@ -539,21 +1268,29 @@ func (s *Server) handleCreateSubtask(w http.ResponseWriter, r *http.Request) {
 	ctx := parseRequestContext(r)
 	taskID := r.PathValue("id")
 
-	sub, err := s.store.AddSubtask(taskID, "New Subtask")
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = "New Subtask"
+	}
+	sub, err := s.store.AddSubtask(taskID, name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		created(w, "/subtasks/"+sub.ID, sub)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/subtasks/"+sub.ID+"/details")
 		return
 	}
 
 	// Fetch parent category and render it as OOB
 	cat, err := s.store.GetCategory(sub.CategoryID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
@@ -581,45 +1318,57 @@ func (s *Server) handleUpdateSubtask(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	sub, err := s.store.GetSubtask(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Handle form field updates - only one field per form submission
-	if name := r.FormValue("name"); name != "" {
-		sub.Name = name
-	} else if desc := r.FormValue("description"); desc != "" {
-		sub.Description = desc
-	} else if comp := r.FormValue("completion"); comp != "" {
-		val, err := strconv.Atoi(comp)
-		if err == nil {
-			sub.Completion = val
-		}
-	} else {
-		// Public toggle form
-		sub.Public = r.FormValue("public") == "on"
+	patch := newFormPatch(r.PostForm)
+	if err := patch.Name("name", &sub.Name); err != nil {
+		apiStoreError(w, r, err)
+		return
 	}
-
-	sub, err = s.store.UpdateSubtask(sub)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if mine, conflict := patch.TextSince("description", &sub.Description); conflict {
+		s.renderDescriptionConflict(w, r, NewDescriptionMergeView(sub.ID, sub.Name, "/subtasks/"+sub.ID, "/subtasks/"+sub.ID+"/details", mine, sub.Description, auth))
 		return
 	}
-
-	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	if err := patch.Int("completion", &sub.Completion); err != nil {
+		apiStoreError(w, r, err)
 		return
 	}
-
+	if err := patch.Float("estimate", &sub.Estimate); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	patch.Text("due_date", &sub.DueDate)
+	patch.Checkbox("public", &sub.Public)
+	if sub.Completion > 0 && s.startsTask(sub.TaskID) && s.wipExceeded(w, r, auth, sub.CategoryID) {
+		return
+	}
+
+	sub, err = s.store.UpdateSubtask(sub, auth.Handle)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, sub)
+		return
+	}
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/")
+		return
+	}
+
 	// Fetch parent category and render it as OOB
 	cat, err := s.store.GetCategory(sub.CategoryID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
@@ -632,14 +1381,43 @@ func (s *Server) handleUpdateSubtask(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+// storeError writes the response for a failed store call. Every handler goes
+// through here so that the same typed domain error always gets the same
+// status, rather than each route guessing.
+func storeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), storeErrorStatus(err))
+}
+
+// storeErrorStatus maps a store failure to a response code. Missing entities,
+// invalid input, and conflicts are the client's problem; anything else is ours.
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrStaleOrder), errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON answers an API client with v
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
 func (s *Server) handleReorderCategories(w http.ResponseWriter, r *http.Request) {
-	if _, ok := s.requireAuth(w, r); !ok {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
 		return
 	}
 
 	ctx := parseRequestContext(r)
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -648,13 +1426,26 @@ func (s *Server) handleReorderCategories(w http.ResponseWriter, r *http.Request)
 		return // Nothing to do
 	}
 
+	if len(auth.hidden) > 0 {
+		current, err := s.store.GetCategories()
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		ids = withHiddenInPlace(current, ids, auth)
+	}
+
 	if err := s.store.ReorderCategories(ids); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -667,7 +1458,7 @@ func (s *Server) handleReorderTasks(w http.ResponseWriter, r *http.Request) {
 
 	ctx := parseRequestContext(r)
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -678,12 +1469,16 @@ func (s *Server) handleReorderTasks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.ReorderTasks(catID, ids); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -696,7 +1491,7 @@ func (s *Server) handleReorderSubtasks(w http.ResponseWriter, r *http.Request) {
 
 	ctx := parseRequestContext(r)
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -704,38 +1499,319 @@ func (s *Server) handleReorderSubtasks(w http.ResponseWriter, r *http.Request) {
 	ids := r.Form["id"]
 
 	if err := s.store.ReorderSubtasks(taskID, ids); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleMoveCategory(w http.ResponseWriter, r *http.Request) {
 	if _, ok := s.requireAuth(w, r); !ok {
 		return
 	}
 
-	ctx := parseRequestContext(r)
 	id := r.PathValue("id")
+	cats, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
 
-	if _, err := s.store.DeleteCategory(id); err != nil {
+	ids := make([]string, len(cats))
+	for i, c := range cats {
+		ids[i] = c.ID
+	}
+	ids, ok := moveID(ids, id, r.FormValue("direction"))
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.store.ReorderCategories(ids); err != nil {
+		storeError(w, err)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+func (s *Server) handleMoveTask(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	task, err := s.store.GetTask(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	cat, err := s.store.GetCategory(task.CategoryID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	ids := make([]string, len(cat.Tasks))
+	for i, t := range cat.Tasks {
+		ids[i] = t.ID
+	}
+	ids, _ = moveID(ids, id, r.FormValue("direction"))
+
+	if err := s.store.ReorderTasks(cat.ID, ids); err != nil {
+		storeError(w, err)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+func (s *Server) handleMoveSubtask(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	sub, err := s.store.GetSubtask(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	task, err := s.store.GetTask(sub.TaskID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	ids := make([]string, len(task.Subtasks))
+	for i, st := range task.Subtasks {
+		ids[i] = st.ID
+	}
+	ids, _ = moveID(ids, id, r.FormValue("direction"))
+
+	if err := s.store.ReorderSubtasks(task.ID, ids); err != nil {
+		storeError(w, err)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+// moveID swaps id with its neighbour in the given direction ("up" or "down").
+// Moving past either end leaves the order unchanged. Returns false if id is
+// not in ids.
+func moveID(ids []string, id string, direction string) ([]string, bool) {
+	for i, candidate := range ids {
+		if candidate != id {
+			continue
+		}
+		j := i + 1
+		if direction == "up" {
+			j = i - 1
+		}
+		if j >= 0 && j < len(ids) {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+		return ids, true
+	}
+	return ids, false
+}
+
+func (s *Server) handleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := s.store.GetPreferences(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	patch := newFormPatch(r.PostForm)
+	patch.Checkbox("accessible", &prefs.Accessible)
+	patch.Checkbox("multiple_timers", &prefs.MultipleTimers)
+	patch.Text("display_name", &prefs.DisplayName)
+	patch.Text("timezone", &prefs.Timezone)
+	prefs.Timezone = strings.TrimSpace(prefs.Timezone)
+	patch.Text("language", &prefs.Language)
+	patch.Text("context", &prefs.Context)
+	patch.Text("email", &prefs.Email)
+	patch.Text("clip_category", &prefs.ClipCategory)
+	if err := patch.Int("digest_hour", &prefs.DigestHour); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := patch.Float("weekly_capacity", &prefs.WeeklyCapacity); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := patch.Int("idle_minutes", &prefs.IdleMinutes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, kind := range notify.Kinds {
+		for _, channel := range s.notifier.Channels() {
+			var mode string
+			patch.Text(deliveryField(kind, channel), &mode)
+			if mode != "" {
+				prefs.Notifications.Set(kind, channel, mode)
+			}
+		}
+	}
+
+	saved, err := s.store.UpdatePreferences(prefs)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if r.PostForm.Has("language") {
+		setLanguageCookie(w, r, saved.Language)
+	}
+	s.profiles.Invalidate(auth.Handle)
+	redirectBack(w, r, "/")
+}
+
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
+
+// renderSettings answers with the settings slideover, or for a plain
+// request the board with settings open. A newly created hook, whose URLs
+// cannot be shown again, is revealed if given.
+func (s *Server) renderSettings(w http.ResponseWriter, r *http.Request, auth AuthContext, newHook *NewHookView) {
+	ctx := parseRequestContext(r)
+
+	prefs, err := s.store.GetPreferences(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	hooks, err := s.store.GetHookTokens(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	statsToken, err := s.store.GetStatsToken(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	// Push services only get a column for delivery once they are set up
+	services := map[string]*domain.NotifyService{}
+	var channels []string
+	for _, name := range s.notifier.Channels() {
+		if name != domain.ServiceNtfy && name != domain.ServiceGotify {
+			channels = append(channels, name)
+			continue
+		}
+		svc, err := s.store.GetNotifyService(auth.Handle, name)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		} else if err != nil {
+			storeError(w, err)
+			return
+		}
+		services[name] = svc
+		channels = append(channels, name)
+	}
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	now := s.clock.Now()
+	view := SettingsView{
+		AuthContext: auth,
+		DisplayName: prefs.DisplayName,
+		Timezone:    prefs.Timezone,
+		Languages:   newLanguageOptions(prefs.Language),
+		Capacity:    formatCapacity(prefs.WeeklyCapacity),
+		IdleMinutes: prefs.IdleMinutes,
+		Profile:     s.profiles.Resolve(auth.Handle),
+		LocalTime:   auth.FormatDateTime(now) + now.In(auth.Location()).Format(" MST"),
+		Hooks:       newHookTokenViews(hooks, categories, auth),
+		NewHook:     newHook,
+		BoardEmpty:  len(categories) == 0,
+		Services:    newNotifyServiceViews(services, auth),
+
+		ClipCategory:        prefs.ClipCategory,
+		DefaultClipCategory: domain.DefaultClipCategory,
+	}
+	for _, c := range categories {
+		if auth.CanSee(c.ID) {
+			view.HookCategories = append(view.HookCategories, CategoryOption{ID: c.ID, Name: c.Name})
+		}
+	}
+	if statsToken != "" {
+		view.StatsURL = baseURL(r) + "/stats.json?token=" + statsToken
+	}
+	if s.push != nil {
+		view.PushKey = s.push.PublicKey()
+	}
+	if s.mail != nil {
+		view.MailEnabled = true
+		view.Email = prefs.Email
+	}
+	view.setNotifications(prefs, channels)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderSettings(w, view); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	entry, err := s.store.DeleteCategory(id, auth.Handle)
+	if err != nil {
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, entry)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/?undo="+entry.ID)
 		return
 	}
 
-	if err := s.presentation.RenderCategoryDeleteOOB(w, id); err != nil {
+	var buf bytes.Buffer
+	if err := s.presentation.RenderCategoryDeleteOOB(&buf, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.presentation.RenderUndoToast(&buf, NewUndoView(entry, auth, s.clock.Now())); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Write(buf.Bytes())
 }
 
 func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
@@ -747,79 +1823,311 @@ func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	ctx := parseRequestContext(r)
 	id := r.PathValue("id")
 
-	task, err := s.store.DeleteTask(id)
+	entry, err := s.store.DeleteTask(id, auth.Handle)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, entry)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/?undo="+entry.ID)
 		return
 	}
 
-	// Re-fetch category after deletion and render it as OOB
-	cat, err := s.store.GetCategory(task.CategoryID)
+	s.renderDeletedChild(w, entry, auth)
+}
+
+func (s *Server) handleDeleteSubtask(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	entry, err := s.store.DeleteSubtask(id, auth.Handle)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, entry)
+		return
+	}
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/?undo="+entry.ID)
+		return
+	}
+
+	s.renderDeletedChild(w, entry, auth)
+}
+
+// renderDeletedChild re-renders the category a task or subtask was deleted
+// from, closes the slideover, and offers the undo toast.
+func (s *Server) renderDeletedChild(w http.ResponseWriter, entry *domain.TrashEntry, auth AuthContext) {
+	cat, err := s.store.GetCategory(entry.CategoryID)
+	if err != nil {
+		storeError(w, err)
 		return
 	}
 
-	s.presentation.RenderSlideoverClear(w)
-	catView := NewCategoryView(cat, true, auth)
 	var buf bytes.Buffer
+	if err := s.presentation.RenderSlideoverClear(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	catView := NewCategoryView(cat, true, auth)
 	if err := s.presentation.RenderCategoryOOB(&buf, catView); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := s.presentation.RenderUndoToast(&buf, NewUndoView(entry, auth, s.clock.Now())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Write(buf.Bytes())
 }
 
-func (s *Server) handleDeleteSubtask(w http.ResponseWriter, r *http.Request) {
+// handleUndo restores a deletion from the trash. Only the person who deleted
+// the entity can undo it, and only within undoTTL.
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
 	auth, ok := s.requireAuth(w, r)
 	if !ok {
 		return
 	}
 
 	ctx := parseRequestContext(r)
-	id := r.PathValue("id")
+	token := r.PathValue("token")
 
-	sub, err := s.store.DeleteSubtask(id)
+	entry, err := s.store.GetTrashEntry(token)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "not found") {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
+		return
+	}
+	if NewUndoView(entry, auth, s.clock.Now()) == nil {
+		apiError(w, r, http.StatusGone, "This deletion can no longer be undone")
+		return
+	}
+
+	if _, err := s.store.RestoreTrashEntry(token, auth.Handle); err != nil {
+		apiStoreError(w, r, err)
 		return
 	}
 
+	if ctx.WantsJSON {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/")
 		return
 	}
 
-	// Re-fetch category after deletion and render it as OOB
-	cat, err := s.store.GetCategory(sub.CategoryID)
+	cats, err := s.store.GetCategories()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
+	catViews := make([]CategoryView, len(cats))
+	for i, c := range cats {
+		catViews[i] = NewCategoryView(c, false, auth)
+	}
 
-	s.presentation.RenderSlideoverClear(w)
-	catView := NewCategoryView(cat, true, auth)
 	var buf bytes.Buffer
-	if err := s.presentation.RenderCategoryOOB(&buf, catView); err != nil {
+	if err := s.presentation.RenderCategoryList(&buf, catViews, auth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.presentation.RenderUndoToast(&buf, nil); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Write(buf.Bytes())
 }
 
+// handleGetHistory lists the revisions of an entity of the given type
+func (s *Server) handleGetHistory(entityType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Past versions may hold private text, so history is never public
+		auth := s.getAuthContext(w, r)
+		if !auth.IsAuthenticated {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		ctx := parseRequestContext(r)
+		id := r.PathValue("id")
+		if categoryID, err := s.store.GetOwningCategoryID(entityType, id); err == nil && !auth.CanSee(categoryID) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		var title, detailsURL string
+		switch entityType {
+		case domain.EntityCategory:
+			cat, err := s.store.GetCategory(id)
+			if err != nil {
+				storeError(w, err)
+				return
+			}
+			title, detailsURL = cat.Name, "/categories/"+id+"/details"
+		case domain.EntityTask:
+			task, err := s.store.GetTask(id)
+			if err != nil {
+				storeError(w, err)
+				return
+			}
+			title, detailsURL = task.Name, "/tasks/"+id+"/details"
+		case domain.EntitySubtask:
+			sub, err := s.store.GetSubtask(id)
+			if err != nil {
+				storeError(w, err)
+				return
+			}
+			title, detailsURL = sub.Name, "/subtasks/"+id+"/details"
+		}
+
+		revisions, err := s.store.GetRevisions(entityType, id)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		view := NewHistoryView(title, detailsURL, revisions, auth)
+
+		if ctx.IsHTMX {
+			if err := s.presentation.RenderHistory(w, view); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		s.renderIndex(w, auth, view)
+	}
+}
+
+// handleRestoreRevision writes a revision's fields back onto its entity.
+// The restore is an ordinary update, so it is itself recorded as a revision.
+func (s *Server) handleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	revID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision id", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := s.store.GetRevision(revID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	var catID string
+	var details any
+	switch rev.EntityType {
+	case domain.EntityCategory:
+		cat, err := s.store.GetCategory(rev.EntityID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		cat.Name, cat.Description, cat.Public = rev.Name, rev.Description, rev.Public
+		if _, err := s.store.UpdateCategory(cat, auth.Handle); err != nil {
+			storeError(w, err)
+			return
+		}
+		catID = cat.ID
+
+	case domain.EntityTask:
+		task, err := s.store.GetTask(rev.EntityID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		task.Name, task.Description, task.Completion, task.Public = rev.Name, rev.Description, rev.Completion, rev.Public
+		if _, err := s.store.UpdateTask(task, auth.Handle); err != nil {
+			storeError(w, err)
+			return
+		}
+		catID = task.CategoryID
+
+	case domain.EntitySubtask:
+		sub, err := s.store.GetSubtask(rev.EntityID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		sub.Name, sub.Description, sub.Completion, sub.Public = rev.Name, rev.Description, rev.Completion, rev.Public
+		if _, err := s.store.UpdateSubtask(sub, auth.Handle); err != nil {
+			storeError(w, err)
+			return
+		}
+		catID = sub.CategoryID
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/")
+		return
+	}
+
+	// Re-fetch the category and the open details so both show the restore
+	cat, err := s.store.GetCategory(catID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	switch rev.EntityType {
+	case domain.EntityCategory:
+		workLogs, err := s.store.GetWorkLogsForCategory(cat.ID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		withLogs := *cat
+		withLogs.WorkLogs = workLogs
+		details = NewCategoryView(&withLogs, false, auth)
+
+	case domain.EntityTask:
+		task, err := s.store.GetTask(rev.EntityID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		if task.WorkLogs, err = s.store.GetWorkLogsForTask(task.ID); err != nil {
+			storeError(w, err)
+			return
+		}
+		details = NewTaskView(task, false, auth)
+
+	case domain.EntitySubtask:
+		sub, err := s.store.GetSubtask(rev.EntityID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		if sub.WorkLogs, err = s.store.GetWorkLogsForSubtask(sub.ID); err != nil {
+			storeError(w, err)
+			return
+		}
+		details = NewSubtaskView(sub, false, auth)
+	}
+
+	if err := s.presentation.RenderCategoryWithDetails(w, NewCategoryView(cat, true, auth), details); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (s *Server) handleCreateTaskWorkLog(w http.ResponseWriter, r *http.Request) {
 	auth, ok := s.requireAuth(w, r)
 	if !ok {
@@ -830,19 +2138,19 @@ func (s *Server) handleCreateTaskWorkLog(w http.ResponseWriter, r *http.Request)
 	taskID := r.PathValue("id")
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid form data")
 		return
 	}
 
 	hoursWorked, err := strconv.ParseFloat(r.FormValue("hours_worked"), 64)
 	if err != nil {
-		http.Error(w, "Invalid hours_worked value", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid hours_worked value", FieldError{Field: "hours_worked", Message: "must be a number"})
 		return
 	}
 
 	completionEstimate, err := strconv.Atoi(r.FormValue("completion_estimate"))
 	if err != nil {
-		http.Error(w, "Invalid completion_estimate value", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid completion_estimate value", FieldError{Field: "completion_estimate", Message: "must be a whole number"})
 		return
 	}
 
@@ -852,51 +2160,61 @@ func (s *Server) handleCreateTaskWorkLog(w http.ResponseWriter, r *http.Request)
 	var customTime *time.Time
 	if r.FormValue("use_custom_time") == "on" {
 		if ct := r.FormValue("custom_time"); ct != "" {
-			if parsed, err := time.ParseInLocation("2006-01-02T15:04", ct, time.Local); err == nil {
+			if parsed, err := time.ParseInLocation("2006-01-02T15:04", ct, auth.Location()); err == nil {
 				customTime = &parsed
 			}
 		}
 	}
 
-	workLog, err := s.store.AddWorkLogForTask(taskID, hoursWorked, workDescription, completionEstimate, customTime)
+	if task, err := s.store.GetTask(taskID); err == nil && !task.InProgress() && completionEstimate > 0 && completionEstimate < 100 && s.wipExceeded(w, r, auth, task.CategoryID) {
+		return
+	}
+
+	workLog, err := s.store.AddWorkLogForTask(taskID, hoursWorked, workDescription, completionEstimate, customTime, auth.Handle)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
+	s.notifyWorkLogged(auth, workLog)
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusCreated, workLog)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		// Plain forms are asked for a new estimate on the details page
+		if s.needsReforecast(taskID) {
+			http.Redirect(w, r, "/tasks/"+taskID+"/details?reforecast=1", http.StatusSeeOther)
+			return
+		}
+		redirectBack(w, r, "/tasks/"+taskID+"/details")
 		return
 	}
 
-	// Re-fetch category and render as OOB
+	// Re-fetch the category and the open details so both reflect the new log
 	cat, err := s.store.GetCategory(workLog.CategoryID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	catView := NewCategoryView(cat, true, auth)
-	if err := s.presentation.RenderCategoryOOB(w, catView); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	// Re-fetch task with work logs and render slideover OOB update
 	task, err := s.store.GetTask(taskID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	taskWorkLogs, err := s.store.GetWorkLogsForTask(taskID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	task.WorkLogs = taskWorkLogs
 
 	taskView := NewTaskView(task, false, auth)
-	if err := s.presentation.RenderSlideoverWithDetails(w, taskView); err != nil {
+	taskView.Reforecast = newReforecastView(task, auth)
+	if err := s.presentation.RenderCategoryWithDetails(w, catView, taskView); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -911,19 +2229,19 @@ func (s *Server) handleCreateSubtaskWorkLog(w http.ResponseWriter, r *http.Reque
 	subtaskID := r.PathValue("id")
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid form data")
 		return
 	}
 
 	hoursWorked, err := strconv.ParseFloat(r.FormValue("hours_worked"), 64)
 	if err != nil {
-		http.Error(w, "Invalid hours_worked value", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid hours_worked value", FieldError{Field: "hours_worked", Message: "must be a number"})
 		return
 	}
 
 	completionEstimate, err := strconv.Atoi(r.FormValue("completion_estimate"))
 	if err != nil {
-		http.Error(w, "Invalid completion_estimate value", http.StatusBadRequest)
+		apiError(w, r, http.StatusBadRequest, "Invalid completion_estimate value", FieldError{Field: "completion_estimate", Message: "must be a whole number"})
 		return
 	}
 
@@ -933,51 +2251,106 @@ func (s *Server) handleCreateSubtaskWorkLog(w http.ResponseWriter, r *http.Reque
 	var customTime *time.Time
 	if r.FormValue("use_custom_time") == "on" {
 		if ct := r.FormValue("custom_time"); ct != "" {
-			if parsed, err := time.ParseInLocation("2006-01-02T15:04", ct, time.Local); err == nil {
+			if parsed, err := time.ParseInLocation("2006-01-02T15:04", ct, auth.Location()); err == nil {
 				customTime = &parsed
 			}
 		}
 	}
 
-	workLog, err := s.store.AddWorkLogForSubtask(subtaskID, hoursWorked, workDescription, completionEstimate, customTime)
+	if sub, err := s.store.GetSubtask(subtaskID); err == nil && completionEstimate > 0 && s.startsTask(sub.TaskID) && s.wipExceeded(w, r, auth, sub.CategoryID) {
+		return
+	}
+
+	workLog, err := s.store.AddWorkLogForSubtask(subtaskID, hoursWorked, workDescription, completionEstimate, customTime, auth.Handle)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
+	s.notifyWorkLogged(auth, workLog)
 
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusCreated, workLog)
+		return
+	}
 	if !ctx.IsHTMX {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		redirectBack(w, r, "/subtasks/"+subtaskID+"/details")
 		return
 	}
 
-	// Re-fetch category and render as OOB
+	// Re-fetch the category and the open details so both reflect the new log
 	cat, err := s.store.GetCategory(workLog.CategoryID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 
 	catView := NewCategoryView(cat, true, auth)
-	if err := s.presentation.RenderCategoryOOB(w, catView); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	// Re-fetch subtask with work logs and render slideover OOB update
 	sub, err := s.store.GetSubtask(subtaskID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	subWorkLogs, err := s.store.GetWorkLogsForSubtask(subtaskID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apiStoreError(w, r, err)
 		return
 	}
 	sub.WorkLogs = subWorkLogs
 
 	subtaskView := NewSubtaskView(sub, false, auth)
-	if err := s.presentation.RenderSlideoverWithDetails(w, subtaskView); err != nil {
+	if err := s.presentation.RenderCategoryWithDetails(w, catView, subtaskView); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// handleUpdateWorkLog edits a work log, or in ledger mode records the change
+// as an adjustment entry. The open details panel refreshes itself on the
+// detailsChanged event rather than this handler guessing which panel it is.
+func (s *Server) handleUpdateWorkLog(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		apiError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	hoursWorked, err := strconv.ParseFloat(r.FormValue("hours_worked"), 64)
+	if err != nil || hoursWorked < 0 {
+		apiError(w, r, http.StatusBadRequest, "Invalid hours_worked value", FieldError{Field: "hours_worked", Message: "must be a number, at least zero"})
+		return
+	}
+	workDescription := r.FormValue("work_description")
+
+	var wl *domain.WorkLog
+	if s.ledger {
+		wl, err = s.store.CorrectWorkLog(id, hoursWorked, workDescription, auth.Handle)
+	} else {
+		wl, err = s.store.UpdateWorkLog(id, hoursWorked, workDescription, auth.Handle)
+	}
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, wl)
+		return
+	}
+	if !ctx.IsHTMX {
+		fallback := "/tasks/" + wl.TaskID + "/details"
+		if wl.SubtaskID != "" {
+			fallback = "/subtasks/" + wl.SubtaskID + "/details"
+		}
+		redirectBack(w, r, fallback)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func TestPagesRender(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{Admins: []string{"ana"}})
+	c := ts.category("ana", "Garden")
+	ts.task(c.ID, "Turn the compost")
+
+	pages := []string{
+		"/",
+		"/settings",
+		"/m/log",
+		"/search?q=compost",
+		"/queue",
+		"/plan/2026-03-02",
+		"/workload",
+		"/goals/2026-Q1",
+		"/tags",
+		"/blocked",
+		"/work-sessions",
+		"/suggest",
+		"/notifications",
+		"/groups",
+	}
+	for _, page := range pages {
+		t.Run(page, func(t *testing.T) {
+			w := ts.do("ana", http.MethodGet, page, nil)
+			expect(t, w, http.StatusOK)
+			if !strings.Contains(w.Body.String(), "<html") {
+				t.Errorf("a direct visit should get a whole page")
+			}
+		})
+	}
+}
+
+func TestSignedOutChangesAreRefused(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	c := ts.category("ana", "Garden")
+	task := ts.task(c.ID, "Turn the compost")
+
+	changes := []struct{ method, target string }{
+		{http.MethodPost, "/categories"},
+		{http.MethodPatch, "/categories/" + c.ID},
+		{http.MethodDelete, "/categories/" + c.ID},
+		{http.MethodPost, "/categories/" + c.ID + "/tasks"},
+		{http.MethodPatch, "/tasks/" + task.ID},
+		{http.MethodDelete, "/tasks/" + task.ID},
+		{http.MethodPost, "/tasks/" + task.ID + "/subtasks"},
+		{http.MethodPost, "/tasks/" + task.ID + "/work-logs"},
+	}
+	for _, tc := range changes {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			w := ts.htmx("", tc.method, tc.target, url.Values{"name": {"x"}})
+			expect(t, w, http.StatusUnauthorized)
+			if w.Header().Get("HX-Redirect") == "" {
+				t.Errorf("htmx should be sent to sign in")
+			}
+		})
+	}
+}
+
+func TestWrongCSRFIsRefused(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+
+	r := ts.request("ana", http.MethodPost, "/categories", url.Values{"name": {"Garden"}})
+	r.Header.Set(csrfHeader, ts.csrf("bea"))
+	expect(t, ts.serve(r), http.StatusForbidden)
+
+	categories, err := ts.store.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 0 {
+		t.Errorf("a refused request added %d categories", len(categories))
+	}
+}
+
+func TestMissingEntitiesAreNotFound(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	const missing = "00000000-0000-4000-8000-000000000000"
+
+	for _, target := range []string{
+		"/categories/" + missing + "/details",
+		"/tasks/" + missing + "/details",
+		"/subtasks/" + missing + "/details",
+	} {
+		t.Run(target, func(t *testing.T) {
+			expect(t, ts.api("ana", http.MethodGet, target, nil, nil), http.StatusNotFound)
+		})
+	}
+	expect(t, ts.api("ana", http.MethodPatch, "/tasks/"+missing, url.Values{"name": {"x"}}, nil), http.StatusNotFound)
+}
+
+// TestChangesAnswerEachClient checks that each change answers a browser
+// without scripts with a redirect, htmx with fragments to swap, and the API
+// with JSON
+func TestChangesAnswerEachClient(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	c := ts.category("ana", "Garden")
+	task := ts.task(c.ID, "Turn the compost")
+	sub, err := ts.store.AddSubtask(task.ID, "Find the fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		// fragments are the ids htmx is given to swap
+		fragments []string
+	}{
+		{"add category", http.MethodPost, "/categories", url.Values{"name": {"Kitchen"}}, []string{`class="category"`, `id="slideover-container"`}},
+		{"rename category", http.MethodPatch, "/categories/" + c.ID, url.Values{"name": {"Yard"}}, []string{`id="category-` + c.ID + `"`}},
+		{"add task", http.MethodPost, "/categories/" + c.ID + "/tasks", url.Values{"name": {"Weed"}, "duplicate_ok": {"on"}}, []string{`class="task-item`}},
+		{"update task", http.MethodPatch, "/tasks/" + task.ID, url.Values{"completion": {"40"}}, []string{`id="category-` + c.ID + `"`, `id="task-` + task.ID + `"`}},
+		{"add subtask", http.MethodPost, "/tasks/" + task.ID + "/subtasks", url.Values{"name": {"Oil the hinge"}}, []string{`class="row subtask"`}},
+		{"update subtask", http.MethodPatch, "/subtasks/" + sub.ID, url.Values{"name": {"Find the spade"}}, []string{`id="subtask-name-` + sub.ID + `"`}},
+		{"log work", http.MethodPost, "/tasks/" + task.ID + "/work-logs", url.Values{"hours_worked": {"1"}, "work_description": {"Turned half"}, "completion_estimate": {"50"}}, []string{`id="task-percent-` + task.ID + `"`}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ts.do("ana", tc.method, tc.target, tc.form)
+			expect(t, w, http.StatusSeeOther)
+			if w.Header().Get("Location") == "" {
+				t.Errorf("a browser without scripts should be sent back to a page")
+			}
+
+			w = ts.htmx("ana", tc.method, tc.target, tc.form)
+			expect(t, w, http.StatusOK)
+			for _, fragment := range tc.fragments {
+				if !strings.Contains(w.Body.String(), fragment) {
+					t.Errorf("htmx should be given %s", fragment)
+				}
+			}
+
+			w = ts.api("ana", tc.method, tc.target, tc.form, nil)
+			if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+				t.Fatalf("status %d, want 200 or 201: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("the API answered with %s", ct)
+			}
+		})
+	}
+}
+
+func TestDeleteAnswersEachClient(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	c := ts.category("ana", "Garden")
+
+	t.Run("browser", func(t *testing.T) {
+		task := ts.task(c.ID, "Turn the compost")
+		expect(t, ts.do("ana", http.MethodDelete, "/tasks/"+task.ID, nil), http.StatusSeeOther)
+		if _, err := ts.store.GetTask(task.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("looking up the deleted task: %v", err)
+		}
+	})
+	t.Run("htmx", func(t *testing.T) {
+		task := ts.task(c.ID, "Turn the compost")
+		w := ts.htmx("ana", http.MethodDelete, "/tasks/"+task.ID, nil)
+		expect(t, w, http.StatusOK)
+		if !strings.Contains(w.Body.String(), `id="category-`+c.ID+`"`) {
+			t.Errorf("htmx should be given the category again")
+		}
+		if strings.Contains(w.Body.String(), `id="task-`+task.ID+`"`) {
+			t.Errorf("the deleted task is still shown")
+		}
+		if _, err := ts.store.GetTask(task.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("looking up the deleted task: %v", err)
+		}
+	})
+}
+
+// TestWarningsAnswerEachClient checks that the checks a change can override
+// stop it the same way for every client
+func TestWarningsAnswerEachClient(t *testing.T) {
+	ts := newTestServer(t, ServerOptions{})
+	c := ts.category("ana", "Garden")
+	started := ts.task(c.ID, "Turn the compost")
+	started.Completion = 20
+	if _, err := ts.store.UpdateTask(started, "ana"); err != nil {
+		t.Fatal(err)
+	}
+	task := ts.task(c.ID, "Weed the beds")
+	c.WIPLimit = 1
+	if _, err := ts.store.UpdateCategory(c, "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		dialog string
+	}{
+		{"duplicate", http.MethodPost, "/categories/" + c.ID + "/tasks", url.Values{"name": {"Turn the compost"}}, `class="wip-warning duplicate-warning`},
+		{"wip limit", http.MethodPatch, "/tasks/" + task.ID, url.Values{"completion": {"10"}}, `class="wip-warning`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ts.do("ana", tc.method, tc.target, tc.form)
+			expect(t, w, http.StatusConflict)
+			if !strings.Contains(w.Body.String(), tc.dialog) || !strings.Contains(w.Body.String(), `="`+tc.target+`"`) {
+				t.Errorf("a browser without scripts should get the warning on a page that resends the change")
+			}
+
+			w = ts.htmx("ana", tc.method, tc.target, tc.form)
+			expect(t, w, http.StatusOK)
+			if w.Header().Get("HX-Retarget") != "body" || !strings.Contains(w.Body.String(), tc.dialog) {
+				t.Errorf("htmx should be given the warning to lay over the page")
+			}
+
+			expect(t, ts.api("ana", tc.method, tc.target, tc.form, nil), http.StatusConflict)
+		})
+	}
+
+	got, err := ts.store.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Completion != 0 {
+		t.Errorf("a stopped change went through")
+	}
+}
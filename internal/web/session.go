@@ -0,0 +1,125 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// renewBefore is how close to expiry an access token is renewed. The
+// notification bell polls every minute, so an open page keeps its session
+// alive without ever presenting an expired token.
+const renewBefore = 5 * time.Minute
+
+// csrfHeader carries a rotated CSRF token back to the page. Renewing a
+// session issues a new refresh token, and with it a new CSRF secret, so
+// tokens already rendered into the page stop working; app.js swaps them.
+const csrfHeader = "X-CSRF-Token"
+
+// headerWriter collects the headers a verifier sets, so they can be
+// inspected before any reach the response
+type headerWriter struct {
+	header http.Header
+}
+
+func (h *headerWriter) Header() http.Header         { return h.header }
+func (h *headerWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (h *headerWriter) WriteHeader(int)             {}
+
+// withSession renews sessions whose access token has expired or is about to,
+// on safe requests only. The fresh cookies are set on the response and also
+// substituted into the request, so the handler renders the new CSRF token
+// rather than the one about to be replaced. State-changing requests are
+// left to requireAuth, which must check the token the page submitted
+// against the old session before anything is renewed.
+func (s *Server) withSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !strings.HasPrefix(r.URL.Path, "/static/") {
+			if renewed := s.renewSession(r); len(renewed) > 0 {
+				for _, c := range renewed {
+					w.Header().Add("Set-Cookie", c)
+				}
+				replaceRequestCookies(r, renewed)
+				if _, csrf, err := s.auth.Verifier.VerifyAuthorizationGetCSRF(&headerWriter{header: http.Header{}}, r); err == nil {
+					w.Header().Set(csrfHeader, csrf)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// renewSession returns the Set-Cookie lines of a renewed session, or nothing
+// if the session needs no renewal or cannot be renewed
+func (s *Server) renewSession(r *http.Request) []string {
+	refresh, err := r.Cookie("refreshToken")
+	if err != nil {
+		return nil
+	}
+
+	// The verifier refreshes an expired access token by itself
+	hw := &headerWriter{header: http.Header{}}
+	access, err := s.auth.Verifier.VerifyAuthorization(hw, r)
+	if err != nil {
+		return nil
+	}
+	if set := hw.header.Values("Set-Cookie"); len(set) > 0 {
+		return set
+	}
+
+	if s.auth.Renewer == nil || access.Expiration().Sub(s.clock.Now()) > renewBefore {
+		return nil
+	}
+	accessToken, refreshToken, ok := s.auth.Renewer.RefreshTokens(refresh.Value)
+	if !ok {
+		// The current token is still good; try again on the next request
+		return nil
+	}
+	s.auth.Renewer.SetTokenCookies(hw, accessToken, refreshToken)
+	return hw.header.Values("Set-Cookie")
+}
+
+// replaceRequestCookies makes r carry the cookies set by the Set-Cookie
+// lines in place of its own
+func replaceRequestCookies(r *http.Request, set []string) {
+	var fresh []*http.Cookie
+	names := map[string]bool{}
+	for _, line := range set {
+		if c, err := http.ParseSetCookie(line); err == nil {
+			fresh = append(fresh, c)
+			names[c.Name] = true
+		}
+	}
+
+	var pairs []string
+	for _, c := range r.Cookies() {
+		if !names[c.Name] {
+			pairs = append(pairs, c.Name+"="+c.Value)
+		}
+	}
+	for _, c := range fresh {
+		if c.MaxAge >= 0 {
+			pairs = append(pairs, c.Name+"="+c.Value)
+		}
+	}
+	r.Header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+// sessionExpired answers a request whose session is gone. HTMX would
+// otherwise swap the error into the page or drop it silently, so it is told
+// to send the browser to sign in, coming back to the page the user was on.
+func (s *Server) sessionExpired(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") == "true" {
+		target := s.auth.LoginURL
+		if strings.HasPrefix(target, "/") {
+			returnTo := "/"
+			if u, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil && (u.Host == "" || u.Host == r.Host) && localPath(u.RequestURI()) {
+				returnTo = u.RequestURI()
+			}
+			target += "?return_to=" + url.QueryEscape(returnTo)
+		}
+		w.Header().Set("HX-Redirect", target)
+	}
+	apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handleManifest serves the web app manifest, which among other things
+// registers the app as a target for the system share sheet
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	http.ServeFileFS(w, r, staticFiles, "manifest.webmanifest")
+}
+
+// handleGetShare receives a Web Share Target. Shares arrive as a GET
+// navigation that carries no CSRF token, so rather than creating the task
+// outright this shows it prefilled for the user to confirm.
+func (s *Server) handleGetShare(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	q := r.URL.Query()
+	title, text, link := strings.TrimSpace(q.Get("title")), strings.TrimSpace(q.Get("text")), strings.TrimSpace(q.Get("url"))
+
+	// Apps disagree on where the link goes; many put it in text
+	description := text
+	if link != "" && !strings.Contains(text, link) {
+		description = strings.TrimSpace(text + "\n" + link)
+	}
+
+	view := ShareView{AuthContext: auth, Name: title, Description: description}
+	if err := s.presentation.RenderShare(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := s.capture(r.FormValue("name"), r.FormValue("description"), auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/tasks/"+task.ID+"/details", http.StatusSeeOther)
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Sign-in routes back off clients that keep failing. A few failures are
+// free; after that each one doubles how long the client must wait, and
+// enough of them within the window bans it for a while. Clients that simply
+// hit sign-in too often, as a script hammering the dev login would, are
+// treated as failing.
+//
+// Clients are told apart by address only, not by account: a code the
+// consent server rejects does not say whose it was, and the dev login
+// always signs in the same test user. Behind a shared address, such as a
+// NAT or a proxy without --client-ip-header, one client's failures hold
+// back everyone there.
+const (
+	signInWindow      = time.Hour        // failures older than this are forgotten
+	signInFreeFails   = 5                // failures allowed before backing off
+	signInBackoff     = 2 * time.Second  // the first wait, doubled per failure
+	signInMaxBackoff  = 15 * time.Minute // the longest wait short of a ban
+	signInBanAfter    = 20               // failures within the window that ban
+	signInBanDuration = time.Hour
+	signInRateLimit   = 30 // attempts per minute before it counts as abuse
+	signInMaxClients  = 10000
+)
+
+type signInState struct {
+	failures     []time.Time
+	attempts     []time.Time
+	blockedUntil time.Time
+}
+
+// signInGuard tracks sign-in attempts per client address
+type signInGuard struct {
+	store    domain.Store
+	clock    domain.Clock
+	ipHeader string
+
+	mu      sync.Mutex
+	clients map[string]*signInState
+}
+
+func newSignInGuard(store domain.Store, clock domain.Clock, ipHeader string) *signInGuard {
+	return &signInGuard{
+		store:    store,
+		clock:    clock,
+		ipHeader: ipHeader,
+		clients:  make(map[string]*signInState),
+	}
+}
+
+// clientAddress is who is signing in: the connection's address, or the one
+// a trusted reverse proxy reports. With X-Forwarded-For, only the last hop,
+// which the proxy added, can be trusted.
+func (g *signInGuard) clientAddress(r *http.Request) string {
+	if g.ipHeader != "" {
+		v := r.Header.Get(g.ipHeader)
+		if i := strings.LastIndex(v, ","); i >= 0 {
+			v = v[i+1:]
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// wrap guards a sign-in route. A request succeeds if the handler set or
+// cleared session cookies; a redirect without them is a failed sign-in.
+// Success does not clear earlier failures, which only age out, so that
+// signing in again cannot be used to reset the count.
+func (g *signInGuard) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := g.clientAddress(r)
+		if wait := g.attempt(addr); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+			http.Error(w, fmt.Sprintf("Too many sign-in attempts. Try again in %s.", wait.Round(time.Second)), http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+		if len(w.Header().Values("Set-Cookie")) == 0 {
+			g.failed(addr, "sign-in did not complete")
+		}
+	}
+}
+
+// attempt notes an attempt from addr, returning how long it must wait if
+// it is blocked
+func (g *signInGuard) attempt(addr string) time.Duration {
+	now := g.clock.Now()
+	g.mu.Lock()
+	st := g.state(addr, now)
+	if wait := st.blockedUntil.Sub(now); wait > 0 {
+		g.mu.Unlock()
+		return wait
+	}
+	st.attempts = append(pruned(st.attempts, now.Add(-time.Minute)), now)
+	abusive := len(st.attempts) > signInRateLimit
+	g.mu.Unlock()
+
+	if abusive {
+		return g.failed(addr, fmt.Sprintf("more than %d attempts in a minute", signInRateLimit))
+	}
+	return 0
+}
+
+// failed counts a failure against addr and blocks it if it has failed too
+// often, returning how long the block lasts. Every failure and block goes
+// in the audit log.
+func (g *signInGuard) failed(addr, reason string) time.Duration {
+	now := g.clock.Now()
+	g.mu.Lock()
+	st := g.state(addr, now)
+	st.failures = append(pruned(st.failures, now.Add(-signInWindow)), now)
+	n := len(st.failures)
+
+	var wait time.Duration
+	action, summary := domain.AuditLoginFailed, reason
+	switch {
+	case n >= signInBanAfter:
+		wait = signInBanDuration
+		action = domain.AuditLoginBanned
+		summary = fmt.Sprintf("%s; banned for %s after %d failures", reason, wait, n)
+	case n > signInFreeFails:
+		wait = min(signInBackoff<<(n-signInFreeFails-1), signInMaxBackoff)
+		action = domain.AuditLoginThrottled
+		summary = fmt.Sprintf("%s; blocked for %s after %d failures", reason, wait, n)
+	}
+	if wait > 0 {
+		st.blockedUntil = now.Add(wait)
+	}
+	g.mu.Unlock()
+
+	if err := g.store.AuditSignIn(action, addr, summary); err != nil {
+		log.Printf("Failed to audit sign-in from %s: %v", addr, err)
+	}
+	return wait
+}
+
+// state returns addr's record, creating it. Callers hold g.mu.
+func (g *signInGuard) state(addr string, now time.Time) *signInState {
+	st, ok := g.clients[addr]
+	if ok {
+		return st
+	}
+	if len(g.clients) >= signInMaxClients {
+		g.forgetIdle(now)
+	}
+	st = &signInState{}
+	g.clients[addr] = st
+	return st
+}
+
+// forgetIdle drops clients that are neither blocked nor have recent
+// failures, bounding memory under a spray of addresses
+func (g *signInGuard) forgetIdle(now time.Time) {
+	for addr, st := range g.clients {
+		st.failures = pruned(st.failures, now.Add(-signInWindow))
+		if len(st.failures) == 0 && !now.Before(st.blockedUntil) {
+			delete(g.clients, addr)
+		}
+	}
+}
+
+// pruned drops times before cutoff from an ascending list
+func pruned(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/store"
+)
+
+// TestOnlySignInIsGuarded checks that failed sign-ins back the client off,
+// while the other auth routes, which never set a cookie, are left alone
+func TestOnlySignInIsGuarded(t *testing.T) {
+	clock := domain.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "compass.db"), false, clock, domain.NewSeededIDs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := (&HeaderAuth{Key: key}).Auth()
+	auth.Routes = map[string]http.HandlerFunc{
+		"GET /test/login": func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		},
+	}
+	auth.ExtraRoutes = map[string]http.HandlerFunc{
+		"POST /test/clock": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+	server, err := NewServer(st, ServerOptions{Auth: auth, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, target string) int {
+		r := httptest.NewRequest(method, target, nil)
+		r.RemoteAddr = "127.0.0.1:41234"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := range 2 * signInFreeFails {
+		if code := serve(http.MethodPost, "/test/clock"); code != http.StatusNoContent {
+			t.Fatalf("call %d to an unguarded route: got %d", i+1, code)
+		}
+	}
+	for range signInFreeFails + 1 {
+		serve(http.MethodGet, "/test/login")
+	}
+	if code := serve(http.MethodGet, "/test/login"); code != http.StatusTooManyRequests {
+		t.Errorf("after %d failed sign-ins: got %d, want %d", signInFreeFails+1, code, http.StatusTooManyRequests)
+	}
+	if code := serve(http.MethodPost, "/test/clock"); code != http.StatusNoContent {
+		t.Errorf("a blocked client was kept from an unguarded route: got %d", code)
+	}
+}
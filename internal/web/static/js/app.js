@@ -1,3 +1,20 @@
+// Tell the server which layout fits this viewport. The cookie only affects
+// the next full render, so a resize never swaps layouts mid-session.
+(function () {
+  const narrow = window.matchMedia("(max-width: 640px)").matches;
+  document.cookie =
+    "viewport=" + (narrow ? "narrow" : "wide") + "; path=/; max-age=31536000; SameSite=Lax";
+})();
+
+// Tell the server which zone to show times in, for anyone who has not picked
+// one in their settings
+(function () {
+  const zone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+  if (zone) {
+    document.cookie = "tz=" + zone + "; path=/; max-age=31536000; SameSite=Lax";
+  }
+})();
+
 // Get CSRF token from meta tag if present
 function getCsrfToken() {
   const meta = document.querySelector('meta[name="csrf-token"]');
@@ -13,6 +30,56 @@ function withCsrf(values) {
   return values;
 }
 
+// A reorder is rejected with 409 when the list changed underneath us; reload
+// so the page matches what the server has. Other conflicts, like a time
+// block overlapping another, explain themselves in a toast, as do server
+// failures with the reference the server logged them under.
+document.addEventListener("htmx:responseError", function (evt) {
+  const xhr = evt.detail.xhr;
+  if (xhr && xhr.status === 409 && evt.detail.pathInfo.requestPath.includes("/reorder")) {
+    window.location.reload();
+  } else if (xhr && xhr.status === 409) {
+    showErrorToast(xhr.responseText || "That conflicts with something else.");
+  } else if (xhr && xhr.status >= 500) {
+    showErrorToast(xhr.responseText || "Something went wrong.");
+  }
+});
+
+// Renewing the session rotates the CSRF token, which is baked into forms
+// and hx- URLs all over the page; swap the old one out wherever it appears.
+document.addEventListener("htmx:afterRequest", function (evt) {
+  const xhr = evt.detail.xhr;
+  adoptCsrfToken(xhr && xhr.getResponseHeader("X-CSRF-Token"));
+});
+
+function adoptCsrfToken(fresh) {
+  const stale = getCsrfToken();
+  if (!fresh || !stale || fresh === stale) return;
+  document.querySelector('meta[name="csrf-token"]').setAttribute("content", fresh);
+  document.querySelectorAll('input[name="csrf"]').forEach(function (input) {
+    if (input.value === stale) input.value = fresh;
+  });
+  const attrs = ["hx-get", "hx-post", "hx-patch", "hx-put", "hx-delete", "action", "href"];
+  document.querySelectorAll(attrs.map((a) => "[" + a + "*='" + stale + "']").join(",")).forEach(function (el) {
+    attrs.forEach(function (a) {
+      const v = el.getAttribute(a);
+      if (v && v.includes(stale)) el.setAttribute(a, v.split(stale).join(fresh));
+    });
+  });
+}
+
+function showErrorToast(message) {
+  const container = document.getElementById("toast-container");
+  if (!container) return;
+  const toast = document.createElement("div");
+  toast.className = "toast toast-error";
+  toast.textContent = message;
+  container.replaceChildren(toast);
+  setTimeout(function () {
+    toast.remove();
+  }, 8000);
+}
+
 document.addEventListener("htmx:load", function (evt) {
   if (window._hyperscript && window._hyperscript.processNode) {
     const target = evt.detail && evt.detail.elt ? evt.detail.elt : evt.target;
@@ -68,6 +135,24 @@ document.addEventListener("htmx:load", function (evt) {
     }
   });
 
+  // Initialize Sortable for the focus queue
+  let queueList = document.getElementById("queue-list");
+  if (queueList && !queueList.sortableInitialized) {
+    new Sortable(queueList, {
+      animation: 150,
+      draggable: ".queue-item",
+      handle: ".drag-handle",
+      ghostClass: "ghost",
+      onEnd: function () {
+        htmx.ajax("POST", "/queue/reorder", {
+          values: withCsrf({ id: this.toArray() }),
+          swap: "none",
+        });
+      },
+    });
+    queueList.sortableInitialized = true;
+  }
+
   // Initialize Sortable for Subtasks
   document.querySelectorAll(".subtasks-list").forEach(function (el) {
     if (!el.sortableInitialized) {
@@ -97,3 +182,242 @@ document.addEventListener("htmx:load", function (evt) {
     }
   });
 });
+
+// Pasting an image into a description editor uploads it as an attachment
+// and inserts the markup that embeds it, then saves as if it were typed.
+document.addEventListener("paste", function (evt) {
+  const textarea = evt.target.closest && evt.target.closest("textarea[data-paste-url]");
+  if (!textarea || !evt.clipboardData) {
+    return;
+  }
+  const file = Array.from(evt.clipboardData.files).find(function (f) {
+    return f.type.startsWith("image/");
+  });
+  if (!file) {
+    return;
+  }
+  evt.preventDefault();
+
+  const body = new FormData();
+  body.append("csrf", getCsrfToken());
+  body.append("image", file, file.name || "pasted-image.png");
+
+  fetch(textarea.dataset.pasteUrl, { method: "POST", body: body, credentials: "same-origin" })
+    .then(function (resp) {
+      adoptCsrfToken(resp.headers.get("X-CSRF-Token"));
+      return resp.text().then(function (text) {
+        if (!resp.ok) {
+          throw new Error(text.trim());
+        }
+        return text;
+      });
+    })
+    .then(function (markup) {
+      textarea.setRangeText(markup, textarea.selectionStart, textarea.selectionEnd, "end");
+      textarea.dispatchEvent(new Event("change", { bubbles: true }));
+    })
+    .catch(function (err) {
+      window.alert("Could not upload the image: " + err.message);
+    });
+});
+
+// Voice memos: where the browser can record, a button records a clip and
+// uploads it to the work log; a click stops it, as does the length limit.
+const voiceMemoLimitMs = 5 * 60 * 1000;
+
+document.addEventListener("htmx:load", function () {
+  if (!window.MediaRecorder || !navigator.mediaDevices) {
+    return;
+  }
+  document.querySelectorAll(".voice-memo-record[hidden]").forEach(function (btn) {
+    btn.hidden = false;
+  });
+});
+
+document.addEventListener("click", function (evt) {
+  const btn = evt.target.closest && evt.target.closest(".voice-memo-record");
+  if (!btn) {
+    return;
+  }
+  if (btn.recorder) {
+    btn.recorder.stop();
+    return;
+  }
+
+  navigator.mediaDevices.getUserMedia({ audio: true }).then(function (stream) {
+    const recorder = new MediaRecorder(stream);
+    const chunks = [];
+    recorder.ondataavailable = function (e) {
+      chunks.push(e.data);
+    };
+    recorder.onstop = function () {
+      clearTimeout(btn.limit);
+      stream.getTracks().forEach(function (t) {
+        t.stop();
+      });
+      btn.recorder = null;
+      btn.textContent = "Uploading…";
+
+      const type = recorder.mimeType || "audio/webm";
+      const ext = type.indexOf("mp4") >= 0 ? "m4a" : type.indexOf("ogg") >= 0 ? "ogg" : "webm";
+      const body = new FormData();
+      body.append("csrf", getCsrfToken());
+      body.append("audio", new Blob(chunks, { type: type }), "voice-memo." + ext);
+
+      fetch(btn.dataset.recordUrl, { method: "POST", body: body, credentials: "same-origin" })
+        .then(function (resp) {
+          adoptCsrfToken(resp.headers.get("X-CSRF-Token"));
+          if (!resp.ok) {
+            return resp.text().then(function (text) {
+              throw new Error(text.trim());
+            });
+          }
+          htmx.trigger(document.body, "detailsChanged");
+        })
+        .catch(function (err) {
+          window.alert("Could not upload the memo: " + err.message);
+        })
+        .finally(function () {
+          btn.textContent = "Record memo";
+        });
+    };
+
+    btn.recorder = recorder;
+    btn.textContent = "Stop recording";
+    btn.limit = setTimeout(function () {
+      recorder.stop();
+    }, voiceMemoLimitMs);
+    recorder.start();
+  }).catch(function (err) {
+    window.alert("Could not start recording: " + err.message);
+  });
+});
+
+// Browser notifications: where Web Push is available, settings offer to
+// subscribe this device. The subscription itself lives in the service worker.
+function urlBase64ToUint8Array(s) {
+  const padded = (s + "===".slice((s.length + 3) % 4)).replace(/-/g, "+").replace(/_/g, "/");
+  return Uint8Array.from(atob(padded), function (c) {
+    return c.charCodeAt(0);
+  });
+}
+
+function pushRequest(method, path, body) {
+  return fetch(path + "?csrf=" + encodeURIComponent(getCsrfToken()), {
+    method: method,
+    body: body ? JSON.stringify(body) : undefined,
+    headers: { "Content-Type": "application/json" },
+    credentials: "same-origin",
+  }).then(function (resp) {
+    adoptCsrfToken(resp.headers.get("X-CSRF-Token"));
+    if (!resp.ok) {
+      return resp.text().then(function (text) {
+        throw new Error(text.trim());
+      });
+    }
+  });
+}
+
+function showPushState(panel, subscription, status) {
+  panel.querySelector(".push-enable").hidden = !!subscription;
+  panel.querySelector(".push-disable").hidden = !subscription;
+  panel.querySelector(".push-test").hidden = !subscription;
+  panel.querySelector(".push-status").textContent = status || "";
+}
+
+document.addEventListener("htmx:load", function () {
+  if (!("serviceWorker" in navigator) || !("PushManager" in window)) {
+    return;
+  }
+  document.querySelectorAll(".push-settings[hidden]").forEach(function (panel) {
+    panel.hidden = false;
+    navigator.serviceWorker.register("/sw.js").then(function (reg) {
+      return reg.pushManager.getSubscription();
+    }).then(function (subscription) {
+      showPushState(panel, subscription);
+    });
+  });
+});
+
+document.addEventListener("click", function (evt) {
+  const btn = evt.target.closest && evt.target.closest(".push-settings button");
+  if (!btn) {
+    return;
+  }
+  const panel = btn.closest(".push-settings");
+
+  let action;
+  if (btn.classList.contains("push-enable")) {
+    action = navigator.serviceWorker.ready.then(function (reg) {
+      return reg.pushManager.subscribe({
+        userVisibleOnly: true,
+        applicationServerKey: urlBase64ToUint8Array(panel.dataset.pushKey),
+      });
+    }).then(function (subscription) {
+      return pushRequest("POST", "/push/subscriptions", subscription.toJSON()).then(function () {
+        showPushState(panel, subscription, "Notifications are on for this device.");
+      });
+    });
+  } else if (btn.classList.contains("push-disable")) {
+    action = navigator.serviceWorker.ready.then(function (reg) {
+      return reg.pushManager.getSubscription();
+    }).then(function (subscription) {
+      if (!subscription) {
+        return;
+      }
+      return pushRequest("DELETE", "/push/subscriptions", { endpoint: subscription.endpoint }).then(function () {
+        return subscription.unsubscribe();
+      });
+    }).then(function () {
+      showPushState(panel, null, "Notifications are off for this device.");
+    });
+  } else {
+    action = pushRequest("POST", "/push/test").then(function () {
+      panel.querySelector(".push-status").textContent = "Test sent.";
+    });
+  }
+
+  action.catch(function (err) {
+    panel.querySelector(".push-status").textContent = "Could not update notifications: " + err.message;
+  });
+});
+
+// Live updates: the server streams the board over /events whenever anyone
+// changes it, swapping in the new one, and the open panel reloads itself to
+// match. Swaps are held off while the user is editing in the part that
+// would be replaced, and the board is fetched afresh once they leave it.
+(function () {
+  const events = document.getElementById("board-events");
+  if (!events) return;
+  let pending = false;
+
+  function editingIn(id) {
+    const el = document.getElementById(id);
+    return el && el.contains(document.activeElement) && document.activeElement !== document.body;
+  }
+
+  function editing() {
+    return editingIn("categories-list") || editingIn("slideover-container");
+  }
+
+  events.addEventListener("htmx:sseBeforeMessage", function (evt) {
+    if (editing()) {
+      evt.preventDefault();
+      pending = true;
+    }
+  });
+
+  events.addEventListener("htmx:sseMessage", function () {
+    document.body.dispatchEvent(new Event("detailsChanged"));
+  });
+
+  document.addEventListener("focusout", function () {
+    if (!pending) return;
+    setTimeout(function () {
+      if (editing()) return;
+      pending = false;
+      document.body.dispatchEvent(new Event("boardChanged"));
+      document.body.dispatchEvent(new Event("detailsChanged"));
+    }, 0);
+  });
+})();
@@ -0,0 +1,21 @@
+// Service worker: shows Web Push notifications and opens the page they
+// point at when clicked.
+self.addEventListener("push", function (event) {
+  let data = {};
+  try {
+    data = event.data ? event.data.json() : {};
+  } catch (e) {
+    data = { body: event.data.text() };
+  }
+  event.waitUntil(
+    self.registration.showNotification(data.title || "Compass", {
+      body: data.body || "",
+      data: { url: data.url || "/" },
+    })
+  );
+});
+
+self.addEventListener("notificationclick", function (event) {
+  event.notification.close();
+  event.waitUntil(self.clients.openWindow(event.notification.data.url));
+});
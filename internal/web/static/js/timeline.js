@@ -0,0 +1,26 @@
+// Turns the timeline's SVG into a PNG for slides, by drawing it onto a
+// canvas at twice its size so it stays sharp when scaled.
+document.getElementById("timeline-png")?.addEventListener("click", function () {
+  const button = this;
+  const svg = document.querySelector(".timeline-chart");
+  const width = svg.width.baseVal.value;
+  const height = svg.height.baseVal.value;
+  const source = new XMLSerializer().serializeToString(svg);
+  const image = new Image();
+  image.onload = function () {
+    const canvas = document.createElement("canvas");
+    canvas.width = width * 2;
+    canvas.height = height * 2;
+    const context = canvas.getContext("2d");
+    context.scale(2, 2);
+    context.drawImage(image, 0, 0, width, height);
+    canvas.toBlob(function (blob) {
+      const link = document.createElement("a");
+      link.href = URL.createObjectURL(blob);
+      link.download = button.dataset.name + " timeline.png";
+      link.click();
+      URL.revokeObjectURL(link.href);
+    }, "image/png");
+  };
+  image.src = "data:image/svg+xml;charset=utf-8," + encodeURIComponent(source);
+});
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// handleStats publishes the totals of the board the link's owner sees to
+// anyone with the link, for progress widgets on other sites. Any site may read them, and they may be
+// cached for a few minutes.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	userID, err := s.store.GetStatsTokenUser(token)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	// The week is the owner's, in their timezone
+	loc := time.Local
+	if prefs, err := s.store.GetPreferences(userID); err == nil {
+		loc = prefs.Location()
+	}
+	hidden := s.accessOf(userID).hiddenIDs()
+	stats, err := s.store.GetBoardStats(userID, hidden, domain.WeekStart(s.clock.Now().In(loc)))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleCreateStatsLink makes the user a new stats link, retiring the old
+// one if they had one
+func (s *Server) handleCreateStatsLink(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	if err := s.store.SetStatsToken(auth.Handle, base64.RawURLEncoding.EncodeToString(secret)); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
+
+func (s *Server) handleDeleteStatsLink(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.SetStatsToken(auth.Handle, ""); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/settings")
+		return
+	}
+	s.renderSettings(w, r, auth, nil)
+}
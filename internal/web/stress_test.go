@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// TestHandlersUnderLoad sends many users' writes and reads at once, so that
+// `go test -race` can catch state the handlers share, and checks that no
+// write was lost on the way to the store
+func TestHandlersUnderLoad(t *testing.T) {
+	const workers, rounds = 8, 5
+
+	ts := newTestServer(t, ServerOptions{})
+	garden := ts.category("ana", "Garden")
+	tasks := make([]string, workers)
+	for i := range tasks {
+		tasks[i] = ts.task(garden.ID, fmt.Sprintf("Bed %d", i)).ID
+	}
+	// each worker reorders a shelf of its own, since a reorder must name
+	// every task in the category and the garden keeps growing
+	shelves := make([]string, workers)
+	shelved := make([][]string, workers)
+	for i := range shelves {
+		shelves[i] = ts.category("ana", fmt.Sprintf("Shelf %d", i)).ID
+		shelved[i] = []string{ts.task(shelves[i], "Pots").ID, ts.task(shelves[i], "Twine").ID}
+	}
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handle := fmt.Sprintf("user%d", i)
+			for n := range rounds {
+				steps := []struct {
+					method, target string
+					form           url.Values
+					status         int
+				}{
+					{http.MethodPost, "/tasks/" + tasks[i] + "/work-logs", url.Values{"hours_worked": {"0.5"}, "work_description": {"Weeded"}, "completion_estimate": {fmt.Sprint(n * 10)}}, http.StatusCreated},
+					{http.MethodPost, "/categories/" + garden.ID + "/tasks", url.Values{"name": {fmt.Sprintf("Sow row %d-%d", i, n)}, "duplicate_ok": {"on"}}, http.StatusCreated},
+					{http.MethodPatch, "/tasks/" + tasks[(i+1)%workers], url.Values{"description": {handle + " was here"}}, http.StatusOK},
+					{http.MethodPost, "/tasks/reorder", url.Values{"category_id": {shelves[i]}, "id": {shelved[i][(n+1)%2], shelved[i][n%2]}}, http.StatusNoContent},
+					{http.MethodGet, "/tasks/" + tasks[i] + "/details", nil, http.StatusOK},
+					{http.MethodGet, "/", nil, http.StatusOK},
+				}
+				for _, step := range steps {
+					if w := ts.api(handle, step.method, step.target, step.form, nil); w.Code != step.status {
+						t.Errorf("%s %s %s: status %d, want %d: %s", handle, step.method, step.target, w.Code, step.status, w.Body.String())
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range tasks {
+		logs, err := ts.store.GetWorkLogsForTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != rounds {
+			t.Errorf("task %s has %d work logs, want %d", id, len(logs), rounds)
+		}
+	}
+	cat, err := ts.store.GetCategory(garden.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers + workers*rounds; len(cat.Tasks) != want {
+		t.Errorf("the category has %d tasks, want %d", len(cat.Tasks), want)
+	}
+}
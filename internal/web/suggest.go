@@ -0,0 +1,87 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/planning"
+)
+
+// suggestAlternates is how many runners-up are offered besides the top pick
+const suggestAlternates = 3
+
+// handleGetSuggest proposes a task to pick up given the minutes and energy
+// in the query. API clients asking for JSON get every scored candidate.
+func (s *Server) handleGetSuggest(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if !auth.IsAuthenticated {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	query := r.URL.Query()
+	minutes := 0
+	if v := query.Get("minutes"); v != "" {
+		var err error
+		if minutes, err = strconv.Atoi(v); err != nil || minutes < 0 {
+			apiError(w, r, http.StatusBadRequest, "Invalid minutes value", FieldError{Field: "minutes", Message: "must be a whole number of minutes"})
+			return
+		}
+	}
+	energy := query.Get("energy")
+	if energy != "" && !slices.Contains(planning.Energies, energy) {
+		apiError(w, r, http.StatusBadRequest, "Invalid energy value", FieldError{Field: "energy", Message: "must be low, medium, or high"})
+		return
+	}
+
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	queue, err := s.store.GetQueue(auth.Handle)
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	lastWorked, err := s.store.GetLastWorked()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	places := make(map[string]int, len(queue))
+	for i, q := range queue {
+		places[q.TaskID] = i + 1
+	}
+	suggestions := planning.Suggest(categories, planning.SuggestOptions{
+		Minutes:    minutes,
+		Energy:     energy,
+		Now:        s.clock.Now().In(auth.Location()),
+		Queue:      places,
+		LastWorked: lastWorked,
+		Include:    func(t *domain.Task) bool { return auth.CanSee(t.CategoryID) && auth.InContext(t.ID) },
+	})
+
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, map[string]any{"suggestions": suggestions})
+		return
+	}
+	view := NewSuggestView(suggestions, categories, minutes, energy, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderSuggest(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
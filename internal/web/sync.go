@@ -0,0 +1,232 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/mirror"
+)
+
+// syncPeer finds which of the board's peers signed the request. Peers are
+// told apart by their secrets, so one that fails is only told the signature
+// did not check out.
+func (s *Server) syncPeer(w http.ResponseWriter, r *http.Request, body []byte) (*domain.SyncPeer, bool) {
+	peers, err := s.store.GetSyncPeers(r.PathValue("board"))
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	signature := r.Header.Get(mirror.SignatureHeader)
+	for _, p := range peers {
+		if mirror.Verify(p.Secret, signature, s.clock.Now(), r.Method, r.URL.RequestURI(), body) == nil {
+			return p, true
+		}
+	}
+	http.Error(w, "Invalid signature; check the secret, and that both clocks are right", http.StatusUnauthorized)
+	return nil, false
+}
+
+// writeSigned answers a peer with v, signed so it knows the answer is ours
+func (s *Server) writeSigned(w http.ResponseWriter, r *http.Request, peer *domain.SyncPeer, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(mirror.SignatureHeader, mirror.Sign(peer.Secret, s.clock.Now(), r.Method, r.URL.RequestURI(), body))
+	w.Write(body)
+}
+
+// connected notes when a peer that only connects to us last did
+func (s *Server) connected(peer *domain.SyncPeer) {
+	if peer.URL == "" {
+		s.store.RecordSyncResult(peer.ID, peer.PushedSeq, peer.PulledSeq, "")
+	}
+}
+
+// handleGetSyncChanges serves a peer the board's change log after since
+func (s *Server) handleGetSyncChanges(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.syncPeer(w, r, nil)
+	if !ok {
+		return
+	}
+
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+			http.Error(w, "since must be a change number", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.store.RecordSyncChanges(peer.CategoryID); err != nil {
+		storeError(w, err)
+		return
+	}
+	changes, err := s.store.GetSyncChanges(peer.CategoryID, since, mirror.BatchSize)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.connected(peer)
+	s.writeSigned(w, r, peer, mirror.Batch{Changes: changes})
+}
+
+// handlePushSyncChanges applies a batch of changes a peer pushed. What
+// changed here since the last sync is recorded first, so conflicts are
+// judged against it.
+func (s *Server) handlePushSyncChanges(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, mirror.MaxBodyBytes))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	peer, ok := s.syncPeer(w, r, body)
+	if !ok {
+		return
+	}
+
+	var batch mirror.Batch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.store.RecordSyncChanges(peer.CategoryID); err != nil {
+		storeError(w, err)
+		return
+	}
+	applied, err := s.store.ApplySyncChanges(peer.ID, batch.Changes)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.connected(peer)
+	s.writeSigned(w, r, peer, map[string]int{"applied": applied})
+}
+
+// handleAddSyncPeer shares a board with a peer, or joins a board shared
+// from one. Without a secret a new one is made and shown this once, for the
+// other side to be given; with a URL the first sync happens straight away.
+func (s *Server) handleAddSyncPeer(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	board := r.FormValue("board")
+
+	peerURL := r.FormValue("url")
+	if peerURL != "" {
+		u, err := url.Parse(peerURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			http.Error(w, "The peer's address must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	}
+	secret := r.FormValue("secret")
+	generated := secret == ""
+	if generated {
+		key := make([]byte, 32)
+		rand.Read(key)
+		secret = base64.RawURLEncoding.EncodeToString(key)
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		name = "Peer"
+	}
+
+	peer, err := s.store.AddSyncPeer(&domain.SyncPeer{
+		CategoryID: board,
+		Name:       name,
+		URL:        peerURL,
+		Secret:     secret,
+	}, auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	// A failed first sync is shown on the peer, so adding it still worked
+	if peer.URL != "" && s.sync != nil {
+		s.sync.Sync(r.Context(), peer)
+	}
+
+	detailsURL := "/categories/" + board + "/details"
+	if !generated {
+		if ctx.IsHTMX {
+			w.Header().Set("HX-Redirect", detailsURL)
+			return
+		}
+		http.Redirect(w, r, detailsURL, http.StatusSeeOther)
+		return
+	}
+
+	cat, err := s.store.GetCategory(board)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if cat.WorkLogs, err = s.store.GetWorkLogsForCategory(board); err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewCategoryView(cat, false, auth)
+	view.NewSyncPeer = &NewSyncPeerView{
+		Name:    peer.Name,
+		URL:     baseURL(r),
+		BoardID: board,
+		Secret:  secret,
+	}
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+	if err := s.presentation.RenderSlideoverWithDetails(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleSyncNow syncs with a peer instead of waiting for the schedule
+func (s *Server) handleSyncNow(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	peer, err := s.store.GetSyncPeer(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if s.sync == nil {
+		http.Error(w, "Sync is not available", http.StatusServiceUnavailable)
+		return
+	}
+	// The outcome is recorded on the peer, which the details show
+	s.sync.Sync(r.Context(), peer)
+	detailsChanged(w, r, ctx, "/categories/"+peer.CategoryID+"/details")
+}
+
+// handleDeleteSyncPeer stops syncing with the peer; the board stays as it is
+func (s *Server) handleDeleteSyncPeer(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := s.store.DeleteSyncPeer(r.PathValue("id")); err != nil {
+		storeError(w, err)
+		return
+	}
+	detailsChanged(w, r, ctx, "/")
+}
@@ -0,0 +1,217 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// filterTags reads the tags the board is narrowed to from the page's query.
+// HTMX requests made from the board carry the board's URL instead, so the
+// parts of it they refresh stay narrowed too.
+func filterTags(r *http.Request) []string {
+	query := r.URL.Query()
+	if len(query["tag"]) == 0 && r.Header.Get("HX-Request") == "true" {
+		if u, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil && u.Path == "/" {
+			query = u.Query()
+		}
+	}
+	var tags []string
+	for _, name := range query["tag"] {
+		if name = strings.TrimSpace(name); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+func (s *Server) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if !auth.IsAuthenticated {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	tags := auth.AllTags
+	if ctx.WantsJSON {
+		if tags == nil {
+			tags = []*domain.Tag{}
+		}
+		writeJSON(w, http.StatusOK, tags)
+		return
+	}
+	view := NewTagsView(tags, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderTags(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleCreateTag(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	tag, err := s.store.AddTag(r.FormValue("name"), r.FormValue("color"))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusCreated, tag)
+		return
+	}
+	tagsChanged(w, r, ctx, "/tags")
+}
+
+// handleUpdateTag renames or recolors a tag, everywhere it is given at once
+func (s *Server) handleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	tag, err := s.store.GetTag(r.PathValue("id"))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if _, ok := r.PostForm["name"]; ok {
+		tag.Name = r.PostForm.Get("name")
+	}
+	if _, ok := r.PostForm["color"]; ok {
+		tag.Color = r.PostForm.Get("color")
+	}
+	if tag, err = s.store.UpdateTag(tag); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if ctx.WantsJSON {
+		writeJSON(w, http.StatusOK, tag)
+		return
+	}
+	tagsChanged(w, r, ctx, "/tags")
+}
+
+// handleDeleteTag deletes a tag and takes it off every task given it
+func (s *Server) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	if err := s.store.DeleteTag(r.PathValue("id")); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	if ctx.WantsJSON {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	tagsChanged(w, r, ctx, "/tags")
+}
+
+// handleGetTagTasks lists the tasks given a tag, in board order, leaving out
+// those in categories hidden from the user
+func (s *Server) handleGetTagTasks(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tasks, err := s.store.GetTasksByTag(r.PathValue("id"))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	visible := []*domain.Task{}
+	for _, t := range tasks {
+		if auth.CanSee(t.CategoryID) {
+			visible = append(visible, t)
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
+
+// handleTagTask gives a task the tag the form names, making the tag if there
+// is none by that name yet, or the tag with the given ID
+func (s *Server) handleTagTask(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	taskID := r.PathValue("id")
+
+	tagID := r.FormValue("tag_id")
+	if tagID == "" {
+		tag, err := s.store.GetTagByName(r.FormValue("name"))
+		if err == nil {
+			tagID = tag.ID
+		} else if tag, err = s.store.AddTag(r.FormValue("name"), r.FormValue("color")); err == nil {
+			tagID = tag.ID
+		} else {
+			apiStoreError(w, r, err)
+			return
+		}
+	}
+	if err := s.store.TagTask(taskID, tagID); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	s.taskTagsChanged(w, r, ctx, taskID)
+}
+
+func (s *Server) handleUntagTask(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAuth(w, r); !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	taskID := r.PathValue("id")
+
+	if err := s.store.UntagTask(taskID, r.PathValue("tag")); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	s.taskTagsChanged(w, r, ctx, taskID)
+}
+
+// taskTagsChanged answers a change to a task's tags with the task, as the
+// API has it, or by refreshing its details and the board it may have left
+func (s *Server) taskTagsChanged(w http.ResponseWriter, r *http.Request, ctx RequestContext, taskID string) {
+	if ctx.WantsJSON {
+		task, err := s.store.GetTask(taskID)
+		if err != nil {
+			apiStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, task)
+		return
+	}
+	tagsChanged(w, r, ctx, "/tasks/"+taskID+"/details")
+}
+
+// tagsChanged has the page refresh both the open details and the board,
+// whose chips and filter show tags too
+func tagsChanged(w http.ResponseWriter, r *http.Request, ctx RequestContext, fallback string) {
+	if !ctx.IsHTMX {
+		redirectBack(w, r, fallback)
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged, boardChanged")
+}
@@ -4,11 +4,22 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 )
 
 //go:embed templates/*
 var templateFS embed.FS
 
+// staticFS holds stylesheets, scripts, and images. They are embedded like
+// the templates so the server does not depend on its working directory.
+//
+//go:embed static
+var staticFS embed.FS
+
+// staticFiles is staticFS rooted at the static directory
+var staticFiles, _ = fs.Sub(staticFS, "static")
+
 // Presentation handles all view-related logic and template rendering
 type Presentation struct {
 	tmpl *template.Template
@@ -24,3 +35,25 @@ func NewPresentation() (*Presentation, error) {
 	}
 	return &Presentation{tmpl: tmpl}, nil
 }
+
+// mobileVariants maps a template to the fragment that replaces it in the
+// mobile layout. Templates without an entry render the same everywhere.
+var mobileVariants = map[string]string{
+	"category.html": "category_card",
+}
+
+// layoutVariant is implemented by every view that embeds AuthContext
+type layoutVariant interface {
+	mobileLayout() bool
+}
+
+// execute renders the named template, or its mobile variant when the view
+// was requested from a mobile client. Handlers never pick variants directly.
+func (p *Presentation) execute(w io.Writer, name string, view any) error {
+	if v, ok := view.(layoutVariant); ok && v.mobileLayout() {
+		if variant, ok := mobileVariants[name]; ok {
+			name = variant
+		}
+	}
+	return p.tmpl.ExecuteTemplate(w, name, view)
+}
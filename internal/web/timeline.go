@@ -0,0 +1,58 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+func (s *Server) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.timelineView(w, r)
+	if !ok {
+		return
+	}
+	if err := s.presentation.RenderTimeline(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleGetTimelineSVG serves the chart on its own, as a file to download
+func (s *Server) handleGetTimelineSVG(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.timelineView(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": view.CategoryName + " timeline.svg"}))
+	if err := s.presentation.RenderTimelineSVG(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// timelineView loads the category in the path as its viewer may see it
+func (s *Server) timelineView(w http.ResponseWriter, r *http.Request) (TimelineView, bool) {
+	auth := s.getAuthContext(w, r)
+
+	cat, err := s.store.GetCategory(r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return TimelineView{}, false
+	}
+
+	if !auth.CanSee(cat.ID) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return TimelineView{}, false
+	}
+	// Private items are not accessible to unauthenticated users
+	if !auth.IsAuthenticated {
+		public := filterPublicCategories([]*domain.Category{cat})
+		if len(public) == 0 {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return TimelineView{}, false
+		}
+		cat = public[0]
+	}
+
+	return NewTimelineView(cat, s.clock.Now().In(auth.Location()), auth), true
+}
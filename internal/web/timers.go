@@ -0,0 +1,150 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strings"
+)
+
+// handleGetTimers renders the header timer widget, which asks for it again
+// whenever a timer is started or stopped elsewhere on the page
+func (s *Server) handleGetTimers(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := s.presentation.RenderTimers(w, auth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleStartTimer starts a timer on a task. Users who run several at once
+// can name each for what it times, such as a build left running.
+func (s *Server) handleStartTimer(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+	taskID := r.PathValue("id")
+
+	if _, err := s.store.StartTimer(auth.Handle, taskID, strings.TrimSpace(r.FormValue("name"))); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/tasks/"+taskID+"/details")
+		return
+	}
+	w.Header().Set("HX-Trigger", "timerChanged")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePauseTimer(w http.ResponseWriter, r *http.Request) {
+	s.changeTimers(w, r, func(userID string) error {
+		_, err := s.store.PauseTimer(userID, r.PathValue("id"))
+		return err
+	})
+}
+
+func (s *Server) handleResumeTimer(w http.ResponseWriter, r *http.Request) {
+	s.changeTimers(w, r, func(userID string) error {
+		_, err := s.store.ResumeTimer(userID, r.PathValue("id"))
+		return err
+	})
+}
+
+// handleTimerHeartbeat is polled by the widget while a timer runs. When
+// the polling stops, so do the timers, after the user's idle threshold.
+func (s *Server) handleTimerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	s.changeTimers(w, r, func(userID string) error {
+		_, err := s.store.TouchTimers(userID)
+		return err
+	})
+}
+
+// changeTimers applies change to the user's timers and answers with the
+// widget as it now stands
+func (s *Server) changeTimers(w http.ResponseWriter, r *http.Request, change func(userID string) error) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	if err := change(auth.Handle); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/")
+		return
+	}
+	timers, err := s.store.GetTimers(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	auth.Timers = NewTimerViews(timers, s.clock.Now(), auth)
+	if err := s.presentation.RenderTimers(w, auth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleStopTimer logs the time counted on the timer's task, rounded to the
+// nearest minute, and removes the timer. The task's completion is left as
+// it is, and a named timer's name describes the work. Discarding the timer
+// removes it without logging anything.
+func (s *Server) handleStopTimer(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	timer, err := s.store.GetTimer(auth.Handle, r.PathValue("id"))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if r.FormValue("discard") != "on" {
+		minutes := math.Round(timer.ElapsedAt(s.clock.Now()).Minutes())
+		if minutes < 1 {
+			http.Error(w, "Less than a minute was timed; discard the timer instead", http.StatusBadRequest)
+			return
+		}
+		task, err := s.store.GetTask(timer.TaskID)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		description := r.FormValue("work_description")
+		if description == "" {
+			description = timer.Name
+		}
+		hours := math.Round(minutes/60*100) / 100
+		workLog, err := s.store.AddWorkLogForTask(task.ID, hours, description, task.Completion, nil, auth.Handle)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		s.notifyWorkLogged(auth, workLog)
+	}
+	if _, err := s.store.StopTimer(auth.Handle, timer.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/")
+		return
+	}
+	w.Header().Set("HX-Trigger", "timerChanged, detailsChanged")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// AgingRuleView is one of a category's aging rules
+type AgingRuleView struct {
+	AuthContext
+	ID      string
+	Summary string // e.g. "After 14 days without work, flag it"
+	URL     string
+}
+
+func newAgingRuleViews(rules []*domain.AgingRule, auth AuthContext) []AgingRuleView {
+	views := make([]AgingRuleView, len(rules))
+	for i, r := range rules {
+		days := fmt.Sprintf("%d days", r.Days)
+		if r.Days == 1 {
+			days = "1 day"
+		}
+		action := "flag it"
+		if r.Action == domain.AgingEscalate {
+			action = "raise its priority"
+		}
+		views[i] = AgingRuleView{
+			AuthContext: auth,
+			ID:          r.ID,
+			Summary:     fmt.Sprintf("After %s without work, %s", days, action),
+			URL:         "/aging-rules/" + r.ID,
+		}
+	}
+	return views
+}
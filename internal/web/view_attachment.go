@@ -0,0 +1,60 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// AttachmentView is the view model for Attachment
+type AttachmentView struct {
+	AuthContext
+	ID           string
+	Filename     string
+	Size         string // Human readable
+	URL          string
+	ThumbnailURL string // Empty when there is no thumbnail
+	IsAudio      bool   // Shown as a player, e.g. a voice memo
+	DeleteURL    string
+	Author       Profile
+}
+
+// NewAttachmentView creates an AttachmentView from a domain Attachment
+func NewAttachmentView(a *domain.Attachment, auth AuthContext) AttachmentView {
+	view := AttachmentView{
+		AuthContext: auth,
+		ID:          a.ID,
+		Filename:    a.Filename,
+		Size:        formatSize(a.Size),
+		URL:         "/attachments/" + a.ID,
+		DeleteURL:   "/attachments/" + a.ID,
+		Author:      auth.profiles.Resolve(a.UploadedBy),
+		IsAudio:     strings.HasPrefix(a.ContentType, "audio/"),
+	}
+	if a.HasThumbnail {
+		view.ThumbnailURL = "/attachments/" + a.ID + "/thumbnail"
+	}
+	return view
+}
+
+func NewAttachmentViews(attachments []*domain.Attachment, auth AuthContext) []AttachmentView {
+	if attachments == nil {
+		return nil
+	}
+	views := make([]AttachmentView, len(attachments))
+	for i, a := range attachments {
+		views[i] = NewAttachmentView(a, auth)
+	}
+	return views
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<20)), ".0") + " MB"
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d B", n)
+}
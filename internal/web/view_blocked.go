@@ -0,0 +1,72 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// BlockedTaskView is one stuck task on the blocked report
+type BlockedTaskView struct {
+	AuthContext
+	Name         string
+	CategoryName string
+	Reason       string
+	WaitingOn    string
+	Age          string // how long it has been blocked
+	Since        string
+	DetailsURL   string
+}
+
+// BlockedView is the view model for the blocked report
+type BlockedView struct {
+	AuthContext
+	Tasks []BlockedTaskView
+}
+
+// NewBlockedView creates the blocked report of the tasks the viewer can
+// see, keeping the store's order of longest stuck first
+func NewBlockedView(tasks []*domain.BlockedTask, now time.Time, auth AuthContext) BlockedView {
+	view := BlockedView{AuthContext: auth}
+	for _, t := range tasks {
+		if !auth.CanSee(t.CategoryID) || !auth.InContext(t.ID) {
+			continue
+		}
+		view.Tasks = append(view.Tasks, BlockedTaskView{
+			AuthContext:  auth,
+			Name:         t.Name,
+			CategoryName: t.CategoryName,
+			Reason:       t.Reason,
+			WaitingOn:    t.WaitingOn,
+			Age:          formatAge(now.Sub(t.Since)),
+			Since:        auth.FormatDate(t.Since),
+			DetailsURL:   "/tasks/" + t.ID + "/details",
+		})
+	}
+	return view
+}
+
+// formatAge writes a duration in the largest whole unit, down to hours
+func formatAge(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch days := int(d.Hours() / 24); {
+	case days >= 14:
+		return plural(days/7, "week")
+	case days >= 1:
+		return plural(days, "day")
+	case d >= time.Hour:
+		return plural(int(d.Hours()), "hour")
+	}
+	return "under an hour"
+}
+
+func (p *Presentation) RenderBlocked(w io.Writer, view BlockedView) error {
+	return p.tmpl.ExecuteTemplate(w, "blocked", view)
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"html/template"
 	"io"
 
 	"git.sr.ht/~jakintosh/compass/internal/domain"
@@ -13,11 +14,33 @@ type CategoryView struct {
 	Name              string
 	Description       string
 	Public            bool
+	Color             string // accent color name; empty for the default
+	Icon              string
+	Colors            []string // palettes for the pickers
+	Icons             []string
+	DoneCriteria      []DoneCriterionView
+	AgingRules        []AgingRuleView
+	AgingActions      []string
+	SyncPeers         []SyncPeerView
+	WIP               int // tasks in progress
+	WIPLimit          int // 0 for no limit
 	AverageCompletion int
 	Tasks             []TaskView
 	WorkLogs          []WorkLogView
+	DetailsURL        string
+	HistoryURL        string
+	MoveURL           string
 	OOB               bool
 	DeleteButton      DeleteButtonView
+	NewSyncPeer       *NewSyncPeerView // Just shared; its secret is shown this once
+	Embed             *EmbedView       // Set by the details page when the category is shared for embedding
+	ReceivesAlerts    bool
+	WeeklyDigest      bool
+	RequiresReview    bool
+	CanApprove        bool   // Owns the category or is an admin, so approves its tasks
+	AlertURL          string // Just made; the alert webhook is shown this once
+
+	Access *CategoryAccessView // Set by the details page: who the category is shared with
 }
 
 // NewCategoryView creates a CategoryView from a domain Category
@@ -28,29 +51,62 @@ func NewCategoryView(c *domain.Category, oob bool, auth AuthContext) CategoryVie
 		Name:              c.Name,
 		Description:       c.Description,
 		Public:            c.Public,
-		AverageCompletion: c.AverageCompletion(),
+		Color:             c.Color,
+		Icon:              c.Icon,
+		Colors:            domain.CategoryColors,
+		Icons:             domain.CategoryIcons,
+		AverageCompletion: c.Completion,
+		DetailsURL:        "/categories/" + c.ID + "/details",
+		HistoryURL:        "/categories/" + c.ID + "/history",
+		MoveURL:           "/categories/" + c.ID + "/move",
 		OOB:               oob,
-		WorkLogs:          NewWorkLogViewsFromCategory(c),
+		WorkLogs:          NewWorkLogViewsFromCategory(c, auth),
+		DoneCriteria:      newDoneCriterionViews(c.DoneCriteria, auth),
+		AgingRules:        newAgingRuleViews(c.AgingRules, auth),
+		AgingActions:      domain.AgingActions,
+		SyncPeers:         newSyncPeerViews(c.SyncPeers, auth),
+		WIP:               c.WIP(),
+		WIPLimit:          c.WIPLimit,
+		ReceivesAlerts:    c.ReceivesAlerts,
+		WeeklyDigest:      c.WeeklyDigest,
+		RequiresReview:    c.RequiresReview,
+		CanApprove:        canApprove(auth, c),
 	}
-	if len(c.Tasks) > 0 {
-		view.Tasks = make([]TaskView, len(c.Tasks))
-		for i, t := range c.Tasks {
-			view.Tasks[i] = NewTaskView(t, false, auth)
+	for _, t := range c.Tasks {
+		if !auth.InContext(t.ID) || !auth.Tagged(t.Tags) {
+			continue
 		}
+		tv := NewTaskView(t, false, auth)
+		tv.Color = c.Color
+		view.Tasks = append(view.Tasks, tv)
 	}
+	scheduleTaskViews(view.Tasks, c.Tasks)
 
 	view.DeleteButton = DeleteButtonView{
 		URL:            "/categories/" + c.ID + "?csrf=" + auth.CSRFToken,
 		ConfirmMessage: "Delete this category and all its tasks?",
 		ButtonText:     "Delete Category",
+		FormURL:        "/categories/" + c.ID + "/delete",
+		CSRFToken:      auth.CSRFToken,
+		Accessible:     auth.Accessible,
 	}
 
 	return view
 }
 
+// DescriptionHTML is the description with its task references as links and images shown
+func (v CategoryView) DescriptionHTML() template.HTML {
+	return renderText(v.Description, v.AuthContext)
+}
+
+// References lists the tasks the description refers to
+func (v CategoryView) References() []TaskRefView {
+	return refViews(v.Description, v.AuthContext)
+}
+
 // RenderCategory renders a single category from its view model
 func (p *Presentation) RenderCategory(w io.Writer, view CategoryView) error {
-	return p.tmpl.ExecuteTemplate(w, "category.html", view)
+	return p.execute(w, "category.html", view)
 }
 
 // RenderCategoryDetails renders the category details slideover
@@ -60,7 +116,7 @@ func (p *Presentation) RenderCategoryDetails(w io.Writer, view CategoryView) err
 
 // RenderCategoryOOB renders a category as an out-of-band update
 func (p *Presentation) RenderCategoryOOB(w io.Writer, view CategoryView) error {
-	return p.tmpl.ExecuteTemplate(w, "category.html", view)
+	return p.execute(w, "category.html", view)
 }
 
 // RenderCategoryDeleteOOB renders OOB updates for category deletion
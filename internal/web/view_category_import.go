@@ -0,0 +1,31 @@
+package web
+
+import (
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// CategoryImportView takes a markdown vault to import into a category, and
+// says what the last import did
+type CategoryImportView struct {
+	AuthContext
+	ID         string
+	Name       string
+	DetailsURL string
+	Found      int                 // tasks found in the last upload
+	Result     *domain.VaultImport // nil until something is imported
+}
+
+func NewCategoryImportView(cat *domain.Category, auth AuthContext) CategoryImportView {
+	return CategoryImportView{
+		AuthContext: auth,
+		ID:          cat.ID,
+		Name:        cat.Name,
+		DetailsURL:  "/categories/" + cat.ID + "/details",
+	}
+}
+
+func (p *Presentation) RenderCategoryImport(w io.Writer, view CategoryImportView) error {
+	return p.tmpl.ExecuteTemplate(w, "category_import", view)
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// CategoryOption is a category that can be picked from a list
+type CategoryOption struct {
+	ID   string
+	Name string
+}
+
+// CategoryMergeView asks which category to merge one into
+type CategoryMergeView struct {
+	AuthContext
+	ID         string
+	Name       string
+	TaskCount  int
+	Targets    []CategoryOption // every other category the user can see, in board order
+	DetailsURL string
+}
+
+// NewCategoryMergeView creates the merge form for the category with id, or
+// reports false if there is no such category the user can see
+func NewCategoryMergeView(id string, categories []*domain.Category, auth AuthContext) (CategoryMergeView, bool) {
+	view := CategoryMergeView{
+		AuthContext: auth,
+		ID:          id,
+		DetailsURL:  "/categories/" + id + "/details",
+	}
+	found := false
+	for _, c := range categories {
+		if !auth.CanSee(c.ID) {
+			continue
+		}
+		if c.ID == id {
+			view.Name = c.Name
+			view.TaskCount = len(c.Tasks)
+			found = true
+			continue
+		}
+		view.Targets = append(view.Targets, CategoryOption{ID: c.ID, Name: c.Name})
+	}
+	return view, found
+}
+
+func (p *Presentation) RenderCategoryMerge(w io.Writer, view CategoryMergeView) error {
+	return p.tmpl.ExecuteTemplate(w, "category_merge", view)
+}
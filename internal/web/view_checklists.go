@@ -0,0 +1,47 @@
+package web
+
+import "git.sr.ht/~jakintosh/compass/internal/domain"
+
+// CheckView is one item of a task's definition of done
+type CheckView struct {
+	AuthContext
+	ID      string
+	Text    string
+	Checked bool
+	URL     string
+}
+
+// DoneCriterionView is one item of a category's definition of done
+type DoneCriterionView struct {
+	AuthContext
+	ID   string
+	Text string
+	URL  string
+}
+
+func newCheckViews(checks []*domain.TaskCheck, auth AuthContext) []CheckView {
+	views := make([]CheckView, len(checks))
+	for i, c := range checks {
+		views[i] = CheckView{
+			AuthContext: auth,
+			ID:          c.ID,
+			Text:        c.Text,
+			Checked:     c.Checked,
+			URL:         "/checks/" + c.ID,
+		}
+	}
+	return views
+}
+
+func newDoneCriterionViews(criteria []*domain.DoneCriterion, auth AuthContext) []DoneCriterionView {
+	views := make([]DoneCriterionView, len(criteria))
+	for i, c := range criteria {
+		views[i] = DoneCriterionView{
+			AuthContext: auth,
+			ID:          c.ID,
+			Text:        c.Text,
+			URL:         "/done-criteria/" + c.ID,
+		}
+	}
+	return views
+}
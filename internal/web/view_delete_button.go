@@ -5,4 +5,7 @@ type DeleteButtonView struct {
 	URL            string // e.g., "/categories/abc123"
 	ConfirmMessage string // e.g., "Delete this category and all its tasks?"
 	ButtonText     string // e.g., "Delete Category"
+	FormURL        string // POST fallback for accessible mode, e.g., "/categories/abc123/delete"
+	CSRFToken      string
+	Accessible     bool
 }
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"slices"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/planning"
+)
+
+// DependencyView is a task that another waits on
+type DependencyView struct {
+	AuthContext
+	ID         string
+	Name       string
+	Completion int
+	DetailsURL string
+	RemoveURL  string
+}
+
+// scheduleTaskViews marks the views of a category's tasks with where they
+// fall on its critical path. Tasks whose dependencies loop are left
+// unmarked; the store refuses to create such loops.
+func scheduleTaskViews(views []TaskView, tasks []*domain.Task) {
+	schedule, err := planning.CriticalPath(tasks)
+	if err != nil {
+		return
+	}
+	for i := range views {
+		slot, ok := schedule.Slots[views[i].ID]
+		if !ok || slot.Duration == 0 {
+			continue
+		}
+		views[i].Critical = slot.Critical
+		views[i].Slack = formatHours(slot.Slack)
+	}
+}
+
+// planAmong fills in the task's dependencies and schedule from the other
+// tasks in its category
+func (v *TaskView) planAmong(t *domain.Task, siblings []*domain.Task) {
+	one := []TaskView{*v}
+	scheduleTaskViews(one, siblings)
+	v.Critical, v.Slack = one[0].Critical, one[0].Slack
+
+	for _, other := range siblings {
+		if other.ID == t.ID {
+			continue
+		}
+		if slices.Contains(t.DependsOn, other.ID) {
+			v.Dependencies = append(v.Dependencies, DependencyView{
+				AuthContext: v.AuthContext,
+				ID:          other.ID,
+				Name:        other.Name,
+				Completion:  other.Completion,
+				DetailsURL:  "/tasks/" + other.ID + "/details",
+				RemoveURL:   "/tasks/" + t.ID + "/dependencies/" + other.ID,
+			})
+		} else {
+			v.DependencyOptions = append(v.DependencyOptions, PlanTaskOption{ID: other.ID, Name: other.Name})
+		}
+	}
+}
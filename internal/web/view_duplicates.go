@@ -0,0 +1,26 @@
+package web
+
+import "io"
+
+// DuplicateView is an existing task that a new one may duplicate
+type DuplicateView struct {
+	AuthContext
+	Name       string
+	Completion int
+	DetailsURL string
+}
+
+// DuplicateWarningView lists tasks named like one about to be added, and
+// offers to add it anyway
+type DuplicateWarningView struct {
+	AuthContext
+	Name      string
+	Tasks     []DuplicateView
+	URL       string
+	Fields    []FormField
+	ReturnURL string // where cancelling goes in accessible mode
+}
+
+func (p *Presentation) RenderDuplicateWarning(w io.Writer, view DuplicateWarningView) error {
+	return p.tmpl.ExecuteTemplate(w, "duplicate_warning", view)
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// embedColors are the category accent colors as hex, for embeds that are
+// shown without the app's stylesheet. The default is the app's accent.
+var embedColors = map[string]string{
+	"":       "#ef4687",
+	"red":    "#e5484d",
+	"orange": "#f76b15",
+	"yellow": "#e2a336",
+	"green":  "#30a46c",
+	"teal":   "#12a594",
+	"blue":   "#0090ff",
+	"purple": "#8e4ec6",
+	"pink":   "#d6409f",
+	"gray":   "#8b8d98",
+}
+
+// Badge layout, in pixels: the label is sized to its text by an average
+// character width, and the bar after it is always the same length
+const (
+	embedCharWidth   = 7
+	embedPadding     = 10
+	embedBarWidth    = 100
+	embedMaxNameRune = 40
+)
+
+// EmbedProgressView is a category's progress as shown on other sites
+type EmbedProgressView struct {
+	Name       string
+	Label      string // name and completion, as the badge reads
+	Completion int
+	Done       int
+	Total      int
+	Color      string // hex
+
+	LabelWidth int
+	Width      int
+	Fill       int // filled length of the bar
+}
+
+func NewEmbedProgressView(c *domain.Category) EmbedProgressView {
+	name := c.Name
+	if utf8.RuneCountInString(name) > embedMaxNameRune {
+		name = string([]rune(name)[:embedMaxNameRune-1]) + "…"
+	}
+	view := EmbedProgressView{
+		Name:       c.Name,
+		Label:      name + " · " + strconv.Itoa(c.Completion) + "%",
+		Completion: c.Completion,
+		Total:      len(c.Tasks),
+		Color:      embedColors[c.Color],
+	}
+	for _, t := range c.Tasks {
+		if t.Completion >= 100 {
+			view.Done++
+		}
+	}
+	view.LabelWidth = utf8.RuneCountInString(view.Label)*embedCharWidth + 2*embedPadding
+	view.Width = view.LabelWidth + embedBarWidth
+	view.Fill = embedBarWidth * min(max(c.Completion, 0), 100) / 100
+	return view
+}
+
+// EmbedView gives the addresses to embed a shared category's progress with
+type EmbedView struct {
+	BadgeURL string // SVG, for READMEs and status pages
+	PageURL  string // HTML, for an iframe
+	Markdown string
+	IFrame   string
+}
+
+func NewEmbedView(base, token, name string) *EmbedView {
+	badge := base + "/embed/" + token + "/progress.svg"
+	page := base + "/embed/" + token + "/progress.html"
+	return &EmbedView{
+		BadgeURL: badge,
+		PageURL:  page,
+		Markdown: "[![" + strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(name) + " progress](" + badge + ")](" + page + ")",
+		IFrame:   `<iframe src="` + page + `" title="` + template.HTMLEscapeString(name) + ` progress" width="320" height="72" style="border:0"></iframe>`,
+	}
+}
+
+func (p *Presentation) RenderEmbedProgressSVG(w io.Writer, view EmbedProgressView) error {
+	return p.tmpl.ExecuteTemplate(w, "embed_progress_svg", view)
+}
+
+func (p *Presentation) RenderEmbedProgressPage(w io.Writer, view EmbedProgressView) error {
+	return p.tmpl.ExecuteTemplate(w, "embed_progress_page", view)
+}
@@ -0,0 +1,13 @@
+package web
+
+import "io"
+
+// ErrorView is the view model for the page shown when a request fails on
+// our side
+type ErrorView struct {
+	RequestID string
+}
+
+func (p *Presentation) RenderError(w io.Writer, view ErrorView) error {
+	return p.tmpl.ExecuteTemplate(w, "error_page", view)
+}
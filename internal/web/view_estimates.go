@@ -0,0 +1,56 @@
+package web
+
+import (
+	"math"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// ReforecastView asks for a new estimate once the hours logged on a task
+// have used up most of the old one
+type ReforecastView struct {
+	Estimate  string // the estimate now, for reading
+	Logged    string // hours logged so far, for reading
+	Percent   int    // of the estimate logged so far
+	Suggested string // a form value projected from the task's progress
+}
+
+// newReforecastView prompts for a new estimate if the task, with its work
+// logs loaded, needs one, and is nil otherwise
+func newReforecastView(t *domain.Task, auth AuthContext) *ReforecastView {
+	if !t.NeedsReforecast() {
+		return nil
+	}
+	logged := t.HoursLogged()
+	return &ReforecastView{
+		Estimate:  auth.FormatHours(t.Estimate),
+		Logged:    auth.FormatHours(logged),
+		Percent:   int(math.Round(logged / t.Estimate * 100)),
+		Suggested: formatHours(t.ForecastEstimate()),
+	}
+}
+
+// EstimateChangeView is one re-forecast in a task's estimate history
+type EstimateChangeView struct {
+	Previous string // empty if the task was unestimated
+	Estimate string
+	Logged   string
+	Author   Profile
+	When     string
+}
+
+func newEstimateChangeViews(changes []*domain.EstimateChange, auth AuthContext) []EstimateChangeView {
+	views := make([]EstimateChangeView, len(changes))
+	for i, c := range changes {
+		views[i] = EstimateChangeView{
+			Estimate: auth.FormatHours(c.Estimate),
+			Logged:   auth.FormatHours(c.HoursLogged),
+			Author:   auth.profiles.Resolve(c.Author),
+			When:     auth.FormatDate(c.CreatedAt),
+		}
+		if c.Previous > 0 {
+			views[i].Previous = auth.FormatHours(c.Previous)
+		}
+	}
+	return views
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"io"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// LinkedTaskView is a task counted towards a key result
+type LinkedTaskView struct {
+	AuthContext
+	ID           string
+	Name         string
+	CategoryName string
+	Completion   int
+	DetailsURL   string
+	UnlinkURL    string
+}
+
+// KeyResultView is one key result on the goals page
+type KeyResultView struct {
+	AuthContext
+	ID         string
+	Name       string
+	Progress   int
+	FromTasks  bool
+	Current    string
+	Target     string
+	Unit       string
+	URL        string
+	Tasks      []LinkedTaskView
+	Categories []PlanCategoryOption // tasks that can be linked
+}
+
+// ObjectiveView is one objective with its key results
+type ObjectiveView struct {
+	AuthContext
+	ID          string
+	Name        string
+	Description string
+	Progress    int
+	URL         string
+	KeyResults  []KeyResultView
+}
+
+// GoalsView is the view model for a quarter's review page
+type GoalsView struct {
+	AuthContext
+	Quarter    string // e.g. 2026-Q4
+	Title      string
+	URL        string
+	PrevURL    string
+	NextURL    string
+	CurrentURL string
+	IsCurrent  bool
+	Progress   int // averaged over the objectives
+	Objectives []ObjectiveView
+}
+
+// NewGoalsView creates the review of the quarter starting at start
+func NewGoalsView(start time.Time, objectives []*domain.Objective, categories []*domain.Category, current string, auth AuthContext) GoalsView {
+	quarter := domain.Quarter(start)
+	view := GoalsView{
+		AuthContext: auth,
+		Quarter:     quarter,
+		Title:       "Goals for " + quarterLabel(quarter),
+		URL:         "/goals/" + quarter,
+		PrevURL:     "/goals/" + domain.Quarter(start.AddDate(0, -3, 0)),
+		NextURL:     "/goals/" + domain.Quarter(start.AddDate(0, 3, 0)),
+		CurrentURL:  "/goals/" + current,
+		IsCurrent:   quarter == current,
+	}
+
+	var options []PlanCategoryOption
+	for _, c := range categories {
+		option := PlanCategoryOption{Name: c.Name}
+		for _, t := range c.Tasks {
+			if auth.InContext(t.ID) {
+				option.Tasks = append(option.Tasks, PlanTaskOption{ID: t.ID, Name: t.Name})
+			}
+		}
+		if len(option.Tasks) > 0 {
+			options = append(options, option)
+		}
+	}
+
+	total := 0
+	for _, o := range objectives {
+		ov := ObjectiveView{
+			AuthContext: auth,
+			ID:          o.ID,
+			Name:        o.Name,
+			Description: o.Description,
+			Progress:    o.Progress(),
+			URL:         "/objectives/" + o.ID,
+		}
+		for _, kr := range o.KeyResults {
+			krv := KeyResultView{
+				AuthContext: auth,
+				ID:          kr.ID,
+				Name:        kr.Name,
+				Progress:    kr.Progress(),
+				FromTasks:   kr.FromTasks,
+				Current:     formatHours(kr.Current),
+				Target:      formatCapacity(kr.Target),
+				Unit:        kr.Unit,
+				URL:         "/key-results/" + kr.ID,
+				Categories:  options,
+			}
+			for _, t := range kr.Tasks {
+				krv.Tasks = append(krv.Tasks, LinkedTaskView{
+					AuthContext:  auth,
+					ID:           t.ID,
+					Name:         t.Name,
+					CategoryName: t.CategoryName,
+					Completion:   t.Completion,
+					DetailsURL:   "/tasks/" + t.ID + "/details",
+					UnlinkURL:    krv.URL + "/tasks/" + t.ID,
+				})
+			}
+			ov.KeyResults = append(ov.KeyResults, krv)
+		}
+		total += ov.Progress
+		view.Objectives = append(view.Objectives, ov)
+	}
+	if len(objectives) > 0 {
+		view.Progress = total / len(objectives)
+	}
+	return view
+}
+
+// TaskKeyResultView is a key result shown in a task's details
+type TaskKeyResultView struct {
+	AuthContext
+	Name          string
+	ObjectiveName string
+	Progress      int
+	UnlinkURL     string
+}
+
+// KeyResultOption is a key result a task can be linked to
+type KeyResultOption struct {
+	ID            string
+	Name          string
+	ObjectiveName string
+}
+
+// NewTaskKeyResultViews lists the key results linked to a task, and those
+// of the given objectives it is not yet linked to
+func NewTaskKeyResultViews(taskID string, linked []*domain.KeyResult, objectives []*domain.Objective, auth AuthContext) ([]TaskKeyResultView, []KeyResultOption) {
+	var views []TaskKeyResultView
+	isLinked := make(map[string]bool)
+	for _, kr := range linked {
+		isLinked[kr.ID] = true
+		views = append(views, TaskKeyResultView{
+			AuthContext:   auth,
+			Name:          kr.Name,
+			ObjectiveName: kr.Objective,
+			Progress:      kr.Progress(),
+			UnlinkURL:     "/key-results/" + kr.ID + "/tasks/" + taskID,
+		})
+	}
+
+	var options []KeyResultOption
+	for _, o := range objectives {
+		for _, kr := range o.KeyResults {
+			if !isLinked[kr.ID] {
+				options = append(options, KeyResultOption{ID: kr.ID, Name: kr.Name, ObjectiveName: o.Name})
+			}
+		}
+	}
+	return views, options
+}
+
+func (p *Presentation) RenderGoals(w io.Writer, view GoalsView) error {
+	return p.tmpl.ExecuteTemplate(w, "goals", view)
+}
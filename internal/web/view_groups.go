@@ -0,0 +1,79 @@
+package web
+
+import (
+	"io"
+	"slices"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// GroupView is one group on the groups page
+type GroupView struct {
+	AuthContext
+	ID      string
+	Name    string
+	Members []Profile
+}
+
+// GroupsView is the view model for managing groups
+type GroupsView struct {
+	AuthContext
+	Groups []GroupView
+}
+
+// NewGroupsView lists the groups by name, with their members
+func NewGroupsView(groups []*domain.Group, auth AuthContext) GroupsView {
+	view := GroupsView{AuthContext: auth}
+	for _, g := range groups {
+		view.Groups = append(view.Groups, GroupView{
+			AuthContext: auth,
+			ID:          g.ID,
+			Name:        g.Name,
+			Members:     resolveProfiles(g.Members, auth),
+		})
+	}
+	return view
+}
+
+// CategoryAccessView is who a category is shared with, on its details page
+type CategoryAccessView struct {
+	Users        []Profile
+	Groups       []*domain.Group
+	GroupOptions []*domain.Group // groups it could also be shared with
+	Owner        *Profile        // who it belongs to on private boards; nil if everyone's
+	CanShare     bool            // the viewer may change who it is shared with
+}
+
+// NewCategoryAccessView shows who a category is shared with, offering the
+// groups it is not yet shared with. owner is who the category belongs to
+// on private boards, and empty otherwise.
+func NewCategoryAccessView(access *domain.CategoryAccess, groups []*domain.Group, owner string, auth AuthContext) *CategoryAccessView {
+	view := &CategoryAccessView{
+		Users:    resolveProfiles(access.Users, auth),
+		Groups:   access.Groups,
+		CanShare: auth.IsAdmin || owner != "" && owner == auth.Handle,
+	}
+	if owner != "" {
+		profile := auth.profiles.Resolve(owner)
+		view.Owner = &profile
+	}
+	for _, g := range groups {
+		if !slices.ContainsFunc(access.Groups, func(shared *domain.Group) bool { return shared.ID == g.ID }) {
+			view.GroupOptions = append(view.GroupOptions, g)
+		}
+	}
+	return view
+}
+
+// resolveProfiles turns handles into the profiles shown for them
+func resolveProfiles(handles []string, auth AuthContext) []Profile {
+	profiles := make([]Profile, len(handles))
+	for i, h := range handles {
+		profiles[i] = auth.profiles.Resolve(h)
+	}
+	return profiles
+}
+
+func (p *Presentation) RenderGroups(w io.Writer, view GroupsView) error {
+	return p.tmpl.ExecuteTemplate(w, "groups", view)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// RevisionView is the view model for one entry in the history panel
+type RevisionView struct {
+	AuthContext
+	ID             int64
+	Name           string
+	Description    string
+	Completion     int
+	ShowCompletion bool // Categories have no editable completion
+	Public         bool
+	Author         Profile
+	CreatedAt      string // Formatted timestamp
+	Changes        string // What differs from the revision before it
+	Current        bool   // The entity's present state; nothing to restore
+	RestoreURL     string
+	ReturnTo       string // Details URL, for the accessible restore form
+}
+
+// HistoryView is the view model for an entity's revision history
+type HistoryView struct {
+	AuthContext
+	Title      string
+	DetailsURL string
+	Revisions  []RevisionView
+}
+
+// NewHistoryView creates a HistoryView from revisions ordered newest first
+func NewHistoryView(title, detailsURL string, revisions []*domain.Revision, auth AuthContext) HistoryView {
+	view := HistoryView{
+		AuthContext: auth,
+		Title:       title,
+		DetailsURL:  detailsURL,
+		Revisions:   make([]RevisionView, len(revisions)),
+	}
+	for i, rev := range revisions {
+		var older *domain.Revision
+		if i+1 < len(revisions) {
+			older = revisions[i+1]
+		}
+		view.Revisions[i] = RevisionView{
+			AuthContext:    auth,
+			ID:             rev.ID,
+			Name:           rev.Name,
+			Description:    rev.Description,
+			Completion:     rev.Completion,
+			ShowCompletion: rev.EntityType != domain.EntityCategory,
+			Public:         rev.Public,
+			Author:         auth.profiles.Resolve(rev.Author),
+			CreatedAt:      auth.FormatDateTime(rev.CreatedAt),
+			Changes:        describeChanges(older, rev),
+			Current:        i == 0,
+			RestoreURL:     "/revisions/" + strconv.FormatInt(rev.ID, 10) + "/restore",
+			ReturnTo:       detailsURL,
+		}
+	}
+	return view
+}
+
+// describeChanges summarizes what rev changed relative to older
+func describeChanges(older, rev *domain.Revision) string {
+	if older == nil {
+		return "Original version"
+	}
+	var changes []string
+	if rev.Name != older.Name {
+		changes = append(changes, "renamed")
+	}
+	if rev.Description != older.Description {
+		changes = append(changes, "description edited")
+	}
+	if rev.Completion != older.Completion {
+		changes = append(changes, "completion "+strconv.Itoa(older.Completion)+"% → "+strconv.Itoa(rev.Completion)+"%")
+	}
+	if rev.Public != older.Public {
+		if rev.Public {
+			changes = append(changes, "made public")
+		} else {
+			changes = append(changes, "made private")
+		}
+	}
+	if len(changes) == 0 {
+		return "No changes"
+	}
+	s := strings.Join(changes, ", ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// RenderHistory renders the revision list for the details history panel
+func (p *Presentation) RenderHistory(w io.Writer, view HistoryView) error {
+	return p.tmpl.ExecuteTemplate(w, "history", view)
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"io"
+	"strings"
+)
+
+// Landing is what an instance that requires login tells signed-out
+// visitors about itself
+type Landing struct {
+	Name        string // e.g. the team or company; defaults to In Progress
+	Description string // plain text; blank lines separate paragraphs
+}
+
+// LandingView is the view model for the page signed-out visitors see
+type LandingView struct {
+	AuthContext
+	Name       string
+	Paragraphs []string
+}
+
+func NewLandingView(landing *Landing, auth AuthContext) LandingView {
+	view := LandingView{AuthContext: auth, Name: landing.Name}
+	if view.Name == "" {
+		view.Name = "In Progress"
+	}
+	for _, p := range strings.Split(strings.ReplaceAll(landing.Description, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			view.Paragraphs = append(view.Paragraphs, p)
+		}
+	}
+	return view
+}
+
+func (p *Presentation) RenderLanding(w io.Writer, view LandingView) error {
+	return p.tmpl.ExecuteTemplate(w, "landing_page", view)
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/url"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// LinkView is the view model for Link
+type LinkView struct {
+	AuthContext
+	ID         string
+	Kind       string
+	URL        string
+	Title      string // Page title, or the host until a preview is fetched
+	Host       string
+	FaviconURL string // Empty when the site has no cached favicon
+	DeleteURL  string
+}
+
+// NewLinkView creates a LinkView from a domain Link
+func NewLinkView(l *domain.Link, auth AuthContext) LinkView {
+	view := LinkView{
+		AuthContext: auth,
+		ID:          l.ID,
+		Kind:        l.Kind,
+		URL:         l.URL,
+		Title:       l.Title,
+		DeleteURL:   "/links/" + l.ID,
+	}
+	if u, err := url.Parse(l.URL); err == nil {
+		view.Host = u.Host
+	}
+	if view.Title == "" {
+		view.Title = view.Host
+	}
+	if l.HasFavicon {
+		view.FaviconURL = "/links/" + l.ID + "/favicon"
+	}
+	return view
+}
+
+func NewLinkViews(links []*domain.Link, auth AuthContext) []LinkView {
+	if links == nil {
+		return nil
+	}
+	views := make([]LinkView, len(links))
+	for i, l := range links {
+		views[i] = NewLinkView(l, auth)
+	}
+	return views
+}
@@ -0,0 +1,41 @@
+package web
+
+import "io"
+
+// DescriptionMergeView is the view model for resolving a description that
+// someone else changed while it was being edited
+type DescriptionMergeView struct {
+	AuthContext
+	ID         string
+	Title      string // Name of the entity, for the accessible page heading
+	UpdateURL  string // Where the resolved description is submitted
+	DetailsURL string
+	Mine       string // What the editor submitted
+	Theirs     string // What is stored now
+	Merged     string // Starting point for a manual merge
+}
+
+// NewDescriptionMergeView creates a DescriptionMergeView. The merge starts
+// from both versions, stored first, so nothing is lost by saving it as is.
+func NewDescriptionMergeView(id, title, updateURL, detailsURL, mine, theirs string, auth AuthContext) DescriptionMergeView {
+	merged := theirs
+	if theirs == "" {
+		merged = mine
+	} else if mine != "" {
+		merged = theirs + "\n\n" + mine
+	}
+	return DescriptionMergeView{
+		AuthContext: auth,
+		ID:          id,
+		Title:       title,
+		UpdateURL:   updateURL,
+		DetailsURL:  detailsURL,
+		Mine:        mine,
+		Theirs:      theirs,
+		Merged:      merged,
+	}
+}
+
+func (p *Presentation) RenderDescriptionMerge(w io.Writer, view DescriptionMergeView) error {
+	return p.tmpl.ExecuteTemplate(w, "description_merge", view)
+}
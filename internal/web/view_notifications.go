@@ -0,0 +1,52 @@
+package web
+
+import (
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// NotificationView is the view model for one entry in the inbox
+type NotificationView struct {
+	AuthContext
+	ID        string
+	Title     string
+	Body      string
+	URL       string
+	Read      bool
+	CreatedAt string // Formatted timestamp
+	ReadURL   string
+}
+
+// NotificationsView is the view model for the inbox slideover
+type NotificationsView struct {
+	AuthContext
+	Notifications []NotificationView
+}
+
+// NewNotificationsView creates a NotificationsView from notifications
+// ordered newest first
+func NewNotificationsView(notifications []*domain.Notification, auth AuthContext) NotificationsView {
+	view := NotificationsView{AuthContext: auth}
+	for _, n := range notifications {
+		view.Notifications = append(view.Notifications, NotificationView{
+			AuthContext: auth,
+			ID:          n.ID,
+			Title:       n.Title,
+			Body:        n.Body,
+			URL:         n.URL,
+			Read:        n.Read,
+			CreatedAt:   auth.FormatDateTime(n.CreatedAt),
+			ReadURL:     "/notifications/" + n.ID + "/read",
+		})
+	}
+	return view
+}
+
+func (p *Presentation) RenderNotifications(w io.Writer, view NotificationsView) error {
+	return p.tmpl.ExecuteTemplate(w, "notifications", view)
+}
+
+func (p *Presentation) RenderNotificationBell(w io.Writer, auth AuthContext) error {
+	return p.tmpl.ExecuteTemplate(w, "notification_bell", auth)
+}
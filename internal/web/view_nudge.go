@@ -0,0 +1,57 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// NudgeView is the view model for one of the viewer's reminders on a task
+type NudgeView struct {
+	AuthContext
+	ID        string
+	Schedule  string // e.g. "Every Tuesday at 09:00"
+	NextAt    string
+	DeleteURL string
+}
+
+// WeekdayOption is a choice in the nudge schedule picker
+type WeekdayOption struct {
+	Value int
+	Label string
+}
+
+var nudgeWeekdays = []WeekdayOption{
+	{domain.EveryDay, "Every day"},
+	{int(time.Monday), "Every Monday"},
+	{int(time.Tuesday), "Every Tuesday"},
+	{int(time.Wednesday), "Every Wednesday"},
+	{int(time.Thursday), "Every Thursday"},
+	{int(time.Friday), "Every Friday"},
+	{int(time.Saturday), "Every Saturday"},
+	{int(time.Sunday), "Every Sunday"},
+}
+
+// newNudgeViews lists the viewer's own nudges; other people's reminders
+// are none of their business
+func newNudgeViews(nudges []*domain.Nudge, auth AuthContext) []NudgeView {
+	var views []NudgeView
+	for _, n := range nudges {
+		if !auth.IsAuthenticated || n.UserID != auth.Handle {
+			continue
+		}
+		day := "Every day"
+		if n.Weekday != domain.EveryDay {
+			day = "Every " + time.Weekday(n.Weekday).String()
+		}
+		views = append(views, NudgeView{
+			AuthContext: auth,
+			ID:          n.ID,
+			Schedule:    fmt.Sprintf("%s at %02d:%02d", day, n.Hour, n.Minute),
+			NextAt:      auth.FormatDateTime(n.NextAt),
+			DeleteURL:   "/nudges/" + n.ID,
+		})
+	}
+	return views
+}
@@ -5,6 +5,12 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"golang.org/x/text/language"
 )
 
 // AuthContext carries authentication state through view models
@@ -14,12 +20,124 @@ type AuthContext struct {
 	CSRFToken       string // For CSRF protection on forms
 	LoginURL        string // Where login button should link
 	LogoutURL       string // Where logout button should link
+	Accessible      bool   // Render plain forms and links instead of HTMX interactions
+	Mobile          bool   // Render the mobile layout (bottom sheet, condensed cards)
+	WorkLogLedger   bool   // Work logs are corrected with adjustment entries, never edited
+	IsAdmin         bool   // Manages groups and who categories are shared with
+
+	UnreadNotifications int         // For the header bell; only counted on page loads
+	Timers              []TimerView // For the header widget
+	MultipleTimers      bool        // May start more than one timer at once, each named
+
+	Context  string   // The context every view is filtered to; empty for everything
+	Contexts []string // Every context in use, for the header switcher
+
+	Tags    []string      // The tags the board is narrowed to; a task must carry them all
+	AllTags []*domain.Tag // Every tag, for the header filter
+
+	profiles *ProfileCache  // Resolves attribution chips; nil falls back to raw handles
+	refs     *TaskRefCache  // Resolves task references in text; nil leaves them as written
+	location *time.Location // Zone for displaying and parsing timestamps; nil means server local
+	lang     language.Tag   // Conventions for writing dates and numbers; Und means the fallback
+
+	inContext     map[string]bool // IDs of the tasks in Context
+	hidden        map[string]bool // IDs of the categories shared only with others
+	loginRequired bool            // signed out on an instance that shows them only its landing page
+}
+
+// Location is the zone the viewer reads and enters times in
+func (a AuthContext) Location() *time.Location {
+	if a.location == nil {
+		return time.Local
+	}
+	return a.location
+}
+
+// InContext reports whether a task belongs in views filtered to the viewer's
+// context
+func (a AuthContext) InContext(taskID string) bool {
+	return a.Context == "" || a.inContext[taskID]
+}
+
+// Tagged reports whether a task carrying tags belongs on a board narrowed
+// to the viewer's chosen tags
+func (a AuthContext) Tagged(tags []*domain.Tag) bool {
+	for _, name := range a.Tags {
+		found := false
+		for _, t := range tags {
+			if strings.EqualFold(t.Name, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TagFilterURL is the board narrowed to the viewer's tags with add added
+// and remove left out
+func (a AuthContext) TagFilterURL(add, remove string) string {
+	q := url.Values{}
+	for _, name := range a.Tags {
+		if !strings.EqualFold(name, remove) && !strings.EqualFold(name, add) {
+			q.Add("tag", name)
+		}
+	}
+	if add != "" {
+		q.Add("tag", add)
+	}
+	if len(q) == 0 {
+		return "/"
+	}
+	return "/?" + q.Encode()
+}
+
+// EventsURL is where the board listens for changes, narrowed to the
+// viewer's tags
+func (a AuthContext) EventsURL() string {
+	q := url.Values{}
+	for _, name := range a.Tags {
+		q.Add("tag", name)
+	}
+	if len(q) == 0 {
+		return "/events"
+	}
+	return "/events?" + q.Encode()
+}
+
+// CanSee reports whether the viewer may see a category, which they may
+// unless it is shared with others and not with them, or they are signed
+// out of an instance that requires login
+func (a AuthContext) CanSee(categoryID string) bool {
+	return !a.loginRequired && !a.hidden[categoryID]
+}
+
+// visibleCategories drops the categories the viewer may not see from a board
+func visibleCategories(categories []CategoryView, auth AuthContext) []CategoryView {
+	if len(auth.hidden) == 0 && !auth.loginRequired {
+		return categories
+	}
+	var visible []CategoryView
+	for _, c := range categories {
+		if auth.CanSee(c.ID) {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+func (a AuthContext) mobileLayout() bool {
+	return a.Mobile
 }
 
 type PageView struct {
 	AuthContext
 	Categories    []CategoryView
 	ActiveDetails template.HTML // Pre-rendered details for deep linking
+	Undo          *UndoView     // Undo toast after a non-HTMX deletion
 	OOB           bool          // Always false for full page renders
 }
 
@@ -27,14 +145,19 @@ type DeleteOOBView struct {
 	ID string
 }
 
-func (p *Presentation) RenderIndex(w io.Writer, categories []CategoryView, auth AuthContext) error {
-	return p.RenderIndexWithDetails(w, categories, auth, nil)
+func (p *Presentation) RenderIndex(w io.Writer, categories []CategoryView, auth AuthContext, undo *UndoView) error {
+	pageView := PageView{
+		AuthContext: auth,
+		Categories:  visibleCategories(categories, auth),
+		Undo:        undo,
+	}
+	return p.tmpl.ExecuteTemplate(w, "layout.html", pageView)
 }
 
 func (p *Presentation) RenderIndexWithDetails(w io.Writer, categories []CategoryView, auth AuthContext, detailsView any) error {
 	pageView := PageView{
 		AuthContext: auth,
-		Categories:  categories,
+		Categories:  visibleCategories(categories, auth),
 	}
 
 	if detailsView != nil {
@@ -53,6 +176,78 @@ func (p *Presentation) RenderIndexWithDetails(w io.Writer, categories []Category
 			if err := p.tmpl.ExecuteTemplate(&buf, "category_details", v); err != nil {
 				return err
 			}
+		case SettingsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "settings", v); err != nil {
+				return err
+			}
+		case NotificationsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "notifications", v); err != nil {
+				return err
+			}
+		case QueueView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "queue", v); err != nil {
+				return err
+			}
+		case PlanView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "plan", v); err != nil {
+				return err
+			}
+		case GoalsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "goals", v); err != nil {
+				return err
+			}
+		case BlockedView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "blocked", v); err != nil {
+				return err
+			}
+		case WorkSessionsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "work_sessions", v); err != nil {
+				return err
+			}
+		case SuggestView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "suggest", v); err != nil {
+				return err
+			}
+		case CategoryMergeView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "category_merge", v); err != nil {
+				return err
+			}
+		case CategoryImportView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "category_import", v); err != nil {
+				return err
+			}
+		case DuplicateWarningView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "duplicate_warning", v); err != nil {
+				return err
+			}
+		case WIPWarningView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "wip_warning", v); err != nil {
+				return err
+			}
+		case HistoryView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "history_page", v); err != nil {
+				return err
+			}
+		case DescriptionMergeView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "description_merge_page", v); err != nil {
+				return err
+			}
+		case GroupsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "groups", v); err != nil {
+				return err
+			}
+		case SearchView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "search", v); err != nil {
+				return err
+			}
+		case TagsView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "tags", v); err != nil {
+				return err
+			}
+		case WorkloadView:
+			if err := p.tmpl.ExecuteTemplate(&buf, "workload", v); err != nil {
+				return err
+			}
 		default:
 			return fmt.Errorf("unknown details view type: %T", v)
 		}
@@ -63,6 +258,26 @@ func (p *Presentation) RenderIndexWithDetails(w io.Writer, categories []Category
 	return p.tmpl.ExecuteTemplate(w, "layout.html", pageView)
 }
 
+// RenderCategoryList renders the contents of the categories list, including
+// the empty-board onboarding fragment when there is nothing to show.
+func (p *Presentation) RenderCategoryList(w io.Writer, categories []CategoryView, auth AuthContext) error {
+	pageView := PageView{
+		AuthContext: auth,
+		Categories:  visibleCategories(categories, auth),
+	}
+	return p.tmpl.ExecuteTemplate(w, "category_list", pageView)
+}
+
+// RenderBoardEvent renders the categories list to be swapped in out of band
+// when the board changes
+func (p *Presentation) RenderBoardEvent(w io.Writer, categories []CategoryView, auth AuthContext) error {
+	pageView := PageView{
+		AuthContext: auth,
+		Categories:  visibleCategories(categories, auth),
+	}
+	return p.tmpl.ExecuteTemplate(w, "board_event", pageView)
+}
+
 func (p *Presentation) RenderSlideoverClear(w io.Writer) error {
 	view := PageView{
 		ActiveDetails: "",
@@ -87,6 +302,14 @@ func (p *Presentation) RenderSlideoverWithDetails(w io.Writer, detailsView any)
 		if err := p.tmpl.ExecuteTemplate(&buf, "subtask_details", v); err != nil {
 			return err
 		}
+	case SettingsView:
+		if err := p.tmpl.ExecuteTemplate(&buf, "settings", v); err != nil {
+			return err
+		}
+	case NotificationsView:
+		if err := p.tmpl.ExecuteTemplate(&buf, "notifications", v); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unknown details view type: %T", v)
 	}
@@ -97,3 +320,19 @@ func (p *Presentation) RenderSlideoverWithDetails(w io.Writer, detailsView any)
 	}
 	return p.tmpl.ExecuteTemplate(w, "slideover_container", view)
 }
+
+// RenderCategoryWithDetails renders the response to a change made from the
+// details slideover: the category as an OOB update, plus the refreshed
+// details, so the open slideover reflects the change. Both are rendered
+// before anything is written so a failure cannot leave half a response.
+func (p *Presentation) RenderCategoryWithDetails(w io.Writer, category CategoryView, details any) error {
+	var buf bytes.Buffer
+	if err := p.RenderCategoryOOB(&buf, category); err != nil {
+		return err
+	}
+	if err := p.RenderSlideoverWithDetails(&buf, details); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
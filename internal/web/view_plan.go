@@ -0,0 +1,212 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// Planner rows are a quarter hour tall, and the grid shows at least the
+// working day, growing to fit any block outside it
+const (
+	planSlot      = 15
+	planDayStarts = 6 * 60
+	planDayEnds   = 22 * 60
+)
+
+// PlanHourView labels one hour of the day grid
+type PlanHourView struct {
+	Label string
+	Row   int
+}
+
+// TimeBlockView is one block on the day grid
+type TimeBlockView struct {
+	AuthContext
+	ID         string
+	TaskName   string
+	DetailsURL string
+	URL        string
+	Start      string // HH:MM
+	End        string
+	Hours      string
+	RowStart   int
+	RowEnd     int
+	Ended      bool // over, so it can be logged as work
+	Logged     bool
+}
+
+// PlanTaskOption is a task that can be put into a block
+type PlanTaskOption struct {
+	ID   string
+	Name string
+}
+
+// PlanCategoryOption groups task options under their category
+type PlanCategoryOption struct {
+	Name  string
+	Tasks []PlanTaskOption
+}
+
+// PlanView is the view model for the day planner
+type PlanView struct {
+	AuthContext
+	Date       string // YYYY-MM-DD
+	Title      string
+	URL        string
+	PrevURL    string
+	NextURL    string
+	TodayURL   string
+	IsToday    bool
+	Hours      []PlanHourView
+	Blocks     []TimeBlockView
+	Categories []PlanCategoryOption
+	TaskID     string // preselected in the form
+	Rows       int
+	Week       WeekView
+}
+
+// AllocationView is one task's planned and logged hours for the week
+type AllocationView struct {
+	AuthContext
+	TaskID     string
+	TaskName   string
+	DetailsURL string
+	Planned    string
+	Logged     string
+	Unplanned  bool // logged on without being planned
+	Overrun    bool // more logged than planned
+}
+
+// WeekView weighs the week's plan against the user's capacity. The load is
+// what has been logged plus what is planned but not yet logged.
+type WeekView struct {
+	AuthContext
+	Label       string
+	URL         string // for planning hours
+	Capacity    string // empty if unset
+	Planned     string
+	Logged      string
+	Load        string
+	Balance     string // hours over or under capacity
+	Over        bool
+	Allocations []AllocationView
+}
+
+// NewPlanView lays out the blocks of day on a grid. now decides which
+// blocks are over.
+func NewPlanView(day time.Time, blocks []*domain.TimeBlock, categories []*domain.Category, taskID string, now time.Time, auth AuthContext) PlanView {
+	date := day.Format(time.DateOnly)
+	view := PlanView{
+		AuthContext: auth,
+		Date:        date,
+		Title:       day.Format("Monday") + ", " + auth.FormatLongDate(day),
+		URL:         "/plan/" + date,
+		PrevURL:     "/plan/" + day.AddDate(0, 0, -1).Format(time.DateOnly),
+		NextURL:     "/plan/" + day.AddDate(0, 0, 1).Format(time.DateOnly),
+		TodayURL:    "/plan/" + now.In(auth.Location()).Format(time.DateOnly),
+		IsToday:     date == now.In(auth.Location()).Format(time.DateOnly),
+		TaskID:      taskID,
+	}
+
+	// Widen the grid to whole hours around every block
+	first, last := planDayStarts, planDayEnds
+	for _, b := range blocks {
+		first = min(first, b.Start/60*60)
+		last = max(last, (b.End+59)/60*60)
+	}
+	row := func(minute int) int {
+		return (minute-first)/planSlot + 1
+	}
+	for m := first; m < last; m += 60 {
+		view.Hours = append(view.Hours, PlanHourView{
+			Label: formatMinutes(m),
+			Row:   row(m),
+		})
+	}
+	view.Rows = row(last) - 1
+
+	for _, b := range blocks {
+		view.Blocks = append(view.Blocks, TimeBlockView{
+			AuthContext: auth,
+			ID:          b.ID,
+			TaskName:    b.TaskName,
+			DetailsURL:  "/tasks/" + b.TaskID + "/details",
+			URL:         "/blocks/" + b.ID,
+			Start:       formatMinutes(b.Start),
+			End:         formatMinutes(b.End),
+			Hours:       auth.FormatHours(b.Hours()),
+			RowStart:    row(b.Start),
+			RowEnd:      max(row((b.End+planSlot-1)/planSlot*planSlot), row(b.Start)+1),
+			Ended:       !b.EndsAt(auth.Location()).After(now),
+			Logged:      b.WorkLogID != "",
+		})
+	}
+
+	for _, c := range categories {
+		option := PlanCategoryOption{Name: c.Name}
+		for _, t := range c.Tasks {
+			if auth.InContext(t.ID) {
+				option.Tasks = append(option.Tasks, PlanTaskOption{ID: t.ID, Name: t.Name})
+			}
+		}
+		if len(option.Tasks) > 0 {
+			view.Categories = append(view.Categories, option)
+		}
+	}
+	return view
+}
+
+// NewWeekView totals the allocations for the week starting on start
+func NewWeekView(start time.Time, allocations []*domain.Allocation, capacity float64, url string, auth AuthContext) WeekView {
+	view := WeekView{
+		AuthContext: auth,
+		Label:       "Week of " + auth.FormatLongDate(start),
+		URL:         url,
+	}
+	var planned, logged, load float64
+	for _, a := range allocations {
+		planned += a.Planned
+		logged += a.Logged
+		load += a.Logged + a.Remaining()
+		view.Allocations = append(view.Allocations, AllocationView{
+			AuthContext: auth,
+			TaskID:      a.TaskID,
+			TaskName:    a.TaskName,
+			DetailsURL:  "/tasks/" + a.TaskID + "/details",
+			Planned:     formatHours(a.Planned),
+			Logged:      auth.FormatHours(a.Logged),
+			Unplanned:   a.Planned == 0,
+			Overrun:     a.Planned > 0 && a.Logged > a.Planned,
+		})
+	}
+	view.Planned = auth.FormatHours(planned)
+	view.Logged = auth.FormatHours(logged)
+	view.Load = auth.FormatHours(load)
+	if capacity > 0 {
+		view.Capacity = auth.FormatHours(capacity)
+		view.Over = load > capacity
+		view.Balance = auth.FormatHours(math.Abs(capacity - load))
+	}
+	return view
+}
+
+// formatCapacity leaves an unset capacity blank
+func formatCapacity(hours float64) string {
+	if hours == 0 {
+		return ""
+	}
+	return formatHours(hours)
+}
+
+// formatMinutes writes minutes past midnight as HH:MM
+func formatMinutes(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func (p *Presentation) RenderPlan(w io.Writer, view PlanView) error {
+	return p.tmpl.ExecuteTemplate(w, "plan", view)
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"io"
+	"slices"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// QueuedTaskView is one task on the focus queue
+type QueuedTaskView struct {
+	AuthContext
+	TaskID       string
+	TaskName     string
+	CategoryName string
+	Completion   int
+	DetailsURL   string
+	QueueURL     string // for removing it from the queue
+	MoveURL      string
+	First        bool
+	Last         bool
+}
+
+// QueueView is the view model for the focus queue slideover
+type QueueView struct {
+	AuthContext
+	Tasks []QueuedTaskView
+}
+
+// NewQueueView creates a QueueView from the queue in order
+func NewQueueView(queue []*domain.QueuedTask, auth AuthContext) QueueView {
+	view := QueueView{AuthContext: auth}
+	queue = slices.DeleteFunc(slices.Clone(queue), func(q *domain.QueuedTask) bool {
+		return !auth.InContext(q.TaskID)
+	})
+	for i, q := range queue {
+		view.Tasks = append(view.Tasks, QueuedTaskView{
+			AuthContext:  auth,
+			TaskID:       q.TaskID,
+			TaskName:     q.TaskName,
+			CategoryName: q.CategoryName,
+			Completion:   q.Completion,
+			DetailsURL:   "/tasks/" + q.TaskID + "/details",
+			QueueURL:     "/tasks/" + q.TaskID + "/queue",
+			MoveURL:      "/queue/" + q.TaskID + "/move",
+			First:        i == 0,
+			Last:         i == len(queue)-1,
+		})
+	}
+	return view
+}
+
+func (p *Presentation) RenderQueue(w io.Writer, view QueueView) error {
+	return p.tmpl.ExecuteTemplate(w, "queue", view)
+}
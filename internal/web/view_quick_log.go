@@ -0,0 +1,66 @@
+package web
+
+import (
+	"io"
+	"strconv"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// quickLogHours are the amounts offered as one-tap buttons
+var quickLogHours = []float64{0.25, 0.5, 1, 2, 4}
+
+// QuickLogItem is one recently worked task offered on the quick-log page
+type QuickLogItem struct {
+	TaskID       string
+	SubtaskID    string
+	Name         string
+	CategoryName string
+	Completion   int
+	LastHours    string
+	LastLoggedAt string
+}
+
+// QuickLogView is the view model for the mobile quick-log page
+type QuickLogView struct {
+	AuthContext
+	Recent []QuickLogItem
+	Hours  []string
+	Logged *QuickLogItem // What was just logged, to confirm it
+}
+
+// NewQuickLogView creates a QuickLogView from recent work, most recent
+// first. If logged is set, the first entry is what was just logged.
+func NewQuickLogView(recent []*domain.RecentWork, logged bool, auth AuthContext) QuickLogView {
+	view := QuickLogView{AuthContext: auth}
+	for _, h := range quickLogHours {
+		view.Hours = append(view.Hours, formatHours(h))
+	}
+	for _, r := range recent {
+		name := r.TaskName
+		if r.SubtaskName != "" {
+			name += " › " + r.SubtaskName
+		}
+		view.Recent = append(view.Recent, QuickLogItem{
+			TaskID:       r.TaskID,
+			SubtaskID:    r.SubtaskID,
+			Name:         name,
+			CategoryName: r.CategoryName,
+			Completion:   r.Completion,
+			LastHours:    formatHours(r.LastHours),
+			LastLoggedAt: auth.FormatDateTime(r.LastLoggedAt),
+		})
+	}
+	if logged && len(view.Recent) > 0 {
+		view.Logged = &view.Recent[0]
+	}
+	return view
+}
+
+func formatHours(h float64) string {
+	return strconv.FormatFloat(h, 'f', -1, 64)
+}
+
+func (p *Presentation) RenderQuickLog(w io.Writer, view QuickLogView) error {
+	return p.tmpl.ExecuteTemplate(w, "quick_log", view)
+}
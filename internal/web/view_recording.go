@@ -0,0 +1,78 @@
+package web
+
+import (
+	"io"
+	"slices"
+	"strings"
+)
+
+// RecordingSummary is one line in the list of recorded exchanges
+type RecordingSummary struct {
+	Seq      int
+	At       string
+	Method   string
+	URL      string
+	Status   int
+	HTMX     bool
+	OOBCount int
+	Trigger  string
+	Duration string
+}
+
+// RecordingsView is the view model for the list of recorded exchanges
+type RecordingsView struct {
+	Dir       string
+	Exchanges []RecordingSummary
+}
+
+// RecordingView is the view model for one recorded exchange
+type RecordingView struct {
+	*Exchange
+	RequestHeaderLines  []string
+	ResponseHeaderLines []string
+}
+
+func NewRecordingsView(exchanges []*Exchange, dir string) RecordingsView {
+	view := RecordingsView{Dir: dir}
+	for _, ex := range exchanges {
+		view.Exchanges = append(view.Exchanges, RecordingSummary{
+			Seq:      ex.Seq,
+			At:       ex.At.Format("15:04:05"),
+			Method:   ex.Method,
+			URL:      ex.URL,
+			Status:   ex.Status,
+			HTMX:     ex.RequestHeaders.Get("HX-Request") == "true",
+			OOBCount: len(ex.OOB),
+			Trigger:  ex.ResponseHeaders.Get("HX-Trigger"),
+			Duration: ex.Duration.String(),
+		})
+	}
+	return view
+}
+
+func NewRecordingView(ex *Exchange) RecordingView {
+	return RecordingView{
+		Exchange:            ex,
+		RequestHeaderLines:  headerLines(ex.RequestHeaders),
+		ResponseHeaderLines: headerLines(ex.ResponseHeaders),
+	}
+}
+
+func headerLines(h map[string][]string) []string {
+	var lines []string
+	for name, values := range h {
+		for _, v := range values {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	slices.SortFunc(lines, strings.Compare)
+	return lines
+}
+
+func (p *Presentation) RenderRecordings(w io.Writer, view RecordingsView) error {
+	return p.tmpl.ExecuteTemplate(w, "recordings", view)
+}
+
+func (p *Presentation) RenderRecording(w io.Writer, view RecordingView) error {
+	return p.tmpl.ExecuteTemplate(w, "recording", view)
+}
@@ -0,0 +1,32 @@
+package web
+
+import "git.sr.ht/~jakintosh/compass/internal/domain"
+
+// ReviewTransitionView is one move in a task's review history
+type ReviewTransitionView struct {
+	From   string
+	To     string
+	Note   string // why it was sent back, if it was
+	Author Profile
+	When   string
+}
+
+func newReviewTransitionViews(transitions []*domain.ReviewTransition, auth AuthContext) []ReviewTransitionView {
+	views := make([]ReviewTransitionView, len(transitions))
+	for i, rt := range transitions {
+		views[i] = ReviewTransitionView{
+			From:   domain.ReviewStateNames[rt.From],
+			To:     domain.ReviewStateNames[rt.To],
+			Note:   rt.Note,
+			Author: auth.profiles.Resolve(rt.Author),
+			When:   auth.FormatDate(rt.CreatedAt),
+		}
+	}
+	return views
+}
+
+// canApprove reports whether the viewer may approve the tasks in a category
+// and choose whether they are reviewed: its owner and admins may
+func canApprove(auth AuthContext, c *domain.Category) bool {
+	return auth.IsAuthenticated && (auth.IsAdmin || c.OwnedBy(auth.Handle))
+}
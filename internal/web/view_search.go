@@ -0,0 +1,111 @@
+package web
+
+import (
+	"io"
+	"strings"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// searchKinds name each kind of entity in search results
+var searchKinds = map[string]string{
+	domain.EntityCategory: "Category",
+	domain.EntityTask:     "Task",
+	domain.EntitySubtask:  "Subtask",
+	domain.EntityWorkLog:  "Work log",
+}
+
+// SnippetPart is a stretch of a search result's snippet, which matched the
+// search or not
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// SearchResultView is one match in the search results
+type SearchResultView struct {
+	AuthContext
+	Kind       string
+	Name       string
+	Snippet    []SnippetPart
+	DetailsURL string
+}
+
+// SearchView is the view model for search results, in the header's dropdown
+// or the search slideover
+type SearchView struct {
+	AuthContext
+	Query   string
+	Results []SearchResultView
+}
+
+// NewSearchView creates a SearchView from the results the viewer may see
+func NewSearchView(query string, results []*domain.SearchResult, auth AuthContext) SearchView {
+	view := SearchView{AuthContext: auth, Query: query}
+	for _, r := range results {
+		if !auth.CanSee(r.CategoryID) {
+			continue
+		}
+		// A name that matched is snippet enough
+		var snippet []SnippetPart
+		if stripMatches(r.Snippet) != r.Name {
+			snippet = snippetParts(r.Snippet)
+		}
+		view.Results = append(view.Results, SearchResultView{
+			AuthContext: auth,
+			Kind:        searchKinds[r.EntityType],
+			Name:        r.Name,
+			Snippet:     snippet,
+			DetailsURL:  searchDetailsURL(r),
+		})
+	}
+	return view
+}
+
+// searchDetailsURL is where a result's details are. A work log's are its
+// subtask's or task's.
+func searchDetailsURL(r *domain.SearchResult) string {
+	switch r.EntityType {
+	case domain.EntityCategory:
+		return "/categories/" + r.ID + "/details"
+	case domain.EntitySubtask:
+		return "/subtasks/" + r.ID + "/details"
+	case domain.EntityWorkLog:
+		if r.SubtaskID != "" {
+			return "/subtasks/" + r.SubtaskID + "/details"
+		}
+		return "/tasks/" + r.TaskID + "/details"
+	}
+	return "/tasks/" + r.ID + "/details"
+}
+
+// snippetParts splits a snippet at its match markers
+func snippetParts(snippet string) []SnippetPart {
+	var parts []SnippetPart
+	for snippet != "" {
+		before, rest, found := strings.Cut(snippet, domain.SearchMatchStart)
+		if before != "" {
+			parts = append(parts, SnippetPart{Text: before})
+		}
+		if !found {
+			break
+		}
+		match, after, _ := strings.Cut(rest, domain.SearchMatchEnd)
+		parts = append(parts, SnippetPart{Text: match, Match: true})
+		snippet = after
+	}
+	return parts
+}
+
+// stripMatches removes the match markers from a snippet, for API clients
+func stripMatches(snippet string) string {
+	return strings.NewReplacer(domain.SearchMatchStart, "", domain.SearchMatchEnd, "").Replace(snippet)
+}
+
+func (p *Presentation) RenderSearch(w io.Writer, view SearchView) error {
+	return p.tmpl.ExecuteTemplate(w, "search", view)
+}
+
+func (p *Presentation) RenderSearchResults(w io.Writer, view SearchView) error {
+	return p.tmpl.ExecuteTemplate(w, "search_results", view)
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/notify"
+	"golang.org/x/text/language/display"
+)
+
+// SettingsView is the view model for the per-user settings slideover
+type SettingsView struct {
+	AuthContext
+	DisplayName string
+	Timezone    string
+	Capacity    string  // Weekly capacity in hours; empty if unset
+	IdleMinutes int     // Minutes without a heartbeat before a running timer pauses
+	Profile     Profile // Current attribution chip, for previewing the display name
+	LocalTime   string  // Current time in the chosen zone, for previewing the timezone
+	PushKey     string  // VAPID public key; empty when Web Push is not configured
+
+	Languages []LanguageOption // The conventions dates and numbers can be written in
+
+	Notifications []NotificationSettingView // One row per event kind; empty when no channels are configured
+	DigestHours   []HourOption
+
+	Hooks          []HookTokenView
+	NewHook        *NewHookView     // Just created; its URLs are shown this once
+	HookCategories []CategoryOption // what a new hook URL can be limited to
+
+	StatsURL string // Public board totals as JSON; empty if the user has no stats link
+
+	ClipCategory        string // where pages clipped from the browser go; empty for the default
+	DefaultClipCategory string
+
+	Services []NotifyServiceView // ntfy and Gotify, set up or not
+
+	MailEnabled bool   // the server can send email
+	Email       string // where the user's email notifications go
+
+	BoardEmpty bool // Backups can only be restored into an empty board
+}
+
+// HookTokenView is one of the user's hook URLs, which are never shown again
+// after creation
+type HookTokenView struct {
+	AuthContext
+	ID         string
+	Name       string
+	Category   string // the only category it reaches; empty for the whole board
+	CreatedAt  string
+	LastUsedAt string // Empty if never used
+	DeleteURL  string
+}
+
+// NewHookView reveals the URLs of a hook just created
+type NewHookView struct {
+	Name       string
+	DocsURL    string
+	CaptureURL string
+	WorkLogURL string
+	FilesURL   string // the board as a read-only WebDAV folder
+	GrafanaURL string // a JSON data source for Grafana dashboards
+	CommitsURL string // where a git hook reports commits
+}
+
+func newHookTokenViews(hooks []*domain.HookToken, categories []*domain.Category, auth AuthContext) []HookTokenView {
+	names := make(map[string]string, len(categories))
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+	var views []HookTokenView
+	for _, h := range hooks {
+		view := HookTokenView{
+			AuthContext: auth,
+			ID:          h.ID,
+			Name:        h.Name,
+			Category:    names[h.CategoryID],
+			CreatedAt:   auth.FormatDate(h.CreatedAt),
+			DeleteURL:   "/settings/hooks/" + h.ID,
+		}
+		if !h.LastUsedAt.IsZero() {
+			view.LastUsedAt = auth.FormatDateTime(h.LastUsedAt)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// NotifyServiceView is where the user's notifications go on a push service
+// such as ntfy. Saved tokens are never shown again.
+type NotifyServiceView struct {
+	AuthContext
+	Service    string
+	Label      string
+	URL        string
+	DefaultURL string // used when the address is left empty; empty if required
+	Topic      string
+	HasToken   bool
+	Configured bool
+}
+
+// newNotifyServiceViews describes each push service, with the user's
+// settings for those they have set up
+func newNotifyServiceViews(services map[string]*domain.NotifyService, auth AuthContext) []NotifyServiceView {
+	var views []NotifyServiceView
+	for _, name := range []string{domain.ServiceNtfy, domain.ServiceGotify} {
+		view := NotifyServiceView{AuthContext: auth, Service: name, Label: channelLabels[name]}
+		if name == domain.ServiceNtfy {
+			view.DefaultURL = notify.DefaultNtfyServer
+		}
+		if svc := services[name]; svc != nil {
+			view.URL = svc.URL
+			view.Topic = svc.Topic
+			view.HasToken = svc.Token != ""
+			view.Configured = true
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// NotificationSettingView is one kind of event and how it reaches the user
+// on each channel
+type NotificationSettingView struct {
+	Label    string
+	Channels []DeliveryView
+}
+
+// DeliveryView is the delivery mode chosen for one kind on one channel
+type DeliveryView struct {
+	Field string // form field name
+	Label string // channel name as shown to the user
+	Mode  string
+}
+
+// LanguageOption is a language offered in settings. The empty value
+// follows the browser's.
+type LanguageOption struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// newLanguageOptions lists the locales, with the user's choice selected
+func newLanguageOptions(chosen string) []LanguageOption {
+	options := []LanguageOption{{Label: "Follow browser", Selected: chosen == ""}}
+	names := display.English.Tags()
+	for _, tag := range locales {
+		options = append(options, LanguageOption{
+			Value:    tag.String(),
+			Label:    names.Name(tag),
+			Selected: chosen == tag.String(),
+		})
+	}
+	return options
+}
+
+type HourOption struct {
+	Value    int
+	Label    string
+	Selected bool
+}
+
+var notificationKindLabels = map[string]string{
+	notify.KindActivity:   "Work logged by others",
+	notify.KindAssignment: "Assigned to me",
+	notify.KindReminder:   "Reminders",
+}
+
+var channelLabels = map[string]string{
+	"inbox":  "Inbox",
+	"push":   "Browser",
+	"ntfy":   "ntfy",
+	"gotify": "Gotify",
+	"email":  "Email",
+}
+
+// deliveryField names the form field choosing how kind is delivered on
+// channel
+func deliveryField(kind, channel string) string {
+	return "notify." + kind + "." + channel
+}
+
+func (v *SettingsView) setNotifications(prefs *domain.Preferences, channels []string) {
+	if len(channels) == 0 {
+		return
+	}
+	for _, kind := range notify.Kinds {
+		row := NotificationSettingView{Label: notificationKindLabels[kind]}
+		for _, channel := range channels {
+			label := channelLabels[channel]
+			if label == "" {
+				label = channel
+			}
+			row.Channels = append(row.Channels, DeliveryView{
+				Field: deliveryField(kind, channel),
+				Label: label,
+				Mode:  prefs.Notifications.Delivery(kind, channel),
+			})
+		}
+		v.Notifications = append(v.Notifications, row)
+	}
+	for h := range 24 {
+		v.DigestHours = append(v.DigestHours, HourOption{
+			Value:    h,
+			Label:    fmt.Sprintf("%02d:00", h),
+			Selected: h == prefs.DigestHour,
+		})
+	}
+}
+
+func (p *Presentation) RenderSettings(w io.Writer, view SettingsView) error {
+	return p.tmpl.ExecuteTemplate(w, "settings", view)
+}
@@ -0,0 +1,15 @@
+package web
+
+import "io"
+
+// ShareView is the view model for confirming something shared from another
+// app before it becomes a task
+type ShareView struct {
+	AuthContext
+	Name        string
+	Description string
+}
+
+func (p *Presentation) RenderShare(w io.Writer, view ShareView) error {
+	return p.tmpl.ExecuteTemplate(w, "share", view)
+}
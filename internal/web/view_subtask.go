@@ -1,6 +1,7 @@
 package web
 
 import (
+	"html/template"
 	"io"
 
 	"git.sr.ht/~jakintosh/compass/internal/domain"
@@ -13,9 +14,16 @@ type SubtaskView struct {
 	Name         string
 	Description  string
 	Completion   int
+	Estimate     string
+	DueDate      string
+	Due          *DueView // Badges a deadline that has passed or is near
 	Public       bool
 	ParentPublic bool // Whether parent task (and its category) is public
+	Checkbox     bool // Done or not, with no percentage
 	WorkLogs     []WorkLogView
+	DetailsURL   string
+	HistoryURL   string
+	MoveURL      string
 	OOB          bool
 	DeleteButton DeleteButtonView
 }
@@ -28,18 +36,43 @@ func NewSubtaskView(s *domain.Subtask, oob bool, auth AuthContext) SubtaskView {
 		Name:         s.Name,
 		Description:  s.Description,
 		Completion:   s.Completion,
+		Estimate:     formatCapacity(s.Estimate),
+		DueDate:      s.DueDate,
+		Due:          newDueView(s.DueDate, s.Completion, auth),
 		Public:       s.Public,
 		ParentPublic: s.ParentPublic,
-		WorkLogs:     NewWorkLogViewsFromSubtask(s),
+		Checkbox:     s.Checkbox,
+		WorkLogs:     NewWorkLogViewsFromSubtask(s, auth),
+		DetailsURL:   "/subtasks/" + s.ID + "/details",
+		HistoryURL:   "/subtasks/" + s.ID + "/history",
+		MoveURL:      "/subtasks/" + s.ID + "/move",
 		OOB:          oob,
 		DeleteButton: DeleteButtonView{
 			URL:            "/subtasks/" + s.ID + "?csrf=" + auth.CSRFToken,
 			ConfirmMessage: "Delete this subtask?",
 			ButtonText:     "Delete Subtask",
+			FormURL:        "/subtasks/" + s.ID + "/delete",
+			CSRFToken:      auth.CSRFToken,
+			Accessible:     auth.Accessible,
 		},
 	}
 }
 
+// Done reports whether the subtask is finished
+func (v SubtaskView) Done() bool {
+	return v.Completion >= 100
+}
+
+// DescriptionHTML is the description with its task references as links and images shown
+func (v SubtaskView) DescriptionHTML() template.HTML {
+	return renderText(v.Description, v.AuthContext)
+}
+
+// References lists the tasks the description refers to
+func (v SubtaskView) References() []TaskRefView {
+	return refViews(v.Description, v.AuthContext)
+}
+
 // RenderSubtask renders a single subtask from its view model
 func (p *Presentation) RenderSubtask(w io.Writer, view SubtaskView) error {
 	return p.tmpl.ExecuteTemplate(w, "subtask.html", view)
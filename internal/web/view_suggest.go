@@ -0,0 +1,66 @@
+package web
+
+import (
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/planning"
+)
+
+// SuggestionView is one task offered by Suggest, with why
+type SuggestionView struct {
+	AuthContext
+	Name         string
+	CategoryName string
+	Size         string
+	Reasons      []string
+	DetailsURL   string
+}
+
+// SuggestView is the view model for the "pick something" slideover
+type SuggestView struct {
+	AuthContext
+	Minutes    int
+	Energy     string
+	Energies   []string
+	Top        *SuggestionView // nil when nothing fits
+	Alternates []SuggestionView
+}
+
+// NewSuggestView offers the best scored task and a few runners-up
+func NewSuggestView(suggestions []planning.Suggestion, categories []*domain.Category, minutes int, energy string, auth AuthContext) SuggestView {
+	view := SuggestView{
+		AuthContext: auth,
+		Minutes:     minutes,
+		Energy:      energy,
+		Energies:    planning.Energies,
+	}
+
+	names := make(map[string]string, len(categories))
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+	for i, s := range suggestions {
+		if i > suggestAlternates {
+			break
+		}
+		sv := SuggestionView{
+			AuthContext:  auth,
+			Name:         s.Task.Name,
+			CategoryName: names[s.Task.CategoryID],
+			Size:         s.Task.Size,
+			Reasons:      s.Reasons,
+			DetailsURL:   "/tasks/" + s.Task.ID + "/details",
+		}
+		if i == 0 {
+			view.Top = &sv
+		} else {
+			view.Alternates = append(view.Alternates, sv)
+		}
+	}
+	return view
+}
+
+func (p *Presentation) RenderSuggest(w io.Writer, view SuggestView) error {
+	return p.tmpl.ExecuteTemplate(w, "suggest", view)
+}
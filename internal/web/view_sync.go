@@ -0,0 +1,43 @@
+package web
+
+import (
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// SyncPeerView is another instance a board is synced with
+type SyncPeerView struct {
+	AuthContext
+	ID        string
+	Name      string
+	URL       string // empty if the peer connects to us
+	SyncedAt  string // empty if never synced
+	LastError string
+	PeerURL   string
+}
+
+// NewSyncPeerView reveals what the other instance needs to join a board
+// just shared, since the secret is not shown again
+type NewSyncPeerView struct {
+	Name    string
+	URL     string // this instance
+	BoardID string
+	Secret  string
+}
+
+func newSyncPeerViews(peers []*domain.SyncPeer, auth AuthContext) []SyncPeerView {
+	views := make([]SyncPeerView, len(peers))
+	for i, p := range peers {
+		views[i] = SyncPeerView{
+			AuthContext: auth,
+			ID:          p.ID,
+			Name:        p.Name,
+			URL:         p.URL,
+			LastError:   p.LastError,
+			PeerURL:     "/sync-peers/" + p.ID,
+		}
+		if !p.SyncedAt.IsZero() {
+			views[i].SyncedAt = auth.FormatDateTime(p.SyncedAt)
+		}
+	}
+	return views
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"io"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// TagView is one tag on the tags page
+type TagView struct {
+	AuthContext
+	ID        string
+	Name      string
+	Color     string
+	Tasks     int
+	FilterURL string // The board narrowed to the tag
+	Colors    []string
+}
+
+// TagsView is the view model for managing tags
+type TagsView struct {
+	AuthContext
+	Tags []TagView
+	New  TagView // The blank tag the create form starts from
+}
+
+// NewTagsView lists the tags by name, with how many tasks are given each
+func NewTagsView(tags []*domain.Tag, auth AuthContext) TagsView {
+	view := TagsView{
+		AuthContext: auth,
+		New:         TagView{AuthContext: auth, Colors: domain.CategoryColors},
+	}
+	for _, t := range tags {
+		view.Tags = append(view.Tags, TagView{
+			AuthContext: auth,
+			ID:          t.ID,
+			Name:        t.Name,
+			Color:       t.Color,
+			Tasks:       t.Tasks,
+			FilterURL:   AuthContext{}.TagFilterURL(t.Name, ""),
+			Colors:      domain.CategoryColors,
+		})
+	}
+	return view
+}
+
+func (p *Presentation) RenderTags(w io.Writer, view TagsView) error {
+	return p.tmpl.ExecuteTemplate(w, "tags", view)
+}
@@ -1,7 +1,11 @@
 package web
 
 import (
+	"fmt"
+	"html/template"
 	"io"
+	"slices"
+	"time"
 
 	"git.sr.ht/~jakintosh/compass/internal/domain"
 )
@@ -9,17 +13,63 @@ import (
 // TaskView is the view model for Task
 type TaskView struct {
 	AuthContext
-	ID           string
-	Name         string
-	Description  string
-	Completion   int
-	Public       bool
-	ParentPublic bool // Whether parent category is public (for disabling toggle)
-	HasSubtasks  bool
-	Subtasks     []SubtaskView
-	WorkLogs     []WorkLogView
-	OOB          bool
-	DeleteButton DeleteButtonView
+	ID                string
+	Name              string
+	Description       string
+	Completion        int
+	Public            bool
+	ParentPublic      bool // Whether parent category is public (for disabling toggle)
+	HasSubtasks       bool
+	Subtasks          []SubtaskView
+	WorkLogs          []WorkLogView
+	Attachments       []AttachmentView
+	AttachURL         string
+	Links             []LinkView
+	LinkKinds         []string
+	AddLinkURL        string
+	Backlinks         []BacklinkView
+	Nudges            []NudgeView
+	NudgeDays         []WeekdayOption
+	AddNudgeURL       string
+	RefCode           string // How to refer to this task from other text
+	DetailsURL        string
+	HistoryURL        string
+	MoveURL           string
+	Queued            bool   // On the viewer's focus queue
+	Color             string // The category's accent color
+	Estimate          string
+	EstimateChanges   []EstimateChangeView
+	Reforecast        *ReforecastView // Set when a work log has used up most of the estimate
+	StartDate         string
+	DueDate           string
+	Due               *DueView
+	Critical          bool   // On the category's critical path
+	Slack             string // How long the task can slip; empty if unscheduled
+	Dependencies      []DependencyView
+	DependencyOptions []PlanTaskOption // Tasks in the category it could wait on
+	KeyResults        []TaskKeyResultView
+	KeyResultOptions  []KeyResultOption // This quarter's key results it could count towards
+	Checklist         []CheckView       // The definition of done copied from its category
+	Review            string            // One of the domain Review states
+	ReviewRequired    bool              // Its category finishes tasks by approving them
+	ReviewTransitions []ReviewTransitionView
+	CanApprove        bool // Set by the details page for the category's owner and admins
+	Blocked           bool
+	BlockedReason     string
+	WaitingOn         string
+	BlockedFor        string // How long it has been blocked
+	Contexts          []string
+	Tags              []*domain.Tag
+	TagOptions        []*domain.Tag // Tags it could also be given
+	Size              string
+	Sizes             []string
+	SubtaskCheckboxes bool
+	Priority          int
+	PriorityName      string
+	PriorityNames     []string // indexed by priority
+	Flag              string   // Why an aging rule flagged it
+	OOB               bool
+	DeleteButton      DeleteButtonView
 }
 
 // NewTaskView creates a TaskView from a domain Task
@@ -32,6 +82,26 @@ func NewTaskView(t *domain.Task, oob bool, auth AuthContext) TaskView {
 		Completion:   t.Completion,
 		Public:       t.Public,
 		ParentPublic: t.ParentPublic,
+		DetailsURL:   "/tasks/" + t.ID + "/details",
+		HistoryURL:   "/tasks/" + t.ID + "/history",
+		AttachURL:    "/tasks/" + t.ID + "/attachments",
+		LinkKinds:    domain.LinkKinds,
+		AddLinkURL:   "/tasks/" + t.ID + "/links",
+		RefCode:      domain.ShortTaskRef(t.ID),
+		NudgeDays:    nudgeWeekdays,
+		AddNudgeURL:  "/tasks/" + t.ID + "/nudges",
+		MoveURL:      "/tasks/" + t.ID + "/move",
+		Estimate:     formatCapacity(t.Estimate),
+		StartDate:    t.StartDate,
+		DueDate:      t.DueDate,
+		Due:          newDueView(t.DueDate, t.Completion, auth),
+		Contexts:     t.Contexts,
+		Tags:         t.Tags,
+		Size:         t.Size,
+		Sizes:        domain.TaskSizes,
+		Priority:     t.Priority,
+		PriorityName: domain.PriorityNames[t.Priority],
+		Flag:         t.Flag,
 		OOB:          oob,
 	}
 	if len(t.Subtasks) > 0 {
@@ -42,17 +112,87 @@ func NewTaskView(t *domain.Task, oob bool, auth AuthContext) TaskView {
 		}
 	}
 
-	view.WorkLogs = NewWorkLogViewsFromTask(t)
+	view.WorkLogs = NewWorkLogViewsFromTask(t, auth)
+	view.Attachments = NewAttachmentViews(t.Attachments, auth)
+	view.Links = NewLinkViews(t.Links, auth)
+	view.Backlinks = newBacklinkViews(t.Backlinks, auth)
+	view.Nudges = newNudgeViews(t.Nudges, auth)
+	view.Checklist = newCheckViews(t.Checklist, auth)
+	view.EstimateChanges = newEstimateChangeViews(t.EstimateChanges, auth)
+	view.Review = t.Review
+	view.ReviewRequired = t.ReviewRequired
+	view.ReviewTransitions = newReviewTransitionViews(t.ReviewTransitions, auth)
+	view.PriorityNames = domain.PriorityNames
+	for _, tag := range auth.AllTags {
+		if !slices.ContainsFunc(t.Tags, func(given *domain.Tag) bool { return given.ID == tag.ID }) {
+			view.TagOptions = append(view.TagOptions, tag)
+		}
+	}
+	view.SubtaskCheckboxes = t.SubtaskCheckboxes
+	if t.Blocked {
+		view.Blocked = true
+		view.BlockedReason = t.BlockedReason
+		view.WaitingOn = t.WaitingOn
+		view.BlockedFor = formatAge(time.Since(t.BlockedSince))
+	}
 
 	view.DeleteButton = DeleteButtonView{
 		URL:            "/tasks/" + t.ID + "?csrf=" + auth.CSRFToken,
 		ConfirmMessage: "Delete this task?",
 		ButtonText:     "Delete Task",
+		FormURL:        "/tasks/" + t.ID + "/delete",
+		CSRFToken:      auth.CSRFToken,
+		Accessible:     auth.Accessible,
 	}
 
 	return view
 }
 
+// dueSoonDays is how many days ahead a deadline is badged on the board
+const dueSoonDays = 7
+
+// DueView badges a deadline on the board
+type DueView struct {
+	State string // "overdue", "today", or "soon", for styling
+	Label string
+	Title string // the date itself
+}
+
+// newDueView badges a YYYY-MM-DD deadline that has passed or is at most
+// dueSoonDays off in the viewer's zone. Finished work, and work without a
+// deadline or with one further off, gets none.
+func newDueView(due string, completion int, auth AuthContext) *DueView {
+	day, err := time.Parse(time.DateOnly, due)
+	if err != nil || completion >= 100 {
+		return nil
+	}
+	y, m, d := time.Now().In(auth.Location()).Date()
+	days := int(day.Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	date := auth.FormatShortDate(day)
+	switch {
+	case days < 0:
+		return &DueView{State: "overdue", Label: "Overdue", Title: "Was due " + date}
+	case days == 0:
+		return &DueView{State: "today", Label: "Due today", Title: "Due " + date}
+	case days == 1:
+		return &DueView{State: "soon", Label: "Due tomorrow", Title: "Due " + date}
+	case days <= dueSoonDays:
+		return &DueView{State: "soon", Label: fmt.Sprintf("Due in %d days", days), Title: "Due " + date}
+	}
+	return nil
+}
+
+// DescriptionHTML is the description with its task references as links and images shown
+func (v TaskView) DescriptionHTML() template.HTML {
+	return renderText(v.Description, v.AuthContext)
+}
+
+// References lists the tasks the description refers to
+func (v TaskView) References() []TaskRefView {
+	return refViews(v.Description, v.AuthContext)
+}
+
 // RenderTask renders a single task from its view model
 func (p *Presentation) RenderTask(w io.Writer, view TaskView) error {
 	return p.tmpl.ExecuteTemplate(w, "task.html", view)
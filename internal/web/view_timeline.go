@@ -0,0 +1,232 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/planning"
+)
+
+// Timeline geometry, in SVG user units
+const (
+	timelineLabelWidth = 220
+	timelineHeader     = 36
+	timelineRow        = 28
+	timelineBar        = 16
+	timelinePad        = 16
+	timelineNameLength = 30 // longer names are cut short with an ellipsis
+)
+
+// TimelineBar is one task on the chart. Tasks with only one of their dates
+// are drawn as a milestone on that day.
+type TimelineBar struct {
+	Name       string
+	FullName   string
+	Dates      string
+	Y          int // top of the row
+	TextY      int
+	BarY       int
+	X          float64
+	Width      float64
+	Progress   float64 // width of the completed part
+	Milestone  bool
+	MidX       float64
+	MidY       int
+	Critical   bool
+	Completion int
+}
+
+// TimelineTick labels a date along the top of the chart
+type TimelineTick struct {
+	X     float64
+	Label string
+}
+
+// TimelineView is the view model for a category's Gantt chart
+type TimelineView struct {
+	AuthContext
+	CategoryName string
+	SVGURL       string
+	Width        float64
+	Height       int
+	ChartHeight  int // bottom of the last row, for grid lines
+	LabelWidth   int
+	Bars         []TimelineBar
+	Ticks        []TimelineTick
+	Arrows       []string // SVG path data from a dependency to what waits on it
+	TodayX       float64
+	ShowToday    bool
+	Unscheduled  []string // tasks with neither date
+}
+
+// NewTimelineView lays out the dated tasks of c on a shared date axis
+func NewTimelineView(c *domain.Category, today time.Time, auth AuthContext) TimelineView {
+	view := TimelineView{
+		AuthContext:  auth,
+		CategoryName: c.Name,
+		SVGURL:       "/timeline/" + c.ID + "/chart.svg",
+		LabelWidth:   timelineLabelWidth,
+	}
+
+	var dated []*domain.Task
+	var first, last time.Time
+	for _, t := range c.Tasks {
+		start, due := parseDate(t.StartDate), parseDate(t.DueDate)
+		if start.IsZero() && due.IsZero() {
+			view.Unscheduled = append(view.Unscheduled, t.Name)
+			continue
+		}
+		dated = append(dated, t)
+		for _, d := range []time.Time{start, due} {
+			if d.IsZero() {
+				continue
+			}
+			if first.IsZero() || d.Before(first) {
+				first = d
+			}
+			if last.IsZero() || d.After(last) {
+				last = d
+			}
+		}
+	}
+	if len(dated) == 0 {
+		return view
+	}
+
+	// A day of margin either side, and a scale that keeps long plans readable
+	first, last = first.AddDate(0, 0, -1), last.AddDate(0, 0, 2)
+	span := days(first, last)
+	dayWidth := 24.0
+	switch {
+	case span > 120:
+		dayWidth = 4
+	case span > 45:
+		dayWidth = 10
+	}
+	x := func(d time.Time) float64 {
+		return timelineLabelWidth + float64(days(first, d))*dayWidth
+	}
+	view.Width = x(last) + timelinePad
+
+	for d := first; d.Before(last); d = d.AddDate(0, 0, 1) {
+		switch {
+		case dayWidth >= 10 && d.Weekday() == time.Monday:
+			view.Ticks = append(view.Ticks, TimelineTick{X: x(d), Label: auth.FormatShortDate(d)})
+		case dayWidth < 10 && d.Day() == 1:
+			view.Ticks = append(view.Ticks, TimelineTick{X: x(d), Label: d.Format("Jan")})
+		}
+	}
+	todayDate := parseDate(today.Format(time.DateOnly))
+	if !todayDate.Before(first) && todayDate.Before(last) {
+		view.ShowToday = true
+		view.TodayX = x(todayDate)
+	}
+
+	var critical map[string]planning.Slot
+	if schedule, err := planning.CriticalPath(c.Tasks); err == nil {
+		critical = schedule.Slots
+	}
+
+	index := make(map[string]int, len(dated))
+	for i, t := range dated {
+		index[t.ID] = i
+		y := timelineHeader + i*timelineRow
+		bar := TimelineBar{
+			Name:       truncateName(t.Name),
+			FullName:   t.Name,
+			Y:          y,
+			TextY:      y + timelineRow/2 + 4,
+			BarY:       y + (timelineRow-timelineBar)/2,
+			MidY:       y + timelineRow/2,
+			Critical:   critical[t.ID].Critical,
+			Completion: t.Completion,
+		}
+		start, due := parseDate(t.StartDate), parseDate(t.DueDate)
+		switch {
+		case !start.IsZero() && !due.IsZero():
+			bar.X = x(start)
+			bar.Width = x(due.AddDate(0, 0, 1)) - bar.X
+			bar.Progress = bar.Width * float64(t.Completion) / 100
+			bar.Dates = t.StartDate + " to " + t.DueDate
+		case !due.IsZero():
+			bar.Milestone = true
+			bar.X = x(due) + dayWidth/2
+			bar.Dates = "due " + t.DueDate
+		default:
+			bar.Milestone = true
+			bar.X = x(start) + dayWidth/2
+			bar.Dates = "starts " + t.StartDate
+		}
+		bar.MidX = bar.X + bar.Width/2
+		view.Bars = append(view.Bars, bar)
+	}
+
+	// Arrows run from the end of a dependency to the start of its dependent
+	for i, t := range dated {
+		for _, dep := range t.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			from, to := view.Bars[j], view.Bars[i]
+			fromX, toX := from.X+from.Width, to.X-2
+			if from.Milestone {
+				fromX += 8
+			}
+			if to.Milestone {
+				toX -= 8
+			}
+			if toX >= fromX+12 {
+				view.Arrows = append(view.Arrows, fmt.Sprintf("M %g %d h 6 V %d H %g", fromX, from.MidY, to.MidY, toX))
+			} else {
+				// Overlapping tasks: drop between the rows and come back
+				// round to the start of the dependent
+				between := to.Y
+				if to.Y < from.Y {
+					between = from.Y
+				}
+				view.Arrows = append(view.Arrows, fmt.Sprintf("M %g %d h 6 V %d H %g V %d H %g", fromX, from.MidY, between, toX-6, to.MidY, toX))
+			}
+		}
+	}
+	slices.Sort(view.Arrows) // stable output for the same plan
+
+	view.ChartHeight = timelineHeader + len(dated)*timelineRow
+	view.Height = view.ChartHeight + timelinePad
+	return view
+}
+
+// parseDate reads a YYYY-MM-DD date, or returns the zero time
+func parseDate(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return d
+}
+
+// days counts whole days from a to b, both UTC dates
+func days(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func truncateName(name string) string {
+	if utf8.RuneCountInString(name) <= timelineNameLength {
+		return name
+	}
+	return string([]rune(name)[:timelineNameLength-1]) + "…"
+}
+
+// RenderTimeline renders the timeline page around the chart
+func (p *Presentation) RenderTimeline(w io.Writer, view TimelineView) error {
+	return p.tmpl.ExecuteTemplate(w, "timeline", view)
+}
+
+// RenderTimelineSVG renders the chart alone as an SVG document
+func (p *Presentation) RenderTimelineSVG(w io.Writer, view TimelineView) error {
+	return p.tmpl.ExecuteTemplate(w, "timeline_svg_document", view)
+}
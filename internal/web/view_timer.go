@@ -0,0 +1,64 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// TimerView is one of the user's timers, as the header widget shows it
+type TimerView struct {
+	ID         string
+	TaskID     string
+	Label      string // the timer's name, or its task's
+	TaskName   string // shown besides Label when the timer is named
+	DetailsURL string
+	Elapsed    string // hours and minutes counted, as h:mm
+	Running    bool
+	IdlePaused bool
+	LastSeen   string // when an idle-paused timer last heard from the browser
+}
+
+func NewTimerView(t *domain.Timer, now time.Time, auth AuthContext) TimerView {
+	elapsed := t.ElapsedAt(now).Truncate(time.Minute)
+	v := TimerView{
+		ID:         t.ID,
+		TaskID:     t.TaskID,
+		Label:      t.Label(),
+		DetailsURL: "/tasks/" + t.TaskID + "/details",
+		Elapsed:    fmt.Sprintf("%d:%02d", int(elapsed.Hours()), int(elapsed.Minutes())%60),
+		Running:    t.Running(),
+		IdlePaused: t.IdlePaused,
+		LastSeen:   auth.FormatTime(t.LastSeen),
+	}
+	if t.Name != "" {
+		v.TaskName = t.TaskName
+	}
+	return v
+}
+
+// NewTimerViews lists the user's timers, oldest first
+func NewTimerViews(timers []*domain.Timer, now time.Time, auth AuthContext) []TimerView {
+	views := make([]TimerView, len(timers))
+	for i, t := range timers {
+		views[i] = NewTimerView(t, now, auth)
+	}
+	return views
+}
+
+// TimerRunning reports whether any of the user's timers is counting, and so whether
+// the widget needs to send heartbeats
+func (a AuthContext) TimerRunning() bool {
+	for _, t := range a.Timers {
+		if t.Running {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Presentation) RenderTimers(w io.Writer, auth AuthContext) error {
+	return p.tmpl.ExecuteTemplate(w, "timer_widget", auth)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"io"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// undoTTL is how long a deletion can be undone from the toast. The trash
+// entry itself lives on; this only bounds the one-click undo.
+const undoTTL = 30 * time.Second
+
+// UndoView is the view model for the "Undo" toast shown after a deletion
+type UndoView struct {
+	AuthContext
+	Token   string // Trash entry ID
+	Name    string
+	Seconds int // Remaining time before the toast dismisses itself
+}
+
+// ToastView is the view model for the toast container
+type ToastView struct {
+	Undo *UndoView
+	OOB  bool
+}
+
+// NewUndoView returns the undo toast for entry, or nil when the viewer did
+// not delete it or the undo window has passed.
+func NewUndoView(entry *domain.TrashEntry, auth AuthContext, now time.Time) *UndoView {
+	if entry == nil || entry.DeletedBy != auth.Handle {
+		return nil
+	}
+	remaining := entry.DeletedAt.Add(undoTTL).Sub(now)
+	if remaining <= 0 {
+		return nil
+	}
+	return &UndoView{
+		AuthContext: auth,
+		Token:       entry.ID,
+		Name:        entry.Name,
+		Seconds:     int(remaining.Round(time.Second) / time.Second),
+	}
+}
+
+// RenderUndoToast renders the toast container out-of-band, holding view or
+// nothing when view is nil
+func (p *Presentation) RenderUndoToast(w io.Writer, view *UndoView) error {
+	return p.tmpl.ExecuteTemplate(w, "toast_container", ToastView{Undo: view, OOB: true})
+}
@@ -0,0 +1,26 @@
+package web
+
+import "io"
+
+// FormField is one submitted form value, carried over into another form
+type FormField struct {
+	Name  string
+	Value string
+}
+
+// WIPWarningView warns that a change would start a task in a category
+// already at its work-in-progress limit, and offers to make it anyway
+type WIPWarningView struct {
+	AuthContext
+	CategoryName string
+	WIP          int
+	Limit        int
+	Method       string // of the original request, lowercase
+	URL          string
+	Fields       []FormField
+	ReturnURL    string // where cancelling goes in accessible mode
+}
+
+func (p *Presentation) RenderWIPWarning(w io.Writer, view WIPWarningView) error {
+	return p.tmpl.ExecuteTemplate(w, "wip_warning", view)
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// WorkSessionView is a run of commits suggested as a work log
+type WorkSessionView struct {
+	AuthContext
+	ID          string // the first commit's, to tell the session's form fields apart
+	CommitIDs   []string
+	TaskID      string
+	TaskName    string
+	DetailsURL  string
+	Repo        string
+	Branch      string
+	When        string
+	Hours       string
+	Description string
+	Messages    []string
+}
+
+// WorkSessionsView is the view model for reviewing suggested work logs
+type WorkSessionsView struct {
+	AuthContext
+	Sessions   []WorkSessionView
+	Categories []PlanCategoryOption
+}
+
+// NewWorkSessionsView lists the suggested sessions, newest first, with the
+// tasks an unlinked one can be logged against
+func NewWorkSessionsView(sessions []*domain.WorkSession, categories []*domain.Category, auth AuthContext) WorkSessionsView {
+	view := WorkSessionsView{AuthContext: auth}
+	loc := auth.Location()
+	for _, s := range sessions {
+		v := WorkSessionView{
+			AuthContext: auth,
+			ID:          s.Commits[0].ID,
+			CommitIDs:   s.CommitIDs(),
+			TaskID:      s.TaskID,
+			TaskName:    s.TaskName,
+			Repo:        s.Repo,
+			Branch:      s.Branch,
+			When:        auth.FormatDateTime(s.Start) + "–" + auth.FormatTime(s.End),
+			Hours:       formatHours(s.Hours()),
+		}
+		if s.TaskID != "" {
+			v.DetailsURL = "/tasks/" + s.TaskID + "/details"
+		}
+		if s.Start.In(loc).Format(time.DateOnly) != s.End.In(loc).Format(time.DateOnly) {
+			v.When = auth.FormatDateTime(s.Start) + " – " + auth.FormatDateTime(s.End)
+		}
+		var lines []string
+		for _, c := range s.Commits {
+			subject, _, _ := strings.Cut(c.Message, "\n")
+			v.Messages = append(v.Messages, subject)
+			lines = append(lines, "- "+subject)
+		}
+		v.Description = strings.Join(lines, "\n")
+		view.Sessions = append(view.Sessions, v)
+	}
+
+	for _, c := range categories {
+		option := PlanCategoryOption{Name: c.Name}
+		for _, t := range c.Tasks {
+			if auth.InContext(t.ID) {
+				option.Tasks = append(option.Tasks, PlanTaskOption{ID: t.ID, Name: t.Name})
+			}
+		}
+		if len(option.Tasks) > 0 {
+			view.Categories = append(view.Categories, option)
+		}
+	}
+	return view
+}
+
+func (p *Presentation) RenderWorkSessions(w io.Writer, view WorkSessionsView) error {
+	return p.tmpl.ExecuteTemplate(w, "work_sessions", view)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// workloadWeeks is how many weeks the workload page shows at once
+const workloadWeeks = 4
+
+// WorkloadView is what everyone planned for the coming weeks
+type WorkloadView struct {
+	AuthContext
+	Weeks       []WorkloadWeekView
+	PrevURL     string
+	NextURL     string
+	ThisWeekURL string
+	IsThisWeek  bool
+}
+
+// WorkloadWeekView is everyone's plans for one week
+type WorkloadWeekView struct {
+	Label  string
+	People []PersonLoadView
+}
+
+// PersonLoadView is one person's plans for a week, against their capacity
+type PersonLoadView struct {
+	Person   Profile
+	Planned  string
+	Capacity string // empty if unset
+	Balance  string // hours over or under capacity
+	Over     bool
+	Tasks    []PlannedTaskView
+}
+
+// PlannedTaskView is one task someone planned hours for
+type PlannedTaskView struct {
+	AuthContext
+	TaskName     string
+	CategoryName string
+	DetailsURL   string
+	Planned      string
+	Remaining    string // of the estimate; empty if unestimated
+	Short        bool   // more planned than the estimate has left
+}
+
+// NewWorkloadView lays out the workloads for the weeks starting on start.
+// Tasks in categories hidden from the viewer are left out, and their hours
+// with them.
+func NewWorkloadView(start time.Time, workloads []*domain.Workload, now time.Time, auth AuthContext) WorkloadView {
+	thisWeek := domain.WeekStart(now.In(auth.Location()))
+	view := WorkloadView{
+		AuthContext: auth,
+		PrevURL:     workloadURL(start.AddDate(0, 0, -7*workloadWeeks)),
+		NextURL:     workloadURL(start.AddDate(0, 0, 7*workloadWeeks)),
+		ThisWeekURL: "/workload",
+		IsThisWeek:  start.Equal(thisWeek),
+	}
+
+	byWeek := make(map[string][]*domain.Workload)
+	for _, w := range workloads {
+		byWeek[w.Week] = append(byWeek[w.Week], w)
+	}
+	for i := range workloadWeeks {
+		week := start.AddDate(0, 0, 7*i)
+		weekView := WorkloadWeekView{Label: "Week of " + auth.FormatLongDate(week)}
+		for _, w := range byWeek[week.Format(time.DateOnly)] {
+			visible := &domain.Workload{UserID: w.UserID, Week: w.Week, Capacity: w.Capacity}
+			for _, t := range w.Tasks {
+				if auth.CanSee(t.CategoryID) {
+					visible.Tasks = append(visible.Tasks, t)
+				}
+			}
+			if len(visible.Tasks) > 0 {
+				weekView.People = append(weekView.People, newPersonLoadView(visible, auth))
+			}
+		}
+		view.Weeks = append(view.Weeks, weekView)
+	}
+	return view
+}
+
+func newPersonLoadView(w *domain.Workload, auth AuthContext) PersonLoadView {
+	view := PersonLoadView{
+		Person:  auth.profiles.Resolve(w.UserID),
+		Planned: auth.FormatHours(w.Planned()),
+		Over:    w.Over(),
+	}
+	if w.Capacity > 0 {
+		view.Capacity = auth.FormatHours(w.Capacity)
+		view.Balance = auth.FormatHours(math.Abs(w.Capacity - w.Planned()))
+	}
+	for _, t := range w.Tasks {
+		tv := PlannedTaskView{
+			AuthContext:  auth,
+			TaskName:     t.TaskName,
+			CategoryName: t.CategoryName,
+			DetailsURL:   "/tasks/" + t.TaskID + "/details",
+			Planned:      auth.FormatHours(t.Planned),
+		}
+		if t.Remaining > 0 {
+			tv.Remaining = auth.FormatHours(t.Remaining)
+			tv.Short = t.Planned > t.Remaining
+		}
+		view.Tasks = append(view.Tasks, tv)
+	}
+	return view
+}
+
+// workloadURL is the workload page starting on the week of start
+func workloadURL(start time.Time) string {
+	return "/workload?week=" + start.Format(time.DateOnly)
+}
+
+func (p *Presentation) RenderWorkload(w io.Writer, view WorkloadView) error {
+	return p.tmpl.ExecuteTemplate(w, "workload", view)
+}
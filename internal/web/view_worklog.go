@@ -1,13 +1,15 @@
 package web
 
 import (
-	"fmt"
+	"html/template"
+	"strconv"
 
 	"git.sr.ht/~jakintosh/compass/internal/domain"
 )
 
 // WorkLogView is the view model for WorkLog
 type WorkLogView struct {
+	AuthContext
 	ID                 string
 	HoursWorked        string // Formatted as string for display
 	WorkDescription    string
@@ -15,34 +17,67 @@ type WorkLogView struct {
 	CreatedAt          string // Formatted timestamp
 	TaskName           string // For category view context
 	SubtaskName        string // For task/category view context
+	Author             Profile
+	IsCorrection       bool   // An adjustment entry; HoursWorked is signed
+	EffectiveHours     string // Hours after corrections, when they differ from HoursWorked
+	EditHours          string // Prefill for the edit/correct form
+	EditURL            string
+	Attachments        []AttachmentView
+	AttachURL          string
+	VoiceMemoURL       string
+	DetailsURL         string // Where the accessible edit form returns to
 }
 
-// NewWorkLogView creates a WorkLogView from a domain WorkLog
-func NewWorkLogView(wl *domain.WorkLog, taskName, subtaskName string) WorkLogView {
-	return WorkLogView{
+// NewWorkLogView creates a WorkLogView from a domain WorkLog. effective is
+// the entry's hours including any corrections to it.
+func NewWorkLogView(wl *domain.WorkLog, taskName, subtaskName string, effective float64, auth AuthContext) WorkLogView {
+	view := WorkLogView{
+		AuthContext:        auth,
 		ID:                 wl.ID,
-		HoursWorked:        fmt.Sprintf("%.1f", wl.HoursWorked),
+		HoursWorked:        auth.printer().Sprintf("%.1f", wl.HoursWorked),
 		WorkDescription:    wl.WorkDescription,
 		CompletionEstimate: wl.CompletionEstimate,
-		CreatedAt:          wl.CreatedAt.Format("Jan 2, 3:04 PM"),
+		CreatedAt:          auth.FormatDateTime(wl.CreatedAt),
 		TaskName:           taskName,
 		SubtaskName:        subtaskName,
+		Author:             auth.profiles.Resolve(wl.Author),
+		IsCorrection:       wl.CorrectsID != "",
+		EditHours:          strconv.FormatFloat(effective, 'f', -1, 64),
+		EditURL:            "/work-logs/" + wl.ID,
+		Attachments:        NewAttachmentViews(wl.Attachments, auth),
+		AttachURL:          "/work-logs/" + wl.ID + "/attachments",
+		VoiceMemoURL:       "/work-logs/" + wl.ID + "/voice-memos",
+		DetailsURL:         "/tasks/" + wl.TaskID + "/details",
 	}
+	if wl.SubtaskID != "" {
+		view.DetailsURL = "/subtasks/" + wl.SubtaskID + "/details"
+	}
+	if view.IsCorrection {
+		view.HoursWorked = auth.printer().Sprintf("%+.1f", wl.HoursWorked)
+	} else if effective != wl.HoursWorked {
+		view.EffectiveHours = auth.printer().Sprintf("%.1f", effective)
+	}
+	return view
+}
+
+// DescriptionHTML is the work description with its task references as links and images shown
+func (v WorkLogView) DescriptionHTML() template.HTML {
+	return renderText(v.WorkDescription, v.AuthContext)
 }
 
-func NewWorkLogViewsFromSubtask(s *domain.Subtask) []WorkLogView {
-	return newWorkLogViews(s.WorkLogs, nil, nil)
+func NewWorkLogViewsFromSubtask(s *domain.Subtask, auth AuthContext) []WorkLogView {
+	return newWorkLogViews(s.WorkLogs, nil, nil, auth)
 }
 
-func NewWorkLogViewsFromTask(t *domain.Task) []WorkLogView {
+func NewWorkLogViewsFromTask(t *domain.Task, auth AuthContext) []WorkLogView {
 	subtaskNames := make(map[string]string, len(t.Subtasks))
 	for _, s := range t.Subtasks {
 		subtaskNames[s.ID] = s.Name
 	}
-	return newWorkLogViews(t.WorkLogs, nil, subtaskNames)
+	return newWorkLogViews(t.WorkLogs, nil, subtaskNames, auth)
 }
 
-func NewWorkLogViewsFromCategory(c *domain.Category) []WorkLogView {
+func NewWorkLogViewsFromCategory(c *domain.Category, auth AuthContext) []WorkLogView {
 	taskNames := make(map[string]string, len(c.Tasks))
 	subtaskNames := make(map[string]string)
 	for _, t := range c.Tasks {
@@ -51,21 +86,33 @@ func NewWorkLogViewsFromCategory(c *domain.Category) []WorkLogView {
 			subtaskNames[s.ID] = s.Name
 		}
 	}
-	return newWorkLogViews(c.WorkLogs, taskNames, subtaskNames)
+	return newWorkLogViews(c.WorkLogs, taskNames, subtaskNames, auth)
 }
 
 func newWorkLogViews(
 	workLogs []*domain.WorkLog,
 	taskNames map[string]string,
 	subtaskNames map[string]string,
+	auth AuthContext,
 ) []WorkLogView {
 	if workLogs == nil {
 		return nil
 	}
 
+	// Corrections share their original's task and subtask, so any list that
+	// holds an original also holds its whole correction chain
+	effective := make(map[string]float64, len(workLogs))
+	for _, wl := range workLogs {
+		if wl.CorrectsID == "" {
+			effective[wl.ID] += wl.HoursWorked
+		} else {
+			effective[wl.CorrectsID] += wl.HoursWorked
+		}
+	}
+
 	views := make([]WorkLogView, len(workLogs))
 	for i, wl := range workLogs {
-		views[i] = NewWorkLogView(wl, taskNames[wl.TaskID], subtaskNames[wl.SubtaskID])
+		views[i] = NewWorkLogView(wl, taskNames[wl.TaskID], subtaskNames[wl.SubtaskID], effective[wl.ID], auth)
 	}
 	return views
 }
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// wipExceeded stops a request that would start a task in a category already
+// at its work-in-progress limit, answering with a warning that can resend
+// the same request with the limit overridden. It reports whether it did.
+// The request's form must already be parsed.
+func (s *Server) wipExceeded(w http.ResponseWriter, r *http.Request, auth AuthContext, categoryID string) bool {
+	if r.PostForm.Get("wip_override") == "on" {
+		return false
+	}
+	// A missing category is left for the update itself to report
+	cat, err := s.store.GetCategory(categoryID)
+	if err != nil || !cat.AtWIPLimit() {
+		return false
+	}
+
+	view := WIPWarningView{
+		AuthContext:  auth,
+		CategoryName: cat.Name,
+		WIP:          cat.WIP(),
+		Limit:        cat.WIPLimit,
+		Method:       strings.ToLower(r.Method),
+		URL:          r.URL.RequestURI(),
+		ReturnURL:    r.PostForm.Get("return_to"),
+		Fields:       carriedFields(r.PostForm, "wip_override"),
+	}
+	// Accessible forms always post to the path itself
+	page := view
+	page.Method = "post"
+	page.URL = r.URL.Path
+
+	s.warnThenConfirm(w, r, auth,
+		fmt.Sprintf("%s already has %d of its %d tasks in progress; send wip_override to start this one anyway", cat.Name, cat.WIP(), cat.WIPLimit),
+		func(w io.Writer) error { return s.presentation.RenderWIPWarning(w, view) },
+		page)
+	return true
+}
+
+// startsTask reports whether progress on one of the task's subtasks would
+// start it. A task that cannot be found is left for the change itself
+// to report.
+func (s *Server) startsTask(taskID string) bool {
+	task, err := s.store.GetTask(taskID)
+	return err == nil && !task.InProgress()
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// handleHookCommit records a commit from the user's git hook. It is linked
+// to the first task the message or branch refers to that exists and that
+// the hook reaches, and is otherwise left for the review page to link.
+func (s *Server) handleHookCommit(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.hookToken(w, r)
+	if !ok {
+		return
+	}
+	fields, err := hookFields(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	c := &domain.CommitEvent{
+		UserID:  t.UserID,
+		Repo:    strings.TrimSpace(fields["repo"]),
+		Branch:  strings.TrimSpace(fields["branch"]),
+		SHA:     strings.ToLower(strings.TrimSpace(fields["sha"])),
+		Message: strings.TrimSpace(fields["message"]),
+	}
+	if c.Repo == "" || c.Message == "" {
+		http.Error(w, "A commit needs a repo and a message", http.StatusBadRequest)
+		return
+	}
+	c.CommittedAt = s.clock.Now()
+	if v := fields["timestamp"]; v != "" {
+		if c.CommittedAt, err = parseCommitTime(v); err != nil {
+			http.Error(w, "timestamp must be RFC 3339 or unix seconds", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reach := s.hookReach(t)
+	for _, ref := range domain.CommitTaskRefs(c.Message, c.Branch) {
+		task, err := s.store.ResolveTaskRef(ref)
+		if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		if !reach(task.CategoryID) {
+			continue
+		}
+		c.TaskID = task.ID
+		break
+	}
+
+	commit, err := s.store.AddCommitEvent(c)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, commit)
+}
+
+// parseCommitTime reads a commit time as git formats it with %cI or %ct
+func parseCommitTime(v string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func (s *Server) handleGetWorkSessions(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	commits, err := s.store.GetPendingCommits(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	view := NewWorkSessionsView(domain.GroupWorkSessions(commits), categories, auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderWorkSessions(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleLogWorkSession confirms a suggested session as a work log, timed at
+// its last commit and leaving the task's completion as it is. The hours and
+// the task can be corrected first.
+func (s *Server) handleLogWorkSession(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	session, ok := s.ownWorkSession(w, r, auth)
+	if !ok {
+		return
+	}
+	hours, err := strconv.ParseFloat(r.FormValue("hours"), 64)
+	if err != nil || hours <= 0 {
+		http.Error(w, "Invalid hours value", http.StatusBadRequest)
+		return
+	}
+	taskID := r.FormValue("task_id")
+	if taskID == "" {
+		taskID = session.TaskID
+	}
+	if taskID == "" {
+		http.Error(w, "Choose the task the work was on", http.StatusBadRequest)
+		return
+	}
+
+	task, err := s.store.GetTask(taskID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	end := session.End
+	workLog, err := s.store.AddWorkLogForTask(task.ID, hours, r.FormValue("work_description"), task.Completion, &end, auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if err := s.store.SettleCommits(auth.Handle, session.CommitIDs()); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.notifyWorkLogged(auth, workLog)
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/work-sessions")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+// handleDismissWorkSession drops a suggested session without logging it
+func (s *Server) handleDismissWorkSession(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := parseRequestContext(r)
+
+	session, ok := s.ownWorkSession(w, r, auth)
+	if !ok {
+		return
+	}
+	if err := s.store.SettleCommits(auth.Handle, session.CommitIDs()); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !ctx.IsHTMX {
+		redirectBack(w, r, "/work-sessions")
+		return
+	}
+	w.Header().Set("HX-Trigger", "detailsChanged")
+}
+
+// ownWorkSession gathers the user's pending commits named in the form's
+// commit fields into the session they make up. Commits that are
+// someone else's or already settled are left out, and a session with none
+// left is treated as missing.
+func (s *Server) ownWorkSession(w http.ResponseWriter, r *http.Request, auth AuthContext) (*domain.WorkSession, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return nil, false
+	}
+	wanted := map[string]bool{}
+	for _, id := range r.Form["commit"] {
+		wanted[id] = true
+	}
+
+	pending, err := s.store.GetPendingCommits(auth.Handle)
+	if err != nil {
+		storeError(w, err)
+		return nil, false
+	}
+	var commits []*domain.CommitEvent
+	for _, c := range pending {
+		if wanted[c.ID] {
+			commits = append(commits, c)
+		}
+	}
+	sessions := domain.GroupWorkSessions(commits)
+	switch len(sessions) {
+	case 0:
+		storeError(w, fmt.Errorf("%w: the work session has already been logged or dismissed", domain.ErrNotFound))
+		return nil, false
+	case 1:
+		return sessions[0], true
+	}
+	http.Error(w, "The commits are from more than one work session", http.StatusBadRequest)
+	return nil, false
+}
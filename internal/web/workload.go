@@ -0,0 +1,70 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// handleGetWorkload shows what everyone planned for the coming weeks, from
+// the hours they allocated in their planners, so a shared board can see who
+// has taken on more than their capacity. The week query parameter picks the
+// first week shown.
+func (s *Server) handleGetWorkload(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	ctx := parseRequestContext(r)
+	if !auth.IsAuthenticated {
+		if ctx.WantsJSON {
+			apiError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	start := domain.WeekStart(s.clock.Now().In(auth.Location()))
+	if week := r.URL.Query().Get("week"); week != "" {
+		day, err := time.ParseInLocation(time.DateOnly, week, auth.Location())
+		if err != nil {
+			apiError(w, r, http.StatusBadRequest, "week must be written as YYYY-MM-DD")
+			return
+		}
+		start = domain.WeekStart(day)
+	}
+	end := start.AddDate(0, 0, 7*workloadWeeks)
+
+	workloads, err := s.store.GetWorkloads(start.Format(time.DateOnly), end.Format(time.DateOnly))
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if ctx.WantsJSON {
+		visible := []*domain.Workload{}
+		for _, wl := range workloads {
+			tasks := wl.Tasks[:0]
+			for _, t := range wl.Tasks {
+				if auth.CanSee(t.CategoryID) {
+					tasks = append(tasks, t)
+				}
+			}
+			if wl.Tasks = tasks; len(tasks) > 0 {
+				visible = append(visible, wl)
+			}
+		}
+		writeJSON(w, http.StatusOK, visible)
+		return
+	}
+
+	view := NewWorkloadView(start, workloads, s.clock.Now(), auth)
+
+	if !ctx.IsHTMX {
+		s.renderIndex(w, auth, view)
+		return
+	}
+
+	if err := s.presentation.RenderWorkload(w, view); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
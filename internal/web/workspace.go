@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+)
+
+// maxWorkspaceSize is how large an imported workspace may be
+const maxWorkspaceSize = 256 << 20
+
+// handleExportWorkspace downloads the board as plain JSON: its categories,
+// their tasks and subtasks, and the work logged on them, in board order.
+// Unlike a backup it leaves out history and attachments, so it can be read,
+// edited, and imported into another instance. Categories hidden from the
+// user are left out.
+func (s *Server) handleExportWorkspace(w http.ResponseWriter, r *http.Request) {
+	auth := s.getAuthContext(w, r)
+	if !auth.IsAuthenticated {
+		loginRedirect(w, r, auth)
+		return
+	}
+
+	ws, err := s.store.ExportWorkspace()
+	if err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+	visible := ws.Categories[:0]
+	for _, c := range ws.Categories {
+		if auth.CanSee(c.ID) {
+			visible = append(visible, c)
+		}
+	}
+	ws.Categories = visible
+
+	filename := "compass-workspace-" + ws.ExportedAt.Format("2006-01-02") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// handleImportWorkspace loads an exported workspace into the user's board, in
+// one transaction, as long as they have no categories of their own yet. It takes the file from an upload form, or the JSON itself
+// as the body with the CSRF token in the X-CSRF-Token header.
+func (s *Server) handleImportWorkspace(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxWorkspaceSize)
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	multipart := ct == "multipart/form-data"
+	if multipart {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			workspaceError(w, r, err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+	} else if ct != "application/json" {
+		apiError(w, r, http.StatusUnsupportedMediaType, "Upload a workspace file or send it as application/json")
+		return
+	}
+
+	auth, ok := s.requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var body io.Reader = r.Body
+	if multipart {
+		file, _, err := r.FormFile("workspace")
+		if err != nil {
+			apiError(w, r, http.StatusBadRequest, "Missing workspace file")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	var ws domain.Workspace
+	if err := json.NewDecoder(body).Decode(&ws); err != nil {
+		workspaceError(w, r, err)
+		return
+	}
+	if err := s.store.ImportWorkspace(&ws, auth.Handle); err != nil {
+		apiStoreError(w, r, err)
+		return
+	}
+
+	if !multipart {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	redirectBack(w, r, "/")
+}
+
+// workspaceError reports a workspace that could not be read
+func workspaceError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apiError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Workspaces are limited to %d MB", maxWorkspaceSize>>20))
+		return
+	}
+	apiError(w, r, http.StatusBadRequest, "That is not a Compass workspace: "+err.Error())
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Category is a category as the API lists it, with its tasks in board order
+type Category struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Public      bool    `json:"public"`
+	Completion  int     `json:"completion"` // 0-100, kept by the server from its tasks
+	OwnerID     string  `json:"owner_id"`   // empty for everyone's
+	Tasks       []*Task `json:"tasks"`
+}
+
+type Task struct {
+	ID          string     `json:"id"`
+	CategoryID  string     `json:"category_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Completion  int        `json:"completion"` // 0-100; with subtasks, kept by the server from theirs
+	Estimate    float64    `json:"estimate"`   // hours; 0 if unestimated
+	StartDate   string     `json:"start_date"` // YYYY-MM-DD; empty if unscheduled
+	DueDate     string     `json:"due_date"`   // YYYY-MM-DD; empty if there is no deadline
+	Subtasks    []*Subtask `json:"subtasks"`
+}
+
+type Subtask struct {
+	ID          string  `json:"id"`
+	TaskID      string  `json:"task_id"`
+	CategoryID  string  `json:"category_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Completion  int     `json:"completion"`
+	Estimate    float64 `json:"estimate"`
+	DueDate     string  `json:"due_date"`
+}
+
+type WorkLog struct {
+	ID                 string    `json:"id"`
+	CategoryID         string    `json:"category_id"`
+	TaskID             string    `json:"task_id"`
+	SubtaskID          string    `json:"subtask_id"` // empty for work on the task itself
+	HoursWorked        float64   `json:"hours_worked"`
+	WorkDescription    string    `json:"work_description"`
+	CompletionEstimate int       `json:"completion_estimate"` // 0-100
+	CreatedAt          time.Time `json:"created_at"`
+	Author             string    `json:"author"`
+}
+
+// Work is what LogWork records
+type Work struct {
+	Hours       float64 `json:"hours_worked"`
+	Description string  `json:"work_description"`
+	Completion  int     `json:"completion_estimate"` // how done the task is now, 0-100
+}
+
+// ListCategories lists the categories the client's user can see, in board
+// order
+func (c *Client) ListCategories(ctx context.Context) ([]*Category, error) {
+	var body struct {
+		Categories []*Category `json:"categories"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/categories", nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Categories, nil
+}
+
+// CreateTask adds a task named name to the bottom of a category. A name much
+// like one already there is refused as a "conflict" Error, as it would be on
+// the board.
+func (c *Client) CreateTask(ctx context.Context, categoryID, name string) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, http.MethodPost, "/categories/"+url.PathEscape(categoryID)+"/tasks", map[string]string{"name": name}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// LogWork records work done on a task, which moves its completion to
+// w.Completion
+func (c *Client) LogWork(ctx context.Context, taskID string, w Work) (*WorkLog, error) {
+	var log WorkLog
+	if err := c.call(ctx, http.MethodPost, "/tasks/"+url.PathEscape(taskID)+"/work-logs", w, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
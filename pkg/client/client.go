@@ -0,0 +1,246 @@
+// Package client talks to a compass server's JSON API, so other Go programs
+// can read the board, add tasks, and log work without building requests by
+// hand:
+//
+//	c := client.New("https://compass.example", token)
+//	categories, err := c.ListCategories(ctx)
+//
+// It signs in with the access token compass's sign-in keeps in the
+// accessToken cookie, and fetches the CSRF token changes need by itself.
+// Requests the server refused without acting on, and reads that failed on
+// the way, are retried.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiPrefix is where compass serves its JSON API
+const apiPrefix = "/api/v1"
+
+// Client calls one compass server as one user. Its fields may be changed
+// until the first request; it is safe for concurrent use after that.
+type Client struct {
+	BaseURL string       // where compass is served, such as https://compass.example
+	Token   string       // the access token sent as the accessToken cookie; empty for none
+	HTTP    *http.Client // Optional; defaults to http.DefaultClient
+
+	// Retries is how many more times a request is tried after a failure
+	// that is safe to repeat, waiting Backoff before the first retry and
+	// twice as long before each one after, or as long as the server asks.
+	Retries int
+	Backoff time.Duration
+
+	mu   sync.Mutex
+	csrf string
+}
+
+// New returns a client for the compass at baseURL, signed in with token
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		Retries: 2,
+		Backoff: 500 * time.Millisecond,
+	}
+}
+
+// Error is a request compass answered with an error
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`    // stable across releases, such as "not_found" or "conflict"
+	Message   string       `json:"message"` // for people; may change
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id"`
+}
+
+// FieldError is a problem with one field the client sent
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("compass: %s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+// staleCSRF reports whether the server refused the CSRF token, as it does
+// once the session behind it has been renewed
+func (e *Error) staleCSRF() bool {
+	for _, f := range e.Fields {
+		if f.Field == "csrf" {
+			return e.Status == http.StatusForbidden
+		}
+	}
+	return false
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// call sends body, if any, as JSON to the API route path and decodes the
+// answer into v, if it is non-nil
+func (c *Client) call(ctx context.Context, method, path string, body, v any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	renewed := false
+	for attempt := 0; ; attempt++ {
+		csrf := ""
+		if method != http.MethodGet {
+			var err error
+			if csrf, err = c.csrfToken(ctx); err != nil {
+				return err
+			}
+		}
+
+		resp, err := c.send(ctx, method, apiPrefix+path, payload, csrf)
+		if err != nil {
+			// A write may have been made before the connection failed
+			if method == http.MethodGet && attempt < c.Retries && ctx.Err() == nil {
+				if err := c.wait(ctx, attempt, 0); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if resp.StatusCode < http.StatusBadRequest {
+			defer resp.Body.Close()
+			if v == nil {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(v)
+		}
+
+		apiErr := readError(resp)
+		switch {
+		case apiErr.staleCSRF() && !renewed:
+			renewed = true
+			c.mu.Lock()
+			c.csrf = ""
+			c.mu.Unlock()
+			attempt--
+			continue
+		case retryable(method, resp.StatusCode) && attempt < c.Retries:
+			if err := c.wait(ctx, attempt, retryAfter(resp)); err != nil {
+				return err
+			}
+			continue
+		}
+		return apiErr
+	}
+}
+
+// send makes one request to path, which is relative to BaseURL
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, csrf string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if csrf != "" {
+		req.Header.Set("X-CSRF-Token", csrf)
+	}
+	if c.Token != "" {
+		req.AddCookie(&http.Cookie{Name: "accessToken", Value: c.Token})
+	}
+	return c.httpClient().Do(req)
+}
+
+// csrfToken is the token changes must carry, asked of the server the first
+// time and whenever the last one was refused
+func (c *Client) csrfToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.csrf != "" {
+		return c.csrf, nil
+	}
+	var me struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/me", nil, &me); err != nil {
+		return "", err
+	}
+	c.csrf = me.CSRFToken
+	return c.csrf, nil
+}
+
+// retryable reports whether a request answered with status may be sent
+// again. Too many requests were refused before anything was done; the
+// gateway and availability errors may have come after a write was made, so
+// only reads are repeated for them.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
+
+// retryAfter is how long the server asked to be left alone for, or 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// wait sleeps before retry attempt+1: asked if the server named a time,
+// otherwise Backoff doubled for each retry so far
+func (c *Client) wait(ctx context.Context, attempt int, asked time.Duration) error {
+	d := asked
+	if d == 0 {
+		d = c.Backoff << attempt
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// readError reads the error an API route answered with, and closes the body
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	var body struct {
+		Error *Error `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == nil {
+		body.Error = &Error{Code: "unknown", Message: strings.TrimSpace(string(data))}
+	}
+	body.Error.Status = resp.StatusCode
+	return body.Error
+}
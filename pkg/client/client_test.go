@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jakintosh/compass/internal/domain"
+	"git.sr.ht/~jakintosh/compass/internal/store"
+	"git.sr.ht/~jakintosh/compass/internal/web"
+)
+
+// asUser signs every request in as handle, the way a trusted proxy in front
+// of compass would
+type asUser struct{ handle string }
+
+func (u asUser) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Remote-User", u.handle)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newTestClient serves a compass over a temporary database and returns a
+// client signed in to it as ana, with the store behind it
+func newTestClient(t *testing.T) (*Client, *store.SQLiteStore) {
+	t.Helper()
+	clock := domain.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	st, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "compass.db"), false, clock, domain.NewSeededIDs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := web.NewServer(st, web.ServerOptions{Auth: (&web.HeaderAuth{Key: key}).Auth(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL, "")
+	c.HTTP = &http.Client{Transport: asUser{"ana"}}
+	c.Backoff = time.Millisecond
+	return c, st
+}
+
+func TestClientWorksTheBoard(t *testing.T) {
+	c, st := newTestClient(t)
+	ctx := context.Background()
+	garden, err := st.AddCategory("Garden", "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := c.CreateTask(ctx, garden.ID, "Turn the compost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.CategoryID != garden.ID || task.Name != "Turn the compost" {
+		t.Errorf("created %+v", task)
+	}
+	log, err := c.LogWork(ctx, task.ID, Work{Hours: 1.5, Description: "Turned half", Completion: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.TaskID != task.ID || log.HoursWorked != 1.5 || log.Author != "ana" {
+		t.Errorf("logged %+v", log)
+	}
+
+	categories, err := c.ListCategories(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || len(categories[0].Tasks) != 1 || categories[0].Tasks[0].Completion != 50 {
+		t.Fatalf("listed %+v", categories)
+	}
+
+	_, err = c.LogWork(ctx, "no-such-task", Work{Hours: 1, Completion: 50})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("logging work on a missing task: got %v, want not_found", err)
+	}
+}
+
+func TestClientHearsTheBoardChange(t *testing.T) {
+	c, st := newTestClient(t)
+	garden, err := st.AddCategory("Garden", "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateTask(ctx, garden.ID, "Turn the compost"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the new task")
+	}
+
+	cancel()
+	for range changed {
+	}
+}
+
+func TestClientRetriesWhatIsSafeToRepeat(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := calls.Add(1); {
+		case r.URL.Path == "/api/v1/me":
+			w.Write([]byte(`{"csrf_token": "token"}`))
+		case r.Method == http.MethodGet && n == 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case r.Method == http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"code": "unavailable", "message": "Down for a moment"}}`))
+		default:
+			w.Write([]byte(`{"categories": []}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	c := New(ts.URL, "")
+	c.Backoff = time.Millisecond
+	ctx := context.Background()
+
+	if _, err := c.ListCategories(ctx); err != nil {
+		t.Errorf("a read wasn't retried: %v", err)
+	}
+	calls.Store(0)
+	_, err := c.CreateTask(ctx, "garden", "Turn the compost")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "unavailable" {
+		t.Errorf("creating a task: got %v, want unavailable", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d calls to create a task, want 2: the CSRF token and one try", n)
+	}
+}
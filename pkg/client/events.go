@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// maxEventLine is the longest line of an event stream read. Board events
+// carry the rendered board, a line of it at a time.
+const maxEventLine = 1 << 20
+
+// Subscribe follows the board's server-sent events at /events, signaling the
+// returned channel each time the board changes. A signal carries nothing:
+// read what you need again. Signals arriving while one waits are merged
+// into it. The server ends each stream after a while, and Subscribe
+// reconnects; the channel is closed once ctx is done or the server can't be
+// reached again after Retries tries. Only connecting the first time is
+// reported as an error.
+func (c *Client) Subscribe(ctx context.Context) (<-chan struct{}, error) {
+	resp, err := c.openEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer close(changed)
+		for {
+			readEvents(resp, changed)
+			resp = nil
+			for attempt := 0; resp == nil; attempt++ {
+				if ctx.Err() != nil || attempt > c.Retries {
+					return
+				}
+				if attempt > 0 {
+					if c.wait(ctx, attempt-1, 0) != nil {
+						return
+					}
+				}
+				resp, _ = c.openEvents(ctx)
+			}
+		}
+	}()
+	return changed, nil
+}
+
+// openEvents starts a stream of the board's events
+func (c *Client) openEvents(ctx context.Context) (*http.Response, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/events", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	return resp, nil
+}
+
+// readEvents signals changed for each board event until the stream ends
+func readEvents(resp *http.Response, changed chan<- struct{}) {
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, maxEventLine)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "board" {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		}
+	}
+}